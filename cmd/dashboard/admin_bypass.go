@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/nezhahq/nezha/pkg/utils"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+const adminBypassUsage = `用法: dashboard admin-bypass [参数]
+
+  生成一次性应急链接，在受 admin_allowed_cidrs 限制的网络以外打开后，
+  当前浏览器可访问管理后台 12 小时
+
+`
+
+// runAdminBypass 生成允许从受限网段以外访问管理后台的一次性链接
+func runAdminBypass(args []string) error {
+	fs := flag.NewFlagSet("admin-bypass", flag.ExitOnError)
+	configFile := fs.String("c", "data/config.yaml", "配置文件路径")
+	ttl := fs.Duration("ttl", 15*time.Minute, "链接有效期")
+	base := fs.String("url", "", "面板地址，如 https://nezha.example.com")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), adminBypassUsage)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if err := utils.FirstError(singleton.InitFrontendTemplates,
+		func() error { return singleton.InitConfigFromPath(*configFile) },
+	); err != nil {
+		return err
+	}
+
+	link := strings.TrimSuffix(*base, "/") + "/api/v1/admin-bypass?token=" + url.QueryEscape(singleton.IssueAdminBypass(*ttl))
+	fmt.Println(link)
+	fmt.Printf("expires at %s\n", time.Now().Add(*ttl).Format(time.DateTime))
+	return nil
+}
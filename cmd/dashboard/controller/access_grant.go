@@ -0,0 +1,210 @@
+package controller
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/service/rpc"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+// List access grants
+// @Summary List access grants
+// @Schemes
+// @Description List terminal, exec and file access grants
+// @Security BearerAuth
+// @Tags admin required
+// @Produce json
+// @Success 200 {object} model.CommonResponse[[]model.AccessGrant]
+// @Router /access-grant [get]
+func listAccessGrant(c *gin.Context) ([]*model.AccessGrant, error) {
+	return singleton.AccessGrantShared.GetSortedList(), nil
+}
+
+// Add access grant
+// @Summary Add access grant
+// @Security BearerAuth
+// @Schemes
+// @Description Grant a user terminal, exec or file access to a server or server group
+// @Tags admin required
+// @Accept json
+// @param request body model.AccessGrantForm true "Access Grant Request"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[uint64]
+// @Router /access-grant [post]
+func createAccessGrant(c *gin.Context) (uint64, error) {
+	var gf model.AccessGrantForm
+	if err := c.ShouldBindJSON(&gf); err != nil {
+		return 0, err
+	}
+
+	var g model.AccessGrant
+	g.UserID = getUid(c)
+	if err := applyAccessGrantForm(&g, &gf); err != nil {
+		return 0, err
+	}
+
+	if err := singleton.DB.Create(&g).Error; err != nil {
+		return 0, newGormError("%v", err)
+	}
+
+	singleton.AccessGrantShared.Update(&g)
+	return g.ID, nil
+}
+
+// Edit access grant
+// @Summary Edit access grant
+// @Security BearerAuth
+// @Schemes
+// @Description Edit access grant
+// @Tags admin required
+// @Accept json
+// @param id path uint true "Grant ID"
+// @param request body model.AccessGrantForm true "Access Grant Request"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /access-grant/{id} [patch]
+func updateAccessGrant(c *gin.Context) (any, error) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	var gf model.AccessGrantForm
+	if err := c.ShouldBindJSON(&gf); err != nil {
+		return nil, err
+	}
+
+	var g model.AccessGrant
+	if err := singleton.DB.First(&g, id).Error; err != nil {
+		return nil, singleton.Localizer.ErrorT("grant id %d does not exist", id)
+	}
+	if err := applyAccessGrantForm(&g, &gf); err != nil {
+		return nil, err
+	}
+
+	if err := singleton.DB.Save(&g).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+
+	singleton.AccessGrantShared.Update(&g)
+	return nil, nil
+}
+
+// Batch delete access grants
+// @Summary Batch delete access grants
+// @Security BearerAuth
+// @Schemes
+// @Description Batch delete access grants
+// @Tags admin required
+// @Accept json
+// @param request body []uint64 true "id list"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /batch-delete/access-grant [post]
+func batchDeleteAccessGrant(c *gin.Context) (any, error) {
+	var ids []uint64
+	if err := c.ShouldBindJSON(&ids); err != nil {
+		return nil, err
+	}
+
+	if err := singleton.DB.Unscoped().Delete(&model.AccessGrant{}, "id in (?)", ids).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+
+	singleton.AccessGrantShared.Delete(ids)
+	return nil, nil
+}
+
+func applyAccessGrantForm(g *model.AccessGrant, gf *model.AccessGrantForm) error {
+	singleton.UserLock.RLock()
+	target, ok := singleton.UserInfoMap[gf.TargetUserID]
+	singleton.UserLock.RUnlock()
+	if !ok {
+		return singleton.Localizer.ErrorT("user id %d does not exist", gf.TargetUserID)
+	}
+
+	if (gf.ServerID == 0) == (gf.ServerGroupID == 0) {
+		return singleton.Localizer.ErrorT("exactly one of server or server group is required")
+	}
+	// 授权不能让用户访问原本无权访问的服务器，非管理员只能获得自己服务器的授权
+	serverIDs := []uint64{gf.ServerID}
+	if gf.ServerID != 0 {
+		if _, ok := singleton.ServerShared.Get(gf.ServerID); !ok {
+			return singleton.Localizer.ErrorT("server id %d does not exist", gf.ServerID)
+		}
+	} else {
+		var count int64
+		if err := singleton.DB.Model(&model.ServerGroup{}).Where("id = ?", gf.ServerGroupID).Count(&count).Error; err != nil {
+			return newGormError("%v", err)
+		}
+		if count == 0 {
+			return singleton.Localizer.ErrorT("group id %d does not exist", gf.ServerGroupID)
+		}
+		serverIDs = singleton.ServerShared.GroupMembers(gf.ServerGroupID)
+	}
+	if target.Role != model.RoleAdmin {
+		for _, id := range serverIDs {
+			if s, ok := singleton.ServerShared.Get(id); ok && s.UserID != gf.TargetUserID {
+				return singleton.Localizer.ErrorT("user id %d cannot access server id %d", gf.TargetUserID, id)
+			}
+		}
+	}
+	if !gf.Terminal && !gf.Exec && !gf.File {
+		return singleton.Localizer.ErrorT("at least one permission is required")
+	}
+	if gf.ExpiresAt != nil && !gf.ExpiresAt.After(time.Now()) {
+		return singleton.Localizer.ErrorT("expiry must be in the future")
+	}
+
+	g.TargetUserID = gf.TargetUserID
+	g.ServerID = gf.ServerID
+	g.ServerGroupID = gf.ServerGroupID
+	g.Terminal = gf.Terminal
+	g.Exec = gf.Exec
+	g.File = gf.File
+	g.ExpiresAt = gf.ExpiresAt
+	return nil
+}
+
+// checkServerAccess 检查当前用户能否对服务器执行终端、文件管理、命令执行等操作，
+// 管理员不受限制，其他用户在需要授权时必须持有有效的授权。拒绝时记录审计日志并返回 403
+func checkServerAccess(c *gin.Context, server *model.Server, access string) error {
+	user := c.MustGet(model.CtxKeyAuthorizedUser).(*model.User)
+	switch {
+	case !server.HasPermission(c):
+	case user.Role == model.RoleAdmin:
+		return nil
+	case !model.AccessRequiresGrant(access, singleton.Conf.RequireAccessGrants):
+		return nil
+	case singleton.AccessGrantShared.Allowed(user.ID, server.ID, access):
+		return nil
+	}
+
+	err := singleton.Localizer.ErrorT("permission denied")
+	if !auditedRequest(c) {
+		recordAccessDenied(c, server.ID, access, err)
+	}
+	return &forbiddenError{err: err}
+}
+
+// checkStreamAccess 用户连接终端、文件管理流时再次校验权限，避免授权撤销后仍可使用已创建的流
+func checkStreamAccess(c *gin.Context, streamId string, access string) error {
+	stream, err := rpc.NezhaHandlerSingleton.GetStream(streamId)
+	if err != nil {
+		return err
+	}
+	userID, serverID := stream.Owner()
+	if userID != getUid(c) {
+		return &forbiddenError{err: singleton.Localizer.ErrorT("permission denied")}
+	}
+	server, _ := singleton.ServerShared.Get(serverID)
+	if server == nil {
+		return singleton.Localizer.ErrorT("server not found or not connected")
+	}
+	return checkServerAccess(c, server, access)
+}
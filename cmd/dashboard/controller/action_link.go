@@ -0,0 +1,122 @@
+package controller
+
+import (
+	"html/template"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+var actionLinkPage = template.Must(template.New("action").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+</head>
+<body style="font-family: sans-serif; text-align: center; padding-top: 20vh">
+<h2>{{.Title}}</h2>
+<p>{{.Message}}</p>
+{{- if .Confirm}}
+<form method="post"><button type="submit">{{.Confirm}}</button></form>
+{{- end}}
+</body>
+</html>`))
+
+type actionLinkPageData struct {
+	Title   string
+	Message string
+	Confirm string // 不为空时显示确认按钮，避免聊天软件预览链接时触发操作
+}
+
+func renderActionLinkPage(c *gin.Context, code int, data actionLinkPageData) {
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Header("Cache-Control", "no-store")
+	c.Status(code)
+	actionLinkPage.Execute(c.Writer, data)
+}
+
+// Silence server alerts via action link
+// @Summary Silence server alerts via action link
+// @Schemes
+// @Description Signed link embedded in alert notifications, GET shows a confirmation page and POST stops alert notifications of the server for 1 hour
+// @Tags common
+// @Param id path uint true "Server ID"
+// @Param channel query uint true "Notification ID the link was sent to"
+// @Param exp query int true "Expiry timestamp in seconds"
+// @Param sig query string true "Signature"
+// @Produce html
+// @Success 200 {string} string
+// @Router /server/{id}/silence [get]
+func silenceServerByLink(c *gin.Context) (any, error) {
+	t := singleton.Localizer
+	return serverActionByLink(c, singleton.ActionSilenceServer, t.T("Silence this server for 1 hour"), func(s *model.Server, channel uint64, _ time.Time) (string, error) {
+		until, err := singleton.SilenceServer(s.ID, channel)
+		if err != nil {
+			return "", err
+		}
+		return t.Tf("Alert notifications are silenced until %s", until.In(singleton.Loc).Format(time.DateTime)), nil
+	})
+}
+
+// Acknowledge server alerts via action link
+// @Summary Acknowledge server alerts via action link
+// @Schemes
+// @Description Signed link embedded in alert notifications, GET shows a confirmation page and POST acknowledges the alerts that were already firing on the server when the link was issued and have not resolved since, repeated notifications stop until they resolve
+// @Tags common
+// @Param id path uint true "Server ID"
+// @Param channel query uint true "Notification ID the link was sent to"
+// @Param exp query int true "Expiry timestamp in seconds"
+// @Param sig query string true "Signature"
+// @Produce html
+// @Success 200 {string} string
+// @Router /server/{id}/ack [get]
+func ackServerByLink(c *gin.Context) (any, error) {
+	t := singleton.Localizer
+	return serverActionByLink(c, singleton.ActionAckServer, t.T("Acknowledge alerts of this server"), func(s *model.Server, channel uint64, issued time.Time) (string, error) {
+		if err := singleton.AckServerAlerts(s, channel, issued); err != nil {
+			return "", err
+		}
+		return t.T("Alerts are acknowledged, repeated notifications stop until they resolve"), nil
+	})
+}
+
+// serverActionByLink 校验操作链接，GET 显示确认页面，POST 执行操作，issued 为链接生成的时间
+func serverActionByLink(c *gin.Context, action, prompt string, apply func(s *model.Server, channel uint64, issued time.Time) (string, error)) (any, error) {
+	t := singleton.Localizer
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	channel, _ := strconv.ParseUint(c.Query("channel"), 10, 64)
+	exp, _ := strconv.ParseInt(c.Query("exp"), 10, 64)
+	issued, err := singleton.VerifyActionLink(action, id, channel, exp, c.Query("sig"))
+	if err != nil {
+		renderActionLinkPage(c, http.StatusForbidden, actionLinkPageData{Title: t.T("Failed"), Message: err.Error()})
+		return nil, errNoop
+	}
+	server, ok := singleton.ServerShared.Get(id)
+	if !ok {
+		renderActionLinkPage(c, http.StatusNotFound, actionLinkPageData{Title: t.T("Failed"), Message: t.Tf("server id %d does not exist", id)})
+		return nil, errNoop
+	}
+
+	if c.Request.Method != http.MethodPost {
+		renderActionLinkPage(c, http.StatusOK, actionLinkPageData{
+			Title:   server.Name,
+			Message: prompt,
+			Confirm: t.T("Confirm"),
+		})
+		return nil, errNoop
+	}
+
+	msg, err := apply(server, channel, issued)
+	if err != nil {
+		renderActionLinkPage(c, http.StatusInternalServerError, actionLinkPageData{Title: t.T("Failed"), Message: err.Error()})
+		return nil, errNoop
+	}
+	renderActionLinkPage(c, http.StatusOK, actionLinkPageData{Title: server.Name, Message: msg})
+	return nil, errNoop
+}
@@ -0,0 +1,55 @@
+package controller
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/nezhahq/nezha/cmd/dashboard/controller/waf"
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+// adminAccessAllowed 请求来自允许的网段，或持有有效的应急放行 Cookie
+func adminAccessAllowed(c *gin.Context) bool {
+	ip, _ := waf.RequestRealIP(c.Request)
+	if singleton.AdminNetworkAllowed(ip) {
+		return true
+	}
+	cookie, err := c.Cookie(model.AdminBypassCookie)
+	return err == nil && singleton.ValidAdminBypassCookie(cookie)
+}
+
+// adminNetworkGuard 拒绝受限网段以外的请求，返回 404 以免暴露管理接口
+func adminNetworkGuard(c *gin.Context) {
+	if adminAccessAllowed(c) {
+		c.Next()
+		return
+	}
+	c.AbortWithStatusJSON(http.StatusNotFound, newErrorResponse(errors.New("404 Not Found")))
+}
+
+// mutationNetworkGuard 对修改类请求执行 adminNetworkGuard
+func mutationNetworkGuard(c *gin.Context) {
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		c.Next()
+	default:
+		adminNetworkGuard(c)
+	}
+}
+
+// adminBypass 使用 dashboard admin-bypass 生成的一次性链接，临时允许当前浏览器访问管理区域
+func adminBypass(c *gin.Context) {
+	ip, _ := waf.RequestRealIP(c.Request)
+	if err := singleton.ConsumeAdminBypass(c.Query("token")); err != nil {
+		log.Printf("NEZHA>> Rejected admin bypass from %s: %v", ip, err)
+		c.JSON(http.StatusNotFound, newErrorResponse(errors.New("404 Not Found")))
+		return
+	}
+	log.Printf("NEZHA>> Admin bypass used from %s", ip)
+	c.SetCookie(model.AdminBypassCookie, singleton.AdminBypassCookieValue(), int(singleton.AdminBypassDuration.Seconds()), "/", "", requestIsHTTPS(c.Request), true)
+	c.Redirect(http.StatusFound, "/dashboard/")
+}
@@ -0,0 +1,113 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+func setupAdminNetworkTest(t *testing.T) *gin.Engine {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	config := "admin_allowed_cidrs:\n  - 10.0.0.0/8\njwt_secret_key: secret\nagent_secret_key: secret\n"
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	oldConf, oldDB := singleton.Conf, singleton.DB
+	if err := singleton.InitConfigFromPath(path); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(dir, "sqlite.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(model.AdminBypassUse{}); err != nil {
+		t.Fatal(err)
+	}
+	singleton.DB = db
+	t.Cleanup(func() {
+		singleton.Conf, singleton.DB = oldConf, oldDB
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/admin-bypass", adminBypass)
+	r.GET("/admin", adminNetworkGuard, func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	return r
+}
+
+func serveAdminNetworkTest(r *gin.Engine, target, remoteAddr string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	req.RemoteAddr = remoteAddr
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestAdminNetworkGuard(t *testing.T) {
+	r := setupAdminNetworkTest(t)
+
+	if w := serveAdminNetworkTest(r, "/admin", "10.1.2.3:1234"); w.Code != http.StatusNoContent {
+		t.Fatalf("expected allowed network to pass, got %d", w.Code)
+	}
+	// 受限网段以外返回 404，不暴露管理接口
+	if w := serveAdminNetworkTest(r, "/admin", "203.0.113.1:1234"); w.Code != http.StatusNotFound {
+		t.Fatalf("expected other networks to get 404, got %d", w.Code)
+	}
+	cookie := &http.Cookie{Name: model.AdminBypassCookie, Value: singleton.IssueAdminBypass(time.Minute)}
+	if w := serveAdminNetworkTest(r, "/admin", "203.0.113.1:1234", cookie); w.Code != http.StatusNotFound {
+		t.Fatalf("expected link token to be rejected as a cookie, got %d", w.Code)
+	}
+}
+
+func TestAdminBypass(t *testing.T) {
+	r := setupAdminNetworkTest(t)
+
+	if w := serveAdminNetworkTest(r, "/admin-bypass?token=invalid", "203.0.113.1:1234"); w.Code != http.StatusNotFound {
+		t.Fatalf("expected invalid token to get 404, got %d", w.Code)
+	} else if len(w.Result().Cookies()) != 0 {
+		t.Fatal("expected no cookie for invalid token")
+	}
+
+	target := "/admin-bypass?token=" + url.QueryEscape(singleton.IssueAdminBypass(time.Minute))
+	w := serveAdminNetworkTest(r, target, "203.0.113.1:1234")
+	if w.Code != http.StatusFound {
+		t.Fatalf("expected valid token to redirect, got %d", w.Code)
+	}
+	var cookie *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		if c.Name == model.AdminBypassCookie {
+			cookie = c
+		}
+	}
+	if cookie == nil || !cookie.HttpOnly {
+		t.Fatal("expected an http-only bypass cookie")
+	}
+
+	if w := serveAdminNetworkTest(r, "/admin", "203.0.113.1:1234", cookie); w.Code != http.StatusNoContent {
+		t.Fatalf("expected bypass cookie to pass the guard, got %d", w.Code)
+	}
+	// 链接只能使用一次
+	if w := serveAdminNetworkTest(r, target, "203.0.113.1:1234"); w.Code != http.StatusNotFound {
+		t.Fatalf("expected used token to get 404, got %d", w.Code)
+	}
+}
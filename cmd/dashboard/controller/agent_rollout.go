@@ -0,0 +1,112 @@
+package controller
+
+import (
+	"slices"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+// List agent rollouts
+// @Summary List agent rollouts
+// @Security BearerAuth
+// @Schemes
+// @Description List agent upgrade rollouts, newest first
+// @Tags auth required
+// @Produce json
+// @Success 200 {object} model.CommonResponse[[]model.AgentRollout]
+// @Router /agent-rollouts [get]
+func listAgentRollout(c *gin.Context) ([]*model.AgentRollout, error) {
+	var rollouts []*model.AgentRollout
+	if err := singleton.DB.Order("id DESC").Limit(100).Find(&rollouts).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+	return slices.DeleteFunc(rollouts, func(r *model.AgentRollout) bool {
+		return !r.HasPermission(c)
+	}), nil
+}
+
+// Create agent rollout
+// @Summary Create agent rollout
+// @Security BearerAuth
+// @Schemes
+// @Description Upgrade the agents of the selected servers to a version in batches. Servers already on the target version are skipped, the rollout pauses when the failure threshold is reached.
+// @Tags auth required
+// @Accept json
+// @Param request body model.AgentRolloutForm true "Rollout Request"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.AgentRollout]
+// @Router /agent-rollouts [post]
+func createAgentRollout(c *gin.Context) (*model.AgentRollout, error) {
+	var rf model.AgentRolloutForm
+	if err := c.ShouldBindJSON(&rf); err != nil {
+		return nil, err
+	}
+	if rf.TargetVersion == "" {
+		return nil, singleton.Localizer.ErrorT("target version is required")
+	}
+	if rf.Selector.IsEmpty() {
+		return nil, singleton.Localizer.ErrorT("server selector is required")
+	}
+	if err := checkServerSelectorPermission(c, rf.Selector); err != nil {
+		return nil, err
+	}
+
+	return singleton.AgentRolloutShared.Create(getUid(c), &rf)
+}
+
+// Get agent rollout
+// @Summary Get agent rollout
+// @Security BearerAuth
+// @Schemes
+// @Description Get an agent rollout with the upgrade status of each server
+// @Tags auth required
+// @Param id path uint true "Rollout ID"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.AgentRollout]
+// @Router /agent-rollouts/{id} [get]
+func getAgentRollout(c *gin.Context) (*model.AgentRollout, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := singleton.AgentRolloutShared.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if !r.HasPermission(c) {
+		return nil, singleton.Localizer.ErrorT("permission denied")
+	}
+	return r, nil
+}
+
+// Control agent rollout
+// @Summary Control agent rollout
+// @Security BearerAuth
+// @Schemes
+// @Description Pause, resume or abort a rollout. Upgrades already sent to agents are not reverted.
+// @Tags auth required
+// @Param id path uint true "Rollout ID"
+// @Param action path string true "Action" Enums(pause, resume, abort)
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /agent-rollouts/{id}/{action} [post]
+func controlAgentRollout(c *gin.Context) (any, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := singleton.AgentRolloutShared.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if !r.HasPermission(c) {
+		return nil, singleton.Localizer.ErrorT("permission denied")
+	}
+	return nil, singleton.AgentRolloutShared.Control(id, c.Param("action"))
+}
@@ -2,6 +2,7 @@ package controller
 
 import (
 	"maps"
+	"slices"
 	"strconv"
 	"time"
 
@@ -26,8 +27,10 @@ func listAlertRule(c *gin.Context) ([]*model.AlertRule, error) {
 	singleton.AlertsLock.RLock()
 	defer singleton.AlertsLock.RUnlock()
 
+	// 只复制有权限访问的规则
+	alerts := filter(c, slices.Clone(singleton.Alerts))
 	var ar []*model.AlertRule
-	if err := copier.Copy(&ar, &singleton.Alerts); err != nil {
+	if err := copier.Copy(&ar, &alerts); err != nil {
 		return nil, err
 	}
 	return ar, nil
@@ -73,6 +76,7 @@ func createAlertRule(c *gin.Context) (uint64, error) {
 	}
 
 	singleton.OnRefreshOrAddAlert(&r)
+	singleton.ClusterShared.PublishChange("alert-rule")
 	return r.ID, nil
 }
 
@@ -127,6 +131,7 @@ func updateAlertRule(c *gin.Context) (any, error) {
 	}
 
 	singleton.OnRefreshOrAddAlert(&r)
+	singleton.ClusterShared.PublishChange("alert-rule")
 	return r.ID, nil
 }
 
@@ -163,6 +168,7 @@ func batchDeleteAlertRule(c *gin.Context) (any, error) {
 	}
 
 	singleton.OnDeleteAlert(ar)
+	singleton.ClusterShared.PublishChange("alert-rule")
 	return nil, nil
 }
 
@@ -173,6 +179,42 @@ func validateRule(c *gin.Context, r *model.AlertRule) error {
 				return singleton.Localizer.ErrorT("permission denied")
 			}
 
+			if rule.Type == "container" && rule.Container == "" {
+				return singleton.Localizer.ErrorT("container name is not set")
+			}
+
+			if rule.Type == "agent_version" && rule.AgentVersion != "" && !model.ValidAgentVersion(rule.AgentVersion) {
+				return singleton.Localizer.ErrorT("invalid agent version %s", rule.AgentVersion)
+			}
+
+			if rule.IsConnGrowthRule() && rule.GrowthWindow() > model.ConnStatsWindow {
+				return singleton.Localizer.ErrorT("window need to be at most %d", int(model.ConnStatsWindow.Seconds()))
+			}
+
+			if rule.IsFieldStaleRule() && !slices.Contains(model.StaleFields, rule.StaleField) {
+				return singleton.Localizer.ErrorT("invalid stale field: %s", rule.StaleField)
+			}
+
+			if name, ok := rule.CustomMetric(); ok && !model.ValidCustomMetricName(name) {
+				return singleton.Localizer.ErrorT("invalid custom metric name: %s", name)
+			}
+
+			if rule.IsAggregateRule() != r.IsAggregate() {
+				return singleton.Localizer.ErrorT("aggregate rules cannot be mixed with other rules")
+			}
+			if rule.IsServiceDownRule() != r.IsServiceAlert() {
+				return singleton.Localizer.ErrorT("service rules cannot be mixed with other rules")
+			}
+			if rule.IsServiceDownRule() && !singleton.ServiceSentinelShared.CheckPermission(c, slices.Values(rule.Services)) {
+				return singleton.Localizer.ErrorT("permission denied")
+			}
+
+			if rule.IsAggregateRule() {
+				if err := validateAggregateRule(c, rule); err != nil {
+					return err
+				}
+			}
+
 			if !rule.IsTransferDurationRule() {
 				if rule.Duration < 3 {
 					return singleton.Localizer.ErrorT("duration need to be at least 3")
@@ -194,3 +236,36 @@ func validateRule(c *gin.Context, r *model.AlertRule) error {
 	}
 	return nil
 }
+
+// validateAggregateRule 汇总规则需要指定服务器范围与对单台服务器检查的条件
+func validateAggregateRule(c *gin.Context, rule *model.Rule) error {
+	if rule.Selector.IsEmpty() {
+		return singleton.Localizer.ErrorT("server selector is not set")
+	}
+	if err := checkServerSelectorPermission(c, rule.Selector); err != nil {
+		return err
+	}
+	cond := rule.Condition
+	if cond == nil || cond.IsAggregateRule() || cond.IsServiceDownRule() || cond.IsTransferDurationRule() {
+		return singleton.Localizer.ErrorT("invalid aggregate condition")
+	}
+	if cond.Type == "container" && cond.Container == "" {
+		return singleton.Localizer.ErrorT("container name is not set")
+	}
+	if cond.Type == "agent_version" && cond.AgentVersion != "" && !model.ValidAgentVersion(cond.AgentVersion) {
+		return singleton.Localizer.ErrorT("invalid agent version %s", cond.AgentVersion)
+	}
+	if cond.IsConnGrowthRule() && cond.GrowthWindow() > model.ConnStatsWindow {
+		return singleton.Localizer.ErrorT("window need to be at most %d", int(model.ConnStatsWindow.Seconds()))
+	}
+	if cond.IsFieldStaleRule() && !slices.Contains(model.StaleFields, cond.StaleField) {
+		return singleton.Localizer.ErrorT("invalid stale field: %s", cond.StaleField)
+	}
+	if name, ok := cond.CustomMetric(); ok && !model.ValidCustomMetricName(name) {
+		return singleton.Localizer.ErrorT("invalid custom metric name: %s", name)
+	}
+	if rule.Min <= 0 && rule.Max <= 0 {
+		return singleton.Localizer.ErrorT("min or max must be set")
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package controller
+
+import (
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/nezhahq/nezha/cmd/dashboard/controller/waf"
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/utils"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+// tokenRouteScopes 需要特定权限的路由，未列出的写操作仅 admin 权限可以访问
+var tokenRouteScopes = map[string]string{
+	"PATCH /api/v1/server/:id":                  model.APITokenScopeServerWrite,
+	"POST /api/v1/server/config":                model.APITokenScopeServerWrite,
+	"POST /api/v1/batch-delete/server":          model.APITokenScopeServerWrite,
+	"POST /api/v1/batch-move/server":            model.APITokenScopeServerWrite,
+	"POST /api/v1/force-update/server":          model.APITokenScopeServerWrite,
+	"POST /api/v1/server-group":                 model.APITokenScopeServerWrite,
+	"PATCH /api/v1/server-group/:id":            model.APITokenScopeServerWrite,
+	"POST /api/v1/batch-delete/server-group":    model.APITokenScopeServerWrite,
+	"POST /api/v1/ingest/server":                model.APITokenScopeServerWrite,
+	"POST /api/v1/ingest/annotation":            model.APITokenScopeAnnotation,
+	"GET /api/v1/cron/:id/manual":               model.APITokenScopeCronExecute,
+	"POST /api/v1/cron/:id/run":                 model.APITokenScopeCronExecute,
+	"POST /api/v1/server/:id/exec":              model.APITokenScopeCronExecute,
+	"GET /api/v1/refresh-token":                 model.APITokenScopeAdmin,
+	"GET /api/v1/file":                          model.APITokenScopeAdmin,
+	"GET /api/v1/ws/file/:id":                   model.APITokenScopeAdmin,
+	"GET /api/v1/ws/terminal/:id":               model.APITokenScopeAdmin,
+	"GET /api/v1/server/:id/exec/:execution_id": model.APITokenScopeCronExecute,
+}
+
+func tokenScopeAllows(t *model.APIToken, method, path string) bool {
+	if scope, ok := tokenRouteScopes[method+" "+path]; ok {
+		return t.HasScope(scope)
+	}
+	if method == http.MethodGet || method == http.MethodHead {
+		// 任意权限均包含只读
+		return len(t.Scopes) > 0
+	}
+	return t.HasScope(model.APITokenScopeAdmin)
+}
+
+// tokenAuthMiddleware 使用 API Token 认证，请求未携带 API Token 时交由 next 处理
+func tokenAuthMiddleware(next gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || !strings.HasPrefix(token, model.APITokenPrefix) {
+			next(c)
+			return
+		}
+
+		realip := c.GetString(model.CtxKeyRealIPStr)
+		t, user, err := singleton.APITokenShared.Resolve(token)
+		if err != nil {
+			if err := model.BlockIP(singleton.DB, realip, model.WAFBlockReasonTypeBruteForceToken, model.BlockIDToken); err != nil {
+				waf.ShowBlockPage(c, err)
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusOK, model.CommonResponse[any]{
+				Success: false,
+				Error:   "ApiErrorUnauthorized",
+			})
+			return
+		}
+		model.UnblockIP(singleton.DB, realip, model.BlockIDToken)
+
+		if !tokenScopeAllows(t, c.Request.Method, c.FullPath()) {
+			c.AbortWithStatusJSON(http.StatusOK, newErrorResponse(singleton.Localizer.ErrorT("permission denied")))
+			return
+		}
+
+		c.Set(model.CtxKeyAuthorizedUser, user)
+		c.Set(model.CtxKeyAPIToken, t)
+		c.Next()
+	}
+}
+
+// 不允许使用 API Token 管理 API Token
+func rejectAPIToken(c *gin.Context) error {
+	if _, ok := c.Get(model.CtxKeyAPIToken); ok {
+		return singleton.Localizer.ErrorT("permission denied")
+	}
+	return nil
+}
+
+// List API tokens
+// @Summary List API tokens
+// @Security BearerAuth
+// @Schemes
+// @Description List API tokens of the current user
+// @Tags auth required
+// @Produce json
+// @Success 200 {object} model.CommonResponse[[]model.APIToken]
+// @Router /user/tokens [get]
+func listAPIToken(c *gin.Context) ([]*model.APIToken, error) {
+	if err := rejectAPIToken(c); err != nil {
+		return nil, err
+	}
+
+	tokens, err := singleton.APITokenShared.List(getUid(c))
+	if err != nil {
+		return nil, newGormError("%v", err)
+	}
+	return tokens, nil
+}
+
+// Create API token
+// @Summary Create API token
+// @Security BearerAuth
+// @Schemes
+// @Description Create an API token, the token is only returned once
+// @Tags auth required
+// @Accept json
+// @param request body model.APITokenForm true "APITokenForm"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.APITokenCreated]
+// @Router /user/tokens [post]
+func createAPIToken(c *gin.Context) (*model.APITokenCreated, error) {
+	if err := rejectAPIToken(c); err != nil {
+		return nil, err
+	}
+
+	var tf model.APITokenForm
+	if err := c.ShouldBindJSON(&tf); err != nil {
+		return nil, err
+	}
+
+	tf.Name = strings.TrimSpace(tf.Name)
+	if tf.Name == "" {
+		return nil, singleton.Localizer.ErrorT("name cannot be empty")
+	}
+	if len(tf.Scopes) == 0 {
+		return nil, singleton.Localizer.ErrorT("scopes cannot be empty")
+	}
+	for _, scope := range tf.Scopes {
+		if !slices.Contains(model.APITokenScopes[:], scope) {
+			return nil, singleton.Localizer.ErrorT("invalid scope: %s", scope)
+		}
+	}
+	if tf.ExpiresAt != nil && !tf.ExpiresAt.After(time.Now()) {
+		return nil, singleton.Localizer.ErrorT("expiry time must be in the future")
+	}
+
+	secret, err := utils.GenerateRandomString(32)
+	if err != nil {
+		return nil, err
+	}
+	token := model.APITokenPrefix + secret
+
+	t := &model.APIToken{
+		Name:      tf.Name,
+		TokenHash: model.HashAPIToken(token),
+		Hint:      token[:len(model.APITokenPrefix)+4],
+		Scopes:    slices.Compact(slices.Sorted(slices.Values(tf.Scopes))),
+		ExpiresAt: tf.ExpiresAt,
+	}
+	t.UserID = getUid(c)
+
+	if err := singleton.DB.Create(t).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+
+	log.Printf("NEZHA>> User %d created API token %d (%s) with scopes %v", t.UserID, t.ID, t.Name, t.Scopes)
+	return &model.APITokenCreated{APIToken: t, Token: token}, nil
+}
+
+// Revoke API token
+// @Summary Revoke API token
+// @Security BearerAuth
+// @Schemes
+// @Description Revoke an API token, it becomes invalid immediately
+// @Tags auth required
+// @param id path uint true "Token ID"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /user/tokens/{id} [delete]
+func revokeAPIToken(c *gin.Context) (any, error) {
+	if err := rejectAPIToken(c); err != nil {
+		return nil, err
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	uid := getUid(c)
+	if err := singleton.APITokenShared.Revoke(uid, id); err != nil {
+		return nil, err
+	}
+
+	log.Printf("NEZHA>> User %d revoked API token %d", uid, id)
+	return nil, nil
+}
@@ -0,0 +1,40 @@
+package controller
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+func TestTokenScopeAllows(t *testing.T) {
+	cases := []struct {
+		scopes []string
+		method string
+		path   string
+		allow  bool
+	}{
+		{[]string{model.APITokenScopeRead}, http.MethodGet, "/api/v1/server", true},
+		{[]string{model.APITokenScopeAnnotation}, http.MethodGet, "/api/v1/server", true},
+		{nil, http.MethodGet, "/api/v1/server", false},
+		{[]string{model.APITokenScopeRead}, http.MethodPatch, "/api/v1/server/:id", false},
+		{[]string{model.APITokenScopeServerWrite}, http.MethodPatch, "/api/v1/server/:id", true},
+		{[]string{model.APITokenScopeServerWrite}, http.MethodPost, "/api/v1/cron/:id/run", false},
+		{[]string{model.APITokenScopeCronExecute}, http.MethodPost, "/api/v1/cron/:id/run", true},
+		{[]string{model.APITokenScopeAnnotation}, http.MethodPost, "/api/v1/ingest/annotation", true},
+		// 列出的读请求同样需要对应权限
+		{[]string{model.APITokenScopeRead}, http.MethodGet, "/api/v1/ws/terminal/:id", false},
+		{[]string{model.APITokenScopeRead}, http.MethodGet, "/api/v1/cron/:id/manual", false},
+		// 未列出的写操作仅 admin 权限可以访问
+		{[]string{model.APITokenScopeServerWrite, model.APITokenScopeCronExecute}, http.MethodPost, "/api/v1/user", false},
+		{[]string{model.APITokenScopeAdmin}, http.MethodPost, "/api/v1/user", true},
+		{[]string{model.APITokenScopeAdmin}, http.MethodGet, "/api/v1/ws/terminal/:id", true},
+	}
+
+	for _, c := range cases {
+		at := &model.APIToken{Scopes: c.scopes}
+		if got := tokenScopeAllows(at, c.method, c.path); got != c.allow {
+			t.Errorf("%v %s %s: expected allow=%v, got %v", c.scopes, c.method, c.path, c.allow, got)
+		}
+	}
+}
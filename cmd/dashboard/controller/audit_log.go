@@ -0,0 +1,331 @@
+package controller
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/goccy/go-json"
+	"github.com/tidwall/gjson"
+	"gorm.io/gorm"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+const (
+	auditBodyLimit     = 64 << 10
+	auditSummaryLimit  = 1024
+	auditEntityIDLimit = 256
+	auditResponseHead  = 512
+)
+
+// 路径中表示批量操作的前缀，实体类型取其后一段
+var auditActionPrefixes = []string{"batch-delete", "batch-move", "force-update"}
+
+// 需要记录审计日志的 GET 接口，会从 Agent 读取敏感内容
+var auditedReadRoutes = []string{"/api/v1/server/:id/agent-logs"}
+
+// 请求内容中包含这些字段名时隐藏其值
+var auditSensitiveKeys = []string{"password", "secret", "token", "key", "otp", "code", "url", "header", "body", "access_id", "command"}
+
+// auditWriter 记录响应的开头部分，用于判断请求是否成功
+type auditWriter struct {
+	gin.ResponseWriter
+	head []byte
+}
+
+func (w *auditWriter) Write(b []byte) (int, error) {
+	if n := auditResponseHead - len(w.head); n > 0 {
+		w.head = append(w.head, b[:min(n, len(b))]...)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *auditWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// auditBody 记录处理请求时读取的 JSON 请求内容，不提前读取，超过 auditBodyLimit 的部分不记录
+type auditBody struct {
+	io.ReadCloser
+	buf []byte
+}
+
+func (b *auditBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if room := auditBodyLimit - len(b.buf); room > 0 {
+		b.buf = append(b.buf, p[:min(n, room)]...)
+	}
+	return n, err
+}
+
+// auditLog 记录所有修改请求、读取敏感内容的请求，以及严格租户模式下查看全部租户数据的请求
+func auditLog(c *gin.Context) {
+	read, sensitive := false, false
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		sensitive = slices.Contains(auditedReadRoutes, c.FullPath())
+		if !sensitive && !allTenants(c) {
+			c.Next()
+			return
+		}
+		read = true
+	}
+
+	var body *auditBody
+	if c.Request.Body != nil && c.ContentType() == binding.MIMEJSON {
+		body = &auditBody{ReadCloser: c.Request.Body}
+		c.Request.Body = body
+	}
+
+	w := &auditWriter{ResponseWriter: c.Writer}
+	c.Writer = w
+	c.Next()
+
+	route := c.FullPath()
+	if route == "" {
+		route = c.Request.URL.Path
+	}
+	entry := &model.AuditLog{
+		CreatedAt: time.Now(),
+		IP:        c.GetString(model.CtxKeyRealIPStr),
+		Method:    c.Request.Method,
+		Route:     route,
+		Status:    w.Status(),
+	}
+	if entry.IP == "" {
+		entry.IP = c.RemoteIP()
+	}
+	if auth, ok := c.Get(model.CtxKeyAuthorizedUser); ok {
+		entry.UserID = auth.(*model.User).ID
+	}
+	if t, ok := c.Get(model.CtxKeyAPIToken); ok {
+		entry.TokenID = t.(*model.APIToken).ID
+	}
+
+	var payload any
+	if body != nil && len(body.buf) > 0 && json.Unmarshal(body.buf, &payload) == nil {
+		entry.Summary = truncate(auditSummary(payload), auditSummaryLimit)
+	}
+	if summary := c.GetString(model.CtxKeyAuditSummary); summary != "" {
+		entry.Summary = truncate(summary, auditSummaryLimit)
+	}
+	entry.EntityType, entry.EntityID = auditEntity(c, payload)
+
+	result := gjson.ParseBytes(w.head)
+	entry.Success = entry.Status < http.StatusBadRequest && result.Get("success").Bool()
+	if read {
+		// WebSocket 等请求没有 JSON 响应
+		entry.Summary = "all_tenants"
+		if sensitive {
+			entry.Summary = truncate(c.Request.URL.RawQuery, auditSummaryLimit)
+		}
+		entry.Success = entry.Status < http.StatusBadRequest && (!result.Get("success").Exists() || entry.Success)
+	}
+	if !entry.Success {
+		entry.Error = truncate(result.Get("error").String(), auditSummaryLimit)
+	}
+
+	singleton.AuditLogShared.Record(entry)
+}
+
+// auditedRequest 请求是否会由 auditLog 记录
+func auditedRequest(c *gin.Context) bool {
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return slices.Contains(auditedReadRoutes, c.FullPath()) || allTenants(c)
+	}
+	return true
+}
+
+// recordAccessDenied 记录被拒绝的终端、文件管理等 GET 请求，其余请求由 auditLog 记录
+func recordAccessDenied(c *gin.Context, serverID uint64, access string, err error) {
+	entry := &model.AuditLog{
+		CreatedAt:  time.Now(),
+		IP:         c.GetString(model.CtxKeyRealIPStr),
+		Method:     c.Request.Method,
+		Route:      c.FullPath(),
+		EntityType: "server",
+		EntityID:   strconv.FormatUint(serverID, 10),
+		Summary:    "access: " + access,
+		Status:     http.StatusForbidden,
+		Error:      err.Error(),
+	}
+	if entry.IP == "" {
+		entry.IP = c.RemoteIP()
+	}
+	if auth, ok := c.Get(model.CtxKeyAuthorizedUser); ok {
+		entry.UserID = auth.(*model.User).ID
+	}
+	if t, ok := c.Get(model.CtxKeyAPIToken); ok {
+		entry.TokenID = t.(*model.APIToken).ID
+	}
+	singleton.AuditLogShared.Record(entry)
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return strings.ToValidUTF8(s[:n], "") + "..."
+}
+
+func auditSensitive(key string) bool {
+	if singleton.IsSecretSetting(key) {
+		return true
+	}
+	key = strings.ToLower(key)
+	return slices.ContainsFunc(auditSensitiveKeys, func(k string) bool {
+		return strings.Contains(key, k)
+	})
+}
+
+func redact(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		// 标记为 secret 的键值对（如计划任务的环境变量）隐藏其值
+		secret, _ := v["secret"].(bool)
+		for k, item := range v {
+			if auditSensitive(k) || (secret && k == "value") {
+				if item != nil && item != "" {
+					v[k] = "******"
+				}
+				continue
+			}
+			v[k] = redact(item)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = redact(item)
+		}
+	}
+	return v
+}
+
+func auditSummary(payload any) string {
+	data, err := json.Marshal(redact(payload))
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// auditEntity 从路由中解析实体类型，并从路径参数或批量操作的 ID 列表中获取实体 ID
+func auditEntity(c *gin.Context, payload any) (string, string) {
+	segs := strings.Split(strings.TrimPrefix(c.FullPath(), "/api/v1/"), "/")
+	if len(segs) > 0 && slices.Contains(auditActionPrefixes, segs[0]) {
+		segs = segs[1:]
+	}
+	var names []string
+	for _, s := range segs {
+		if strings.HasPrefix(s, ":") {
+			break
+		}
+		names = append(names, s)
+	}
+	entityType := strings.Join(names, "/")
+
+	if len(c.Params) > 0 {
+		return entityType, c.Params[0].Value
+	}
+
+	var ids []any
+	switch v := payload.(type) {
+	case []any:
+		ids = v
+	case map[string]any:
+		ids, _ = v["ids"].([]any)
+	}
+	if len(ids) == 0 {
+		return entityType, ""
+	}
+	list := make([]string, 0, len(ids))
+	for _, id := range ids {
+		list = append(list, fmt.Sprint(id))
+	}
+	return entityType, truncate(strings.Join(list, ","), auditEntityIDLimit)
+}
+
+// List audit logs
+// @Summary List audit logs
+// @Security BearerAuth
+// @Schemes
+// @Description List audit logs
+// @Tags admin required
+// @Param user_id query uint false "Actor user ID"
+// @Param token_id query uint false "Actor API token ID"
+// @Param entity_type query string false "Entity type"
+// @Param entity_id query string false "Entity ID"
+// @Param from query string false "Start time (RFC3339)"
+// @Param to query string false "End time (RFC3339)"
+// @Param limit query uint false "Page limit"
+// @Param offset query uint false "Page offset"
+// @Produce json
+// @Success 200 {object} model.PaginatedResponse[[]model.AuditLog, model.AuditLog]
+// @Router /audit [get]
+func listAuditLog(c *gin.Context) (*model.Value[[]*model.AuditLog], error) {
+	if user, _ := c.MustGet(model.CtxKeyAuthorizedUser).(*model.User); user.Role != model.RoleAdmin {
+		return nil, singleton.Localizer.ErrorT("permission denied")
+	}
+
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit < 1 {
+		limit = 25
+	}
+	offset, err := strconv.Atoi(c.Query("offset"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	query := singleton.DB.Model(&model.AuditLog{})
+	for _, key := range []string{"user_id", "token_id"} {
+		if v := c.Query(key); v != "" {
+			id, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				return nil, singleton.Localizer.ErrorT("invalid %s: %s", key, v)
+			}
+			query = query.Where(key+" = ?", id)
+		}
+	}
+	for _, key := range []string{"entity_type", "entity_id"} {
+		if v := c.Query(key); v != "" {
+			query = query.Where(key+" = ?", v)
+		}
+	}
+	for key, op := range map[string]string{"from": ">=", "to": "<="} {
+		if v := c.Query(key); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return nil, singleton.Localizer.ErrorT("invalid time: %s", v)
+			}
+			query = query.Where("created_at "+op+" ?", t)
+		}
+	}
+
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+	var logs []*model.AuditLog
+	if err := query.Order("id DESC").Limit(limit).Offset(offset).Find(&logs).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+
+	return &model.Value[[]*model.AuditLog]{
+		Value: logs,
+		Pagination: model.Pagination{
+			Offset: offset,
+			Limit:  limit,
+			Total:  total,
+		},
+	}, nil
+}
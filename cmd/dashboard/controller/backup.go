@@ -0,0 +1,148 @@
+package controller
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+// restoreGuard 恢复备份期间拒绝修改操作
+func restoreGuard(c *gin.Context) {
+	if !singleton.IsRestoring() {
+		c.Next()
+		return
+	}
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		c.Next()
+		return
+	}
+	c.AbortWithStatusJSON(http.StatusServiceUnavailable, newErrorResponse(singleton.Localizer.ErrorT("the dashboard is restoring a backup")))
+}
+
+// List backups
+// @Summary List backups
+// @Security BearerAuth
+// @Schemes
+// @Description List database backups in the backup directory
+// @Tags admin required
+// @Produce json
+// @Success 200 {object} model.CommonResponse[[]model.BackupInfo]
+// @Router /admin/backup [get]
+func listBackup(c *gin.Context) ([]*model.BackupInfo, error) {
+	return singleton.ListBackups()
+}
+
+// Create backup
+// @Summary Create backup
+// @Security BearerAuth
+// @Schemes
+// @Description Create a consistent snapshot of the database, written to the backup directory or downloaded directly
+// @Tags admin required
+// @Param download query bool false "Download the snapshot instead of writing it to the backup directory"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.BackupInfo]
+// @Router /admin/backup [post]
+func createBackup(c *gin.Context) (*model.BackupInfo, error) {
+	if c.Query("download") != "true" {
+		info, err := singleton.CreateBackup(singleton.BackupTriggerManual)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("NEZHA>> User %d created backup %s", getUid(c), info.Name)
+		return info, nil
+	}
+
+	dir, err := os.MkdirTemp("", "nezha-backup-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "sqlite.db")
+	if err := singleton.SnapshotDB(path); err != nil {
+		return nil, err
+	}
+
+	log.Printf("NEZHA>> User %d downloaded a database backup", getUid(c))
+	c.FileAttachment(path, "nezha-"+time.Now().Format("20060102-150405")+".db")
+	return nil, errNoop
+}
+
+// Restore backup
+// @Summary Restore backup
+// @Security BearerAuth
+// @Schemes
+// @Description Restore the database from an uploaded file or a backup in the backup directory, the current database is backed up first. Requires read-only mode with agent reports paused
+// @Tags admin required
+// @Accept multipart/form-data
+// @Param file formData file false "Backup file"
+// @Param name formData string false "Name of a backup in the backup directory"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /admin/restore [post]
+func restoreBackup(c *gin.Context) (any, error) {
+	if err := rejectAPIToken(c); err != nil {
+		return nil, err
+	}
+	// 恢复只能在维护模式下进行，此时拒绝修改操作并暂停处理 Agent 上报
+	if !singleton.Conf.ReadOnly || !singleton.Conf.ReadOnlyPauseReports {
+		return nil, singleton.Localizer.ErrorT("enable read-only mode with agent reports paused before restoring a backup")
+	}
+
+	var path string
+	if fh, err := c.FormFile("file"); err == nil {
+		dir, err := os.MkdirTemp("", "nezha-restore-")
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(dir)
+
+		path = filepath.Join(dir, "sqlite.db")
+		if err := c.SaveUploadedFile(fh, path); err != nil {
+			return nil, err
+		}
+	} else if name := c.PostForm("name"); name != "" {
+		if path, err = singleton.BackupPath(name); err != nil {
+			return nil, singleton.Localizer.ErrorT("backup %s does not exist", name)
+		}
+	} else {
+		return nil, singleton.Localizer.ErrorT("no backup specified")
+	}
+
+	if err := singleton.RestoreBackup(path); err != nil {
+		return nil, err
+	}
+
+	log.Printf("NEZHA>> User %d restored the database from a backup", getUid(c))
+	return nil, nil
+}
+
+// Run history retention
+// @Summary Run history retention
+// @Security BearerAuth
+// @Schemes
+// @Description Downsample and delete service history, latency summaries and transfer records according to the retention settings, or report the affected rows with dry_run
+// @Tags admin required
+// @Param dry_run query bool false "Only count the rows of each tier"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.RetentionReport]
+// @Router /admin/retention [post]
+func runHistoryRetention(c *gin.Context) (*model.RetentionReport, error) {
+	dryRun := c.Query("dry_run") == "true"
+	report, err := singleton.RunHistoryRetention(dryRun)
+	if err != nil {
+		return nil, newGormError("%v", err)
+	}
+	if !dryRun {
+		log.Printf("NEZHA>> User %d ran history retention", getUid(c))
+	}
+	return report, nil
+}
@@ -0,0 +1,91 @@
+package controller
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+// Get branding asset
+// @Summary Get branding asset
+// @Schemes
+// @Description Get the uploaded site logo or favicon
+// @Tags common
+// @Param name path string true "logo or favicon"
+// @Produce image/png,image/jpeg,image/gif,image/webp,image/x-icon
+// @Success 200 {file} binary
+// @Router /branding/{name} [get]
+func getBrandingAsset(c *gin.Context) (any, error) {
+	asset, err := singleton.GetBrandingAsset(c.Param("name"))
+	if err != nil {
+		return nil, newGormError("%v", err)
+	}
+	if asset == nil {
+		c.Status(http.StatusNotFound)
+		return nil, errNoop
+	}
+
+	etag := `"` + asset.SHA256 + `"`
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "public, max-age=86400")
+	c.Header("X-Content-Type-Options", "nosniff")
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return nil, errNoop
+	}
+	c.Data(http.StatusOK, asset.ContentType, asset.Content)
+	return nil, errNoop
+}
+
+// Upload branding asset
+// @Summary Upload branding asset
+// @Security BearerAuth
+// @Schemes
+// @Description Upload the site logo or favicon, only PNG, JPEG, GIF, WebP and ICO images are accepted
+// @Tags admin required
+// @Accept multipart/form-data
+// @Param name path string true "logo or favicon"
+// @Param file formData file true "File"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.BrandingAsset]
+// @Router /admin/branding/{name} [put]
+func uploadBrandingAsset(c *gin.Context) (*model.BrandingAsset, error) {
+	fh, err := c.FormFile("file")
+	if err != nil {
+		return nil, err
+	}
+	if fh.Size > model.BrandingAssetMaxSize {
+		return nil, singleton.Localizer.ErrorT("file size exceeds %d bytes", model.BrandingAssetMaxSize)
+	}
+	r, err := fh.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	content, err := io.ReadAll(io.LimitReader(r, model.BrandingAssetMaxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	return singleton.SaveBrandingAsset(c.Param("name"), content)
+}
+
+// Delete branding asset
+// @Summary Delete branding asset
+// @Security BearerAuth
+// @Schemes
+// @Description Delete the uploaded site logo or favicon, the configured URL is used again
+// @Tags admin required
+// @Param name path string true "logo or favicon"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /admin/branding/{name} [delete]
+func deleteBrandingAsset(c *gin.Context) (any, error) {
+	if err := singleton.DeleteBrandingAsset(c.Param("name")); err != nil {
+		return nil, newGormError("%v", err)
+	}
+	return nil, nil
+}
@@ -0,0 +1,152 @@
+package controller
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+// List command policies
+// @Summary List command policies
+// @Schemes
+// @Description List command policies
+// @Security BearerAuth
+// @Tags admin required
+// @Produce json
+// @Success 200 {object} model.CommonResponse[[]model.CommandPolicy]
+// @Router /command-policy [get]
+func listCommandPolicy(c *gin.Context) ([]*model.CommandPolicy, error) {
+	return singleton.CommandPolicyShared.GetSortedList(), nil
+}
+
+// Add command policy
+// @Summary Add command policy
+// @Security BearerAuth
+// @Schemes
+// @Description Add command policy
+// @Tags admin required
+// @Accept json
+// @param request body model.CommandPolicyForm true "Command Policy Request"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[uint64]
+// @Router /command-policy [post]
+func createCommandPolicy(c *gin.Context) (uint64, error) {
+	var pf model.CommandPolicyForm
+	if err := c.ShouldBindJSON(&pf); err != nil {
+		return 0, err
+	}
+
+	var p model.CommandPolicy
+	p.UserID = getUid(c)
+	if err := applyCommandPolicyForm(&p, &pf); err != nil {
+		return 0, err
+	}
+
+	if err := singleton.DB.Create(&p).Error; err != nil {
+		return 0, newGormError("%v", err)
+	}
+
+	singleton.CommandPolicyShared.Update(&p)
+	return p.ID, nil
+}
+
+// Edit command policy
+// @Summary Edit command policy
+// @Security BearerAuth
+// @Schemes
+// @Description Edit command policy
+// @Tags admin required
+// @Accept json
+// @param id path uint true "Policy ID"
+// @param request body model.CommandPolicyForm true "Command Policy Request"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /command-policy/{id} [patch]
+func updateCommandPolicy(c *gin.Context) (any, error) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	var pf model.CommandPolicyForm
+	if err := c.ShouldBindJSON(&pf); err != nil {
+		return nil, err
+	}
+
+	var p model.CommandPolicy
+	if err := singleton.DB.First(&p, id).Error; err != nil {
+		return nil, singleton.Localizer.ErrorT("policy id %d does not exist", id)
+	}
+	if err := applyCommandPolicyForm(&p, &pf); err != nil {
+		return nil, err
+	}
+
+	if err := singleton.DB.Save(&p).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+
+	singleton.CommandPolicyShared.Update(&p)
+	return nil, nil
+}
+
+// Batch delete command policies
+// @Summary Batch delete command policies
+// @Security BearerAuth
+// @Schemes
+// @Description Batch delete command policies
+// @Tags admin required
+// @Accept json
+// @param request body []uint64 true "id list"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /batch-delete/command-policy [post]
+func batchDeleteCommandPolicy(c *gin.Context) (any, error) {
+	var p []uint64
+	if err := c.ShouldBindJSON(&p); err != nil {
+		return nil, err
+	}
+
+	if err := singleton.DB.Unscoped().Delete(&model.CommandPolicy{}, "id in (?)", p).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+
+	singleton.CommandPolicyShared.Delete(p)
+	return nil, nil
+}
+
+func applyCommandPolicyForm(p *model.CommandPolicy, pf *model.CommandPolicyForm) error {
+	switch pf.Mode {
+	case model.CommandPolicyAllow, model.CommandPolicyDeny:
+	default:
+		return singleton.Localizer.ErrorT("invalid policy mode")
+	}
+	if pf.Role > model.RoleMember {
+		return singleton.Localizer.ErrorT("invalid role")
+	}
+
+	p.Name = pf.Name
+	p.TargetUserID = pf.TargetUserID
+	p.Role = pf.Role
+	p.Mode = pf.Mode
+	p.Patterns = pf.Patterns
+	if p.Patterns == nil {
+		p.Patterns = []string{}
+	}
+	if err := p.Compile(); err != nil {
+		return singleton.Localizer.ErrorT("invalid pattern: %v", err)
+	}
+	return nil
+}
+
+// checkCommandPolicy 检查当前用户能否创建、修改该命令
+func checkCommandPolicy(c *gin.Context, command string) error {
+	user := c.MustGet(model.CtxKeyAuthorizedUser).(*model.User)
+	if singleton.Conf.RequireAdminForShellTasks && user.Role != model.RoleAdmin {
+		return singleton.Localizer.ErrorT("only administrators can manage shell tasks")
+	}
+	return singleton.CommandPolicyShared.Check(user.ID, user.Role, command)
+}
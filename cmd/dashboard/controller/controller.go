@@ -21,6 +21,7 @@ import (
 
 	"github.com/nezhahq/nezha/cmd/dashboard/controller/waf"
 	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/i18n"
 	"github.com/nezhahq/nezha/pkg/utils"
 	"github.com/nezhahq/nezha/service/singleton"
 )
@@ -55,27 +56,52 @@ func routers(r *gin.Engine, frontendDist fs.FS) {
 	if err := authMiddleware.MiddlewareInit(); err != nil {
 		log.Fatal("authMiddleware.MiddlewareInit Error:" + err.Error())
 	}
-	api := r.Group("api/v1")
-	api.POST("/login", authMiddleware.LoginHandler)
-	api.GET("/oauth2/:provider", commonHandler(oauth2redirect))
-
-	fallbackAuthMw := fallbackAuthMiddleware(authMiddleware)
-	fallbackAuth := api.Group("", fallbackAuthMw)
+	natAuthMiddleware = authMiddleware
+	initRateLimiters()
+	singleton.OnSettingsChange("rate_limit.", initRateLimiters)
+	singleton.OnSettingsChange("probe.rate_limit.", initRateLimiters)
+	singleton.OnSettingsChange("public_status.rate_limit.", initRateLimiters)
+	// 通知中的操作链接由签名授权，不受管理区域网段限制，只读模式下也可以使用
+	actions := r.Group("api/v1", auditLog, restoreGuard, rateLimit)
+	actions.GET("/server/:id/silence", commonHandler(silenceServerByLink))
+	actions.POST("/server/:id/silence", commonHandler(silenceServerByLink))
+	actions.GET("/server/:id/ack", commonHandler(ackServerByLink))
+	actions.POST("/server/:id/ack", commonHandler(ackServerByLink))
+
+	api := r.Group("api/v1", mutationNetworkGuard, auditLog, restoreGuard, readOnlyGuard)
+
+	public := api.Group("", rateLimit)
+	public.POST("/login", authMiddleware.LoginHandler)
+	public.POST("/logout", commonHandler(logout(authMiddleware)))
+	public.GET("/oauth2/:provider", adminNetworkGuard, commonHandler(oauth2redirect))
+	public.POST("/oauth2/totp", commonHandler(oauth2TOTP(authMiddleware)))
+	public.GET("/status-page", commonHandler(getStatusPage))
+	public.GET("/settings/public", commonHandler(getPublicSetting))
+	public.GET("/branding/:name", commonHandler(getBrandingAsset))
+	public.GET("/admin-bypass", adminBypass)
+
+	fallbackAuthMw := tokenAuthMiddleware(fallbackAuthMiddleware(authMiddleware))
+	fallbackAuth := api.Group("", fallbackAuthMw, rateLimit, viewerGuard)
 	fallbackAuth.GET("/setting", commonHandler(listConfig))
-	fallbackAuth.GET("/oauth2/callback", commonHandler(oauth2callback(authMiddleware)))
+	fallbackAuth.GET("/oauth2/callback", adminNetworkGuard, commonHandler(oauth2callback(authMiddleware)))
 
-	authMw := authMiddleware.MiddlewareFunc()
+	authMw := tokenAuthMiddleware(authMiddleware.MiddlewareFunc())
 	optionalAuthMw := utils.IfOr(singleton.Conf.ForceAuth, authMw, fallbackAuthMw)
 
-	optionalAuth := api.Group("", optionalAuthMw)
+	optionalAuth := api.Group("", optionalAuthMw, rateLimit, viewerGuard, tenantScope)
 	optionalAuth.GET("/ws/server", commonHandler(serverStream))
+	optionalAuth.GET("/ws/server/:id", commonHandler(singleServerStream))
+	optionalAuth.GET("/server/:id/state-history", commonHandler(getServerStateHistory))
 	optionalAuth.GET("/server-group", commonHandler(listServerGroup))
 
+	api.GET("/public/status", publicStatusGuard, tenantScope, getPublicStatus)
+
 	optionalAuth.GET("/service", commonHandler(showService))
 	optionalAuth.GET("/service/:id", commonHandler(listServiceHistory))
+	optionalAuth.GET("/service/:id/latency", commonHandler(getServiceLatency))
 	optionalAuth.GET("/service/server", commonHandler(listServerWithServices))
 
-	auth := api.Group("", authMw)
+	auth := api.Group("", adminNetworkGuard, authMw, rateLimit, viewerGuard, tenantScope)
 
 	auth.GET("/refresh-token", authMiddleware.RefreshHandler)
 
@@ -89,13 +115,35 @@ func routers(r *gin.Engine, frontendDist fs.FS) {
 	auth.POST("/profile", commonHandler(updateProfile))
 	auth.POST("/oauth2/:provider/unbind", commonHandler(unbindOauth2))
 
+	auth.GET("/user/tokens", commonHandler(listAPIToken))
+	auth.POST("/user/tokens", requireMFA, commonHandler(createAPIToken))
+	auth.DELETE("/user/tokens/:id", commonHandler(revokeAPIToken))
+
+	auth.GET("/user/sessions", commonHandler(listSession))
+	auth.DELETE("/user/sessions", commonHandler(revokeOtherSessions))
+	auth.DELETE("/user/sessions/:id", commonHandler(revokeSession))
+
+	auth.POST("/user/2fa/enroll", commonHandler(enrollTOTP))
+	auth.POST("/user/2fa/activate", commonHandler(activateTOTP))
+	auth.POST("/user/2fa/verify", commonHandler(verifyTOTP(authMiddleware)))
+	auth.DELETE("/user/2fa", requireMFA, commonHandler(disableTOTP))
+
 	auth.GET("/user", adminHandler(listUser))
 	auth.POST("/user", adminHandler(createUser))
-	auth.POST("/batch-delete/user", adminHandler(batchDeleteUser))
+	auth.POST("/batch-delete/user", requireMFA, adminHandler(batchDeleteUser))
+	auth.POST("/user/:id/2fa/reset", requireMFA, adminHandler(resetUserTOTP))
+	auth.PATCH("/user/:id/role", adminHandler(updateUserRole))
+
+	auth.GET("/search", commonHandler(search))
 
 	auth.GET("/service/list", listHandler(listService))
+	auth.GET("/service/:id/dns", commonHandler(listServiceDNSProbe))
+	auth.GET("/service/:id/probes", commonHandler(getServiceProbeAssignment))
+	auth.GET("/service/:id/results", commonHandler(listServiceResults))
+	auth.GET("/service/:id/composite", commonHandler(getCompositeServiceStatus))
 	auth.POST("/service", commonHandler(createService))
 	auth.PATCH("/service/:id", commonHandler(updateService))
+	auth.DELETE("/service/:id/history", adminHandler(purgeServiceHistory))
 	auth.POST("/batch-delete/service", commonHandler(batchDeleteService))
 
 	auth.POST("/server-group", commonHandler(createServerGroup))
@@ -108,13 +156,39 @@ func routers(r *gin.Engine, frontendDist fs.FS) {
 	auth.POST("/batch-delete/notification-group", commonHandler(batchDeleteNotificationGroup))
 
 	auth.GET("/server", listHandler(listServer))
+	auth.GET("/server/export", commonHandler(exportServer))
 	auth.PATCH("/server/:id", commonHandler(updateServer))
+	auth.PUT("/server/:id/wake-schedule", commonHandler(setServerWakeSchedule))
+	auth.DELETE("/server/:id/wake-schedule", commonHandler(deleteServerWakeSchedule))
+	auth.PUT("/server/:id/heartbeat", commonHandler(setServerHeartbeat))
 	auth.GET("/server/config/:id", commonHandler(getServerConfig))
+	auth.GET("/server/:id/traffic", commonHandler(getServerTraffic))
+	auth.GET("/server/:id/custom-metric-history", commonHandler(getServerCustomMetricHistory))
+	auth.GET("/server/:id/containers", commonHandler(getServerContainers))
+	auth.POST("/server/:id/processes", commonHandler(getServerProcesses))
+	auth.POST("/server/:id/probe", commonHandler(probeFromServer))
+	auth.GET("/server/:id/agent-logs", adminHandler(getServerAgentLogs))
+	auth.GET("/server/:id/annotations", commonHandler(listServerAnnotation))
+	auth.GET("/server/:id/events", commonHandler(listServerEvent))
+	auth.DELETE("/server/:id/history", adminHandler(purgeServerHistory))
+
+	auth.POST("/ingest/annotation", commonHandler(ingestAnnotation))
+	auth.POST("/ingest/server", commonHandler(ingestServer))
+
+	auth.GET("/mesh", commonHandler(getMeshMatrix))
+	auth.GET("/mesh/:source/:target", commonHandler(getMeshHistory))
+	auth.POST("/server/:id/exec", commonHandler(execServerCommand))
+	auth.GET("/server/:id/exec/:execution_id", commonHandler(getServerExecution))
 	auth.POST("/server/config", commonHandler(setServerConfig))
 	auth.POST("/batch-delete/server", commonHandler(batchDeleteServer))
 	auth.POST("/batch-move/server", commonHandler(batchMoveServer))
 	auth.POST("/force-update/server", commonHandler(forceUpdateServer))
 
+	auth.GET("/agent-rollouts", commonHandler(listAgentRollout))
+	auth.POST("/agent-rollouts", commonHandler(createAgentRollout))
+	auth.GET("/agent-rollouts/:id", commonHandler(getAgentRollout))
+	auth.POST("/agent-rollouts/:id/:action", commonHandler(controlAgentRollout))
+
 	auth.GET("/notification", listHandler(listNotification))
 	auth.POST("/notification", commonHandler(createNotification))
 	auth.PATCH("/notification/:id", commonHandler(updateNotification))
@@ -127,32 +201,150 @@ func routers(r *gin.Engine, frontendDist fs.FS) {
 
 	auth.GET("/cron", listHandler(listCron))
 	auth.POST("/cron", commonHandler(createCron))
+	auth.POST("/cron/validate", commonHandler(validateCronSchedule))
 	auth.PATCH("/cron/:id", commonHandler(updateCron))
 	auth.GET("/cron/:id/manual", commonHandler(manualTriggerCron))
+	auth.POST("/cron/:id/run", commonHandler(runCron))
+	auth.GET("/cron/:id", commonHandler(getCron))
+	auth.GET("/cron/:id/runs", commonHandler(listCronRun))
+	auth.GET("/cron/:id/executions", commonHandler(listCronExecution))
 	auth.POST("/batch-delete/cron", commonHandler(batchDeleteCron))
 
 	auth.GET("/ddns", listHandler(listDDNS))
 	auth.GET("/ddns/providers", commonHandler(listProviders))
 	auth.POST("/ddns", commonHandler(createDDNS))
 	auth.PATCH("/ddns/:id", commonHandler(updateDDNS))
+	auth.GET("/ddns/:id/history", commonHandler(listDDNSHistory))
+	auth.POST("/ddns/:id/run", commonHandler(runDDNS))
 	auth.POST("/batch-delete/ddns", commonHandler(batchDeleteDDNS))
 
 	auth.GET("/nat", listHandler(listNAT))
 	auth.POST("/nat", commonHandler(createNAT))
 	auth.PATCH("/nat/:id", commonHandler(updateNAT))
+	auth.GET("/nat/:id/stats", commonHandler(getNATStats))
+	auth.GET("/nat/:id/login", commonHandler(natLogin))
 	auth.POST("/batch-delete/nat", commonHandler(batchDeleteNAT))
 
+	auth.GET("/command-policy", adminHandler(listCommandPolicy))
+	auth.POST("/command-policy", adminHandler(createCommandPolicy))
+	auth.PATCH("/command-policy/:id", adminHandler(updateCommandPolicy))
+	auth.POST("/batch-delete/command-policy", adminHandler(batchDeleteCommandPolicy))
+
+	auth.GET("/access-grant", adminHandler(listAccessGrant))
+	auth.POST("/access-grant", adminHandler(createAccessGrant))
+	auth.PATCH("/access-grant/:id", adminHandler(updateAccessGrant))
+	auth.POST("/batch-delete/access-grant", adminHandler(batchDeleteAccessGrant))
+
 	auth.GET("/waf", pCommonHandler(listBlockedAddress))
 	auth.POST("/batch-delete/waf", adminHandler(batchDeleteBlockedAddress))
+	auth.GET("/waf/blocks", adminHandler(listWAFBlock))
+	auth.POST("/waf/blocks", adminHandler(createWAFBlock))
+	auth.DELETE("/waf/blocks/:id", adminHandler(deleteWAFBlock))
+	auth.GET("/waf/rules", adminHandler(listWAFRule))
+	auth.POST("/waf/rules", adminHandler(createWAFRule))
+	auth.PATCH("/waf/rules/:id", adminHandler(updateWAFRule))
+	auth.POST("/batch-delete/waf/rules", adminHandler(batchDeleteWAFRule))
+
+	auth.GET("/audit", pCommonHandler(listAuditLog))
+
+	auth.GET("/terminal-recording", pCommonHandler(listTerminalRecording))
+	auth.GET("/terminal-recording/:id", adminHandler(downloadTerminalRecording))
+	auth.POST("/batch-delete/terminal-recording", adminHandler(batchDeleteTerminalRecording))
+
+	auth.GET("/push-file", adminHandler(listPushFile))
+	auth.POST("/push-file", adminHandler(createPushFile))
+	auth.POST("/batch-delete/push-file", adminHandler(batchDeletePushFile))
+	auth.POST("/server/:id/push-file", adminHandler(pushFileToServer))
+	auth.GET("/server/:id/push-file/:transfer_id", adminHandler(getFilePush))
+
+	auth.GET("/jobs/:id", adminHandler(getJob))
+
+	auth.GET("/admin/backup", adminHandler(listBackup))
+	auth.POST("/admin/backup", adminHandler(createBackup))
+	auth.POST("/admin/restore", requireMFA, adminHandler(restoreBackup))
+	auth.POST("/admin/retention", adminHandler(runHistoryRetention))
+	auth.GET("/admin/diagnostics", adminHandler(getDiagnostics))
 
 	auth.GET("/online-user", pCommonHandler(listOnlineUser))
 	auth.POST("/online-user/batch-block", adminHandler(batchBlockOnlineUser))
 
+	auth.GET("/status-page/section", adminHandler(listStatusPageSection))
+	auth.POST("/status-page/section", adminHandler(createStatusPageSection))
+	auth.PATCH("/status-page/section/:id", adminHandler(updateStatusPageSection))
+	auth.POST("/batch-delete/status-page/section", adminHandler(batchDeleteStatusPageSection))
+
+	auth.GET("/incident", adminHandler(listIncident))
+	auth.POST("/incident", adminHandler(createIncident))
+	auth.PATCH("/incident/:id", adminHandler(updateIncident))
+	auth.POST("/incident/:id/update", adminHandler(createIncidentUpdate))
+	auth.POST("/batch-delete/incident", adminHandler(batchDeleteIncident))
+
+	auth.GET("/report-config", adminHandler(listSLAReportConfig))
+	auth.POST("/report-config", adminHandler(createSLAReportConfig))
+	auth.PATCH("/report-config/:id", adminHandler(updateSLAReportConfig))
+	auth.POST("/report-config/:id/generate", adminHandler(generateSLAReport))
+	auth.POST("/batch-delete/report-config", adminHandler(batchDeleteSLAReportConfig))
+	auth.GET("/reports", commonHandler(listSLAReport))
+	auth.GET("/reports/:id", commonHandler(getSLAReport))
+
 	auth.PATCH("/setting", adminHandler(updateConfig))
+	auth.PATCH("/setting/read-only", adminHandler(updateReadOnly))
+	auth.GET("/admin/settings", adminHandler(listSettingItems))
+	auth.PATCH("/admin/settings", adminHandler(updateSettingItems))
+	auth.PUT("/admin/branding/:name", adminHandler(uploadBrandingAsset))
+	auth.DELETE("/admin/branding/:name", adminHandler(deleteBrandingAsset))
+
+	if singleton.Conf.EnableMetrics {
+		r.GET("/metrics", serveMetrics)
+	}
+
+	// 健康检查不经过认证与限流
+	r.GET("/healthz", healthz)
+	r.GET("/readyz", readyz)
 
 	r.NoRoute(fallbackToFrontend(frontendDist))
 }
 
+// viewerSelfServiceRoutes 只读用户可以修改自身账号的接口
+var viewerSelfServiceRoutes = []string{
+	"/api/v1/profile",
+	"/api/v1/oauth2/:provider/unbind",
+	"/api/v1/user/tokens",
+	"/api/v1/user/tokens/:id",
+	"/api/v1/user/2fa",
+	"/api/v1/user/2fa/enroll",
+	"/api/v1/user/2fa/activate",
+	"/api/v1/user/2fa/verify",
+}
+
+// viewerDeniedRoutes 只读用户不能访问的 GET 接口，访问时会执行操作或建立会话
+var viewerDeniedRoutes = []string{
+	"/api/v1/cron/:id/manual",
+	"/api/v1/server/:id/exec/:execution_id",
+	"/api/v1/file",
+	"/api/v1/ws/file/:id",
+	"/api/v1/ws/terminal/:id",
+}
+
+// viewerGuard 拒绝只读用户的修改请求
+func viewerGuard(c *gin.Context) {
+	auth, ok := c.Get(model.CtxKeyAuthorizedUser)
+	if !ok || auth.(*model.User).Role != model.RoleViewer {
+		c.Next()
+		return
+	}
+
+	path := c.FullPath()
+	readOnly := c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead
+	if (readOnly && !slices.Contains(viewerDeniedRoutes, path)) ||
+		(!readOnly && slices.Contains(viewerSelfServiceRoutes, path)) {
+		c.Next()
+		return
+	}
+
+	c.AbortWithStatusJSON(http.StatusForbidden, newErrorResponse(singleton.Localizer.ErrorT("permission denied")))
+}
+
 func recordPath(c *gin.Context) {
 	url := c.Request.URL.String()
 	for _, p := range c.Params {
@@ -168,6 +360,28 @@ func newErrorResponse(err error) model.CommonResponse[any] {
 	}
 }
 
+// requestLanguage 接口错误信息的语言：用户设置的语言优先，其次按 Accept-Language 协商，均不可用时使用系统语言
+func requestLanguage(c *gin.Context) string {
+	if auth, ok := c.Get(model.CtxKeyAuthorizedUser); ok {
+		if lang := auth.(*model.User).Language; lang != "" {
+			return lang
+		}
+	}
+	return singleton.Localizer.Negotiate(c.GetHeader("Accept-Language"))
+}
+
+// localizeError 按请求的语言重新翻译由 ErrorT 生成的错误
+func localizeError(c *gin.Context, err error) error {
+	le, ok := err.(*i18n.Error)
+	if !ok {
+		return err
+	}
+	if lang := requestLanguage(c); lang != "" {
+		return errors.New(le.Localize(lang))
+	}
+	return err
+}
+
 type handlerFunc[T any] func(c *gin.Context) (T, error)
 type pHandlerFunc[S ~[]E, E any] func(c *gin.Context) (*model.Value[S], error)
 
@@ -205,6 +419,15 @@ func (we *wsError) Error() string {
 	return fmt.Sprintf(we.msg, we.a...)
 }
 
+// forbiddenError 以 403 状态码返回的权限错误
+type forbiddenError struct {
+	err error
+}
+
+func (fe *forbiddenError) Error() string {
+	return fe.err.Error()
+}
+
 var errNoop = errors.New("wrote")
 
 func commonHandler[T any](handler handlerFunc[T]) func(*gin.Context) {
@@ -240,7 +463,24 @@ func handle[T any](c *gin.Context, handler handlerFunc[T]) {
 	switch err.(type) {
 	case *gormError:
 		log.Printf("NEZHA>> gorm error: %v", err)
-		c.JSON(http.StatusOK, newErrorResponse(singleton.Localizer.ErrorT("database error")))
+		c.JSON(http.StatusOK, newErrorResponse(localizeError(c, singleton.Localizer.ErrorT("database error"))))
+		return
+	case model.SettingErrors:
+		// 按设置项返回校验错误
+		c.JSON(http.StatusOK, model.CommonResponse[model.SettingErrors]{
+			Data:  err.(model.SettingErrors),
+			Error: localizeError(c, singleton.Localizer.ErrorT("invalid settings")).Error(),
+		})
+		return
+	case *model.AgentUnsupportedError:
+		// 返回所需的能力，便于前端区分 Agent 不支持与请求失败
+		c.JSON(http.StatusOK, model.CommonResponse[*model.AgentUnsupportedError]{
+			Data:  err.(*model.AgentUnsupportedError),
+			Error: localizeError(c, singleton.Localizer.ErrorT("the agent does not support %s", err.(*model.AgentUnsupportedError).Capability)).Error(),
+		})
+		return
+	case *forbiddenError:
+		c.JSON(http.StatusForbidden, newErrorResponse(localizeError(c, err.(*forbiddenError).err)))
 		return
 	case *wsError:
 		// Connection is upgraded to WebSocket, so c.Writer is no longer usable
@@ -250,7 +490,7 @@ func handle[T any](c *gin.Context, handler handlerFunc[T]) {
 		return
 	default:
 		if !errors.Is(err, errNoop) {
-			c.JSON(http.StatusOK, newErrorResponse(err))
+			c.JSON(http.StatusOK, newErrorResponse(localizeError(c, err)))
 		}
 		return
 	}
@@ -351,6 +591,14 @@ func fallbackToFrontend(frontendDist fs.FS) func(*gin.Context) {
 			return
 		}
 
+		// 受限网段以外访问管理后台时按不存在的页面处理
+		if strings.HasPrefix(c.Request.URL.Path, "/dashboard") && !adminAccessAllowed(c) {
+			if !checkLocalFileOrFs(c, frontendDist, singleton.Conf.UserTemplate+"/index.html", http.StatusNotFound) {
+				c.JSON(http.StatusNotFound, newErrorResponse(errors.New("404 Not Found")))
+			}
+			return
+		}
+
 		// redirect for /dashboard to /dashboard/
 		if c.Request.URL.Path == "/dashboard" {
 			c.Redirect(http.StatusMovedPermanently, "/dashboard/")
@@ -6,6 +6,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/jinzhu/copier"
+	"gorm.io/gorm"
 
 	"github.com/nezhahq/nezha/model"
 	"github.com/nezhahq/nezha/service/singleton"
@@ -23,11 +24,23 @@ import (
 // @Router /cron [get]
 func listCron(c *gin.Context) ([]*model.Cron, error) {
 	slist := singleton.CronShared.GetSortedList()
+	if scope, ok := model.GetTenantScope(c); ok {
+		slist = singleton.CronShared.GetSortedListByTenant(scope)
+	}
 
 	var cr []*model.Cron
 	if err := copier.Copy(&cr, &slist); err != nil {
 		return nil, err
 	}
+	for i := range cr {
+		cr[i].Env = slist[i].RedactedEnv()
+		singleton.CronShared.FillRunTimes(cr[i])
+		cr[i].Stats, cr[i].ServerStats = singleton.GetCronStats(cr[i].ID)
+		// 策略修改前创建的任务仍可执行，仅在列表中标记
+		if err := singleton.CommandPolicyShared.CheckOwner(cr[i].UserID, cr[i].Command); err != nil {
+			cr[i].PolicyViolation = err.Error()
+		}
+	}
 	return cr, nil
 }
 
@@ -54,24 +67,41 @@ func createCron(c *gin.Context) (uint64, error) {
 		return 0, singleton.Localizer.ErrorT("permission denied")
 	}
 
+	var err error
 	cr.UserID = getUid(c)
 	cr.TaskType = cf.TaskType
 	cr.Name = cf.Name
 	cr.Scheduler = cf.Scheduler
+	cr.Timezone = cf.Timezone
 	cr.Command = cf.Command
 	cr.Servers = cf.Servers
 	cr.PushSuccessful = cf.PushSuccessful
 	cr.NotificationGroupID = cf.NotificationGroupID
 	cr.Cover = cf.Cover
+	cr.TimeoutSeconds = cf.TimeoutSeconds
+	cr.ConcurrencyPolicy = cf.ConcurrencyPolicy
+	cr.NotifyTimeout = cf.NotifyTimeout
+	cr.Selector = cf.Selector
+	cr.OnSuccessCronID = cf.OnSuccessCronID
+	cr.OnFailureCronID = cf.OnFailureCronID
+	cr.PushFileID = cf.PushFileID
+	cr.PushFilePath = cf.PushFilePath
+	cr.PushFileMode = cf.PushFileMode
+	cr.CommandTemplate = cf.CommandTemplate
+	if cr.Env, err = applyCronEnv(nil, cf.Env); err != nil {
+		return 0, err
+	}
 
 	if cr.TaskType == model.CronTypeCronTask && cr.Cover == model.CronCoverAlertTrigger {
 		return 0, singleton.Localizer.ErrorT("scheduled tasks cannot be triggered by alarms")
 	}
+	if err := validateCronOptions(c, &cr); err != nil {
+		return 0, err
+	}
 
 	// 对于计划任务类型，需要更新CronJob
-	var err error
 	if cf.TaskType == model.CronTypeCronTask {
-		if cr.CronJobID, err = singleton.CronShared.AddFunc(cr.Scheduler, singleton.CronTrigger(&cr)); err != nil {
+		if cr.CronJobID, err = singleton.CronShared.AddFunc(cr.Spec(), singleton.CronTrigger(&cr)); err != nil {
 			return 0, err
 		}
 	}
@@ -81,6 +111,7 @@ func createCron(c *gin.Context) (uint64, error) {
 	}
 
 	singleton.CronShared.Update(&cr)
+	singleton.ClusterShared.PublishChange("cron")
 	return cr.ID, nil
 }
 
@@ -124,19 +155,36 @@ func updateCron(c *gin.Context) (any, error) {
 	cr.TaskType = cf.TaskType
 	cr.Name = cf.Name
 	cr.Scheduler = cf.Scheduler
+	cr.Timezone = cf.Timezone
 	cr.Command = cf.Command
 	cr.Servers = cf.Servers
 	cr.PushSuccessful = cf.PushSuccessful
 	cr.NotificationGroupID = cf.NotificationGroupID
 	cr.Cover = cf.Cover
+	cr.TimeoutSeconds = cf.TimeoutSeconds
+	cr.ConcurrencyPolicy = cf.ConcurrencyPolicy
+	cr.NotifyTimeout = cf.NotifyTimeout
+	cr.Selector = cf.Selector
+	cr.OnSuccessCronID = cf.OnSuccessCronID
+	cr.OnFailureCronID = cf.OnFailureCronID
+	cr.PushFileID = cf.PushFileID
+	cr.PushFilePath = cf.PushFilePath
+	cr.PushFileMode = cf.PushFileMode
+	cr.CommandTemplate = cf.CommandTemplate
+	if cr.Env, err = applyCronEnv(cr.Env, cf.Env); err != nil {
+		return nil, err
+	}
 
 	if cr.TaskType == model.CronTypeCronTask && cr.Cover == model.CronCoverAlertTrigger {
 		return nil, singleton.Localizer.ErrorT("scheduled tasks cannot be triggered by alarms")
 	}
+	if err := validateCronOptions(c, &cr); err != nil {
+		return nil, err
+	}
 
 	// 对于计划任务类型，需要更新CronJob
 	if cf.TaskType == model.CronTypeCronTask {
-		if cr.CronJobID, err = singleton.CronShared.AddFunc(cr.Scheduler, singleton.CronTrigger(&cr)); err != nil {
+		if cr.CronJobID, err = singleton.CronShared.AddFunc(cr.Spec(), singleton.CronTrigger(&cr)); err != nil {
 			return nil, err
 		}
 	}
@@ -146,6 +194,7 @@ func updateCron(c *gin.Context) (any, error) {
 	}
 
 	singleton.CronShared.Update(&cr)
+	singleton.ClusterShared.PublishChange("cron")
 	return nil, nil
 }
 
@@ -180,6 +229,271 @@ func manualTriggerCron(c *gin.Context) (any, error) {
 	return nil, nil
 }
 
+// Run schedule task
+// @Summary Run schedule task
+// @Security BearerAuth
+// @Schemes
+// @Description Run schedule task immediately, optionally on the given servers and server groups instead of its configured targets. Results are recorded in the execution history as agents report them.
+// @Tags auth required
+// @Accept json
+// @param id path uint true "Task ID"
+// @param request body model.CronRunForm false "Target override"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.CronRun]
+// @Router /cron/{id}/run [post]
+func runCron(c *gin.Context) (*model.CronRun, error) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	var rf model.CronRunForm
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&rf); err != nil {
+			return nil, err
+		}
+	}
+
+	cr, ok := singleton.CronShared.Get(id)
+	if !ok {
+		return nil, singleton.Localizer.ErrorT("task id %d does not exist", id)
+	}
+	if !cr.HasPermission(c) {
+		return nil, singleton.Localizer.ErrorT("permission denied")
+	}
+
+	var targets []uint64
+	sel := &model.ServerSelector{Servers: rf.Servers, ServerGroups: rf.ServerGroups}
+	if !sel.IsEmpty() {
+		if err := checkServerSelectorPermission(c, sel); err != nil {
+			return nil, err
+		}
+		if targets = singleton.ResolveServerSelector(sel, cr.UserID); len(targets) == 0 {
+			return nil, singleton.Localizer.ErrorT("no server matches the task")
+		}
+		if !singleton.ServerShared.CheckPermission(c, slices.Values(targets)) {
+			return nil, singleton.Localizer.ErrorT("permission denied")
+		}
+	}
+
+	return singleton.RunCron(cr, targets)
+}
+
+// Get schedule task
+// @Summary Get schedule task
+// @Security BearerAuth
+// @Schemes
+// @Description Get schedule task with the servers it currently resolves to
+// @Tags auth required
+// @param id path uint true "Task ID"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.CronDetail]
+// @Router /cron/{id} [get]
+func getCron(c *gin.Context) (*model.CronDetail, error) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	cr, ok := singleton.CronShared.Get(id)
+	if !ok {
+		return nil, singleton.Localizer.ErrorT("task id %d does not exist", id)
+	}
+	if !cr.HasPermission(c) {
+		return nil, singleton.Localizer.ErrorT("permission denied")
+	}
+
+	var crCopy model.Cron
+	if err := copier.Copy(&crCopy, cr); err != nil {
+		return nil, err
+	}
+	crCopy.Env = cr.RedactedEnv()
+	singleton.CronShared.FillRunTimes(&crCopy)
+	crCopy.Stats, crCopy.ServerStats = singleton.GetCronStats(cr.ID)
+
+	detail := &model.CronDetail{Cron: &crCopy, ResolvedServers: []uint64{}}
+	if cr.Cover != model.CronCoverAlertTrigger {
+		detail.ResolvedServers = singleton.CronTargets(cr)
+	}
+	if cr.TaskType == model.CronTypeCronTask {
+		if preview, err := model.PreviewCronSchedule(cr.Scheduler, cr.Timezone, singleton.Loc, model.CronPreviewCount); err == nil {
+			detail.NextRuns = preview.NextRuns
+		}
+	}
+	return detail, nil
+}
+
+// List schedule task runs
+// @Summary List schedule task runs
+// @Security BearerAuth
+// @Schemes
+// @Description List recent runs of a schedule task with the servers targeted by each run
+// @Tags auth required
+// @param id path uint true "Task ID"
+// @param limit query uint false "Max number of runs, default 100"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[[]model.CronRun]
+// @Router /cron/{id}/runs [get]
+func listCronRun(c *gin.Context) ([]model.CronRun, error) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	cr, ok := singleton.CronShared.Get(id)
+	if !ok {
+		return nil, singleton.Localizer.ErrorT("task id %d does not exist", id)
+	}
+	if !cr.HasPermission(c) {
+		return nil, singleton.Localizer.ErrorT("permission denied")
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+
+	runs, err := singleton.ListCronRuns(id, limit)
+	if err != nil {
+		return nil, newGormError("%v", err)
+	}
+	return runs, nil
+}
+
+// List schedule task executions
+// @Summary List schedule task executions
+// @Security BearerAuth
+// @Schemes
+// @Description List recent executions of a schedule task, including skipped and timed out runs
+// @Tags auth required
+// @param id path uint true "Task ID"
+// @param limit query uint false "Max number of executions, default 100"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[[]model.CronExecution]
+// @Router /cron/{id}/executions [get]
+func listCronExecution(c *gin.Context) ([]model.CronExecution, error) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	cr, ok := singleton.CronShared.Get(id)
+	if !ok {
+		return nil, singleton.Localizer.ErrorT("task id %d does not exist", id)
+	}
+	if !cr.HasPermission(c) {
+		return nil, singleton.Localizer.ErrorT("permission denied")
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+
+	executions, err := singleton.ListCronExecutions(id, limit)
+	if err != nil {
+		return nil, newGormError("%v", err)
+	}
+	return executions, nil
+}
+
+func validateCronOptions(c *gin.Context, cr *model.Cron) error {
+	if err := checkCommandPolicy(c, cr.Command); err != nil {
+		return err
+	}
+	if cr.TaskType == model.CronTypeCronTask {
+		if _, err := model.PreviewCronSchedule(cr.Scheduler, cr.Timezone, singleton.Loc, 0); err != nil {
+			return singleton.Localizer.ErrorT("invalid schedule: %v", err)
+		}
+	}
+	if err := cr.ValidateCommand(); err != nil {
+		return singleton.Localizer.ErrorT("invalid command template: %v", err)
+	}
+	if cr.Cover > model.CronCoverSelector {
+		return singleton.Localizer.ErrorT("invalid cover type")
+	}
+	if cr.Cover == model.CronCoverSelector {
+		if cr.Selector.IsEmpty() {
+			return singleton.Localizer.ErrorT("server selector is required")
+		}
+		if err := checkServerSelectorPermission(c, cr.Selector); err != nil {
+			return err
+		}
+	} else {
+		cr.Selector = nil
+	}
+	switch cr.ConcurrencyPolicy {
+	case "":
+		cr.ConcurrencyPolicy = model.CronConcurrencyAllow
+	case model.CronConcurrencyAllow, model.CronConcurrencySkip, model.CronConcurrencyQueue:
+	default:
+		return singleton.Localizer.ErrorT("invalid concurrency policy")
+	}
+	if cr.TimeoutSeconds > 86400 {
+		return singleton.Localizer.ErrorT("timeout must not exceed 86400 seconds")
+	}
+	if cr.NeedsTaskOptions() {
+		for _, id := range singleton.CronTargets(cr) {
+			if s, ok := singleton.ServerShared.Get(id); ok {
+				if err := singleton.CheckTaskOptions(s); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	for _, id := range []uint64{cr.OnSuccessCronID, cr.OnFailureCronID} {
+		if id == 0 {
+			continue
+		}
+		next, ok := singleton.CronShared.Get(id)
+		if !ok {
+			return singleton.Localizer.ErrorT("task id %d does not exist", id)
+		}
+		if !next.HasPermission(c) {
+			return singleton.Localizer.ErrorT("permission denied")
+		}
+	}
+	if err := singleton.CronShared.CheckChainCycle(cr); err != nil {
+		return err
+	}
+	if cr.PushFileID != 0 {
+		if err := validatePushFileOptions(c, cr.PushFileID, cr.PushFilePath, cr.PushFileMode); err != nil {
+			return err
+		}
+	} else {
+		cr.PushFilePath, cr.PushFileMode = "", 0
+	}
+	return nil
+}
+
+// Validate schedule
+// @Summary Validate schedule
+// @Security BearerAuth
+// @Schemes
+// @Description Validate a cron expression (with seconds) and preview its next run times in the given or dashboard timezone
+// @Tags auth required
+// @Accept json
+// @param request body model.CronScheduleForm true "Schedule"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.CronSchedulePreview]
+// @Router /cron/validate [post]
+func validateCronSchedule(c *gin.Context) (*model.CronSchedulePreview, error) {
+	var sf model.CronScheduleForm
+	if err := c.ShouldBindJSON(&sf); err != nil {
+		return nil, err
+	}
+
+	preview, err := model.PreviewCronSchedule(sf.Scheduler, sf.Timezone, singleton.Loc, model.CronPreviewCount)
+	if err != nil {
+		return nil, singleton.Localizer.ErrorT("invalid schedule: %v", err)
+	}
+	return preview, nil
+}
+
 // Batch delete schedule tasks
 // @Summary Batch delete schedule tasks
 // @Security BearerAuth
@@ -201,10 +515,51 @@ func batchDeleteCron(c *gin.Context) (any, error) {
 		return nil, singleton.Localizer.ErrorT("permission denied")
 	}
 
-	if err := singleton.DB.Unscoped().Delete(&model.Cron{}, "id in (?)", cr).Error; err != nil {
+	if err := singleton.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Delete(&model.CronExecution{}, "cron_id in (?)", cr).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Delete(&model.CronRun{}, "cron_id in (?)", cr).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Delete(&model.CronStatDaily{}, "cron_id in (?)", cr).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&model.Cron{}, "id in (?)", cr).Error
+	}); err != nil {
 		return nil, newGormError("%v", err)
 	}
 
 	singleton.CronShared.Delete(cr)
+	singleton.ClusterShared.PublishChange("cron")
 	return nil, nil
 }
+
+// applyCronEnv 加密新的敏感值，敏感值为占位符时保留原有的加密值
+func applyCronEnv(old, env []model.CronEnv) ([]model.CronEnv, error) {
+	if err := model.ValidateCronEnv(env); err != nil {
+		return nil, err
+	}
+	ret := make([]model.CronEnv, 0, len(env))
+	for _, e := range env {
+		if e.Secret {
+			if e.Value == model.SecretPlaceholder {
+				i := slices.IndexFunc(old, func(o model.CronEnv) bool {
+					return o.Key == e.Key && o.Secret
+				})
+				if i < 0 {
+					return nil, singleton.Localizer.ErrorT("value of secret %s is required", e.Key)
+				}
+				e.Value = old[i].Value
+			} else {
+				v, err := singleton.EncryptSecret(e.Value)
+				if err != nil {
+					return nil, err
+				}
+				e.Value = v
+			}
+		}
+		ret = append(ret, e)
+	}
+	return ret, nil
+}
@@ -1,11 +1,13 @@
 package controller
 
 import (
+	"net"
+	"net/netip"
 	"slices"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
-	"github.com/jinzhu/copier"
 	"golang.org/x/net/idna"
 
 	"github.com/nezhahq/nezha/model"
@@ -23,11 +25,10 @@ import (
 // @Success 200 {object} model.CommonResponse[[]model.DDNSProfile]
 // @Router /ddns [get]
 func listDDNS(c *gin.Context) ([]*model.DDNSProfile, error) {
-	var ddnsProfiles []*model.DDNSProfile
-
 	list := singleton.DDNSShared.GetSortedList()
-	if err := copier.Copy(&ddnsProfiles, &list); err != nil {
-		return nil, err
+	ddnsProfiles := make([]*model.DDNSProfile, 0, len(list))
+	for _, p := range list {
+		ddnsProfiles = append(ddnsProfiles, p.Redacted())
 	}
 
 	return ddnsProfiles, nil
@@ -56,6 +57,7 @@ func createDDNS(c *gin.Context) (uint64, error) {
 		return 0, singleton.Localizer.ErrorT("the retry count must be an integer between 1 and 10")
 	}
 
+	var err error
 	p.UserID = getUid(c)
 	p.Name = df.Name
 	enableIPv4 := df.EnableIPv4
@@ -72,15 +74,32 @@ func createDDNS(c *gin.Context) (uint64, error) {
 	p.WebhookRequestType = df.WebhookRequestType
 	p.WebhookRequestBody = df.WebhookRequestBody
 	p.WebhookHeaders = df.WebhookHeaders
+	p.DryRun = df.DryRun
+	p.Verify = df.Verify
+	user, _ := c.MustGet(model.CtxKeyAuthorizedUser).(*model.User)
+	if p.VerifyResolver, err = normalizeResolvers(df.VerifyResolver, user.Role == model.RoleAdmin); err != nil {
+		return 0, err
+	}
 
 	for n, domain := range p.Domains {
 		// IDN to ASCII
-		domainValid, domainErr := idna.Lookup.ToASCII(domain)
-		if domainErr != nil {
-			return 0, singleton.Localizer.ErrorT("error parsing %s: %v", domain, domainErr)
+		if p.Domains[n], err = domainToASCII(domain); err != nil {
+			return 0, err
 		}
-		p.Domains[n] = domainValid
 	}
+	if p.Records, err = normalizeDDNSRecords(&p, df.Records); err != nil {
+		return 0, err
+	}
+	if err = model.ValidateDDNSSource(df.IPv4Source, df.IPv4SourceValue, false); err != nil {
+		return 0, singleton.Localizer.ErrorT("invalid IPv4 source: %v", err)
+	}
+	if err = model.ValidateDDNSSource(df.IPv6Source, df.IPv6SourceValue, true); err != nil {
+		return 0, singleton.Localizer.ErrorT("invalid IPv6 source: %v", err)
+	}
+	p.IPv4Source = df.IPv4Source
+	p.IPv4SourceValue = df.IPv4SourceValue
+	p.IPv6Source = df.IPv6Source
+	p.IPv6SourceValue = df.IPv6SourceValue
 
 	if err := singleton.DB.Create(&p).Error; err != nil {
 		return 0, newGormError("%v", err)
@@ -134,24 +153,48 @@ func updateDDNS(c *gin.Context) (any, error) {
 	p.EnableIPv4 = &enableIPv4
 	p.EnableIPv6 = &enableIPv6
 	p.MaxRetries = df.MaxRetries
+	// 接口不返回凭据，提交隐藏后的值时保留原有值
+	if p.AccessIDIsSecret() && df.Provider == p.Provider {
+		p.AccessID = model.RestoreSecret(df.AccessID, p.AccessID, model.RedactSecret)
+	} else {
+		p.AccessID = df.AccessID
+	}
 	p.Provider = df.Provider
 	p.Domains = df.Domains
-	p.AccessID = df.AccessID
-	p.AccessSecret = df.AccessSecret
-	p.WebhookURL = df.WebhookURL
+	p.AccessSecret = model.RestoreSecret(df.AccessSecret, p.AccessSecret, model.RedactSecret)
+	p.WebhookURL = model.RestoreSecret(df.WebhookURL, p.WebhookURL, model.RedactURL)
 	p.WebhookMethod = df.WebhookMethod
 	p.WebhookRequestType = df.WebhookRequestType
-	p.WebhookRequestBody = df.WebhookRequestBody
-	p.WebhookHeaders = df.WebhookHeaders
+	p.WebhookRequestBody = model.RestoreSecret(df.WebhookRequestBody, p.WebhookRequestBody, model.RedactSecret)
+	p.WebhookHeaders = model.RestoreSecret(df.WebhookHeaders, p.WebhookHeaders, model.RedactSecret)
+	p.DryRun = df.DryRun
+	p.Verify = df.Verify
+	// 未修改时保留管理员设置的解析器
+	user, _ := c.MustGet(model.CtxKeyAuthorizedUser).(*model.User)
+	allowInternal := user.Role == model.RoleAdmin || df.VerifyResolver == p.VerifyResolver
+	if p.VerifyResolver, err = normalizeResolvers(df.VerifyResolver, allowInternal); err != nil {
+		return nil, err
+	}
 
 	for n, domain := range p.Domains {
 		// IDN to ASCII
-		domainValid, domainErr := idna.Lookup.ToASCII(domain)
-		if domainErr != nil {
-			return nil, singleton.Localizer.ErrorT("error parsing %s: %v", domain, domainErr)
+		if p.Domains[n], err = domainToASCII(domain); err != nil {
+			return nil, err
 		}
-		p.Domains[n] = domainValid
 	}
+	if p.Records, err = normalizeDDNSRecords(&p, df.Records); err != nil {
+		return nil, err
+	}
+	if err = model.ValidateDDNSSource(df.IPv4Source, df.IPv4SourceValue, false); err != nil {
+		return nil, singleton.Localizer.ErrorT("invalid IPv4 source: %v", err)
+	}
+	if err = model.ValidateDDNSSource(df.IPv6Source, df.IPv6SourceValue, true); err != nil {
+		return nil, singleton.Localizer.ErrorT("invalid IPv6 source: %v", err)
+	}
+	p.IPv4Source = df.IPv4Source
+	p.IPv4SourceValue = df.IPv4SourceValue
+	p.IPv6Source = df.IPv6Source
+	p.IPv6SourceValue = df.IPv6SourceValue
 
 	if err = singleton.DB.Save(&p).Error; err != nil {
 		return nil, newGormError("%v", err)
@@ -192,6 +235,72 @@ func batchDeleteDDNS(c *gin.Context) (any, error) {
 	return nil, nil
 }
 
+// List DDNS update history
+// @Summary List DDNS update history
+// @Security BearerAuth
+// @Schemes
+// @Description List recent record updates of a DDNS profile, including dry runs and failures
+// @Tags auth required
+// @param id path uint true "Profile ID"
+// @param limit query uint false "Max number of records, default 100"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[[]model.DDNSHistory]
+// @Router /ddns/{id}/history [get]
+func listDDNSHistory(c *gin.Context) ([]model.DDNSHistory, error) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	p, ok := singleton.DDNSShared.Get(id)
+	if !ok {
+		return nil, singleton.Localizer.ErrorT("profile id %d does not exist", id)
+	}
+	if !p.HasPermission(c) {
+		return nil, singleton.Localizer.ErrorT("permission denied")
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+
+	history, err := singleton.ListDDNSHistory(id, limit)
+	if err != nil {
+		return nil, newGormError("%v", err)
+	}
+	return history, nil
+}
+
+// Run DDNS profile
+// @Summary Run DDNS profile
+// @Security BearerAuth
+// @Schemes
+// @Description Update records of a DDNS profile immediately with the current IP of every server using it, regardless of IP changes
+// @Tags auth required
+// @param id path uint true "Profile ID"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[[]uint64]
+// @Router /ddns/{id}/run [post]
+func runDDNS(c *gin.Context) ([]uint64, error) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	p, ok := singleton.DDNSShared.Get(id)
+	if !ok {
+		return nil, singleton.Localizer.ErrorT("profile id %d does not exist", id)
+	}
+	if !p.HasPermission(c) {
+		return nil, singleton.Localizer.ErrorT("permission denied")
+	}
+
+	return singleton.ServerShared.RunDDNSProfile(id)
+}
+
 // List DDNS Providers
 // @Summary List DDNS providers
 // @Schemes
@@ -204,3 +313,66 @@ func batchDeleteDDNS(c *gin.Context) (any, error) {
 func listProviders(c *gin.Context) ([]string, error) {
 	return model.ProviderList[:], nil
 }
+
+// domainToASCII IDN 转换为 ASCII，保留通配符前缀
+func domainToASCII(domain string) (string, error) {
+	name, wildcard := strings.CutPrefix(domain, "*.")
+	name, err := idna.Lookup.ToASCII(name)
+	if err != nil {
+		return "", singleton.Localizer.ErrorT("error parsing %s: %v", domain, err)
+	}
+	if wildcard {
+		name = "*." + name
+	}
+	return name, nil
+}
+
+// normalizeDDNSRecords 检查记录配置，域名转换为 ASCII
+func normalizeDDNSRecords(p *model.DDNSProfile, records []model.DDNSRecord) ([]model.DDNSRecord, error) {
+	for i := range records {
+		r := &records[i]
+		name, err := domainToASCII(r.Name)
+		if err != nil {
+			return nil, err
+		}
+		r.Name = name
+		r.Type = strings.ToUpper(r.Type)
+		if r.Type != "" && r.Type != "A" && r.Type != "AAAA" {
+			return nil, singleton.Localizer.ErrorT("unsupported record type %s", r.Type)
+		}
+		if r.Proxied && !p.SupportsProxied() {
+			return nil, singleton.Localizer.ErrorT("provider %s does not support proxied records", p.Provider)
+		}
+	}
+	return records, nil
+}
+
+// normalizeResolvers 校验以逗号分隔的解析器地址，未指定端口时使用 53。
+// 解析器由面板发起查询，非管理员只能使用公网 IP 地址，避免借此探测内网
+func normalizeResolvers(resolvers string, allowInternal bool) (string, error) {
+	if strings.TrimSpace(resolvers) == "" {
+		return "", nil
+	}
+	var ret []string
+	for r := range strings.SplitSeq(resolvers, ",") {
+		r = strings.TrimSpace(r)
+		if r == "" {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(r); err != nil {
+			r = net.JoinHostPort(strings.Trim(r, "[]"), "53")
+		}
+		host, _, err := net.SplitHostPort(r)
+		if err != nil {
+			return "", singleton.Localizer.ErrorT("invalid resolver %s", r)
+		}
+		if !allowInternal {
+			addr, err := netip.ParseAddr(host)
+			if err != nil || !addr.IsGlobalUnicast() || addr.IsPrivate() {
+				return "", singleton.Localizer.ErrorT("resolver %s must be a public IP address", r)
+			}
+		}
+		ret = append(ret, r)
+	}
+	return strings.Join(ret, ","), nil
+}
@@ -37,8 +37,12 @@ func createFM(c *gin.Context) (*model.CreateFMResponse, error) {
 		return nil, singleton.Localizer.ErrorT("server not found or not connected")
 	}
 
-	if !server.HasPermission(c) {
-		return nil, singleton.Localizer.ErrorT("permission denied")
+	if err := checkServerAccess(c, server, model.AccessFile); err != nil {
+		return nil, err
+	}
+	// 文件管理通过 Agent 的终端能力实现
+	if err := singleton.CheckCapability(server, model.AgentCapabilityTerminal); err != nil {
+		return nil, err
 	}
 
 	streamId, err := uuid.GenerateUUID()
@@ -47,6 +51,7 @@ func createFM(c *gin.Context) (*model.CreateFMResponse, error) {
 	}
 
 	rpc.NezhaHandlerSingleton.CreateStream(streamId)
+	rpc.NezhaHandlerSingleton.SetStreamOwner(streamId, getUid(c), server.ID)
 
 	fmData, _ := json.Marshal(&model.TaskFM{
 		StreamID: streamId,
@@ -72,7 +77,7 @@ func createFM(c *gin.Context) (*model.CreateFMResponse, error) {
 // @Router /ws/file/{id} [get]
 func fmStream(c *gin.Context) (any, error) {
 	streamId := c.Param("id")
-	if _, err := rpc.NezhaHandlerSingleton.GetStream(streamId); err != nil {
+	if err := checkStreamAccess(c, streamId, model.AccessFile); err != nil {
 		return nil, err
 	}
 	defer rpc.NezhaHandlerSingleton.CloseStream(streamId)
@@ -82,6 +87,7 @@ func fmStream(c *gin.Context) (any, error) {
 		return nil, newWsError("%v", err)
 	}
 	defer wsConn.Close()
+	defer singleton.SessionShared.TrackConn(c.GetString(model.CtxKeySessionID), wsConn)()
 	conn := websocketx.NewConn(wsConn)
 
 	go func() {
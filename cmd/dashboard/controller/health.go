@@ -0,0 +1,128 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/service/rpc"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+// 每项检查的超时时间
+const readyCheckTimeout = 2 * time.Second
+
+type readyCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+var readyChecks = []readyCheck{
+	{"database", checkDatabase},
+	{"singleton", checkSingleton},
+	{"grpc", checkGRPC},
+}
+
+// healthz 进程正在处理请求即返回 200
+func healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, model.HealthResponse{Status: "ok"})
+}
+
+// readyz 检查数据库、子服务缓存与 gRPC 监听，任一项失败时返回 503
+func readyz(c *gin.Context) {
+	resp := model.HealthResponse{Status: "ok"}
+	for _, rc := range readyChecks {
+		ctx, cancel := context.WithTimeout(c, readyCheckTimeout)
+		start := time.Now()
+		err := rc.check(ctx)
+		cancel()
+
+		component := &model.HealthComponent{
+			Name:      rc.name,
+			OK:        err == nil,
+			LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+		}
+		if err != nil {
+			component.Error = err.Error()
+			resp.Status = "fail"
+		}
+		resp.Components = append(resp.Components, component)
+	}
+
+	status := http.StatusOK
+	if resp.Status != "ok" {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, resp)
+}
+
+func checkDatabase(ctx context.Context) error {
+	if singleton.IsRestoring() {
+		return errors.New("restoring backup")
+	}
+	sqlDB, err := singleton.DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+func checkSingleton(context.Context) error {
+	if !singleton.Loaded() {
+		return errors.New("not initialized")
+	}
+	return nil
+}
+
+// checkGRPC gRPC 与 HTTP 共用监听端口，检查服务已注册且端口可以连接
+func checkGRPC(ctx context.Context) error {
+	if rpc.NezhaHandlerSingleton == nil {
+		return errors.New("not registered")
+	}
+
+	host := singleton.Conf.ListenHost
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "127.0.0.1"
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(host, strconv.Itoa(int(singleton.Conf.ListenPort))))
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// Get diagnostics
+// @Summary Get diagnostics
+// @Security BearerAuth
+// @Schemes
+// @Description Get a diagnostics bundle for troubleshooting: build info, database schema version, object counts, GeoIP lookups, connection counts, gRPC listener status, recent error logs, cleanup job runs, self-check findings and settings without secrets
+// @Tags admin required
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.Diagnostics]
+// @Router /admin/diagnostics [get]
+func getDiagnostics(c *gin.Context) (*model.Diagnostics, error) {
+	d, err := singleton.Diagnostics()
+	if err != nil {
+		return nil, newGormError("%v", err)
+	}
+
+	d.GRPC.Listen = net.JoinHostPort(singleton.Conf.ListenHost, strconv.Itoa(int(singleton.Conf.ListenPort)))
+	if singleton.GRPCTLSShared != nil {
+		d.GRPC.TLSListen = net.JoinHostPort(singleton.Conf.ListenHost, strconv.Itoa(int(singleton.Conf.GRPCTLS.ListenPort)))
+	}
+	ctx, cancel := context.WithTimeout(c, readyCheckTimeout)
+	defer cancel()
+	if err := checkGRPC(ctx); err != nil {
+		d.GRPC.Error = err.Error()
+	} else {
+		d.GRPC.OK = true
+	}
+	return d, nil
+}
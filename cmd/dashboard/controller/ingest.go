@@ -0,0 +1,223 @@
+package controller
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+// Ingest annotation
+// @Summary Ingest annotation
+// @Security BearerAuth
+// @Schemes
+// @Description Create an annotation (e.g. a deployment) on the selected servers from an external system. Requests with an existing external_id return the existing annotation.
+// @Tags auth required
+// @Accept json
+// @Param request body model.IngestAnnotationForm true "Annotation"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.IngestResult]
+// @Router /ingest/annotation [post]
+func ingestAnnotation(c *gin.Context) (*model.IngestResult, error) {
+	var af model.IngestAnnotationForm
+	if err := c.ShouldBindJSON(&af); err != nil {
+		return nil, err
+	}
+
+	af.Text = strings.TrimSpace(af.Text)
+	if af.Text == "" {
+		return nil, singleton.Localizer.ErrorT("text is required")
+	}
+	if af.Severity == "" {
+		af.Severity = model.AnnotationSeverityInfo
+	}
+	if !model.ValidAnnotationSeverity(af.Severity) {
+		return nil, singleton.Localizer.ErrorT("unsupported severity: %s", af.Severity)
+	}
+
+	uid := getUid(c)
+	if af.ExternalID != "" {
+		var existing model.Annotation
+		err := singleton.DB.Where("external_id = ?", af.ExternalID).First(&existing).Error
+		if err == nil {
+			if existing.UserID != uid {
+				return nil, singleton.Localizer.ErrorT("external id %s is already in use", af.ExternalID)
+			}
+			return &model.IngestResult{ID: existing.ID}, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, newGormError("%v", err)
+		}
+	}
+
+	if af.Selector.IsEmpty() {
+		return nil, singleton.Localizer.ErrorT("no server matches the selector")
+	}
+	// 只标注有权限的服务器
+	serverIDs := singleton.ResolveServerSelector(&af.Selector, uid)
+	if len(serverIDs) == 0 {
+		return nil, singleton.Localizer.ErrorT("no server matches the selector")
+	}
+
+	a := model.Annotation{
+		Text:      af.Text,
+		Severity:  af.Severity,
+		Time:      time.Now(),
+		ServerIDs: serverIDs,
+	}
+	a.UserID = uid
+	if af.Time != nil {
+		a.Time = *af.Time
+	}
+	if af.ExternalID != "" {
+		a.ExternalID = &af.ExternalID
+	}
+	if err := singleton.DB.Create(&a).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+	return &model.IngestResult{ID: a.ID, Created: true}, nil
+}
+
+// Ingest server
+// @Summary Ingest server
+// @Security BearerAuth
+// @Schemes
+// @Description Pre-create a server before its agent first connects, the agent reporting the same UUID binds to it. Requests with an existing UUID or external_id return the existing server.
+// @Tags auth required
+// @Accept json
+// @Param request body model.IngestServerForm true "Server"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.IngestResult]
+// @Router /ingest/server [post]
+func ingestServer(c *gin.Context) (*model.IngestResult, error) {
+	var sf model.IngestServerForm
+	if err := c.ShouldBindJSON(&sf); err != nil {
+		return nil, err
+	}
+
+	// 与 Agent 认证时的限制一致
+	sf.UUID = strings.TrimSpace(sf.UUID)
+	if sf.UUID == "" || len(sf.UUID) > 64 {
+		return nil, singleton.Localizer.ErrorT("uuid must be 1-64 characters")
+	}
+	sf.Name = strings.TrimSpace(sf.Name)
+	if sf.Name == "" {
+		return nil, singleton.Localizer.ErrorT("name is required")
+	}
+
+	uid := getUid(c)
+	query := singleton.DB.Where("uuid = ?", sf.UUID)
+	if sf.ExternalID != "" {
+		query = query.Or("external_id = ?", sf.ExternalID)
+	}
+	var existing model.Server
+	err := query.First(&existing).Error
+	if err == nil {
+		if existing.UserID != uid || existing.UUID != sf.UUID {
+			return nil, singleton.Localizer.ErrorT("server with uuid %s or external id %s already exists", sf.UUID, sf.ExternalID)
+		}
+		return &model.IngestResult{ID: existing.ID}, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, newGormError("%v", err)
+	}
+
+	if sf.ServerGroupID != 0 {
+		var sg model.ServerGroup
+		if err := singleton.DB.First(&sg, sf.ServerGroupID).Error; err != nil {
+			return nil, singleton.Localizer.ErrorT("group id %d does not exist", sf.ServerGroupID)
+		}
+		if !sg.HasPermission(c) {
+			return nil, singleton.Localizer.ErrorT("permission denied")
+		}
+	}
+
+	s := model.Server{
+		UUID: sf.UUID,
+		Name: sf.Name,
+	}
+	s.UserID = uid
+	if sf.ExternalID != "" {
+		s.ExternalID = &sf.ExternalID
+	}
+	err = singleton.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&s).Error; err != nil {
+			return err
+		}
+		if sf.ServerGroupID != 0 {
+			sgs := model.ServerGroupServer{
+				ServerGroupId: sf.ServerGroupID,
+				ServerId:      s.ID,
+			}
+			sgs.UserID = uid
+			return tx.Create(&sgs).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, newGormError("%v", err)
+	}
+
+	changes := []model.ServerEventChange{{Field: "name", New: s.Name}}
+	if sf.ServerGroupID != 0 {
+		changes = append(changes, model.ServerEventChange{Field: "server_group", New: sf.ServerGroupID})
+	}
+	singleton.RecordServerEvent(s.ID, model.ServerEventRegistered, model.ServerEventActorUser, uid, changes...)
+
+	model.InitServer(&s)
+	singleton.ServerShared.Update(&s, s.UUID)
+	return &model.IngestResult{ID: s.ID, Created: true}, nil
+}
+
+// List server annotations
+// @Summary List server annotations
+// @Security BearerAuth
+// @Schemes
+// @Description List annotations of a server within a time range, used to mark events on history charts
+// @Tags auth required
+// @Param id path uint true "Server ID"
+// @Param from query int false "Start timestamp in seconds, defaults to 30 days ago"
+// @Param to query int false "End timestamp in seconds, defaults to now"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[[]model.Annotation]
+// @Router /server/{id}/annotations [get]
+func listServerAnnotation(c *gin.Context) ([]*model.Annotation, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	server, ok := singleton.ServerShared.Get(id)
+	if !ok || server == nil {
+		return nil, singleton.Localizer.ErrorT("server id %d does not exist", id)
+	}
+	if !server.HasPermission(c) {
+		return nil, singleton.Localizer.ErrorT("permission denied")
+	}
+
+	to := time.Now()
+	from := to.AddDate(0, 0, -30)
+	if v, err := strconv.ParseInt(c.Query("from"), 10, 64); err == nil {
+		from = time.Unix(v, 0)
+	}
+	if v, err := strconv.ParseInt(c.Query("to"), 10, 64); err == nil {
+		to = time.Unix(v, 0)
+	}
+
+	var list []*model.Annotation
+	if err := singleton.DB.Where("time BETWEEN ? AND ?", from, to).Order("time").Find(&list).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+	annotations := make([]*model.Annotation, 0, len(list))
+	for _, a := range list {
+		if a.HasServer(id) {
+			annotations = append(annotations, a)
+		}
+	}
+	return annotations, nil
+}
@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"errors"
 	"net/http"
 	"time"
 
@@ -48,12 +49,37 @@ func initParams() *jwt.GinJWTMiddleware {
 	}
 }
 
+var errPasswordLoginDisabled = errors.New("ApiErrorPasswordLoginDisabled")
+
+// errTooManyLoginAttempts 登录失败次数过多，需要等待后重试或账号已被锁定
+var errTooManyLoginAttempts = errors.New("ApiErrorTooManyLoginAttempts")
+
+// jwtClaimMFA 会话是否通过了两步验证
+const jwtClaimMFA = "mfa"
+
+// jwtIdentity 登录时签发 JWT 所需的信息
+type jwtIdentity struct {
+	UserID    string
+	MFA       bool
+	SessionID string
+}
+
 func payloadFunc() func(data any) jwt.MapClaims {
 	return func(data any) jwt.MapClaims {
-		if v, ok := data.(string); ok {
+		switch v := data.(type) {
+		case string:
 			return jwt.MapClaims{
 				model.CtxKeyAuthorizedUser: v,
 			}
+		case jwtIdentity:
+			claims := jwt.MapClaims{
+				model.CtxKeyAuthorizedUser: v.UserID,
+				jwtClaimMFA:                v.MFA,
+			}
+			if v.SessionID != "" {
+				claims[model.JWTClaimSessionID] = v.SessionID
+			}
+			return claims
 		}
 		return jwt.MapClaims{}
 	}
@@ -67,6 +93,13 @@ func identityHandler() func(c *gin.Context) any {
 		if err := singleton.DB.First(&user, userId).Error; err != nil {
 			return nil
 		}
+		// 升级前签发的 JWT 没有会话 ID，过期前仍然有效
+		if sid, ok := claims[model.JWTClaimSessionID].(string); ok {
+			if !singleton.SessionShared.Validate(sid, user.ID) {
+				return nil
+			}
+			c.Set(model.CtxKeySessionID, sid)
+		}
 		return &user
 	}
 }
@@ -82,37 +115,70 @@ func identityHandler() func(c *gin.Context) any {
 // @Router /login [post]
 func authenticator() func(c *gin.Context) (any, error) {
 	return func(c *gin.Context) (any, error) {
+		if singleton.Conf.DisablePasswordLogin {
+			return nil, errPasswordLoginDisabled
+		}
+
 		var loginVals model.LoginRequest
 		if err := c.ShouldBind(&loginVals); err != nil {
 			return "", jwt.ErrMissingLoginValues
 		}
 
 		var user model.User
-		realip := c.GetString(model.CtxKeyRealIPStr)
+		realip, err := waf.RequestRealIP(c.Request)
+		if err != nil {
+			return nil, jwt.ErrFailedAuthentication
+		}
 
-		if err := singleton.DB.Select("id", "password", "reject_password").Where("username = ?", loginVals.Username).First(&user).Error; err != nil {
-			if err == gorm.ErrRecordNotFound {
-				model.BlockIP(singleton.DB, realip, model.WAFBlockReasonTypeLoginFail, model.BlockIDUnknownUser)
-			}
+		if err := singleton.DB.Select("id", "password", "reject_password", "totp_enabled", "totp_secret", "totp_last_step", "recovery_codes_raw").Where("username = ?", loginVals.Username).First(&user).Error; err != nil && err != gorm.ErrRecordNotFound {
 			return nil, jwt.ErrFailedAuthentication
 		}
 
-		if user.RejectPassword {
-			model.BlockIP(singleton.DB, realip, model.WAFBlockReasonTypeLoginFail, int64(user.ID))
+		if singleton.LoginGuardShared.Check(realip, user.ID) > 0 {
+			return nil, errTooManyLoginAttempts
+		}
+
+		if user.ID == 0 || user.RejectPassword {
+			singleton.LoginGuardShared.Fail(realip, user.ID)
 			return nil, jwt.ErrFailedAuthentication
 		}
 
 		if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(loginVals.Password)); err != nil {
-			model.BlockIP(singleton.DB, realip, model.WAFBlockReasonTypeLoginFail, int64(user.ID))
+			singleton.LoginGuardShared.Fail(realip, user.ID)
 			return nil, jwt.ErrFailedAuthentication
 		}
 
+		if user.TOTPEnabled {
+			if loginVals.OTP == "" {
+				return nil, errTOTPRequired
+			}
+			if err := verifySecondFactor(c, &user, loginVals.OTP); err != nil {
+				return nil, jwt.ErrFailedAuthentication
+			}
+		}
+
+		singleton.LoginGuardShared.Succeed(realip, user.ID)
 		model.UnblockIP(singleton.DB, realip, model.BlockIDUnknownUser)
 		model.UnblockIP(singleton.DB, realip, int64(user.ID))
-		return utils.Itoa(user.ID), nil
+
+		session, err := createSession(c, user.ID)
+		if err != nil {
+			return nil, err
+		}
+		return jwtIdentity{UserID: utils.Itoa(user.ID), MFA: user.TOTPEnabled, SessionID: session.ID}, nil
 	}
 }
 
+// createSession 登录成功后创建会话，记录登录的 IP 与 User-Agent
+func createSession(c *gin.Context, uid uint64) (*model.Session, error) {
+	ip, _ := waf.RequestRealIP(c.Request)
+	session, err := singleton.SessionShared.Create(uid, ip, c.Request.UserAgent())
+	if err != nil {
+		return nil, newGormError("%v", err)
+	}
+	return session, nil
+}
+
 func authorizator() func(data any, c *gin.Context) bool {
 	return func(data any, c *gin.Context) bool {
 		_, ok := data.(*model.User)
@@ -124,7 +190,7 @@ func unauthorized() func(c *gin.Context, code int, message string) {
 	return func(c *gin.Context, code int, message string) {
 		c.JSON(http.StatusOK, model.CommonResponse[any]{
 			Success: false,
-			Error:   "ApiErrorUnauthorized",
+			Error:   utils.IfOr(message == errTOTPRequired.Error() || message == errPasswordLoginDisabled.Error() || message == errTooManyLoginAttempts.Error(), message, "ApiErrorUnauthorized"),
 		})
 	}
 }
@@ -150,27 +216,8 @@ func refreshResponse(c *gin.Context, code int, token string, expire time.Time) {
 
 func fallbackAuthMiddleware(mw *jwt.GinJWTMiddleware) func(c *gin.Context) {
 	return func(c *gin.Context) {
-		claims, err := mw.GetClaimsFromJWT(c)
-		if err != nil {
-			return
-		}
-
-		switch v := claims["exp"].(type) {
-		case nil:
-			return
-		case float64:
-			if int64(v) < mw.TimeFunc().Unix() {
-				return
-			}
-		case json.Number:
-			n, err := v.Int64()
-			if err != nil {
-				return
-			}
-			if n < mw.TimeFunc().Unix() {
-				return
-			}
-		default:
+		claims, ok := validClaims(mw, c)
+		if !ok {
 			return
 		}
 
@@ -190,3 +237,29 @@ func fallbackAuthMiddleware(mw *jwt.GinJWTMiddleware) func(c *gin.Context) {
 		c.Next()
 	}
 }
+
+// validClaims 获取请求中携带的未过期的 JWT
+func validClaims(mw *jwt.GinJWTMiddleware, c *gin.Context) (jwt.MapClaims, bool) {
+	claims, err := mw.GetClaimsFromJWT(c)
+	if err != nil {
+		return nil, false
+	}
+
+	switch v := claims["exp"].(type) {
+	case float64:
+		if int64(v) < mw.TimeFunc().Unix() {
+			return nil, false
+		}
+	case json.Number:
+		n, err := v.Int64()
+		if err != nil {
+			return nil, false
+		}
+		if n < mw.TimeFunc().Unix() {
+			return nil, false
+		}
+	default:
+		return nil, false
+	}
+	return claims, true
+}
@@ -0,0 +1,81 @@
+package controller
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+// Get mesh latency matrix
+// @Summary Get mesh latency matrix
+// @Security BearerAuth
+// @Schemes
+// @Description Get the latest latency between servers taking part in mesh ping, pairs not scheduled yet are omitted
+// @Tags auth required
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.MeshMatrix]
+// @Router /mesh [get]
+func getMeshMatrix(c *gin.Context) (*model.MeshMatrix, error) {
+	matrix, err := singleton.MeshShared.Matrix(func(s *model.Server) bool {
+		return s.HasPermission(c)
+	})
+	if err != nil {
+		return nil, newGormError("%v", err)
+	}
+	return matrix, nil
+}
+
+// Get mesh latency history
+// @Summary Get mesh latency history
+// @Security BearerAuth
+// @Schemes
+// @Description Get the latency history from one server to another
+// @Tags auth required
+// @Param source path uint true "Source server ID"
+// @Param target path uint true "Target server ID"
+// @Param from query string false "Start time (2006-01-02 or RFC3339), defaults to 24 hours ago"
+// @Param to query string false "End time (2006-01-02 or RFC3339), defaults to now"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[[]model.MeshLatency]
+// @Router /mesh/{source}/{target} [get]
+func getMeshHistory(c *gin.Context) ([]*model.MeshLatency, error) {
+	var ids [2]uint64
+	for i, key := range []string{"source", "target"} {
+		id, err := strconv.ParseUint(c.Param(key), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		s, ok := singleton.ServerShared.Get(id)
+		if !ok {
+			return nil, singleton.Localizer.ErrorT("server id %d does not exist", id)
+		}
+		if !s.HasPermission(c) {
+			return nil, singleton.Localizer.ErrorT("permission denied")
+		}
+		ids[i] = id
+	}
+
+	var err error
+	to := time.Now()
+	if v := c.Query("to"); v != "" {
+		if to, err = parseDateQuery(v); err != nil {
+			return nil, err
+		}
+	}
+	from := to.Add(-24 * time.Hour)
+	if v := c.Query("from"); v != "" {
+		if from, err = parseDateQuery(v); err != nil {
+			return nil, err
+		}
+	}
+
+	rows, err := singleton.MeshShared.History(ids[0], ids[1], from, to)
+	if err != nil {
+		return nil, newGormError("%v", err)
+	}
+	return rows, nil
+}
@@ -0,0 +1,53 @@
+package controller
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+const metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// serveMetrics 导出 Prometheus 指标，可使用 metrics_token 或任意 API Token 认证，
+// 普通成员的 API Token 只导出该成员的服务器与服务
+func serveMetrics(c *gin.Context) {
+	token, _ := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+
+	authorized := false
+	var scope singleton.MetricsScope
+	switch {
+	case token == "":
+	case singleton.Conf.MetricsToken != "" &&
+		subtle.ConstantTimeCompare([]byte(token), []byte(singleton.Conf.MetricsToken)) == 1:
+		authorized = true
+	case strings.HasPrefix(token, model.APITokenPrefix):
+		if t, user, err := singleton.APITokenShared.Resolve(token); err == nil && len(t.Scopes) > 0 {
+			authorized = true
+			if user.Role != model.RoleAdmin && user.Role != model.RoleViewer {
+				scope.UserID = user.ID
+			}
+		}
+	}
+
+	if !authorized {
+		if token != "" {
+			if err := model.BlockIP(singleton.DB, c.GetString(model.CtxKeyRealIPStr), model.WAFBlockReasonTypeBruteForceToken, model.BlockIDToken); err != nil {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+		}
+		if token != "" || singleton.Conf.MetricsToken != "" || singleton.Conf.ForceAuth {
+			c.Header("WWW-Authenticate", `Bearer realm="nezha"`)
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+	}
+
+	scope.Guest = !authorized
+	c.Data(http.StatusOK, metricsContentType, singleton.RenderMetrics(scope))
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/jinzhu/copier"
+	"golang.org/x/crypto/bcrypt"
 
 	"github.com/nezhahq/nezha/model"
 	"github.com/nezhahq/nezha/pkg/utils"
@@ -31,9 +32,41 @@ func listNAT(c *gin.Context) ([]*model.NAT, error) {
 		return nil, err
 	}
 
+	for _, nat := range n {
+		nat.Stats = singleton.NATShared.GetStats(nat.ID)
+	}
+
 	return n, nil
 }
 
+// Get NAT profile statistics
+// @Summary Get NAT profile statistics
+// @Security BearerAuth
+// @Schemes
+// @Description Get traffic and connection statistics of a NAT profile since the last reset, with daily history of the last 30 days
+// @Tags auth required
+// @param id path uint true "Profile ID"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.NATStatsDetail]
+// @Router /nat/{id}/stats [get]
+func getNATStats(c *gin.Context) (*model.NATStatsDetail, error) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	n, ok := singleton.NATShared.Get(singleton.NATShared.GetDomain(id))
+	if !ok {
+		return nil, singleton.Localizer.ErrorT("profile id %d does not exist", id)
+	}
+	if !n.HasPermission(c) {
+		return nil, singleton.Localizer.ErrorT("permission denied")
+	}
+
+	return singleton.NATShared.GetStatsDetail(id), nil
+}
+
 // Add NAT profile
 // @Summary Add NAT profile
 // @Security BearerAuth
@@ -67,6 +100,9 @@ func createNAT(c *gin.Context) (uint64, error) {
 	n.Domain = nf.Domain
 	n.Host = nf.Host
 	n.ServerID = nf.ServerID
+	if err := applyNATAccess(&n, &nf); err != nil {
+		return 0, err
+	}
 
 	if err := singleton.DB.Create(&n).Error; err != nil {
 		return 0, newGormError("%v", err)
@@ -121,6 +157,9 @@ func updateNAT(c *gin.Context) (any, error) {
 	n.Domain = nf.Domain
 	n.Host = nf.Host
 	n.ServerID = nf.ServerID
+	if err := applyNATAccess(&n, &nf); err != nil {
+		return nil, err
+	}
 
 	if err := singleton.DB.Save(&n).Error; err != nil {
 		return 0, newGormError("%v", err)
@@ -157,7 +196,37 @@ func batchDeleteNAT(c *gin.Context) (any, error) {
 	if err := singleton.DB.Unscoped().Delete(&model.NAT{}, "id in (?)", n).Error; err != nil {
 		return nil, newGormError("%v", err)
 	}
+	if err := singleton.DB.Unscoped().Delete(&model.NATStatDaily{}, "nat_id in (?)", n).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
 
 	singleton.NATShared.Delete(n)
 	return nil, nil
 }
+
+// applyNATAccess 设置访问控制，基本认证的密码以 bcrypt 哈希保存，提交占位符时保留原有密码
+func applyNATAccess(n *model.NAT, nf *model.NATForm) error {
+	n.AllowCIDRs = nf.AllowCIDRs
+	if err := n.ParseAllowCIDRs(); err != nil {
+		return singleton.Localizer.ErrorT("invalid CIDR: %v", err)
+	}
+
+	n.BasicAuthUser = nf.BasicAuthUser
+	switch {
+	case nf.BasicAuthUser == "":
+		n.BasicAuthPassword = ""
+	case nf.BasicAuthPassword == model.SecretPlaceholder && n.BasicAuthPassword != "":
+	case nf.BasicAuthPassword == "" || nf.BasicAuthPassword == model.SecretPlaceholder:
+		return singleton.Localizer.ErrorT("basic auth password is required")
+	default:
+		hash, err := bcrypt.GenerateFromPassword([]byte(nf.BasicAuthPassword), bcrypt.DefaultCost)
+		if err != nil {
+			return err
+		}
+		n.BasicAuthPassword = string(hash)
+	}
+
+	n.RequireLogin = nf.RequireLogin
+	n.BlockOnDeny = nf.BlockOnDeny
+	return nil
+}
@@ -0,0 +1,243 @@
+package controller
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/nezhahq/nezha/cmd/dashboard/controller/waf"
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/utils"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+// natAuthMiddleware 用于检查 NAT 请求携带的面板登录状态
+var natAuthMiddleware *jwt.GinJWTMiddleware
+
+// CheckNATAccess 按 NAT 配置的访问控制检查请求，拒绝时写入响应并返回 false
+func CheckNATAccess(w http.ResponseWriter, r *http.Request, n *model.NAT) bool {
+	c, _ := gin.CreateTestContext(w)
+	c.Request = r
+
+	ip, err := waf.RequestRealIP(r)
+	if err != nil {
+		waf.ShowBlockPage(c, err)
+		return false
+	}
+	if err := model.CheckIP(singleton.DB, ip); err != nil {
+		waf.ShowBlockPage(c, err)
+		return false
+	}
+	if err := singleton.WAFRuleShared.Check(ip, model.WAFSurfaceHTTP); err != nil {
+		waf.ShowBlockPage(c, err)
+		return false
+	}
+
+	if !n.IPAllowed(ip) {
+		denyNAT(c, n, ip, fmt.Errorf("ip %s is not allowed", ip))
+		return false
+	}
+	if n.RequireLogin {
+		if ticket := r.URL.Query().Get(natTicketParam); ticket != "" {
+			// 面板的 Cookie 不会发送到 NAT 域名，登录状态通过面板签发的一次性凭据传递
+			redeemNATTicket(c, n, ip, ticket)
+			return false
+		}
+		if !natLoggedIn(c, n) {
+			natLoginRedirect(c, n, ip)
+			return false
+		}
+	}
+	if n.BasicAuthUser != "" {
+		user, password, ok := r.BasicAuth()
+		if !ok {
+			natChallenge(w, n)
+			return false
+		}
+		if subtle.ConstantTimeCompare([]byte(user), []byte(n.BasicAuthUser)) != 1 ||
+			bcrypt.CompareHashAndPassword([]byte(n.BasicAuthPassword), []byte(password)) != nil {
+			recordNATDenial(n, ip)
+			natChallenge(w, n)
+			return false
+		}
+	}
+	if n.RequireLogin || n.BasicAuthUser != "" {
+		// 面板的凭据不转发给 Agent
+		r.Header.Del("Authorization")
+		removeCookie(r, natSessionCookie)
+		if natAuthMiddleware != nil {
+			removeCookie(r, natAuthMiddleware.CookieName)
+		}
+	}
+	return true
+}
+
+const (
+	natTicketParam   = "nz_nat_ticket"
+	natSessionCookie = "nz-nat"
+	natTicketTTL     = time.Minute
+)
+
+// natToken 签名绑定用途、用户、NAT 与过期时间，格式为 <用户 ID>.<过期时间>.<签名>
+func natToken(purpose string, uid, natID uint64, exp int64) string {
+	mac := hmac.New(sha256.New, []byte(singleton.Conf.JWTSecretKey))
+	fmt.Fprintf(mac, "nat:%s:%d:%d:%d", purpose, uid, natID, exp)
+	return fmt.Sprintf("%d.%d.%s", uid, exp, hex.EncodeToString(mac.Sum(nil)))
+}
+
+// verifyNATToken 校验签名与有效期，且用户仍然存在，返回用户 ID
+func verifyNATToken(purpose string, natID uint64, token string) (uint64, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return 0, false
+	}
+	uid, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	exp, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || time.Now().Unix() > exp {
+		return 0, false
+	}
+	if !hmac.Equal([]byte(token), []byte(natToken(purpose, uid, natID, exp))) {
+		return 0, false
+	}
+	singleton.UserLock.RLock()
+	_, ok := singleton.UserInfoMap[uid]
+	singleton.UserLock.RUnlock()
+	return uid, ok
+}
+
+func natLoggedIn(c *gin.Context, n *model.NAT) bool {
+	session, err := c.Cookie(natSessionCookie)
+	if err != nil {
+		return false
+	}
+	_, ok := verifyNATToken("session", n.ID, session)
+	return ok
+}
+
+// redeemNATTicket 使用面板签发的凭据在 NAT 域名下建立会话，并跳转到去掉凭据的地址
+func redeemNATTicket(c *gin.Context, n *model.NAT, ip, ticket string) {
+	uid, ok := verifyNATToken("ticket", n.ID, ticket)
+	if !ok {
+		denyNAT(c, n, ip, errors.New("invalid login ticket"))
+		return
+	}
+	// 凭据出现在地址中，可能被记录或泄露，使用后在有效期内拒绝再次使用
+	if singleton.Cache.Add(model.CacheKeyNATTicket+ticket, struct{}{}, natTicketTTL) != nil {
+		denyNAT(c, n, ip, errors.New("login ticket has already been used"))
+		return
+	}
+
+	timeout := time.Hour
+	if natAuthMiddleware != nil {
+		timeout = natAuthMiddleware.Timeout
+	}
+	exp := time.Now().Add(timeout)
+	c.SetCookie(natSessionCookie, natToken("session", uid, n.ID, exp.Unix()), int(timeout.Seconds()), "/", "", requestIsHTTPS(c.Request), true)
+
+	u := *c.Request.URL
+	q := u.Query()
+	q.Del(natTicketParam)
+	u.RawQuery = q.Encode()
+	c.Redirect(http.StatusFound, u.RequestURI())
+}
+
+// natLoginRedirect 未登录时跳转到面板签发凭据，未设置面板地址时拒绝访问
+func natLoginRedirect(c *gin.Context, n *model.NAT, ip string) {
+	if singleton.Conf.DashboardURL == "" || c.Request.Method != http.MethodGet {
+		denyNAT(c, n, ip, errors.New("login required"))
+		return
+	}
+	q := url.Values{}
+	q.Set("redirect", c.Request.URL.RequestURI())
+	c.Redirect(http.StatusFound, fmt.Sprintf("%s/api/v1/nat/%d/login?%s", strings.TrimSuffix(singleton.Conf.DashboardURL, "/"), n.ID, q.Encode()))
+}
+
+// NAT login
+// @Summary NAT login
+// @Security BearerAuth
+// @Schemes
+// @Description Issue a one-time ticket for a NAT profile that requires login and redirect to its domain
+// @Tags auth required
+// @param id path uint true "Profile ID"
+// @param redirect query string false "Path on the NAT domain"
+// @Produce json
+// @Success 302
+// @Router /nat/{id}/login [get]
+func natLogin(c *gin.Context) (any, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	n, ok := singleton.NATShared.Get(singleton.NATShared.GetDomain(id))
+	if !ok || !n.RequireLogin {
+		return nil, singleton.Localizer.ErrorT("profile id %d does not exist", id)
+	}
+	if !n.HasPermission(c) {
+		return nil, singleton.Localizer.ErrorT("permission denied")
+	}
+
+	path := c.Query("redirect")
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") {
+		path = "/"
+	}
+	target, err := url.Parse(path)
+	if err != nil {
+		return nil, err
+	}
+	q := target.Query()
+	q.Set(natTicketParam, natToken("ticket", getUid(c), n.ID, time.Now().Add(natTicketTTL).Unix()))
+	target.RawQuery = q.Encode()
+	target.Scheme = utils.IfOr(requestIsHTTPS(c.Request), "https", "http")
+	target.Host = n.Domain
+
+	c.Redirect(http.StatusFound, target.String())
+	return nil, errNoop
+}
+
+// requestIsHTTPS 请求是否通过 HTTPS 访问，包括由反向代理终止 TLS 的情况
+func requestIsHTTPS(r *http.Request) bool {
+	return r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https"
+}
+
+func denyNAT(c *gin.Context, n *model.NAT, ip string, err error) {
+	recordNATDenial(n, ip)
+	waf.ShowBlockPage(c, err)
+}
+
+// recordNATDenial 统计拒绝次数，开启后计入 WAF，多次拒绝的 IP 会被封禁
+func recordNATDenial(n *model.NAT, ip string) {
+	singleton.NATShared.Deny(n.ID)
+	if n.BlockOnDeny {
+		model.BlockIP(singleton.DB, ip, model.WAFBlockReasonTypeNATDenied, model.BlockIDNAT)
+	}
+}
+
+func natChallenge(w http.ResponseWriter, n *model.NAT) {
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf("Basic realm=%q", n.Name))
+	w.WriteHeader(http.StatusUnauthorized)
+}
+
+func removeCookie(r *http.Request, name string) {
+	cookies := r.Cookies()
+	r.Header.Del("Cookie")
+	for _, cookie := range cookies {
+		if cookie.Name != name {
+			r.AddCookie(cookie)
+		}
+	}
+}
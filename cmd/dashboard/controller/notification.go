@@ -24,11 +24,19 @@ import (
 // @Router /notification [get]
 func listNotification(c *gin.Context) ([]*model.Notification, error) {
 	slist := singleton.NotificationShared.GetSortedList()
+	if scope, ok := model.GetTenantScope(c); ok {
+		slist = singleton.NotificationShared.GetSortedListByTenant(scope)
+	}
+	// 只复制有权限访问的通知方式
+	slist = filter(c, slices.Clone(slist))
 
 	var notifications []*model.Notification
 	if err := copier.Copy(&notifications, &slist); err != nil {
 		return nil, err
 	}
+	for i, n := range notifications {
+		notifications[i] = n.Redacted()
+	}
 	return notifications, nil
 }
 
@@ -49,6 +57,10 @@ func createNotification(c *gin.Context) (uint64, error) {
 		return 0, err
 	}
 
+	if err := validateNotificationForm(&nf); err != nil {
+		return 0, err
+	}
+
 	var n model.Notification
 	n.UserID = getUid(c)
 	n.Name = nf.Name
@@ -59,6 +71,15 @@ func createNotification(c *gin.Context) (uint64, error) {
 	n.URL = nf.URL
 	verifyTLS := nf.VerifyTLS
 	n.VerifyTLS = &verifyTLS
+	n.Type = nf.Type
+	n.TelegramBotToken = nf.TelegramBotToken
+	n.TelegramChatID = nf.TelegramChatID
+	n.TelegramThreadID = nf.TelegramThreadID
+	n.TelegramParseMode = nf.TelegramParseMode
+	n.DisableDedup = nf.DisableDedup
+	if n.Type == model.NotificationTypeTelegram && n.TelegramBotToken == "" {
+		return 0, singleton.Localizer.ErrorT("telegram bot token is required")
+	}
 
 	ns := model.NotificationServerBundle{
 		Notification: &n,
@@ -102,6 +123,9 @@ func updateNotification(c *gin.Context) (any, error) {
 	if err := c.ShouldBindJSON(&nf); err != nil {
 		return nil, err
 	}
+	if err := validateNotificationForm(&nf); err != nil {
+		return nil, err
+	}
 
 	var n model.Notification
 	if err := singleton.DB.First(&n, id).Error; err != nil {
@@ -115,11 +139,21 @@ func updateNotification(c *gin.Context) (any, error) {
 	n.Name = nf.Name
 	n.RequestMethod = nf.RequestMethod
 	n.RequestType = nf.RequestType
-	n.RequestHeader = nf.RequestHeader
-	n.RequestBody = nf.RequestBody
-	n.URL = nf.URL
+	// 接口不返回地址与请求内容中的凭据，提交隐藏后的值时保留原有值
+	n.RequestHeader = model.RestoreSecret(nf.RequestHeader, n.RequestHeader, model.RedactSecret)
+	n.RequestBody = model.RestoreSecret(nf.RequestBody, n.RequestBody, model.RedactSecret)
+	n.URL = model.RestoreSecret(nf.URL, n.URL, model.RedactURL)
 	verifyTLS := nf.VerifyTLS
 	n.VerifyTLS = &verifyTLS
+	n.Type = nf.Type
+	n.TelegramBotToken = model.RestoreSecret(nf.TelegramBotToken, n.TelegramBotToken, model.RedactSecret)
+	n.TelegramChatID = nf.TelegramChatID
+	n.TelegramThreadID = nf.TelegramThreadID
+	n.TelegramParseMode = nf.TelegramParseMode
+	n.DisableDedup = nf.DisableDedup
+	if n.Type == model.NotificationTypeTelegram && n.TelegramBotToken == "" {
+		return nil, singleton.Localizer.ErrorT("telegram bot token is required")
+	}
 
 	ns := model.NotificationServerBundle{
 		Notification: &n,
@@ -179,3 +213,20 @@ func batchDeleteNotification(c *gin.Context) (any, error) {
 	singleton.NotificationShared.Delete(n)
 	return nil, nil
 }
+
+// validateNotificationForm 检查通知类型及 Telegram 通知的必填项
+func validateNotificationForm(nf *model.NotificationForm) error {
+	switch nf.Type {
+	case model.NotificationTypeWebhook:
+	case model.NotificationTypeTelegram:
+		if nf.TelegramChatID == "" {
+			return singleton.Localizer.ErrorT("telegram chat id is required")
+		}
+		if !model.ValidTelegramParseMode(nf.TelegramParseMode) {
+			return singleton.Localizer.ErrorT("unsupported parse mode: %s", nf.TelegramParseMode)
+		}
+	default:
+		return singleton.Localizer.ErrorT("unsupported notification type: %d", nf.Type)
+	}
+	return nil
+}
@@ -22,7 +22,7 @@ import (
 // @Router /notification-group [get]
 func listNotificationGroup(c *gin.Context) ([]*model.NotificationGroupResponseItem, error) {
 	var ng []model.NotificationGroup
-	if err := singleton.DB.Find(&ng).Error; err != nil {
+	if err := tenantQuery(c, singleton.DB).Find(&ng).Error; err != nil {
 		return nil, err
 	}
 
@@ -74,9 +74,19 @@ func createNotificationGroup(c *gin.Context) (uint64, error) {
 
 	uid := getUid(c)
 
+	if !singleton.ValidLanguage(ngf.Language) {
+		return 0, singleton.Localizer.ErrorT("unsupported language: %s", ngf.Language)
+	}
+
+	if err := validateActiveHours(c, 0, ngf.ActiveHours); err != nil {
+		return 0, err
+	}
+
 	var ng model.NotificationGroup
 	ng.Name = ngf.Name
+	ng.Language = singleton.NormalizeLanguage(ngf.Language)
 	ng.UserID = uid
+	ng.ActiveHours = ngf.ActiveHours
 
 	var count int64
 	if err := singleton.DB.Model(&model.Notification{}).Where("id in (?)", ngf.Notifications).Count(&count).Error; err != nil {
@@ -150,7 +160,17 @@ func updateNotificationGroup(c *gin.Context) (any, error) {
 		return nil, singleton.Localizer.ErrorT("permission denied")
 	}
 
+	if !singleton.ValidLanguage(ngf.Language) {
+		return nil, singleton.Localizer.ErrorT("unsupported language: %s", ngf.Language)
+	}
+
+	if err := validateActiveHours(c, id, ngf.ActiveHours); err != nil {
+		return nil, err
+	}
+
 	ngDB.Name = ngf.Name
+	ngDB.Language = singleton.NormalizeLanguage(ngf.Language)
+	ngDB.ActiveHours = ngf.ActiveHours
 	ngf.Notifications = slices.Compact(ngf.Notifications)
 
 	var count int64
@@ -237,3 +257,28 @@ func batchDeleteNotificationGroup(c *gin.Context) (any, error) {
 	singleton.NotificationShared.DeleteGroup(ngn)
 	return nil, nil
 }
+
+// validateActiveHours 校验接收时段，备用通知组必须存在且不能是通知组自身
+func validateActiveHours(c *gin.Context, gid uint64, ah *model.ActiveHours) error {
+	if ah == nil {
+		return nil
+	}
+	if err := ah.Validate(singleton.Loc); err != nil {
+		return err
+	}
+	if ah.Policy != model.QuietHoursPolicyFallback {
+		return nil
+	}
+	if ah.FallbackGroupID == gid {
+		return singleton.Localizer.ErrorT("fallback group cannot be the group itself")
+	}
+
+	var fallback model.NotificationGroup
+	if err := singleton.DB.First(&fallback, ah.FallbackGroupID).Error; err != nil {
+		return singleton.Localizer.ErrorT("group id %d does not exist", ah.FallbackGroupID)
+	}
+	if !fallback.HasPermission(c) {
+		return singleton.Localizer.ErrorT("permission denied")
+	}
+	return nil
+}
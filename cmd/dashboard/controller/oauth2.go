@@ -2,19 +2,18 @@ package controller
 
 import (
 	"fmt"
-	"io"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	jwt "github.com/appleboy/gin-jwt/v2"
 	"github.com/gin-gonic/gin"
 	"github.com/patrickmn/go-cache"
-	"github.com/tidwall/gjson"
-	"golang.org/x/oauth2"
 	"gorm.io/gorm"
 
 	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/oidc"
 	"github.com/nezhahq/nezha/pkg/utils"
 	"github.com/nezhahq/nezha/service/singleton"
 )
@@ -51,21 +50,25 @@ func oauth2redirect(c *gin.Context) (*model.Oauth2LoginResponse, error) {
 		return nil, singleton.Localizer.ErrorT("provider not found")
 	}
 	redirectURL := getRedirectURL(c)
-	o2conf := o2confRaw.Setup(redirectURL)
+	o2provider, err := newOauth2Provider(c, o2confRaw, redirectURL)
+	if err != nil {
+		return nil, err
+	}
 
-	randomString, err := utils.GenerateRandomString(32)
+	randomString, err := utils.GenerateRandomString(48)
 	if err != nil {
 		return nil, err
 	}
-	state, stateKey := randomString[:16], randomString[16:]
+	state, stateKey, nonce := randomString[:16], randomString[16:32], randomString[32:]
 	singleton.Cache.Set(fmt.Sprintf("%s%s", model.CacheKeyOauth2State, stateKey), &model.Oauth2State{
 		Action:      model.Oauth2LoginType(rTypeInt),
 		Provider:    provider,
 		State:       state,
+		Nonce:       nonce,
 		RedirectURL: redirectURL,
 	}, cache.DefaultExpiration)
 
-	url := o2conf.AuthCodeURL(state, oauth2.AccessTypeOnline)
+	url := o2provider.AuthCodeURL(state, nonce)
 	c.SetCookie("nz-o2s", stateKey, 60*5, "", "", false, false)
 
 	return &model.Oauth2LoginResponse{Redirect: url}, nil
@@ -108,6 +111,74 @@ func unbindOauth2(c *gin.Context) (any, error) {
 	return nil, nil
 }
 
+const oauth2SessionCookie = "nz-o2l"
+
+// Oauth2 登录后等待两步验证的 Cookie 及有效期
+const (
+	oauth2MFACookie  = "nz-o2m"
+	oauth2MFATimeout = 5 * time.Minute
+)
+
+// oauth2Session 通过 OIDC 登录的会话信息
+type oauth2Session struct {
+	Provider string
+	IDToken  string
+}
+
+// oauth2PendingMFA 已通过 Oauth2 认证、等待提交验证码的登录
+type oauth2PendingMFA struct {
+	UserID   uint64
+	Provider string
+	IDToken  string
+}
+
+// Logout
+// @Summary Logout
+// @Security BearerAuth
+// @Schemes
+// @Description Clear the login cookie, returns the logout URL of the identity provider if the session is from OIDC
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.LogoutResponse]
+// @Router /logout [post]
+func logout(mw *jwt.GinJWTMiddleware) func(c *gin.Context) (*model.LogoutResponse, error) {
+	return func(c *gin.Context) (*model.LogoutResponse, error) {
+		c.SetCookie(mw.CookieName, "", -1, "/", mw.CookieDomain, mw.SecureCookie, mw.CookieHTTPOnly)
+		if claims, ok := validClaims(mw, c); ok {
+			uid, _ := claims[model.CtxKeyAuthorizedUser].(string)
+			if sid, ok := claims[model.JWTClaimSessionID].(string); ok {
+				id, _ := strconv.ParseUint(uid, 10, 64)
+				singleton.SessionShared.Revoke(id, sid)
+			}
+		}
+
+		var resp model.LogoutResponse
+		logoutKey, err := c.Cookie(oauth2SessionCookie)
+		if err != nil {
+			return &resp, nil
+		}
+		c.SetCookie(oauth2SessionCookie, "", -1, "/", "", false, true)
+
+		cacheKey := model.CacheKeyOauth2IDToken + logoutKey
+		v, ok := singleton.Cache.Get(cacheKey)
+		if !ok {
+			return &resp, nil
+		}
+		singleton.Cache.Delete(cacheKey)
+
+		session := v.(*oauth2Session)
+		o2conf, ok := singleton.Conf.Oauth2[session.Provider]
+		if !ok || !o2conf.IsOIDC() {
+			return &resp, nil
+		}
+		p, err := oidc.Get(c, o2conf.Issuer)
+		if err != nil {
+			return nil, err
+		}
+		resp.Redirect = p.LogoutURL(session.IDToken, o2conf.ClientID, strings.TrimSuffix(getRedirectURL(c), "/api/v1/oauth2/callback")+"/dashboard/login")
+		return &resp, nil
+	}
+}
+
 // @Summary Oauth2 Callback
 // @Description Oauth2 Callback
 // @Accept json
@@ -139,13 +210,20 @@ func oauth2callback(jwtConfig *jwt.GinJWTMiddleware) func(c *gin.Context) (any,
 			return nil, singleton.Localizer.ErrorT("code is required")
 		}
 
-		openId, err := exchangeOpenId(c, o2confRaw, callbackData, state.RedirectURL)
+		o2provider, err := newOauth2Provider(c, o2confRaw, state.RedirectURL)
+		if err != nil {
+			return nil, err
+		}
+		identity, err := o2provider.Exchange(c, callbackData.Code, state.Nonce)
 		if err != nil {
 			model.BlockIP(singleton.DB, realip, model.WAFBlockReasonTypeBruteForceOauth2, model.BlockIDToken)
 			return nil, err
 		}
+		openId := identity.OpenID
+		model.UnblockIP(singleton.DB, realip, model.BlockIDToken)
 
 		var bind model.Oauth2Bind
+		providerName := state.Provider
 		state.Provider = strings.ToLower(state.Provider)
 		switch state.Action {
 		case model.RTypeBind:
@@ -173,42 +251,118 @@ func oauth2callback(jwtConfig *jwt.GinJWTMiddleware) func(c *gin.Context) (any,
 			}
 		default:
 			if err := singleton.DB.Where("provider = ? AND open_id = ?", state.Provider, openId).First(&bind).Error; err != nil {
-				return nil, singleton.Localizer.ErrorT("oauth2 user not binded yet")
+				if !o2confRaw.AutoProvision {
+					return nil, singleton.Localizer.ErrorT("oauth2 user not binded yet")
+				}
+				b, err := provisionOauth2User(state.Provider, o2confRaw, identity)
+				if err != nil {
+					return nil, err
+				}
+				bind = *b
+			}
+			if err := syncOauth2Role(bind.UserID, o2confRaw, identity.Claims); err != nil {
+				return nil, err
 			}
 		}
 
-		tokenString, _, err := jwtConfig.TokenGenerator(fmt.Sprintf("%d", bind.UserID))
-		if err != nil {
-			return nil, err
+		if state.Action != model.RTypeBind {
+			var user model.User
+			if err := singleton.DB.Select("id", "totp_enabled").First(&user, bind.UserID).Error; err != nil {
+				return nil, newGormError("%v", err)
+			}
+			if user.TOTPEnabled {
+				// 已启用两步验证的用户需要再提交验证码才能建立会话
+				pendingKey := utils.MustGenerateRandomString(32)
+				singleton.Cache.Set(model.CacheKeyOauth2MFA+pendingKey, &oauth2PendingMFA{
+					UserID:   bind.UserID,
+					Provider: providerName,
+					IDToken:  identity.IDToken,
+				}, oauth2MFATimeout)
+				c.SetCookie(oauth2MFACookie, pendingKey, int(oauth2MFATimeout.Seconds()), "/", "", false, true)
+				c.Redirect(http.StatusFound, "/dashboard/login?oauth2=true&totp=true")
+				return nil, errNoop
+			}
 		}
 
-		jwtConfig.SetCookie(c, tokenString)
+		if _, err := issueOauth2Session(c, jwtConfig, bind.UserID, false, providerName, identity.IDToken); err != nil {
+			return nil, err
+		}
 		c.Redirect(http.StatusFound, utils.IfOr(state.Action == model.RTypeBind, "/dashboard/profile?oauth2=true", "/dashboard/login?oauth2=true"))
 
 		return nil, errNoop
 	}
 }
 
-func exchangeOpenId(c *gin.Context, o2confRaw *model.Oauth2Config,
-	callbackData *model.Oauth2Callback, redirectURL string) (string, error) {
-	o2conf := o2confRaw.Setup(redirectURL)
+// Oauth2 TOTP
+// @Summary Oauth2 TOTP
+// @Description Complete an Oauth2 login with a verification code when two-factor authentication is enabled
+// @Accept json
+// @param request body model.TOTPCodeForm true "TOTPCodeForm"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.LoginResponse]
+// @Router /api/v1/oauth2/totp [post]
+func oauth2TOTP(jwtConfig *jwt.GinJWTMiddleware) func(c *gin.Context) (*model.LoginResponse, error) {
+	return func(c *gin.Context) (*model.LoginResponse, error) {
+		var cf model.TOTPCodeForm
+		if err := c.ShouldBindJSON(&cf); err != nil {
+			return nil, err
+		}
 
-	otk, err := o2conf.Exchange(c, callbackData.Code)
-	if err != nil {
-		return "", err
+		pendingKey, err := c.Cookie(oauth2MFACookie)
+		if err != nil {
+			return nil, singleton.Localizer.ErrorT("invalid state key")
+		}
+		cacheKey := model.CacheKeyOauth2MFA + pendingKey
+		v, ok := singleton.Cache.Get(cacheKey)
+		if !ok {
+			return nil, singleton.Localizer.ErrorT("invalid state key")
+		}
+		pending := v.(*oauth2PendingMFA)
+
+		var user model.User
+		if err := singleton.DB.Select("id", "totp_enabled", "totp_secret", "totp_last_step", "recovery_codes_raw").First(&user, pending.UserID).Error; err != nil {
+			return nil, newGormError("%v", err)
+		}
+		if user.TOTPEnabled {
+			if err := verifySecondFactor(c, &user, cf.Code); err != nil {
+				return nil, err
+			}
+		}
+
+		// 验证通过后待验证状态只能使用一次
+		singleton.Cache.Delete(cacheKey)
+		c.SetCookie(oauth2MFACookie, "", -1, "/", "", false, true)
+
+		return issueOauth2Session(c, jwtConfig, user.ID, user.TOTPEnabled, pending.Provider, pending.IDToken)
 	}
-	oauth2client := o2conf.Client(c, otk)
-	resp, err := oauth2client.Get(o2confRaw.UserInfoURL)
+}
+
+// issueOauth2Session 为通过 Oauth2 登录的用户建立会话并写入 Cookie
+func issueOauth2Session(c *gin.Context, jwtConfig *jwt.GinJWTMiddleware, uid uint64, mfa bool, provider, idToken string) (*model.LoginResponse, error) {
+	session, err := createSession(c, uid)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
+	tokenString, expire, err := jwtConfig.TokenGenerator(jwtIdentity{UserID: fmt.Sprintf("%d", uid), MFA: mfa, SessionID: session.ID})
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	jwtConfig.SetCookie(c, tokenString)
+	if idToken != "" {
+		// 保存 ID Token 用于登出时通知身份提供方
+		logoutKey := utils.MustGenerateRandomString(32)
+		singleton.Cache.Set(model.CacheKeyOauth2IDToken+logoutKey, &oauth2Session{
+			Provider: provider,
+			IDToken:  idToken,
+		}, jwtConfig.Timeout)
+		c.SetCookie(oauth2SessionCookie, logoutKey, int(jwtConfig.Timeout.Seconds()), "/", "", false, true)
 	}
 
-	return gjson.GetBytes(body, o2confRaw.UserIDPath).String(), nil
+	return &model.LoginResponse{
+		Token:  tokenString,
+		Expire: expire.Format(time.RFC3339),
+	}, nil
 }
 
 func verifyState(c *gin.Context, state string) (*model.Oauth2State, error) {
@@ -228,6 +382,8 @@ func verifyState(c *gin.Context, state string) (*model.Oauth2State, error) {
 	if !ok || oauth2State.State != state {
 		return nil, singleton.Localizer.ErrorT("invalid state key")
 	}
+	// State 只能使用一次
+	singleton.Cache.Delete(cacheKey)
 
 	return oauth2State, nil
 }
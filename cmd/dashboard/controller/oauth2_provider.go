@@ -0,0 +1,192 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/oauth2"
+	"gorm.io/gorm"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/oidc"
+	"github.com/nezhahq/nezha/pkg/utils"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+// oauth2Identity 第三方登录得到的用户信息
+type oauth2Identity struct {
+	OpenID   string
+	Username string
+	Claims   map[string]any
+	IDToken  string
+}
+
+// oauth2Provider 第三方登录提供方，普通 OAuth2 通过用户信息接口识别用户，OIDC 通过 ID Token 识别
+type oauth2Provider interface {
+	AuthCodeURL(state, nonce string) string
+	Exchange(c *gin.Context, code, nonce string) (*oauth2Identity, error)
+}
+
+func newOauth2Provider(c *gin.Context, conf *model.Oauth2Config, redirectURL string) (oauth2Provider, error) {
+	o2conf := conf.Setup(redirectURL)
+	if !conf.IsOIDC() {
+		return &genericOauth2Provider{conf: conf, o2conf: o2conf}, nil
+	}
+
+	p, err := oidc.Get(c, conf.Issuer)
+	if err != nil {
+		return nil, err
+	}
+	o2conf.Endpoint = oauth2.Endpoint{AuthURL: p.AuthURL, TokenURL: p.TokenURL}
+	if !slices.Contains(o2conf.Scopes, "openid") {
+		o2conf.Scopes = append([]string{"openid", "profile", "email"}, o2conf.Scopes...)
+	}
+	return &oidcProvider{conf: conf, o2conf: o2conf, provider: p}, nil
+}
+
+type genericOauth2Provider struct {
+	conf   *model.Oauth2Config
+	o2conf *oauth2.Config
+}
+
+func (p *genericOauth2Provider) AuthCodeURL(state, _ string) string {
+	return p.o2conf.AuthCodeURL(state, oauth2.AccessTypeOnline)
+}
+
+func (p *genericOauth2Provider) Exchange(c *gin.Context, code, _ string) (*oauth2Identity, error) {
+	otk, err := p.o2conf.Exchange(c, code)
+	if err != nil {
+		return nil, err
+	}
+	oauth2client := p.o2conf.Client(c, otk)
+	resp, err := oauth2client.Get(p.conf.UserInfoURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	openID := gjson.GetBytes(body, p.conf.UserIDPath).String()
+	if openID == "" {
+		return nil, errors.New("empty user id")
+	}
+	return &oauth2Identity{OpenID: openID}, nil
+}
+
+type oidcProvider struct {
+	conf     *model.Oauth2Config
+	o2conf   *oauth2.Config
+	provider *oidc.Provider
+}
+
+func (p *oidcProvider) AuthCodeURL(state, nonce string) string {
+	return p.o2conf.AuthCodeURL(state, oauth2.AccessTypeOnline, oauth2.SetAuthURLParam("nonce", nonce))
+}
+
+func (p *oidcProvider) Exchange(c *gin.Context, code, nonce string) (*oauth2Identity, error) {
+	otk, err := p.o2conf.Exchange(c, code)
+	if err != nil {
+		return nil, err
+	}
+	rawIDToken, _ := otk.Extra("id_token").(string)
+	if rawIDToken == "" {
+		return nil, errors.New("oidc: missing id token")
+	}
+
+	claims, err := p.provider.Verify(c, rawIDToken, p.conf.ClientID, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	identity := &oauth2Identity{
+		Claims:  claims,
+		IDToken: rawIDToken,
+	}
+	identity.OpenID, _ = claims["sub"].(string)
+	for _, key := range []string{"preferred_username", "email", "name"} {
+		if v, _ := claims[key].(string); v != "" {
+			identity.Username = v
+			break
+		}
+	}
+	return identity, nil
+}
+
+// provisionOauth2User 为未绑定的第三方用户创建本地用户，该用户不能使用密码登录
+func provisionOauth2User(provider string, conf *model.Oauth2Config, identity *oauth2Identity) (*model.Oauth2Bind, error) {
+	role, ok := conf.MapRole(identity.Claims)
+	if !ok {
+		role = model.RoleMember
+	}
+
+	username := identity.Username
+	if username == "" {
+		username = fmt.Sprintf("%s-%s", provider, identity.OpenID)
+	}
+	var count int64
+	if err := singleton.DB.Model(&model.User{}).Where("username = ?", username).Count(&count).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+	if count > 0 {
+		username = fmt.Sprintf("%s-%s", username, strings.ToLower(utils.MustGenerateRandomString(4)))
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(utils.MustGenerateRandomString(32)), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	user := model.User{
+		Username:       username,
+		Password:       string(hash),
+		Role:           role,
+		RejectPassword: true,
+	}
+	bind := model.Oauth2Bind{
+		Provider: provider,
+		OpenID:   identity.OpenID,
+	}
+	if err := singleton.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		bind.UserID = user.ID
+		return tx.Create(&bind).Error
+	}); err != nil {
+		return nil, newGormError("%v", err)
+	}
+
+	singleton.OnUserUpdate(&user)
+	return &bind, nil
+}
+
+// syncOauth2Role 按声明更新用户角色
+func syncOauth2Role(uid uint64, conf *model.Oauth2Config, claims map[string]any) error {
+	role, ok := conf.MapRole(claims)
+	if !ok {
+		return nil
+	}
+
+	var user model.User
+	if err := singleton.DB.First(&user, uid).Error; err != nil {
+		return newGormError("%v", err)
+	}
+	if user.Role == role {
+		return nil
+	}
+	user.Role = role
+	if err := singleton.DB.Model(&user).Update("role", role).Error; err != nil {
+		return newGormError("%v", err)
+	}
+	singleton.OnUserUpdate(&user)
+	return nil
+}
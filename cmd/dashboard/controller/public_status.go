@@ -0,0 +1,131 @@
+package controller
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+// publicStatusPayload 缓存的游客状态数据，generation 在每次重新生成时递增
+type publicStatusPayload struct {
+	data       []byte
+	generation uint64
+	expires    time.Time
+}
+
+var publicStatusCache struct {
+	mu         sync.Mutex
+	payloads   map[string]*publicStatusPayload // 严格租户模式下按租户范围缓存
+	generation uint64
+}
+
+// publicStatusGuard 接口关闭或要求登录时返回 404，否则按 IP 限流
+func publicStatusGuard(c *gin.Context) {
+	if singleton.Conf.PublicStatus.Disable || singleton.Conf.ForceAuth {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	ip := c.GetString(model.CtxKeyRealIPStr)
+	if ip == "" {
+		ip = c.RemoteIP()
+	}
+	if ok, wait, _ := rateLimiters.publicStatus.Allow(ip, time.Now()); !ok {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, newErrorResponse(singleton.Localizer.ErrorT("too many requests")))
+		return
+	}
+	c.Next()
+}
+
+// Get public status
+// @Summary Get public status
+// @Schemes
+// @Description Snapshot of the servers visible to guests, in the same format as the guest server websocket stream. Responses carry an ETag and may be cached for the stream interval.
+// @Tags common
+// @Param tenant query string false "Public slug of the tenant in strict tenant mode"
+// @Produce json
+// @Success 200 {object} model.StreamServerData
+// @Failure 304
+// @Failure 404
+// @Failure 429
+// @Router /public/status [get]
+func getPublicStatus(c *gin.Context) {
+	setPublicStatusCORS(c)
+
+	scope, scoped := model.GetTenantScope(c)
+	payload, err := cachedPublicStatus(scope, scoped)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, newErrorResponse(err))
+		return
+	}
+
+	etag := fmt.Sprintf(`"%x-%x"`, singleton.DashboardBootTime, payload.generation)
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(serverStreamInterval.Seconds())))
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", payload.data)
+}
+
+// cachedPublicStatus 返回缓存的游客数据，过期后按推送间隔重新获取
+func cachedPublicStatus(scope model.TenantScope, scoped bool) (*publicStatusPayload, error) {
+	key := ""
+	if scoped {
+		key = scope.Key()
+	}
+
+	publicStatusCache.mu.Lock()
+	defer publicStatusCache.mu.Unlock()
+
+	now := time.Now()
+	if p, ok := publicStatusCache.payloads[key]; ok && now.Before(p.expires) {
+		return p, nil
+	}
+
+	data, err := getServerStat(true, false, scope, scoped, false)
+	if err != nil {
+		return nil, err
+	}
+	if publicStatusCache.payloads == nil {
+		publicStatusCache.payloads = make(map[string]*publicStatusPayload)
+	}
+	publicStatusCache.generation++
+	p := &publicStatusPayload{
+		data:       data,
+		generation: publicStatusCache.generation,
+		expires:    now.Add(serverStreamInterval),
+	}
+	publicStatusCache.payloads[key] = p
+	return p, nil
+}
+
+// setPublicStatusCORS 请求来源在允许列表中时返回 CORS 头
+func setPublicStatusCORS(c *gin.Context) {
+	origin := c.GetHeader("Origin")
+	if origin == "" {
+		return
+	}
+	allowed := singleton.Conf.PublicStatus.AllowedOrigins
+	switch {
+	case slices.Contains(allowed, "*"):
+		c.Header("Access-Control-Allow-Origin", "*")
+	case slices.Contains(allowed, origin):
+		c.Header("Access-Control-Allow-Origin", origin)
+		c.Header("Vary", "Origin")
+	default:
+		return
+	}
+	c.Header("Access-Control-Expose-Headers", "ETag")
+}
@@ -0,0 +1,97 @@
+package controller
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+// Purge server history
+// @Summary Purge server history
+// @Security BearerAuth
+// @Schemes
+// @Description Delete monitor results, traffic records, state history and events of a server older than the given time. Rows are deleted in batches in the background, query the returned job for progress.
+// @Tags admin required
+// @Param id path uint true "Server ID"
+// @Param before query string true "Delete data older than this time, a date or RFC3339 timestamp"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.PurgeJob]
+// @Router /server/{id}/history [delete]
+func purgeServerHistory(c *gin.Context) (*model.PurgeJob, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	before, err := parsePurgeBefore(c)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := singleton.ServerShared.Get(id); !ok {
+		return nil, singleton.Localizer.ErrorT("server id %d does not exist", id)
+	}
+	return singleton.PurgeShared.Start(getUid(c), model.PurgeEntityServer, id, before)
+}
+
+// Purge service history
+// @Summary Purge service history
+// @Security BearerAuth
+// @Schemes
+// @Description Delete monitor results and availability statistics of a service older than the given time. Rows are deleted in batches in the background, query the returned job for progress.
+// @Tags admin required
+// @Param id path uint true "Service ID"
+// @Param before query string true "Delete data older than this time, a date or RFC3339 timestamp"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.PurgeJob]
+// @Router /service/{id}/history [delete]
+func purgeServiceHistory(c *gin.Context) (*model.PurgeJob, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	before, err := parsePurgeBefore(c)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := singleton.ServiceSentinelShared.Get(id); !ok {
+		return nil, singleton.Localizer.ErrorT("service id %d does not exist", id)
+	}
+	return singleton.PurgeShared.Start(getUid(c), model.PurgeEntityService, id, before)
+}
+
+// Get job
+// @Summary Get job
+// @Security BearerAuth
+// @Schemes
+// @Description Get the progress and row counts of a purge job, finished jobs are kept for an hour
+// @Tags admin required
+// @Param id path string true "Job ID"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.PurgeJob]
+// @Router /jobs/{id} [get]
+func getJob(c *gin.Context) (*model.PurgeJob, error) {
+	job, ok := singleton.PurgeShared.Get(c.Param("id"))
+	if !ok {
+		return nil, singleton.Localizer.ErrorT("job %s does not exist", c.Param("id"))
+	}
+	return job, nil
+}
+
+// parsePurgeBefore before 必须指定且不晚于当前时间，避免误删全部数据
+func parsePurgeBefore(c *gin.Context) (time.Time, error) {
+	v := c.Query("before")
+	if v == "" {
+		return time.Time{}, singleton.Localizer.ErrorT("before is required")
+	}
+	before, err := parseDateQuery(v)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if before.After(time.Now()) {
+		return time.Time{}, singleton.Localizer.ErrorT("before must not be in the future")
+	}
+	return before, nil
+}
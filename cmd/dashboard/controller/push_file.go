@@ -0,0 +1,185 @@
+package controller
+
+import (
+	"io"
+	"path/filepath"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+// List push files
+// @Summary List push files
+// @Security BearerAuth
+// @Schemes
+// @Description List files that can be pushed to agents, without content
+// @Tags admin required
+// @Produce json
+// @Success 200 {object} model.CommonResponse[[]model.PushFile]
+// @Router /push-file [get]
+func listPushFile(c *gin.Context) ([]*model.PushFile, error) {
+	var files []*model.PushFile
+	if err := singleton.DB.Omit("content").Order("id").Find(&files).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+	return files, nil
+}
+
+// Upload push file
+// @Summary Upload push file
+// @Security BearerAuth
+// @Schemes
+// @Description Upload a small file (config, script) that can be pushed to agents
+// @Tags admin required
+// @Accept multipart/form-data
+// @Param file formData file true "File"
+// @Param name formData string false "Name, defaults to the file name"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.PushFile]
+// @Router /push-file [post]
+func createPushFile(c *gin.Context) (*model.PushFile, error) {
+	fh, err := c.FormFile("file")
+	if err != nil {
+		return nil, err
+	}
+	if fh.Size > model.PushFileMaxSize {
+		return nil, singleton.Localizer.ErrorT("file size exceeds %d bytes", model.PushFileMaxSize)
+	}
+	r, err := fh.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	content, err := io.ReadAll(io.LimitReader(r, model.PushFileMaxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(content) > model.PushFileMaxSize {
+		return nil, singleton.Localizer.ErrorT("file size exceeds %d bytes", model.PushFileMaxSize)
+	}
+
+	f := &model.PushFile{
+		Name:    c.PostForm("name"),
+		Size:    int64(len(content)),
+		SHA256:  singleton.PushFileChecksum(content),
+		Content: content,
+	}
+	if f.Name == "" {
+		f.Name = filepath.Base(fh.Filename)
+	}
+	if err := singleton.DB.Create(f).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+	return f, nil
+}
+
+// Batch delete push files
+// @Summary Batch delete push files
+// @Security BearerAuth
+// @Schemes
+// @Description Batch delete push files, files referenced by tasks can not be deleted
+// @Tags admin required
+// @Accept json
+// @param request body []uint64 true "id list"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /batch-delete/push-file [post]
+func batchDeletePushFile(c *gin.Context) (any, error) {
+	var ids []uint64
+	if err := c.ShouldBindJSON(&ids); err != nil {
+		return nil, err
+	}
+
+	var cr model.Cron
+	if err := singleton.DB.Where("push_file_id in (?)", ids).Limit(1).Find(&cr).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+	if cr.ID != 0 {
+		return nil, singleton.Localizer.ErrorT("file is used by task %s", cr.Name)
+	}
+	if err := singleton.DB.Unscoped().Delete(&model.PushFile{}, "id in (?)", ids).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+	return nil, nil
+}
+
+// Push file to server
+// @Summary Push file to server
+// @Security BearerAuth
+// @Schemes
+// @Description Push a stored file to a server. The agent writes it atomically and reports the checksum back, query the transfer for the result.
+// @Tags admin required
+// @Accept json
+// @Param id path uint true "Server ID"
+// @Param request body model.FilePushForm true "Push Request"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.FilePush]
+// @Router /server/{id}/push-file [post]
+func pushFileToServer(c *gin.Context) (*model.FilePush, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	var pf model.FilePushForm
+	if err := c.ShouldBindJSON(&pf); err != nil {
+		return nil, err
+	}
+	if err := validatePushFileOptions(c, pf.FileID, pf.Path, pf.Mode); err != nil {
+		return nil, err
+	}
+
+	s, ok := singleton.ServerShared.Get(id)
+	if !ok {
+		return nil, singleton.Localizer.ErrorT("server id %d does not exist", id)
+	}
+	return singleton.FilePushShared.Start(getUid(c), s, pf.FileID, pf.Path, pf.Mode, nil)
+}
+
+// Get file push
+// @Summary Get file push
+// @Security BearerAuth
+// @Schemes
+// @Description Get the progress and result of a file push, finished pushes are kept for an hour
+// @Tags admin required
+// @Param id path uint true "Server ID"
+// @Param transfer_id path string true "Transfer ID"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.FilePush]
+// @Router /server/{id}/push-file/{transfer_id} [get]
+func getFilePush(c *gin.Context) (*model.FilePush, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	p, ok := singleton.FilePushShared.Get(c.Param("transfer_id"))
+	if !ok || p.ServerID != id {
+		return nil, singleton.Localizer.ErrorT("transfer %s does not exist", c.Param("transfer_id"))
+	}
+	return p, nil
+}
+
+// validatePushFileOptions 推送文件仅限管理员，目标路径在面板和 Agent 两侧都会校验
+func validatePushFileOptions(c *gin.Context, fileID uint64, path string, mode uint32) error {
+	if user, _ := c.MustGet(model.CtxKeyAuthorizedUser).(*model.User); user.Role != model.RoleAdmin {
+		return singleton.Localizer.ErrorT("permission denied")
+	}
+	if err := model.ValidatePushPath(path); err != nil {
+		return singleton.Localizer.ErrorT("invalid destination path: %v", err)
+	}
+	if err := model.ValidatePushMode(mode); err != nil {
+		return singleton.Localizer.ErrorT("invalid file mode: %v", err)
+	}
+	var count int64
+	if err := singleton.DB.Model(&model.PushFile{}).Where("id = ?", fileID).Count(&count).Error; err != nil {
+		return newGormError("%v", err)
+	}
+	if count == 0 {
+		return singleton.Localizer.ErrorT("file id %d does not exist", fileID)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package controller
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/ratelimit"
+	"github.com/nezhahq/nezha/pkg/utils"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+var rateLimiters struct {
+	public        *ratelimit.Limiter
+	authenticated *ratelimit.Limiter
+	admin         *ratelimit.Limiter
+	probe         *ratelimit.Limiter // 按用户限制网络探测
+	publicStatus  *ratelimit.Limiter // 按 IP 限制公开状态接口
+}
+
+// initRateLimiters 首次调用时创建限流器，之后只修改参数，
+// 限流器不会被替换，处理中的请求无需加锁，已有的令牌桶也不会被重置
+func initRateLimiters() {
+	conf := singleton.Conf.RateLimit
+	setLimit := func(l **ratelimit.Limiter, rule model.RateLimitRule) {
+		if *l == nil {
+			*l = ratelimit.New(rule.Rate, rule.Burst, conf.MaxKeys)
+			return
+		}
+		(*l).SetLimit(rule.Rate, rule.Burst, conf.MaxKeys)
+	}
+	setLimit(&rateLimiters.public, conf.Public)
+	setLimit(&rateLimiters.authenticated, conf.Authenticated)
+	setLimit(&rateLimiters.admin, conf.Admin)
+	setLimit(&rateLimiters.probe, singleton.Conf.Probe.RateLimit)
+	setLimit(&rateLimiters.publicStatus, singleton.Conf.PublicStatus.RateLimit)
+}
+
+// rateLimit 匿名请求按 IP 限流，已登录的请求按用户限流
+func rateLimit(c *gin.Context) {
+	ip := c.GetString(model.CtxKeyRealIPStr)
+	if ip == "" {
+		ip = c.RemoteIP()
+	}
+
+	limiter, key := rateLimiters.public, ip
+	auth, authorized := c.Get(model.CtxKeyAuthorizedUser)
+	if authorized {
+		user := auth.(*model.User)
+		limiter = utils.IfOr(user.Role == model.RoleAdmin, rateLimiters.admin, rateLimiters.authenticated)
+		key = utils.Itoa(user.ID)
+	}
+
+	ok, wait, exceeded := limiter.Allow(key, time.Now())
+	if ok {
+		c.Next()
+		return
+	}
+
+	threshold := singleton.Conf.RateLimit.BlockThreshold
+	if !authorized && threshold > 0 && exceeded%threshold == 0 {
+		model.BlockIP(singleton.DB, ip, model.WAFBlockReasonTypeRateLimit, model.BlockIDRateLimit)
+	}
+
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, newErrorResponse(singleton.Localizer.ErrorT("too many requests")))
+}
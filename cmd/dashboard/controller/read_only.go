@@ -0,0 +1,101 @@
+package controller
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/goccy/go-json"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+// readOnlyExemptRoutes 只读模式下仍允许的修改类接口，保证管理员可以登录并关闭只读模式
+var readOnlyExemptRoutes = map[string]bool{
+	"/api/v1/login":             true,
+	"/api/v1/logout":            true,
+	"/api/v1/oauth2/totp":       true,
+	"/api/v1/setting/read-only": true,
+	"/api/v1/admin/restore":     true,
+}
+
+// readOnlyGuard 只读模式下拒绝修改操作
+func readOnlyGuard(c *gin.Context) {
+	if !singleton.Conf.ReadOnly || readOnlyExemptRoutes[c.FullPath()] {
+		c.Next()
+		return
+	}
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		c.Next()
+		return
+	}
+	err := singleton.Localizer.ErrorT("the dashboard is in read-only mode")
+	if banner := singleton.Conf.MaintenanceBanner; banner != "" {
+		err = singleton.Localizer.ErrorT("the dashboard is in read-only mode: %s", banner)
+	}
+	c.AbortWithStatusJSON(http.StatusServiceUnavailable, newErrorResponse(err))
+}
+
+// Get public settings
+// @Summary Get public settings
+// @Schemes
+// @Description Get settings the frontend needs before login, such as the maintenance banner and branding
+// @Tags common
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.PublicSettingResponse]
+// @Router /settings/public [get]
+func getPublicSetting(c *gin.Context) (*model.PublicSettingResponse, error) {
+	resp, etag, err := singleton.PublicSettings()
+	if err != nil {
+		return nil, newGormError("%v", err)
+	}
+
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "no-cache")
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return nil, errNoop
+	}
+	return resp, nil
+}
+
+// Set read-only mode
+// @Summary Set read-only mode
+// @Security BearerAuth
+// @Schemes
+// @Description Freeze or unfreeze writes through the API, takes effect immediately
+// @Tags admin required
+// @Accept json
+// @Param body body model.ReadOnlyForm true "ReadOnlyForm"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /setting/read-only [patch]
+func updateReadOnly(c *gin.Context) (any, error) {
+	var rf model.ReadOnlyForm
+	if err := c.ShouldBindJSON(&rf); err != nil {
+		return nil, err
+	}
+
+	// 保存在设置表中，集群中的其他节点收到通知后同时切换
+	values := make(map[string]json.RawMessage, 3)
+	for key, v := range map[string]any{
+		"read_only":               rf.Enabled,
+		"read_only_pause_reports": rf.Enabled && rf.PauseReports,
+		"maintenance_banner":      rf.Banner,
+	} {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		values[key] = data
+	}
+	if _, err := singleton.UpdateSettings(getUid(c), values); err != nil {
+		return nil, newGormError("%v", err)
+	}
+
+	user := c.MustGet(model.CtxKeyAuthorizedUser).(*model.User)
+	log.Printf("NEZHA>> Read-only mode set to %t (pause reports: %t) by %s", singleton.Conf.ReadOnly, singleton.Conf.ReadOnlyPauseReports, user.Username)
+	return nil, nil
+}
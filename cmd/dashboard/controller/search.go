@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+// Search resources
+// @Summary Search resources
+// @Security BearerAuth
+// @Schemes
+// @Description Search servers (name, note, UUID, IP), services (name, target), crons (name, command) and alert rules (name) the user can access. Exact and prefix matches come first.
+// @Tags auth required
+// @Param q query string true "Query"
+// @Param limit query uint false "Results per resource type, default 5, at most 50"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.SearchResponse]
+// @Router /search [get]
+func search(c *gin.Context) (*model.SearchResponse, error) {
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		return nil, singleton.Localizer.ErrorT("query is required")
+	}
+	if len(q) > model.SearchMaxQueryLen {
+		return nil, singleton.Localizer.ErrorT("query is too long")
+	}
+	limit := model.SearchDefaultLimit
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return nil, singleton.Localizer.ErrorT("invalid limit")
+		}
+		limit = min(n, model.SearchMaxLimit)
+	}
+
+	user := c.MustGet(model.CtxKeyAuthorizedUser).(*model.User)
+	showNote := user.Role != model.RoleViewer || singleton.Conf.ViewerShowNote
+
+	s := model.NewSearcher(q, limit)
+	for _, server := range singleton.ServerShared.GetSortedList() {
+		if !server.HasPermission(c) {
+			continue
+		}
+		fields := []model.SearchField{{Name: "uuid", Value: server.UUID}, {Name: "public_note", Value: server.PublicNote}}
+		if showNote {
+			fields = append(fields, model.SearchField{Name: "note", Value: server.Note})
+		}
+		if server.GeoIP != nil {
+			fields = append(fields,
+				model.SearchField{Name: "ipv4", Value: server.GeoIP.IP.IPv4Addr},
+				model.SearchField{Name: "ipv6", Value: server.GeoIP.IP.IPv6Addr})
+		}
+		s.Add(model.SearchTypeServer, server.ID, server.Name, fields...)
+	}
+	for _, service := range singleton.ServiceSentinelShared.GetSortedList() {
+		if service.HasPermission(c) {
+			s.Add(model.SearchTypeService, service.ID, service.Name, model.SearchField{Name: "target", Value: service.Target})
+		}
+	}
+	for _, cr := range singleton.CronShared.GetSortedList() {
+		if cr.HasPermission(c) {
+			s.Add(model.SearchTypeCron, cr.ID, cr.Name, model.SearchField{Name: "command", Value: cr.Command})
+		}
+	}
+
+	singleton.AlertsLock.RLock()
+	for _, alert := range singleton.Alerts {
+		if alert.HasPermission(c) {
+			s.Add(model.SearchTypeAlertRule, alert.ID, alert.Name)
+		}
+	}
+	singleton.AlertsLock.RUnlock()
+
+	return s.Response(), nil
+}
@@ -1,8 +1,11 @@
 package controller
 
 import (
+	"fmt"
+	"log"
 	"slices"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -28,11 +31,25 @@ import (
 // @Router /server [get]
 func listServer(c *gin.Context) ([]*model.Server, error) {
 	slist := singleton.ServerShared.GetSortedList()
+	if scope, ok := model.GetTenantScope(c); ok {
+		slist = singleton.ServerShared.GetSortedListByTenant(scope)
+	}
 
 	var ssl []*model.Server
 	if err := copier.Copy(&ssl, &slist); err != nil {
 		return nil, err
 	}
+
+	for _, s := range ssl {
+		s.Connection = singleton.ServerShared.Connection(s.ID)
+	}
+
+	user := c.MustGet(model.CtxKeyAuthorizedUser).(*model.User)
+	if user.Role == model.RoleViewer && !singleton.Conf.ViewerShowNote {
+		for _, s := range ssl {
+			s.Note = ""
+		}
+	}
 	return ssl, nil
 }
 
@@ -72,12 +89,14 @@ func updateServer(c *gin.Context) (any, error) {
 		return nil, singleton.Localizer.ErrorT("permission denied")
 	}
 
+	old := s
 	s.Name = sf.Name
 	s.DisplayIndex = sf.DisplayIndex
 	s.Note = sf.Note
 	s.PublicNote = sf.PublicNote
 	s.HideForGuest = sf.HideForGuest
 	s.EnableDDNS = sf.EnableDDNS
+	s.EnableMeshPing = sf.EnableMeshPing
 	s.DDNSProfiles = sf.DDNSProfiles
 	s.OverrideDDNSDomains = sf.OverrideDDNSDomains
 
@@ -93,14 +112,147 @@ func updateServer(c *gin.Context) (any, error) {
 	}
 	s.OverrideDDNSDomainsRaw = string(overrideDomainsRaw)
 
+	s.Tags = normalizeTags(sf.Tags)
+	tagsRaw, err := json.Marshal(s.Tags)
+	if err != nil {
+		return nil, err
+	}
+	s.TagsRaw = string(tagsRaw)
+
 	if err := singleton.DB.Save(&s).Error; err != nil {
 		return nil, newGormError("%v", err)
 	}
+	singleton.RecordServerUpdate(&old, &s, getUid(c))
+
+	rs, _ := singleton.ServerShared.Get(s.ID)
+	s.CopyFromRunningServer(rs)
+	singleton.ServerShared.Update(&s, "")
+
+	return nil, nil
+}
+
+// Set server wake schedule
+// @Summary Set server wake schedule
+// @Security BearerAuth
+// @Schemes
+// @Description Set the weekly windows in which the server is expected to be online. Outside the windows an offline server is shown as scheduled off and offline alerts are suppressed
+// @Tags auth required
+// @Accept json
+// @Param id path uint true "Server ID"
+// @Param request body model.WakeSchedule true "Wake schedule"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /server/{id}/wake-schedule [put]
+func setServerWakeSchedule(c *gin.Context) (any, error) {
+	var ws model.WakeSchedule
+	if err := c.ShouldBindJSON(&ws); err != nil {
+		return nil, err
+	}
+	if err := ws.Validate(singleton.Loc); err != nil {
+		return nil, singleton.Localizer.ErrorT("invalid wake schedule: %v", err)
+	}
+	return nil, saveServerWakeSchedule(c, &ws)
+}
+
+// Delete server wake schedule
+// @Summary Delete server wake schedule
+// @Security BearerAuth
+// @Schemes
+// @Description Remove the wake schedule, the server is then always expected to be online
+// @Tags auth required
+// @Param id path uint true "Server ID"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /server/{id}/wake-schedule [delete]
+func deleteServerWakeSchedule(c *gin.Context) (any, error) {
+	return nil, saveServerWakeSchedule(c, nil)
+}
+
+func saveServerWakeSchedule(c *gin.Context, ws *model.WakeSchedule) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return err
+	}
+	var s model.Server
+	if err := singleton.DB.First(&s, id).Error; err != nil {
+		return singleton.Localizer.ErrorT("server id %d does not exist", id)
+	}
+	if !s.HasPermission(c) {
+		return singleton.Localizer.ErrorT("permission denied")
+	}
 
+	var raw string
+	if ws != nil {
+		data, err := json.Marshal(ws)
+		if err != nil {
+			return err
+		}
+		raw = string(data)
+	}
+	if err := singleton.DB.Model(&s).Update("wake_schedule_raw", raw).Error; err != nil {
+		return newGormError("%v", err)
+	}
+
+	s.WakeScheduleRaw, s.WakeSchedule = raw, ws
 	rs, _ := singleton.ServerShared.Get(s.ID)
 	s.CopyFromRunningServer(rs)
 	singleton.ServerShared.Update(&s, "")
+	return nil
+}
 
+// Set server heartbeat mode
+// @Summary Set server heartbeat mode
+// @Security BearerAuth
+// @Schemes
+// @Description Switch the agent between full reports and heartbeat mode, in which it only reports uptime and load at a longer interval. The mode is pushed to the agent now if it is connected, otherwise when it reconnects
+// @Tags auth required
+// @Accept json
+// @Param id path uint true "Server ID"
+// @Param request body model.AgentReportConfig true "Report mode"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /server/{id}/heartbeat [put]
+func setServerHeartbeat(c *gin.Context) (any, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	var rc model.AgentReportConfig
+	if err := c.ShouldBindJSON(&rc); err != nil {
+		return nil, err
+	}
+	if err := rc.Validate(); err != nil {
+		return nil, singleton.Localizer.ErrorT("heartbeat interval must be between %d and %d seconds", model.HeartbeatIntervalMin, model.HeartbeatIntervalMax)
+	}
+
+	var s model.Server
+	if err := singleton.DB.First(&s, id).Error; err != nil {
+		return nil, singleton.Localizer.ErrorT("server id %d does not exist", id)
+	}
+	if !s.HasPermission(c) {
+		return nil, singleton.Localizer.ErrorT("permission denied")
+	}
+	rs, _ := singleton.ServerShared.Get(s.ID)
+	// 只有明确上报了心跳能力的 Agent 才能开启，旧版本 Agent 不会按心跳模式上报
+	if rc.HeartbeatMode && (rs == nil || !rs.Reports(model.AgentCapabilityHeartbeat)) {
+		return nil, singleton.Localizer.ErrorT("the agent of server %s does not allow %s", s.Name, model.AgentCapabilityHeartbeat)
+	}
+
+	if err := singleton.DB.Model(&s).Updates(map[string]any{
+		"heartbeat_mode":     rc.HeartbeatMode,
+		"heartbeat_interval": rc.HeartbeatInterval,
+	}).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+
+	s.HeartbeatMode, s.HeartbeatInterval = rc.HeartbeatMode, rc.HeartbeatInterval
+	s.CopyFromRunningServer(rs)
+	singleton.ServerShared.Update(&s, "")
+
+	// 下发失败时 Agent 重新连接后会再次下发
+	if err := singleton.PushReportConfig(&s); err != nil {
+		log.Printf("NEZHA>> Failed to push report config to server %d: %v", s.ID, err)
+	}
 	return nil, nil
 }
 
@@ -132,7 +284,7 @@ func batchDeleteServer(c *gin.Context) (any, error) {
 		if err := tx.Unscoped().Delete(&model.ServerGroupServer{}, "server_id in (?)", servers).Error; err != nil {
 			return err
 		}
-		return nil
+		return tx.Unscoped().Delete(&model.AccessGrant{}, "server_id in (?)", servers).Error
 	})
 
 	if err != nil {
@@ -152,7 +304,9 @@ func batchDeleteServer(c *gin.Context) (any, error) {
 	singleton.DB.Unscoped().Delete(&model.Transfer{}, "server_id in (?)", servers)
 	singleton.AlertsLock.Unlock()
 
+	singleton.RecordServerDeletion(servers, model.ServerEventActorUser, getUid(c))
 	singleton.ServerShared.Delete(servers)
+	singleton.AccessGrantShared.Reload()
 	return nil, nil
 }
 
@@ -244,6 +398,363 @@ func getServerConfig(c *gin.Context) (string, error) {
 	return "", singleton.Localizer.ErrorT("get server config failed")
 }
 
+// Get server containers
+// @Summary Get server containers
+// @Security BearerAuth
+// @Schemes
+// @Description Get the latest container list reported by the agent, empty if the agent has not detected a container runtime
+// @Tags auth required
+// @Param id path uint true "Server ID"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.ContainerReport]
+// @Router /server/{id}/containers [get]
+func getServerContainers(c *gin.Context) (*model.ContainerReport, error) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	s, ok := singleton.ServerShared.Get(id)
+	if !ok {
+		return nil, singleton.Localizer.ErrorT("server id %d does not exist", id)
+	}
+	if !s.HasPermission(c) {
+		return nil, singleton.Localizer.ErrorT("permission denied")
+	}
+	if err := singleton.CheckCapability(s, model.AgentCapabilityContainers); err != nil {
+		return nil, err
+	}
+
+	if s.Containers == nil {
+		return &model.ContainerReport{Containers: []model.Container{}}, nil
+	}
+	return s.Containers, nil
+}
+
+// Get server process snapshot
+// @Summary Get server process snapshot
+// @Security BearerAuth
+// @Schemes
+// @Description Ask the agent for the top processes by CPU and memory, the result is not persisted
+// @Tags auth required
+// @Param id path uint true "Server ID"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.ProcessSnapshot]
+// @Router /server/{id}/processes [post]
+func getServerProcesses(c *gin.Context) (*model.ProcessSnapshot, error) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	s, ok := singleton.ServerShared.Get(id)
+	if !ok || s.TaskStream == nil {
+		return nil, singleton.Localizer.ErrorT("server not found or not connected")
+	}
+	if !s.HasPermission(c) {
+		return nil, singleton.Localizer.ErrorT("permission denied")
+	}
+
+	// 同一服务器的并发请求共用一次快照
+	v, err, _ := requestGroup.Do(fmt.Sprintf("processSnapshot::%d", id), func() (any, error) {
+		return singleton.RunProcessSnapshot(s)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*model.ProcessSnapshot), nil
+}
+
+// Get agent logs
+// @Summary Get agent logs
+// @Security BearerAuth
+// @Schemes
+// @Description Ask the agent for its recent log lines, limited by lines and size, whichever is smaller. The agent redacts its secret before sending and the result is not persisted
+// @Tags admin required
+// @Param id path uint true "Server ID"
+// @Param lines query int false "Number of lines, default 200, max 5000"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.AgentLogs]
+// @Router /server/{id}/agent-logs [get]
+func getServerAgentLogs(c *gin.Context) (*model.AgentLogs, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := model.AgentLogsDefaultLines
+	if v := c.Query("lines"); v != "" {
+		if lines, err = strconv.Atoi(v); err != nil || lines < 1 {
+			return nil, singleton.Localizer.ErrorT("invalid lines: %s", v)
+		}
+		lines = min(lines, model.AgentLogsMaxLines)
+	}
+
+	s, ok := singleton.ServerShared.Get(id)
+	if !ok || s.TaskStream == nil {
+		return nil, singleton.Localizer.ErrorT("server not found or not connected")
+	}
+	// 旧版本 Agent 不会响应该任务，需明确上报能力
+	if s.Capabilities == nil || !s.Capabilities.Has(model.AgentCapabilityLogs) {
+		return nil, &model.AgentUnsupportedError{Capability: model.AgentCapabilityLogs}
+	}
+
+	// 同一服务器相同行数的并发请求共用一次结果
+	v, err, _ := requestGroup.Do(fmt.Sprintf("agentLogs::%d::%d", id, lines), func() (any, error) {
+		return singleton.RunAgentLogs(s, lines)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*model.AgentLogs), nil
+}
+
+// Probe from server
+// @Summary Probe from server
+// @Security BearerAuth
+// @Schemes
+// @Description Ask the agent to ping (icmp, tcp) or traceroute a target and wait for the result. The number of probes, hops and the duration are capped, targets in the configured deny list are rejected, and requests are rate limited per user.
+// @Tags auth required
+// @Accept json
+// @Param id path uint true "Server ID"
+// @Param request body model.ProbeForm true "Probe"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.ProbeResult]
+// @Router /server/{id}/probe [post]
+func probeFromServer(c *gin.Context) (*model.ProbeResult, error) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	var pf model.ProbeForm
+	if err := c.ShouldBindJSON(&pf); err != nil {
+		return nil, err
+	}
+	task, err := pf.Task()
+	if err != nil {
+		return nil, singleton.Localizer.ErrorT("invalid probe: %v", err)
+	}
+
+	s, ok := singleton.ServerShared.Get(id)
+	if !ok || s.TaskStream == nil {
+		return nil, singleton.Localizer.ErrorT("server not found or not connected")
+	}
+	if err := checkServerAccess(c, s, model.AccessProbe); err != nil {
+		return nil, err
+	}
+
+	if ok, _, _ := rateLimiters.probe.Allow(strconv.FormatUint(getUid(c), 10), time.Now()); !ok {
+		return nil, singleton.Localizer.ErrorT("too many requests")
+	}
+	return singleton.RunProbe(s, task)
+}
+
+// Get server traffic history
+// @Summary Get server traffic history
+// @Security BearerAuth
+// @Schemes
+// @Description Get daily traffic rollups of a server, with per-interface breakdown if reported by agent
+// @Tags auth required
+// @Param id path uint true "Server ID"
+// @Param from query string false "Start date (2006-01-02 or RFC3339), defaults to 30 days ago"
+// @Param to query string false "End date (2006-01-02 or RFC3339), defaults to now"
+// @Param granularity query string false "daily or monthly" Enums(daily, monthly) default(daily)
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.ServerTrafficResponse]
+// @Router /server/{id}/traffic [get]
+func getServerTraffic(c *gin.Context) (*model.ServerTrafficResponse, error) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	s, ok := singleton.ServerShared.Get(id)
+	if !ok {
+		return nil, singleton.Localizer.ErrorT("server id %d does not exist", id)
+	}
+	if !s.HasPermission(c) {
+		return nil, singleton.Localizer.ErrorT("permission denied")
+	}
+
+	granularity := c.DefaultQuery("granularity", model.TrafficGranularityDaily)
+	if granularity != model.TrafficGranularityDaily && granularity != model.TrafficGranularityMonthly {
+		return nil, singleton.Localizer.ErrorT("invalid granularity")
+	}
+
+	to := time.Now()
+	if toStr := c.Query("to"); toStr != "" {
+		if to, err = parseDateQuery(toStr); err != nil {
+			return nil, err
+		}
+	}
+	from := to.AddDate(0, 0, -30)
+	if fromStr := c.Query("from"); fromStr != "" {
+		if from, err = parseDateQuery(fromStr); err != nil {
+			return nil, err
+		}
+	}
+	if from.After(to) {
+		return nil, singleton.Localizer.ErrorT("invalid time range")
+	}
+
+	return singleton.GetServerTraffic(id, from, to, granularity)
+}
+
+const (
+	stateHistoryDefaultWindow = time.Hour
+	stateHistoryDefaultPoints = 360
+	stateHistoryMaxPoints     = 2000
+)
+
+// Get server state history
+// @Summary Get server state history
+// @Schemes
+// @Description Get recent state samples of a server kept in memory, downsampled for charting
+// @Tags common
+// @Param id path uint true "Server ID"
+// @Param minutes query uint false "Time window in minutes, limited by state_history_minutes" default(60)
+// @Param points query uint false "Maximum number of points" default(360)
+// @Produce json
+// @Success 200 {object} model.CommonResponse[[]model.StateSample]
+// @Router /server/{id}/state-history [get]
+func getServerStateHistory(c *gin.Context) ([]model.StateSample, error) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	server, ok := singleton.ServerShared.Get(id)
+	if !ok || !serverVisible(c, server) {
+		return nil, singleton.Localizer.ErrorT("server id %d does not exist", id)
+	}
+
+	minutes, err := strconv.Atoi(c.DefaultQuery("minutes", "60"))
+	if err != nil || minutes <= 0 || minutes > singleton.Conf.StateHistoryMinutes {
+		return nil, singleton.Localizer.ErrorT("minutes must be between 1 and %d", singleton.Conf.StateHistoryMinutes)
+	}
+	points, err := strconv.Atoi(c.DefaultQuery("points", strconv.Itoa(stateHistoryDefaultPoints)))
+	if err != nil || points <= 0 || points > stateHistoryMaxPoints {
+		return nil, singleton.Localizer.ErrorT("points must be between 1 and %d", stateHistoryMaxPoints)
+	}
+
+	samples := singleton.ServerShared.StateHistory(id, time.Duration(minutes)*time.Minute)
+	return model.DownsampleStateSamples(samples, points), nil
+}
+
+// Get server custom metric history
+// @Summary Get server custom metric history
+// @Security BearerAuth
+// @Schemes
+// @Description Get recent samples of agent-reported custom metrics kept in memory, downsampled for charting
+// @Tags auth required
+// @Param id path uint true "Server ID"
+// @Param name query string false "Metric name, all metrics if empty"
+// @Param minutes query uint false "Time window in minutes, limited by state_history_minutes" default(60)
+// @Param points query uint false "Maximum number of points per metric" default(360)
+// @Produce json
+// @Success 200 {object} model.CommonResponse[map[string][]model.CustomMetricSample]
+// @Router /server/{id}/custom-metric-history [get]
+func getServerCustomMetricHistory(c *gin.Context) (map[string][]model.CustomMetricSample, error) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	server, ok := singleton.ServerShared.Get(id)
+	if !ok || !serverVisible(c, server) {
+		return nil, singleton.Localizer.ErrorT("server id %d does not exist", id)
+	}
+
+	minutes, err := strconv.Atoi(c.DefaultQuery("minutes", "60"))
+	if err != nil || minutes <= 0 || minutes > singleton.Conf.StateHistoryMinutes {
+		return nil, singleton.Localizer.ErrorT("minutes must be between 1 and %d", singleton.Conf.StateHistoryMinutes)
+	}
+	points, err := strconv.Atoi(c.DefaultQuery("points", strconv.Itoa(stateHistoryDefaultPoints)))
+	if err != nil || points <= 0 || points > stateHistoryMaxPoints {
+		return nil, singleton.Localizer.ErrorT("points must be between 1 and %d", stateHistoryMaxPoints)
+	}
+
+	var names []string
+	if name := c.Query("name"); name != "" {
+		names = append(names, name)
+	}
+	history := singleton.ServerShared.CustomMetricHistory(id, time.Duration(minutes)*time.Minute, names...)
+	return downsampleCustomMetrics(history, points), nil
+}
+
+func downsampleCustomMetrics(history map[string][]model.CustomMetricSample, points int) map[string][]model.CustomMetricSample {
+	for name, samples := range history {
+		history[name] = model.DownsampleCustomMetricSamples(samples, points)
+	}
+	return history
+}
+
+const (
+	serverEventStreamLimit  = 20
+	serverEventDefaultLimit = 100
+	serverEventMaxLimit     = 1000
+)
+
+// List server lifecycle events
+// @Summary List server lifecycle events
+// @Security BearerAuth
+// @Schemes
+// @Description List lifecycle events of a server such as registration, renames, group changes and agent version changes, newest first
+// @Tags auth required
+// @Param id path uint true "Server ID"
+// @Param limit query uint false "Maximum number of events" default(100)
+// @Produce json
+// @Success 200 {object} model.CommonResponse[[]model.ServerEvent]
+// @Router /server/{id}/events [get]
+func listServerEvent(c *gin.Context) ([]*model.ServerEvent, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	server, ok := singleton.ServerShared.Get(id)
+	if !ok || server == nil {
+		return nil, singleton.Localizer.ErrorT("server id %d does not exist", id)
+	}
+	if !server.HasPermission(c) {
+		return nil, singleton.Localizer.ErrorT("permission denied")
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(serverEventDefaultLimit)))
+	if err != nil || limit <= 0 || limit > serverEventMaxLimit {
+		return nil, singleton.Localizer.ErrorT("limit must be between 1 and %d", serverEventMaxLimit)
+	}
+
+	events, err := singleton.ListServerEvents(id, limit)
+	if err != nil {
+		return nil, newGormError("%v", err)
+	}
+	return events, nil
+}
+
+func normalizeTags(tags []string) []string {
+	ret := make([]string, 0, len(tags))
+	for _, t := range tags {
+		if t = strings.TrimSpace(t); t != "" && !slices.Contains(ret, t) {
+			ret = append(ret, t)
+		}
+	}
+	return ret
+}
+
+func parseDateQuery(v string) (time.Time, error) {
+	if t, err := time.ParseInLocation(time.DateOnly, v, singleton.Loc); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
+
 // Set server config
 // @Summary Set server config
 // @Security BearerAuth
@@ -364,6 +875,98 @@ func batchMoveServer(c *gin.Context) (any, error) {
 		s.UserID = moveForm.ToUser
 		return true
 	})
+	singleton.ClusterShared.PublishChange("server")
 
 	return nil, nil
 }
+
+// Execute command on server
+// @Summary Execute command on server
+// @Security BearerAuth
+// @Schemes
+// @Description Execute a one-off command on a server without saving a schedule task. Returns the execution immediately, query it later for the output. Non-admin users need an exec access grant for the server.
+// @Tags auth required
+// @Accept json
+// @Param id path uint true "Server ID"
+// @Param request body model.ServerExecForm true "Command"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.CronExecution]
+// @Router /server/{id}/exec [post]
+func execServerCommand(c *gin.Context) (*model.CronExecution, error) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	var ef model.ServerExecForm
+	if err := c.ShouldBindJSON(&ef); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(ef.Command) == "" {
+		return nil, singleton.Localizer.ErrorT("command is required")
+	}
+	if ef.TimeoutSeconds > 86400 {
+		return nil, singleton.Localizer.ErrorT("timeout must not exceed 86400 seconds")
+	}
+	if err := checkCommandPolicy(c, ef.Command); err != nil {
+		return nil, err
+	}
+
+	server, ok := singleton.ServerShared.Get(id)
+	if !ok || server.TaskStream == nil {
+		return nil, singleton.Localizer.ErrorT("server not found or not connected")
+	}
+	if err := checkServerAccess(c, server, model.AccessExec); err != nil {
+		return nil, err
+	}
+	if err := singleton.CheckCapability(server, model.AgentCapabilityExec); err != nil {
+		return nil, err
+	}
+	if ef.TimeoutSeconds > 0 {
+		if err := singleton.CheckTaskOptions(server); err != nil {
+			return nil, err
+		}
+	}
+
+	e := singleton.ExecCommand(server, ef.Command, ef.TimeoutSeconds)
+	// 请求内容中的命令会被隐藏，审计日志中记录实际执行的命令
+	c.Set(model.CtxKeyAuditSummary, fmt.Sprintf("execution: %d, timeout: %d, command: %s", e.ID, ef.TimeoutSeconds, ef.Command))
+	return e, nil
+}
+
+// Get command execution
+// @Summary Get command execution
+// @Security BearerAuth
+// @Schemes
+// @Description Get the status and output of a one-off command executed on a server
+// @Tags auth required
+// @Param id path uint true "Server ID"
+// @Param execution_id path uint true "Execution ID"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.CronExecution]
+// @Router /server/{id}/exec/{execution_id} [get]
+func getServerExecution(c *gin.Context) (*model.CronExecution, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	executionID, err := strconv.ParseUint(c.Param("execution_id"), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	server, ok := singleton.ServerShared.Get(id)
+	if !ok {
+		return nil, singleton.Localizer.ErrorT("server id %d does not exist", id)
+	}
+	if err := checkServerAccess(c, server, model.AccessExec); err != nil {
+		return nil, err
+	}
+
+	e, err := singleton.GetCronExecution(executionID)
+	if err != nil || e.ServerID != id || e.CronID != 0 {
+		return nil, singleton.Localizer.ErrorT("execution id %d does not exist", executionID)
+	}
+	return e, nil
+}
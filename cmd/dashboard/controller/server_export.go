@@ -0,0 +1,189 @@
+package controller
+
+import (
+	"encoding/csv"
+	"fmt"
+	"iter"
+	"log"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/xlsx"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+type serverExportField struct {
+	name  string
+	value func(s *model.Server, groups map[uint64]string) any
+}
+
+// Agent 上报的 CPU 信息形如 "AMD EPYC 7B13 2 Virtual Core"
+var cpuCoresRegexp = regexp.MustCompile(`(\d+) (?:Physical|Virtual) Core`)
+
+var serverExportFields = []serverExportField{
+	{"id", func(s *model.Server, _ map[uint64]string) any { return s.ID }},
+	{"name", func(s *model.Server, _ map[uint64]string) any { return s.Name }},
+	{"group", func(s *model.Server, groups map[uint64]string) any { return groups[s.ID] }},
+	{"tags", func(s *model.Server, _ map[uint64]string) any { return strings.Join(s.Tags, ",") }},
+	{"uuid", func(s *model.Server, _ map[uint64]string) any { return s.UUID }},
+	{"country", func(s *model.Server, _ map[uint64]string) any { return s.GeoIP.CountryCode }},
+	{"asn", func(s *model.Server, _ map[uint64]string) any { return s.GeoIP.ASN }},
+	{"ipv4", func(s *model.Server, _ map[uint64]string) any { return s.GeoIP.IP.IPv4Addr }},
+	{"ipv6", func(s *model.Server, _ map[uint64]string) any { return s.GeoIP.IP.IPv6Addr }},
+	{"platform", func(s *model.Server, _ map[uint64]string) any {
+		return strings.TrimSpace(s.Host.Platform + " " + s.Host.PlatformVersion)
+	}},
+	{"arch", func(s *model.Server, _ map[uint64]string) any { return s.Host.Arch }},
+	{"virtualization", func(s *model.Server, _ map[uint64]string) any { return s.Host.Virtualization }},
+	{"cpu", func(s *model.Server, _ map[uint64]string) any { return strings.Join(s.Host.CPU, "; ") }},
+	{"cpu_cores", func(s *model.Server, _ map[uint64]string) any { return cpuCores(s.Host.CPU) }},
+	{"gpu", func(s *model.Server, _ map[uint64]string) any { return strings.Join(s.Host.GPU, "; ") }},
+	{"mem_total", func(s *model.Server, _ map[uint64]string) any { return s.Host.MemTotal }},
+	{"swap_total", func(s *model.Server, _ map[uint64]string) any { return s.Host.SwapTotal }},
+	{"disk_total", func(s *model.Server, _ map[uint64]string) any { return s.Host.DiskTotal }},
+	{"agent_version", func(s *model.Server, _ map[uint64]string) any { return s.Host.Version }},
+	{"last_active", func(s *model.Server, _ map[uint64]string) any {
+		if s.LastActive.IsZero() {
+			return ""
+		}
+		return s.LastActive.In(singleton.Loc).Format(time.RFC3339)
+	}},
+	{"public_note", func(s *model.Server, _ map[uint64]string) any { return s.PublicNote }},
+}
+
+func cpuCores(cpus []string) int {
+	var cores int
+	for _, cpu := range cpus {
+		if m := cpuCoresRegexp.FindStringSubmatch(cpu); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			cores += n
+		}
+	}
+	return cores
+}
+
+func parseServerExportFields(s string) ([]serverExportField, error) {
+	if s == "" {
+		return serverExportFields, nil
+	}
+
+	var fields []serverExportField
+	for name := range strings.SplitSeq(s, ",") {
+		name = strings.TrimSpace(name)
+		i := slices.IndexFunc(serverExportFields, func(f serverExportField) bool {
+			return f.name == name
+		})
+		if i < 0 {
+			return nil, singleton.Localizer.ErrorT("invalid field: %s", name)
+		}
+		fields = append(fields, serverExportFields[i])
+	}
+	return fields, nil
+}
+
+// Export server inventory
+// @Summary Export server inventory
+// @Security BearerAuth
+// @Schemes
+// @Description Export servers the current user can access as a CSV or Excel file
+// @Tags auth required
+// @Param format query string false "csv (default) or xlsx"
+// @Param fields query string false "Comma separated columns, all columns are exported by default"
+// @Produce octet-stream
+// @Success 200 {file} file
+// @Router /server/export [get]
+func exportServer(c *gin.Context) (any, error) {
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "xlsx" {
+		return nil, singleton.Localizer.ErrorT("invalid format: %s", format)
+	}
+	fields, err := parseServerExportFields(c.Query("fields"))
+	if err != nil {
+		return nil, err
+	}
+
+	servers := slices.DeleteFunc(singleton.ServerShared.GetSortedList(), func(s *model.Server) bool {
+		return !s.HasPermission(c)
+	})
+	groups := singleton.ServerGroupNames()
+
+	header := make([]string, len(fields))
+	for i, f := range fields {
+		header[i] = f.name
+	}
+	var rows iter.Seq[[]any] = func(yield func([]any) bool) {
+		for _, s := range servers {
+			row := make([]any, len(fields))
+			for i, f := range fields {
+				row[i] = f.value(s, groups)
+			}
+			if !yield(row) {
+				return
+			}
+		}
+	}
+
+	filename := fmt.Sprintf("servers-%s.%s", time.Now().In(singleton.Loc).Format("20060102"), format)
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	if format == "xlsx" {
+		c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		err = writeServerXLSX(c, header, rows)
+	} else {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		err = writeServerCSV(c, header, rows)
+	}
+	if err != nil {
+		log.Printf("NEZHA>> Failed to export servers: %v", err)
+	}
+	return nil, errNoop
+}
+
+func writeServerCSV(c *gin.Context, header []string, rows iter.Seq[[]any]) error {
+	// 写入 BOM 以便 Excel 识别 UTF-8
+	if _, err := c.Writer.WriteString("\ufeff"); err != nil {
+		return err
+	}
+
+	w := csv.NewWriter(c.Writer)
+	w.UseCRLF = true
+	if err := w.Write(header); err != nil {
+		return err
+	}
+	record := make([]string, len(header))
+	for row := range rows {
+		for i, v := range row {
+			record[i] = fmt.Sprint(v)
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func writeServerXLSX(c *gin.Context, header []string, rows iter.Seq[[]any]) error {
+	w, err := xlsx.NewWriter(c.Writer, "servers")
+	if err != nil {
+		return err
+	}
+	cells := make([]any, len(header))
+	for i, h := range header {
+		cells[i] = h
+	}
+	if err := w.WriteRow(cells...); err != nil {
+		return err
+	}
+	for row := range rows {
+		if err := w.WriteRow(row...); err != nil {
+			return err
+		}
+	}
+	return w.Close()
+}
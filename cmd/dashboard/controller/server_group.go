@@ -22,7 +22,7 @@ import (
 // @Router /server-group [get]
 func listServerGroup(c *gin.Context) ([]*model.ServerGroupResponseItem, error) {
 	var sg []model.ServerGroup
-	if err := singleton.DB.Find(&sg).Error; err != nil {
+	if err := tenantQuery(c, singleton.DB).Find(&sg).Error; err != nil {
 		return nil, err
 	}
 
@@ -105,6 +105,9 @@ func createServerGroup(c *gin.Context) (uint64, error) {
 	if err != nil {
 		return 0, newGormError("%v", err)
 	}
+	singleton.ServerShared.RefreshGroups()
+	singleton.ClusterShared.PublishChange("server-group")
+	singleton.RecordGroupMembership(sg.ID, nil, sgf.Servers, uid)
 
 	return sg.ID, nil
 }
@@ -158,6 +161,11 @@ func updateServerGroup(c *gin.Context) (any, error) {
 		return nil, singleton.Localizer.ErrorT("have invalid server id")
 	}
 
+	var members []uint64
+	if err := singleton.DB.Model(&model.ServerGroupServer{}).Where("server_group_id = ?", id).Pluck("server_id", &members).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+
 	uid := getUid(c)
 
 	err = singleton.DB.Transaction(func(tx *gorm.DB) error {
@@ -184,6 +192,10 @@ func updateServerGroup(c *gin.Context) (any, error) {
 	if err != nil {
 		return nil, newGormError("%v", err)
 	}
+	singleton.ServerShared.RefreshGroups()
+	singleton.RecordGroupMembership(sgDB.ID, members, sg.Servers, uid)
+	singleton.AccessGrantShared.RefreshMembers()
+	singleton.ClusterShared.PublishChange("server-group")
 
 	return nil, nil
 }
@@ -216,6 +228,11 @@ func batchDeleteServerGroup(c *gin.Context) (any, error) {
 		}
 	}
 
+	var members []model.ServerGroupServer
+	if err := singleton.DB.Where("server_group_id in (?)", sgs).Find(&members).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+
 	err := singleton.DB.Transaction(func(tx *gorm.DB) error {
 		if err := tx.Unscoped().Delete(&model.ServerGroup{}, "id in (?)", sgs).Error; err != nil {
 			return err
@@ -223,12 +240,40 @@ func batchDeleteServerGroup(c *gin.Context) (any, error) {
 		if err := tx.Unscoped().Delete(&model.ServerGroupServer{}, "server_group_id in (?)", sgs).Error; err != nil {
 			return err
 		}
-		return nil
+		return tx.Unscoped().Delete(&model.AccessGrant{}, "server_group_id in (?)", sgs).Error
 	})
 
 	if err != nil {
 		return nil, newGormError("%v", err)
 	}
+	singleton.ServerShared.RefreshGroups()
+	uid := getUid(c)
+	for _, m := range members {
+		singleton.RecordGroupMembership(m.ServerGroupId, []uint64{m.ServerId}, nil, uid)
+	}
+	singleton.AccessGrantShared.Reload()
+	singleton.ClusterShared.PublishChange("server-group")
 
 	return nil, nil
 }
+
+// checkServerSelectorPermission 检查服务器选择条件中明确指定的服务器与分组的权限，
+// 按标签或全部服务器匹配时在解析阶段按所有者过滤
+func checkServerSelectorPermission(c *gin.Context, sel *model.ServerSelector) error {
+	if !singleton.ServerShared.CheckPermission(c, slices.Values(slices.Concat(sel.Servers, sel.Exclude))) {
+		return singleton.Localizer.ErrorT("permission denied")
+	}
+	if len(sel.ServerGroups) == 0 {
+		return nil
+	}
+	var groups []model.ServerGroup
+	if err := singleton.DB.Where("id IN (?)", sel.ServerGroups).Find(&groups).Error; err != nil {
+		return newGormError("%v", err)
+	}
+	for _, g := range groups {
+		if !g.HasPermission(c) {
+			return singleton.Localizer.ErrorT("permission denied")
+		}
+	}
+	return nil
+}
@@ -1,7 +1,12 @@
 package controller
 
 import (
+	"encoding/csv"
+	"fmt"
+	"log"
 	"maps"
+	"net/http"
+	"net/url"
 	"slices"
 	"strconv"
 	"strings"
@@ -26,24 +31,67 @@ import (
 // @Success 200 {object} model.CommonResponse[model.ServiceResponse]
 // @Router /service [get]
 func showService(c *gin.Context) (*model.ServiceResponse, error) {
-	res, err, _ := requestGroup.Do("list-service", func() (any, error) {
-		singleton.AlertsLock.RLock()
-		defer singleton.AlertsLock.RUnlock()
-		stats := singleton.ServiceSentinelShared.CopyStats()
-		var cycleTransferStats map[uint64]model.CycleTransferStats
-		copier.Copy(&cycleTransferStats, singleton.AlertsCycleTransferStatsStore)
-		return []any{
-			stats, cycleTransferStats,
-		}, nil
+	key := singleton.QueryCacheKey{Endpoint: singleton.QueryCacheServiceOverview, Auth: queryCacheAuth(c)}
+	res, err := singleton.QueryCacheShared.GetOrLoad(key, func() (any, error) {
+		res, err, _ := requestGroup.Do("list-service", func() (any, error) {
+			singleton.AlertsLock.RLock()
+			defer singleton.AlertsLock.RUnlock()
+			stats := singleton.ServiceSentinelShared.CopyStats()
+			var cycleTransferStats map[uint64]model.CycleTransferStats
+			copier.Copy(&cycleTransferStats, singleton.AlertsCycleTransferStatsStore)
+			return &model.ServiceResponse{
+				Services:           stats,
+				CycleTransferStats: cycleTransferStats,
+			}, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if scope, ok := model.GetTenantScope(c); ok {
+			return tenantServiceResponse(res.(*model.ServiceResponse), scope), nil
+		}
+		return res, nil
 	})
 	if err != nil {
 		return nil, err
 	}
+	return res.(*model.ServiceResponse), nil
+}
+
+// tenantServiceResponse 只保留租户的服务与流量统计
+func tenantServiceResponse(res *model.ServiceResponse, scope model.TenantScope) *model.ServiceResponse {
+	ret := &model.ServiceResponse{
+		Services:           make(map[uint64]model.ServiceResponseItem),
+		CycleTransferStats: make(map[uint64]model.CycleTransferStats),
+	}
+	for _, s := range singleton.ServiceSentinelShared.GetSortedListByTenant(scope) {
+		if item, ok := res.Services[s.ID]; ok {
+			ret.Services[s.ID] = item
+		}
+	}
 
-	return &model.ServiceResponse{
-		Services:           res.([]any)[0].(map[uint64]model.ServiceResponseItem),
-		CycleTransferStats: res.([]any)[1].(map[uint64]model.CycleTransferStats),
-	}, nil
+	singleton.AlertsLock.RLock()
+	defer singleton.AlertsLock.RUnlock()
+	for _, alert := range singleton.Alerts {
+		if stats, ok := res.CycleTransferStats[alert.ID]; ok && scope.Contains(alert.UserID) {
+			ret.CycleTransferStats[alert.ID] = stats
+		}
+	}
+	return ret
+}
+
+// queryCacheAuth 返回请求者的权限级别，用于区分缓存，严格租户模式下包含租户范围
+func queryCacheAuth(c *gin.Context) string {
+	auth := "member"
+	if u, ok := c.Get(model.CtxKeyAuthorizedUser); !ok {
+		auth = "guest"
+	} else if u.(*model.User).Role == model.RoleAdmin {
+		auth = "admin"
+	}
+	if scope, ok := model.GetTenantScope(c); ok {
+		auth += ":" + scope.Key()
+	}
+	return auth
 }
 
 // List service
@@ -59,6 +107,9 @@ func showService(c *gin.Context) (*model.ServiceResponse, error) {
 func listService(c *gin.Context) ([]*model.Service, error) {
 	var ss []*model.Service
 	ssl := singleton.ServiceSentinelShared.GetSortedList()
+	if scope, ok := model.GetTenantScope(c); ok {
+		ssl = singleton.ServiceSentinelShared.GetSortedListByTenant(scope)
+	}
 	if err := copier.Copy(&ss, &ssl); err != nil {
 		return nil, err
 	}
@@ -70,9 +121,11 @@ func listService(c *gin.Context) ([]*model.Service, error) {
 // @Summary List service histories by server id
 // @Security BearerAuth
 // @Schemes
-// @Description List service histories by server id
+// @Description List service histories by server id, older ranges are filled with hourly or daily rollups
 // @Tags common
 // @param id path uint true "Server ID"
+// @param from query string false "Start time, YYYY-MM-DD or RFC3339, default 24 hours ago"
+// @param to query string false "End time, YYYY-MM-DD or RFC3339, default now"
 // @Produce json
 // @Success 200 {object} model.CommonResponse[[]model.ServiceInfos]
 // @Router /service/{id} [get]
@@ -88,6 +141,9 @@ func listServiceHistory(c *gin.Context) ([]*model.ServiceInfos, error) {
 	if !ok || server == nil {
 		return nil, singleton.Localizer.ErrorT("server not found")
 	}
+	if scope, ok := model.GetTenantScope(c); ok && !scope.Contains(server.UserID) {
+		return nil, singleton.Localizer.ErrorT("server not found")
+	}
 
 	_, isMember := c.Get(model.CtxKeyAuthorizedUser)
 	authorized := isMember // TODO || isViewPasswordVerfied
@@ -96,12 +152,44 @@ func listServiceHistory(c *gin.Context) ([]*model.ServiceInfos, error) {
 		return nil, singleton.Localizer.ErrorT("unauthorized")
 	}
 
-	var serviceHistories []*model.ServiceHistory
-	if err := singleton.DB.Model(&model.ServiceHistory{}).Select("service_id, created_at, server_id, avg_delay").
-		Where("server_id = ?", id).Where("created_at >= ?", time.Now().Add(-24*time.Hour)).Order("service_id, created_at").
-		Scan(&serviceHistories).Error; err != nil {
+	to := time.Now()
+	if toStr := c.Query("to"); toStr != "" {
+		if to, err = parseDateQuery(toStr); err != nil {
+			return nil, err
+		}
+	}
+	from := to.Add(-24 * time.Hour)
+	if fromStr := c.Query("from"); fromStr != "" {
+		if from, err = parseDateQuery(fromStr); err != nil {
+			return nil, err
+		}
+	}
+	if from.After(to) {
+		return nil, singleton.Localizer.ErrorT("invalid time range")
+	}
+
+	key := singleton.QueryCacheKey{
+		Endpoint: singleton.QueryCacheServerServiceHistory,
+		ID:       id,
+		Params:   c.Query("from") + "|" + c.Query("to"),
+		Auth:     queryCacheAuth(c),
+	}
+	res, err := singleton.QueryCacheShared.GetOrLoad(key, func() (any, error) {
+		return buildServiceInfos(id, from, to)
+	})
+	if err != nil {
 		return nil, err
 	}
+	return res.([]*model.ServiceInfos), nil
+}
+
+// buildServiceInfos 按服务汇总服务器在时间范围内的监控记录
+func buildServiceInfos(serverID uint64, from, to time.Time) ([]*model.ServiceInfos, error) {
+	m := singleton.ServerShared.GetList()
+	serviceHistories, err := singleton.QueryServiceHistory(serverID, from, to)
+	if err != nil {
+		return nil, newGormError("%v", err)
+	}
 
 	var sortedServiceIDs []uint64
 	resultMap := make(map[uint64]*model.ServiceInfos)
@@ -120,6 +208,10 @@ func listServiceHistory(c *gin.Context) ([]*model.ServiceInfos, error) {
 		}
 		infos.CreatedAt = append(infos.CreatedAt, history.CreatedAt.Truncate(time.Minute).Unix()*1000)
 		infos.AvgDelay = append(infos.AvgDelay, history.AvgDelay)
+		if service.Type == model.TaskTypeICMPPing {
+			infos.Loss = append(infos.Loss, history.Loss)
+			infos.Jitter = append(infos.Jitter, history.Jitter)
+		}
 	}
 
 	ret := make([]*model.ServiceInfos, 0, len(sortedServiceIDs))
@@ -130,6 +222,258 @@ func listServiceHistory(c *gin.Context) ([]*model.ServiceInfos, error) {
 	return ret, nil
 }
 
+// Get service latency percentiles
+// @Summary Get service latency percentiles
+// @Security BearerAuth
+// @Schemes
+// @Description Get latency percentiles of a service, grouped by probing server or region
+// @Tags common
+// @param id path uint true "Service ID"
+// @param group_by query string false "Group by" Enums(server, region, none) default(server)
+// @param percentile query number false "Percentile" default(95)
+// @param hours query int false "Time range in hours" default(24)
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.ServiceLatencyResponse]
+// @Router /service/{id}/latency [get]
+func getServiceLatency(c *gin.Context) (*model.ServiceLatencyResponse, error) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	percentile, err := strconv.ParseFloat(c.DefaultQuery("percentile", "95"), 64)
+	if err != nil || percentile <= 0 || percentile > 100 {
+		return nil, singleton.Localizer.ErrorT("invalid percentile")
+	}
+	hours, err := strconv.Atoi(c.DefaultQuery("hours", "24"))
+	if err != nil || hours <= 0 || hours > 30*24 {
+		return nil, singleton.Localizer.ErrorT("invalid time range")
+	}
+	groupBy := c.DefaultQuery("group_by", model.LatencyGroupByServer)
+	switch groupBy {
+	case model.LatencyGroupByServer, model.LatencyGroupByRegion, model.LatencyGroupByNone:
+	default:
+		return nil, singleton.Localizer.ErrorT("invalid group_by")
+	}
+
+	service, ok := singleton.ServiceSentinelShared.Get(id)
+	if !ok {
+		return nil, singleton.Localizer.ErrorT("service id %d does not exist", id)
+	}
+	if scope, ok := model.GetTenantScope(c); ok && !scope.Contains(service.UserID) {
+		return nil, singleton.Localizer.ErrorT("service id %d does not exist", id)
+	}
+
+	_, isMember := c.Get(model.CtxKeyAuthorizedUser)
+	authorized := isMember
+	if !authorized && !service.EnableShowInService {
+		return nil, singleton.Localizer.ErrorT("unauthorized")
+	}
+
+	servers := singleton.ServerShared.GetList()
+	return singleton.ServiceSentinelShared.QueryLatency(id, time.Now().Add(-time.Duration(hours)*time.Hour), percentile, groupBy, func(serverID uint64) string {
+		server, ok := servers[serverID]
+		if !ok || (server.HideForGuest && !authorized) {
+			return ""
+		}
+		return singleton.LatencyGroupKey(server, groupBy)
+	})
+}
+
+// List DNS probe results of a service
+// @Summary List DNS probe results of a service
+// @Security BearerAuth
+// @Schemes
+// @Description List the latest DNS answers reported by each agent
+// @Tags auth required
+// @param id path uint true "Service ID"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[[]model.DNSProbeResult]
+// @Router /service/{id}/dns [get]
+func listServiceDNSProbe(c *gin.Context) ([]model.DNSProbeResult, error) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	service, ok := singleton.ServiceSentinelShared.Get(id)
+	if !ok {
+		return nil, singleton.Localizer.ErrorT("service id %d does not exist", id)
+	}
+	if !service.HasPermission(c) {
+		return nil, singleton.Localizer.ErrorT("permission denied")
+	}
+
+	return singleton.ServiceSentinelShared.GetDNSProbeResults(id), nil
+}
+
+// Get probe assignment of a service
+// @Summary Get probe assignment of a service
+// @Security BearerAuth
+// @Schemes
+// @Description Get agents currently selected to run the service, with their last check time and recent changes
+// @Tags auth required
+// @param id path uint true "Service ID"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.ServiceProbeAssignment]
+// @Router /service/{id}/probes [get]
+func getServiceProbeAssignment(c *gin.Context) (*model.ServiceProbeAssignment, error) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	service, ok := singleton.ServiceSentinelShared.Get(id)
+	if !ok {
+		return nil, singleton.Localizer.ErrorT("service id %d does not exist", id)
+	}
+	if !service.HasPermission(c) {
+		return nil, singleton.Localizer.ErrorT("permission denied")
+	}
+
+	return singleton.ServiceSentinelShared.GetProbeAssignment(id), nil
+}
+
+// List raw check results of a service
+// @Summary List raw check results of a service
+// @Security BearerAuth
+// @Schemes
+// @Description List the unaggregated check results of a service, which are only recorded when record_results is enabled. JSON results are paginated by cursor, CSV results are streamed
+// @Tags auth required
+// @param id path uint true "Service ID"
+// @param from query string false "Start time, YYYY-MM-DD or RFC3339, default 24 hours ago"
+// @param to query string false "End time, YYYY-MM-DD or RFC3339, default now"
+// @param format query string false "json or csv"
+// @param cursor query string false "next_cursor returned by the previous page"
+// @param limit query uint false "Page size, default 1000, max 10000"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.ServiceResultsResponse]
+// @Router /service/{id}/results [get]
+func listServiceResults(c *gin.Context) (*model.ServiceResultsResponse, error) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	service, ok := singleton.ServiceSentinelShared.Get(id)
+	if !ok {
+		return nil, singleton.Localizer.ErrorT("service id %d does not exist", id)
+	}
+	if !service.HasPermission(c) {
+		return nil, singleton.Localizer.ErrorT("permission denied")
+	}
+
+	to := time.Now()
+	if toStr := c.Query("to"); toStr != "" {
+		if to, err = parseDateQuery(toStr); err != nil {
+			return nil, err
+		}
+	}
+	from := to.Add(-24 * time.Hour)
+	if fromStr := c.Query("from"); fromStr != "" {
+		if from, err = parseDateQuery(fromStr); err != nil {
+			return nil, err
+		}
+	}
+	if from.After(to) {
+		return nil, singleton.Localizer.ErrorT("invalid time range")
+	}
+
+	cursor := c.Query("cursor")
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	if c.Query("format") == "csv" {
+		return nil, streamServiceResultsCSV(c, service, from, to, cursor, limit)
+	}
+
+	if limit <= 0 {
+		limit = 1000
+	}
+	limit = min(limit, 10000)
+
+	resp := &model.ServiceResultsResponse{}
+	if resp.Results, resp.NextCursor, err = singleton.ServiceSentinelShared.ServiceResults(id, from, to, cursor, limit); err != nil {
+		return nil, newGormError("%v", err)
+	}
+	return resp, nil
+}
+
+// streamServiceResultsCSV 分页读取并写出原始检查结果，避免将整个时间范围的数据载入内存，limit 为 0 时不限制行数
+func streamServiceResultsCSV(c *gin.Context, service *model.Service, from, to time.Time, cursor string, limit int) error {
+	const pageSize = 1000
+
+	page, cursor, err := singleton.ServiceSentinelShared.ServiceResults(service.ID, from, to, cursor, pageSize)
+	if err != nil {
+		return newGormError("%v", err)
+	}
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=service-%d-results.csv", service.ID))
+	c.Status(http.StatusOK)
+
+	w := csv.NewWriter(c.Writer)
+	w.Write([]string{"id", "created_at", "service_id", "server_id", "successful", "delay", "data"})
+
+	var n int
+	for len(page) > 0 && (limit <= 0 || n < limit) {
+		for _, r := range page[:min(len(page), utils.IfOr(limit > 0, limit-n, len(page)))] {
+			w.Write([]string{
+				strconv.FormatUint(r.ID, 10),
+				r.CreatedAt.Format(time.RFC3339),
+				strconv.FormatUint(r.ServiceID, 10),
+				strconv.FormatUint(r.ServerID, 10),
+				strconv.FormatBool(r.Successful),
+				strconv.FormatFloat(float64(r.Delay), 'f', 2, 32),
+				r.Data,
+			})
+			n++
+		}
+		w.Flush()
+		c.Writer.Flush()
+
+		if cursor == "" {
+			break
+		}
+		if page, cursor, err = singleton.ServiceSentinelShared.ServiceResults(service.ID, from, to, cursor, pageSize); err != nil {
+			log.Printf("NEZHA>> Failed to export results of service %d: %v", service.ID, err)
+			break
+		}
+	}
+	return errNoop
+}
+
+// Get composite service status
+// @Summary Get composite service status
+// @Security BearerAuth
+// @Schemes
+// @Description Get the rolled-up state of a composite service and the states of its child services
+// @Tags auth required
+// @param id path uint true "Service ID"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.CompositeServiceStatus]
+// @Router /service/{id}/composite [get]
+func getCompositeServiceStatus(c *gin.Context) (*model.CompositeServiceStatus, error) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	service, ok := singleton.ServiceSentinelShared.Get(id)
+	if !ok || service.Type != model.TaskTypeComposite {
+		return nil, singleton.Localizer.ErrorT("service id %d does not exist", id)
+	}
+	if !service.HasPermission(c) {
+		return nil, singleton.Localizer.ErrorT("permission denied")
+	}
+
+	return singleton.ServiceSentinelShared.GetCompositeStatus(service), nil
+}
+
 // List server with service
 // @Summary List server with service
 // @Security BearerAuth
@@ -140,16 +484,14 @@ func listServiceHistory(c *gin.Context) ([]*model.ServiceInfos, error) {
 // @Success 200 {object} model.CommonResponse[[]uint64]
 // @Router /service/server [get]
 func listServerWithServices(c *gin.Context) ([]uint64, error) {
-	var serverIdsWithService []uint64
-	if err := singleton.DB.Model(&model.ServiceHistory{}).
-		Select("distinct(server_id)").
-		Where("server_id != 0").
-		Find(&serverIdsWithService).Error; err != nil {
+	serverIdsWithService, err := singleton.TSDBShared.ServerIDs()
+	if err != nil {
 		return nil, newGormError("%v", err)
 	}
 
 	_, isMember := c.Get(model.CtxKeyAuthorizedUser)
 	authorized := isMember // TODO || isViewPasswordVerfied
+	scope, scoped := model.GetTenantScope(c)
 
 	var ret []uint64
 	for _, id := range serverIdsWithService {
@@ -157,6 +499,9 @@ func listServerWithServices(c *gin.Context) ([]uint64, error) {
 		if !ok || server == nil {
 			return nil, singleton.Localizer.ErrorT("server not found")
 		}
+		if scoped && !scope.Contains(server.UserID) {
+			continue
+		}
 		if !server.HideForGuest || authorized {
 			ret = append(ret, id)
 		}
@@ -193,14 +538,39 @@ func createService(c *gin.Context) (uint64, error) {
 	m.Cover = mf.Cover
 	m.Notify = mf.Notify
 	m.NotificationGroupID = mf.NotificationGroupID
-	m.Duration = mf.Duration
+	m.Duration = mf.Interval()
+	m.TimeoutSeconds = mf.TimeoutSeconds
+	m.FailureThreshold = mf.FailureThreshold
+	m.RecoveryThreshold = mf.RecoveryThreshold
+	m.QuorumCount = mf.QuorumCount
+	m.QuorumPercent = mf.QuorumPercent
+	m.QuorumWindow = mf.QuorumWindow
 	m.LatencyNotify = mf.LatencyNotify
 	m.MinLatency = mf.MinLatency
 	m.MaxLatency = mf.MaxLatency
 	m.EnableShowInService = mf.EnableShowInService
+	m.RecordResults = mf.RecordResults
 	m.EnableTriggerTask = mf.EnableTriggerTask
 	m.RecoverTriggerTasks = mf.RecoverTriggerTasks
 	m.FailTriggerTasks = mf.FailTriggerTasks
+	m.HTTPAssertion = mf.HTTPAssertion
+	m.DNSConfig = mf.DNSConfig
+	m.HTTPConfig = mf.HTTPConfig
+	m.ProbeSelector = mf.ProbeSelector
+	m.Composite = mf.Composite
+	m.WebhookURL = strings.TrimSpace(mf.WebhookURL)
+	m.WebhookEveryCheck = mf.WebhookEveryCheck
+	m.ICMPCount = mf.ICMPCount
+	m.ICMPSize = mf.ICMPSize
+	m.ICMPInterval = mf.ICMPInterval
+	m.MaxLoss = mf.MaxLoss
+	m.MaxJitter = mf.MaxJitter
+	m.TLSWarnDays = mf.TLSWarnDays
+	m.TLSCriticalDays = mf.TLSCriticalDays
+
+	if err := validateServiceOptions(&m); err != nil {
+		return 0, err
+	}
 
 	if err := validateServers(c, &m); err != nil {
 		return 0, err
@@ -216,9 +586,10 @@ func createService(c *gin.Context) (uint64, error) {
 	}
 
 	var err error
-	if m.Cover == 0 {
+	switch m.Cover {
+	case model.ServiceCoverAll:
 		err = singleton.DB.Unscoped().Delete(&model.ServiceHistory{}, "service_id = ? and server_id in (?)", m.ID, skipServers).Error
-	} else {
+	case model.ServiceCoverIgnoreAll:
 		err = singleton.DB.Unscoped().Delete(&model.ServiceHistory{}, "service_id = ? and server_id not in (?)", m.ID, skipServers).Error
 	}
 	if err != nil {
@@ -228,8 +599,12 @@ func createService(c *gin.Context) (uint64, error) {
 	if err := singleton.ServiceSentinelShared.Update(&m); err != nil {
 		return 0, err
 	}
+	if err := singleton.SyncServiceAlertRule(&m); err != nil {
+		return 0, newGormError("%v", err)
+	}
 
 	singleton.ServiceSentinelShared.UpdateServiceList()
+	singleton.ClusterShared.PublishChange("service")
 	return m.ID, nil
 }
 
@@ -271,14 +646,39 @@ func updateService(c *gin.Context) (any, error) {
 	m.Cover = mf.Cover
 	m.Notify = mf.Notify
 	m.NotificationGroupID = mf.NotificationGroupID
-	m.Duration = mf.Duration
+	m.Duration = mf.Interval()
+	m.TimeoutSeconds = mf.TimeoutSeconds
+	m.FailureThreshold = mf.FailureThreshold
+	m.RecoveryThreshold = mf.RecoveryThreshold
+	m.QuorumCount = mf.QuorumCount
+	m.QuorumPercent = mf.QuorumPercent
+	m.QuorumWindow = mf.QuorumWindow
 	m.LatencyNotify = mf.LatencyNotify
 	m.MinLatency = mf.MinLatency
 	m.MaxLatency = mf.MaxLatency
 	m.EnableShowInService = mf.EnableShowInService
+	m.RecordResults = mf.RecordResults
 	m.EnableTriggerTask = mf.EnableTriggerTask
 	m.RecoverTriggerTasks = mf.RecoverTriggerTasks
 	m.FailTriggerTasks = mf.FailTriggerTasks
+	m.HTTPAssertion = mf.HTTPAssertion
+	m.DNSConfig = mf.DNSConfig
+	m.HTTPConfig = mf.HTTPConfig
+	m.ProbeSelector = mf.ProbeSelector
+	m.Composite = mf.Composite
+	m.WebhookURL = strings.TrimSpace(mf.WebhookURL)
+	m.WebhookEveryCheck = mf.WebhookEveryCheck
+	m.ICMPCount = mf.ICMPCount
+	m.ICMPSize = mf.ICMPSize
+	m.ICMPInterval = mf.ICMPInterval
+	m.MaxLoss = mf.MaxLoss
+	m.MaxJitter = mf.MaxJitter
+	m.TLSWarnDays = mf.TLSWarnDays
+	m.TLSCriticalDays = mf.TLSCriticalDays
+
+	if err := validateServiceOptions(&m); err != nil {
+		return 0, err
+	}
 
 	if err := validateServers(c, &m); err != nil {
 		return 0, err
@@ -290,9 +690,10 @@ func updateService(c *gin.Context) (any, error) {
 
 	skipServers := utils.MapKeysToSlice(mf.SkipServers)
 
-	if m.Cover == model.ServiceCoverAll {
+	switch m.Cover {
+	case model.ServiceCoverAll:
 		err = singleton.DB.Unscoped().Delete(&model.ServiceHistory{}, "service_id = ? and server_id in (?)", m.ID, skipServers).Error
-	} else {
+	case model.ServiceCoverIgnoreAll:
 		err = singleton.DB.Unscoped().Delete(&model.ServiceHistory{}, "service_id = ? and server_id not in (?) and server_id > 0", m.ID, skipServers).Error
 	}
 	if err != nil {
@@ -302,8 +703,12 @@ func updateService(c *gin.Context) (any, error) {
 	if err := singleton.ServiceSentinelShared.Update(&m); err != nil {
 		return nil, err
 	}
+	if err := singleton.SyncServiceAlertRule(&m); err != nil {
+		return nil, newGormError("%v", err)
+	}
 
 	singleton.ServiceSentinelShared.UpdateServiceList()
+	singleton.ClusterShared.PublishChange("service")
 	return nil, nil
 }
 
@@ -327,6 +732,14 @@ func batchDeleteService(c *gin.Context) (any, error) {
 	if !singleton.ServiceSentinelShared.CheckPermission(c, slices.Values(ids)) {
 		return nil, singleton.Localizer.ErrorT("permission denied")
 	}
+	// 组合服务仍引用的子服务不能单独删除
+	for _, id := range ids {
+		for _, parent := range singleton.ServiceSentinelShared.CompositeParents(id) {
+			if !slices.Contains(ids, parent) {
+				return nil, singleton.Localizer.ErrorT("service %d is referenced by composite service %d", id, parent)
+			}
+		}
+	}
 
 	err := singleton.DB.Transaction(func(tx *gorm.DB) error {
 		if err := tx.Unscoped().Delete(&model.Service{}, "id in (?)", ids).Error; err != nil {
@@ -339,6 +752,10 @@ func batchDeleteService(c *gin.Context) (any, error) {
 	}
 	singleton.ServiceSentinelShared.Delete(ids)
 	singleton.ServiceSentinelShared.UpdateServiceList()
+	singleton.ClusterShared.PublishChange("service")
+	if err := singleton.DeleteServiceAlertRules(ids); err != nil {
+		return nil, newGormError("%v", err)
+	}
 	return nil, nil
 }
 
@@ -346,6 +763,78 @@ func validateServers(c *gin.Context, ss *model.Service) error {
 	if !singleton.ServerShared.CheckPermission(c, maps.Keys(ss.SkipServers)) {
 		return singleton.Localizer.ErrorT("permission denied")
 	}
+	if ss.Composite != nil && !singleton.ServiceSentinelShared.CheckPermission(c, slices.Values(ss.Composite.Children)) {
+		return singleton.Localizer.ErrorT("permission denied")
+	}
+	if ss.Cover == model.ServiceCoverSelected && ss.ProbeSelector != nil {
+		if err := checkServerSelectorPermission(c, &ss.ProbeSelector.ServerSelector); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
 
+func validateServiceOptions(m *model.Service) error {
+	if m.Duration != 0 && (m.Duration < model.ServiceIntervalMin || m.Duration > model.ServiceIntervalMax) {
+		return singleton.Localizer.ErrorT("duration must be between 5 and 86400 seconds")
+	}
+	if m.TimeoutSeconds > 60 || (m.Duration != 0 && uint64(m.TimeoutSeconds) >= m.Duration) {
+		return singleton.Localizer.ErrorT("timeout must not exceed 60 seconds or the check interval")
+	}
+	if m.FailureThreshold > 10 || m.RecoveryThreshold > 10 {
+		return singleton.Localizer.ErrorT("threshold must be at most 10")
+	}
+	if m.ICMPCount > 20 || m.ICMPSize > 65500 || m.ICMPInterval > 5000 {
+		return singleton.Localizer.ErrorT("invalid icmp options")
+	}
+	if m.MaxLoss < 0 || m.MaxLoss > 100 || m.MaxJitter < 0 {
+		return singleton.Localizer.ErrorT("invalid packet loss or jitter threshold")
+	}
+	if m.WebhookURL != "" {
+		if u, err := url.Parse(m.WebhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return singleton.Localizer.ErrorT("invalid webhook url")
+		}
+	}
+	if m.Cover > model.ServiceCoverSelected {
+		return singleton.Localizer.ErrorT("invalid cover type")
+	}
+	if m.Cover == model.ServiceCoverSelected {
+		if err := m.ProbeSelector.Validate(); err != nil {
+			return err
+		}
+	}
+	if err := m.HTTPAssertion.Validate(); err != nil {
+		return err
+	}
+	if m.Type == model.TaskTypeHTTPGet && !m.HTTPConfig.IsEmpty() {
+		if err := m.HTTPConfig.Validate(m.Target); err != nil {
+			return err
+		}
+	} else {
+		m.HTTPConfig = nil
+	}
+	if err := m.ValidateQuorum(); err != nil {
+		return err
+	}
+	if m.Type == model.TaskTypeComposite {
+		// 组合服务的状态由子服务决定
+		m.QuorumCount, m.QuorumPercent, m.QuorumWindow = 0, 0, 0
+		if err := m.Composite.Validate(m.ID); err != nil {
+			return err
+		}
+		if err := singleton.ServiceSentinelShared.CheckCompositeCycle(m.ID, m.Composite); err != nil {
+			return err
+		}
+	} else {
+		m.Composite = nil
+	}
+	if m.Type == model.TaskTypeDNS {
+		if err := m.DNSConfig.Validate(); err != nil {
+			return err
+		}
+	} else {
+		m.DNSConfig = nil
+	}
 	return nil
 }
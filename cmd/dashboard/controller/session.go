@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"log"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+// List sessions
+// @Summary List sessions
+// @Security BearerAuth
+// @Schemes
+// @Description List active login sessions of the current user
+// @Tags auth required
+// @Produce json
+// @Success 200 {object} model.CommonResponse[[]model.Session]
+// @Router /user/sessions [get]
+func listSession(c *gin.Context) ([]*model.Session, error) {
+	if err := rejectAPIToken(c); err != nil {
+		return nil, err
+	}
+
+	sessions, err := singleton.SessionShared.List(getUid(c), c.GetString(model.CtxKeySessionID))
+	if err != nil {
+		return nil, newGormError("%v", err)
+	}
+	return sessions, nil
+}
+
+// Revoke session
+// @Summary Revoke session
+// @Security BearerAuth
+// @Schemes
+// @Description Revoke a login session, its requests and websocket connections are rejected immediately
+// @Tags auth required
+// @param id path string true "Session ID"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /user/sessions/{id} [delete]
+func revokeSession(c *gin.Context) (any, error) {
+	if err := rejectAPIToken(c); err != nil {
+		return nil, err
+	}
+
+	uid := getUid(c)
+	if err := singleton.SessionShared.Revoke(uid, c.Param("id")); err != nil {
+		return nil, err
+	}
+
+	log.Printf("NEZHA>> User %d revoked session %s", uid, c.Param("id"))
+	return nil, nil
+}
+
+// Revoke other sessions
+// @Summary Revoke other sessions
+// @Security BearerAuth
+// @Schemes
+// @Description Revoke all login sessions of the current user except the one making the request
+// @Tags auth required
+// @Produce json
+// @Success 200 {object} model.CommonResponse[int]
+// @Router /user/sessions [delete]
+func revokeOtherSessions(c *gin.Context) (int, error) {
+	if err := rejectAPIToken(c); err != nil {
+		return 0, err
+	}
+
+	uid := getUid(c)
+	n, err := singleton.SessionShared.RevokeOthers(uid, c.GetString(model.CtxKeySessionID))
+	if err != nil {
+		return 0, newGormError("%v", err)
+	}
+
+	log.Printf("NEZHA>> User %d revoked %d other session(s)", uid, n)
+	return n, nil
+}
@@ -5,8 +5,10 @@ import (
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/goccy/go-json"
 
 	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/utils"
 	"github.com/nezhahq/nezha/service/singleton"
 )
 
@@ -21,6 +23,9 @@ import (
 // @Router /setting [get]
 func listConfig(c *gin.Context) (*model.SettingResponse, error) {
 	u, authorized := c.Get(model.CtxKeyAuthorizedUser)
+	// 管理区域网段以外只返回访客设置，也不展示无法使用的 OAuth2 登录方式
+	adminNetwork := adminAccessAllowed(c)
+	authorized = authorized && adminNetwork
 	var isAdmin bool
 	if authorized {
 		user := u.(*model.User)
@@ -52,7 +57,7 @@ func listConfig(c *gin.Context) (*model.SettingResponse, error) {
 			Config: model.Setting{
 				ConfigForGuests: configForGuests,
 				ConfigDashboard: configDashboard,
-				Oauth2Providers: config.Oauth2Providers,
+				Oauth2Providers: utils.IfOr(adminNetwork, config.Oauth2Providers, nil),
 			},
 		}
 	}
@@ -89,10 +94,47 @@ func updateConfig(c *gin.Context) (any, error) {
 		return nil, errors.New("invalid user template")
 	}
 
+	if sf.NATStatResetSchedule != "" {
+		if _, err := model.ParseCronSchedule(sf.NATStatResetSchedule); err != nil {
+			return nil, singleton.Localizer.ErrorT("invalid schedule: %v", err)
+		}
+	}
+
+	if _, err := utils.ParseTrustedProxies(sf.TrustedProxies); err != nil {
+		return nil, singleton.Localizer.ErrorT("invalid trusted proxy: %v", err)
+	}
+	if singleton.Conf.ProxyProtocol && len(sf.TrustedProxies) == 0 {
+		return nil, singleton.Localizer.ErrorT("proxy_protocol requires trusted_proxies to be set")
+	}
+
+	switch sf.WAFUnresolvedAction {
+	case "", model.WAFRuleAllow, model.WAFRuleDeny:
+	default:
+		return nil, singleton.Localizer.ErrorT("invalid action")
+	}
+
+	for _, name := range sf.WAFNotifyReasons {
+		if _, ok := model.WAFBlockReasons[name]; !ok {
+			return nil, singleton.Localizer.ErrorT("unknown block reason: %s", name)
+		}
+	}
+
+	if sf.DisablePasswordLogin && len(singleton.Conf.Oauth2) == 0 {
+		return nil, singleton.Localizer.ErrorT("no oauth2 provider is configured")
+	}
+
 	singleton.Conf.Language = strings.Replace(sf.Language, "-", "_", -1)
 
 	singleton.Conf.EnableIPChangeNotification = sf.EnableIPChangeNotification
 	singleton.Conf.EnablePlainIPInNotification = sf.EnablePlainIPInNotification
+	singleton.Conf.RequireAdminForShellTasks = sf.RequireAdminForShellTasks
+	singleton.Conf.DisablePasswordLogin = sf.DisablePasswordLogin
+	singleton.Conf.ViewerShowNote = sf.ViewerShowNote
+	singleton.Conf.StrictTenant = sf.StrictTenant
+	singleton.Conf.AuditRejectedReports = sf.AuditRejectedReports
+	singleton.Conf.WAFNotificationGroupID = sf.WAFNotificationGroupID
+	singleton.Conf.WAFNotifyReasons = sf.WAFNotifyReasons
+	singleton.Conf.WAFDailySummary = sf.WAFDailySummary
 	singleton.Conf.Cover = sf.Cover
 	singleton.Conf.InstallHost = sf.InstallHost
 	singleton.Conf.IgnoredIPNotification = sf.IgnoredIPNotification
@@ -103,13 +145,94 @@ func updateConfig(c *gin.Context) (any, error) {
 	singleton.Conf.CustomCodeDashboard = sf.CustomCodeDashboard
 	singleton.Conf.WebRealIPHeader = sf.WebRealIPHeader
 	singleton.Conf.AgentRealIPHeader = sf.AgentRealIPHeader
+	singleton.Conf.TrustedProxies = sf.TrustedProxies
 	singleton.Conf.AgentTLS = sf.AgentTLS
 	singleton.Conf.UserTemplate = sf.UserTemplate
+	if sf.TrafficRetentionDays > 0 {
+		singleton.Conf.TrafficRetentionDays = sf.TrafficRetentionDays
+	}
+	if sf.CronHistoryRetentionDays > 0 {
+		singleton.Conf.CronHistoryRetentionDays = sf.CronHistoryRetentionDays
+	}
+	if sf.CronHistoryMaxRows > 0 {
+		singleton.Conf.CronHistoryMaxRows = sf.CronHistoryMaxRows
+	}
+	if sf.AuditLogRetentionDays > 0 {
+		singleton.Conf.AuditLogRetentionDays = sf.AuditLogRetentionDays
+	}
+	if sf.ServiceHistoryRawDays > 0 {
+		singleton.Conf.ServiceHistoryRawDays = sf.ServiceHistoryRawDays
+	}
+	if sf.ServiceHistoryHourlyDays > 0 {
+		singleton.Conf.ServiceHistoryHourlyDays = sf.ServiceHistoryHourlyDays
+	}
+	if sf.ServiceHistoryDailyDays != nil && *sf.ServiceHistoryDailyDays >= 0 {
+		singleton.Conf.ServiceHistoryDailyDays = *sf.ServiceHistoryDailyDays
+	}
+	natScheduleChanged := sf.NATStatResetSchedule != "" && sf.NATStatResetSchedule != singleton.Conf.NATStatResetSchedule
+	if natScheduleChanged {
+		singleton.Conf.NATStatResetSchedule = sf.NATStatResetSchedule
+	}
+	if sf.MeshPingInterval > 0 {
+		singleton.Conf.MeshPingInterval = max(sf.MeshPingInterval, 10)
+	}
+	if sf.MeshPingFanOut > 0 {
+		singleton.Conf.MeshPingFanOut = sf.MeshPingFanOut
+	}
+	if sf.WAFUnresolvedAction != "" {
+		singleton.Conf.WAFUnresolvedAction = sf.WAFUnresolvedAction
+	}
 
 	if err := singleton.Conf.Save(); err != nil {
 		return nil, newGormError("%v", err)
 	}
 
+	if natScheduleChanged {
+		singleton.NATShared.RescheduleStats()
+	}
 	singleton.OnUpdateLang(singleton.Conf.Language)
 	return nil, nil
 }
+
+// List runtime settings
+// @Summary List runtime settings
+// @Security BearerAuth
+// @Schemes
+// @Description List settings that can be changed without editing the config file, secret values are not returned
+// @Tags admin required
+// @Produce json
+// @Success 200 {object} model.CommonResponse[[]model.SettingItem]
+// @Router /admin/settings [get]
+func listSettingItems(c *gin.Context) ([]model.SettingItem, error) {
+	return singleton.ListSettings(), nil
+}
+
+// Update runtime settings
+// @Summary Update runtime settings
+// @Security BearerAuth
+// @Schemes
+// @Description Update settings by key, a null value restores the value from the config file. Nothing is changed if any key fails validation
+// @Tags admin required
+// @Accept json
+// @Param body body map[string]any true "key -> value"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.SettingsUpdateResponse]
+// @Router /admin/settings [patch]
+func updateSettingItems(c *gin.Context) (*model.SettingsUpdateResponse, error) {
+	var values map[string]json.RawMessage
+	if err := c.ShouldBindJSON(&values); err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return nil, singleton.Localizer.ErrorT("no settings to update")
+	}
+
+	resp, err := singleton.UpdateSettings(getUid(c), values)
+	if err != nil {
+		if _, ok := err.(model.SettingErrors); ok {
+			return nil, err
+		}
+		return nil, newGormError("%v", err)
+	}
+	return resp, nil
+}
@@ -0,0 +1,276 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/goccy/go-json"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+// List SLA report configs
+// @Summary List SLA report configs
+// @Security BearerAuth
+// @Schemes
+// @Description List SLA report configs
+// @Tags admin required
+// @Produce json
+// @Success 200 {object} model.CommonResponse[[]model.SLAReportConfig]
+// @Router /report-config [get]
+func listSLAReportConfig(c *gin.Context) ([]*model.SLAReportConfig, error) {
+	var configs []*model.SLAReportConfig
+	if err := singleton.DB.Order("id").Find(&configs).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+	for i, cfg := range configs {
+		configs[i] = cfg.Redacted()
+	}
+	return configs, nil
+}
+
+// Add SLA report config
+// @Summary Add SLA report config
+// @Security BearerAuth
+// @Schemes
+// @Description Add SLA report config
+// @Tags admin required
+// @Accept json
+// @param request body model.SLAReportConfigForm true "SLAReportConfigForm"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[uint64]
+// @Router /report-config [post]
+func createSLAReportConfig(c *gin.Context) (uint64, error) {
+	var rf model.SLAReportConfigForm
+	if err := c.ShouldBindJSON(&rf); err != nil {
+		return 0, err
+	}
+
+	var cfg model.SLAReportConfig
+	cfg.UserID = getUid(c)
+	if err := applySLAReportConfigForm(&cfg, &rf); err != nil {
+		return 0, err
+	}
+
+	if err := singleton.DB.Create(&cfg).Error; err != nil {
+		return 0, newGormError("%v", err)
+	}
+	return cfg.ID, nil
+}
+
+// Update SLA report config
+// @Summary Update SLA report config
+// @Security BearerAuth
+// @Schemes
+// @Description Update SLA report config
+// @Tags admin required
+// @Accept json
+// @param id path uint true "Config ID"
+// @param request body model.SLAReportConfigForm true "SLAReportConfigForm"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /report-config/{id} [patch]
+func updateSLAReportConfig(c *gin.Context) (any, error) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	var rf model.SLAReportConfigForm
+	if err := c.ShouldBindJSON(&rf); err != nil {
+		return nil, err
+	}
+
+	var cfg model.SLAReportConfig
+	if err := singleton.DB.First(&cfg, id).Error; err != nil {
+		return nil, singleton.Localizer.ErrorT("report config id %d does not exist", id)
+	}
+	rf.WebhookURL = model.RestoreSecret(rf.WebhookURL, cfg.WebhookURL, model.RedactURL)
+	if err := applySLAReportConfigForm(&cfg, &rf); err != nil {
+		return nil, err
+	}
+
+	if err := singleton.DB.Save(&cfg).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+	return nil, nil
+}
+
+// Batch delete SLA report configs
+// @Summary Batch delete SLA report configs
+// @Security BearerAuth
+// @Schemes
+// @Description Batch delete SLA report configs, reports already generated are kept
+// @Tags admin required
+// @Accept json
+// @param request body []uint64 true "id list"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /batch-delete/report-config [post]
+func batchDeleteSLAReportConfig(c *gin.Context) (any, error) {
+	var ids []uint64
+	if err := c.ShouldBindJSON(&ids); err != nil {
+		return nil, err
+	}
+
+	if err := singleton.DB.Unscoped().Delete(&model.SLAReportConfig{}, "id in (?)", ids).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+	return nil, nil
+}
+
+// Generate SLA report
+// @Summary Generate SLA report
+// @Security BearerAuth
+// @Schemes
+// @Description Generate a report now, for the last complete period unless a time range is given
+// @Tags admin required
+// @Accept json
+// @param id path uint true "Config ID"
+// @param request body model.SLAReportGenerateForm true "SLAReportGenerateForm"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[uint64]
+// @Router /report-config/{id}/generate [post]
+func generateSLAReport(c *gin.Context) (uint64, error) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	var gf model.SLAReportGenerateForm
+	if err := c.ShouldBindJSON(&gf); err != nil {
+		return 0, err
+	}
+
+	var cfg model.SLAReportConfig
+	if err := singleton.DB.First(&cfg, id).Error; err != nil {
+		return 0, singleton.Localizer.ErrorT("report config id %d does not exist", id)
+	}
+
+	start, end := cfg.PeriodRange(time.Now().In(singleton.Loc))
+	if gf.PeriodStart != nil && gf.PeriodEnd != nil {
+		start, end = time.Unix(*gf.PeriodStart, 0), time.Unix(*gf.PeriodEnd, 0)
+	}
+	if !start.Before(end) {
+		return 0, singleton.Localizer.ErrorT("invalid time range")
+	}
+
+	report, err := singleton.GenerateSLAReport(&cfg, start, end)
+	if err != nil {
+		return 0, newGormError("%v", err)
+	}
+	return report.ID, nil
+}
+
+// List SLA reports
+// @Summary List SLA reports
+// @Security BearerAuth
+// @Schemes
+// @Description List generated SLA reports, newest first
+// @Tags auth required
+// @Param config_id query uint false "Config ID"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[[]model.SLAReport]
+// @Router /reports [get]
+func listSLAReport(c *gin.Context) ([]*model.SLAReport, error) {
+	query := singleton.DB.Omit("data", "html").Order("period_start DESC, id DESC")
+	if configID, err := strconv.ParseUint(c.Query("config_id"), 10, 64); err == nil {
+		query = query.Where("config_id = ?", configID)
+	}
+	var list []*model.SLAReport
+	if err := query.Find(&list).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+	reports := make([]*model.SLAReport, 0, len(list))
+	for _, r := range list {
+		if r.HasPermission(c) {
+			reports = append(reports, r)
+		}
+	}
+	return reports, nil
+}
+
+// Get SLA report
+// @Summary Get SLA report
+// @Security BearerAuth
+// @Schemes
+// @Description Get a generated SLA report, format=html downloads the HTML version
+// @Tags auth required
+// @Param id path uint true "Report ID"
+// @Param format query string false "json or html"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.SLAReportResponse]
+// @Router /reports/{id} [get]
+func getSLAReport(c *gin.Context) (*model.SLAReportResponse, error) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	var report model.SLAReport
+	if err := singleton.DB.First(&report, id).Error; err != nil {
+		return nil, singleton.Localizer.ErrorT("report id %d does not exist", id)
+	}
+	if !report.HasPermission(c) {
+		return nil, singleton.Localizer.ErrorT("permission denied")
+	}
+
+	if c.Query("format") == "html" {
+		filename := fmt.Sprintf("sla-report-%d-%s.html", report.ID, report.PeriodStart.In(singleton.Loc).Format("20060102"))
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(report.HTML))
+		return nil, errNoop
+	}
+
+	resp := &model.SLAReportResponse{SLAReport: report}
+	if err := json.Unmarshal([]byte(report.Data), &resp.Data); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func applySLAReportConfigForm(cfg *model.SLAReportConfig, rf *model.SLAReportConfigForm) error {
+	rf.ServerGroups = slices.Compact(slices.Sorted(slices.Values(rf.ServerGroups)))
+	if len(rf.Services) == 0 && len(rf.ServerGroups) == 0 {
+		return singleton.Localizer.ErrorT("at least one service or server group is required")
+	}
+	for _, id := range rf.Services {
+		if _, ok := singleton.ServiceSentinelShared.Get(id); !ok {
+			return singleton.Localizer.ErrorT("service id %d does not exist", id)
+		}
+	}
+	if len(rf.ServerGroups) > 0 {
+		var count int64
+		if err := singleton.DB.Model(&model.ServerGroup{}).Where("id in (?)", rf.ServerGroups).Count(&count).Error; err != nil {
+			return newGormError("%v", err)
+		}
+		if int(count) != len(rf.ServerGroups) {
+			return singleton.Localizer.ErrorT("server group not found")
+		}
+	}
+	if rf.Target <= 0 || rf.Target > 100 {
+		return singleton.Localizer.ErrorT("target must be between 0 and 100")
+	}
+	switch rf.Period {
+	case model.SLAReportPeriodMonthly, model.SLAReportPeriodWeekly:
+	default:
+		return singleton.Localizer.ErrorT("unsupported period: %s", rf.Period)
+	}
+
+	cfg.Name = rf.Name
+	cfg.Services = rf.Services
+	cfg.ServerGroups = rf.ServerGroups
+	cfg.Target = rf.Target
+	cfg.Period = rf.Period
+	cfg.Enabled = rf.Enabled
+	cfg.WebhookURL = rf.WebhookURL
+	return nil
+}
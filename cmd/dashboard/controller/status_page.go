@@ -0,0 +1,391 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+// Get status page
+// @Summary Get status page
+// @Schemes
+// @Description Get public status page, only services and server groups added to the layout are visible
+// @Tags common
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.StatusPageResponse]
+// @Router /status-page [get]
+func getStatusPage(c *gin.Context) (*model.StatusPageResponse, error) {
+	sp, err := singleton.GetStatusPage()
+	if err != nil {
+		return nil, newGormError("%v", err)
+	}
+
+	c.Header("ETag", sp.ETag)
+	c.Header("Cache-Control", "public, max-age=60")
+	if c.GetHeader("If-None-Match") == sp.ETag {
+		c.Status(http.StatusNotModified)
+		return nil, errNoop
+	}
+	return sp.Response, nil
+}
+
+// List status page sections
+// @Summary List status page sections
+// @Security BearerAuth
+// @Schemes
+// @Description List status page sections
+// @Tags admin required
+// @Produce json
+// @Success 200 {object} model.CommonResponse[[]model.StatusPageSection]
+// @Router /status-page/section [get]
+func listStatusPageSection(c *gin.Context) ([]model.StatusPageSection, error) {
+	var sections []model.StatusPageSection
+	if err := singleton.DB.Order("display_index DESC, id").Find(&sections).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+	return sections, nil
+}
+
+// Add status page section
+// @Summary Add status page section
+// @Security BearerAuth
+// @Schemes
+// @Description Add status page section
+// @Tags admin required
+// @Accept json
+// @param request body model.StatusPageSectionForm true "StatusPageSectionForm"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[uint64]
+// @Router /status-page/section [post]
+func createStatusPageSection(c *gin.Context) (uint64, error) {
+	var sf model.StatusPageSectionForm
+	if err := c.ShouldBindJSON(&sf); err != nil {
+		return 0, err
+	}
+
+	var s model.StatusPageSection
+	s.UserID = getUid(c)
+	if err := applyStatusPageSectionForm(&s, &sf); err != nil {
+		return 0, err
+	}
+
+	if err := singleton.DB.Create(&s).Error; err != nil {
+		return 0, newGormError("%v", err)
+	}
+
+	singleton.InvalidateStatusPage()
+	return s.ID, nil
+}
+
+// Update status page section
+// @Summary Update status page section
+// @Security BearerAuth
+// @Schemes
+// @Description Update status page section
+// @Tags admin required
+// @Accept json
+// @param id path uint true "Section ID"
+// @param request body model.StatusPageSectionForm true "StatusPageSectionForm"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /status-page/section/{id} [patch]
+func updateStatusPageSection(c *gin.Context) (any, error) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	var sf model.StatusPageSectionForm
+	if err := c.ShouldBindJSON(&sf); err != nil {
+		return nil, err
+	}
+
+	var s model.StatusPageSection
+	if err := singleton.DB.First(&s, id).Error; err != nil {
+		return nil, singleton.Localizer.ErrorT("section id %d does not exist", id)
+	}
+	if err := applyStatusPageSectionForm(&s, &sf); err != nil {
+		return nil, err
+	}
+
+	if err := singleton.DB.Save(&s).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+
+	singleton.InvalidateStatusPage()
+	return nil, nil
+}
+
+// Batch delete status page sections
+// @Summary Batch delete status page sections
+// @Security BearerAuth
+// @Schemes
+// @Description Batch delete status page sections
+// @Tags admin required
+// @Accept json
+// @param request body []uint64 true "id list"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /batch-delete/status-page/section [post]
+func batchDeleteStatusPageSection(c *gin.Context) (any, error) {
+	var ids []uint64
+	if err := c.ShouldBindJSON(&ids); err != nil {
+		return nil, err
+	}
+
+	if err := singleton.DB.Unscoped().Delete(&model.StatusPageSection{}, "id in (?)", ids).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+
+	singleton.InvalidateStatusPage()
+	return nil, nil
+}
+
+func applyStatusPageSectionForm(s *model.StatusPageSection, sf *model.StatusPageSectionForm) error {
+	for _, id := range sf.Services {
+		if _, ok := singleton.ServiceSentinelShared.Get(id); !ok {
+			return singleton.Localizer.ErrorT("service id %d does not exist", id)
+		}
+	}
+	if len(sf.ServerGroups) > 0 {
+		var count int64
+		if err := singleton.DB.Model(&model.ServerGroup{}).Where("id in (?)", sf.ServerGroups).Count(&count).Error; err != nil {
+			return newGormError("%v", err)
+		}
+		if int(count) != len(sf.ServerGroups) {
+			return singleton.Localizer.ErrorT("server group not found")
+		}
+	}
+
+	s.Name = sf.Name
+	s.DisplayIndex = sf.DisplayIndex
+	s.Services = sf.Services
+	s.ServerGroups = sf.ServerGroups
+	return nil
+}
+
+// List incidents
+// @Summary List incidents
+// @Security BearerAuth
+// @Schemes
+// @Description List incidents with their timeline updates
+// @Tags admin required
+// @Produce json
+// @Success 200 {object} model.CommonResponse[[]model.Incident]
+// @Router /incident [get]
+func listIncident(c *gin.Context) ([]*model.Incident, error) {
+	incidents, err := singleton.ListIncidents(time.Time{})
+	if err != nil {
+		return nil, newGormError("%v", err)
+	}
+	return incidents, nil
+}
+
+// Add incident
+// @Summary Add incident
+// @Security BearerAuth
+// @Schemes
+// @Description Add incident
+// @Tags admin required
+// @Accept json
+// @param request body model.IncidentForm true "IncidentForm"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[uint64]
+// @Router /incident [post]
+func createIncident(c *gin.Context) (uint64, error) {
+	var inf model.IncidentForm
+	if err := c.ShouldBindJSON(&inf); err != nil {
+		return 0, err
+	}
+
+	var i model.Incident
+	i.UserID = getUid(c)
+	if err := applyIncidentForm(&i, &inf); err != nil {
+		return 0, err
+	}
+
+	if err := singleton.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&i).Error; err != nil {
+			return err
+		}
+		return tx.Create(&model.IncidentUpdate{
+			IncidentID: i.ID,
+			Status:     i.Status,
+			Body:       i.Body,
+		}).Error
+	}); err != nil {
+		return 0, newGormError("%v", err)
+	}
+
+	singleton.InvalidateStatusPage()
+	return i.ID, nil
+}
+
+// Update incident
+// @Summary Update incident
+// @Security BearerAuth
+// @Schemes
+// @Description Update incident
+// @Tags admin required
+// @Accept json
+// @param id path uint true "Incident ID"
+// @param request body model.IncidentForm true "IncidentForm"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /incident/{id} [patch]
+func updateIncident(c *gin.Context) (any, error) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	var inf model.IncidentForm
+	if err := c.ShouldBindJSON(&inf); err != nil {
+		return nil, err
+	}
+
+	var i model.Incident
+	if err := singleton.DB.First(&i, id).Error; err != nil {
+		return nil, singleton.Localizer.ErrorT("incident id %d does not exist", id)
+	}
+	if err := applyIncidentForm(&i, &inf); err != nil {
+		return nil, err
+	}
+
+	if err := singleton.DB.Save(&i).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+
+	singleton.InvalidateStatusPage()
+	return nil, nil
+}
+
+// Add incident timeline update
+// @Summary Add incident timeline update
+// @Security BearerAuth
+// @Schemes
+// @Description Add a timeline update to an incident, status resolved marks the incident as resolved
+// @Tags admin required
+// @Accept json
+// @param id path uint true "Incident ID"
+// @param request body model.IncidentUpdateForm true "IncidentUpdateForm"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[uint64]
+// @Router /incident/{id}/update [post]
+func createIncidentUpdate(c *gin.Context) (uint64, error) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	var uf model.IncidentUpdateForm
+	if err := c.ShouldBindJSON(&uf); err != nil {
+		return 0, err
+	}
+	if !isValidIncidentStatus(uf.Status) {
+		return 0, singleton.Localizer.ErrorT("invalid incident status")
+	}
+
+	var i model.Incident
+	if err := singleton.DB.First(&i, id).Error; err != nil {
+		return 0, singleton.Localizer.ErrorT("incident id %d does not exist", id)
+	}
+	setIncidentStatus(&i, uf.Status)
+
+	u := model.IncidentUpdate{
+		IncidentID: i.ID,
+		Status:     uf.Status,
+		Body:       uf.Body,
+	}
+	if err := singleton.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&i).Error; err != nil {
+			return err
+		}
+		return tx.Create(&u).Error
+	}); err != nil {
+		return 0, newGormError("%v", err)
+	}
+
+	singleton.InvalidateStatusPage()
+	return u.ID, nil
+}
+
+// Batch delete incidents
+// @Summary Batch delete incidents
+// @Security BearerAuth
+// @Schemes
+// @Description Batch delete incidents
+// @Tags admin required
+// @Accept json
+// @param request body []uint64 true "id list"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /batch-delete/incident [post]
+func batchDeleteIncident(c *gin.Context) (any, error) {
+	var ids []uint64
+	if err := c.ShouldBindJSON(&ids); err != nil {
+		return nil, err
+	}
+
+	if err := singleton.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Delete(&model.IncidentUpdate{}, "incident_id in (?)", ids).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&model.Incident{}, "id in (?)", ids).Error
+	}); err != nil {
+		return nil, newGormError("%v", err)
+	}
+
+	singleton.InvalidateStatusPage()
+	return nil, nil
+}
+
+func applyIncidentForm(i *model.Incident, inf *model.IncidentForm) error {
+	if inf.Status == "" {
+		inf.Status = model.IncidentStatusInvestigating
+	}
+	if !isValidIncidentStatus(inf.Status) {
+		return singleton.Localizer.ErrorT("invalid incident status")
+	}
+	if inf.Severity > model.IncidentSeverityMaintenance {
+		return singleton.Localizer.ErrorT("invalid incident severity")
+	}
+
+	i.Title = inf.Title
+	i.Body = inf.Body
+	i.Severity = inf.Severity
+	i.Services = inf.Services
+	i.ServerGroups = inf.ServerGroups
+	setIncidentStatus(i, inf.Status)
+	return nil
+}
+
+func setIncidentStatus(i *model.Incident, status string) {
+	i.Status = status
+	if status == model.IncidentStatusResolved {
+		if i.ResolvedAt == nil {
+			now := time.Now()
+			i.ResolvedAt = &now
+		}
+	} else {
+		i.ResolvedAt = nil
+	}
+}
+
+func isValidIncidentStatus(status string) bool {
+	switch status {
+	case model.IncidentStatusInvestigating, model.IncidentStatusIdentified,
+		model.IncidentStatusMonitoring, model.IncidentStatusResolved:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+var publicSlugPattern = regexp.MustCompile(`^[a-z0-9-]{1,32}$`)
+
+// tenantScope 严格租户模式下限定请求可以访问的资源：
+// 用户只能访问自己的资源，管理员指定 all_tenants 时不受限制；
+// 访客通过 tenant 参数访问指定用户的公开页面，未指定时为管理员的资源
+func tenantScope(c *gin.Context) {
+	if !singleton.Conf.StrictTenant {
+		c.Next()
+		return
+	}
+
+	auth, ok := c.Get(model.CtxKeyAuthorizedUser)
+	if !ok {
+		scope := singleton.AdminTenantScope()
+		if slug := c.Query("tenant"); slug != "" {
+			uid, ok := singleton.UserIDByPublicSlug(slug)
+			if !ok {
+				c.AbortWithStatusJSON(http.StatusNotFound, newErrorResponse(singleton.Localizer.ErrorT("tenant %s does not exist", slug)))
+				return
+			}
+			scope = model.TenantScope{uid}
+		}
+		c.Set(model.CtxKeyTenantScope, scope)
+		c.Next()
+		return
+	}
+
+	user := auth.(*model.User)
+	if user.Role == model.RoleAdmin && allTenants(c) {
+		c.Next()
+		return
+	}
+	c.Set(model.CtxKeyTenantScope, model.TenantScope{user.ID})
+	c.Next()
+}
+
+// allTenants 严格租户模式下请求是否要求查看全部租户的资源，这类请求会写入审计日志
+func allTenants(c *gin.Context) bool {
+	if !singleton.Conf.StrictTenant {
+		return false
+	}
+	v, _ := strconv.ParseBool(c.Query("all_tenants"))
+	return v
+}
+
+// tenantQuery 严格租户模式下只查询租户范围内用户的记录
+func tenantQuery(c *gin.Context, db *gorm.DB) *gorm.DB {
+	if scope, ok := model.GetTenantScope(c); ok {
+		return db.Where("user_id IN (?)", []uint64(scope))
+	}
+	return db
+}
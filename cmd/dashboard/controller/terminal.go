@@ -1,12 +1,17 @@
 package controller
 
 import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/goccy/go-json"
 	"github.com/gorilla/websocket"
 	"github.com/hashicorp/go-uuid"
+	"gorm.io/gorm"
 
 	"github.com/nezhahq/nezha/model"
 	"github.com/nezhahq/nezha/pkg/websocketx"
@@ -35,8 +40,11 @@ func createTerminal(c *gin.Context) (*model.CreateTerminalResponse, error) {
 		return nil, singleton.Localizer.ErrorT("server not found or not connected")
 	}
 
-	if !server.HasPermission(c) {
-		return nil, singleton.Localizer.ErrorT("permission denied")
+	if err := checkServerAccess(c, server, model.AccessTerminal); err != nil {
+		return nil, err
+	}
+	if err := singleton.CheckCapability(server, model.AgentCapabilityTerminal); err != nil {
+		return nil, err
 	}
 
 	streamId, err := uuid.GenerateUUID()
@@ -45,6 +53,9 @@ func createTerminal(c *gin.Context) (*model.CreateTerminalResponse, error) {
 	}
 
 	rpc.NezhaHandlerSingleton.CreateStream(streamId)
+	rpc.NezhaHandlerSingleton.SetStreamOwner(streamId, getUid(c), server.ID)
+	session := singleton.OpenTerminalSession(getUid(c), c.GetString(model.CtxKeyRealIPStr), server)
+	rpc.NezhaHandlerSingleton.RecordStream(streamId, session)
 
 	terminalData, _ := json.Marshal(&model.TerminalTask{
 		StreamID: streamId,
@@ -53,6 +64,7 @@ func createTerminal(c *gin.Context) (*model.CreateTerminalResponse, error) {
 		Type: model.TaskTypeTerminalGRPC,
 		Data: string(terminalData),
 	}); err != nil {
+		rpc.NezhaHandlerSingleton.CloseStream(streamId)
 		return nil, err
 	}
 
@@ -60,6 +72,7 @@ func createTerminal(c *gin.Context) (*model.CreateTerminalResponse, error) {
 		SessionID:  streamId,
 		ServerID:   server.ID,
 		ServerName: server.Name,
+		Recording:  session.Recording(),
 	}, nil
 }
 
@@ -72,7 +85,7 @@ func createTerminal(c *gin.Context) (*model.CreateTerminalResponse, error) {
 // @Router /ws/terminal/{id} [get]
 func terminalStream(c *gin.Context) (any, error) {
 	streamId := c.Param("id")
-	if _, err := rpc.NezhaHandlerSingleton.GetStream(streamId); err != nil {
+	if err := checkStreamAccess(c, streamId, model.AccessTerminal); err != nil {
 		return nil, err
 	}
 	defer rpc.NezhaHandlerSingleton.CloseStream(streamId)
@@ -82,6 +95,7 @@ func terminalStream(c *gin.Context) (any, error) {
 		return nil, newWsError("%v", err)
 	}
 	defer wsConn.Close()
+	defer singleton.SessionShared.TrackConn(c.GetString(model.CtxKeySessionID), wsConn)()
 	conn := websocketx.NewConn(wsConn)
 
 	go func() {
@@ -104,3 +118,113 @@ func terminalStream(c *gin.Context) (any, error) {
 
 	return nil, newWsError("")
 }
+
+// List terminal recordings
+// @Summary List terminal recordings
+// @Security BearerAuth
+// @Schemes
+// @Description List recorded web terminal sessions
+// @Tags admin required
+// @Param server_id query uint false "Server ID"
+// @Param user_id query uint false "User ID"
+// @Param limit query uint false "Page limit"
+// @Param offset query uint false "Page offset"
+// @Produce json
+// @Success 200 {object} model.PaginatedResponse[[]model.TerminalRecording, model.TerminalRecording]
+// @Router /terminal-recording [get]
+func listTerminalRecording(c *gin.Context) (*model.Value[[]*model.TerminalRecording], error) {
+	if user, _ := c.MustGet(model.CtxKeyAuthorizedUser).(*model.User); user.Role != model.RoleAdmin {
+		return nil, singleton.Localizer.ErrorT("permission denied")
+	}
+
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit < 1 {
+		limit = 25
+	}
+	offset, err := strconv.Atoi(c.Query("offset"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	query := singleton.DB.Model(&model.TerminalRecording{})
+	for _, key := range []string{"server_id", "user_id"} {
+		if v := c.Query(key); v != "" {
+			id, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				return nil, singleton.Localizer.ErrorT("invalid %s: %s", key, v)
+			}
+			query = query.Where(key+" = ?", id)
+		}
+	}
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+	var recordings []*model.TerminalRecording
+	if err := query.Order("id DESC").Limit(limit).Offset(offset).Find(&recordings).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+
+	return &model.Value[[]*model.TerminalRecording]{
+		Value: recordings,
+		Pagination: model.Pagination{
+			Offset: offset,
+			Limit:  limit,
+			Total:  total,
+		},
+	}, nil
+}
+
+// Download terminal recording
+// @Summary Download terminal recording
+// @Security BearerAuth
+// @Schemes
+// @Description Download a terminal recording in asciicast v2 format
+// @Tags admin required
+// @Param id path uint true "Recording ID"
+// @Produce octet-stream
+// @Success 200 {file} file
+// @Router /terminal-recording/{id} [get]
+func downloadTerminalRecording(c *gin.Context) (any, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	rec, path, err := singleton.TerminalRecordingPath(id)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(path); err != nil {
+		return nil, singleton.Localizer.ErrorT("recording id %d does not exist", id)
+	}
+
+	log.Printf("NEZHA>> User %d downloaded terminal recording %d", getUid(c), id)
+	c.FileAttachment(path, fmt.Sprintf("nezha-terminal-%d-%s.cast", rec.ServerID, rec.CreatedAt.Format("20060102-150405")))
+	return nil, errNoop
+}
+
+// Batch delete terminal recordings
+// @Summary Batch delete terminal recordings
+// @Security BearerAuth
+// @Schemes
+// @Description Batch delete terminal recordings
+// @Tags admin required
+// @Accept json
+// @param request body []uint64 true "id list"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /batch-delete/terminal-recording [post]
+func batchDeleteTerminalRecording(c *gin.Context) (any, error) {
+	var ids []uint64
+	if err := c.ShouldBindJSON(&ids); err != nil {
+		return nil, err
+	}
+
+	if err := singleton.DeleteTerminalRecordings(ids); err != nil {
+		return nil, newGormError("%v", err)
+	}
+	return nil, nil
+}
@@ -0,0 +1,257 @@
+package controller
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/gin-gonic/gin"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/utils"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+var errTOTPRequired = errors.New("ApiErrorTOTPRequired")
+
+// verifySecondFactor 校验验证码或恢复码，失败次数计入 WAF
+func verifySecondFactor(c *gin.Context, user *model.User, code string) error {
+	realip := c.GetString(model.CtxKeyRealIPStr)
+
+	updates := make(map[string]any)
+	if step, ok := utils.ValidateTOTP(user.TOTPSecret, code, time.Now()); ok && step > user.TOTPLastStep {
+		user.TOTPLastStep = step
+		updates["totp_last_step"] = step
+	} else if user.UseRecoveryCode(code) {
+		updates["recovery_codes_raw"] = user.RecoveryCodesRaw
+		log.Printf("NEZHA>> User %d used a recovery code", user.ID)
+	} else {
+		model.BlockIP(singleton.DB, realip, model.WAFBlockReasonTypeBruteForceOTP, int64(user.ID))
+		return singleton.Localizer.ErrorT("invalid verification code")
+	}
+
+	if err := singleton.DB.Model(&model.User{}).Where("id = ?", user.ID).Updates(updates).Error; err != nil {
+		return newGormError("%v", err)
+	}
+	model.UnblockIP(singleton.DB, realip, int64(user.ID))
+	return nil
+}
+
+// requireMFA 已启用两步验证的用户访问敏感接口时，要求当前会话通过了两步验证
+func requireMFA(c *gin.Context) {
+	user, _ := c.MustGet(model.CtxKeyAuthorizedUser).(*model.User)
+	if !user.TOTPEnabled {
+		c.Next()
+		return
+	}
+
+	// API Token 无法完成两步验证
+	if _, ok := c.Get(model.CtxKeyAPIToken); !ok {
+		if mfa, _ := jwt.ExtractClaims(c)[jwtClaimMFA].(bool); mfa {
+			c.Next()
+			return
+		}
+	}
+
+	c.AbortWithStatusJSON(http.StatusOK, model.CommonResponse[any]{
+		Success: false,
+		Error:   "ApiErrorMFARequired",
+	})
+}
+
+// Enroll TOTP
+// @Summary Enroll TOTP
+// @Security BearerAuth
+// @Schemes
+// @Description Generate a TOTP secret for the current user, it takes effect after activation
+// @Tags auth required
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.TOTPEnrollResponse]
+// @Router /user/2fa/enroll [post]
+func enrollTOTP(c *gin.Context) (*model.TOTPEnrollResponse, error) {
+	if err := rejectAPIToken(c); err != nil {
+		return nil, err
+	}
+
+	user := c.MustGet(model.CtxKeyAuthorizedUser).(*model.User)
+	if user.TOTPEnabled {
+		return nil, singleton.Localizer.ErrorT("two-factor authentication is already enabled")
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := singleton.DB.Model(&model.User{}).Where("id = ?", user.ID).Updates(&model.User{TOTPSecret: secret}).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+
+	return &model.TOTPEnrollResponse{
+		Secret: secret,
+		URI:    utils.TOTPURI(singleton.Conf.SiteName, user.Username, secret),
+	}, nil
+}
+
+// Activate TOTP
+// @Summary Activate TOTP
+// @Security BearerAuth
+// @Schemes
+// @Description Activate TOTP with a code from the authenticator, returns recovery codes which are only shown once
+// @Tags auth required
+// @Accept json
+// @param request body model.TOTPCodeForm true "TOTPCodeForm"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.TOTPActivateResponse]
+// @Router /user/2fa/activate [post]
+func activateTOTP(c *gin.Context) (*model.TOTPActivateResponse, error) {
+	if err := rejectAPIToken(c); err != nil {
+		return nil, err
+	}
+
+	var cf model.TOTPCodeForm
+	if err := c.ShouldBindJSON(&cf); err != nil {
+		return nil, err
+	}
+
+	user := *c.MustGet(model.CtxKeyAuthorizedUser).(*model.User)
+	if user.TOTPEnabled {
+		return nil, singleton.Localizer.ErrorT("two-factor authentication is already enabled")
+	}
+	if user.TOTPSecret == "" {
+		return nil, singleton.Localizer.ErrorT("two-factor authentication is not enrolled")
+	}
+
+	step, ok := utils.ValidateTOTP(user.TOTPSecret, cf.Code, time.Now())
+	if !ok {
+		model.BlockIP(singleton.DB, c.GetString(model.CtxKeyRealIPStr), model.WAFBlockReasonTypeBruteForceOTP, int64(user.ID))
+		return nil, singleton.Localizer.ErrorT("invalid verification code")
+	}
+
+	codes, err := user.GenerateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := singleton.DB.Model(&model.User{}).Where("id = ?", user.ID).Updates(map[string]any{
+		"totp_enabled":       true,
+		"totp_last_step":     step,
+		"recovery_codes_raw": user.RecoveryCodesRaw,
+	}).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+	// API Token 缓存了用户，需要随两步验证状态刷新
+	singleton.OnUserUpdate(&user)
+
+	log.Printf("NEZHA>> User %d enabled two-factor authentication", user.ID)
+	return &model.TOTPActivateResponse{RecoveryCodes: codes}, nil
+}
+
+// Verify TOTP
+// @Summary Verify TOTP
+// @Security BearerAuth
+// @Schemes
+// @Description Verify a code for the current session, returns a new token that satisfies two-factor authentication
+// @Tags auth required
+// @Accept json
+// @param request body model.TOTPCodeForm true "TOTPCodeForm"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.LoginResponse]
+// @Router /user/2fa/verify [post]
+func verifyTOTP(mw *jwt.GinJWTMiddleware) func(c *gin.Context) (*model.LoginResponse, error) {
+	return func(c *gin.Context) (*model.LoginResponse, error) {
+		if err := rejectAPIToken(c); err != nil {
+			return nil, err
+		}
+
+		var cf model.TOTPCodeForm
+		if err := c.ShouldBindJSON(&cf); err != nil {
+			return nil, err
+		}
+
+		user := *c.MustGet(model.CtxKeyAuthorizedUser).(*model.User)
+		if !user.TOTPEnabled {
+			return nil, singleton.Localizer.ErrorT("two-factor authentication is not enabled")
+		}
+		if err := verifySecondFactor(c, &user, cf.Code); err != nil {
+			return nil, err
+		}
+
+		token, expire, err := mw.TokenGenerator(jwtIdentity{UserID: utils.Itoa(user.ID), MFA: true, SessionID: c.GetString(model.CtxKeySessionID)})
+		if err != nil {
+			return nil, err
+		}
+		mw.SetCookie(c, token)
+
+		return &model.LoginResponse{
+			Token:  token,
+			Expire: expire.Format(time.RFC3339),
+		}, nil
+	}
+}
+
+// Disable TOTP
+// @Summary Disable TOTP
+// @Security BearerAuth
+// @Schemes
+// @Description Disable TOTP for the current user
+// @Tags auth required
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /user/2fa [delete]
+func disableTOTP(c *gin.Context) (any, error) {
+	if err := rejectAPIToken(c); err != nil {
+		return nil, err
+	}
+
+	user := *c.MustGet(model.CtxKeyAuthorizedUser).(*model.User)
+	if err := resetTOTP(&user); err != nil {
+		return nil, err
+	}
+
+	log.Printf("NEZHA>> User %d disabled two-factor authentication", user.ID)
+	return nil, nil
+}
+
+// Reset TOTP
+// @Summary Reset TOTP
+// @Security BearerAuth
+// @Schemes
+// @Description Reset TOTP of a user
+// @Tags admin required
+// @param id path uint true "User ID"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /user/{id}/2fa/reset [post]
+func resetUserTOTP(c *gin.Context) (any, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	var user model.User
+	if err := singleton.DB.First(&user, id).Error; err != nil {
+		return nil, singleton.Localizer.ErrorT("user id %d does not exist", id)
+	}
+	if err := resetTOTP(&user); err != nil {
+		return nil, err
+	}
+
+	log.Printf("NEZHA>> User %d reset two-factor authentication of user %d", getUid(c), id)
+	return nil, nil
+}
+
+func resetTOTP(user *model.User) error {
+	user.ResetTOTP()
+	if err := singleton.DB.Model(&model.User{}).Where("id = ?", user.ID).Updates(map[string]any{
+		"totp_enabled":       false,
+		"totp_secret":        "",
+		"totp_last_step":     0,
+		"recovery_codes_raw": "",
+	}).Error; err != nil {
+		return newGormError("%v", err)
+	}
+	singleton.OnUserUpdate(user)
+	return nil
+}
@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"log"
 	"slices"
 	"strconv"
 
@@ -34,10 +35,16 @@ func getProfile(c *gin.Context) (*model.Profile, error) {
 	for _, v := range ob {
 		obMap[v.Provider] = v.OpenID
 	}
+	user := auth.(*model.User)
+	display := singleton.Conf.Display
+	if user.DisplayPreferences != nil {
+		display = *user.DisplayPreferences
+	}
 	return &model.Profile{
-		User:       *auth.(*model.User),
+		User:       *user,
 		LoginIP:    c.GetString(model.CtxKeyRealIPStr),
 		Oauth2Bind: obMap,
+		Display:    display,
 	}, nil
 }
 
@@ -82,9 +89,31 @@ func updateProfile(c *gin.Context) (any, error) {
 		return nil, singleton.Localizer.ErrorT("you don't have any oauth2 bindings")
 	}
 
+	if !singleton.ValidLanguage(pf.Language) {
+		return nil, singleton.Localizer.ErrorT("unsupported language: %s", pf.Language)
+	}
+
+	if pf.PublicSlug != "" {
+		if !publicSlugPattern.MatchString(pf.PublicSlug) {
+			return nil, singleton.Localizer.ErrorT("public slug must be 1-32 lowercase letters, digits or hyphens")
+		}
+		if uid, ok := singleton.UserIDByPublicSlug(pf.PublicSlug); ok && uid != user.ID {
+			return nil, singleton.Localizer.ErrorT("public slug %s is already in use", pf.PublicSlug)
+		}
+	}
+
+	if pf.DisplayPreferences != nil {
+		if err := pf.DisplayPreferences.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
 	user.Username = pf.NewUsername
 	user.Password = string(hash)
 	user.RejectPassword = pf.RejectPassword
+	user.Language = singleton.NormalizeLanguage(pf.Language)
+	user.PublicSlug = pf.PublicSlug
+	user.DisplayPreferences = pf.DisplayPreferences
 	if err := singleton.DB.Save(&user).Error; err != nil {
 		return nil, newGormError("%v", err)
 	}
@@ -133,7 +162,7 @@ func createUser(c *gin.Context) (uint64, error) {
 	if uf.Username == "" {
 		return 0, singleton.Localizer.ErrorT("username can't be empty")
 	}
-	if uf.Role != model.RoleAdmin && uf.Role != model.RoleMember {
+	if !model.ValidRole(uf.Role) {
 		return 0, singleton.Localizer.ErrorT("invalid role")
 	}
 
@@ -155,6 +184,49 @@ func createUser(c *gin.Context) (uint64, error) {
 	return u.ID, nil
 }
 
+// Update user role
+// @Summary Update user role
+// @Security BearerAuth
+// @Schemes
+// @Description Update user role
+// @Tags admin required
+// @Accept json
+// @param id path uint true "User ID"
+// @param request body model.UserRoleForm true "User Role Request"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /user/{id}/role [patch]
+func updateUserRole(c *gin.Context) (any, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	var rf model.UserRoleForm
+	if err := c.ShouldBindJSON(&rf); err != nil {
+		return nil, err
+	}
+	if !model.ValidRole(rf.Role) {
+		return nil, singleton.Localizer.ErrorT("invalid role")
+	}
+	if id == getUid(c) {
+		return nil, singleton.Localizer.ErrorT("you can't change your own role")
+	}
+
+	var u model.User
+	if err := singleton.DB.First(&u, id).Error; err != nil {
+		return nil, singleton.Localizer.ErrorT("user id %d does not exist", id)
+	}
+	u.Role = rf.Role
+	if err := singleton.DB.Model(&u).Update("role", rf.Role).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+
+	singleton.OnUserUpdate(&u)
+	log.Printf("NEZHA>> User %d changed role of user %d to %d", getUid(c), id, rf.Role)
+	return nil, nil
+}
+
 // Batch delete users
 // @Summary Batch delete users
 // @Security BearerAuth
@@ -1,9 +1,12 @@
 package controller
 
 import (
+	"bytes"
 	"net"
+	"net/netip"
 	"slices"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
@@ -52,6 +55,7 @@ func listBlockedAddress(c *gin.Context) (*model.Value[[]*model.WAFApiMock], erro
 				BlockReason:     e.BlockReason,
 				BlockTimestamp:  e.BlockTimestamp,
 				Count:           e.Count,
+				ExpiresAt:       e.ExpiresAt,
 			}
 		})),
 		Pagination: model.Pagination{
@@ -85,3 +89,198 @@ func batchDeleteBlockedAddress(c *gin.Context) (any, error) {
 
 	return nil, nil
 }
+
+// List WAF blocks
+// @Summary List WAF blocks
+// @Security BearerAuth
+// @Schemes
+// @Description List manual blocks (newest first) followed by automatic blocks
+// @Tags admin required
+// @Param reason query uint false "Block reason"
+// @Param ip query string false "Only blocks overlapping this IP or CIDR"
+// @Param status query string false "active or expired" Enums(active, expired)
+// @Param limit query uint false "Page limit"
+// @Param offset query uint false "Page offset"
+// @Produce json
+// @Success 200 {object} model.PaginatedResponse[[]model.WAFBlockEntry, model.WAFBlockEntry]
+// @Router /waf/blocks [get]
+func listWAFBlock(c *gin.Context) (*model.Value[[]*model.WAFBlockEntry], error) {
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit < 1 {
+		limit = 25
+	}
+	offset, err := strconv.Atoi(c.Query("offset"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	now := time.Now()
+	manualQuery := singleton.DB.Model(&model.WAFBlock{})
+	autoQuery := singleton.DB.Model(&model.WAF{})
+	if reason := c.Query("reason"); reason != "" {
+		r, err := strconv.ParseUint(reason, 10, 8)
+		if err != nil {
+			return nil, err
+		}
+		if uint8(r) != model.WAFBlockReasonTypeManual {
+			manualQuery = manualQuery.Where("1 = 0")
+		}
+		autoQuery = autoQuery.Where("block_reason = ?", r)
+	}
+	if ip := c.Query("ip"); ip != "" {
+		_, start, end, err := model.ParseBlockRange(ip)
+		if err != nil {
+			return nil, singleton.Localizer.ErrorT("invalid ip or cidr: %v", err)
+		}
+		manualQuery = manualQuery.Where("range_start <= ? AND range_end >= ?", end, start)
+		autoQuery = autoQuery.Where("ip >= ? AND ip <= ?", start, end)
+	}
+	switch c.Query("status") {
+	case "":
+	case "active":
+		manualQuery = manualQuery.Where("expires_at IS NULL OR expires_at > ?", now)
+		autoQuery = autoQuery.Where("expires_at = 0 OR expires_at > ?", now.Unix())
+	case "expired":
+		manualQuery = manualQuery.Where("expires_at <= ?", now)
+		autoQuery = autoQuery.Where("expires_at <> 0 AND expires_at <= ?", now.Unix())
+	default:
+		return nil, singleton.Localizer.ErrorT("invalid status")
+	}
+
+	var manualTotal, autoTotal int64
+	if err := manualQuery.Count(&manualTotal).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+	if err := autoQuery.Count(&autoTotal).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+
+	// 手动封禁在前，分页跨越两类记录
+	entries := make([]*model.WAFBlockEntry, 0, limit)
+	if int64(offset) < manualTotal {
+		var blocks []*model.WAFBlock
+		if err := manualQuery.Order("id DESC").Limit(limit).Offset(offset).Find(&blocks).Error; err != nil {
+			return nil, newGormError("%v", err)
+		}
+		for _, b := range blocks {
+			entries = append(entries, &model.WAFBlockEntry{
+				ID:          b.ID,
+				IP:          b.CIDR,
+				Manual:      true,
+				BlockReason: model.WAFBlockReasonTypeManual,
+				Note:        b.Note,
+				CreatedAt:   b.CreatedAt,
+				ExpiresAt:   b.ExpiresAt,
+				Active:      b.Active(now),
+			})
+		}
+	}
+	if rest := limit - len(entries); rest > 0 {
+		var waf []*model.WAF
+		if err := autoQuery.Order("block_timestamp DESC").Limit(rest).Offset(max(offset-int(manualTotal), 0)).Find(&waf).Error; err != nil {
+			return nil, newGormError("%v", err)
+		}
+		for _, w := range waf {
+			var expiresAt *time.Time
+			if w.ExpiresAt != 0 {
+				t := time.Unix(int64(w.ExpiresAt), 0)
+				expiresAt = &t
+			}
+			entries = append(entries, &model.WAFBlockEntry{
+				IP:              utils.BinaryToIPString(w.IP),
+				Manual:          w.BlockReason == model.WAFBlockReasonTypeManual,
+				BlockIdentifier: w.BlockIdentifier,
+				BlockReason:     w.BlockReason,
+				Count:           w.Count,
+				CreatedAt:       time.Unix(int64(w.BlockTimestamp), 0),
+				ExpiresAt:       expiresAt,
+				Active:          w.Active(now),
+			})
+		}
+	}
+
+	return &model.Value[[]*model.WAFBlockEntry]{
+		Value: entries,
+		Pagination: model.Pagination{
+			Offset: offset,
+			Limit:  limit,
+			Total:  manualTotal + autoTotal,
+		},
+	}, nil
+}
+
+// Add WAF block
+// @Summary Add WAF block
+// @Security BearerAuth
+// @Schemes
+// @Description Manually block an IP or CIDR, optionally until a point in time
+// @Tags admin required
+// @Accept json
+// @Param request body model.WAFBlockForm true "Block Request"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.WAFBlock]
+// @Router /waf/blocks [post]
+func createWAFBlock(c *gin.Context) (*model.WAFBlock, error) {
+	var bf model.WAFBlockForm
+	if err := c.ShouldBindJSON(&bf); err != nil {
+		return nil, err
+	}
+	cidr, start, end, err := model.ParseBlockRange(bf.CIDR)
+	if err != nil {
+		return nil, singleton.Localizer.ErrorT("invalid ip or cidr: %v", err)
+	}
+	if bf.ExpiresAt != nil && !bf.ExpiresAt.After(time.Now()) {
+		return nil, singleton.Localizer.ErrorT("expiry must be in the future")
+	}
+
+	// 避免管理员封禁自己当前的地址
+	if ip, err := utils.IPStringToBinary(c.GetString(model.CtxKeyRealIPStr)); err == nil &&
+		bytes.Compare(start, ip) <= 0 && bytes.Compare(end, ip) >= 0 {
+		return nil, singleton.Localizer.ErrorT("you can not block your own address")
+	}
+
+	b := &model.WAFBlock{
+		UserID:     getUid(c),
+		CIDR:       cidr,
+		RangeStart: start,
+		RangeEnd:   end,
+		Note:       bf.Note,
+		ExpiresAt:  bf.ExpiresAt,
+	}
+	if err := singleton.DB.Create(b).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+	return b, nil
+}
+
+// Delete WAF block
+// @Summary Delete WAF block
+// @Security BearerAuth
+// @Schemes
+// @Description Remove a manual block by ID, or all automatic blocks of an IP address
+// @Tags admin required
+// @Param id path string true "Manual block ID or IP address"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /waf/blocks/{id} [delete]
+func deleteWAFBlock(c *gin.Context) (any, error) {
+	param := c.Param("id")
+	if id, err := strconv.ParseUint(param, 10, 64); err == nil {
+		result := singleton.DB.Delete(&model.WAFBlock{}, id)
+		if result.Error != nil {
+			return nil, newGormError("%v", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return nil, singleton.Localizer.ErrorT("block id %d does not exist", id)
+		}
+		return nil, nil
+	}
+
+	if _, err := netip.ParseAddr(param); err != nil {
+		return nil, singleton.Localizer.ErrorT("invalid ip or cidr: %v", err)
+	}
+	if err := model.BatchUnblockIP(singleton.DB, []string{param}); err != nil {
+		return nil, newGormError("%v", err)
+	}
+	return nil, nil
+}
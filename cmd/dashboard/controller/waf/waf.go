@@ -8,31 +8,16 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"github.com/nezhahq/nezha/model"
-	"github.com/nezhahq/nezha/pkg/utils"
 	"github.com/nezhahq/nezha/service/singleton"
 )
 
 //go:embed waf.html
 var errorPageTemplate string
 
+// RealIp 解析请求的真实 IP，未配置真实 IP 请求头时使用连接地址
 func RealIp(c *gin.Context) {
-	if singleton.Conf.WebRealIPHeader == "" {
-		c.Next()
-		return
-	}
-
-	if singleton.Conf.WebRealIPHeader == model.ConfigUsePeerIP {
-		c.Set(model.CtxKeyRealIPStr, c.RemoteIP())
-		c.Next()
-		return
-	}
-
-	vals := c.Request.Header.Get(singleton.Conf.WebRealIPHeader)
-	if vals == "" {
-		c.AbortWithStatusJSON(http.StatusOK, model.CommonResponse[any]{Success: false, Error: "real ip header not found"})
-		return
-	}
-	ip, err := utils.GetIPFromHeader(vals)
+	singleton.CheckForwardedHeader(c.Request.Header, c.Request.RemoteAddr)
+	ip, err := RequestRealIP(c.Request)
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusOK, model.CommonResponse[any]{Success: false, Error: err.Error()})
 		return
@@ -41,8 +26,22 @@ func RealIp(c *gin.Context) {
 	c.Next()
 }
 
+// RequestRealIP 获取请求的真实 IP，未配置真实 IP 请求头时使用连接 IP
+func RequestRealIP(r *http.Request) (string, error) {
+	header := singleton.Conf.WebRealIPHeader
+	if header == "" {
+		header = model.ConfigUsePeerIP
+	}
+	return singleton.RealIP(header, r.RemoteAddr, r.Header.Get)
+}
+
 func Waf(c *gin.Context) {
-	if err := model.CheckIP(singleton.DB, c.GetString(model.CtxKeyRealIPStr)); err != nil {
+	ip := c.GetString(model.CtxKeyRealIPStr)
+	if err := model.CheckIP(singleton.DB, ip); err != nil {
+		ShowBlockPage(c, err)
+		return
+	}
+	if err := singleton.WAFRuleShared.Check(ip, model.WAFSurfaceHTTP); err != nil {
 		ShowBlockPage(c, err)
 		return
 	}
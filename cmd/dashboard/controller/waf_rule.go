@@ -0,0 +1,189 @@
+package controller
+
+import (
+	"net/netip"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+// List WAF rules
+// @Summary List WAF rules
+// @Security BearerAuth
+// @Schemes
+// @Description List CIDR and country rules with their hit counters
+// @Tags admin required
+// @Produce json
+// @Success 200 {object} model.CommonResponse[[]model.WAFRule]
+// @Router /waf/rules [get]
+func listWAFRule(c *gin.Context) ([]*model.WAFRule, error) {
+	var rules []*model.WAFRule
+	if err := singleton.DB.Order("id").Find(&rules).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+	for _, r := range rules {
+		r.Hits += singleton.WAFRuleShared.Hits(r.ID)
+	}
+	return rules, nil
+}
+
+// Add WAF rule
+// @Summary Add WAF rule
+// @Security BearerAuth
+// @Schemes
+// @Description Add a CIDR or country rule. A request matching any allow rule is let through; otherwise a matching deny rule blocks it, and when allow rules exist requests matching none of them are blocked as well.
+// @Tags admin required
+// @Accept json
+// @Param request body model.WAFRuleForm true "Rule Request"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[uint64]
+// @Router /waf/rules [post]
+func createWAFRule(c *gin.Context) (uint64, error) {
+	var rf model.WAFRuleForm
+	if err := c.ShouldBindJSON(&rf); err != nil {
+		return 0, err
+	}
+
+	var r model.WAFRule
+	if err := applyWAFRuleForm(&r, &rf); err != nil {
+		return 0, err
+	}
+	r.UserID = getUid(c)
+	if err := checkWAFSelfLockout(c, func(rules map[uint64]*model.WAFRule) {
+		rules[0] = &r
+	}); err != nil {
+		return 0, err
+	}
+	if err := singleton.DB.Create(&r).Error; err != nil {
+		return 0, newGormError("%v", err)
+	}
+	singleton.WAFRuleShared.Reload()
+	singleton.ClusterShared.PublishChange("waf/rules")
+	return r.ID, nil
+}
+
+// Edit WAF rule
+// @Summary Edit WAF rule
+// @Security BearerAuth
+// @Schemes
+// @Description Edit WAF rule, the hit counter is kept
+// @Tags admin required
+// @Accept json
+// @Param id path uint true "Rule ID"
+// @Param request body model.WAFRuleForm true "Rule Request"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /waf/rules/{id} [patch]
+func updateWAFRule(c *gin.Context) (any, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	var rf model.WAFRuleForm
+	if err := c.ShouldBindJSON(&rf); err != nil {
+		return nil, err
+	}
+
+	var r model.WAFRule
+	if err := singleton.DB.First(&r, id).Error; err != nil {
+		return nil, singleton.Localizer.ErrorT("rule id %d does not exist", id)
+	}
+	if err := applyWAFRuleForm(&r, &rf); err != nil {
+		return nil, err
+	}
+	if err := checkWAFSelfLockout(c, func(rules map[uint64]*model.WAFRule) {
+		rules[r.ID] = &r
+	}); err != nil {
+		return nil, err
+	}
+	if err := singleton.DB.Omit("hits").Save(&r).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+	singleton.WAFRuleShared.Reload()
+	singleton.ClusterShared.PublishChange("waf/rules")
+	return nil, nil
+}
+
+// Batch delete WAF rules
+// @Summary Batch delete WAF rules
+// @Security BearerAuth
+// @Schemes
+// @Description Batch delete WAF rules
+// @Tags admin required
+// @Accept json
+// @param request body []uint64 true "id list"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /batch-delete/waf/rules [post]
+func batchDeleteWAFRule(c *gin.Context) (any, error) {
+	var ids []uint64
+	if err := c.ShouldBindJSON(&ids); err != nil {
+		return nil, err
+	}
+
+	if err := checkWAFSelfLockout(c, func(rules map[uint64]*model.WAFRule) {
+		for _, id := range ids {
+			delete(rules, id)
+		}
+	}); err != nil {
+		return nil, err
+	}
+	if err := singleton.DB.Unscoped().Delete(&model.WAFRule{}, "id in (?)", ids).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+	singleton.WAFRuleShared.Reload()
+	singleton.ClusterShared.PublishChange("waf/rules")
+	return nil, nil
+}
+
+// checkWAFSelfLockout 拒绝会拦截当前管理员访问面板的修改
+func checkWAFSelfLockout(c *gin.Context, change func(rules map[uint64]*model.WAFRule)) error {
+	ip := c.GetString(model.CtxKeyRealIPStr)
+	if err := singleton.WAFRuleShared.PreviewCheck(ip, model.WAFSurfaceHTTP, change); err != nil {
+		return singleton.Localizer.ErrorT("this change would block your own address %s", ip)
+	}
+	return nil
+}
+
+func applyWAFRuleForm(r *model.WAFRule, rf *model.WAFRuleForm) error {
+	if rf.Action != model.WAFRuleAllow && rf.Action != model.WAFRuleDeny {
+		return singleton.Localizer.ErrorT("invalid action")
+	}
+	if rf.Surface > model.WAFSurfaceGRPC {
+		return singleton.Localizer.ErrorT("invalid surface")
+	}
+
+	cidrs := make([]string, 0, len(rf.CIDRs))
+	for _, s := range rf.CIDRs {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			addr, err := netip.ParseAddr(s)
+			if err != nil {
+				return singleton.Localizer.ErrorT("invalid ip or cidr: %v", err)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		cidrs = append(cidrs, prefix.Masked().String())
+	}
+	countries := model.NormalizeCountries(rf.Countries)
+	for _, country := range countries {
+		if len(country) != 2 {
+			return singleton.Localizer.ErrorT("invalid country code: %s", country)
+		}
+	}
+	if len(cidrs) == 0 && len(countries) == 0 {
+		return singleton.Localizer.ErrorT("a rule needs at least one cidr or country")
+	}
+
+	r.Name = rf.Name
+	r.Action = rf.Action
+	r.Surface = rf.Surface
+	r.Enabled = rf.Enabled
+	r.CIDRs = cidrs
+	r.Countries = countries
+	return nil
+}
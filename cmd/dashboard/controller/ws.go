@@ -2,9 +2,12 @@ package controller
 
 import (
 	"fmt"
+	"math"
 	"net"
 	"net/http"
 	"net/url"
+	"slices"
+	"strconv"
 	"time"
 	"unicode/utf8"
 
@@ -14,6 +17,7 @@ import (
 	"github.com/hashicorp/go-uuid"
 	"golang.org/x/sync/singleflight"
 
+	"github.com/nezhahq/nezha/cmd/dashboard/controller/waf"
 	"github.com/nezhahq/nezha/model"
 	"github.com/nezhahq/nezha/pkg/utils"
 	"github.com/nezhahq/nezha/service/singleton"
@@ -100,10 +104,22 @@ func checkSameOrigin(r *http.Request) bool {
 // @Schemes
 // @Description Websocket server stream
 // @security BearerAuth
+// @Param mode query string false "summary: only online status and rounded CPU and memory usage per server, sent every 10 seconds by default"
 // @Produce json
 // @Success 200 {object} model.StreamServerData
 // @Router /ws/server [get]
 func serverStream(c *gin.Context) (any, error) {
+	mode := c.Query("mode")
+	if mode != "" && mode != serverStreamModeSummary {
+		return nil, singleton.Localizer.ErrorT("invalid mode: %s", mode)
+	}
+	summary := mode == serverStreamModeSummary
+
+	userIp, _ := waf.RequestRealIP(c.Request)
+	if limit := singleton.Conf.WebSocket.MaxConnectionsPerIP; limit > 0 && singleton.CountOnlineUsersByIP(userIp) >= limit {
+		return nil, singleton.Localizer.ErrorT("too many connections")
+	}
+
 	connId, err := uuid.GenerateUUID()
 	if err != nil {
 		return nil, newWsError("%v", err)
@@ -114,11 +130,7 @@ func serverStream(c *gin.Context) (any, error) {
 		return nil, newWsError("%v", err)
 	}
 	defer conn.Close()
-
-	userIp := c.GetString(model.CtxKeyRealIPStr)
-	if userIp == "" {
-		userIp = c.RemoteIP()
-	}
+	defer singleton.SessionShared.TrackConn(c.GetString(model.CtxKeySessionID), conn)()
 
 	u, isMember := c.Get(model.CtxKeyAuthorizedUser)
 	var userId uint64
@@ -134,9 +146,10 @@ func serverStream(c *gin.Context) (any, error) {
 	})
 	defer singleton.RemoveOnlineUser(connId)
 
+	scope, scoped := model.GetTenantScope(c)
 	count := 0
 	for {
-		stat, err := getServerStat(count == 0, isMember)
+		stat, err := getServerStat(count == 0, isMember, scope, scoped, summary)
 		if err != nil {
 			continue
 		}
@@ -150,54 +163,254 @@ func serverStream(c *gin.Context) (any, error) {
 				break
 			}
 		}
+		time.Sleep(time.Duration(utils.IfOr(summary, singleton.Conf.WebSocket.SummaryInterval, singleton.Conf.WebSocket.StreamInterval)) * time.Second)
+	}
+	return nil, newWsError("")
+}
+
+// Websocket single server stream
+// @Summary Websocket single server stream
+// @tags common
+// @Schemes
+// @Description Websocket stream of a single server, including per-core CPU and per-mount disk usage
+// @security BearerAuth
+// @Param id path uint true "Server ID"
+// @Produce json
+// @Success 200 {object} model.StreamServer
+// @Router /ws/server/{id} [get]
+func singleServerStream(c *gin.Context) (any, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	_, authorized := c.Get(model.CtxKeyAuthorizedUser)
+	if server, ok := singleton.ServerShared.Get(id); !ok || !serverVisible(c, server) {
+		return nil, singleton.Localizer.ErrorT("server id %d does not exist", id)
+	}
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return nil, newWsError("%v", err)
+	}
+	defer conn.Close()
+	defer singleton.SessionShared.TrackConn(c.GetString(model.CtxKeySessionID), conn)()
+
+	for count := 0; ; count++ {
+		server, ok := singleton.ServerShared.Get(id)
+		if !ok {
+			break
+		}
+		ss := streamServer(server, count == 0, authorized, false)
+		if count == 0 {
+			ss.History = model.DownsampleStateSamples(singleton.ServerShared.StateHistory(id, stateHistoryDefaultWindow), stateHistoryDefaultPoints)
+			if authorized && server.HasPermission(c) {
+				ss.FirstSeen = server.CreatedAt
+				ss.Events, _ = singleton.ListServerEvents(id, serverEventStreamLimit)
+			}
+			if authorized {
+				ss.CustomHistory = downsampleCustomMetrics(singleton.ServerShared.CustomMetricHistory(id, stateHistoryDefaultWindow), stateHistoryDefaultPoints)
+			}
+		}
+		stat, err := json.Marshal(ss)
+		if err != nil {
+			break
+		}
+		if err := conn.WriteMessage(websocket.TextMessage, stat); err != nil {
+			break
+		}
+		if count%4 == 3 {
+			if err := conn.WriteMessage(websocket.PingMessage, []byte{}); err != nil {
+				break
+			}
+		}
 		time.Sleep(time.Second * 2)
 	}
 	return nil, newWsError("")
 }
 
+// serverVisible 访客不可见隐藏的服务器，严格租户模式下只可见租户范围内的服务器
+func serverVisible(c *gin.Context, server *model.Server) bool {
+	if _, authorized := c.Get(model.CtxKeyAuthorizedUser); !authorized && server.HideForGuest {
+		return false
+	}
+	if scope, ok := model.GetTenantScope(c); ok && !scope.Contains(server.UserID) {
+		return false
+	}
+	return true
+}
+
+// streamServer 推送给前端的服务器状态，brief 为真时不包含各核心、各挂载点的明细，游客不可见 GPU 详细状态
+func streamServer(server *model.Server, withPublicNote, authorized, brief bool) model.StreamServer {
+	var countryCode string
+	var ipAddress string
+	var asnOrg string
+
+	if server.GeoIP != nil {
+		countryCode = server.GeoIP.CountryCode
+		ipAddress = server.GeoIP.IP.Join()
+		asnOrg = server.GeoIP.ASN
+	}
+
+	state := utils.IfOr(brief, server.State.Brief(), server.State)
+	if !authorized {
+		state = state.ForGuest()
+	}
+
+	now := time.Now()
+	online, _, _ := serverUsage(server, now)
+	scheduledOff := server.ScheduledOff(now, online)
+
+	var conn *model.ServerConnection
+	var sockets *model.SocketStats
+	if authorized {
+		conn = singleton.ServerShared.Connection(server.ID)
+		sockets = server.ConnStats.Peaks()
+	}
+
+	return model.StreamServer{
+		ID:            server.ID,
+		Name:          server.Name,
+		PublicNote:    utils.IfOr(withPublicNote, server.PublicNote, ""),
+		DisplayIndex:  server.DisplayIndex,
+		Host:          utils.IfOr(authorized, server.Host, server.Host.Filter()),
+		State:         state,
+		CountryCode:   countryCode,
+		IPAddress:     ipAddress,
+		ASN:           asnOrg,
+		LastActive:    server.LastActive,
+		ScheduledOff:  scheduledOff,
+		HeartbeatMode: server.HeartbeatMode,
+		Capabilities:  utils.IfOr(authorized, server.Capabilities, nil),
+		Connection:    conn,
+		Sockets:       sockets,
+	}
+}
+
+const (
+	serverStreamModeSummary = "summary"
+	serverStreamInterval    = 2 * time.Second
+	serverOnlineTimeout     = 10 * time.Second
+)
+
 var requestGroup singleflight.Group
 
-func getServerStat(withPublicNote, authorized bool) ([]byte, error) {
-	v, err, _ := requestGroup.Do(fmt.Sprintf("serverStats::%t", authorized), func() (any, error) {
+// serverStatSnapshot 同一次遍历服务器列表生成的完整与精简推送数据
+type serverStatSnapshot struct {
+	full    []byte
+	summary []byte
+}
+
+// serverUsage 返回服务器是否在线及 CPU、内存使用率
+func serverUsage(server *model.Server, now time.Time) (online bool, cpu, mem float64) {
+	online = now.Sub(server.LastActive) < server.OnlineTimeout(serverOnlineTimeout)
+	if server.State == nil {
+		return
+	}
+	cpu = server.State.CPU
+	if server.Host != nil && server.Host.MemTotal > 0 {
+		mem = float64(server.State.MemUsed) / float64(server.Host.MemTotal) * 100
+	}
+	return
+}
+
+// getServerStat scoped 为真时只包含租户范围内用户的服务器，summary 为真时返回精简数据
+func getServerStat(withPublicNote, authorized bool, scope model.TenantScope, scoped, summary bool) ([]byte, error) {
+	key := fmt.Sprintf("serverStats::%t::%t", authorized, withPublicNote)
+	if scoped {
+		key += "::" + scope.Key()
+	}
+	v, err, _ := requestGroup.Do(key, func() (any, error) {
 		var serverList []*model.Server
-		if authorized {
+		switch {
+		case scoped:
+			serverList = slices.DeleteFunc(singleton.ServerShared.GetSortedListByTenant(scope), func(s *model.Server) bool {
+				return !authorized && s.HideForGuest
+			})
+		case authorized:
 			serverList = singleton.ServerShared.GetSortedList()
-		} else {
+		default:
 			serverList = singleton.ServerShared.GetSortedListForGuest()
 		}
 
+		// 首帧附带访客的展示偏好，客户端无需再请求公开设置即可按统一的单位渲染
+		var display *model.DisplayPreferences
+		if withPublicNote {
+			d := singleton.Conf.Display
+			display = &d
+		}
+
+		now := time.Now()
 		servers := make([]model.StreamServer, 0, len(serverList))
+		summaries := make([]model.StreamServerSummary, 0, len(serverList))
+		aggregate := model.StreamAggregate{Total: len(serverList)}
+		var cpuSum, memSum float64
+		var metered int // 有 CPU、内存数据的在线服务器，心跳模式的服务器不计入平均值
 		for _, server := range serverList {
-			var countryCode string
-			var ipAddress string
-			var asnOrg string
-
-			if server.GeoIP != nil {
-				countryCode = server.GeoIP.CountryCode
-				ipAddress = server.GeoIP.IP.Join()
-				asnOrg = server.GeoIP.ASN
-			}
+			servers = append(servers, streamServer(server, withPublicNote, authorized, true))
 
-			servers = append(servers, model.StreamServer{
-				ID:           server.ID,
-				Name:         server.Name,
-				PublicNote:   utils.IfOr(withPublicNote, server.PublicNote, ""),
-				DisplayIndex: server.DisplayIndex,
-				Host:         utils.IfOr(authorized, server.Host, server.Host.Filter()),
-				State:        server.State,
-				CountryCode:  countryCode,
-				IPAddress:    ipAddress,
-				ASN:          asnOrg,
-				LastActive:   server.LastActive,
+			online, cpu, mem := serverUsage(server, now)
+			scheduledOff := server.ScheduledOff(now, online)
+			summaries = append(summaries, model.StreamServerSummary{
+				ID:            server.ID,
+				Name:          server.Name,
+				Online:        online,
+				ScheduledOff:  scheduledOff,
+				HeartbeatMode: server.HeartbeatMode,
+				CPU:           int(math.Round(cpu)),
+				Mem:           int(math.Round(mem)),
 			})
+			if scheduledOff {
+				aggregate.ScheduledOff++
+			}
+			if online {
+				aggregate.Online++
+				if !server.HeartbeatMode {
+					metered++
+					cpuSum += cpu
+					memSum += mem
+				}
+				if server.State != nil {
+					aggregate.NetInSpeed += server.State.NetInSpeed
+					aggregate.NetOutSpeed += server.State.NetOutSpeed
+				}
+			}
 		}
+		if metered > 0 {
+			aggregate.CPU = int(math.Round(cpuSum / float64(metered)))
+			aggregate.Mem = int(math.Round(memSum / float64(metered)))
+		}
+
+		full, err := json.Marshal(model.StreamServerData{
+			Now:       now.Unix() * 1000,
+			Online:    utils.IfOr(scoped, 0, singleton.GetClusterOnlineUserCount()), // 在线人数是全站数据，不对租户展示
+			Servers:   servers,
+			Aggregate: aggregate,
+
+			ReadOnly: singleton.Conf.ReadOnly,
+			Banner:   singleton.Conf.MaintenanceBanner,
+			Display:  display,
+		})
+		if err != nil {
+			return nil, err
+		}
+		brief, err := json.Marshal(model.StreamSummaryData{
+			Now:       now.Unix() * 1000,
+			Servers:   summaries,
+			Aggregate: aggregate,
 
-		return json.Marshal(model.StreamServerData{
-			Now:     time.Now().Unix() * 1000,
-			Online:  singleton.GetOnlineUserCount(),
-			Servers: servers,
+			ReadOnly: singleton.Conf.ReadOnly,
+			Banner:   singleton.Conf.MaintenanceBanner,
+			Display:  display,
 		})
+		if err != nil {
+			return nil, err
+		}
+		return &serverStatSnapshot{full: full, summary: brief}, nil
 	})
+	if err != nil {
+		return nil, err
+	}
 
-	return v.([]byte), err
+	snapshot := v.(*serverStatSnapshot)
+	return utils.IfOr(summary, snapshot.summary, snapshot.full), nil
 }
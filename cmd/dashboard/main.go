@@ -32,6 +32,7 @@ type DashboardCliParam struct {
 	Version          bool   // 当前版本号
 	ConfigFile       string // 配置文件路径
 	DatabaseLocation string // Sqlite3 数据库文件路径
+	MigrateTSDB      bool   // 将监控记录复制到时序数据库后退出
 }
 
 var (
@@ -66,7 +67,7 @@ func initSystem(bus chan<- *model.Service) error {
 	}
 
 	// 每天的3:30 对 监控记录 和 流量记录 进行清理
-	if _, err := singleton.CronShared.AddFunc("0 30 3 * * *", singleton.CleanServiceHistory); err != nil {
+	if _, err := singleton.CronShared.AddFunc("0 30 3 * * *", singleton.OnClusterLeader(singleton.CleanServiceHistory)); err != nil {
 		return err
 	}
 
@@ -74,6 +75,58 @@ func initSystem(bus chan<- *model.Service) error {
 	if _, err := singleton.CronShared.AddFunc("0 0 * * * *", func() { singleton.RecordTransferHourlyUsage() }); err != nil {
 		return err
 	}
+
+	// 每小时汇总上一小时的服务器状态，供服务器汇总通知统计 CPU 占用
+	if _, err := singleton.CronShared.AddFunc("0 1 * * * *", singleton.OnClusterLeader(singleton.RecordServerStateHourly)); err != nil {
+		return err
+	}
+
+	// 每分钟采样服务器的在线状态，供 SLA 报告计算服务器分组的可用率
+	if _, err := singleton.CronShared.AddFunc("45 * * * * *", singleton.OnClusterLeader(singleton.RecordServerUptime)); err != nil {
+		return err
+	}
+
+	// 每分钟汇总 NAT 流量统计
+	if _, err := singleton.CronShared.AddFunc("0 * * * * *", singleton.NATShared.FlushStats); err != nil {
+		return err
+	}
+
+	// 每分钟保存登录失败记录
+	if _, err := singleton.CronShared.AddFunc("15 * * * * *", singleton.LoginGuardShared.Flush); err != nil {
+		return err
+	}
+
+	// 每分钟清理过期的 WAF 封禁
+	if _, err := singleton.CronShared.AddFunc("30 * * * * *", singleton.OnClusterLeader(singleton.CleanWAFBlocks)); err != nil {
+		return err
+	}
+
+	// 每天 9:00 发送 WAF 封禁汇总
+	if _, err := singleton.CronShared.AddFunc("0 0 9 * * *", singleton.OnClusterLeader(singleton.SendWAFDailySummary)); err != nil {
+		return err
+	}
+
+	// 每天 0:30 补齐上一周期的 SLA 报告
+	if _, err := singleton.CronShared.AddFunc("0 30 0 * * *", singleton.OnClusterLeader(singleton.RunDueSLAReports)); err != nil {
+		return err
+	}
+
+	// 定时发送服务器汇总
+	if singleton.Conf.FleetSummary.Schedule != "" {
+		if err := singleton.ValidateFleetSummary(); err != nil {
+			return err
+		}
+		if _, err := singleton.CronShared.AddFunc(singleton.Conf.FleetSummary.Schedule, singleton.OnClusterLeader(singleton.SendFleetSummary)); err != nil {
+			return err
+		}
+	}
+
+	// 定时备份数据库
+	if singleton.Conf.Backup.Schedule != "" {
+		if _, err := singleton.CronShared.AddFunc(singleton.Conf.Backup.Schedule, singleton.OnClusterLeader(singleton.ScheduledBackup)); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -99,9 +152,29 @@ func initSystem(bus chan<- *model.Service) error {
 // @externalDocs.description  OpenAPI
 // @externalDocs.url          https://swagger.io/resources/open-api/
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(os.Args[2:]); err != nil {
+			log.Fatalf("NEZHA>> %v", err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "secrets" {
+		if err := runSecrets(os.Args[2:]); err != nil {
+			log.Fatalf("NEZHA>> %v", err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "admin-bypass" {
+		if err := runAdminBypass(os.Args[2:]); err != nil {
+			log.Fatalf("NEZHA>> %v", err)
+		}
+		return
+	}
+
 	flag.BoolVar(&dashboardCliParam.Version, "v", false, "查看当前版本号")
 	flag.StringVar(&dashboardCliParam.ConfigFile, "c", "data/config.yaml", "配置文件路径")
 	flag.StringVar(&dashboardCliParam.DatabaseLocation, "db", "data/sqlite.db", "Sqlite3数据库文件路径")
+	flag.BoolVar(&dashboardCliParam.MigrateTSDB, "migrate-tsdb", false, "将已有的监控记录复制到配置的时序数据库后退出")
 	flag.Parse()
 
 	if dashboardCliParam.Version {
@@ -109,20 +182,51 @@ func main() {
 		os.Exit(0)
 	}
 
+	singleton.CaptureErrorLog()
 	serviceSentinelDispatchBus := make(chan *model.Service) // 用于传递服务监控任务信息的channel
 	// 初始化 dao 包
 	if err := utils.FirstError(singleton.InitFrontendTemplates,
 		func() error { return singleton.InitConfigFromPath(dashboardCliParam.ConfigFile) },
+		singleton.InitGRPCTLS,
 		singleton.InitTimezoneAndCache,
 		func() error { return singleton.InitDBFromPath(dashboardCliParam.DatabaseLocation) },
-		func() error { return initSystem(serviceSentinelDispatchBus) }); err != nil {
+		singleton.InitTSDB,
+		singleton.InitCluster); err != nil {
+		log.Fatal(err)
+	}
+
+	if dashboardCliParam.MigrateTSDB {
+		n, err := singleton.MigrateServiceHistoryToTSDB()
+		if err != nil {
+			log.Fatalf("NEZHA>> Failed to migrate service history after %d row(s): %v", n, err)
+		}
+		log.Printf("NEZHA>> Migrated %d service history row(s) to tsdb", n)
+		os.Exit(0)
+	}
+
+	if err := initSystem(serviceSentinelDispatchBus); err != nil {
 		log.Fatal(err)
 	}
+	singleton.RunSelfCheck()
 
 	l, err := net.Listen("tcp", fmt.Sprintf("%s:%d", singleton.Conf.ListenHost, singleton.Conf.ListenPort))
 	if err != nil {
 		log.Fatal(err)
 	}
+	if singleton.Conf.ProxyProtocol {
+		l = &utils.ProxyProtoListener{Listener: l, Trusted: singleton.TrustedProxy}
+	}
+
+	var grpcTLSListener net.Listener
+	if singleton.GRPCTLSShared != nil {
+		grpcTLSListener, err = net.Listen("tcp", fmt.Sprintf("%s:%d", singleton.Conf.ListenHost, singleton.Conf.GRPCTLS.ListenPort))
+		if err != nil {
+			log.Fatal(err)
+		}
+		if singleton.Conf.ProxyProtocol {
+			grpcTLSListener = &utils.ProxyProtoListener{Listener: grpcTLSListener, Trusted: singleton.TrustedProxy}
+		}
+	}
 
 	singleton.CleanServiceHistory()
 	rpc.DispatchKeepalive()
@@ -154,7 +258,17 @@ func main() {
 		}
 	}
 
-	errChan := make(chan error, 2)
+	// Agent 专用的 TLS 端口，只提供 gRPC 服务
+	var grpcServerTLS *http.Server
+	if grpcTLSListener != nil {
+		grpcServerTLS = &http.Server{
+			Handler:           grpcHandler,
+			ReadHeaderTimeout: time.Second * 5,
+			TLSConfig:         singleton.GRPCTLSShared.TLSConfig(),
+		}
+	}
+
+	errChan := make(chan error, 3)
 	errHTTPS := errors.New("error from https server")
 
 	if err := graceful.Graceful(func() error {
@@ -165,6 +279,12 @@ func main() {
 			}()
 			log.Printf("NEZHA>> Dashboard::START ON %s:%d", singleton.Conf.ListenHost, singleton.Conf.HTTPS.ListenPort)
 		}
+		if grpcServerTLS != nil {
+			go func() {
+				errChan <- grpcServerTLS.ServeTLS(grpcTLSListener, "", "")
+			}()
+			log.Printf("NEZHA>> gRPC TLS::START ON %s:%d", singleton.Conf.ListenHost, singleton.Conf.GRPCTLS.ListenPort)
+		}
 		go func() {
 			errChan <- muxServerHTTP.Serve(l)
 		}()
@@ -172,11 +292,17 @@ func main() {
 	}, func(c context.Context) error {
 		log.Println("NEZHA>> Graceful::START")
 		singleton.RecordTransferHourlyUsage()
+		singleton.ServiceSentinelShared.FlushLatency()
+		singleton.ServiceSentinelShared.FlushHistory()
+		singleton.ClusterShared.Close()
 		log.Println("NEZHA>> Graceful::END")
 		var err error
 		if muxServerHTTPS != nil {
 			err = muxServerHTTPS.Shutdown(c)
 		}
+		if grpcServerTLS != nil {
+			grpcServerTLS.Shutdown(c)
+		}
 		return errors.Join(muxServerHTTP.Shutdown(c), utils.IfOr(err != nil, utils.NewWrapError(errHTTPS, err), nil))
 	}); err != nil {
 		log.Printf("NEZHA>> ERROR: %v", err)
@@ -198,6 +324,9 @@ func newHTTPandGRPCMux(httpHandler http.Handler, grpcHandler http.Handler) http.
 				waf.ShowBlockPage(c, fmt.Errorf("nat host %s is disabled", natConfig.Domain))
 				return
 			}
+			if !controller.CheckNATAccess(w, r, natConfig) {
+				return
+			}
 			rpc.ServeNAT(w, r, natConfig)
 			return
 		}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/nezhahq/nezha/pkg/utils"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+const migrateUsage = `用法: dashboard migrate [参数] <up|down|status>
+
+  up      执行未执行的迁移，可用 -to 指定目标版本
+  down    回滚最近的迁移，可用 -steps 指定数量
+  status  查看各迁移的执行情况
+
+`
+
+// runMigrate 在不启动面板的情况下执行或回滚数据库迁移
+func runMigrate(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	configFile := fs.String("c", "data/config.yaml", "配置文件路径")
+	dbLocation := fs.String("db", "data/sqlite.db", "Sqlite3数据库文件路径")
+	to := fs.Int64("to", 0, "up 的目标版本，0 为最新版本")
+	steps := fs.Int("steps", 1, "down 回滚的迁移数量")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), migrateUsage)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if err := utils.FirstError(singleton.InitFrontendTemplates,
+		func() error { return singleton.InitConfigFromPath(*configFile) },
+		func() error { return singleton.OpenDBFromPath(*dbLocation) },
+	); err != nil {
+		return err
+	}
+	m, err := singleton.NewMigrator()
+	if err != nil {
+		return err
+	}
+
+	switch action := fs.Arg(0); action {
+	case "", "up":
+		done, err := m.Up(*to)
+		for _, mg := range done {
+			fmt.Printf("applied %d %s\n", mg.Version, mg.Name)
+		}
+		return err
+	case "down":
+		done, err := m.Down(*steps)
+		for _, mg := range done {
+			fmt.Printf("rolled back %d %s\n", mg.Version, mg.Name)
+		}
+		return err
+	case "status":
+		status, err := m.Status()
+		if err != nil {
+			return err
+		}
+		for _, s := range status {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%4d  %-40s %s\n", s.Version, s.Name, applied)
+		}
+		return m.Check()
+	default:
+		fs.Usage()
+		os.Exit(2)
+	}
+	return nil
+}
@@ -3,13 +3,16 @@ package rpc
 import (
 	"context"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"net/netip"
+	"strings"
 	"time"
 
 	"github.com/goccy/go-json"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/peer"
 
@@ -22,25 +25,82 @@ import (
 )
 
 func ServeRPC() *grpc.Server {
-	server := grpc.NewServer(grpc.ChainUnaryInterceptor(getRealIp, waf))
+	ka := singleton.Conf.GRPCKeepalive
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(getRealIp, waf),
+		grpc.ChainStreamInterceptor(getRealIpStream, wafStream),
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             time.Duration(ka.MinTime) * time.Second,
+			PermitWithoutStream: ka.PermitWithoutStream,
+		}),
+		grpc.KeepaliveParams(keepalive.ServerParameters{
+			Time:    time.Duration(ka.Time) * time.Second,
+			Timeout: time.Duration(ka.Timeout) * time.Second,
+		}),
+	)
 	rpcService.NezhaHandlerSingleton = rpcService.NewNezhaHandler()
 	proto.RegisterNezhaServiceServer(server, rpcService.NezhaHandlerSingleton)
 	return server
 }
 
 func waf(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if err := checkWAF(ctx); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// wafStream 对 ReportSystemState、RequestTask 等流式接口执行同样的检查
+func wafStream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := checkWAF(ss.Context()); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+func checkWAF(ctx context.Context) error {
 	realip, _ := ctx.Value(model.CtxKeyRealIP{}).(string)
 	if err := model.CheckIP(singleton.DB, realip); err != nil {
+		return err
+	}
+	// 未配置真实 IP 请求头时按连接地址匹配规则
+	if realip == "" {
+		realip, _ = ctx.Value(model.CtxKeyConnectingIP{}).(string)
+	}
+	return singleton.WAFRuleShared.Check(realip, model.WAFSurfaceGRPC)
+}
+
+func getRealIp(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, err := withRealIP(ctx)
+	if err != nil {
 		return nil, err
 	}
 	return handler(ctx, req)
 }
 
-func getRealIp(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
-	var ip, connectingIp string
+func getRealIpStream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := withRealIP(ss.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, &contextServerStream{ServerStream: ss, ctx: ctx})
+}
+
+// contextServerStream 替换流的 Context，使后续拦截器与处理函数能读取真实 IP
+type contextServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func withRealIP(ctx context.Context) (context.Context, error) {
+	var peerAddr, connectingIp string
 	p, ok := peer.FromContext(ctx)
 	if ok {
-		addrPort, err := netip.ParseAddrPort(p.Addr.String())
+		peerAddr = p.Addr.String()
+		addrPort, err := netip.ParseAddrPort(peerAddr)
 		if err == nil {
 			connectingIp = addrPort.Addr().String()
 		}
@@ -48,31 +108,21 @@ func getRealIp(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler
 	ctx = context.WithValue(ctx, model.CtxKeyConnectingIP{}, connectingIp)
 
 	if singleton.Conf.AgentRealIPHeader == "" {
-		return handler(ctx, req)
+		return ctx, nil
 	}
 
-	if singleton.Conf.AgentRealIPHeader == model.ConfigUsePeerIP {
-		if connectingIp == "" {
-			return nil, fmt.Errorf("connecting ip not found")
-		}
-	} else {
-		vals := metadata.ValueFromIncomingContext(ctx, singleton.Conf.AgentRealIPHeader)
-		if len(vals) == 0 {
-			return nil, fmt.Errorf("real ip header not found")
-		}
-		var err error
-		ip, err = utils.GetIPFromHeader(vals[0])
-		if err != nil {
-			return nil, err
-		}
+	ip, err := singleton.RealIP(singleton.Conf.AgentRealIPHeader, peerAddr, func(header string) string {
+		return strings.Join(metadata.ValueFromIncomingContext(ctx, header), ",")
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	if singleton.Conf.Debug {
 		log.Printf("NEZHA>> gRPC Agent Real IP: %s, connecting IP: %s\n", ip, connectingIp)
 	}
 
-	ctx = context.WithValue(ctx, model.CtxKeyRealIP{}, ip)
-	return handler(ctx, req)
+	return context.WithValue(ctx, model.CtxKeyRealIP{}, ip), nil
 }
 
 func DispatchTask(serviceSentinelDispatchBus <-chan *model.Service) {
@@ -94,7 +144,7 @@ func DispatchTask(serviceSentinelDispatchBus <-chan *model.Service) {
 				}
 
 				if canSendTaskToServer(task, server) {
-					server.TaskStream.Send(task.PB())
+					server.TaskStream.Send(task.PB(server))
 				}
 			}
 		case model.ServiceCoverAll:
@@ -104,9 +154,15 @@ func DispatchTask(serviceSentinelDispatchBus <-chan *model.Service) {
 				}
 
 				if canSendTaskToServer(task, server) {
-					server.TaskStream.Send(task.PB())
+					server.TaskStream.Send(task.PB(server))
 				}
 			}
+		case model.ServiceCoverSelected:
+			for _, server := range singleton.ServiceSentinelShared.SelectProbes(task, func(server *model.Server) bool {
+				return canSendTaskToServer(task, server)
+			}) {
+				server.TaskStream.Send(task.PB(server))
+			}
 		}
 	}
 }
@@ -167,7 +223,10 @@ func ServeNAT(w http.ResponseWriter, r *http.Request, natConfig *model.NAT) {
 		return
 	}
 
-	if err := rpcService.NezhaHandlerSingleton.UserConnected(streamId, wWrapped); err != nil {
+	conn := singleton.NATShared.OpenConn(natConfig.ID)
+	defer singleton.NATShared.CloseConn(conn)
+
+	if err := rpcService.NezhaHandlerSingleton.UserConnected(streamId, &natCountingConn{wWrapped, conn}); err != nil {
 		w.WriteHeader(http.StatusServiceUnavailable)
 		w.Write(fmt.Appendf(nil, "user connected error: %v", err))
 		return
@@ -176,7 +235,29 @@ func ServeNAT(w http.ResponseWriter, r *http.Request, natConfig *model.NAT) {
 	rpcService.NezhaHandlerSingleton.StartStream(streamId, time.Second*10)
 }
 
+// natCountingConn 统计用户与 Agent 之间转发的流量
+type natCountingConn struct {
+	io.ReadWriteCloser
+	stat *singleton.NATConn
+}
+
+func (c *natCountingConn) Read(p []byte) (int, error) {
+	n, err := c.ReadWriteCloser.Read(p)
+	c.stat.BytesIn.Add(uint64(n))
+	return n, err
+}
+
+func (c *natCountingConn) Write(p []byte) (int, error) {
+	n, err := c.ReadWriteCloser.Write(p)
+	c.stat.BytesOut.Add(uint64(n))
+	return n, err
+}
+
 func canSendTaskToServer(task *model.Service, server *model.Server) bool {
+	if !task.CanRunOn(server) {
+		return false
+	}
+
 	var role uint8
 	singleton.UserLock.RLock()
 	if u, ok := singleton.UserInfoMap[server.UserID]; !ok {
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/nezhahq/nezha/pkg/utils"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+const secretsUsage = `用法: dashboard secrets [参数] encrypt
+
+  encrypt  使用当前密钥重新加密通知地址、DDNS 凭据等敏感信息，
+           用于加密升级前写入的明文数据，或在轮换密钥后迁移旧密钥加密的数据
+
+`
+
+// runSecrets 在不启动面板的情况下管理加密存储的敏感信息
+func runSecrets(args []string) error {
+	fs := flag.NewFlagSet("secrets", flag.ExitOnError)
+	configFile := fs.String("c", "data/config.yaml", "配置文件路径")
+	dbLocation := fs.String("db", "data/sqlite.db", "Sqlite3数据库文件路径")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), secretsUsage)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.Arg(0) != "encrypt" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	if err := utils.FirstError(singleton.InitFrontendTemplates,
+		func() error { return singleton.InitConfigFromPath(*configFile) },
+		func() error { return singleton.InitDBFromPath(*dbLocation) },
+	); err != nil {
+		return err
+	}
+	return singleton.ReencryptSecrets()
+}
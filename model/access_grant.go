@@ -0,0 +1,53 @@
+package model
+
+import (
+	"time"
+)
+
+// 授权的操作类型
+const (
+	AccessTerminal = "terminal"
+	AccessFile     = "file"
+	AccessExec     = "exec"
+	AccessProbe    = "probe" // 从服务器发起的诊断探测，由命令执行权限授予
+)
+
+// AccessGrant 管理员授予用户对指定服务器或服务器分组的终端、命令执行及文件管理权限，
+// ServerID 与 ServerGroupID 只能设置其一
+type AccessGrant struct {
+	Common
+	TargetUserID  uint64     `gorm:"index" json:"target_user_id"`
+	ServerID      uint64     `json:"server_id,omitempty"`
+	ServerGroupID uint64     `json:"server_group_id,omitempty"`
+	Terminal      bool       `json:"terminal,omitempty"`
+	Exec          bool       `json:"exec,omitempty"`
+	File          bool       `json:"file,omitempty"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"` // 为空时永久有效
+}
+
+// Expired 授权已过期
+func (g *AccessGrant) Expired(now time.Time) bool {
+	return g.ExpiresAt != nil && !now.Before(*g.ExpiresAt)
+}
+
+// Allows 判断授权在 now 时是否允许该操作
+func (g *AccessGrant) Allows(access string, now time.Time) bool {
+	if g.Expired(now) {
+		return false
+	}
+	switch access {
+	case AccessTerminal:
+		return g.Terminal
+	case AccessFile:
+		return g.File
+	case AccessExec, AccessProbe:
+		return g.Exec
+	}
+	return false
+}
+
+// AccessRequiresGrant 判断非管理员执行该操作时是否需要授权。
+// 命令执行始终需要授权，其余操作仅在开启 RequireAccessGrants 后需要
+func AccessRequiresGrant(access string, requireGrants bool) bool {
+	return access == AccessExec || requireGrants
+}
@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+type AccessGrantForm struct {
+	TargetUserID  uint64     `json:"target_user_id"`
+	ServerID      uint64     `json:"server_id,omitempty" validate:"optional"`       // 与 server_group_id 二选一
+	ServerGroupID uint64     `json:"server_group_id,omitempty" validate:"optional"` // 与 server_id 二选一
+	Terminal      bool       `json:"terminal,omitempty" validate:"optional"`
+	Exec          bool       `json:"exec,omitempty" validate:"optional"`
+	File          bool       `json:"file,omitempty" validate:"optional"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty" validate:"optional"`
+}
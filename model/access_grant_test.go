@@ -0,0 +1,32 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAccessGrant(t *testing.T) {
+	now := time.Now()
+	expires := now.Add(time.Hour)
+	g := &AccessGrant{Terminal: true, Exec: true, ExpiresAt: &expires}
+
+	for access, want := range map[string]bool{
+		AccessTerminal: true,
+		AccessFile:     false,
+		AccessExec:     true,
+		AccessProbe:    true,
+		"unknown":      false,
+	} {
+		if got := g.Allows(access, now); got != want {
+			t.Errorf("Allows(%s) = %v, want %v", access, got, want)
+		}
+	}
+	if g.Allows(AccessTerminal, expires) {
+		t.Error("expected grant to expire at ExpiresAt")
+	}
+
+	// 命令执行始终需要授权，其余操作按设置决定
+	if !AccessRequiresGrant(AccessExec, false) || AccessRequiresGrant(AccessTerminal, false) || !AccessRequiresGrant(AccessFile, true) {
+		t.Error("unexpected AccessRequiresGrant result")
+	}
+}
@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// AdminBypassCookie 通过应急链接后允许从受限网络以外访问管理区域的 Cookie
+const AdminBypassCookie = "nz-admin-bypass"
+
+// AdminBypassUse 已使用的应急链接，用于保证链接只能使用一次
+type AdminBypassUse struct {
+	Nonce     string    `gorm:"primaryKey;type:char(32)"`
+	ExpiresAt time.Time `gorm:"index"`
+}
@@ -0,0 +1,130 @@
+package model
+
+import "strings"
+
+// Agent 通过 gRPC 元数据 capabilities 上报的能力，值为逗号分隔的能力名称
+const (
+	AgentCapabilityExec        = "exec"
+	AgentCapabilityTerminal    = "terminal"
+	AgentCapabilityFilePush    = "file_push"
+	AgentCapabilityContainers  = "containers"
+	AgentCapabilityGPU         = "gpu"
+	AgentCapabilityLogs        = "logs"
+	AgentCapabilityHeartbeat   = "heartbeat"    // 支持面板下发的心跳上报模式
+	AgentCapabilityTaskOptions = "task_options" // 支持 JSON 格式的命令任务，可设置超时时间与环境变量
+	AgentCapabilityHTTPOptions = "http_options" // 支持 HTTP 监控的 DNS 服务器、连接 IP、协议与本地地址选项
+	AgentCapabilityHTTP3       = "http3"        // 支持使用 HTTP/3 进行 HTTP 监控
+
+	AgentCapabilityMonitorOptions = "monitor_options" // 支持 JSON 格式的服务监控任务，如 HTTP 断言、超时时间与 ICMP 发包选项
+)
+
+// AgentCapabilities Agent 根据本地的命令白名单、安全模式等配置上报的可用功能
+type AgentCapabilities struct {
+	Exec        bool `json:"exec"`
+	Terminal    bool `json:"terminal"`
+	FilePush    bool `json:"file_push"`
+	Containers  bool `json:"containers"`
+	GPU         bool `json:"gpu"`
+	Logs        bool `json:"logs"`
+	Heartbeat   bool `json:"heartbeat"`
+	TaskOptions bool `json:"task_options"`
+	HTTPOptions bool `json:"http_options"`
+	HTTP3       bool `json:"http3"`
+
+	MonitorOptions bool `json:"monitor_options"`
+}
+
+// ParseAgentCapabilities 解析逗号分隔的能力列表，忽略不认识的能力
+func ParseAgentCapabilities(list string) *AgentCapabilities {
+	caps := &AgentCapabilities{}
+	for name := range strings.SplitSeq(list, ",") {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case AgentCapabilityExec:
+			caps.Exec = true
+		case AgentCapabilityTerminal:
+			caps.Terminal = true
+		case AgentCapabilityFilePush:
+			caps.FilePush = true
+		case AgentCapabilityContainers:
+			caps.Containers = true
+		case AgentCapabilityGPU:
+			caps.GPU = true
+		case AgentCapabilityLogs:
+			caps.Logs = true
+		case AgentCapabilityHeartbeat:
+			caps.Heartbeat = true
+		case AgentCapabilityTaskOptions:
+			caps.TaskOptions = true
+		case AgentCapabilityHTTPOptions:
+			caps.HTTPOptions = true
+		case AgentCapabilityHTTP3:
+			caps.HTTP3 = true
+		case AgentCapabilityMonitorOptions:
+			caps.MonitorOptions = true
+		}
+	}
+	return caps
+}
+
+// String 返回规范化的能力列表，用于持久化
+func (c *AgentCapabilities) String() string {
+	var names []string
+	for _, f := range []struct {
+		name string
+		ok   bool
+	}{
+		{AgentCapabilityExec, c.Exec},
+		{AgentCapabilityTerminal, c.Terminal},
+		{AgentCapabilityFilePush, c.FilePush},
+		{AgentCapabilityContainers, c.Containers},
+		{AgentCapabilityGPU, c.GPU},
+		{AgentCapabilityLogs, c.Logs},
+		{AgentCapabilityHeartbeat, c.Heartbeat},
+		{AgentCapabilityTaskOptions, c.TaskOptions},
+		{AgentCapabilityHTTPOptions, c.HTTPOptions},
+		{AgentCapabilityHTTP3, c.HTTP3},
+		{AgentCapabilityMonitorOptions, c.MonitorOptions},
+	} {
+		if f.ok {
+			names = append(names, f.name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+func (c *AgentCapabilities) Has(capability string) bool {
+	switch capability {
+	case AgentCapabilityExec:
+		return c.Exec
+	case AgentCapabilityTerminal:
+		return c.Terminal
+	case AgentCapabilityFilePush:
+		return c.FilePush
+	case AgentCapabilityContainers:
+		return c.Containers
+	case AgentCapabilityGPU:
+		return c.GPU
+	case AgentCapabilityLogs:
+		return c.Logs
+	case AgentCapabilityHeartbeat:
+		return c.Heartbeat
+	case AgentCapabilityTaskOptions:
+		return c.TaskOptions
+	case AgentCapabilityHTTPOptions:
+		return c.HTTPOptions
+	case AgentCapabilityHTTP3:
+		return c.HTTP3
+	case AgentCapabilityMonitorOptions:
+		return c.MonitorOptions
+	}
+	return false
+}
+
+// AgentUnsupportedError Agent 未上报所需的能力，前端据此提示升级 Agent 而不是重试
+type AgentUnsupportedError struct {
+	Capability string `json:"capability"`
+}
+
+func (e *AgentUnsupportedError) Error() string {
+	return "the agent does not support " + e.Capability
+}
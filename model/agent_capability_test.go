@@ -0,0 +1,31 @@
+package model
+
+import "testing"
+
+func TestParseAgentCapabilities(t *testing.T) {
+	caps := ParseAgentCapabilities(" Exec, gpu,unknown,,file_push")
+	if *caps != (AgentCapabilities{Exec: true, FilePush: true, GPU: true}) {
+		t.Fatalf("unexpected capabilities: %+v", caps)
+	}
+	if s := caps.String(); s != "exec,file_push,gpu" {
+		t.Fatalf("unexpected normalized list: %s", s)
+	}
+
+	var s Server
+	if !s.Supports(AgentCapabilityTerminal) {
+		t.Fatal("agent without reported capabilities should support everything")
+	}
+	s.Capabilities = caps
+	if s.Supports(AgentCapabilityTerminal) || !s.Supports(AgentCapabilityExec) {
+		t.Fatalf("unexpected support result for %+v", caps)
+	}
+
+	// 需要新协议的功能必须由 Agent 明确上报
+	if (&Server{}).Reports(AgentCapabilityTaskOptions) || s.Reports(AgentCapabilityTaskOptions) {
+		t.Fatal("task options should require an explicitly reported capability")
+	}
+	s.Capabilities = ParseAgentCapabilities("exec,task_options")
+	if !s.Reports(AgentCapabilityTaskOptions) {
+		t.Fatal("expected task options to be reported")
+	}
+}
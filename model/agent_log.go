@@ -0,0 +1,43 @@
+package model
+
+import (
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	AgentLogsDefaultLines = 200
+	AgentLogsMaxLines     = 5000
+	AgentLogsMaxBytes     = 512 << 10 // Agent 返回日志的最大字节数
+	AgentLogsTimeout      = 10 * time.Second
+)
+
+// AgentLogsRequest 下发给 Agent 的日志请求，Agent 取最近 Lines 行与 MaxBytes 字节中较少的部分，并在发送前隐去通信密钥
+type AgentLogsRequest struct {
+	Lines    int `json:"lines"`
+	MaxBytes int `json:"max_bytes"`
+}
+
+// AgentLogs Agent 按需返回的近期日志，不保存到数据库
+type AgentLogs struct {
+	Text      string    `json:"text"`
+	Truncated bool      `json:"truncated"` // 日志缓冲区中还有更早的内容
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Sanitize 超出最大字节数时保留末尾的完整行
+func (l *AgentLogs) Sanitize() {
+	if len(l.Text) <= AgentLogsMaxBytes {
+		return
+	}
+	text := l.Text[len(l.Text)-AgentLogsMaxBytes:]
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		text = text[i+1:]
+	}
+	for len(text) > 0 && !utf8.RuneStart(text[0]) {
+		text = text[1:]
+	}
+	l.Text = text
+	l.Truncated = true
+}
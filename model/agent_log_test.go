@@ -0,0 +1,24 @@
+package model
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAgentLogsSanitize(t *testing.T) {
+	line := strings.Repeat("x", 99) + "\n"
+	l := &AgentLogs{Text: "first\n" + strings.Repeat(line, AgentLogsMaxBytes/len(line)+1)}
+	l.Sanitize()
+	if !l.Truncated || len(l.Text) > AgentLogsMaxBytes {
+		t.Fatalf("expected truncated text, got %d bytes", len(l.Text))
+	}
+	if !strings.HasPrefix(l.Text, line) {
+		t.Fatal("expected text to start at a line boundary")
+	}
+
+	l = &AgentLogs{Text: "short\n"}
+	l.Sanitize()
+	if l.Truncated || l.Text != "short\n" {
+		t.Fatalf("unexpected sanitize result: %+v", l)
+	}
+}
@@ -0,0 +1,152 @@
+package model
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-json"
+	"gorm.io/gorm"
+)
+
+const (
+	RolloutRunning   = "running"
+	RolloutPaused    = "paused"
+	RolloutAborted   = "aborted"
+	RolloutCompleted = "completed"
+)
+
+const (
+	RolloutServerPending         = "pending"
+	RolloutServerUpgrading       = "upgrading"
+	RolloutServerSucceeded       = "succeeded"
+	RolloutServerFailed          = "failed"
+	RolloutServerVersionMismatch = "version_mismatch" // Agent 重启后的版本与目标版本不一致
+	RolloutServerSkipped         = "skipped"          // 已是目标版本
+)
+
+// AgentRollout 分批升级 Agent 的记录
+type AgentRollout struct {
+	Common
+	TargetVersion    string                `json:"target_version"`
+	BatchSize        int                   `json:"batch_size"`                  // 同时升级的服务器数量
+	MaxFailures      int                   `json:"max_failures"`                // 失败的服务器达到该数量时暂停，0 为不暂停
+	AcceptedFailures int                   `json:"accepted_failures,omitempty"` // 继续升级前已有的失败数量，不再计入
+	Status           string                `json:"status"`
+	Reason           string                `json:"reason,omitempty"` // 自动暂停的原因
+	Selector         *ServerSelector       `gorm:"-" json:"selector"`
+	Servers          []*AgentRolloutServer `gorm:"-" json:"servers"`
+
+	SelectorRaw string `json:"-"`
+	ServersRaw  string `json:"-"`
+}
+
+// AgentRolloutServer 单台服务器的升级状态
+type AgentRolloutServer struct {
+	ServerID    uint64     `json:"server_id"`
+	Status      string     `json:"status"`
+	FromVersion string     `json:"from_version,omitempty"`
+	Version     string     `json:"version,omitempty"` // 升级后上报的版本
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	Error       string     `json:"error,omitempty"`
+}
+
+type AgentRolloutForm struct {
+	TargetVersion string          `json:"target_version" minLength:"1"` // 目标版本，latest 为最新发布的版本
+	Selector      *ServerSelector `json:"selector"`
+	BatchSize     int             `json:"batch_size,omitempty" validate:"optional" default:"1"`
+	MaxFailures   int             `json:"max_failures,omitempty" validate:"optional"`
+}
+
+type AgentRolloutActionForm struct {
+	Action string `json:"action" enums:"pause,resume,abort"`
+}
+
+func (r *AgentRollout) BeforeSave(tx *gorm.DB) error {
+	data, err := json.Marshal(r.Selector)
+	if err != nil {
+		return err
+	}
+	r.SelectorRaw = string(data)
+	if data, err = json.Marshal(r.Servers); err != nil {
+		return err
+	}
+	r.ServersRaw = string(data)
+	return nil
+}
+
+func (r *AgentRollout) AfterFind(tx *gorm.DB) error {
+	if r.SelectorRaw != "" {
+		if err := json.Unmarshal([]byte(r.SelectorRaw), &r.Selector); err != nil {
+			return err
+		}
+	}
+	if r.ServersRaw != "" {
+		return json.Unmarshal([]byte(r.ServersRaw), &r.Servers)
+	}
+	return nil
+}
+
+// Finished 升级已结束，不会再改变状态
+func (r *AgentRollout) Finished() bool {
+	return r.Status == RolloutAborted || r.Status == RolloutCompleted
+}
+
+// Count 返回处于指定状态的服务器数量
+func (r *AgentRollout) Count(status ...string) int {
+	var n int
+	for _, s := range r.Servers {
+		for _, st := range status {
+			if s.Status == st {
+				n++
+				break
+			}
+		}
+	}
+	return n
+}
+
+// SameAgentVersion 比较版本号，忽略 v 前缀
+func SameAgentVersion(a, b string) bool {
+	return a != "" && strings.TrimPrefix(a, "v") == strings.TrimPrefix(b, "v")
+}
+
+// parseAgentVersion 解析 v1.2.3 格式的版本号，忽略预发布等后缀
+func parseAgentVersion(v string) ([3]int, bool) {
+	var parts [3]int
+	v, _, _ = strings.Cut(strings.TrimPrefix(strings.TrimSpace(v), "v"), "-")
+	fields := strings.Split(v, ".")
+	if len(fields) == 0 || len(fields) > 3 {
+		return parts, false
+	}
+	for i, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil || n < 0 {
+			return parts, false
+		}
+		parts[i] = n
+	}
+	return parts, true
+}
+
+func ValidAgentVersion(v string) bool {
+	_, ok := parseAgentVersion(v)
+	return ok
+}
+
+// AgentVersionBehind 判断 current 是否比 target 落后超过 minors 个次版本，minors 为 0 时低于 target 即为落后。
+// 版本号无法解析时 ok 为 false
+func AgentVersionBehind(current, target string, minors uint64) (behind, ok bool) {
+	cur, ok1 := parseAgentVersion(current)
+	tgt, ok2 := parseAgentVersion(target)
+	if !ok1 || !ok2 {
+		return false, false
+	}
+	if cur[0] != tgt[0] {
+		return cur[0] < tgt[0], true
+	}
+	if minors == 0 {
+		return cur[1] < tgt[1] || (cur[1] == tgt[1] && cur[2] < tgt[2]), true
+	}
+	return tgt[1]-cur[1] > int(minors), true
+}
@@ -0,0 +1,20 @@
+package model
+
+import "time"
+
+// AlertSilence 通过通知中的操作链接设置的服务器报警静音
+type AlertSilence struct {
+	ServerID       uint64    `gorm:"primaryKey;autoIncrement:false" json:"server_id"`
+	NotificationID uint64    `json:"notification_id"` // 链接来源的通知方式
+	Until          time.Time `gorm:"index" json:"until"`
+}
+
+// AlertAck 通过通知中的操作链接确认的报警，报警恢复前不再重复通知。
+// AlertID 为 0 时表示等待报警检查确认的服务器，检查时确认该服务器上在链接生成前已经开始报警的规则
+type AlertAck struct {
+	AlertID        uint64    `gorm:"primaryKey;autoIncrement:false" json:"alert_id"`
+	ServerID       uint64    `gorm:"primaryKey;autoIncrement:false" json:"server_id"`
+	NotificationID uint64    `json:"notification_id"` // 链接来源的通知方式
+	IssuedAt       time.Time `json:"issued_at"`       // 链接生成的时间
+	CreatedAt      time.Time `json:"created_at"`
+}
@@ -21,6 +21,7 @@ type AlertRule struct {
 	NotificationGroupID    uint64   `json:"notification_group_id"`         // 该报警规则所在的通知组
 	FailTriggerTasksRaw    string   `gorm:"default:'[]'" json:"-"`
 	RecoverTriggerTasksRaw string   `gorm:"default:'[]'" json:"-"`
+	ServiceID              uint64   `json:"service_id,omitempty"` // 由服务监控的通知设置生成时为对应的服务，修改服务时同步更新
 	Rules                  []*Rule  `gorm:"-" json:"rules"`
 	FailTriggerTasks       []uint64 `gorm:"-" json:"fail_trigger_tasks"`    // 失败时执行的触发任务id
 	RecoverTriggerTasks    []uint64 `gorm:"-" json:"recover_trigger_tasks"` // 恢复时执行的触发任务id
@@ -63,6 +64,66 @@ func (r *AlertRule) Enabled() bool {
 	return r.Enable != nil && *r.Enable
 }
 
+// IsAggregate 汇总规则按服务器范围检查，同一报警规则中不能与其他规则混用
+func (r *AlertRule) IsAggregate() bool {
+	return len(r.Rules) > 0 && r.Rules[0].IsAggregateRule()
+}
+
+// IsServiceAlert 服务规则按服务检查，同一报警规则中不能与其他规则混用
+func (r *AlertRule) IsServiceAlert() bool {
+	return len(r.Rules) > 0 && r.Rules[0].IsServiceDownRule()
+}
+
+// CoversService 报警规则中的服务规则是否检查该服务
+func (r *AlertRule) CoversService(id uint64) bool {
+	return slices.ContainsFunc(r.Rules, func(rule *Rule) bool {
+		return rule.CoversService(id)
+	})
+}
+
+// ServiceSnapshot 对服务进行该报警规则下所有规则的检查，返回每项检查结果
+func (r *AlertRule) ServiceSnapshot(id uint64, down bool) []bool {
+	point := make([]bool, len(r.Rules))
+	for i, rule := range r.Rules {
+		point[i] = rule.ServiceSnapshot(id, down)
+	}
+	return point
+}
+
+// ServiceAlertRule 按服务监控的通知设置生成等价的 service_down 报警规则
+func ServiceAlertRule(s *Service) *AlertRule {
+	enable := true
+	return &AlertRule{
+		Common:              Common{UserID: s.UserID},
+		Name:                s.Name,
+		Enable:              &enable,
+		NotificationGroupID: s.NotificationGroupID,
+		ServiceID:           s.ID,
+		Rules: []*Rule{{
+			Type:     "service_down",
+			Services: []uint64{s.ID},
+			Duration: 3,
+		}},
+		FailTriggerTasks:    []uint64{},
+		RecoverTriggerTasks: []uint64{},
+	}
+}
+
+// UsesLatestAgentVersion 包含与最新版本比较的 agent_version 规则
+func (r *AlertRule) UsesLatestAgentVersion() bool {
+	return slices.ContainsFunc(r.Rules, (*Rule).usesLatestAgentVersion)
+}
+
+// SetLatestAgentVersion 更新 agent_version 规则使用的最新版本
+func (r *AlertRule) SetLatestAgentVersion(version string) {
+	for _, rule := range r.Rules {
+		rule.LatestAgentVersion = version
+		if rule.Condition != nil {
+			rule.Condition.LatestAgentVersion = version
+		}
+	}
+}
+
 // Snapshot 对传入的Server进行该报警规则下所有type的检查 返回每项检查结果
 func (r *AlertRule) Snapshot(cycleTransferStats *CycleTransferStats, server *Server, db *gorm.DB) []bool {
 	point := make([]bool, len(r.Rules))
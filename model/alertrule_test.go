@@ -315,3 +315,144 @@ func assertEq(t *testing.T, msg string, exp, act any) {
 		t.Fatalf("failed to test for %s. exp=[%v] but act=[%v]", msg, exp, act)
 	}
 }
+
+func TestCoreAndMountRules(t *testing.T) {
+	server := &Server{Host: &Host{}, State: &HostState{
+		CPU:        30,
+		CPUCores:   []float64{10, 95},
+		DiskMounts: []DiskMount{{Path: "/", Total: 100, Used: 20}, {Path: "/data", Total: 200, Used: 190}},
+		GPU:        []float64{40},
+		GPUs:       []GPUStat{{Utilization: 80, Temperature: 85}},
+	}}
+
+	cases := []struct {
+		rule *Rule
+		exp  bool
+	}{
+		{&Rule{Type: "cpu", Max: 90}, true},
+		{&Rule{Type: "cpu_core_max", Max: 90}, false},
+		{&Rule{Type: "cpu_core_max", Max: 95}, true},
+		{&Rule{Type: "disk_mount_max", Max: 90}, false},
+		{&Rule{Type: "disk_mount_max", Max: 95}, true},
+		{&Rule{Type: "gpu_max", Max: 50}, false},
+		{&Rule{Type: "gpu_max", Max: 80}, true},
+		{&Rule{Type: "gpu_temperature_max", Max: 80}, false},
+		{&Rule{Type: "gpu_temperature_max", Max: 90}, true},
+	}
+	for _, c := range cases {
+		if got := c.rule.Snapshot(nil, server, nil); got != c.exp {
+			t.Fatalf("%s max %v: expected %v, got %v", c.rule.Type, c.rule.Max, c.exp, got)
+		}
+	}
+
+	// 旧版 Agent 不上报明细时规则始终通过
+	old := &Server{Host: &Host{}, State: &HostState{CPU: 99}}
+	for _, typ := range []string{"cpu_core_max", "disk_mount_max", "gpu_max", "gpu_temperature_max"} {
+		if !(&Rule{Type: typ, Max: 1}).Snapshot(nil, old, nil) {
+			t.Fatalf("%s: expected pass without per-core or per-mount data", typ)
+		}
+	}
+}
+
+func TestContainerRule(t *testing.T) {
+	rule := &Rule{Type: "container", Container: "web"}
+
+	server := &Server{Host: &Host{}, State: &HostState{}}
+	if !rule.Snapshot(nil, server, nil) {
+		t.Fatal("expected pass when the agent does not report containers")
+	}
+
+	server.Containers = &ContainerReport{Containers: []Container{{Name: "web", State: ContainerStateRunning}}}
+	if !rule.Snapshot(nil, server, nil) {
+		t.Fatal("expected pass when the container is running")
+	}
+
+	server.Containers = &ContainerReport{Containers: []Container{{Name: "web", State: "exited"}}}
+	if rule.Snapshot(nil, server, nil) {
+		t.Fatal("expected failure when the container has exited")
+	}
+
+	// 容器被删除
+	server.Containers = &ContainerReport{Containers: []Container{{Name: "db", State: ContainerStateRunning}}}
+	if rule.Snapshot(nil, server, nil) {
+		t.Fatal("expected failure when the container is gone")
+	}
+}
+
+func TestTemperatureAndDiskHealthRules(t *testing.T) {
+	server := &Server{Host: &Host{}, State: &HostState{
+		Temperatures: []SensorTemperature{
+			{Name: "k10temp_tctl", Temperature: 88, Sensor: "cpu"},
+			{Name: "nvme_composite", Temperature: 50, Sensor: "nvme"},
+			{Name: "acpitz", Temperature: 0, Sensor: "acpi"},
+		},
+		DiskHealth: []DiskHealth{
+			{Device: "sda", SMARTPassed: true, WearLevel: 12},
+			{Device: "nvme0n1", SMARTPassed: true, WearLevel: 93},
+		},
+	}}
+
+	cases := []struct {
+		rule *Rule
+		exp  bool
+	}{
+		{&Rule{Type: "temperature_max", Max: 85}, false},
+		{&Rule{Type: "temperature_max", Max: 90}, true},
+		{&Rule{Type: "temperature_max", Max: 85, Sensor: "nvme"}, true},
+		{&Rule{Type: "temperature_max", Max: 40, Sensor: "nvme"}, false},
+		{&Rule{Type: "temperature_max", Max: 1, Sensor: "gpu"}, true},
+		{&Rule{Type: "disk_wear_max", Max: 90}, false},
+		{&Rule{Type: "disk_wear_max", Max: 95}, true},
+		{&Rule{Type: "smart_failed"}, true},
+	}
+	for _, c := range cases {
+		if got := c.rule.Snapshot(nil, server, nil); got != c.exp {
+			t.Fatalf("%s %s max %v: expected %v, got %v", c.rule.Type, c.rule.Sensor, c.rule.Max, c.exp, got)
+		}
+	}
+
+	server.State.DiskHealth[0].SMARTPassed = false
+	if (&Rule{Type: "smart_failed"}).Snapshot(nil, server, nil) {
+		t.Fatal("expected failure when a disk fails SMART")
+	}
+
+	// 未上报温度时不再 panic
+	empty := &Server{Host: &Host{}, State: &HostState{Temperatures: []SensorTemperature{}}}
+	if !(&Rule{Type: "temperature_max", Max: 1}).Snapshot(nil, empty, nil) {
+		t.Fatal("expected pass without temperatures")
+	}
+}
+
+func TestServiceDownRule(t *testing.T) {
+	alert := ServiceAlertRule(&Service{Common: Common{ID: 3, UserID: 1}, Name: "api", Notify: true, NotificationGroupID: 2})
+	if !alert.IsServiceAlert() || !alert.Enabled() || alert.ServiceID != 3 || alert.NotificationGroupID != 2 {
+		t.Fatalf("unexpected rule generated from service: %+v", alert)
+	}
+	if !alert.CoversService(3) || alert.CoversService(4) {
+		t.Fatal("expected generated rule to cover only its service")
+	}
+	if !(&Rule{Type: "service_down"}).CoversService(4) {
+		t.Fatal("expected rule without services to cover all services")
+	}
+
+	// 服务规则不针对服务器
+	if !alert.Rules[0].Snapshot(nil, &Server{Host: &Host{}, State: &HostState{}}, nil) {
+		t.Fatal("expected service rule to pass for servers")
+	}
+
+	var points [][]bool
+	for range 2 {
+		points = append(points, alert.ServiceSnapshot(3, true))
+	}
+	if _, passed := alert.Check(points); !passed {
+		t.Fatal("expected pass before the duration is reached")
+	}
+	points = append(points, alert.ServiceSnapshot(3, true))
+	if _, passed := alert.Check(points); passed {
+		t.Fatal("expected failure when the service stays down for the duration")
+	}
+	points = append(points, alert.ServiceSnapshot(3, false))
+	if _, passed := alert.Check(points); !passed {
+		t.Fatal("expected recovery once the service is up")
+	}
+}
@@ -0,0 +1,54 @@
+package model
+
+import (
+	"slices"
+	"time"
+
+	"github.com/goccy/go-json"
+	"gorm.io/gorm"
+)
+
+const (
+	AnnotationSeverityInfo     = "info"
+	AnnotationSeverityWarning  = "warning"
+	AnnotationSeverityCritical = "critical"
+)
+
+// Annotation 外部系统提交的事件标注，如部署记录，显示在服务器的历史图表中
+type Annotation struct {
+	Common
+	ExternalID   *string   `gorm:"uniqueIndex" json:"external_id,omitempty"` // 外部系统的 ID，重复提交时返回已有的标注
+	Time         time.Time `gorm:"index" json:"time"`
+	Text         string    `json:"text"`
+	Severity     string    `json:"severity"`
+	ServerIDs    []uint64  `gorm:"-" json:"server_ids"`
+	ServerIDsRaw string    `json:"-"`
+}
+
+func ValidAnnotationSeverity(s string) bool {
+	switch s {
+	case AnnotationSeverityInfo, AnnotationSeverityWarning, AnnotationSeverityCritical:
+		return true
+	}
+	return false
+}
+
+func (a *Annotation) BeforeSave(tx *gorm.DB) error {
+	data, err := json.Marshal(a.ServerIDs)
+	if err != nil {
+		return err
+	}
+	a.ServerIDsRaw = string(data)
+	return nil
+}
+
+func (a *Annotation) AfterFind(tx *gorm.DB) error {
+	if a.ServerIDsRaw == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(a.ServerIDsRaw), &a.ServerIDs)
+}
+
+func (a *Annotation) HasServer(id uint64) bool {
+	return slices.Contains(a.ServerIDs, id)
+}
@@ -8,6 +8,10 @@ type Oauth2LoginResponse struct {
 	Redirect string `json:"redirect,omitempty"`
 }
 
+type LogoutResponse struct {
+	Redirect string `json:"redirect,omitempty"` // 需要跳转到身份提供方登出时返回
+}
+
 type Oauth2Callback struct {
 	State string `json:"state,omitempty"`
 	Code  string `json:"code,omitempty"`
@@ -16,6 +20,7 @@ type Oauth2Callback struct {
 type LoginRequest struct {
 	Username string `json:"username,omitempty"`
 	Password string `json:"password,omitempty"`
+	OTP      string `json:"otp,omitempty"` // 启用两步验证时需要，可使用恢复码
 }
 
 type CommonResponse[T any] struct {
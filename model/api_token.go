@@ -0,0 +1,79 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+	"time"
+
+	"github.com/goccy/go-json"
+	"gorm.io/gorm"
+)
+
+// APITokenPrefix API Token 的前缀，用于与 JWT 区分
+const APITokenPrefix = "nzt_"
+
+const CtxKeyAPIToken = "ckat"
+
+const (
+	APITokenScopeRead        = "read"         // 只读
+	APITokenScopeServerWrite = "server:write" // 修改服务器及分组
+	APITokenScopeCronExecute = "cron:execute" // 执行计划任务及命令
+	APITokenScopeAnnotation  = "annotation"   // 提交事件标注
+	APITokenScopeAdmin       = "admin"        // 与用户本身相同的权限
+)
+
+var APITokenScopes = [...]string{APITokenScopeRead, APITokenScopeServerWrite, APITokenScopeCronExecute, APITokenScopeAnnotation, APITokenScopeAdmin}
+
+// APIToken 用户创建的 API Token，仅保存哈希
+type APIToken struct {
+	Common
+	Name       string     `json:"name"`
+	TokenHash  string     `gorm:"uniqueIndex" json:"-"`
+	Hint       string     `json:"hint"` // Token 的前几位，用于辨认
+	Scopes     []string   `gorm:"-" json:"scopes"`
+	ScopesRaw  string     `json:"-"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+type APITokenForm struct {
+	Name      string     `json:"name" minLength:"1"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty" validate:"optional"` // 为空时不过期
+}
+
+// APITokenCreated 创建时返回的 Token，之后无法再次获取
+type APITokenCreated struct {
+	*APIToken
+	Token string `json:"token"`
+}
+
+func (t *APIToken) BeforeSave(tx *gorm.DB) error {
+	data, err := json.Marshal(t.Scopes)
+	if err != nil {
+		return err
+	}
+	t.ScopesRaw = string(data)
+	return nil
+}
+
+func (t *APIToken) AfterFind(tx *gorm.DB) error {
+	if t.ScopesRaw == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(t.ScopesRaw), &t.Scopes)
+}
+
+func (t *APIToken) Expired() bool {
+	return t.ExpiresAt != nil && time.Now().After(*t.ExpiresAt)
+}
+
+func (t *APIToken) HasScope(scope string) bool {
+	return slices.Contains(t.Scopes, scope) || slices.Contains(t.Scopes, APITokenScopeAdmin)
+}
+
+func HashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
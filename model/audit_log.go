@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+// CtxKeyAuditSummary 处理函数指定的审计日志摘要，设置后替代隐藏了敏感字段的请求内容摘要
+const CtxKeyAuditSummary = "ckas"
+
+// AuditLog 修改操作的审计记录
+type AuditLog struct {
+	ID         uint64    `gorm:"primaryKey" json:"id"`
+	CreatedAt  time.Time `gorm:"index" json:"created_at"`
+	UserID     uint64    `gorm:"index" json:"user_id,omitempty"`
+	TokenID    uint64    `json:"token_id,omitempty"` // 使用 API Token 时的 Token ID
+	IP         string    `json:"ip,omitempty"`
+	Method     string    `json:"method"`
+	Route      string    `json:"route"`
+	EntityType string    `gorm:"index" json:"entity_type,omitempty"`
+	EntityID   string    `json:"entity_id,omitempty"`
+	Summary    string    `json:"summary,omitempty"` // 请求内容摘要，敏感字段已隐藏
+	Status     int       `json:"status"`
+	Success    bool      `json:"success"`
+	Error      string    `json:"error,omitempty"`
+}
@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+type BackupInfo struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// BackupEvent 备份完成或失败时推送到 Webhook 的内容
+type BackupEvent struct {
+	Event   string      `json:"event"` // backup_succeeded, backup_failed
+	Backup  *BackupInfo `json:"backup,omitempty"`
+	Error   string      `json:"error,omitempty"`
+	Trigger string      `json:"trigger"` // manual, schedule
+	Time    time.Time   `json:"time"`
+}
@@ -0,0 +1,38 @@
+package model
+
+import "time"
+
+const (
+	BrandingAssetLogo    = "logo"
+	BrandingAssetFavicon = "favicon"
+
+	BrandingAssetMaxSize = 512 << 10
+)
+
+var BrandingAssetNames = []string{BrandingAssetLogo, BrandingAssetFavicon}
+
+// 上传的图标只允许位图格式，SVG 可能包含脚本
+var BrandingAssetTypes = []string{"image/png", "image/jpeg", "image/gif", "image/webp", "image/x-icon"}
+
+var BrandingThemes = []string{"light", "dark", "system"}
+
+// BrandingAsset 上传的站点 Logo 与 favicon
+type BrandingAsset struct {
+	Name        string    `gorm:"primaryKey" json:"name"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"`
+	Content     []byte    `json:"-"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Branding 前端展示的站点标识，FooterHTML 已过滤
+type Branding struct {
+	SiteTitle       string `json:"site_title"`
+	LogoURL         string `json:"logo_url,omitempty"`
+	FaviconURL      string `json:"favicon_url,omitempty"`
+	FooterHTML      string `json:"footer_html,omitempty"`
+	CustomCSS       string `json:"custom_css,omitempty"`
+	DefaultLanguage string `json:"default_language,omitempty"`
+	DefaultTheme    string `json:"default_theme,omitempty"`
+}
@@ -0,0 +1,94 @@
+package model
+
+import (
+	"log"
+	"regexp"
+
+	"github.com/goccy/go-json"
+	"gorm.io/gorm"
+)
+
+const (
+	CommandPolicyAllow = "allow" // 命令需匹配任一规则
+	CommandPolicyDeny  = "deny"  // 命令不能匹配任何规则
+)
+
+// CommandPolicy 在创建、修改计划任务或执行命令时校验命令内容。
+// TargetUserID 不为 0 时仅对该用户生效，且优先于按角色生效的策略
+type CommandPolicy struct {
+	Common
+	Name         string   `json:"name"`
+	TargetUserID uint64   `json:"target_user_id,omitempty"`
+	Role         uint8    `json:"role"`
+	Mode         string   `json:"mode"`
+	Patterns     []string `gorm:"-" json:"patterns"`
+	PatternsRaw  string   `json:"-"`
+
+	compiled []*regexp.Regexp
+	invalid  bool // 数据库中的规则无法解析，拒绝所有命令
+}
+
+func (p *CommandPolicy) BeforeSave(tx *gorm.DB) error {
+	data, err := json.Marshal(p.Patterns)
+	if err != nil {
+		return err
+	}
+	p.PatternsRaw = string(data)
+	return nil
+}
+
+func (p *CommandPolicy) AfterFind(tx *gorm.DB) error {
+	if err := json.Unmarshal([]byte(p.PatternsRaw), &p.Patterns); err != nil {
+		log.Println("NEZHA>> CommandPolicy.AfterFind:", err)
+		p.invalid = true
+		return nil
+	}
+	if err := p.Compile(); err != nil {
+		log.Println("NEZHA>> CommandPolicy.AfterFind:", err)
+		p.invalid = true
+	}
+	return nil
+}
+
+// Compile 编译规则，保存前调用以校验正则表达式
+func (p *CommandPolicy) Compile() error {
+	compiled := make([]*regexp.Regexp, 0, len(p.Patterns))
+	for _, pattern := range p.Patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return err
+		}
+		compiled = append(compiled, re)
+	}
+	p.compiled = compiled
+	p.invalid = false
+	return nil
+}
+
+// Invalid 规则无法解析时策略拒绝所有命令，避免 deny 模式的策略失效
+func (p *CommandPolicy) Invalid() bool {
+	return p.invalid
+}
+
+func (p *CommandPolicy) AppliesTo(userID uint64, role uint8) bool {
+	if p.TargetUserID != 0 {
+		return p.TargetUserID == userID
+	}
+	return p.Role == role
+}
+
+// Violation 检查命令是否违反策略，deny 模式下返回匹配到的规则
+func (p *CommandPolicy) Violation(command string) (string, bool) {
+	if p.invalid {
+		return "", true
+	}
+	for i, re := range p.compiled {
+		if re.MatchString(command) {
+			if p.Mode == CommandPolicyDeny {
+				return p.Patterns[i], true
+			}
+			return "", false
+		}
+	}
+	return "", p.Mode == CommandPolicyAllow
+}
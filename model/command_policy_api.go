@@ -0,0 +1,9 @@
+package model
+
+type CommandPolicyForm struct {
+	Name         string   `json:"name" minLength:"1"`
+	TargetUserID uint64   `json:"target_user_id,omitempty" validate:"optional"` // 为 0 时按角色生效
+	Role         uint8    `json:"role,omitempty" validate:"optional"`
+	Mode         string   `json:"mode" enums:"allow,deny"`
+	Patterns     []string `json:"patterns"`
+}
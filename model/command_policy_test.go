@@ -0,0 +1,45 @@
+package model
+
+import "testing"
+
+func TestCommandPolicyViolation(t *testing.T) {
+	cases := []struct {
+		name        string
+		mode        string
+		patternsRaw string
+		command     string
+		violated    bool
+	}{
+		{"DenyMatch", CommandPolicyDeny, `["^rm "]`, "rm -rf /", true},
+		{"DenyNoMatch", CommandPolicyDeny, `["^rm "]`, "uptime", false},
+		{"AllowMatch", CommandPolicyAllow, `["^uptime$"]`, "uptime", false},
+		{"AllowNoMatch", CommandPolicyAllow, `["^uptime$"]`, "reboot", true},
+		// 无法解析的规则拒绝所有命令
+		{"DenyInvalidPattern", CommandPolicyDeny, `["^rm ", "("]`, "uptime", true},
+		{"DenyInvalidJSON", CommandPolicyDeny, `["^rm "`, "uptime", true},
+		{"AllowInvalidPattern", CommandPolicyAllow, `["^uptime$", "("]`, "uptime", true},
+	}
+
+	for _, c := range cases {
+		p := &CommandPolicy{Mode: c.mode, PatternsRaw: c.patternsRaw}
+		if err := p.AfterFind(nil); err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if _, violated := p.Violation(c.command); violated != c.violated {
+			t.Errorf("%s: expected violated=%v, got %v", c.name, c.violated, violated)
+		}
+	}
+
+	p := &CommandPolicy{Mode: CommandPolicyDeny, PatternsRaw: `["("]`}
+	p.AfterFind(nil)
+	if !p.Invalid() {
+		t.Fatal("expected policy with invalid pattern to be invalid")
+	}
+	p.Patterns = []string{"^rm "}
+	if err := p.Compile(); err != nil || p.Invalid() {
+		t.Fatalf("expected fixed patterns to compile, got %v", err)
+	}
+	if _, violated := p.Violation("uptime"); violated {
+		t.Error("expected fixed policy to allow unmatched commands")
+	}
+}
@@ -15,10 +15,14 @@ import (
 const (
 	CtxKeyAuthorizedUser = "ckau"
 	CtxKeyRealIPStr      = "ckri"
+	CtxKeyTenantScope    = "ckts"
 )
 
 const (
-	CacheKeyOauth2State = "cko2s::"
+	CacheKeyOauth2State   = "cko2s::"
+	CacheKeyOauth2IDToken = "cko2i::"
+	CacheKeyOauth2MFA     = "cko2m::"
+	CacheKeyNATTicket     = "cknt::"
 )
 
 type CtxKeyRealIP struct{}
@@ -46,14 +50,43 @@ func (c *Common) HasPermission(ctx *gin.Context) bool {
 		return false
 	}
 
+	if scope, ok := GetTenantScope(ctx); ok {
+		return scope.Contains(c.UserID)
+	}
+
 	user := *auth.(*User)
-	if user.Role == RoleAdmin {
+	if user.Role == RoleAdmin || user.Role == RoleViewer {
 		return true
 	}
 
 	return user.ID == c.UserID
 }
 
+// TenantScope 严格租户模式下请求可以访问的资源所属用户
+type TenantScope []uint64
+
+func (s TenantScope) Contains(uid uint64) bool {
+	return slices.Contains(s, uid)
+}
+
+// Key 用于区分不同租户的缓存
+func (s TenantScope) Key() string {
+	ids := make([]string, 0, len(s))
+	for _, id := range s {
+		ids = append(ids, strconv.FormatUint(id, 10))
+	}
+	return "tenant:" + strings.Join(ids, ",")
+}
+
+// GetTenantScope 返回请求的租户范围，ok 为 false 时不限制
+func GetTenantScope(ctx *gin.Context) (TenantScope, bool) {
+	v, ok := ctx.Get(CtxKeyTenantScope)
+	if !ok {
+		return nil, false
+	}
+	return v.(TenantScope), true
+}
+
 type CommonInterface interface {
 	GetID() uint64
 	GetUserID() uint64
@@ -1,9 +1,12 @@
 package model
 
 import (
+	"net/http/httptest"
 	"reflect"
 	"slices"
 	"testing"
+
+	"github.com/gin-gonic/gin"
 )
 
 func TestSearchByID(t *testing.T) {
@@ -47,3 +50,21 @@ func TestSearchByID(t *testing.T) {
 		}
 	})
 }
+
+func TestHasPermissionTenantScope(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Set(CtxKeyAuthorizedUser, &User{Common: Common{ID: 1}, Role: RoleAdmin})
+
+	own, other := &Common{UserID: 1}, &Common{UserID: 2}
+	if !own.HasPermission(c) || !other.HasPermission(c) {
+		t.Fatal("admin should access all resources without tenant scope")
+	}
+
+	c.Set(CtxKeyTenantScope, TenantScope{1})
+	if !own.HasPermission(c) {
+		t.Fatal("own resource should be accessible in tenant scope")
+	}
+	if other.HasPermission(c) {
+		t.Fatal("resource of another tenant should not be accessible")
+	}
+}
@@ -0,0 +1,112 @@
+package model
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	ConnStatsResolution     = 10 * time.Second // 连接数采样的最小间隔
+	ConnStatsWindow         = time.Hour        // 保留的连接数采样时长，也是变化量规则的最大时间窗口
+	ConnGrowthWindowDefault = 5 * time.Minute  // 变化量规则未指定时间窗口时的默认值
+)
+
+// ConnPeak 连接数的最高值及出现时间
+type ConnPeak struct {
+	Count uint64    `json:"count"`
+	At    time.Time `json:"at,omitempty"`
+}
+
+// SocketStats 面板启动以来 TCP、UDP 连接数的最高值
+type SocketStats struct {
+	TCPPeak ConnPeak `json:"tcp_peak"`
+	UDPPeak ConnPeak `json:"udp_peak"`
+}
+
+type connSample struct {
+	at       int64 // 秒级时间戳
+	tcp, udp uint64
+}
+
+// ConnStats 服务器连接数的最高值与最近一段时间的采样，采样用于计算连接数的变化量
+type ConnStats struct {
+	mu      sync.RWMutex
+	peaks   SocketStats
+	last    connSample
+	samples []connSample
+	next    int
+	full    bool
+}
+
+// Add 记录一次状态上报中的连接数
+func (s *ConnStats) Add(t time.Time, tcp, udp uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if tcp > s.peaks.TCPPeak.Count {
+		s.peaks.TCPPeak = ConnPeak{Count: tcp, At: t}
+	}
+	if udp > s.peaks.UDPPeak.Count {
+		s.peaks.UDPPeak = ConnPeak{Count: udp, At: t}
+	}
+
+	s.last = connSample{at: t.Unix(), tcp: tcp, udp: udp}
+	if s.samples == nil {
+		s.samples = make([]connSample, ConnStatsWindow/ConnStatsResolution)
+	}
+	if prev, ok := s.prev(); ok && s.last.at-prev.at < int64(ConnStatsResolution.Seconds()) {
+		return
+	}
+	s.samples[s.next] = s.last
+	s.next = (s.next + 1) % len(s.samples)
+	if s.next == 0 {
+		s.full = true
+	}
+}
+
+func (s *ConnStats) prev() (connSample, bool) {
+	if !s.full && s.next == 0 {
+		return connSample{}, false
+	}
+	return s.samples[(s.next-1+len(s.samples))%len(s.samples)], true
+}
+
+// Peaks 返回连接数的最高值
+func (s *ConnStats) Peaks() *SocketStats {
+	if s == nil {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.peaks.TCPPeak.At.IsZero() && s.peaks.UDPPeak.At.IsZero() {
+		return nil
+	}
+	peaks := s.peaks
+	return &peaks
+}
+
+// Growth 返回最近 window 内连接数的增长量，即当前值减去窗口内的最小值，proto 为 tcp 或 udp
+func (s *ConnStats) Growth(proto string, now time.Time, window time.Duration) uint64 {
+	if s == nil {
+		return 0
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	count := func(c connSample) uint64 {
+		if proto == "udp" {
+			return c.udp
+		}
+		return c.tcp
+	}
+	current, lowest := count(s.last), count(s.last)
+	since := now.Add(-window).Unix()
+	for i, c := range s.samples {
+		if (!s.full && i >= s.next) || c.at < since {
+			continue
+		}
+		lowest = min(lowest, count(c))
+	}
+	return current - lowest
+}
@@ -0,0 +1,39 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConnStats(t *testing.T) {
+	start := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	var s ConnStats
+
+	// 每 5 秒上报一次，TCP 连接数先下降后快速上升
+	counts := []uint64{3000, 2000, 1000, 1000, 1500, 4000, 7000}
+	for i, n := range counts {
+		s.Add(start.Add(time.Duration(i)*5*time.Second), n, 10)
+	}
+	now := start.Add(30 * time.Second)
+
+	if got := s.Growth("tcp", now, time.Minute); got != 6000 {
+		t.Fatalf("expected tcp growth 6000, got %d", got)
+	}
+	if got := s.Growth("udp", now, time.Minute); got != 0 {
+		t.Fatalf("expected udp growth 0, got %d", got)
+	}
+	// 窗口内只有按采样间隔保留的 20 秒时的 1500
+	if got := s.Growth("tcp", now, 10*time.Second); got != 5500 {
+		t.Fatalf("expected tcp growth 5500 in 10s, got %d", got)
+	}
+
+	peaks := s.Peaks()
+	if peaks.TCPPeak.Count != 7000 || !peaks.TCPPeak.At.Equal(now) || peaks.UDPPeak.Count != 10 {
+		t.Fatalf("unexpected peaks: %+v", peaks)
+	}
+
+	var empty *ConnStats
+	if empty.Peaks() != nil || empty.Growth("tcp", now, time.Minute) != 0 {
+		t.Fatal("expected nil stats to report nothing")
+	}
+}
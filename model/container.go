@@ -0,0 +1,66 @@
+package model
+
+import (
+	"math"
+	"slices"
+	"time"
+)
+
+const (
+	ContainerStateRunning = "running"
+
+	ReportMaxContainers = 512 // 上报的容器的最大数量
+	// ContainerReportMinInterval 容器信息的最短上报间隔，更频繁的上报会被忽略
+	ContainerReportMinInterval = 10 * time.Second
+)
+
+// Container Agent 上报的单个容器的状态
+type Container struct {
+	ID           string  `json:"id,omitempty"`
+	Name         string  `json:"name"`
+	Image        string  `json:"image,omitempty"`
+	State        string  `json:"state"`                   // running、exited、paused 等
+	CPU          float64 `json:"cpu"`                     // CPU 使用率（百分比，多核时可超过 100）
+	MemUsed      uint64  `json:"mem_used,omitempty"`      // 内存用量（字节）
+	MemLimit     uint64  `json:"mem_limit,omitempty"`     // 内存限制（字节），0 为不限制
+	RestartCount uint64  `json:"restart_count,omitempty"` // 重启次数
+	Uptime       uint64  `json:"uptime,omitempty"`        // 运行时间（秒）
+}
+
+// ContainerReport Agent 检测到容器运行时后定期上报的容器列表
+type ContainerReport struct {
+	Runtime    string      `json:"runtime,omitempty"` // docker、podman 等
+	Containers []Container `json:"containers"`
+	UpdatedAt  time.Time   `json:"updated_at"`
+}
+
+// Sanitize 截短过长的列表与字符串，重置无效的数值
+func (r *ContainerReport) Sanitize() {
+	r.Runtime = truncateString(r.Runtime, ReportMaxStringLen)
+	r.Containers = truncateSlice(r.Containers, ReportMaxContainers)
+	for i := range r.Containers {
+		c := &r.Containers[i]
+		c.ID = truncateString(c.ID, ReportMaxStringLen)
+		c.Name = truncateString(c.Name, ReportMaxStringLen)
+		c.Image = truncateString(c.Image, ReportMaxStringLen)
+		c.State = truncateString(c.State, ReportMaxStringLen)
+		if math.IsNaN(c.CPU) || math.IsInf(c.CPU, 0) || c.CPU < 0 {
+			c.CPU = 0
+		}
+		c.CPU = min(c.CPU, 100*ReportMaxCPUCores)
+		if c.MemUsed > ReportMaxBytes {
+			c.MemUsed = 0
+		}
+		if c.MemLimit > ReportMaxBytes {
+			c.MemLimit = 0
+		}
+		c.Uptime = min(c.Uptime, ReportMaxUptime)
+	}
+}
+
+// Running 名称为 name 的容器是否在运行，容器不存在时返回 false
+func (r *ContainerReport) Running(name string) bool {
+	return slices.ContainsFunc(r.Containers, func(c Container) bool {
+		return c.Name == name && c.State == ContainerStateRunning
+	})
+}
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"log"
 	"time"
 
 	"github.com/goccy/go-json"
@@ -12,25 +13,73 @@ const (
 	CronCoverIgnoreAll = iota
 	CronCoverAll
 	CronCoverAlertTrigger
+	CronCoverSelector   // 按 Selector 在执行时选择服务器
 	CronTypeCronTask    = 0
 	CronTypeTriggerTask = 1
 )
 
+const (
+	CronConcurrencyAllow = "allow" // 允许同时执行
+	CronConcurrencySkip  = "skip"  // 上一次仍在执行时跳过本次
+	CronConcurrencyQueue = "queue" // 上一次执行完成后再执行，最多排队一次
+)
+
 type Cron struct {
 	Common
 	Name                string    `json:"name"`
 	TaskType            uint8     `gorm:"default:0" json:"task_type"` // 0:计划任务 1:触发任务
-	Scheduler           string    `json:"scheduler"`                  // 分钟 小时 天 月 星期
+	Scheduler           string    `json:"scheduler"`                  // 秒 分钟 小时 天 月 星期
+	Timezone            string    `json:"timezone,omitempty"`         // 调度使用的时区，为空时使用面板时区
 	Command             string    `json:"command,omitempty"`
 	Servers             []uint64  `gorm:"-" json:"servers"`
-	PushSuccessful      bool      `json:"push_successful,omitempty"`  // 推送成功的通知
-	NotificationGroupID uint64    `json:"notification_group_id"`      // 指定通知方式的分组
-	LastExecutedAt      time.Time `json:"last_executed_at,omitempty"` // 最后一次执行时间
-	LastResult          bool      `json:"last_result,omitempty"`      // 最后一次执行结果
-	Cover               uint8     `json:"cover"`                      // 计划任务覆盖范围 (0:仅覆盖特定服务器 1:仅忽略特定服务器 2:由触发该计划任务的服务器执行)
-
-	CronJobID  cron.EntryID `gorm:"-" json:"cron_job_id,omitempty"`
-	ServersRaw string       `json:"-"`
+	PushSuccessful      bool      `json:"push_successful,omitempty"`                           // 推送成功的通知
+	NotificationGroupID uint64    `json:"notification_group_id"`                               // 指定通知方式的分组
+	LastExecutedAt      time.Time `json:"last_executed_at,omitempty"`                          // 最后一次执行时间
+	LastResult          bool      `json:"last_result,omitempty"`                               // 最后一次执行结果
+	Cover               uint8     `json:"cover"`                                               // 计划任务覆盖范围 (0:仅覆盖特定服务器 1:仅忽略特定服务器 2:由触发该计划任务的服务器执行)
+	TimeoutSeconds      uint32    `json:"timeout_seconds,omitempty"`                           // 单次执行超时时间，0 为不限制
+	ConcurrencyPolicy   string    `gorm:"default:'allow'" json:"concurrency_policy,omitempty"` // 同一服务器上一次执行未完成时的处理方式
+	NotifyTimeout       bool      `json:"notify_timeout,omitempty"`                            // 执行超时时发送通知
+	OnSuccessCronID     uint64    `json:"on_success_cron_id,omitempty"`                        // 执行成功后在同一服务器上执行的任务
+	OnFailureCronID     uint64    `json:"on_failure_cron_id,omitempty"`                        // 执行失败或超时后在同一服务器上执行的任务
+	PushFileID          uint64    `json:"push_file_id,omitempty"`                              // 执行命令前推送到服务器的文件
+	PushFilePath        string    `json:"push_file_path,omitempty"`                            // 推送文件的目标路径
+	PushFileMode        uint32    `json:"push_file_mode,omitempty"`                            // 推送文件的权限
+	CommandTemplate     bool      `json:"command_template,omitempty"`                          // 命令是否按模板展开，未开启时原样执行
+
+	Selector *ServerSelector `gorm:"-" json:"selector,omitempty"` // Cover 为 CronCoverSelector 时的服务器选择条件
+	Env      []CronEnv       `gorm:"-" json:"env,omitempty"`      // 环境变量，敏感值加密存储
+
+	CronJobID       cron.EntryID          `gorm:"-" json:"cron_job_id,omitempty"`
+	NextRunAt       *time.Time            `gorm:"-" json:"next_run_at,omitempty"`      // 调度器中的下次执行时间
+	LastRunAt       *time.Time            `gorm:"-" json:"last_run_at,omitempty"`      // 调度器上次触发的时间，面板重启后清空
+	Stats           *CronStats            `gorm:"-" json:"stats,omitempty"`            // 近 30 天的执行统计
+	ServerStats     map[uint64]*CronStats `gorm:"-" json:"server_stats,omitempty"`     // 近 30 天各服务器的执行统计
+	PolicyViolation string                `gorm:"-" json:"policy_violation,omitempty"` // 违反当前命令策略的原因
+	ServersRaw      string                `json:"-"`
+	SelectorRaw     string                `gorm:"default:'{}'" json:"-"`
+	EnvRaw          string                `gorm:"default:'[]'" json:"-"`
+}
+
+// NeedsTaskOptions 配置了超时时间或环境变量时需要 Agent 支持 JSON 格式的任务数据
+func (c *Cron) NeedsTaskOptions() bool {
+	return c.TimeoutSeconds > 0 || len(c.Env) > 0
+}
+
+// TaskData 返回下发给 Agent 的任务数据，配置了超时时间或环境变量时以 JSON 格式下发
+func (c *Cron) TaskData(command string, env map[string]string) string {
+	if c.TimeoutSeconds == 0 && len(env) == 0 {
+		return command
+	}
+	data, err := json.Marshal(CronTaskData{
+		Command: command,
+		Timeout: c.TimeoutSeconds,
+		Env:     env,
+	})
+	if err != nil {
+		return command
+	}
+	return string(data)
 }
 
 func (c *Cron) BeforeSave(tx *gorm.DB) error {
@@ -39,9 +88,36 @@ func (c *Cron) BeforeSave(tx *gorm.DB) error {
 	} else {
 		c.ServersRaw = string(data)
 	}
+	if data, err := json.Marshal(c.Env); err != nil {
+		return err
+	} else {
+		c.EnvRaw = string(data)
+	}
+	if c.Selector.IsEmpty() {
+		c.SelectorRaw = "{}"
+	} else if data, err := json.Marshal(c.Selector); err != nil {
+		return err
+	} else {
+		c.SelectorRaw = string(data)
+	}
 	return nil
 }
 
 func (c *Cron) AfterFind(tx *gorm.DB) error {
-	return json.Unmarshal([]byte(c.ServersRaw), &c.Servers)
+	if err := json.Unmarshal([]byte(c.ServersRaw), &c.Servers); err != nil {
+		return err
+	}
+	if c.SelectorRaw != "" && c.SelectorRaw != "{}" {
+		c.Selector = new(ServerSelector)
+		if err := json.Unmarshal([]byte(c.SelectorRaw), c.Selector); err != nil {
+			log.Println("NEZHA>> Cron.AfterFind:", err)
+			c.Selector = nil
+		}
+	}
+	if c.EnvRaw != "" {
+		if err := json.Unmarshal([]byte(c.EnvRaw), &c.Env); err != nil {
+			log.Println("NEZHA>> Cron.AfterFind:", err)
+		}
+	}
+	return nil
 }
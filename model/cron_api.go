@@ -1,12 +1,42 @@
 package model
 
+import "time"
+
 type CronForm struct {
-	TaskType            uint8    `json:"task_type,omitempty" default:"0"` // 0:计划任务 1:触发任务
-	Name                string   `json:"name,omitempty" minLength:"1"`
-	Scheduler           string   `json:"scheduler,omitempty"`
-	Command             string   `json:"command,omitempty" validate:"optional"`
-	Servers             []uint64 `json:"servers,omitempty"`
-	Cover               uint8    `json:"cover,omitempty" default:"0"`
-	PushSuccessful      bool     `json:"push_successful,omitempty" validate:"optional"`
-	NotificationGroupID uint64   `json:"notification_group_id,omitempty"`
+	TaskType            uint8           `json:"task_type,omitempty" default:"0"` // 0:计划任务 1:触发任务
+	Name                string          `json:"name,omitempty" minLength:"1"`
+	Scheduler           string          `json:"scheduler,omitempty"`
+	Timezone            string          `json:"timezone,omitempty" validate:"optional"`
+	Command             string          `json:"command,omitempty" validate:"optional"`
+	Servers             []uint64        `json:"servers,omitempty"`
+	Cover               uint8           `json:"cover,omitempty" default:"0"`
+	PushSuccessful      bool            `json:"push_successful,omitempty" validate:"optional"`
+	NotificationGroupID uint64          `json:"notification_group_id,omitempty"`
+	TimeoutSeconds      uint32          `json:"timeout_seconds,omitempty" validate:"optional"`
+	ConcurrencyPolicy   string          `json:"concurrency_policy,omitempty" validate:"optional" enums:"allow,skip,queue"`
+	NotifyTimeout       bool            `json:"notify_timeout,omitempty" validate:"optional"`
+	Selector            *ServerSelector `json:"selector,omitempty" validate:"optional"`
+	Env                 []CronEnv       `json:"env,omitempty" validate:"optional"` // 敏感值为占位符时保留原值
+	OnSuccessCronID     uint64          `json:"on_success_cron_id,omitempty" validate:"optional"`
+	OnFailureCronID     uint64          `json:"on_failure_cron_id,omitempty" validate:"optional"`
+	PushFileID          uint64          `json:"push_file_id,omitempty" validate:"optional"` // 执行命令前推送的文件，仅管理员可设置
+	PushFilePath        string          `json:"push_file_path,omitempty" validate:"optional"`
+	PushFileMode        uint32          `json:"push_file_mode,omitempty" validate:"optional"`
+	CommandTemplate     bool            `json:"command_template,omitempty" validate:"optional"` // 开启后命令中的 {{ }} 按模板展开
+}
+
+type CronDetail struct {
+	*Cron
+	ResolvedServers []uint64    `json:"resolved_servers"`    // 当前会执行该任务的服务器
+	NextRuns        []time.Time `json:"next_runs,omitempty"` // 之后的执行时间
+}
+
+type CronRunForm struct {
+	Servers      []uint64 `json:"servers,omitempty" validate:"optional"`       // 本次执行的服务器，为空时使用任务配置
+	ServerGroups []uint64 `json:"server_groups,omitempty" validate:"optional"` // 本次执行的服务器分组
+}
+
+type ServerExecForm struct {
+	Command        string `json:"command" minLength:"1"`
+	TimeoutSeconds uint32 `json:"timeout_seconds,omitempty" validate:"optional"`
 }
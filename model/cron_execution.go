@@ -0,0 +1,93 @@
+package model
+
+import (
+	"strings"
+	"time"
+
+	"github.com/goccy/go-json"
+	"gorm.io/gorm"
+)
+
+const (
+	CronExecutionRunning = "running"
+	CronExecutionSuccess = "success"
+	CronExecutionFailure = "failure"
+	CronExecutionTimeout = "timeout"
+	CronExecutionSkipped = "skipped"
+	CronExecutionOffline = "offline"
+)
+
+const cronExecutionOutputLimit = 16 << 10
+
+// CronExecution 计划任务在单台服务器上的一次执行记录
+type CronExecution struct {
+	ID         uint64     `gorm:"primaryKey" json:"id"`
+	CronID     uint64     `gorm:"index:idx_cron_execution" json:"cron_id"`
+	RunID      uint64     `gorm:"index" json:"run_id,omitempty"`
+	ServerID   uint64     `json:"server_id"`
+	ParentID   uint64     `json:"parent_id,omitempty"` // 由上一个任务的执行结果触发时，上一次执行的 ID
+	Depth      uint8      `json:"depth,omitempty"`     // 在任务链中的深度
+	Status     string     `json:"status"`
+	StartedAt  time.Time  `gorm:"index:idx_cron_execution" json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Output     string     `json:"output,omitempty"`
+}
+
+// CronRun 计划任务的一次触发，记录本次选中的服务器
+type CronRun struct {
+	ID         uint64    `gorm:"primaryKey" json:"id"`
+	CronID     uint64    `gorm:"index" json:"cron_id"`
+	CreatedAt  time.Time `json:"created_at"`
+	TargetsRaw string    `json:"-"`
+	Targets    []uint64  `gorm:"-" json:"targets"`
+}
+
+func (r *CronRun) BeforeSave(tx *gorm.DB) error {
+	data, err := json.Marshal(r.Targets)
+	if err != nil {
+		return err
+	}
+	r.TargetsRaw = string(data)
+	return nil
+}
+
+func (r *CronRun) AfterFind(tx *gorm.DB) error {
+	if r.TargetsRaw == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(r.TargetsRaw), &r.Targets)
+}
+
+// CronTaskData 计划任务数据，超时后 Agent 结束整个进程组
+type CronTaskData struct {
+	Command string            `json:"command"`
+	Timeout uint32            `json:"timeout,omitempty"` // 秒
+	Env     map[string]string `json:"env,omitempty"`     // 执行命令时设置的环境变量
+}
+
+// CronTaskResult Agent 上报的计划任务执行结果
+type CronTaskResult struct {
+	Output   string `json:"output"`
+	TimedOut bool   `json:"timed_out,omitempty"`
+}
+
+// ParseCronTaskResult 解析 Agent 上报的数据，旧版 Agent 直接上报命令输出
+func ParseCronTaskResult(data string) CronTaskResult {
+	if strings.HasPrefix(data, "{") {
+		var r CronTaskResult
+		if err := json.Unmarshal([]byte(data), &r); err == nil {
+			return r
+		}
+	}
+	return CronTaskResult{Output: data}
+}
+
+func (e *CronExecution) Finish(status, output string) {
+	now := time.Now()
+	e.Status = status
+	e.FinishedAt = &now
+	if len(output) > cronExecutionOutputLimit {
+		output = strings.ToValidUTF8(output[:cronExecutionOutputLimit], "")
+	}
+	e.Output = output
+}
@@ -0,0 +1,69 @@
+package model
+
+import (
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// CronPreviewCount 预览的执行次数
+const CronPreviewCount = 5
+
+// 与面板调度器相同的解析规则：秒 分 时 日 月 星期
+var cronParser = cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// ParseCronSchedule 使用面板调度器的规则解析表达式
+func ParseCronSchedule(spec string) (cron.Schedule, error) {
+	return cronParser.Parse(spec)
+}
+
+type CronScheduleForm struct {
+	Scheduler string `json:"scheduler" minLength:"1"`
+	Timezone  string `json:"timezone,omitempty" validate:"optional"` // 为空时使用面板时区
+}
+
+type CronSchedulePreview struct {
+	Timezone string      `json:"timezone"`
+	NextRuns []time.Time `json:"next_runs"`
+}
+
+// Spec 返回注册到调度器的表达式，设置了时区时添加 CRON_TZ 前缀
+func (c *Cron) Spec() string {
+	return cronSpec(c.Scheduler, c.Timezone)
+}
+
+func cronSpec(scheduler, timezone string) string {
+	if timezone == "" {
+		return scheduler
+	}
+	return "CRON_TZ=" + timezone + " " + scheduler
+}
+
+// PreviewCronSchedule 校验调度表达式及时区，返回之后的执行时间，loc 为面板时区
+func PreviewCronSchedule(scheduler, timezone string, loc *time.Location, n int) (*CronSchedulePreview, error) {
+	if timezone != "" {
+		tz, err := time.LoadLocation(timezone)
+		if err != nil {
+			return nil, err
+		}
+		loc = tz
+	}
+	sched, err := cronParser.Parse(cronSpec(scheduler, timezone))
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &CronSchedulePreview{
+		Timezone: loc.String(),
+		NextRuns: make([]time.Time, 0, n),
+	}
+	t := time.Now().In(loc)
+	for range n {
+		t = sched.Next(t)
+		if t.IsZero() {
+			break
+		}
+		preview.NextRuns = append(preview.NextRuns, t)
+	}
+	return preview, nil
+}
@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+// CronStatDaily 计划任务在单台服务器上的每日执行统计，在上报结果时增量累加
+type CronStatDaily struct {
+	ID            uint64     `gorm:"primaryKey" json:"-"`
+	CronID        uint64     `gorm:"uniqueIndex:idx_cron_stat_daily" json:"cron_id"`
+	ServerID      uint64     `gorm:"uniqueIndex:idx_cron_stat_daily" json:"server_id"`
+	Date          time.Time  `gorm:"uniqueIndex:idx_cron_stat_daily" json:"date"`
+	Total         uint64     `json:"total"`
+	Success       uint64     `json:"success"`
+	Duration      uint64     `json:"duration"`       // 已执行完成的耗时总和，毫秒
+	DurationCount uint64     `json:"duration_count"` // 计入耗时的执行次数
+	LastFailureAt *time.Time `json:"last_failure_at,omitempty"`
+}
+
+// CronStats 计划任务近期的执行统计
+type CronStats struct {
+	Total         uint64     `json:"total"`
+	SuccessRate   float64    `json:"success_rate"` // 百分比
+	AvgDuration   uint64     `json:"avg_duration"` // 毫秒
+	LastFailureAt *time.Time `json:"last_failure_at,omitempty"`
+}
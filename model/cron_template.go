@@ -0,0 +1,113 @@
+package model
+
+import (
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+const (
+	SecretPlaceholder = "******" // 读取接口中敏感信息的占位符
+	EncryptedPrefix   = "enc:"
+)
+
+var cronEnvKeyRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// CronEnv 计划任务环境变量，Secret 为真时加密存储且读取时隐藏
+type CronEnv struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Secret bool   `json:"secret,omitempty"`
+}
+
+// CronTemplateData 命令模板中可以使用的数据，仅暴露以下字段
+type CronTemplateData struct {
+	Server CronTemplateServer
+}
+
+type CronTemplateServer struct {
+	ID    uint64
+	Name  string
+	GeoIP CronTemplateGeoIP
+}
+
+type CronTemplateGeoIP struct {
+	CountryCode string
+}
+
+func NewCronTemplateData(s *Server) CronTemplateData {
+	d := CronTemplateData{
+		Server: CronTemplateServer{
+			ID:   s.ID,
+			Name: s.Name,
+		},
+	}
+	if s.GeoIP != nil {
+		d.Server.GeoIP.CountryCode = s.GeoIP.CountryCode
+	}
+	return d
+}
+
+// isTemplate 只有显式开启模板的任务才展开命令，避免命令中原有的 {{ }} 被解析
+func (c *Cron) isTemplate() bool {
+	return c.CommandTemplate
+}
+
+// ValidateCommand 检查命令模板语法及引用的字段
+func (c *Cron) ValidateCommand() error {
+	if !c.isTemplate() {
+		return nil
+	}
+	tmpl, err := template.New("command").Option("missingkey=error").Parse(c.Command)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(io.Discard, CronTemplateData{})
+}
+
+// RenderCommand 使用服务器信息展开命令模板
+func (c *Cron) RenderCommand(s *Server) (string, error) {
+	if !c.isTemplate() {
+		return c.Command, nil
+	}
+	tmpl, err := template.New("command").Option("missingkey=error").Parse(c.Command)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, NewCronTemplateData(s)); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+func ValidateCronEnv(env []CronEnv) error {
+	keys := make(map[string]bool, len(env))
+	for _, e := range env {
+		if !cronEnvKeyRe.MatchString(e.Key) {
+			return errors.New("invalid environment variable name " + e.Key)
+		}
+		if keys[e.Key] {
+			return errors.New("duplicate environment variable " + e.Key)
+		}
+		keys[e.Key] = true
+	}
+	return nil
+}
+
+// RedactedEnv 返回隐藏了敏感值的环境变量
+func (c *Cron) RedactedEnv() []CronEnv {
+	if c.Env == nil {
+		return nil
+	}
+	env := make([]CronEnv, len(c.Env))
+	for i, e := range c.Env {
+		env[i] = e
+		if e.Secret {
+			env[i].Value = SecretPlaceholder
+		}
+	}
+	return env
+}
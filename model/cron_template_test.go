@@ -0,0 +1,25 @@
+package model
+
+import "testing"
+
+func TestCronCommandTemplate(t *testing.T) {
+	s := &Server{Common: Common{ID: 7}, Name: "web", GeoIP: &GeoIP{CountryCode: "jp"}}
+
+	// 未开启模板时命令原样下发
+	cr := &Cron{Command: `echo '{{ .Server.Name }}'`}
+	if err := cr.ValidateCommand(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cmd, err := cr.RenderCommand(s); err != nil || cmd != cr.Command {
+		t.Fatalf("expected command to be kept as is, got %q, %v", cmd, err)
+	}
+
+	cr.CommandTemplate = true
+	if cmd, err := cr.RenderCommand(s); err != nil || cmd != "echo 'web'" {
+		t.Fatalf("unexpected rendered command %q, %v", cmd, err)
+	}
+	cr.Command = "echo {{ .Server.Secret }}"
+	if cr.ValidateCommand() == nil {
+		t.Fatal("expected unknown field to be rejected")
+	}
+}
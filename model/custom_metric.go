@@ -0,0 +1,78 @@
+package model
+
+import (
+	"math"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const (
+	ReportMaxCustomMetrics = 32 // 每次上报的自定义指标的最大数量
+
+	// CustomMetricRulePrefix 报警规则类型为 custom.<name> 时检查名称为 name 的自定义指标
+	CustomMetricRulePrefix = "custom."
+)
+
+var customMetricNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_.\-]{0,63}$`)
+
+// ValidCustomMetricName 指标名称以字母或下划线开头，只包含字母、数字、下划线、点与连字符，最长 64 个字符
+func ValidCustomMetricName(name string) bool {
+	return customMetricNamePattern.MatchString(name)
+}
+
+// CustomMetricSample 自定义指标历史中的一个采样点
+type CustomMetricSample struct {
+	Time  int64   `json:"time"` // 毫秒时间戳
+	Value float64 `json:"value"`
+}
+
+// DownsampleCustomMetricSamples 同 DownsampleStateSamples，每组取平均值
+func DownsampleCustomMetricSamples(samples []CustomMetricSample, points int) []CustomMetricSample {
+	if points <= 0 || len(samples) <= points {
+		return samples
+	}
+
+	ret := make([]CustomMetricSample, 0, points)
+	for i := range points {
+		start, end := i*len(samples)/points, (i+1)*len(samples)/points
+		if start == end {
+			continue
+		}
+		var sum float64
+		for _, s := range samples[start:end] {
+			sum += s.Value
+		}
+		ret = append(ret, CustomMetricSample{Time: samples[end-1].Time, Value: sum / float64(end-start)})
+	}
+	return ret
+}
+
+// sanitizeCustomMetrics 丢弃名称无效或数值非有限的指标，超出数量上限时按名称排序保留前面的指标，
+// 保证每次上报保留的指标相同
+func sanitizeCustomMetrics(metrics map[string]float64) map[string]float64 {
+	for name, v := range metrics {
+		if !ValidCustomMetricName(name) || math.IsNaN(v) || math.IsInf(v, 0) {
+			delete(metrics, name)
+		}
+	}
+	if len(metrics) > ReportMaxCustomMetrics {
+		names := make([]string, 0, len(metrics))
+		for name := range metrics {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		for _, name := range names[ReportMaxCustomMetrics:] {
+			delete(metrics, name)
+		}
+	}
+	if len(metrics) == 0 {
+		return nil
+	}
+	return metrics
+}
+
+// CustomMetric 返回规则检查的自定义指标名称，不是自定义指标规则时 ok 为 false
+func (u *Rule) CustomMetric() (name string, ok bool) {
+	return strings.CutPrefix(u.Type, CustomMetricRulePrefix)
+}
@@ -0,0 +1,76 @@
+package model
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeCustomMetrics(t *testing.T) {
+	s := &HostState{CustomMetrics: map[string]float64{
+		"queue_depth":           3,
+		"http.rps":              120.5,
+		"1bad":                  1,
+		"with space":            1,
+		"nan":                   math.NaN(),
+		"inf":                   math.Inf(1),
+		"app-latency":           -2,
+		"_private.ms":           0,
+		strings.Repeat("x", 65): 1,
+	}}
+	if err := s.Sanitize(nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"queue_depth", "http.rps", "app-latency", "_private.ms"} {
+		if _, ok := s.CustomMetrics[name]; !ok {
+			t.Errorf("expected %s to be kept", name)
+		}
+	}
+	if len(s.CustomMetrics) != 4 {
+		t.Errorf("expected invalid metrics to be dropped, got %v", s.CustomMetrics)
+	}
+
+	// 超出数量上限时按名称保留，每次上报保留的指标相同
+	many := make(map[string]float64)
+	for i := range ReportMaxCustomMetrics + 8 {
+		many[fmt.Sprintf("m%02d", i)] = float64(i)
+	}
+	s = &HostState{CustomMetrics: many}
+	if err := s.Sanitize(nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.CustomMetrics) != ReportMaxCustomMetrics {
+		t.Fatalf("expected %d metrics, got %d", ReportMaxCustomMetrics, len(s.CustomMetrics))
+	}
+	if _, ok := s.CustomMetrics[fmt.Sprintf("m%02d", ReportMaxCustomMetrics)]; ok {
+		t.Error("expected metrics sorted after the limit to be dropped")
+	}
+}
+
+func TestCustomMetricRule(t *testing.T) {
+	server := &Server{State: &HostState{CustomMetrics: map[string]float64{"queue": 150}}}
+
+	rule := &Rule{Type: "custom.queue", Max: 100}
+	if rule.Snapshot(nil, server, nil) {
+		t.Error("expected rule to fail when metric exceeds max")
+	}
+	rule.Max = 200
+	if !rule.Snapshot(nil, server, nil) {
+		t.Error("expected rule to pass when metric is within range")
+	}
+
+	// 未上报的指标不检查
+	rule = &Rule{Type: "custom.missing", Max: 1}
+	if !rule.Snapshot(nil, server, nil) {
+		t.Error("expected rule to pass when metric is not reported")
+	}
+}
+
+func TestDownsampleCustomMetricSamples(t *testing.T) {
+	samples := []CustomMetricSample{{1, 1}, {2, 3}, {3, 5}, {4, 7}}
+	got := DownsampleCustomMetricSamples(samples, 2)
+	if len(got) != 2 || got[0] != (CustomMetricSample{2, 2}) || got[1] != (CustomMetricSample{4, 6}) {
+		t.Errorf("unexpected downsampled samples: %v", got)
+	}
+}
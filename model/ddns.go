@@ -11,28 +11,87 @@ const (
 	ProviderCloudflare   = "cloudflare"
 	ProviderTencentCloud = "tencentcloud"
 	ProviderHE           = "he"
+	ProviderDuckDNS      = "duckdns"
+	ProviderPorkbun      = "porkbun"
+	ProviderHetzner      = "hetzner"
 )
 
 var ProviderList = [...]string{
 	ProviderDummy, ProviderWebHook, ProviderCloudflare, ProviderTencentCloud, ProviderHE,
+	ProviderDuckDNS, ProviderPorkbun, ProviderHetzner,
 }
 
 type DDNSProfile struct {
 	Common
-	EnableIPv4         *bool    `json:"enable_ipv4,omitempty"`
-	EnableIPv6         *bool    `json:"enable_ipv6,omitempty"`
-	MaxRetries         uint64   `json:"max_retries"`
-	Name               string   `json:"name"`
-	Provider           string   `json:"provider"`
-	AccessID           string   `json:"access_id,omitempty"`
-	AccessSecret       string   `json:"access_secret,omitempty"`
-	WebhookURL         string   `json:"webhook_url,omitempty"`
-	WebhookMethod      uint8    `json:"webhook_method,omitempty"`
-	WebhookRequestType uint8    `json:"webhook_request_type,omitempty"`
-	WebhookRequestBody string   `json:"webhook_request_body,omitempty"`
-	WebhookHeaders     string   `json:"webhook_headers,omitempty"`
-	Domains            []string `json:"domains" gorm:"-"`
-	DomainsRaw         string   `json:"-"`
+	EnableIPv4         *bool        `json:"enable_ipv4,omitempty"`
+	EnableIPv6         *bool        `json:"enable_ipv6,omitempty"`
+	MaxRetries         uint64       `json:"max_retries"`
+	Name               string       `json:"name"`
+	Provider           string       `json:"provider"`
+	AccessID           string       `json:"access_id,omitempty"`
+	AccessSecret       string       `json:"access_secret,omitempty" gorm:"serializer:secret"`
+	WebhookURL         string       `json:"webhook_url,omitempty" gorm:"serializer:secret"`
+	WebhookMethod      uint8        `json:"webhook_method,omitempty"`
+	WebhookRequestType uint8        `json:"webhook_request_type,omitempty"`
+	WebhookRequestBody string       `json:"webhook_request_body,omitempty" gorm:"serializer:secret"`
+	WebhookHeaders     string       `json:"webhook_headers,omitempty" gorm:"serializer:secret"`
+	DryRun             bool         `json:"dry_run,omitempty"`           // 仅记录将要进行的修改，不调用服务商接口
+	Verify             bool         `json:"verify,omitempty"`            // 通过公共解析器检查记录，不一致时更新，更新后检查是否生效
+	VerifyResolver     string       `json:"verify_resolver,omitempty"`   // 验证使用的解析器，多个用逗号分隔，为空时使用面板配置的 DNS 服务器
+	IPv4Source         string       `json:"ipv4_source,omitempty"`       // IPv4 地址来源，为空时使用 Agent 上报的 IP
+	IPv4SourceValue    string       `json:"ipv4_source_value,omitempty"` // 网卡名或固定地址
+	IPv6Source         string       `json:"ipv6_source,omitempty"`
+	IPv6SourceValue    string       `json:"ipv6_source_value,omitempty"`
+	Domains            []string     `json:"domains" gorm:"-"`
+	DomainsRaw         string       `json:"-"`
+	Records            []DDNSRecord `json:"records,omitempty" gorm:"-"` // 记录配置，设置后代替 Domains
+	RecordsRaw         string       `json:"-"`
+}
+
+// DDNSRecord 单条记录配置，Name 为完整域名，可使用 *. 开头的通配符
+type DDNSRecord struct {
+	Name    string `json:"name"`
+	Type    string `json:"type,omitempty"`    // A 或 AAAA，为空时按配置的 IPv4/IPv6 开关
+	TTL     uint32 `json:"ttl,omitempty"`     // 秒，为空时使用 60
+	Proxied bool   `json:"proxied,omitempty"` // 仅 Cloudflare 支持
+}
+
+// SupportsProxied 服务商是否支持代理记录
+func (d *DDNSProfile) SupportsProxied() bool {
+	return d.Provider == ProviderCloudflare
+}
+
+// RecordSpecs 返回要更新的记录，指定了域名时使用默认设置
+func (d *DDNSProfile) RecordSpecs(domains ...string) []DDNSRecord {
+	if len(domains) == 0 && len(d.Records) > 0 {
+		return d.Records
+	}
+	if len(domains) == 0 {
+		domains = d.Domains
+	}
+	records := make([]DDNSRecord, len(domains))
+	for i, domain := range domains {
+		records[i] = DDNSRecord{Name: domain}
+	}
+	return records
+}
+
+// AccessIDIsSecret Porkbun 的 AccessID 为 API Key，与 AccessSecret 一样不在接口中返回
+func (d *DDNSProfile) AccessIDIsSecret() bool {
+	return d.Provider == ProviderPorkbun
+}
+
+// Redacted 返回隐藏了凭据的副本，用于接口返回
+func (d *DDNSProfile) Redacted() *DDNSProfile {
+	p := *d
+	if p.AccessIDIsSecret() {
+		p.AccessID = RedactSecret(p.AccessID)
+	}
+	p.AccessSecret = RedactSecret(p.AccessSecret)
+	p.WebhookURL = RedactURL(p.WebhookURL)
+	p.WebhookHeaders = RedactSecret(p.WebhookHeaders)
+	p.WebhookRequestBody = RedactSecret(p.WebhookRequestBody)
+	return &p
 }
 
 func (d *DDNSProfile) TableName() string {
@@ -45,9 +104,19 @@ func (d *DDNSProfile) BeforeSave(tx *gorm.DB) error {
 	} else {
 		d.DomainsRaw = string(data)
 	}
+	if data, err := json.Marshal(d.Records); err != nil {
+		return err
+	} else {
+		d.RecordsRaw = string(data)
+	}
 	return nil
 }
 
 func (d *DDNSProfile) AfterFind(tx *gorm.DB) error {
+	if d.RecordsRaw != "" {
+		if err := json.Unmarshal([]byte(d.RecordsRaw), &d.Records); err != nil {
+			return err
+		}
+	}
 	return json.Unmarshal([]byte(d.DomainsRaw), &d.Domains)
 }
@@ -1,17 +1,25 @@
 package model
 
 type DDNSForm struct {
-	MaxRetries         uint64   `json:"max_retries,omitempty" default:"3"`
-	EnableIPv4         bool     `json:"enable_ipv4,omitempty" validate:"optional"`
-	EnableIPv6         bool     `json:"enable_ipv6,omitempty" validate:"optional"`
-	Name               string   `json:"name,omitempty" minLength:"1"`
-	Provider           string   `json:"provider,omitempty"`
-	Domains            []string `json:"domains,omitempty"`
-	AccessID           string   `json:"access_id,omitempty" validate:"optional"`
-	AccessSecret       string   `json:"access_secret,omitempty" validate:"optional"`
-	WebhookURL         string   `json:"webhook_url,omitempty" validate:"optional"`
-	WebhookMethod      uint8    `json:"webhook_method,omitempty" validate:"optional" default:"1"`
-	WebhookRequestType uint8    `json:"webhook_request_type,omitempty" validate:"optional" default:"1"`
-	WebhookRequestBody string   `json:"webhook_request_body,omitempty" validate:"optional"`
-	WebhookHeaders     string   `json:"webhook_headers,omitempty" validate:"optional"`
+	MaxRetries         uint64       `json:"max_retries,omitempty" default:"3"`
+	EnableIPv4         bool         `json:"enable_ipv4,omitempty" validate:"optional"`
+	EnableIPv6         bool         `json:"enable_ipv6,omitempty" validate:"optional"`
+	Name               string       `json:"name,omitempty" minLength:"1"`
+	Provider           string       `json:"provider,omitempty"`
+	Domains            []string     `json:"domains,omitempty"`
+	AccessID           string       `json:"access_id,omitempty" validate:"optional"`
+	AccessSecret       string       `json:"access_secret,omitempty" validate:"optional"`
+	WebhookURL         string       `json:"webhook_url,omitempty" validate:"optional"`
+	WebhookMethod      uint8        `json:"webhook_method,omitempty" validate:"optional" default:"1"`
+	WebhookRequestType uint8        `json:"webhook_request_type,omitempty" validate:"optional" default:"1"`
+	WebhookRequestBody string       `json:"webhook_request_body,omitempty" validate:"optional"`
+	WebhookHeaders     string       `json:"webhook_headers,omitempty" validate:"optional"`
+	DryRun             bool         `json:"dry_run,omitempty" validate:"optional"`
+	Verify             bool         `json:"verify,omitempty" validate:"optional"`
+	VerifyResolver     string       `json:"verify_resolver,omitempty" validate:"optional"`
+	Records            []DDNSRecord `json:"records,omitempty" validate:"optional"`
+	IPv4Source         string       `json:"ipv4_source,omitempty" validate:"optional"`
+	IPv4SourceValue    string       `json:"ipv4_source_value,omitempty" validate:"optional"`
+	IPv6Source         string       `json:"ipv6_source,omitempty" validate:"optional"`
+	IPv6SourceValue    string       `json:"ipv6_source_value,omitempty" validate:"optional"`
 }
@@ -0,0 +1,28 @@
+package model
+
+import "time"
+
+const (
+	DDNSResultSuccess = "success"
+	DDNSResultFailure = "failure"
+	DDNSResultDryRun  = "dry_run"
+
+	DDNSVerified   = "verified"
+	DDNSUnverified = "unverified"
+)
+
+// DDNSHistory 单条 DNS 记录的一次更新结果
+type DDNSHistory struct {
+	ID         uint64    `gorm:"primaryKey" json:"id"`
+	CreatedAt  time.Time `gorm:"index" json:"created_at"`
+	ProfileID  uint64    `gorm:"index" json:"profile_id"`
+	ServerID   uint64    `json:"server_id"`
+	Domain     string    `json:"domain"`
+	RecordType string    `json:"record_type"`
+	OldValue   string    `json:"old_value,omitempty"` // 更新前解析到的值
+	NewValue   string    `json:"new_value"`
+	Result     string    `json:"result"`
+	Response   string    `json:"response,omitempty"` // 服务商返回内容的摘要
+
+	Verification string `json:"verification,omitempty"` // 开启验证时，更新后记录是否已生效
+}
@@ -0,0 +1,104 @@
+package model
+
+import (
+	"fmt"
+	"net/netip"
+	"slices"
+)
+
+const (
+	DDNSSourceAgent      = "agent"      // Agent 上报的 IP，默认
+	DDNSSourceConnection = "connection" // 面板观察到的 Agent 连接 IP
+	DDNSSourceInterface  = "interface"  // Agent 上报的指定网卡地址
+	DDNSSourceStatic     = "static"     // 固定地址
+)
+
+var DDNSSourceList = [...]string{DDNSSourceAgent, DDNSSourceConnection, DDNSSourceInterface, DDNSSourceStatic}
+
+// ValidateDDNSSource 检查地址来源配置，网卡来源需要网卡名，固定来源需要对应协议的地址
+func ValidateDDNSSource(source, value string, ipv6 bool) error {
+	switch source {
+	case "", DDNSSourceAgent, DDNSSourceConnection:
+		return nil
+	case DDNSSourceInterface:
+		if value == "" {
+			return fmt.Errorf("interface name is required")
+		}
+		return nil
+	case DDNSSourceStatic:
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			return err
+		}
+		if addr.Unmap().Is4() == ipv6 {
+			return fmt.Errorf("%s is not an %s address", value, ipFamily(ipv6))
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown address source %s", source)
+}
+
+// SourceIP 按 IPv4、IPv6 各自的来源选择要更新的地址，来源不可用时地址为空并返回原因
+func (d *DDNSProfile) SourceIP(s *Server, reported *IP) (*IP, []error) {
+	var ip IP
+	var errs []error
+	var err error
+	if ip.IPv4Addr, err = sourceAddr(d.IPv4Source, d.IPv4SourceValue, false, s, reported.IPv4Addr); err != nil {
+		errs = append(errs, err)
+	}
+	if ip.IPv6Addr, err = sourceAddr(d.IPv6Source, d.IPv6SourceValue, true, s, reported.IPv6Addr); err != nil {
+		errs = append(errs, err)
+	}
+	return &ip, errs
+}
+
+func sourceAddr(source, value string, ipv6 bool, s *Server, reported string) (string, error) {
+	switch source {
+	case "", DDNSSourceAgent:
+		return reported, nil
+	case DDNSSourceConnection:
+		if addr, ok := familyAddr(s.ConnectionIP, ipv6); ok {
+			return addr, nil
+		}
+		return "", fmt.Errorf("no %s connection address", ipFamily(ipv6))
+	case DDNSSourceInterface:
+		if s.Host != nil {
+			i := slices.IndexFunc(s.Host.Interfaces, func(i NetworkInterface) bool { return i.Name == value })
+			if i >= 0 {
+				for _, a := range s.Host.Interfaces[i].Addrs {
+					if addr, ok := familyAddr(a, ipv6); ok {
+						return addr, nil
+					}
+				}
+			}
+		}
+		return "", fmt.Errorf("no %s address on interface %s", ipFamily(ipv6), value)
+	case DDNSSourceStatic:
+		return value, nil
+	}
+	return "", fmt.Errorf("unknown address source %s", source)
+}
+
+// familyAddr 地址可以带有前缀长度，仅接受全局单播地址
+func familyAddr(s string, ipv6 bool) (string, bool) {
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return "", false
+		}
+		addr = prefix.Addr()
+	}
+	addr = addr.Unmap()
+	if addr.Is4() == ipv6 || !addr.IsGlobalUnicast() {
+		return "", false
+	}
+	return addr.String(), true
+}
+
+func ipFamily(ipv6 bool) string {
+	if ipv6 {
+		return "IPv6"
+	}
+	return "IPv4"
+}
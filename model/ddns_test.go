@@ -0,0 +1,19 @@
+package model
+
+import "testing"
+
+func TestDDNSProfileRedacted(t *testing.T) {
+	p := &DDNSProfile{Provider: ProviderPorkbun, AccessID: "pk1_key", AccessSecret: "sk1_secret"}
+	r := p.Redacted()
+	if r.AccessID != SecretPlaceholder || r.AccessSecret != SecretPlaceholder {
+		t.Fatalf("expected porkbun credentials to be redacted, got %q %q", r.AccessID, r.AccessSecret)
+	}
+	if p.AccessID != "pk1_key" {
+		t.Fatal("expected the original profile to be left unchanged")
+	}
+
+	p = &DDNSProfile{Provider: ProviderCloudflare, AccessID: "zone-id", AccessSecret: "token"}
+	if r := p.Redacted(); r.AccessID != "zone-id" || r.AccessSecret != SecretPlaceholder {
+		t.Fatalf("unexpected redaction for cloudflare: %q %q", r.AccessID, r.AccessSecret)
+	}
+}
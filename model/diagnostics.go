@@ -0,0 +1,78 @@
+package model
+
+import (
+	"time"
+
+	"github.com/nezhahq/nezha/pkg/geoip"
+)
+
+// Diagnostics 面板的诊断信息，用于排查用户环境中的问题，不包含密钥等敏感设置
+type Diagnostics struct {
+	GeneratedAt time.Time              `json:"generated_at"`
+	Build       DiagnosticsBuild       `json:"build"`
+	Database    DiagnosticsDatabase    `json:"database"`
+	Counts      DiagnosticsCounts      `json:"counts"`
+	GeoIP       geoip.Stats            `json:"geoip"`
+	Connections DiagnosticsConnections `json:"connections"`
+	GRPC        DiagnosticsGRPC        `json:"grpc"`
+	ErrorLog    []LogEntry             `json:"error_log"` // 最近的错误与警告日志，按时间排序
+	Jobs        map[string]JobRun      `json:"jobs"`      // 定时清理任务最近一次的执行情况
+	SelfCheck   []SelfCheckFinding     `json:"self_check"`
+	Settings    []SettingItem          `json:"settings"`
+}
+
+type DiagnosticsBuild struct {
+	Version   string    `json:"version"`
+	Revision  string    `json:"revision,omitempty"` // 构建时的 VCS 版本
+	GoVersion string    `json:"go_version"`
+	OS        string    `json:"os"`
+	Arch      string    `json:"arch"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+type DiagnosticsDatabase struct {
+	Driver        string `json:"driver"`
+	SchemaVersion int64  `json:"schema_version"` // 已执行的最新迁移
+	LatestVersion int64  `json:"latest_version"` // 程序支持的最新迁移
+	TSDB          string `json:"tsdb,omitempty"` // 外部时序数据库的类型
+}
+
+type DiagnosticsCounts struct {
+	Servers       int   `json:"servers"`
+	OnlineServers int   `json:"online_servers"`
+	Services      int   `json:"services"`
+	AlertRules    int64 `json:"alert_rules"`
+	Users         int64 `json:"users"`
+}
+
+type DiagnosticsConnections struct {
+	WebSocket        int `json:"websocket"`         // 本节点的前端 WebSocket 连接
+	ClusterWebSocket int `json:"cluster_websocket"` // 集群所有节点的前端 WebSocket 连接
+	Agents           int `json:"agents"`            // 已建立任务流的 Agent
+}
+
+type DiagnosticsGRPC struct {
+	Listen    string `json:"listen"`
+	TLSListen string `json:"tls_listen,omitempty"` // Agent 专用的 TLS 端口
+	OK        bool   `json:"ok"`
+	Error     string `json:"error,omitempty"`
+}
+
+// LogEntry 一条日志
+type LogEntry struct {
+	Time    time.Time `json:"time"`
+	Message string    `json:"message"`
+}
+
+// JobRun 任务最近一次的执行时间与耗时
+type JobRun struct {
+	StartedAt  time.Time `json:"started_at"`
+	DurationMs int64     `json:"duration_ms"`
+}
+
+// SelfCheckFinding 启动自检或运行中发现的配置问题
+type SelfCheckFinding struct {
+	Check   string    `json:"check"`
+	Message string    `json:"message"`
+	Time    time.Time `json:"time"`
+}
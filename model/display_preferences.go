@@ -0,0 +1,91 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// 数据单位
+const (
+	DisplayUnitsSI  = "si"  // 十进制，1 KB = 1000 B
+	DisplayUnitsIEC = "iec" // 二进制，1 KiB = 1024 B
+)
+
+// 温度单位
+const (
+	TemperatureCelsius    = "c"
+	TemperatureFahrenheit = "f"
+)
+
+// 网速单位
+const (
+	NetworkUnitBytes = "bytes"
+	NetworkUnitBits  = "bits"
+)
+
+const MaxPercentDecimals = 4
+
+var (
+	DisplayUnits     = []string{DisplayUnitsSI, DisplayUnitsIEC}
+	TemperatureUnits = []string{TemperatureCelsius, TemperatureFahrenheit}
+	NetworkUnits     = []string{NetworkUnitBytes, NetworkUnitBits}
+)
+
+// DisplayPreferences 前端展示数据时使用的单位与精度，面板只声明偏好，数值的换算由前端完成
+type DisplayPreferences struct {
+	Units           string `koanf:"units" json:"units"`
+	TemperatureUnit string `koanf:"temperature_unit" json:"temperature_unit"`
+	PercentDecimals int    `koanf:"percent_decimals" json:"percent_decimals"` // 百分比保留的小数位数
+	NetworkUnit     string `koanf:"network_unit" json:"network_unit"`
+	Timezone        string `koanf:"timezone" json:"timezone"`                   // IANA 时区，为空时使用浏览器时区
+	FirstDayOfWeek  int    `koanf:"first_day_of_week" json:"first_day_of_week"` // 0 为周日
+}
+
+// DefaultDisplayPreferences 未配置时的展示偏好，与前端原有的显示方式一致
+func DefaultDisplayPreferences() DisplayPreferences {
+	return DisplayPreferences{
+		Units:           DisplayUnitsIEC,
+		TemperatureUnit: TemperatureCelsius,
+		PercentDecimals: 2,
+		NetworkUnit:     NetworkUnitBytes,
+		FirstDayOfWeek:  int(time.Monday),
+	}
+}
+
+func (p *DisplayPreferences) Validate() error {
+	if !slices.Contains(DisplayUnits, p.Units) {
+		return fmt.Errorf("invalid units: %s", p.Units)
+	}
+	if !slices.Contains(TemperatureUnits, p.TemperatureUnit) {
+		return fmt.Errorf("invalid temperature unit: %s", p.TemperatureUnit)
+	}
+	if p.PercentDecimals < 0 || p.PercentDecimals > MaxPercentDecimals {
+		return fmt.Errorf("percent decimals must be between 0 and %d", MaxPercentDecimals)
+	}
+	if !slices.Contains(NetworkUnits, p.NetworkUnit) {
+		return fmt.Errorf("invalid network unit: %s", p.NetworkUnit)
+	}
+	if err := ValidTimezone(p.Timezone); err != nil {
+		return err
+	}
+	if p.FirstDayOfWeek < int(time.Sunday) || p.FirstDayOfWeek > int(time.Saturday) {
+		return errors.New("first day of week must be between 0 (Sunday) and 6 (Saturday)")
+	}
+	return nil
+}
+
+// ValidTimezone 允许为空或 IANA 时区名，Local 取决于面板所在机器，不能用于前端
+func ValidTimezone(tz string) error {
+	if tz == "" {
+		return nil
+	}
+	if tz == "Local" {
+		return fmt.Errorf("invalid timezone: %s", tz)
+	}
+	if _, err := time.LoadLocation(tz); err != nil {
+		return fmt.Errorf("invalid timezone: %s", tz)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package model
+
+import (
+	"os"
+	"testing"
+)
+
+func TestDisplayPreferencesValidate(t *testing.T) {
+	valid := DefaultDisplayPreferences()
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("default preferences should be valid: %v", err)
+	}
+
+	cases := []struct {
+		name  string
+		apply func(p *DisplayPreferences)
+		ok    bool
+	}{
+		{"si", func(p *DisplayPreferences) { p.Units = DisplayUnitsSI }, true},
+		{"unknown units", func(p *DisplayPreferences) { p.Units = "binary" }, false},
+		{"fahrenheit", func(p *DisplayPreferences) { p.TemperatureUnit = TemperatureFahrenheit }, true},
+		{"kelvin", func(p *DisplayPreferences) { p.TemperatureUnit = "k" }, false},
+		{"no decimals", func(p *DisplayPreferences) { p.PercentDecimals = 0 }, true},
+		{"too many decimals", func(p *DisplayPreferences) { p.PercentDecimals = MaxPercentDecimals + 1 }, false},
+		{"negative decimals", func(p *DisplayPreferences) { p.PercentDecimals = -1 }, false},
+		{"bits", func(p *DisplayPreferences) { p.NetworkUnit = NetworkUnitBits }, true},
+		{"empty network unit", func(p *DisplayPreferences) { p.NetworkUnit = "" }, false},
+		{"timezone", func(p *DisplayPreferences) { p.Timezone = "Asia/Shanghai" }, true},
+		{"local timezone", func(p *DisplayPreferences) { p.Timezone = "Local" }, false},
+		{"unknown timezone", func(p *DisplayPreferences) { p.Timezone = "Mars/Olympus" }, false},
+		{"sunday", func(p *DisplayPreferences) { p.FirstDayOfWeek = 0 }, true},
+		{"invalid day", func(p *DisplayPreferences) { p.FirstDayOfWeek = 7 }, false},
+	}
+	for _, c := range cases {
+		p := DefaultDisplayPreferences()
+		c.apply(&p)
+		if err := p.Validate(); (err == nil) != c.ok {
+			t.Errorf("%s: Validate() = %v, want ok %v", c.name, err, c.ok)
+		}
+	}
+}
+
+func TestReadDisplayConfig(t *testing.T) {
+	// 配置中显式设置为 0 的项不应被默认值覆盖
+	file := newTempConfig(t, "display:\n  units: si\n  percent_decimals: 0\n")
+	defer os.Remove(file)
+
+	c := &Config{}
+	if err := c.Read(file, nil); err != nil {
+		t.Fatalf("read config failed: %v", err)
+	}
+	want := DefaultDisplayPreferences()
+	want.Units = DisplayUnitsSI
+	want.PercentDecimals = 0
+	if c.Display != want {
+		t.Errorf("Display = %+v, want %+v", c.Display, want)
+	}
+}
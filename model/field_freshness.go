@@ -0,0 +1,110 @@
+package model
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// field_stale 规则可检查的状态字段
+const (
+	StaleFieldTemperatures = "temperatures"
+	StaleFieldDisk         = "disk"
+	StaleFieldGPU          = "gpu"
+	StaleFieldSwap         = "swap"
+	StaleFieldConnections  = "connections"
+)
+
+const StaleWindowDefault = time.Hour // field_stale 规则未指定时间窗口时的默认值
+
+var StaleFields = []string{StaleFieldTemperatures, StaleFieldDisk, StaleFieldGPU, StaleFieldSwap, StaleFieldConnections}
+
+type fieldSample struct {
+	at    time.Time
+	host  *Host
+	state *HostState
+}
+
+// FieldFreshness 记录状态上报中各字段最近一次不为空的时间，用于发现 Agent 在线但不再上报部分数据
+type FieldFreshness struct {
+	mu      sync.RWMutex
+	started time.Time // 面板收到的第一次上报，字段从未上报过时从此时开始计算
+	last    map[string]fieldSample
+}
+
+// Update 记录一次状态上报，上报中为空或为 0 的字段不更新
+func (f *FieldFreshness) Update(t time.Time, host *Host, state *HostState) {
+	if state == nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.last == nil {
+		f.started = t
+		f.last = make(map[string]fieldSample)
+	}
+	for _, field := range StaleFields {
+		if fieldReported(field, host, state) {
+			f.last[field] = fieldSample{at: t, host: host, state: state}
+		}
+	}
+}
+
+// Stale 返回字段未上报或为空的时长，以及最近一次有效的值与时间，从未上报过时 value 为空、at 为零值
+func (f *FieldFreshness) Stale(field string, now time.Time) (d time.Duration, value string, at time.Time) {
+	if f == nil {
+		return 0, "", time.Time{}
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	if f.last == nil {
+		return 0, "", time.Time{}
+	}
+	sample, ok := f.last[field]
+	if !ok {
+		return now.Sub(f.started), "", time.Time{}
+	}
+	return now.Sub(sample.at), fieldValue(field, sample.host, sample.state), sample.at
+}
+
+func fieldReported(field string, host *Host, state *HostState) bool {
+	switch field {
+	case StaleFieldTemperatures:
+		return len(state.Temperatures) > 0
+	case StaleFieldDisk:
+		return state.DiskUsed > 0 || len(state.DiskMounts) > 0
+	case StaleFieldGPU:
+		return len(state.GPU) > 0 || len(state.GPUs) > 0
+	case StaleFieldSwap:
+		return host != nil && host.SwapTotal > 0
+	case StaleFieldConnections:
+		return state.TcpConnCount > 0 || state.UdpConnCount > 0
+	}
+	return false
+}
+
+func fieldValue(field string, host *Host, state *HostState) string {
+	switch field {
+	case StaleFieldTemperatures:
+		hottest := slices.MaxFunc(state.Temperatures, func(a, b SensorTemperature) int {
+			return cmp.Compare(a.Temperature, b.Temperature)
+		})
+		return fmt.Sprintf("%s %.1f°C", hottest.Name, hottest.Temperature)
+	case StaleFieldDisk:
+		if host != nil && host.DiskTotal > 0 {
+			return fmt.Sprintf("%.1f%%", percentage(state.DiskUsed, host.DiskTotal))
+		}
+		return fmt.Sprintf("%.1f%%", state.MaxMountUsage())
+	case StaleFieldGPU:
+		return fmt.Sprintf("%.1f%%", state.MaxGPUUsage())
+	case StaleFieldSwap:
+		return fmt.Sprintf("%.1f%%", percentage(state.SwapUsed, host.SwapTotal))
+	case StaleFieldConnections:
+		return fmt.Sprintf("TCP %d, UDP %d", state.TcpConnCount, state.UdpConnCount)
+	}
+	return ""
+}
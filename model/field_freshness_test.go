@@ -0,0 +1,69 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFieldFreshness(t *testing.T) {
+	start := time.Now()
+	host := &Host{SwapTotal: 1000}
+	f := &FieldFreshness{}
+
+	f.Update(start, host, &HostState{
+		Temperatures: []SensorTemperature{{Name: "cpu", Temperature: 48}, {Name: "nvme", Temperature: 52}},
+		SwapUsed:     250,
+		TcpConnCount: 10,
+	})
+	// 温度与连接数随后不再上报
+	f.Update(start.Add(time.Minute), host, &HostState{SwapUsed: 500})
+
+	now := start.Add(time.Hour)
+	d, value, at := f.Stale(StaleFieldTemperatures, now)
+	if d != time.Hour || !at.Equal(start) || value != "nvme 52.0°C" {
+		t.Errorf("temperatures: got %v %q %v", d, value, at)
+	}
+	if d, value, _ := f.Stale(StaleFieldSwap, now); d != 59*time.Minute || value != "50.0%" {
+		t.Errorf("swap: got %v %q", d, value)
+	}
+	if _, value, _ := f.Stale(StaleFieldConnections, now); value != "TCP 10, UDP 0" {
+		t.Errorf("connections: got %q", value)
+	}
+
+	// 从未上报过的字段从收到第一次上报开始计算
+	d, value, at = f.Stale(StaleFieldGPU, now)
+	if d != time.Hour || value != "" || !at.IsZero() {
+		t.Errorf("gpu: got %v %q %v", d, value, at)
+	}
+
+	var empty *FieldFreshness
+	if d, _, _ := empty.Stale(StaleFieldDisk, now); d != 0 {
+		t.Errorf("nil freshness should not be stale, got %v", d)
+	}
+}
+
+func TestFieldStaleRule(t *testing.T) {
+	now := time.Now()
+	server := &Server{Common: Common{ID: 1}, LastActive: now}
+	server.Freshness = &FieldFreshness{}
+	server.Freshness.Update(now.Add(-2*time.Hour), server.Host, &HostState{Temperatures: []SensorTemperature{{Name: "cpu", Temperature: 40}}})
+	server.Freshness.Update(now.Add(-time.Hour), server.Host, &HostState{Temperatures: []SensorTemperature{{Name: "cpu", Temperature: 40}}})
+
+	rule := &Rule{Type: "field_stale", StaleField: StaleFieldTemperatures, Window: 1800}
+	if rule.Snapshot(nil, server, nil) {
+		t.Error("expected rule to fail when temperatures are stale")
+	}
+
+	rule.Window = 0 // 默认一小时
+	server.Freshness.Update(now.Add(-30*time.Minute), server.Host, &HostState{Temperatures: []SensorTemperature{{Name: "cpu", Temperature: 40}}})
+	if !rule.Snapshot(nil, server, nil) {
+		t.Error("expected rule to pass within the default window")
+	}
+
+	// 离线的服务器由 offline 规则处理
+	rule.Window = 60
+	server.LastActive = now.Add(-time.Minute)
+	if !rule.Snapshot(nil, server, nil) {
+		t.Error("expected rule to pass for offline server")
+	}
+}
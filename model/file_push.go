@@ -0,0 +1,96 @@
+package model
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	PushFileMaxSize   = 4 << 20  // 可推送文件的大小上限
+	PushFileChunkSize = 64 << 10 // 每次下发的分块大小
+
+	FilePushRunning = "running"
+	FilePushSuccess = "success"
+	FilePushFailure = "failure"
+)
+
+// PushFile 可推送到 Agent 的小文件，如配置文件或脚本
+type PushFile struct {
+	Common
+	Name    string `json:"name"`
+	Size    int64  `json:"size"`
+	SHA256  string `json:"sha256"`
+	Content []byte `json:"-"`
+}
+
+// FilePushTask 下发给 Agent 的文件分块，Agent 收到最后一块并校验 SHA256 后原子替换目标文件
+type FilePushTask struct {
+	TransferID string `json:"transfer_id"`
+	Path       string `json:"path"`
+	Mode       uint32 `json:"mode"`
+	Size       int64  `json:"size"`
+	SHA256     string `json:"sha256"`
+	Offset     int64  `json:"offset"`
+	Data       []byte `json:"data"`
+}
+
+// FilePushResult Agent 对分块的确认
+type FilePushResult struct {
+	TransferID string `json:"transfer_id"`
+	Offset     int64  `json:"offset"`           // Agent 已收到的字节数，重新连接后从此处继续
+	SHA256     string `json:"sha256,omitempty"` // 写入完成后目标文件的校验和
+	Error      string `json:"error,omitempty"`
+}
+
+// FilePush 一次文件推送的状态
+type FilePush struct {
+	ID        string    `json:"id"`
+	ServerID  uint64    `json:"server_id"`
+	FileID    uint64    `json:"file_id"`
+	Path      string    `json:"path"`
+	Mode      uint32    `json:"mode"`
+	Size      int64     `json:"size"`
+	Offset    int64     `json:"offset"`
+	Status    string    `json:"status"`
+	SHA256    string    `json:"sha256,omitempty"` // Agent 返回的校验和
+	Error     string    `json:"error,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type FilePushForm struct {
+	FileID uint64 `json:"file_id"`
+	Path   string `json:"path" minLength:"1"` // 目标文件的绝对路径
+	Mode   uint32 `json:"mode,omitempty" validate:"optional" default:"420"`
+}
+
+var windowsAbsPath = regexp.MustCompile(`^[A-Za-z]:[\\/]`)
+
+// ValidatePushPath 目标路径须为绝对路径，且不能包含 . 或 .. 路径段
+func ValidatePushPath(path string) error {
+	if path == "" || strings.ContainsRune(path, 0) {
+		return errors.New("invalid path")
+	}
+	if !strings.HasPrefix(path, "/") && !windowsAbsPath.MatchString(path) {
+		return errors.New("path must be absolute")
+	}
+	segments := strings.FieldsFunc(path, func(r rune) bool { return r == '/' || r == '\\' })
+	for _, s := range segments {
+		if s == "." || s == ".." {
+			return errors.New("path must not contain . or ..")
+		}
+	}
+	if len(segments) == 0 || strings.HasSuffix(path, "/") || strings.HasSuffix(path, "\\") {
+		return errors.New("path must point to a file")
+	}
+	return nil
+}
+
+// ValidatePushMode 只允许权限位
+func ValidatePushMode(mode uint32) error {
+	if mode > 0o777 {
+		return errors.New("invalid file mode")
+	}
+	return nil
+}
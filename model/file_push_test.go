@@ -0,0 +1,27 @@
+package model
+
+import "testing"
+
+func TestValidatePushPath(t *testing.T) {
+	cases := []struct {
+		path string
+		ok   bool
+	}{
+		{"/etc/nginx/nginx.conf", true},
+		{`C:\nezha\script.ps1`, true},
+		{"C:/nezha/script.ps1", true},
+		{"", false},
+		{"etc/passwd", false},
+		{"/etc/../root/.ssh/authorized_keys", false},
+		{`C:\nezha\..\Windows\win.ini`, false},
+		{"/tmp/./file", false},
+		{"/tmp/", false},
+		{"/", false},
+		{"/tmp/a\x00b", false},
+	}
+	for _, c := range cases {
+		if err := ValidatePushPath(c.path); (err == nil) != c.ok {
+			t.Fatalf("%q: expected ok=%v, got %v", c.path, c.ok, err)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package model
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// 服务器汇总中可以包含的内容
+const (
+	FleetSummaryServers      = "servers"      // 新增与删除的服务器
+	FleetSummaryDowntime     = "downtime"     // 服务监控的故障次数
+	FleetSummaryCPU          = "cpu"          // 平均 CPU 占用最高的服务器
+	FleetSummaryBandwidth    = "bandwidth"    // 流量最多的服务器
+	FleetSummaryCertificates = "certificates" // 即将过期及需要续期的证书
+)
+
+var FleetSummarySections = []string{
+	FleetSummaryServers,
+	FleetSummaryDowntime,
+	FleetSummaryCPU,
+	FleetSummaryBandwidth,
+	FleetSummaryCertificates,
+}
+
+// FleetSummaryConf 定时发送服务器汇总通知
+type FleetSummaryConf struct {
+	Schedule            string   `koanf:"schedule" json:"schedule,omitempty"`                           // 秒级 cron 表达式，为空时不启用，如每周一 9:00 为 0 0 9 * * 1
+	NotificationGroupID uint64   `koanf:"notification_group_id" json:"notification_group_id,omitempty"` // 接收汇总的通知组
+	Sections            []string `koanf:"sections" json:"sections,omitempty"`                           // 包含的内容，为空时包含全部
+	Days                int      `koanf:"days" json:"days,omitempty"`                                   // 统计最近多少天，默认 7
+	Template            string   `koanf:"template" json:"template,omitempty"`                           // text/template 格式的消息模板，为空时使用默认模板
+}
+
+func (c *FleetSummaryConf) Validate() error {
+	for _, s := range c.Sections {
+		if !slices.Contains(FleetSummarySections, s) {
+			return fmt.Errorf("unknown fleet summary section %s", s)
+		}
+	}
+	return nil
+}
+
+func (c *FleetSummaryConf) Has(section string) bool {
+	return len(c.Sections) == 0 || slices.Contains(c.Sections, section)
+}
+
+// FleetSummaryData 汇总消息模板中可以使用的数据，未包含的内容为空
+type FleetSummaryData struct {
+	Start    time.Time
+	End      time.Time
+	Sections map[string]bool
+
+	ServersAdded   []FleetSummaryItem // Value 为服务器 ID
+	ServersRemoved []FleetSummaryItem // Value 为服务器 ID
+
+	DowntimeEvents   int
+	DowntimeServices []FleetSummaryItem // Value 为故障次数
+
+	TopCPU       []FleetSummaryItem // Value 为平均 CPU 占用百分比
+	TopBandwidth []FleetSummaryItem // Value 为入站与出站流量之和（字节）
+
+	Certificates []FleetSummaryCert
+}
+
+type FleetSummaryItem struct {
+	Name  string
+	Value float64
+}
+
+type FleetSummaryCert struct {
+	Service       string
+	Issuer        string
+	NotAfter      time.Time
+	DaysRemaining int
+	RenewalDue    bool // 已进入服务设置的证书过期提醒阈值
+}
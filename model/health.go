@@ -0,0 +1,13 @@
+package model
+
+type HealthComponent struct {
+	Name      string  `json:"name"`
+	OK        bool    `json:"ok"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type HealthResponse struct {
+	Status     string             `json:"status"` // ok, fail
+	Components []*HealthComponent `json:"components,omitempty"`
+}
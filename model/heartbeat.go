@@ -0,0 +1,87 @@
+package model
+
+import (
+	"errors"
+	"slices"
+	"time"
+)
+
+// 心跳模式的上报间隔（秒）
+const (
+	HeartbeatIntervalDefault = 60
+	HeartbeatIntervalMin     = 10
+	HeartbeatIntervalMax     = 3600
+)
+
+// 心跳上报中包含数据的规则类型，心跳模式下其余规则跳过检查，不会把缺失的指标当作 0
+var heartbeatRuleTypes = []string{"offline", "load1", "agent_version"}
+
+// AgentReportConfig 面板通过 TaskTypeApplyConfig 下发给 Agent 的上报模式
+type AgentReportConfig struct {
+	HeartbeatMode     bool   `json:"heartbeat_mode"`
+	HeartbeatInterval uint32 `json:"heartbeat_interval,omitempty" validate:"optional"` // 心跳上报间隔（秒），为 0 时使用默认值
+}
+
+// Validate 检查心跳间隔，为 0 时使用默认值
+func (c *AgentReportConfig) Validate() error {
+	if c.HeartbeatInterval == 0 {
+		c.HeartbeatInterval = HeartbeatIntervalDefault
+	}
+	if c.HeartbeatInterval < HeartbeatIntervalMin || c.HeartbeatInterval > HeartbeatIntervalMax {
+		return errors.New("heartbeat interval out of range")
+	}
+	return nil
+}
+
+// HeartbeatReport 心跳模式下 Agent 通过任务流上报的最小状态
+type HeartbeatReport struct {
+	Timestamp int64   `json:"timestamp"` // Agent 本地的上报时间，Unix 秒
+	Uptime    uint64  `json:"uptime"`
+	Load1     float64 `json:"load_1"`
+}
+
+// Validate 检查上报时间，last 为上一次心跳的时间，重复或乱序的心跳不再记录
+func (r *HeartbeatReport) Validate(last time.Time) error {
+	if r.Timestamp <= 0 {
+		return errors.New("heartbeat timestamp is required")
+	}
+	if !last.IsZero() && r.Timestamp <= last.Unix() {
+		return errors.New("heartbeat is older than the last one")
+	}
+	return nil
+}
+
+// State 转换为只包含运行时间与负载的状态，其余字段为空
+func (r *HeartbeatReport) State() HostState {
+	return HostState{Uptime: r.Uptime, Load1: r.Load1}
+}
+
+// HeartbeatIntervalOrDefault 返回心跳上报间隔
+func (s *Server) HeartbeatIntervalOrDefault() time.Duration {
+	if s.HeartbeatInterval == 0 {
+		return HeartbeatIntervalDefault * time.Second
+	}
+	return time.Duration(s.HeartbeatInterval) * time.Second
+}
+
+// OnlineTimeout 返回超过多久未收到上报时视为离线，心跳模式下允许错过一次上报，否则返回 d
+func (s *Server) OnlineTimeout(d time.Duration) time.Duration {
+	if !s.HeartbeatMode {
+		return d
+	}
+	return max(d, 2*s.HeartbeatIntervalOrDefault())
+}
+
+// ReportConfig 返回需要下发给 Agent 的上报模式
+func (s *Server) ReportConfig() AgentReportConfig {
+	return AgentReportConfig{
+		HeartbeatMode:     s.HeartbeatMode,
+		HeartbeatInterval: uint32(s.HeartbeatIntervalOrDefault() / time.Second),
+	}
+}
+
+// HeartbeatSkipsRule 最近一次为心跳上报时，上报中不包含规则所需的指标，跳过检查。
+// 已开启心跳模式但 Agent 仍在完整上报时照常检查
+func (s *Server) HeartbeatSkipsRule(ruleType string) bool {
+	return !s.HeartbeatAt.IsZero() && !slices.Contains(heartbeatRuleTypes, ruleType)
+}
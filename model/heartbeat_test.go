@@ -0,0 +1,70 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHeartbeatReport(t *testing.T) {
+	report := HeartbeatReport{Timestamp: time.Now().Unix(), Uptime: 3600, Load1: 0.5}
+	state := report.State()
+	if err := state.Sanitize(&Host{MemTotal: 1 << 30, DiskTotal: 1 << 40}); err != nil {
+		t.Fatalf("reduced payload should be accepted: %v", err)
+	}
+	if err := state.Sanitize(nil); err != nil {
+		t.Fatalf("reduced payload without host should be accepted: %v", err)
+	}
+
+	if report.Validate(time.Unix(report.Timestamp, 0)) == nil {
+		t.Fatal("expected repeated heartbeat to be rejected")
+	}
+	if (&HeartbeatReport{}).Validate(time.Time{}) == nil {
+		t.Fatal("expected heartbeat without timestamp to be rejected")
+	}
+	if err := report.Validate(time.Time{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	rc := AgentReportConfig{HeartbeatMode: true}
+	if err := rc.Validate(); err != nil || rc.HeartbeatInterval != HeartbeatIntervalDefault {
+		t.Fatalf("expected default interval, got %d, %v", rc.HeartbeatInterval, err)
+	}
+	rc.HeartbeatInterval = HeartbeatIntervalMin - 1
+	if rc.Validate() == nil {
+		t.Fatal("expected interval below the minimum to be rejected")
+	}
+}
+
+func TestHeartbeatRules(t *testing.T) {
+	s := &Server{HeartbeatMode: true, HeartbeatInterval: 30, State: &HostState{Load1: 4}}
+
+	// 尚未收到心跳上报时照常检查
+	if (&Rule{Type: "cpu", Min: 10}).Snapshot(nil, s, nil) {
+		t.Fatal("expected cpu rule to be checked before any heartbeat")
+	}
+	s.HeartbeatAt = time.Now()
+	if d := s.OnlineTimeout(10 * time.Second); d != time.Minute {
+		t.Fatalf("unexpected online timeout: %s", d)
+	}
+	if d := (&Server{}).OnlineTimeout(10 * time.Second); d != 10*time.Second {
+		t.Fatalf("unexpected online timeout without heartbeat mode: %s", d)
+	}
+
+	// 心跳上报不包含 CPU，规则跳过而不是按 0 处理
+	if !(&Rule{Type: "cpu", Min: 10}).Snapshot(nil, s, nil) {
+		t.Fatal("expected cpu rule to be skipped in heartbeat mode")
+	}
+	if (&Rule{Type: "load1", Max: 2}).Snapshot(nil, s, nil) {
+		t.Fatal("expected load1 rule to be checked in heartbeat mode")
+	}
+
+	// 错过一次心跳不视为离线
+	s.LastActive = time.Now().Add(-45 * time.Second)
+	if !(&Rule{Type: "offline", Duration: 60}).Snapshot(nil, s, nil) {
+		t.Fatal("expected server within two heartbeat intervals to be online")
+	}
+	s.LastActive = time.Now().Add(-2 * time.Minute)
+	if (&Rule{Type: "offline", Duration: 60}).Snapshot(nil, s, nil) {
+		t.Fatal("expected server missing heartbeats to be offline")
+	}
+}
@@ -2,6 +2,7 @@ package model
 
 import (
 	"fmt"
+	"slices"
 
 	pb "github.com/nezhahq/nezha/proto"
 )
@@ -15,6 +16,35 @@ const (
 type SensorTemperature struct {
 	Name        string
 	Temperature float64
+	Sensor      string `json:",omitempty"` // 归一化后的传感器名称，由面板根据 Name 生成
+}
+
+// DiskHealth 单个磁盘的 SMART 健康状态
+type DiskHealth struct {
+	Device             string  `json:"device"`
+	SMARTPassed        bool    `json:"smart_passed"`
+	ReallocatedSectors uint64  `json:"reallocated_sectors,omitempty"`
+	WearLevel          float64 `json:"wear_level,omitempty"` // SSD/NVMe 已用寿命（百分比）
+	PowerOnHours       uint64  `json:"power_on_hours,omitempty"`
+}
+
+// DiskMount 单个挂载点的磁盘用量
+type DiskMount struct {
+	Path   string `json:"path"`
+	FSType string `json:"fstype,omitempty"`
+	Total  uint64 `json:"total"`
+	Used   uint64 `json:"used"`
+}
+
+// GPUStat 单个 GPU 的状态，由可读取 NVML/ROCm 的 Agent 上报
+type GPUStat struct {
+	Vendor      string  `json:"vendor,omitempty"`
+	Model       string  `json:"model,omitempty"`
+	Utilization float64 `json:"utilization"`            // 使用率（百分比）
+	MemoryUsed  uint64  `json:"memory_used,omitempty"`  // 显存用量（字节）
+	MemoryTotal uint64  `json:"memory_total,omitempty"` // 显存总量（字节）
+	Temperature float64 `json:"temperature,omitempty"`  // 温度（摄氏度）
+	Power       float64 `json:"power,omitempty"`        // 功耗（瓦）
 }
 
 type HostState struct {
@@ -35,6 +65,12 @@ type HostState struct {
 	ProcessCount   uint64              `json:"process_count,omitempty"`
 	Temperatures   []SensorTemperature `json:"temperatures,omitempty"`
 	GPU            []float64           `json:"gpu,omitempty"`
+	CPUCores       []float64           `json:"cpu_cores,omitempty"`   // 各核心的使用率，旧版 Agent 不上报
+	DiskMounts     []DiskMount         `json:"disk_mounts,omitempty"` // 各挂载点的用量，旧版 Agent 不上报
+	GPUs           []GPUStat           `json:"gpus,omitempty"`        // 各 GPU 的详细状态，旧版 Agent 不上报
+	DiskHealth     []DiskHealth        `json:"disk_health,omitempty"` // 各磁盘的 SMART 状态，旧版 Agent 不上报
+
+	CustomMetrics map[string]float64 `json:"custom_metrics,omitempty"` // Agent 推送的自定义指标，游客不可见
 }
 
 func (s *HostState) PB() *pb.State {
@@ -46,6 +82,40 @@ func (s *HostState) PB() *pb.State {
 		})
 	}
 
+	var mounts []*pb.State_DiskMount
+	for _, m := range s.DiskMounts {
+		mounts = append(mounts, &pb.State_DiskMount{
+			Path:   m.Path,
+			Fstype: m.FSType,
+			Total:  m.Total,
+			Used:   m.Used,
+		})
+	}
+
+	var gpus []*pb.State_GPU
+	for _, g := range s.GPUs {
+		gpus = append(gpus, &pb.State_GPU{
+			Vendor:      g.Vendor,
+			Model:       g.Model,
+			Utilization: g.Utilization,
+			MemoryUsed:  g.MemoryUsed,
+			MemoryTotal: g.MemoryTotal,
+			Temperature: g.Temperature,
+			Power:       g.Power,
+		})
+	}
+
+	var disks []*pb.State_DiskHealth
+	for _, d := range s.DiskHealth {
+		disks = append(disks, &pb.State_DiskHealth{
+			Device:             d.Device,
+			SmartPassed:        d.SMARTPassed,
+			ReallocatedSectors: d.ReallocatedSectors,
+			WearLevel:          d.WearLevel,
+			PowerOnHours:       d.PowerOnHours,
+		})
+	}
+
 	return &pb.State{
 		Cpu:            s.CPU,
 		MemUsed:        s.MemUsed,
@@ -64,6 +134,11 @@ func (s *HostState) PB() *pb.State {
 		ProcessCount:   s.ProcessCount,
 		Temperatures:   ts,
 		Gpu:            s.GPU,
+		CpuCores:       s.CPUCores,
+		DiskMounts:     mounts,
+		Gpus:           gpus,
+		DiskHealth:     disks,
+		CustomMetrics:  s.CustomMetrics,
 	}
 }
 
@@ -76,6 +151,40 @@ func PB2State(s *pb.State) HostState {
 		})
 	}
 
+	var mounts []DiskMount
+	for _, m := range s.GetDiskMounts() {
+		mounts = append(mounts, DiskMount{
+			Path:   m.GetPath(),
+			FSType: m.GetFstype(),
+			Total:  m.GetTotal(),
+			Used:   m.GetUsed(),
+		})
+	}
+
+	var gpus []GPUStat
+	for _, g := range s.GetGpus() {
+		gpus = append(gpus, GPUStat{
+			Vendor:      g.GetVendor(),
+			Model:       g.GetModel(),
+			Utilization: g.GetUtilization(),
+			MemoryUsed:  g.GetMemoryUsed(),
+			MemoryTotal: g.GetMemoryTotal(),
+			Temperature: g.GetTemperature(),
+			Power:       g.GetPower(),
+		})
+	}
+
+	var disks []DiskHealth
+	for _, d := range s.GetDiskHealth() {
+		disks = append(disks, DiskHealth{
+			Device:             d.GetDevice(),
+			SMARTPassed:        d.GetSmartPassed(),
+			ReallocatedSectors: d.GetReallocatedSectors(),
+			WearLevel:          d.GetWearLevel(),
+			PowerOnHours:       d.GetPowerOnHours(),
+		})
+	}
+
 	return HostState{
 		CPU:            s.GetCpu(),
 		MemUsed:        s.GetMemUsed(),
@@ -94,24 +203,142 @@ func PB2State(s *pb.State) HostState {
 		ProcessCount:   s.GetProcessCount(),
 		Temperatures:   ts,
 		GPU:            s.GetGpu(),
+		CPUCores:       s.GetCpuCores(),
+		DiskMounts:     mounts,
+		GPUs:           gpus,
+		DiskHealth:     disks,
+		CustomMetrics:  s.GetCustomMetrics(),
+	}
+}
+
+// Brief 返回不含各核心、各挂载点、各磁盘明细的副本，用于服务器列表的推送
+func (s *HostState) Brief() *HostState {
+	if s == nil {
+		return nil
+	}
+	brief := *s
+	brief.CPUCores = nil
+	brief.DiskMounts = nil
+	brief.DiskHealth = nil
+	return &brief
+}
+
+// MaxCoreUsage 使用率最高的核心的使用率
+func (s *HostState) MaxCoreUsage() float64 {
+	if len(s.CPUCores) == 0 {
+		return 0
 	}
+	return slices.Max(s.CPUCores)
+}
+
+// ForGuest 返回不含 GPU 详细状态与自定义指标的副本，用于游客可见的推送
+func (s *HostState) ForGuest() *HostState {
+	if s == nil || (len(s.GPUs) == 0 && len(s.CustomMetrics) == 0) {
+		return s
+	}
+	state := *s
+	state.GPUs = nil
+	state.CustomMetrics = nil
+	return &state
+}
+
+// MaxGPUUsage 使用率最高的 GPU 的使用率，兼容只上报使用率的旧版 Agent
+func (s *HostState) MaxGPUUsage() float64 {
+	var usage float64
+	for _, v := range s.GPU {
+		usage = max(usage, v)
+	}
+	for _, g := range s.GPUs {
+		usage = max(usage, g.Utilization)
+	}
+	return usage
+}
+
+// MaxGPUTemperature 温度最高的 GPU 的温度
+func (s *HostState) MaxGPUTemperature() float64 {
+	var temp float64
+	for _, g := range s.GPUs {
+		temp = max(temp, g.Temperature)
+	}
+	return temp
+}
+
+// MaxMountUsage 使用率最高的挂载点的使用率（百分比）
+func (s *HostState) MaxMountUsage() float64 {
+	var usage float64
+	for _, m := range s.DiskMounts {
+		usage = max(usage, percentage(m.Used, m.Total))
+	}
+	return usage
+}
+
+// MaxTemperature 温度最高的传感器的温度，sensor 不为空时只统计该归一化名称的传感器
+// 读数为 0 的传感器视为未读取到
+func (s *HostState) MaxTemperature(sensor string) float64 {
+	var temp float64
+	var found bool
+	for _, t := range s.Temperatures {
+		if t.Temperature == 0 || (sensor != "" && t.Sensor != sensor) {
+			continue
+		}
+		if !found || t.Temperature > temp {
+			temp = t.Temperature
+		}
+		found = true
+	}
+	return temp
+}
+
+// SMARTFailed 未通过 SMART 自检的磁盘数量
+func (s *HostState) SMARTFailed() int {
+	var n int
+	for _, d := range s.DiskHealth {
+		if !d.SMARTPassed {
+			n++
+		}
+	}
+	return n
+}
+
+// MaxDiskWear 已用寿命最高的磁盘的已用寿命（百分比）
+func (s *HostState) MaxDiskWear() float64 {
+	var wear float64
+	for _, d := range s.DiskHealth {
+		wear = max(wear, d.WearLevel)
+	}
+	return wear
+}
+
+// NetworkInterface Agent 上报的网卡及其地址
+type NetworkInterface struct {
+	Name  string   `json:"name"`
+	Addrs []string `json:"addrs,omitempty"`
 }
 
 type Host struct {
-	Platform        string   `json:"platform,omitempty"`
-	PlatformVersion string   `json:"platform_version,omitempty"`
-	CPU             []string `json:"cpu,omitempty"`
-	MemTotal        uint64   `json:"mem_total,omitempty"`
-	DiskTotal       uint64   `json:"disk_total,omitempty"`
-	SwapTotal       uint64   `json:"swap_total,omitempty"`
-	Arch            string   `json:"arch,omitempty"`
-	Virtualization  string   `json:"virtualization,omitempty"`
-	BootTime        uint64   `json:"boot_time,omitempty"`
-	Version         string   `json:"version,omitempty"`
-	GPU             []string `json:"gpu,omitempty"`
+	Platform        string             `json:"platform,omitempty"`
+	PlatformVersion string             `json:"platform_version,omitempty"`
+	CPU             []string           `json:"cpu,omitempty"`
+	MemTotal        uint64             `json:"mem_total,omitempty"`
+	DiskTotal       uint64             `json:"disk_total,omitempty"`
+	SwapTotal       uint64             `json:"swap_total,omitempty"`
+	Arch            string             `json:"arch,omitempty"`
+	Virtualization  string             `json:"virtualization,omitempty"`
+	BootTime        uint64             `json:"boot_time,omitempty"`
+	Version         string             `json:"version,omitempty"`
+	GPU             []string           `json:"gpu,omitempty"`
+	Interfaces      []NetworkInterface `json:"interfaces,omitempty"`
 }
 
 func (h *Host) PB() *pb.Host {
+	var interfaces []*pb.NetworkInterface
+	for _, i := range h.Interfaces {
+		interfaces = append(interfaces, &pb.NetworkInterface{
+			Name:  i.Name,
+			Addrs: i.Addrs,
+		})
+	}
+
 	return &pb.Host{
 		Platform:        h.Platform,
 		PlatformVersion: h.PlatformVersion,
@@ -124,6 +351,7 @@ func (h *Host) PB() *pb.Host {
 		BootTime:        h.BootTime,
 		Version:         h.Version,
 		Gpu:             h.GPU,
+		Interfaces:      interfaces,
 	}
 }
 
@@ -143,6 +371,14 @@ func (h *Host) Filter() *Host {
 }
 
 func PB2Host(h *pb.Host) Host {
+	var interfaces []NetworkInterface
+	for _, i := range h.GetInterfaces() {
+		interfaces = append(interfaces, NetworkInterface{
+			Name:  i.GetName(),
+			Addrs: i.GetAddrs(),
+		})
+	}
+
 	return Host{
 		Platform:        h.GetPlatform(),
 		PlatformVersion: h.GetPlatformVersion(),
@@ -155,6 +391,7 @@ func PB2Host(h *pb.Host) Host {
 		BootTime:        h.GetBootTime(),
 		Version:         h.GetVersion(),
 		GPU:             h.GetGpu(),
+		Interfaces:      interfaces,
 	}
 }
 
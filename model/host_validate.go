@@ -0,0 +1,247 @@
+package model
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Agent 上报数据的合理范围，超出范围的数值视为异常上报
+const (
+	ReportMaxStringLen  = 256       // 平台、版本等字符串的最大长度
+	ReportMaxCPUModels  = 256       // CPU 型号列表的最大长度
+	ReportMaxGPUs       = 64        // GPU 列表的最大长度
+	ReportMaxSensors    = 128       // 温度传感器的最大数量
+	ReportMaxCPUCores   = 1024      // 上报的 CPU 核心的最大数量
+	ReportMaxDiskMounts = 128       // 上报的挂载点的最大数量
+	ReportMaxDisks      = 128       // 上报 SMART 状态的磁盘的最大数量
+	ReportMaxPowerOn    = 1 << 20   // 通电时间上限（小时）
+	ReportMaxGPUPower   = 10000.0   // 单个 GPU 的功耗上限（瓦）
+	ReportMaxInterfaces = 256       // 网卡的最大数量
+	ReportMaxAddrs      = 64        // 每个网卡地址的最大数量
+	ReportMaxBytes      = 1 << 60   // 内存、磁盘、流量的上限 (1 EiB)
+	ReportMaxNetSpeed   = 1 << 40   // 网速上限 (1 TiB/s)
+	ReportMaxLoad       = 1 << 20   // 系统负载上限
+	ReportMaxConnCount  = 1 << 32   // TCP、UDP 连接数上限
+	ReportMaxProcesses  = 1 << 24   // 进程数上限
+	ReportMaxUptime     = 1 << 40   // 运行时间上限（秒）
+	ReportMinTemp       = -273.15   // 温度下限（摄氏度）
+	ReportMaxTemp       = 1000.0    // 温度上限（摄氏度）
+	ReportMaxBootTime   = 1<<35 - 1 // 开机时间戳上限
+)
+
+// Sanitize 校验 Agent 上报的状态，host 为已知的主机信息
+// 数值无效或超出上限时返回错误，百分比超出范围时截断，过长的列表与字符串会被截短
+func (s *HostState) Sanitize(host *Host) error {
+	for name, v := range map[string]float64{
+		"cpu": s.CPU, "load_1": s.Load1, "load_5": s.Load5, "load_15": s.Load15,
+	} {
+		if math.IsNaN(v) || math.IsInf(v, 0) || v < 0 {
+			return fmt.Errorf("invalid %s: %v", name, v)
+		}
+	}
+	if max(s.Load1, s.Load5, s.Load15) > ReportMaxLoad {
+		return fmt.Errorf("load out of range: %v", max(s.Load1, s.Load5, s.Load15))
+	}
+	s.CPU = min(s.CPU, 100)
+
+	for name, v := range map[string]uint64{
+		"mem_used": s.MemUsed, "swap_used": s.SwapUsed, "disk_used": s.DiskUsed,
+		"net_in_transfer": s.NetInTransfer, "net_out_transfer": s.NetOutTransfer,
+	} {
+		if v > ReportMaxBytes {
+			return fmt.Errorf("%s out of range: %d", name, v)
+		}
+	}
+	if s.NetInSpeed > ReportMaxNetSpeed || s.NetOutSpeed > ReportMaxNetSpeed {
+		return fmt.Errorf("network speed out of range: %d/%d", s.NetInSpeed, s.NetOutSpeed)
+	}
+	if s.TcpConnCount > ReportMaxConnCount || s.UdpConnCount > ReportMaxConnCount {
+		return fmt.Errorf("connection count out of range: %d/%d", s.TcpConnCount, s.UdpConnCount)
+	}
+	if s.ProcessCount > ReportMaxProcesses {
+		return fmt.Errorf("process count out of range: %d", s.ProcessCount)
+	}
+	if s.Uptime > ReportMaxUptime {
+		return fmt.Errorf("uptime out of range: %d", s.Uptime)
+	}
+
+	// 已知总量时，使用量不能超过总量
+	if host != nil {
+		if host.MemTotal > 0 && s.MemUsed > host.MemTotal {
+			return fmt.Errorf("mem_used %d exceeds mem_total %d", s.MemUsed, host.MemTotal)
+		}
+		if host.SwapTotal > 0 && s.SwapUsed > host.SwapTotal {
+			return fmt.Errorf("swap_used %d exceeds swap_total %d", s.SwapUsed, host.SwapTotal)
+		}
+		if host.DiskTotal > 0 && s.DiskUsed > host.DiskTotal {
+			return fmt.Errorf("disk_used %d exceeds disk_total %d", s.DiskUsed, host.DiskTotal)
+		}
+	}
+
+	// 单个传感器或 GPU 的异常读数只丢弃该项
+	// 同一传感器在不同驱动下的别名只保留读数最高的一项
+	s.Temperatures = truncateSlice(s.Temperatures, ReportMaxSensors)
+	temps := s.Temperatures[:0]
+	seen := make(map[string]int)
+	for _, t := range s.Temperatures {
+		if math.IsNaN(t.Temperature) || t.Temperature < ReportMinTemp || t.Temperature > ReportMaxTemp {
+			continue
+		}
+		t.Name = truncateString(t.Name, ReportMaxStringLen)
+		t.Sensor = NormalizeSensorName(t.Name)
+		if i, ok := seen[t.Sensor]; ok {
+			if t.Temperature > temps[i].Temperature {
+				temps[i] = t
+			}
+			continue
+		}
+		seen[t.Sensor] = len(temps)
+		temps = append(temps, t)
+	}
+	s.Temperatures = temps
+
+	s.GPU = truncateSlice(s.GPU, ReportMaxGPUs)
+	for i, v := range s.GPU {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			v = 0
+		}
+		s.GPU[i] = min(max(v, 0), 100)
+	}
+
+	s.CPUCores = truncateSlice(s.CPUCores, ReportMaxCPUCores)
+	for i, v := range s.CPUCores {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			v = 0
+		}
+		s.CPUCores[i] = min(max(v, 0), 100)
+	}
+
+	s.DiskMounts = truncateSlice(s.DiskMounts, ReportMaxDiskMounts)
+	mounts := s.DiskMounts[:0]
+	for _, m := range s.DiskMounts {
+		if m.Total > ReportMaxBytes || m.Used > m.Total {
+			continue
+		}
+		m.Path = truncateString(m.Path, ReportMaxStringLen)
+		m.FSType = truncateString(m.FSType, ReportMaxStringLen)
+		mounts = append(mounts, m)
+	}
+	s.DiskMounts = mounts
+
+	s.GPUs = truncateSlice(s.GPUs, ReportMaxGPUs)
+	gpus := s.GPUs[:0]
+	for _, g := range s.GPUs {
+		if g.MemoryTotal > ReportMaxBytes || g.MemoryUsed > ReportMaxBytes || (g.MemoryTotal > 0 && g.MemoryUsed > g.MemoryTotal) {
+			continue
+		}
+		if math.IsNaN(g.Utilization) || math.IsInf(g.Utilization, 0) {
+			g.Utilization = 0
+		}
+		g.Utilization = min(max(g.Utilization, 0), 100)
+		// 无法读取的温度与功耗视为未上报
+		if math.IsNaN(g.Temperature) || g.Temperature < ReportMinTemp || g.Temperature > ReportMaxTemp {
+			g.Temperature = 0
+		}
+		if math.IsNaN(g.Power) || g.Power < 0 || g.Power > ReportMaxGPUPower {
+			g.Power = 0
+		}
+		g.Vendor = truncateString(g.Vendor, ReportMaxStringLen)
+		g.Model = truncateString(g.Model, ReportMaxStringLen)
+		gpus = append(gpus, g)
+	}
+	s.GPUs = gpus
+
+	s.DiskHealth = truncateSlice(s.DiskHealth, ReportMaxDisks)
+	for i := range s.DiskHealth {
+		d := &s.DiskHealth[i]
+		d.Device = truncateString(d.Device, ReportMaxStringLen)
+		if math.IsNaN(d.WearLevel) || math.IsInf(d.WearLevel, 0) {
+			d.WearLevel = 0
+		}
+		d.WearLevel = min(max(d.WearLevel, 0), 100)
+		if d.PowerOnHours > ReportMaxPowerOn {
+			d.PowerOnHours = 0
+		}
+	}
+
+	s.CustomMetrics = sanitizeCustomMetrics(s.CustomMetrics)
+	return nil
+}
+
+// sensorAliases 不同驱动对同一传感器的命名，归一化后报警规则可以在不同机器间通用
+var sensorAliases = []struct{ prefix, sensor string }{
+	{"coretemp_package_id_0", "cpu"},
+	{"coretemp_physical_id_0", "cpu"},
+	{"k10temp_tdie", "cpu"},
+	{"k10temp_tctl", "cpu"},
+	{"zenpower_tdie", "cpu"},
+	{"zenpower_tctl", "cpu"},
+	{"cpu_thermal", "cpu"},
+	{"cpu-thermal", "cpu"},
+	{"soc_thermal", "cpu"},
+	{"nvme_composite", "nvme"},
+	{"amdgpu_edge", "gpu"},
+	{"nouveau", "gpu"},
+	{"acpitz", "acpi"},
+	{"pch_", "chipset"},
+}
+
+// NormalizeSensorName 将 Agent 上报的传感器名称归一化，无已知别名时返回小写的原名称
+func NormalizeSensorName(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, a := range sensorAliases {
+		if strings.HasPrefix(name, a.prefix) {
+			return a.sensor
+		}
+	}
+	return name
+}
+
+// Sanitize 校验 Agent 上报的主机信息，规则同 HostState.Sanitize
+func (h *Host) Sanitize() error {
+	for name, v := range map[string]uint64{
+		"mem_total": h.MemTotal, "disk_total": h.DiskTotal, "swap_total": h.SwapTotal,
+	} {
+		if v > ReportMaxBytes {
+			return fmt.Errorf("%s out of range: %d", name, v)
+		}
+	}
+	if h.BootTime > ReportMaxBootTime {
+		return fmt.Errorf("boot_time out of range: %d", h.BootTime)
+	}
+
+	for _, p := range []*string{&h.Platform, &h.PlatformVersion, &h.Arch, &h.Virtualization, &h.Version} {
+		*p = truncateString(*p, ReportMaxStringLen)
+	}
+	h.CPU = truncateStrings(truncateSlice(h.CPU, ReportMaxCPUModels))
+	h.GPU = truncateStrings(truncateSlice(h.GPU, ReportMaxGPUs))
+
+	h.Interfaces = truncateSlice(h.Interfaces, ReportMaxInterfaces)
+	for i := range h.Interfaces {
+		h.Interfaces[i].Name = truncateString(h.Interfaces[i].Name, ReportMaxStringLen)
+		h.Interfaces[i].Addrs = truncateStrings(truncateSlice(h.Interfaces[i].Addrs, ReportMaxAddrs))
+	}
+	return nil
+}
+
+func truncateSlice[S ~[]E, E any](s S, n int) S {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+func truncateString(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return strings.ToValidUTF8(s[:n], "")
+}
+
+func truncateStrings(list []string) []string {
+	for i, s := range list {
+		list[i] = truncateString(s, ReportMaxStringLen)
+	}
+	return list
+}
@@ -0,0 +1,240 @@
+package model
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestHostStateSanitize(t *testing.T) {
+	host := &Host{MemTotal: 1024, SwapTotal: 0, DiskTotal: 2048}
+
+	cases := []struct {
+		name  string
+		state HostState
+		ok    bool
+	}{
+		{"Empty", HostState{}, true},
+		{"NegativeCPU", HostState{CPU: -0.1}, false},
+		{"NaNCPU", HostState{CPU: math.NaN()}, false},
+		{"InfLoad", HostState{Load5: math.Inf(1)}, false},
+		{"MaxLoad", HostState{Load1: ReportMaxLoad}, true},
+		{"LoadOverflow", HostState{Load15: ReportMaxLoad + 1}, false},
+		{"MemEqualsTotal", HostState{MemUsed: 1024}, true},
+		{"MemExceedsTotal", HostState{MemUsed: 1025}, false},
+		{"SwapWithoutTotal", HostState{SwapUsed: 4096}, true},
+		{"DiskExceedsTotal", HostState{DiskUsed: 2049}, false},
+		{"MaxTransfer", HostState{NetInTransfer: ReportMaxBytes}, true},
+		{"TransferOverflow", HostState{NetOutTransfer: ReportMaxBytes + 1}, false},
+		{"MaxNetSpeed", HostState{NetInSpeed: ReportMaxNetSpeed}, true},
+		{"NetSpeedOverflow", HostState{NetOutSpeed: ReportMaxNetSpeed + 1}, false},
+		{"MaxConnCount", HostState{TcpConnCount: ReportMaxConnCount}, true},
+		{"ConnCountOverflow", HostState{UdpConnCount: ReportMaxConnCount + 1}, false},
+		{"ProcessCountOverflow", HostState{ProcessCount: ReportMaxProcesses + 1}, false},
+		{"UptimeOverflow", HostState{Uptime: ReportMaxUptime + 1}, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := c.state.Sanitize(host)
+			if (err == nil) != c.ok {
+				t.Fatalf("expected ok=%v, got %v", c.ok, err)
+			}
+		})
+	}
+}
+
+func TestHostStateSanitizeClamp(t *testing.T) {
+	s := HostState{
+		CPU: 100.5,
+		GPU: []float64{-1, 50, 101, math.NaN()},
+		Temperatures: []SensorTemperature{
+			{Name: "ok", Temperature: 40},
+			{Name: "min", Temperature: ReportMinTemp},
+			{Name: "cold", Temperature: ReportMinTemp - 1},
+			{Name: "hot", Temperature: ReportMaxTemp + 1},
+			{Name: strings.Repeat("x", ReportMaxStringLen+1), Temperature: ReportMaxTemp},
+		},
+	}
+	if err := s.Sanitize(nil); err != nil {
+		t.Fatal(err)
+	}
+	if s.CPU != 100 {
+		t.Fatalf("expected cpu to be clamped to 100, got %v", s.CPU)
+	}
+	for i, want := range []float64{0, 50, 100, 0} {
+		if s.GPU[i] != want {
+			t.Fatalf("gpu[%d]: expected %v, got %v", i, want, s.GPU[i])
+		}
+	}
+	if len(s.Temperatures) != 3 || s.Temperatures[1].Name != "min" || len(s.Temperatures[2].Name) != ReportMaxStringLen {
+		t.Fatalf("unexpected temperatures: %+v", s.Temperatures)
+	}
+
+	s = HostState{Temperatures: make([]SensorTemperature, ReportMaxSensors+1)}
+	for i := range s.Temperatures {
+		s.Temperatures[i].Name = fmt.Sprintf("sensor%d", i)
+	}
+	s.Sanitize(nil)
+	if len(s.Temperatures) != ReportMaxSensors {
+		t.Fatalf("expected %d sensors, got %d", ReportMaxSensors, len(s.Temperatures))
+	}
+}
+
+func TestHostSanitize(t *testing.T) {
+	h := Host{
+		Platform: strings.Repeat("p", ReportMaxStringLen+10),
+		Version:  strings.Repeat("v", ReportMaxStringLen),
+		CPU:      make([]string, ReportMaxCPUModels+1),
+		Interfaces: []NetworkInterface{
+			{Name: "eth0", Addrs: make([]string, ReportMaxAddrs+1)},
+		},
+		MemTotal: ReportMaxBytes,
+		BootTime: ReportMaxBootTime,
+	}
+	if err := h.Sanitize(); err != nil {
+		t.Fatal(err)
+	}
+	if len(h.Platform) != ReportMaxStringLen || len(h.Version) != ReportMaxStringLen {
+		t.Fatal("expected strings to be truncated")
+	}
+	if len(h.CPU) != ReportMaxCPUModels || len(h.Interfaces[0].Addrs) != ReportMaxAddrs {
+		t.Fatal("expected lists to be truncated")
+	}
+
+	// 截断时不产生无效的 UTF-8
+	h = Host{Platform: strings.Repeat("a", ReportMaxStringLen-1) + "中"}
+	h.Sanitize()
+	if h.Platform != strings.Repeat("a", ReportMaxStringLen-1) {
+		t.Fatalf("unexpected platform: %q", h.Platform)
+	}
+
+	for _, h := range []Host{{MemTotal: ReportMaxBytes + 1}, {DiskTotal: ReportMaxBytes + 1}, {BootTime: ReportMaxBootTime + 1}} {
+		if err := h.Sanitize(); err == nil {
+			t.Fatalf("expected error for %+v", h)
+		}
+	}
+}
+
+func TestHostStateSanitizeCoresAndMounts(t *testing.T) {
+	s := HostState{
+		CPUCores: append(make([]float64, ReportMaxCPUCores), 50),
+		DiskMounts: []DiskMount{
+			{Path: "/", Total: 100, Used: 100},
+			{Path: "/broken", Total: 100, Used: 101},
+			{Path: "/huge", Total: ReportMaxBytes + 1},
+		},
+	}
+	s.CPUCores[0], s.CPUCores[1] = -5, 150
+	if err := s.Sanitize(nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.CPUCores) != ReportMaxCPUCores || s.CPUCores[0] != 0 || s.CPUCores[1] != 100 {
+		t.Fatalf("unexpected cores: len %d, %v", len(s.CPUCores), s.CPUCores[:2])
+	}
+	if len(s.DiskMounts) != 1 || s.DiskMounts[0].Path != "/" {
+		t.Fatalf("unexpected mounts: %+v", s.DiskMounts)
+	}
+}
+
+func TestHostStateSanitizeGPUs(t *testing.T) {
+	s := HostState{GPUs: []GPUStat{
+		{Model: "ok", Utilization: 120, MemoryUsed: 8, MemoryTotal: 16, Temperature: 70, Power: 300},
+		{Model: "unknown-sensors", Utilization: math.NaN(), Temperature: ReportMaxTemp + 1, Power: -1},
+		{Model: "bad-memory", MemoryUsed: 17, MemoryTotal: 16},
+		{Model: "no-total", MemoryUsed: 17},
+	}}
+	if err := s.Sanitize(nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.GPUs) != 3 || s.GPUs[2].Model != "no-total" {
+		t.Fatalf("unexpected gpus: %+v", s.GPUs)
+	}
+	if g := s.GPUs[0]; g.Utilization != 100 || g.Temperature != 70 || g.Power != 300 {
+		t.Fatalf("unexpected gpu: %+v", g)
+	}
+	if g := s.GPUs[1]; g.Utilization != 0 || g.Temperature != 0 || g.Power != 0 {
+		t.Fatalf("expected invalid readings to be reset: %+v", g)
+	}
+
+	s = HostState{GPUs: make([]GPUStat, ReportMaxGPUs+1)}
+	s.Sanitize(nil)
+	if len(s.GPUs) != ReportMaxGPUs {
+		t.Fatalf("expected %d gpus, got %d", ReportMaxGPUs, len(s.GPUs))
+	}
+}
+
+func TestContainerReportSanitize(t *testing.T) {
+	r := ContainerReport{Containers: make([]Container, ReportMaxContainers+1)}
+	r.Containers[0] = Container{Name: strings.Repeat("n", ReportMaxStringLen+1), CPU: math.NaN(), MemUsed: ReportMaxBytes + 1}
+	r.Containers[1] = Container{CPU: 350}
+	r.Sanitize()
+	if len(r.Containers) != ReportMaxContainers {
+		t.Fatalf("expected %d containers, got %d", ReportMaxContainers, len(r.Containers))
+	}
+	if c := r.Containers[0]; len(c.Name) != ReportMaxStringLen || c.CPU != 0 || c.MemUsed != 0 {
+		t.Fatalf("unexpected container: %+v", c)
+	}
+	if r.Containers[1].CPU != 350 {
+		t.Fatalf("expected multi-core cpu usage to be kept, got %v", r.Containers[1].CPU)
+	}
+}
+
+func TestHostStateSanitizeSensorsAndDisks(t *testing.T) {
+	s := HostState{
+		Temperatures: []SensorTemperature{
+			{Name: "k10temp_tctl", Temperature: 70},
+			{Name: "k10temp_tdie", Temperature: 60},
+			{Name: "coretemp_core_0", Temperature: 55},
+			{Name: "NVME_Composite", Temperature: 40},
+			{Name: "broken", Temperature: math.NaN()},
+		},
+		DiskHealth: []DiskHealth{
+			{Device: "sda", SMARTPassed: true, WearLevel: math.NaN(), PowerOnHours: ReportMaxPowerOn + 1},
+			{Device: "nvme0n1", WearLevel: 120, PowerOnHours: 1000},
+		},
+	}
+	if err := s.Sanitize(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(s.Temperatures) != 3 {
+		t.Fatalf("unexpected temperatures: %+v", s.Temperatures)
+	}
+	if ts := s.Temperatures[0]; ts.Sensor != "cpu" || ts.Temperature != 70 || ts.Name != "k10temp_tctl" {
+		t.Fatalf("expected aliases to be merged: %+v", ts)
+	}
+	if s.Temperatures[1].Sensor != "coretemp_core_0" || s.Temperatures[2].Sensor != "nvme" {
+		t.Fatalf("unexpected sensor names: %+v", s.Temperatures)
+	}
+
+	if d := s.DiskHealth[0]; d.WearLevel != 0 || d.PowerOnHours != 0 {
+		t.Fatalf("expected invalid readings to be reset: %+v", d)
+	}
+	if d := s.DiskHealth[1]; d.WearLevel != 100 || d.PowerOnHours != 1000 {
+		t.Fatalf("unexpected disk: %+v", d)
+	}
+
+	s = HostState{DiskHealth: make([]DiskHealth, ReportMaxDisks+1)}
+	s.Sanitize(nil)
+	if len(s.DiskHealth) != ReportMaxDisks {
+		t.Fatalf("expected %d disks, got %d", ReportMaxDisks, len(s.DiskHealth))
+	}
+}
+
+func TestProcessSnapshotSanitize(t *testing.T) {
+	p := ProcessSnapshot{Processes: make([]Process, ReportMaxProcessList+1)}
+	p.Processes[0] = Process{PID: 1, Command: strings.Repeat("c", ReportMaxStringLen+1), CPU: math.Inf(1), RSS: ReportMaxBytes + 1}
+	p.Processes[1] = Process{PID: 2, Command: "nginx", CPU: 250, RSS: 1 << 20}
+	p.Sanitize()
+
+	if len(p.Processes) != ReportMaxProcessList {
+		t.Fatalf("expected %d processes, got %d", ReportMaxProcessList, len(p.Processes))
+	}
+	if proc := p.Processes[0]; len(proc.Command) != ReportMaxStringLen || proc.CPU != 0 || proc.RSS != 0 {
+		t.Fatalf("unexpected process: %+v", proc)
+	}
+	if proc := p.Processes[1]; proc.CPU != 250 || proc.RSS != 1<<20 {
+		t.Fatalf("unexpected process: %+v", proc)
+	}
+}
@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+type IngestAnnotationForm struct {
+	Selector   ServerSelector `json:"selector"`
+	Text       string         `json:"text" minLength:"1"`
+	Time       *time.Time     `json:"time,omitempty" validate:"optional"`        // 为空时为当前时间
+	Severity   string         `json:"severity,omitempty" validate:"optional"`    // info、warning、critical，默认为 info
+	ExternalID string         `json:"external_id,omitempty" validate:"optional"` // 用于幂等，重复提交时返回已有的记录
+}
+
+type IngestServerForm struct {
+	UUID          string `json:"uuid" minLength:"1"`
+	Name          string `json:"name" minLength:"1"`
+	ServerGroupID uint64 `json:"server_group_id,omitempty" validate:"optional"`
+	ExternalID    string `json:"external_id,omitempty" validate:"optional"` // 用于幂等，重复提交时返回已有的记录
+}
+
+type IngestResult struct {
+	ID      uint64 `json:"id"`
+	Created bool   `json:"created"` // 为 false 时为已存在的记录
+}
@@ -0,0 +1,47 @@
+package model
+
+import "time"
+
+const (
+	IPFamilyV4 = "ipv4"
+	IPFamilyV6 = "ipv6"
+)
+
+// IPChangeDetector 记录服务器已确认的公网 IP，新地址需在稳定窗口内持续上报才视为变化，
+// 避免双线路在两个地址间来回切换时反复产生事件
+type IPChangeDetector struct {
+	Stable  GeoIP // 已确认的地址及其国家代码
+	pending GeoIP
+	since   time.Time
+}
+
+// Observe 处理一次上报，地址变化得到确认时返回变化前的地址
+func (d *IPChangeDetector) Observe(geoip GeoIP, now time.Time, window time.Duration) (old GeoIP, changed bool) {
+	if geoip.IP == d.Stable.IP {
+		d.pending, d.since = GeoIP{}, time.Time{}
+		return GeoIP{}, false
+	}
+	if geoip.IP != d.pending.IP {
+		d.pending, d.since = geoip, now
+	}
+	if window > 0 && now.Sub(d.since) < window {
+		return GeoIP{}, false
+	}
+
+	old, d.Stable = d.Stable, geoip
+	d.pending, d.since = GeoIP{}, time.Time{}
+	return old, true
+}
+
+// IPChanges 返回两个地址间变化的 IPv4、IPv6 及国家代码
+func IPChanges(old, new GeoIP) []ServerEventChange {
+	var changes []ServerEventChange
+	if old.IP.IPv4Addr != new.IP.IPv4Addr {
+		changes = append(changes, ServerEventChange{Field: IPFamilyV4, Old: old.IP.IPv4Addr, New: new.IP.IPv4Addr})
+	}
+	if old.IP.IPv6Addr != new.IP.IPv6Addr {
+		changes = append(changes, ServerEventChange{Field: IPFamilyV6, Old: old.IP.IPv6Addr, New: new.IP.IPv6Addr})
+	}
+	changes = append(changes, ServerEventChange{Field: "country_code", Old: old.CountryCode, New: new.CountryCode})
+	return changes
+}
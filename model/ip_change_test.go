@@ -0,0 +1,53 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIPChangeDetector(t *testing.T) {
+	a := GeoIP{IP: IP{IPv4Addr: "1.1.1.1"}, CountryCode: "us"}
+	b := GeoIP{IP: IP{IPv4Addr: "2.2.2.2"}, CountryCode: "de"}
+	window := 5 * time.Minute
+	now := time.Unix(1700000000, 0)
+	d := &IPChangeDetector{Stable: a}
+
+	// 双线路来回切换，新地址没有持续整个窗口
+	for i := range 10 {
+		ip := a
+		if i%2 == 0 {
+			ip = b
+		}
+		if _, changed := d.Observe(ip, now.Add(time.Duration(i)*time.Minute), window); changed {
+			t.Fatalf("report %d: unexpected change while flapping", i)
+		}
+	}
+
+	start := now.Add(20 * time.Minute)
+	if _, changed := d.Observe(b, start, window); changed {
+		t.Fatal("unexpected change before window elapsed")
+	}
+	if _, changed := d.Observe(b, start.Add(4*time.Minute), window); changed {
+		t.Fatal("unexpected change before window elapsed")
+	}
+	old, changed := d.Observe(b, start.Add(5*time.Minute), window)
+	if !changed || old != a || d.Stable != b {
+		t.Fatalf("expected change from %v to %v, got %v %v", a, b, old, changed)
+	}
+	if _, changed := d.Observe(b, start.Add(6*time.Minute), window); changed {
+		t.Fatal("unexpected repeated change")
+	}
+
+	// 不设置窗口时立即确认
+	if old, changed := d.Observe(a, start.Add(7*time.Minute), 0); !changed || old != b {
+		t.Fatalf("expected immediate change, got %v %v", old, changed)
+	}
+}
+
+func TestIPChanges(t *testing.T) {
+	changes := IPChanges(GeoIP{IP: IP{IPv4Addr: "1.1.1.1", IPv6Addr: "::1"}, CountryCode: "us"},
+		GeoIP{IP: IP{IPv4Addr: "1.1.1.1", IPv6Addr: "::2"}, CountryCode: "us"})
+	if len(changes) != 2 || changes[0].Field != IPFamilyV6 || changes[1].Field != "country_code" {
+		t.Fatalf("unexpected changes: %+v", changes)
+	}
+}
@@ -0,0 +1,58 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// LoginFailure 一个 IP 或账号在统计窗口内的登录失败次数
+type LoginFailure struct {
+	Key         string    `gorm:"primaryKey" json:"key"` // ip:<地址> 或 user:<用户 ID>
+	Count       int       `json:"count"`
+	WindowStart time.Time `json:"window_start"`
+	LastFailure time.Time `json:"last_failure"`
+	LockedUntil time.Time `json:"locked_until"` // 账号锁定的结束时间
+}
+
+func (c *LoginGuardConf) WindowDuration() time.Duration {
+	return time.Duration(c.Window) * time.Second
+}
+
+func LoginFailureIPKey(ip string) string {
+	return "ip:" + ip
+}
+
+func LoginFailureUserKey(id uint64) string {
+	return fmt.Sprintf("user:%d", id)
+}
+
+// Fail 记录一次失败，距离窗口开始超过 window 时重新计数
+func (f *LoginFailure) Fail(now time.Time, window time.Duration) {
+	if f.Expired(now, window) {
+		f.Count = 0
+		f.WindowStart = now
+	}
+	f.Count++
+	f.LastFailure = now
+}
+
+// Expired 统计窗口已结束且没有处于锁定中
+func (f *LoginFailure) Expired(now time.Time, window time.Duration) bool {
+	return now.Sub(f.WindowStart) > window && !now.Before(f.LockedUntil)
+}
+
+// RetryAfter 返回距离允许下次尝试的时间。
+// 失败次数达到 DelayAfter 后，每次失败后需等待的时间从 1 秒开始翻倍，最长 MaxDelay 秒
+func (f *LoginFailure) RetryAfter(now time.Time, conf *LoginGuardConf) time.Duration {
+	if now.Before(f.LockedUntil) {
+		return f.LockedUntil.Sub(now)
+	}
+	if conf.DelayAfter <= 0 || f.Count < conf.DelayAfter || f.Expired(now, conf.WindowDuration()) {
+		return 0
+	}
+	delay := min(time.Second<<min(f.Count-conf.DelayAfter, 16), time.Duration(conf.MaxDelay)*time.Second)
+	if next := f.LastFailure.Add(delay); now.Before(next) {
+		return next.Sub(now)
+	}
+	return 0
+}
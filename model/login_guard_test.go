@@ -0,0 +1,49 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoginFailureRetryAfter(t *testing.T) {
+	conf := &LoginGuardConf{Window: 900, DelayAfter: 3, MaxDelay: 4}
+	now := time.Unix(1700000000, 0)
+	f := &LoginFailure{Key: LoginFailureIPKey("1.1.1.1")}
+
+	for i := 1; i <= 2; i++ {
+		f.Fail(now, conf.WindowDuration())
+		if d := f.RetryAfter(now, conf); d != 0 {
+			t.Fatalf("failure %d: unexpected delay %v", i, d)
+		}
+	}
+
+	// 达到 DelayAfter 后等待时间翻倍，最长 MaxDelay 秒
+	for i, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second} {
+		f.Fail(now, conf.WindowDuration())
+		if d := f.RetryAfter(now, conf); d != want {
+			t.Fatalf("failure %d: expected %v, got %v", i+3, want, d)
+		}
+	}
+	if d := f.RetryAfter(now.Add(4*time.Second), conf); d != 0 {
+		t.Fatalf("expected no delay after waiting, got %v", d)
+	}
+
+	// 窗口结束后重新计数
+	later := now.Add(16 * time.Minute)
+	if d := f.RetryAfter(later, conf); d != 0 {
+		t.Fatalf("expected no delay after window, got %v", d)
+	}
+	f.Fail(later, conf.WindowDuration())
+	if f.Count != 1 {
+		t.Fatalf("expected count reset, got %d", f.Count)
+	}
+
+	// 锁定期间始终需要等待
+	f.LockedUntil = later.Add(time.Hour)
+	if d := f.RetryAfter(later, conf); d != time.Hour {
+		t.Fatalf("expected locked for 1h, got %v", d)
+	}
+	if f.Expired(later.Add(30*time.Minute), conf.WindowDuration()) {
+		t.Fatal("locked record should not expire")
+	}
+}
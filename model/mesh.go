@@ -0,0 +1,28 @@
+package model
+
+import "time"
+
+// MeshTaskIDFlag 节点间延迟测试复用 ICMP Ping 任务，任务 ID 带此标记，低位为目标服务器 ID
+const MeshTaskIDFlag = 1 << 63
+
+// MeshLatency 一次节点间延迟测试的结果
+type MeshLatency struct {
+	ID        uint64    `gorm:"primaryKey" json:"-"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+	SourceID  uint64    `gorm:"index:idx_mesh_latency_pair" json:"source_id"`
+	TargetID  uint64    `gorm:"index:idx_mesh_latency_pair" json:"target_id"`
+	Delay     float32   `json:"delay"` // 毫秒
+	Loss      float32   `json:"loss,omitempty"`
+	Reachable bool      `json:"reachable"`
+}
+
+type MeshNode struct {
+	ID   uint64 `json:"id"`
+	Name string `json:"name"`
+}
+
+// MeshMatrix 参与延迟测试的节点及各节点对的最新结果，未被调度过的节点对不在 Links 中
+type MeshMatrix struct {
+	Nodes []MeshNode     `json:"nodes"`
+	Links []*MeshLatency `json:"links"`
+}
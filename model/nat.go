@@ -1,5 +1,12 @@
 package model
 
+import (
+	"net/netip"
+
+	"github.com/goccy/go-json"
+	"gorm.io/gorm"
+)
+
 type NAT struct {
 	Common
 	Enabled  bool   `json:"enabled"`
@@ -7,4 +14,69 @@ type NAT struct {
 	ServerID uint64 `json:"server_id"`
 	Host     string `json:"host"`
 	Domain   string `json:"domain" gorm:"unique"`
+
+	AllowCIDRs        []string `gorm:"-" json:"allow_cidrs,omitempty"` // 允许访问的来源地址段，为空时不限制
+	AllowCIDRsRaw     string   `json:"-"`
+	BasicAuthUser     string   `json:"basic_auth_user,omitempty"` // 设置后由面板要求 HTTP 基本认证
+	BasicAuthPassword string   `json:"-"`                         // bcrypt 哈希
+	RequireLogin      bool     `json:"require_login,omitempty"`   // 要求已登录面板
+	BlockOnDeny       bool     `json:"block_on_deny,omitempty"`   // 拒绝访问时计入 WAF，多次拒绝后封禁来源 IP
+
+	Stats *NATStats `gorm:"-" json:"stats,omitempty"`
+
+	allowPrefixes []netip.Prefix
+}
+
+func (n *NAT) BeforeSave(tx *gorm.DB) error {
+	data, err := json.Marshal(n.AllowCIDRs)
+	if err != nil {
+		return err
+	}
+	n.AllowCIDRsRaw = string(data)
+	return nil
+}
+
+func (n *NAT) AfterFind(tx *gorm.DB) error {
+	if n.AllowCIDRsRaw != "" {
+		if err := json.Unmarshal([]byte(n.AllowCIDRsRaw), &n.AllowCIDRs); err != nil {
+			return err
+		}
+	}
+	return n.ParseAllowCIDRs()
+}
+
+// ParseAllowCIDRs 解析允许的地址段，单个 IP 视为单地址段
+func (n *NAT) ParseAllowCIDRs() error {
+	prefixes := make([]netip.Prefix, 0, len(n.AllowCIDRs))
+	for _, s := range n.AllowCIDRs {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(s)
+			if addrErr != nil {
+				return err
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	n.allowPrefixes = prefixes
+	return nil
+}
+
+// IPAllowed 检查来源 IP 是否在允许的地址段中
+func (n *NAT) IPAllowed(ip string) bool {
+	if len(n.allowPrefixes) == 0 {
+		return true
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range n.allowPrefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
 }
@@ -6,4 +6,10 @@ type NATForm struct {
 	ServerID uint64 `json:"server_id,omitempty"`
 	Host     string `json:"host,omitempty"`
 	Domain   string `json:"domain,omitempty"`
+
+	AllowCIDRs        []string `json:"allow_cidrs,omitempty" validate:"optional"`
+	BasicAuthUser     string   `json:"basic_auth_user,omitempty" validate:"optional"`
+	BasicAuthPassword string   `json:"basic_auth_password,omitempty" validate:"optional"` // 修改时提交占位符保留原有密码
+	RequireLogin      bool     `json:"require_login,omitempty" validate:"optional"`
+	BlockOnDeny       bool     `json:"block_on_deny,omitempty" validate:"optional"`
 }
@@ -0,0 +1,29 @@
+package model
+
+import "time"
+
+// NATStatDaily NAT 配置每日的流量及连接数
+type NATStatDaily struct {
+	ID          uint64    `gorm:"primaryKey" json:"-"`
+	NATID       uint64    `gorm:"uniqueIndex:idx_nat_stat_daily" json:"-"`
+	Date        time.Time `gorm:"uniqueIndex:idx_nat_stat_daily" json:"date"`
+	BytesIn     uint64    `json:"bytes_in"`  // 用户发往 Agent
+	BytesOut    uint64    `json:"bytes_out"` // Agent 返回用户
+	Connections uint64    `json:"connections"`
+	Denied      uint64    `json:"denied"` // 被访问控制拒绝的请求数
+}
+
+// NATStats NAT 配置自上次重置以来的统计
+type NATStats struct {
+	BytesIn           uint64    `json:"bytes_in"`
+	BytesOut          uint64    `json:"bytes_out"`
+	ActiveConnections int64     `json:"active_connections"`
+	TotalConnections  uint64    `json:"total_connections"`
+	Denied            uint64    `json:"denied"`
+	Since             time.Time `json:"since"`
+}
+
+type NATStatsDetail struct {
+	NATStats
+	History []*NATStatDaily `json:"history"`
+}
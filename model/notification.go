@@ -25,6 +25,11 @@ const (
 	NotificationRequestMethodPOST
 )
 
+const (
+	NotificationTypeWebhook uint8 = iota
+	NotificationTypeTelegram
+)
+
 type NotificationServerBundle struct {
 	Notification *Notification
 	Server       *Server
@@ -34,12 +39,30 @@ type NotificationServerBundle struct {
 type Notification struct {
 	Common
 	Name          string `json:"name"`
-	URL           string `json:"url"`
+	URL           string `json:"url" gorm:"serializer:secret"`
 	RequestMethod uint8  `json:"request_method"`
 	RequestType   uint8  `json:"request_type"`
-	RequestHeader string `json:"request_header" gorm:"type:longtext"`
-	RequestBody   string `json:"request_body" gorm:"type:longtext"`
+	RequestHeader string `json:"request_header" gorm:"type:longtext;serializer:secret"`
+	RequestBody   string `json:"request_body" gorm:"type:longtext;serializer:secret"`
 	VerifyTLS     *bool  `json:"verify_tls,omitempty"`
+
+	Type              uint8  `json:"type,omitempty"`
+	TelegramBotToken  string `json:"telegram_bot_token,omitempty" gorm:"serializer:secret"`
+	TelegramChatID    string `json:"telegram_chat_id,omitempty"`
+	TelegramThreadID  int64  `json:"telegram_thread_id,omitempty"`  // 论坛群组的话题 ID，为空时发送到 General
+	TelegramParseMode string `json:"telegram_parse_mode,omitempty"` // MarkdownV2、HTML，为空时为纯文本
+
+	DisableDedup bool `json:"disable_dedup,omitempty"` // 每条报警单独发送，不与同一服务器的其他报警合并，用于 PagerDuty 等按规则区分事件的集成
+}
+
+// Redacted 返回隐藏了地址路径与请求内容的副本，用于接口返回
+func (n *Notification) Redacted() *Notification {
+	p := *n
+	p.URL = RedactURL(p.URL)
+	p.RequestHeader = RedactSecret(p.RequestHeader)
+	p.RequestBody = RedactSecret(p.RequestBody)
+	p.TelegramBotToken = RedactSecret(p.TelegramBotToken)
+	return &p
 }
 
 func (ns *NotificationServerBundle) reqURL(message string) string {
@@ -56,7 +79,7 @@ func (n *Notification) reqMethod() (string, error) {
 	case NotificationRequestMethodGET:
 		return http.MethodGet, nil
 	}
-	return "", errors.New("不支持的请求方式")
+	return "", errors.New("unsupported request method")
 }
 
 func (ns *NotificationServerBundle) reqBody(message string) (string, error) {
@@ -81,7 +104,7 @@ func (ns *NotificationServerBundle) reqBody(message string) (string, error) {
 		}
 		return params.Encode(), nil
 	}
-	return "", errors.New("不支持的请求类型")
+	return "", errors.New("unsupported request type")
 }
 
 func (n *Notification) setContentType(req *http.Request) {
@@ -109,14 +132,19 @@ func (n *Notification) setRequestHeader(req *http.Request) error {
 	return nil
 }
 
+func (n *Notification) httpClient() *http.Client {
+	if n.VerifyTLS != nil && *n.VerifyTLS {
+		return utils.HttpClient
+	}
+	return utils.HttpClientSkipTlsVerify
+}
+
 func (ns *NotificationServerBundle) Send(message string) error {
-	var client *http.Client
 	n := ns.Notification
-	if n.VerifyTLS != nil && *n.VerifyTLS {
-		client = utils.HttpClient
-	} else {
-		client = utils.HttpClientSkipTlsVerify
+	if n.Type == NotificationTypeTelegram {
+		return ns.sendTelegram(message)
 	}
+	client := n.httpClient()
 
 	reqBody, err := ns.reqBody(message)
 	if err != nil {
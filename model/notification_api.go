@@ -9,4 +9,12 @@ type NotificationForm struct {
 	RequestBody   string `json:"request_body,omitempty"`
 	VerifyTLS     bool   `json:"verify_tls,omitempty" validate:"optional"`
 	SkipCheck     bool   `json:"skip_check,omitempty" validate:"optional"`
+
+	Type              uint8  `json:"type,omitempty" validate:"optional"`
+	TelegramBotToken  string `json:"telegram_bot_token,omitempty" validate:"optional"`
+	TelegramChatID    string `json:"telegram_chat_id,omitempty" validate:"optional"`
+	TelegramThreadID  int64  `json:"telegram_thread_id,omitempty" validate:"optional"`
+	TelegramParseMode string `json:"telegram_parse_mode,omitempty" validate:"optional"`
+
+	DisableDedup bool `json:"disable_dedup,omitempty" validate:"optional"`
 }
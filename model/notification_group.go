@@ -1,6 +1,34 @@
 package model
 
+import (
+	"github.com/goccy/go-json"
+	"gorm.io/gorm"
+)
+
 type NotificationGroup struct {
 	Common
-	Name string `json:"name"`
+	Name           string       `json:"name"`
+	Language       string       `json:"language,omitempty"` // 发送通知使用的语言，为空时使用系统语言
+	ActiveHoursRaw string       `json:"-"`
+	ActiveHours    *ActiveHours `gorm:"-" json:"active_hours,omitempty"` // 接收通知的时段，为空时始终接收
+}
+
+func (ng *NotificationGroup) BeforeSave(tx *gorm.DB) error {
+	if ng.ActiveHours == nil {
+		ng.ActiveHoursRaw = ""
+		return nil
+	}
+	data, err := json.Marshal(ng.ActiveHours)
+	if err != nil {
+		return err
+	}
+	ng.ActiveHoursRaw = string(data)
+	return nil
+}
+
+func (ng *NotificationGroup) AfterFind(tx *gorm.DB) error {
+	if ng.ActiveHoursRaw == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(ng.ActiveHoursRaw), &ng.ActiveHours)
 }
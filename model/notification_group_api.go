@@ -1,8 +1,10 @@
 package model
 
 type NotificationGroupForm struct {
-	Name          string   `json:"name" minLength:"1"`
-	Notifications []uint64 `json:"notifications"`
+	Name          string       `json:"name" minLength:"1"`
+	Notifications []uint64     `json:"notifications"`
+	Language      string       `json:"language,omitempty" validate:"optional"`
+	ActiveHours   *ActiveHours `json:"active_hours,omitempty" validate:"optional"`
 }
 
 type NotificationGroupResponseItem struct {
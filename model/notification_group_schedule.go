@@ -0,0 +1,147 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	QuietHoursPolicyDrop     = "drop"     // 静默时段内的通知直接丢弃
+	QuietHoursPolicyDefer    = "defer"    // 延迟到时段开始时合并为一条汇总发送
+	QuietHoursPolicyFallback = "fallback" // 转发到备用通知组
+)
+
+// 查找下一个接收时段的最长范围，超过时视为没有接收时段
+const activeHoursSearchLimit = 8 * 24 * time.Hour
+
+// ActiveHoursWindow 每周重复的接收时段，End 不大于 Start 时跨越午夜到次日
+type ActiveHoursWindow struct {
+	Days  []time.Weekday `json:"days"`  // 0 为星期日
+	Start string         `json:"start"` // 15:04
+	End   string         `json:"end"`   // 15:04
+
+	start, end int // 当天的分钟数
+}
+
+// ActiveHours 通知组的接收时段，时段外的通知按 Policy 处理
+type ActiveHours struct {
+	Timezone        string              `json:"timezone,omitempty"` // 为空时使用面板时区
+	Windows         []ActiveHoursWindow `json:"windows"`
+	Policy          string              `json:"policy"`
+	FallbackGroupID uint64              `json:"fallback_group_id,omitempty"`
+
+	loc *time.Location
+}
+
+// Validate 校验并解析接收时段，loc 为面板时区
+func (a *ActiveHours) Validate(loc *time.Location) error {
+	switch a.Policy {
+	case QuietHoursPolicyDrop, QuietHoursPolicyDefer:
+		a.FallbackGroupID = 0
+	case QuietHoursPolicyFallback:
+		if a.FallbackGroupID == 0 {
+			return errors.New("fallback group is required")
+		}
+	default:
+		return fmt.Errorf("unknown quiet hours policy: %s", a.Policy)
+	}
+	if len(a.Windows) == 0 {
+		return errors.New("active hours must have at least one window")
+	}
+
+	a.loc = loc
+	if a.Timezone != "" {
+		tz, err := time.LoadLocation(a.Timezone)
+		if err != nil {
+			return err
+		}
+		a.loc = tz
+	}
+
+	for i := range a.Windows {
+		w := &a.Windows[i]
+		if len(w.Days) == 0 {
+			return fmt.Errorf("window %d has no days", i)
+		}
+		for _, d := range w.Days {
+			if d < time.Sunday || d > time.Saturday {
+				return fmt.Errorf("window %d has invalid day: %d", i, d)
+			}
+		}
+		slices.Sort(w.Days)
+		w.Days = slices.Compact(w.Days)
+
+		var err error
+		if w.start, err = parseClock(w.Start); err != nil {
+			return fmt.Errorf("window %d: %w", i, err)
+		}
+		if w.end, err = parseClock(w.End); err != nil {
+			return fmt.Errorf("window %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// Active 判断 t 是否在接收时段内，按所在时区的本地时间计算，夏令时切换时同样按墙上时间判断
+func (a *ActiveHours) Active(t time.Time) bool {
+	loc := a.loc
+	if loc == nil {
+		loc = time.Local
+	}
+	t = t.In(loc)
+	day := t.Weekday()
+	minute := t.Hour()*60 + t.Minute()
+
+	for _, w := range a.Windows {
+		if w.start < w.end {
+			if slices.Contains(w.Days, day) && minute >= w.start && minute < w.end {
+				return true
+			}
+			continue
+		}
+		// 跨越午夜的时段：开始当天的 start 之后，或次日的 end 之前
+		if slices.Contains(w.Days, day) && minute >= w.start {
+			return true
+		}
+		if slices.Contains(w.Days, (day+6)%7) && minute < w.end {
+			return true
+		}
+	}
+	return false
+}
+
+// NextActive 返回 t 之后最近的接收时段开始时间，t 在时段内时返回 t，找不到时返回零值
+func (a *ActiveHours) NextActive(t time.Time) time.Time {
+	if a.Active(t) {
+		return t
+	}
+	// 逐分钟推进绝对时间，夏令时跳过或重复的本地时间都能得到正确结果
+	next := t.Truncate(time.Minute)
+	for end := t.Add(activeHoursSearchLimit); next.Before(end); {
+		next = next.Add(time.Minute)
+		if a.Active(next) {
+			return next
+		}
+	}
+	return time.Time{}
+}
+
+func parseClock(s string) (int, error) {
+	h, m, ok := strings.Cut(s, ":")
+	if !ok {
+		return 0, fmt.Errorf("invalid time: %q", s)
+	}
+	hour, err := strconv.Atoi(h)
+	if err != nil || hour < 0 || hour > 24 {
+		return 0, fmt.Errorf("invalid time: %q", s)
+	}
+	minute, err := strconv.Atoi(m)
+	if err != nil || minute < 0 || minute > 59 || (hour == 24 && minute != 0) {
+		return 0, fmt.Errorf("invalid time: %q", s)
+	}
+	return hour*60 + minute, nil
+}
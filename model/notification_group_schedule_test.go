@@ -0,0 +1,96 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestActiveHours(t *testing.T) {
+	ah := &ActiveHours{
+		Timezone: "Europe/Berlin",
+		Policy:   QuietHoursPolicyDefer,
+		Windows: []ActiveHoursWindow{
+			{Days: []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}, Start: "08:00", End: "22:00"},
+			{Days: []time.Weekday{time.Saturday}, Start: "22:00", End: "02:00"},
+		},
+	}
+	if err := ah.Validate(time.UTC); err != nil {
+		t.Fatal(err)
+	}
+	loc, _ := time.LoadLocation("Europe/Berlin")
+
+	cases := []struct {
+		t      time.Time
+		active bool
+	}{
+		{time.Date(2026, 10, 14, 7, 59, 0, 0, loc), false}, // 周三
+		{time.Date(2026, 10, 14, 8, 0, 0, 0, loc), true},
+		{time.Date(2026, 10, 14, 22, 0, 0, 0, loc), false},
+		{time.Date(2026, 10, 17, 23, 0, 0, 0, loc), true}, // 周六跨午夜
+		{time.Date(2026, 10, 18, 1, 59, 0, 0, loc), true},
+		{time.Date(2026, 10, 18, 2, 0, 0, 0, loc), false},
+		{time.Date(2026, 10, 18, 23, 0, 0, 0, loc), false},
+	}
+	for _, c := range cases {
+		if got := ah.Active(c.t); got != c.active {
+			t.Errorf("Active(%v) = %v, want %v", c.t, got, c.active)
+		}
+	}
+
+	// 按面板时区以外的时区传入时间时同样按接收时段所在时区判断
+	if !ah.Active(time.Date(2026, 10, 14, 6, 0, 0, 0, time.UTC)) {
+		t.Error("expected 08:00 Berlin to be active")
+	}
+
+	next := ah.NextActive(time.Date(2026, 10, 14, 23, 0, 0, 0, loc))
+	if want := time.Date(2026, 10, 15, 8, 0, 0, 0, loc); !next.Equal(want) {
+		t.Errorf("NextActive = %v, want %v", next, want)
+	}
+}
+
+func TestActiveHoursDST(t *testing.T) {
+	ah := &ActiveHours{
+		Timezone: "Europe/Berlin",
+		Policy:   QuietHoursPolicyDrop,
+		Windows:  []ActiveHoursWindow{{Days: []time.Weekday{time.Sunday}, Start: "02:30", End: "09:00"}},
+	}
+	if err := ah.Validate(time.UTC); err != nil {
+		t.Fatal(err)
+	}
+	loc, _ := time.LoadLocation("Europe/Berlin")
+
+	// 2026-03-29 02:00 跳到 03:00，02:30 不存在，时段从 03:00 开始
+	next := ah.NextActive(time.Date(2026, 3, 29, 1, 0, 0, 0, loc))
+	if want := time.Date(2026, 3, 29, 3, 0, 0, 0, loc); !next.Equal(want) {
+		t.Errorf("NextActive at spring forward = %v, want %v", next, want)
+	}
+
+	// 2026-10-25 03:00 回拨到 02:00，时段仍按本地时间 09:00 结束
+	if !ah.Active(time.Date(2026, 10, 25, 8, 59, 0, 0, loc)) {
+		t.Error("expected 08:59 to be active after fall back")
+	}
+	if ah.Active(time.Date(2026, 10, 25, 9, 0, 0, 0, loc)) {
+		t.Error("expected 09:00 to be inactive after fall back")
+	}
+	next = ah.NextActive(time.Date(2026, 10, 25, 1, 0, 0, 0, loc))
+	// 第一次出现的 02:30 (CEST)
+	if want := time.Date(2026, 10, 25, 0, 30, 0, 0, time.UTC); !next.Equal(want) {
+		t.Errorf("NextActive at fall back = %v, want %v", next, want)
+	}
+}
+
+func TestActiveHoursValidate(t *testing.T) {
+	for _, ah := range []*ActiveHours{
+		{Policy: "snooze", Windows: []ActiveHoursWindow{{Days: []time.Weekday{time.Monday}, Start: "08:00", End: "09:00"}}},
+		{Policy: QuietHoursPolicyFallback, Windows: []ActiveHoursWindow{{Days: []time.Weekday{time.Monday}, Start: "08:00", End: "09:00"}}},
+		{Policy: QuietHoursPolicyDrop},
+		{Policy: QuietHoursPolicyDrop, Windows: []ActiveHoursWindow{{Days: []time.Weekday{7}, Start: "08:00", End: "09:00"}}},
+		{Policy: QuietHoursPolicyDrop, Windows: []ActiveHoursWindow{{Days: []time.Weekday{time.Monday}, Start: "8", End: "09:00"}}},
+		{Policy: QuietHoursPolicyDrop, Windows: []ActiveHoursWindow{{Days: []time.Weekday{time.Monday}, Start: "08:00", End: "24:30"}}},
+		{Policy: QuietHoursPolicyDrop, Timezone: "Mars/Olympus", Windows: []ActiveHoursWindow{{Days: []time.Weekday{time.Monday}, Start: "08:00", End: "09:00"}}},
+	} {
+		if err := ah.Validate(time.UTC); err == nil {
+			t.Errorf("expected error for %+v", ah)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package model
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/goccy/go-json"
+)
+
+const (
+	TelegramParseModeMarkdownV2 = "MarkdownV2"
+	TelegramParseModeHTML       = "HTML"
+)
+
+const (
+	telegramDefaultAPI       = "https://api.telegram.org"
+	telegramMaxMessageLength = 4096
+	telegramMaxRetries       = 3
+	// 等待时间超过该值时直接返回错误，避免长时间阻塞其他通知
+	telegramMaxRetryAfter = time.Minute
+)
+
+// ValidTelegramParseMode 为空时按纯文本发送
+func ValidTelegramParseMode(mode string) bool {
+	switch mode {
+	case "", TelegramParseModeMarkdownV2, TelegramParseModeHTML:
+		return true
+	}
+	return false
+}
+
+var telegramMarkdownV2Replacer = strings.NewReplacer(
+	`\`, `\\`, "_", `\_`, "*", `\*`, "[", `\[`, "]", `\]`, "(", `\(`, ")", `\)`,
+	"~", `\~`, "`", "\\`", ">", `\>`, "#", `\#`, "+", `\+`, "-", `\-`,
+	"=", `\=`, "|", `\|`, "{", `\{`, "}", `\}`, ".", `\.`, "!", `\!`,
+)
+
+// telegramEscaper 按解析模式转义替换到模板中的内容
+func telegramEscaper(mode string) func(string) string {
+	switch mode {
+	case TelegramParseModeMarkdownV2:
+		return telegramMarkdownV2Replacer.Replace
+	case TelegramParseModeHTML:
+		return html.EscapeString
+	}
+	return nil
+}
+
+// splitTelegramMessage 按转义后的长度上限拆分消息内容，尽量在换行处拆分。
+// 拆分在转义前进行，每段单独套用模板，不会拆开转义序列、HTML 实体或标记
+func splitTelegramMessage(text string, escape func(string) string, limit int) []string {
+	runes := []rune(text)
+	var chunks []string
+	for len(runes) > 0 {
+		var cut, size, newline int
+		for cut < len(runes) {
+			n := 1
+			if escape != nil {
+				n = utf8.RuneCountInString(escape(string(runes[cut])))
+			}
+			if size+n > limit {
+				break
+			}
+			size += n
+			cut++
+			if runes[cut-1] == '\n' {
+				newline = cut
+			}
+		}
+		if cut < len(runes) && newline > cut/2 {
+			cut = newline
+		}
+		cut = max(cut, 1)
+		chunks = append(chunks, string(runes[:cut]))
+		runes = runes[cut:]
+	}
+	return chunks
+}
+
+type telegramResponse struct {
+	OK          bool   `json:"ok"`
+	ErrorCode   int    `json:"error_code"`
+	Description string `json:"description"`
+	Parameters  struct {
+		RetryAfter int `json:"retry_after"`
+	} `json:"parameters"`
+}
+
+// sendTelegram 通过 Bot API 发送消息。RequestBody 为消息模板，为空时只发送消息内容；
+// URL 可指定自建的 Bot API 服务地址
+func (ns *NotificationServerBundle) sendTelegram(message string) error {
+	n := ns.Notification
+	tmpl := n.RequestBody
+	if tmpl == "" {
+		tmpl = "#NEZHA#"
+	}
+	escape := telegramEscaper(n.TelegramParseMode)
+	text := ns.replaceParamsInString(tmpl, message, escape)
+	if utf8.RuneCountInString(text) <= telegramMaxMessageLength {
+		return n.sendTelegramMessage(text)
+	}
+
+	// 超长时拆分消息内容，每段按模板单独发送
+	overhead := utf8.RuneCountInString(ns.replaceParamsInString(tmpl, "", escape))
+	limit := (telegramMaxMessageLength - overhead) / max(strings.Count(tmpl, "#NEZHA#"), 1)
+	if limit <= 0 {
+		return errors.New("telegram: the message template exceeds the length limit")
+	}
+	for _, chunk := range splitTelegramMessage(message, escape, limit) {
+		if err := n.sendTelegramMessage(ns.replaceParamsInString(tmpl, chunk, escape)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *Notification) sendTelegramMessage(text string) error {
+	payload := map[string]any{
+		"chat_id": n.TelegramChatID,
+		"text":    text,
+	}
+	if n.TelegramParseMode != "" {
+		payload["parse_mode"] = n.TelegramParseMode
+	}
+	if n.TelegramThreadID != 0 {
+		payload["message_thread_id"] = n.TelegramThreadID
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	base := n.URL
+	if base == "" {
+		base = telegramDefaultAPI
+	}
+	endpoint := strings.TrimSuffix(base, "/") + "/bot" + n.TelegramBotToken + "/sendMessage"
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := n.httpClient().Do(req)
+		if err != nil {
+			return err
+		}
+		var r telegramResponse
+		err = json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&r)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("%d@%s: %w", resp.StatusCode, resp.Status, err)
+		}
+		if r.OK {
+			return nil
+		}
+
+		// 触发限流时按 retry_after 等待后重试
+		wait := time.Duration(r.Parameters.RetryAfter) * time.Second
+		if resp.StatusCode == http.StatusTooManyRequests && attempt < telegramMaxRetries && wait <= telegramMaxRetryAfter {
+			time.Sleep(max(wait, time.Second))
+			continue
+		}
+		return fmt.Errorf("telegram: %d %s", r.ErrorCode, r.Description)
+	}
+}
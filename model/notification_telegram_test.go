@@ -0,0 +1,74 @@
+package model
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goccy/go-json"
+)
+
+func TestSplitTelegramMessage(t *testing.T) {
+	text := strings.Repeat("a", 6) + "\n" + strings.Repeat("b", 6)
+	chunks := splitTelegramMessage(text, nil, 10)
+	if len(chunks) != 2 || chunks[0] != "aaaaaa\n" || chunks[1] != "bbbbbb" {
+		t.Fatalf("unexpected chunks %q", chunks)
+	}
+
+	// 按转义后的长度拆分，转义序列与 HTML 实体不会被拆开
+	chunks = splitTelegramMessage("aaaa_bbbb", telegramEscaper(TelegramParseModeMarkdownV2), 5)
+	if len(chunks) != 3 || chunks[0] != "aaaa" || chunks[1] != "_bbb" || chunks[2] != "b" {
+		t.Fatalf("unexpected chunks %q", chunks)
+	}
+	chunks = splitTelegramMessage("a&b&c", telegramEscaper(TelegramParseModeHTML), 6)
+	if len(chunks) != 3 || chunks[0] != "a&" || chunks[1] != "b&" || chunks[2] != "c" {
+		t.Fatalf("unexpected chunks %q", chunks)
+	}
+}
+
+func TestSendTelegram(t *testing.T) {
+	var requests []map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/bot123:abc/sendMessage" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		body, _ := io.ReadAll(r.Body)
+		var payload map[string]any
+		json.Unmarshal(body, &payload)
+		requests = append(requests, payload)
+		if len(requests) == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"ok":false,"error_code":429,"description":"Too Many Requests","parameters":{"retry_after":1}}`))
+			return
+		}
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer srv.Close()
+
+	ns := &NotificationServerBundle{
+		Notification: &Notification{
+			URL:               srv.URL,
+			RequestBody:       "*Alert* #NEZHA#",
+			Type:              NotificationTypeTelegram,
+			TelegramBotToken:  "123:abc",
+			TelegramChatID:    "-100",
+			TelegramThreadID:  42,
+			TelegramParseMode: TelegramParseModeMarkdownV2,
+		},
+		Loc: time.UTC,
+	}
+	if err := ns.Send("disk_usage > 90.5%"); err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) != 2 {
+		t.Fatalf("expected a retry after 429, got %d requests", len(requests))
+	}
+	p := requests[1]
+	if p["text"] != `*Alert* disk\_usage \> 90\.5%` || p["parse_mode"] != "MarkdownV2" ||
+		p["message_thread_id"] != float64(42) || p["chat_id"] != "-100" {
+		t.Fatalf("unexpected payload %v", p)
+	}
+}
@@ -74,10 +74,8 @@ func execCase(t *testing.T, item testSt) {
 			},
 			CountryCode: "",
 		},
-		LastActive:              time.Time{},
-		TaskStream:              nil,
-		PrevTransferInSnapshot:  0,
-		PrevTransferOutSnapshot: 0,
+		LastActive: time.Time{},
+		TaskStream: nil,
 	}
 	ns := NotificationServerBundle{
 		Notification: &n,
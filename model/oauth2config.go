@@ -1,6 +1,8 @@
 package model
 
 import (
+	"slices"
+
 	"golang.org/x/oauth2"
 )
 
@@ -12,6 +14,41 @@ type Oauth2Config struct {
 
 	UserInfoURL string `koanf:"user_info_url" json:"user_info_url,omitempty"`
 	UserIDPath  string `koanf:"user_id_path" json:"user_id_path,omitempty"`
+
+	// OIDC 配置，设置 Issuer 后通过 Discovery 获取端点，以 ID Token 的 sub 识别用户
+	Issuer        string   `koanf:"issuer" json:"issuer,omitempty"`
+	RoleClaim     string   `koanf:"role_claim" json:"role_claim,omitempty"`         // 用于映射角色的声明，如 groups
+	AdminValues   []string `koanf:"admin_values" json:"admin_values,omitempty"`     // 声明中包含任一值时为管理员
+	AutoProvision bool     `koanf:"auto_provision" json:"auto_provision,omitempty"` // 自动创建未绑定的用户
+}
+
+func (c *Oauth2Config) IsOIDC() bool {
+	return c.Issuer != ""
+}
+
+// MapRole 根据声明映射用户角色，未配置映射时返回 false
+func (c *Oauth2Config) MapRole(claims map[string]any) (uint8, bool) {
+	if c.RoleClaim == "" {
+		return 0, false
+	}
+
+	var values []string
+	switch v := claims[c.RoleClaim].(type) {
+	case string:
+		values = []string{v}
+	case []any:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+	for _, v := range values {
+		if slices.Contains(c.AdminValues, v) {
+			return RoleAdmin, true
+		}
+	}
+	return RoleMember, true
 }
 
 type Oauth2Endpoint struct {
@@ -0,0 +1,132 @@
+package model
+
+import (
+	"errors"
+	"math"
+	"net"
+	"regexp"
+	"time"
+)
+
+const (
+	ProbeTypeICMP       = "icmp"
+	ProbeTypeTCP        = "tcp"
+	ProbeTypeTraceroute = "traceroute"
+
+	ProbeDefaultCount   = 4
+	ProbeMaxCount       = 10               // 每个目标或每一跳最多发送的探测次数
+	ProbeMaxHops        = 30               // 路由追踪的最大跳数
+	ProbeMaxDuration    = 30 * time.Second // Agent 执行探测的最长时间
+	ReportMaxProbeSize  = 64 * 1024        // 探测结果的最大字节数
+	probeMaxTargetLen   = 253
+	probeTimeoutPadding = 5 * time.Second
+)
+
+var probeHostnameRe = regexp.MustCompile(`^([A-Za-z0-9]([A-Za-z0-9-]{0,61}[A-Za-z0-9])?\.)*[A-Za-z0-9]([A-Za-z0-9-]{0,61}[A-Za-z0-9])?\.?$`)
+
+// ProbeForm 从服务器详情页发起的临时网络探测
+type ProbeForm struct {
+	Type   string `json:"type"`                               // icmp, tcp 或 traceroute
+	Target string `json:"target"`                             // 域名或 IP
+	Port   uint16 `json:"port,omitempty" validate:"optional"` // tcp 探测的端口
+	Count  int    `json:"count,omitempty" validate:"optional"`
+}
+
+// Task 校验表单并返回下发给 Agent 的探测任务
+func (f *ProbeForm) Task() (*ProbeTask, error) {
+	switch f.Type {
+	case ProbeTypeICMP, ProbeTypeTraceroute:
+	case ProbeTypeTCP:
+		if f.Port == 0 {
+			return nil, errors.New("port is required for tcp probe")
+		}
+	default:
+		return nil, errors.New("unknown probe type " + f.Type)
+	}
+	if len(f.Target) > probeMaxTargetLen || (net.ParseIP(f.Target) == nil && !probeHostnameRe.MatchString(f.Target)) {
+		return nil, errors.New("invalid target " + f.Target)
+	}
+	if f.Count < 0 || f.Count > ProbeMaxCount {
+		return nil, errors.New("count must be between 1 and 10")
+	}
+
+	t := &ProbeTask{
+		Type:    f.Type,
+		Target:  f.Target,
+		Count:   f.Count,
+		Timeout: int(ProbeMaxDuration.Seconds()),
+	}
+	if t.Count == 0 {
+		t.Count = ProbeDefaultCount
+	}
+	switch f.Type {
+	case ProbeTypeTCP:
+		t.Port = f.Port
+	case ProbeTypeTraceroute:
+		t.MaxHops = ProbeMaxHops
+	}
+	return t, nil
+}
+
+// ProbeTask 下发给 Agent 的探测任务，Agent 需遵守其中的上限
+type ProbeTask struct {
+	Type    string `json:"type"`
+	Target  string `json:"target"`
+	Port    uint16 `json:"port,omitempty"`
+	Count   int    `json:"count"`
+	MaxHops int    `json:"max_hops,omitempty"`
+	Timeout int    `json:"timeout"` // 秒
+}
+
+// WaitTimeout 面板等待探测结果的时间，在 Agent 的执行时间之外预留传输时间
+func (t *ProbeTask) WaitTimeout() time.Duration {
+	return time.Duration(t.Timeout)*time.Second + probeTimeoutPadding
+}
+
+// ProbeResult Agent 返回的探测结果，不保存到数据库
+type ProbeResult struct {
+	Type      string     `json:"type"`
+	Target    string     `json:"target"`
+	IP        string     `json:"ip,omitempty"` // Agent 解析得到的地址
+	Port      uint16     `json:"port,omitempty"`
+	Sent      int        `json:"sent"`
+	Received  int        `json:"received"`
+	Latencies []float64  `json:"latencies,omitempty"` // 每次成功探测的延迟（毫秒），icmp 与 tcp 有值
+	Hops      []ProbeHop `json:"hops,omitempty"`      // 路由追踪的每一跳
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// ProbeHop 路由追踪中的一跳，没有响应时 IP 为空
+type ProbeHop struct {
+	TTL       int       `json:"ttl"`
+	IP        string    `json:"ip,omitempty"`
+	Host      string    `json:"host,omitempty"`
+	Sent      int       `json:"sent"`
+	Latencies []float64 `json:"latencies,omitempty"` // 毫秒
+}
+
+// Sanitize 截短过长的列表与字符串，重置无效的数值
+func (r *ProbeResult) Sanitize() {
+	r.IP = truncateString(r.IP, ReportMaxStringLen)
+	r.Sent = min(max(r.Sent, 0), ProbeMaxCount)
+	r.Latencies = sanitizeProbeLatencies(r.Latencies)
+	r.Received = min(max(r.Received, 0), r.Sent)
+	r.Hops = truncateSlice(r.Hops, ProbeMaxHops)
+	for i := range r.Hops {
+		h := &r.Hops[i]
+		h.IP = truncateString(h.IP, ReportMaxStringLen)
+		h.Host = truncateString(h.Host, ReportMaxStringLen)
+		h.Sent = min(max(h.Sent, 0), ProbeMaxCount)
+		h.Latencies = sanitizeProbeLatencies(h.Latencies)
+	}
+}
+
+func sanitizeProbeLatencies(latencies []float64) []float64 {
+	latencies = truncateSlice(latencies, ProbeMaxCount)
+	for i, l := range latencies {
+		if math.IsNaN(l) || math.IsInf(l, 0) || l < 0 {
+			latencies[i] = 0
+		}
+	}
+	return latencies
+}
@@ -0,0 +1,28 @@
+package model
+
+import "testing"
+
+func TestProbeFormTask(t *testing.T) {
+	for _, f := range []ProbeForm{
+		{Type: "udp", Target: "example.com"},
+		{Type: ProbeTypeTCP, Target: "example.com"},
+		{Type: ProbeTypeICMP, Target: "http://example.com"},
+		{Type: ProbeTypeICMP, Target: "example.com; rm -rf /"},
+		{Type: ProbeTypeICMP, Target: "example.com", Count: 11},
+	} {
+		if _, err := f.Task(); err == nil {
+			t.Fatalf("expected %+v to be rejected", f)
+		}
+	}
+
+	task, err := (&ProbeForm{Type: ProbeTypeTraceroute, Target: "2001:db8::1"}).Task()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.Count != ProbeDefaultCount || task.MaxHops != ProbeMaxHops || task.Timeout != 30 {
+		t.Fatalf("unexpected task: %+v", task)
+	}
+	if task, err := (&ProbeForm{Type: ProbeTypeTCP, Target: "example.com", Port: 443, Count: 2}).Task(); err != nil || task.Port != 443 || task.MaxHops != 0 {
+		t.Fatalf("unexpected task: %+v, %v", task, err)
+	}
+}
@@ -0,0 +1,45 @@
+package model
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	ProcessSnapshotTop     = 20                     // 按 CPU 与内存各取前 N 个进程
+	ReportMaxProcessList   = 2 * ProcessSnapshotTop // 进程快照的最大条数
+	ReportMaxSnapshotSize  = 1 << 20                // 进程快照的最大字节数
+	ProcessSnapshotTimeout = 10 * time.Second
+)
+
+// Process 进程快照中的单个进程
+type Process struct {
+	PID     uint64  `json:"pid"`
+	User    string  `json:"user,omitempty"`
+	Command string  `json:"command"`
+	CPU     float64 `json:"cpu"` // CPU 使用率（百分比，多核时可超过 100）
+	RSS     uint64  `json:"rss"` // 常驻内存（字节）
+}
+
+// ProcessSnapshot Agent 按需返回的进程快照，不保存到数据库
+type ProcessSnapshot struct {
+	Processes []Process `json:"processes"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Sanitize 截短过长的列表与字符串，重置无效的数值
+func (p *ProcessSnapshot) Sanitize() {
+	p.Processes = truncateSlice(p.Processes, ReportMaxProcessList)
+	for i := range p.Processes {
+		proc := &p.Processes[i]
+		proc.User = truncateString(proc.User, ReportMaxStringLen)
+		proc.Command = truncateString(proc.Command, ReportMaxStringLen)
+		if math.IsNaN(proc.CPU) || math.IsInf(proc.CPU, 0) || proc.CPU < 0 {
+			proc.CPU = 0
+		}
+		proc.CPU = min(proc.CPU, 100*ReportMaxCPUCores)
+		if proc.RSS > ReportMaxBytes {
+			proc.RSS = 0
+		}
+	}
+}
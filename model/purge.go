@@ -0,0 +1,71 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	PurgeEntityServer  = "server"
+	PurgeEntityService = "service"
+)
+
+const (
+	PurgeJobRunning = "running"
+	PurgeJobSuccess = "success"
+	PurgeJobFailure = "failure"
+)
+
+// 清理任务的各个步骤，对应被清理的数据
+const (
+	PurgeStepServiceHistory  = "service_history"  // 监控结果
+	PurgeStepHistoryRollup   = "history_rollup"   // 监控结果的小时、每日汇总
+	PurgeStepLatencySummary  = "latency_summary"  // 延迟分布
+	PurgeStepServiceResult   = "service_result"   // 原始检查结果
+	PurgeStepTransfer        = "transfer"         // 流量记录
+	PurgeStepTransferDaily   = "transfer_daily"   // 每日流量汇总
+	PurgeStepStateHistory    = "state_history"    // 内存中的状态采样及状态的小时汇总
+	PurgeStepServerEvent     = "server_event"     // 服务器事件
+	PurgeStepServerUptime    = "server_uptime"    // 在线状态采样
+	PurgeStepServiceOverview = "service_overview" // 内存中的 30 天可用性统计，Rows 为清零的天数
+)
+
+// PurgeStep 清理任务中的一个步骤，Rows 为已删除的行数
+type PurgeStep struct {
+	Name string `json:"name"`
+	Rows int64  `json:"rows"`
+	Done bool   `json:"done"`
+}
+
+// PurgeJob 按时间清理服务器或服务历史数据的任务，数据分批删除，可通过任务 ID 查询进度
+type PurgeJob struct {
+	ID         string      `json:"id"`
+	EntityType string      `json:"entity_type"` // server 或 service
+	EntityID   uint64      `json:"entity_id"`
+	Before     time.Time   `json:"before"`
+	Status     string      `json:"status"`
+	Steps      []PurgeStep `json:"steps"`
+	Error      string      `json:"error,omitempty"`
+	CreatedAt  time.Time   `json:"created_at"`
+	UpdatedAt  time.Time   `json:"updated_at"`
+}
+
+// Rows 返回已删除的总行数
+func (j *PurgeJob) Rows() int64 {
+	var total int64
+	for _, s := range j.Steps {
+		total += s.Rows
+	}
+	return total
+}
+
+// Summary 审计日志中记录的清理范围与各步骤删除的行数
+func (j *PurgeJob) Summary() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "before: %s", j.Before.Format(time.RFC3339))
+	for _, s := range j.Steps {
+		fmt.Fprintf(&b, ", %s: %d", s.Name, s.Rows)
+	}
+	return b.String()
+}
@@ -0,0 +1,24 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPurgeJobSummary(t *testing.T) {
+	job := &PurgeJob{
+		Before: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+		Steps: []PurgeStep{
+			{Name: PurgeStepServiceHistory, Rows: 12000, Done: true},
+			{Name: PurgeStepTransfer, Rows: 30},
+			{Name: PurgeStepStateHistory},
+		},
+	}
+	if rows := job.Rows(); rows != 12030 {
+		t.Errorf("Rows() = %d, want 12030", rows)
+	}
+	want := "before: 2024-05-01T00:00:00Z, service_history: 12000, transfer: 30, state_history: 0"
+	if s := job.Summary(); s != want {
+		t.Errorf("Summary() = %q, want %q", s, want)
+	}
+}
@@ -10,6 +10,9 @@ import (
 	"github.com/nezhahq/nezha/pkg/utils"
 )
 
+// ruleOnlineTimeout 超过该时长未收到上报时视为离线
+const ruleOnlineTimeout = 6 * time.Second
+
 const (
 	RuleCoverAll = iota
 	RuleCoverIgnoreAll
@@ -23,6 +26,17 @@ type Rule struct {
 	// 指标类型，cpu、memory、swap、disk、net_in_speed、net_out_speed
 	// net_all_speed、transfer_in、transfer_out、transfer_all、offline
 	// transfer_in_cycle、transfer_out_cycle、transfer_all_cycle
+	// cpu_core_max（任一核心使用率）、disk_mount_max（任一挂载点使用率）
+	// gpu_max（任一 GPU 使用率）、gpu_temperature_max（任一 GPU 温度）
+	// container（名称为 Container 的容器未运行）
+	// temperature_max（任一传感器温度，指定 Sensor 时只检查该传感器）
+	// smart_failed（任一磁盘未通过 SMART 自检）、disk_wear_max（任一磁盘已用寿命）
+	// agent_version（Agent 版本落后于 AgentVersion，为空时与已知的最新版本比较）
+	// tcp_conn_growth、udp_conn_growth（Window 秒内连接数的增长量）
+	// field_stale（服务器在线但 StaleField 字段超过 Window 秒未上报或为空）
+	// custom.<name>（Agent 推送的名称为 name 的自定义指标）
+	// aggregate（Selector 范围内满足 Condition 的服务器数量或百分比超出 Min/Max，不针对单台服务器）
+	// service_down（Services 中的服务监控判定为故障，为空时检查全部服务，不针对服务器）
 	Type          string          `json:"type"`
 	Min           float64         `json:"min,omitempty" validate:"optional"`                                                        // 最小阈值 (百分比、字节 kb ÷ 1024)
 	Max           float64         `json:"max,omitempty" validate:"optional"`                                                        // 最大阈值 (百分比、字节 kb ÷ 1024)
@@ -32,10 +46,21 @@ type Rule struct {
 	Duration      uint64          `json:"duration,omitempty" validate:"optional"`                                                   // 持续时间 (秒)
 	Cover         uint64          `json:"cover"`                                                                                    // 覆盖范围 RuleCoverAll/IgnoreAll
 	Ignore        map[uint64]bool `json:"ignore,omitempty" validate:"optional"`                                                     // 覆盖范围的排除
+	Container     string          `json:"container,omitempty" validate:"optional"`                                                  // container 规则检查的容器名称
+	Sensor        string          `json:"sensor,omitempty" validate:"optional"`                                                     // temperature_max 规则检查的传感器，如 cpu、nvme
+	AgentVersion  string          `json:"agent_version,omitempty" validate:"optional"`                                              // agent_version 规则比较的版本
+	VersionBehind uint64          `json:"version_behind,omitempty" validate:"optional"`                                             // agent_version 规则允许落后的次版本数
+	Selector      *ServerSelector `json:"selector,omitempty" validate:"optional"`                                                   // aggregate 规则统计的服务器范围
+	Condition     *Rule           `json:"condition,omitempty" validate:"optional"`                                                  // aggregate 规则对每台服务器检查的条件，未通过即计入
+	Percent       bool            `json:"percent,omitempty" validate:"optional"`                                                    // aggregate 规则按百分比而非数量比较 Min/Max
+	Window        uint64          `json:"window,omitempty" validate:"optional"`                                                     // 连接数变化量规则的时间窗口（秒），默认 300；field_stale 规则允许字段缺失的时长（秒），默认 3600
+	StaleField    string          `json:"stale_field,omitempty" validate:"optional"`                                                // field_stale 规则检查的字段，temperatures、disk、gpu、swap 或 connections
+	Services      []uint64        `json:"services,omitempty" validate:"optional"`                                                   // service_down 规则检查的服务，为空时检查全部服务
 
 	// 只作为缓存使用，记录下次该检测的时间
-	NextTransferAt  map[uint64]time.Time `json:"-"`
-	LastCycleStatus map[uint64]bool      `json:"-"`
+	NextTransferAt     map[uint64]time.Time `json:"-"`
+	LastCycleStatus    map[uint64]bool      `json:"-"`
+	LatestAgentVersion string               `json:"-"` // 已知的 Agent 最新版本
 }
 
 func percentage(used, total uint64) float64 {
@@ -55,6 +80,48 @@ func (u *Rule) Snapshot(cycleTransferStats *CycleTransferStats, server *Server,
 	if u.Cover == RuleCoverIgnoreAll && !u.Ignore[server.ID] {
 		return true
 	}
+	if server.HeartbeatSkipsRule(u.Type) {
+		return true
+	}
+	// 服务规则按服务检查，不针对服务器
+	if u.IsServiceDownRule() {
+		return true
+	}
+
+	// 未上报容器信息的服务器不检查
+	if u.Type == "container" {
+		return server.Containers == nil || server.Containers.Running(u.Container)
+	}
+	if u.Type == "smart_failed" {
+		return server.State == nil || server.State.SMARTFailed() == 0
+	}
+	// 版本未知时不检查
+	if u.Type == "agent_version" {
+		if server.Host == nil {
+			return true
+		}
+		behind, ok := AgentVersionBehind(server.Host.Version, utils.IfOr(u.AgentVersion != "", u.AgentVersion, u.LatestAgentVersion), u.VersionBehind)
+		return !ok || !behind
+	}
+
+	// 离线由 offline 规则负责，这里只检查在线的服务器
+	if u.Type == "field_stale" {
+		now := time.Now()
+		if now.Sub(server.LastActive) > server.OnlineTimeout(ruleOnlineTimeout) {
+			return true
+		}
+		stale, _, _ := server.Freshness.Stale(u.StaleField, now)
+		return stale < u.StaleWindow()
+	}
+
+	// 本次上报不含该自定义指标时不检查，指标暂时消失不会触发报警
+	if name, ok := u.CustomMetric(); ok {
+		if server.State == nil {
+			return true
+		}
+		v, reported := server.State.CustomMetrics[name]
+		return !reported || !((u.Max > 0 && v > u.Max) || (u.Min > 0 && v < u.Min))
+	}
 
 	// 循环区间流量检测 · 短期无需重复检测
 	if u.IsTransferDurationRule() && u.NextTransferAt[server.ID].After(time.Now()) {
@@ -66,8 +133,14 @@ func (u *Rule) Snapshot(cycleTransferStats *CycleTransferStats, server *Server,
 	switch u.Type {
 	case "cpu":
 		src = float64(server.State.CPU)
+	case "cpu_core_max":
+		src = server.State.MaxCoreUsage()
+	case "disk_mount_max":
+		src = server.State.MaxMountUsage()
 	case "gpu_max":
-		src = slices.Max(server.State.GPU)
+		src = server.State.MaxGPUUsage()
+	case "gpu_temperature_max":
+		src = server.State.MaxGPUTemperature()
 	case "memory":
 		src = percentage(server.State.MemUsed, server.Host.MemTotal)
 	case "swap":
@@ -93,21 +166,24 @@ func (u *Rule) Snapshot(cycleTransferStats *CycleTransferStats, server *Server,
 			src = float64(server.LastActive.Unix())
 		}
 	case "transfer_in_cycle":
-		src = float64(utils.SubUintChecked(server.State.NetInTransfer, server.PrevTransferInSnapshot))
+		in, _ := server.Transfer.Pending()
+		src = float64(in)
 		if u.CycleInterval != 0 {
 			var res NResult
 			db.Model(&Transfer{}).Select("SUM(`in`) AS n").Where("datetime(`created_at`) >= datetime(?) AND server_id = ?", u.GetTransferDurationStart().UTC(), server.ID).Scan(&res)
 			src += float64(res.N)
 		}
 	case "transfer_out_cycle":
-		src = float64(utils.SubUintChecked(server.State.NetOutTransfer, server.PrevTransferOutSnapshot))
+		_, out := server.Transfer.Pending()
+		src = float64(out)
 		if u.CycleInterval != 0 {
 			var res NResult
 			db.Model(&Transfer{}).Select("SUM(`out`) AS n").Where("datetime(`created_at`) >= datetime(?) AND server_id = ?", u.GetTransferDurationStart().UTC(), server.ID).Scan(&res)
 			src += float64(res.N)
 		}
 	case "transfer_all_cycle":
-		src = float64(utils.SubUintChecked(server.State.NetOutTransfer, server.PrevTransferOutSnapshot) + utils.SubUintChecked(server.State.NetInTransfer, server.PrevTransferInSnapshot))
+		in, out := server.Transfer.Pending()
+		src = float64(in + out)
 		if u.CycleInterval != 0 {
 			var res NResult
 			db.Model(&Transfer{}).Select("SUM(`in`+`out`) AS n").Where("datetime(`created_at`) >= datetime(?) AND server_id = ?", u.GetTransferDurationStart().UTC(), server.ID).Scan(&res)
@@ -125,16 +201,14 @@ func (u *Rule) Snapshot(cycleTransferStats *CycleTransferStats, server *Server,
 		src = float64(server.State.UdpConnCount)
 	case "process_count":
 		src = float64(server.State.ProcessCount)
+	case "tcp_conn_growth":
+		src = float64(server.ConnStats.Growth("tcp", time.Now(), u.GrowthWindow()))
+	case "udp_conn_growth":
+		src = float64(server.ConnStats.Growth("udp", time.Now(), u.GrowthWindow()))
 	case "temperature_max":
-		var temp []float64
-		if server.State.Temperatures != nil {
-			for _, tempStat := range server.State.Temperatures {
-				if tempStat.Temperature != 0 {
-					temp = append(temp, tempStat.Temperature)
-				}
-			}
-			src = slices.Max(temp)
-		}
+		src = server.State.MaxTemperature(u.Sensor)
+	case "disk_wear_max":
+		src = server.State.MaxDiskWear()
 	}
 
 	// 循环区间流量检测 · 更新下次需要检测时间
@@ -162,7 +236,7 @@ func (u *Rule) Snapshot(cycleTransferStats *CycleTransferStats, server *Server,
 		cycleTransferStats.To = u.GetTransferDurationEnd()
 	}
 
-	if u.Type == "offline" && float64(time.Now().Unix())-src > 6 {
+	if u.Type == "offline" && float64(time.Now().Unix())-src > server.OnlineTimeout(ruleOnlineTimeout).Seconds() {
 		return false
 	} else if (u.Max > 0 && src > u.Max) || (u.Min > 0 && src < u.Min) {
 		return false
@@ -176,10 +250,74 @@ func (u *Rule) IsTransferDurationRule() bool {
 	return strings.HasSuffix(u.Type, "_cycle")
 }
 
+func (u *Rule) IsConnGrowthRule() bool {
+	return u.Type == "tcp_conn_growth" || u.Type == "udp_conn_growth"
+}
+
+// GrowthWindow 连接数变化量规则的时间窗口
+func (u *Rule) GrowthWindow() time.Duration {
+	if u.Window == 0 {
+		return ConnGrowthWindowDefault
+	}
+	return time.Duration(u.Window) * time.Second
+}
+
+func (u *Rule) IsFieldStaleRule() bool {
+	return u.Type == "field_stale"
+}
+
+// StaleWindow field_stale 规则允许字段缺失的时长
+func (u *Rule) StaleWindow() time.Duration {
+	if u.Window == 0 {
+		return StaleWindowDefault
+	}
+	return time.Duration(u.Window) * time.Second
+}
+
 func (u *Rule) IsOfflineRule() bool {
 	return u.Type == "offline"
 }
 
+func (u *Rule) usesLatestAgentVersion() bool {
+	return (u.Type == "agent_version" && u.AgentVersion == "") || (u.Condition != nil && u.Condition.usesLatestAgentVersion())
+}
+
+func (u *Rule) IsAggregateRule() bool {
+	return u.Type == "aggregate"
+}
+
+func (u *Rule) IsServiceDownRule() bool {
+	return u.Type == "service_down"
+}
+
+// CoversService service_down 规则是否检查该服务
+func (u *Rule) CoversService(id uint64) bool {
+	return len(u.Services) == 0 || slices.Contains(u.Services, id)
+}
+
+// ServiceSnapshot 对服务检查 service_down 规则，未通过返回 false，不检查该服务时视为通过
+func (u *Rule) ServiceSnapshot(id uint64, down bool) bool {
+	return !u.CoversService(id) || !down
+}
+
+// Aggregate 对范围内的服务器检查 Condition，返回是否通过及未通过 Condition 的服务器
+func (u *Rule) Aggregate(servers []*Server, db *gorm.DB) (bool, []*Server) {
+	var matched []*Server
+	for _, s := range servers {
+		if !u.Condition.Snapshot(nil, s, db) {
+			matched = append(matched, s)
+		}
+	}
+	src := float64(len(matched))
+	if u.Percent {
+		src = percentage(uint64(len(matched)), uint64(len(servers)))
+	}
+	if (u.Max > 0 && src > u.Max) || (u.Min > 0 && src < u.Min) {
+		return false, matched
+	}
+	return true, matched
+}
+
 // GetTransferDurationStart 获取周期流量的起始时间
 func (u *Rule) GetTransferDurationStart() time.Time {
 	// Accept uppercase and lowercase
@@ -0,0 +1,130 @@
+package model
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+)
+
+const (
+	SearchTypeServer    = "server"
+	SearchTypeService   = "service"
+	SearchTypeCron      = "cron"
+	SearchTypeAlertRule = "alert_rule"
+)
+
+const (
+	SearchDefaultLimit = 5  // 每种资源默认返回的结果数
+	SearchMaxLimit     = 50 // 每种资源最多返回的结果数
+	SearchMaxQueryLen  = 100
+)
+
+// 匹配程度，越小越靠前
+const (
+	searchRankExact = iota
+	searchRankPrefix
+	searchRankContains
+)
+
+type SearchResult struct {
+	Type  string `json:"type"`
+	ID    uint64 `json:"id"`
+	Name  string `json:"name"`
+	Field string `json:"field"`           // 匹配的字段
+	Match string `json:"match,omitempty"` // 匹配字段的值，字段为名称时为空
+
+	rank int
+}
+
+type SearchResponse struct {
+	Results []*SearchResult `json:"results"`
+	Counts  map[string]int  `json:"counts"` // 每种资源匹配的总数，可能多于返回的结果
+}
+
+// SearchField 参与搜索的字段
+type SearchField struct {
+	Name  string
+	Value string
+}
+
+// Searcher 按 query 不区分大小写地匹配资源的字段，每种资源保留匹配程度最高的 limit 条结果
+type Searcher struct {
+	query   string
+	limit   int
+	results map[string][]*SearchResult
+	counts  map[string]int
+}
+
+var searchTypes = []string{SearchTypeServer, SearchTypeService, SearchTypeCron, SearchTypeAlertRule}
+
+func NewSearcher(query string, limit int) *Searcher {
+	s := &Searcher{
+		query:   strings.ToLower(query),
+		limit:   limit,
+		results: make(map[string][]*SearchResult),
+		counts:  make(map[string]int, len(searchTypes)),
+	}
+	for _, typ := range searchTypes {
+		s.counts[typ] = 0
+	}
+	return s
+}
+
+// Add 按字段顺序取匹配程度最高的字段，第一个字段为资源名称
+func (s *Searcher) Add(typ string, id uint64, name string, fields ...SearchField) {
+	fields = append([]SearchField{{Name: "name", Value: name}}, fields...)
+
+	var best *SearchResult
+	for _, f := range fields {
+		rank, ok := s.match(f.Value)
+		if !ok || (best != nil && rank >= best.rank) {
+			continue
+		}
+		best = &SearchResult{Type: typ, ID: id, Name: name, Field: f.Name, rank: rank}
+		if f.Name != "name" {
+			best.Match = f.Value
+		}
+	}
+	if best == nil {
+		return
+	}
+	s.counts[typ]++
+	s.results[typ] = append(s.results[typ], best)
+}
+
+func (s *Searcher) match(value string) (int, bool) {
+	value = strings.ToLower(value)
+	switch {
+	case value == "":
+		return 0, false
+	case value == s.query:
+		return searchRankExact, true
+	case strings.HasPrefix(value, s.query):
+		return searchRankPrefix, true
+	case strings.Contains(value, s.query):
+		return searchRankContains, true
+	}
+	return 0, false
+}
+
+// Response 每种资源按匹配程度、名称排序后截取 limit 条，再按匹配程度合并
+func (s *Searcher) Response() *SearchResponse {
+	resp := &SearchResponse{Results: make([]*SearchResult, 0), Counts: s.counts}
+	for _, typ := range searchTypes {
+		results := s.results[typ]
+		slices.SortStableFunc(results, compareSearchResult)
+		resp.Results = append(resp.Results, results[:min(len(results), s.limit)]...)
+	}
+	slices.SortStableFunc(resp.Results, func(a, b *SearchResult) int {
+		return cmp.Compare(a.rank, b.rank)
+	})
+	return resp
+}
+
+func compareSearchResult(a, b *SearchResult) int {
+	return cmp.Or(
+		cmp.Compare(a.rank, b.rank),
+		cmp.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)),
+		cmp.Compare(a.ID, b.ID),
+	)
+}
@@ -0,0 +1,31 @@
+package model
+
+import "testing"
+
+func TestSearcher(t *testing.T) {
+	s := NewSearcher("Web", 2)
+	s.Add(SearchTypeServer, 1, "db-1", SearchField{Name: "note", Value: "backs the web tier"})
+	s.Add(SearchTypeServer, 2, "web-2")
+	s.Add(SearchTypeServer, 3, "web")
+	s.Add(SearchTypeServer, 4, "cache", SearchField{Name: "ipv4", Value: "10.0.0.4"})
+	s.Add(SearchTypeService, 5, "homepage", SearchField{Name: "target", Value: "https://web.example.com"})
+	s.Add(SearchTypeCron, 6, "backup")
+
+	resp := s.Response()
+	if resp.Counts[SearchTypeServer] != 3 || resp.Counts[SearchTypeService] != 1 || resp.Counts[SearchTypeCron] != 0 {
+		t.Fatalf("unexpected counts: %v", resp.Counts)
+	}
+
+	want := []uint64{3, 2, 5}
+	if len(resp.Results) != len(want) {
+		t.Fatalf("expected %d results, got %d", len(want), len(resp.Results))
+	}
+	for i, id := range want {
+		if resp.Results[i].ID != id {
+			t.Errorf("result %d: expected id %d, got %d", i, id, resp.Results[i].ID)
+		}
+	}
+	if r := resp.Results[2]; r.Field != "target" || r.Match != "https://web.example.com" {
+		t.Errorf("unexpected match: %+v", r)
+	}
+}
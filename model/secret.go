@@ -0,0 +1,84 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"reflect"
+
+	"gorm.io/gorm/schema"
+)
+
+// SecretEncrypter 与 SecretDecrypter 用于加解密带有 serializer:secret 标签的字段，启动时由 singleton 设置
+var (
+	SecretEncrypter func(plaintext string) (string, error)
+	SecretDecrypter func(value string) (string, error)
+)
+
+func init() {
+	schema.RegisterSerializer("secret", secretSerializer{})
+}
+
+// secretSerializer 写入数据库时加密，读取时解密，内存中始终为明文
+type secretSerializer struct{}
+
+func (secretSerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue any) error {
+	var value string
+	switch v := dbValue.(type) {
+	case nil:
+	case string:
+		value = v
+	case []byte:
+		value = string(v)
+	default:
+		return fmt.Errorf("unsupported secret value: %T", dbValue)
+	}
+	if value != "" && SecretDecrypter != nil {
+		plaintext, err := SecretDecrypter(value)
+		if err != nil {
+			return fmt.Errorf("decrypt %s: %w", field.Name, err)
+		}
+		value = plaintext
+	}
+	field.ReflectValueOf(ctx, dst).SetString(value)
+	return nil
+}
+
+func (secretSerializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue any) (any, error) {
+	value, _ := fieldValue.(string)
+	if value == "" || SecretEncrypter == nil {
+		return value, nil
+	}
+	return SecretEncrypter(value)
+}
+
+// RedactSecret 隐藏非空的敏感值
+func RedactSecret(s string) string {
+	if s == "" {
+		return ""
+	}
+	return SecretPlaceholder
+}
+
+// RedactURL 保留协议与主机，隐藏路径、参数及用户信息中可能包含的凭据
+func RedactURL(s string) string {
+	if s == "" {
+		return ""
+	}
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" {
+		return SecretPlaceholder
+	}
+	if u.User == nil && (u.Path == "" || u.Path == "/") && u.RawQuery == "" && u.Fragment == "" {
+		return s
+	}
+	return u.Scheme + "://" + u.Host + "/" + SecretPlaceholder
+}
+
+// RestoreSecret 提交的值与隐藏后的原值相同时保留原值
+func RestoreSecret(submitted, old string, redact func(string) string) string {
+	if old != "" && submitted == redact(old) {
+		return old
+	}
+	return submitted
+}
@@ -14,29 +14,46 @@ import (
 type Server struct {
 	Common
 
-	Name                   string `json:"name"`
-	UUID                   string `json:"uuid,omitempty" gorm:"unique"`
-	Note                   string `json:"note,omitempty"`           // 管理员可见备注
-	PublicNote             string `json:"public_note,omitempty"`    // 公开备注
-	DisplayIndex           int    `json:"display_index"`            // 展示排序，越大越靠前
-	HideForGuest           bool   `json:"hide_for_guest,omitempty"` // 对游客隐藏
-	EnableDDNS             bool   `json:"enable_ddns,omitempty"`    // 启用DDNS
-	DDNSProfilesRaw        string `gorm:"default:'[]';column:ddns_profiles_raw" json:"-"`
-	OverrideDDNSDomainsRaw string `gorm:"default:'{}';column:override_ddns_domains_raw" json:"-"`
+	Name                   string  `json:"name"`
+	UUID                   string  `json:"uuid,omitempty" gorm:"unique"`
+	ExternalID             *string `json:"external_id,omitempty" gorm:"uniqueIndex"` // 通过接口预先创建时外部系统的 ID
+	Note                   string  `json:"note,omitempty"`                           // 管理员可见备注
+	PublicNote             string  `json:"public_note,omitempty"`                    // 公开备注
+	DisplayIndex           int     `json:"display_index"`                            // 展示排序，越大越靠前
+	HideForGuest           bool    `json:"hide_for_guest,omitempty"`                 // 对游客隐藏
+	EnableDDNS             bool    `json:"enable_ddns,omitempty"`                    // 启用DDNS
+	EnableMeshPing         bool    `json:"enable_mesh_ping,omitempty"`               // 参与节点间延迟测试
+	HeartbeatMode          bool    `json:"heartbeat_mode,omitempty"`                 // 心跳模式，Agent 只定时上报运行时间与负载
+	HeartbeatInterval      uint32  `json:"heartbeat_interval,omitempty"`             // 心跳上报间隔（秒），为 0 时使用默认值
+	DDNSProfilesRaw        string  `gorm:"default:'[]';column:ddns_profiles_raw" json:"-"`
+	OverrideDDNSDomainsRaw string  `gorm:"default:'{}';column:override_ddns_domains_raw" json:"-"`
+	TagsRaw                string  `gorm:"default:'[]'" json:"-"`
+	CapabilitiesRaw        *string `json:"-"` // Agent 上报的能力列表，旧版本 Agent 不上报时为空
+	WakeScheduleRaw        string  `json:"-"`
 
 	DDNSProfiles        []uint64            `gorm:"-" json:"ddns_profiles,omitempty" validate:"optional"` // DDNS配置
 	OverrideDDNSDomains map[uint64][]string `gorm:"-" json:"override_ddns_domains,omitempty" validate:"optional"`
+	Tags                []string            `gorm:"-" json:"tags,omitempty" validate:"optional"` // 标签，用于批量选择服务器
+	Capabilities        *AgentCapabilities  `gorm:"-" json:"capabilities,omitempty"`             // Agent 上报的能力
+	WakeSchedule        *WakeSchedule       `gorm:"-" json:"wake_schedule,omitempty"`            // 预期在线时段，为空时始终预期在线
 
-	Host       *Host      `gorm:"-" json:"host,omitempty"`
-	State      *HostState `gorm:"-" json:"state,omitempty"`
-	GeoIP      *GeoIP     `gorm:"-" json:"geoip,omitempty"`
-	LastActive time.Time  `gorm:"-" json:"last_active,omitempty"`
+	Host         *Host      `gorm:"-" json:"host,omitempty"`
+	State        *HostState `gorm:"-" json:"state,omitempty"`
+	GeoIP        *GeoIP     `gorm:"-" json:"geoip,omitempty"`
+	ConnectionIP string     `gorm:"-" json:"-"` // 面板观察到的 Agent 连接 IP
+	LastActive   time.Time  `gorm:"-" json:"last_active,omitempty"`
+	HeartbeatAt  time.Time  `gorm:"-" json:"-"` // 最近一次心跳上报中 Agent 的时间，最近一次为完整上报时为零值
+
+	Containers *ContainerReport `gorm:"-" json:"-"` // 最近一次上报的容器列表，未检测到容器运行时的 Agent 为空
+
+	Connection *ServerConnection `gorm:"-" json:"connection,omitempty"` // Agent 流的连接状态，仅在接口返回时填充
 
 	TaskStream  pb.NezhaService_RequestTaskServer `gorm:"-" json:"-"`
 	ConfigCache chan any                          `gorm:"-" json:"-"`
 
-	PrevTransferInSnapshot  uint64 `gorm:"-" json:"-"` // 上次数据点时的入站使用量
-	PrevTransferOutSnapshot uint64 `gorm:"-" json:"-"` // 上次数据点时的出站使用量
+	Transfer  *ServerTransfer `gorm:"-" json:"-"` // 上次数据点以来的流量
+	ConnStats *ConnStats      `gorm:"-" json:"-"` // 连接数的最高值与最近的变化
+	Freshness *FieldFreshness `gorm:"-" json:"-"` // 各状态字段最近一次不为空的时间
 }
 
 func InitServer(s *Server) {
@@ -44,6 +61,9 @@ func InitServer(s *Server) {
 	s.State = &HostState{}
 	s.GeoIP = &GeoIP{}
 	s.ConfigCache = make(chan any, 1)
+	s.Transfer = &ServerTransfer{}
+	s.ConnStats = &ConnStats{}
+	s.Freshness = &FieldFreshness{}
 }
 
 func (s *Server) CopyFromRunningServer(old *Server) {
@@ -51,10 +71,14 @@ func (s *Server) CopyFromRunningServer(old *Server) {
 	s.State = old.State
 	s.GeoIP = old.GeoIP
 	s.LastActive = old.LastActive
+	s.HeartbeatAt = old.HeartbeatAt
+	s.ConnectionIP = old.ConnectionIP
+	s.Containers = old.Containers
 	s.TaskStream = old.TaskStream
 	s.ConfigCache = old.ConfigCache
-	s.PrevTransferInSnapshot = old.PrevTransferInSnapshot
-	s.PrevTransferOutSnapshot = old.PrevTransferOutSnapshot
+	s.Transfer = old.Transfer
+	s.ConnStats = old.ConnStats
+	s.Freshness = old.Freshness
 }
 
 func (s *Server) AfterFind(tx *gorm.DB) error {
@@ -70,9 +94,38 @@ func (s *Server) AfterFind(tx *gorm.DB) error {
 			return nil
 		}
 	}
+	if s.TagsRaw != "" {
+		if err := json.Unmarshal([]byte(s.TagsRaw), &s.Tags); err != nil {
+			log.Println("NEZHA>> Server.AfterFind:", err)
+			return nil
+		}
+	}
+	if s.CapabilitiesRaw != nil {
+		s.Capabilities = ParseAgentCapabilities(*s.CapabilitiesRaw)
+	}
+	if s.WakeScheduleRaw != "" {
+		if err := json.Unmarshal([]byte(s.WakeScheduleRaw), &s.WakeSchedule); err != nil {
+			log.Println("NEZHA>> Server.AfterFind:", err)
+			return nil
+		}
+	}
 	return nil
 }
 
+// Supports 判断 Agent 是否允许某项功能，未上报能力的旧版本 Agent 视为全部允许
+func (s *Server) Supports(capability string) bool {
+	return s.Capabilities == nil || s.Capabilities.Has(capability)
+}
+
+// Reports Agent 明确上报了该能力，旧版本 Agent 不上报能力时返回 false，用于需要新协议的功能
+func (s *Server) Reports(capability string) bool {
+	return s.Capabilities != nil && s.Capabilities.Has(capability)
+}
+
+func (s *Server) HasTag(tag string) bool {
+	return slices.Contains(s.Tags, tag)
+}
+
 func (s *Server) SplitList(x []*Server) ([]*Server, []*Server) {
 	pri := func(s *Server) bool {
 		return s.DisplayIndex == 0
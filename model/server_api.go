@@ -8,10 +8,25 @@ type StreamServer struct {
 	PublicNote   string `json:"public_note,omitempty"`   // 公开备注，只第一个数据包有值
 	DisplayIndex int    `json:"display_index,omitempty"` // 展示排序，越大越靠前
 
+	History []StateSample `json:"history,omitempty"` // 最近的状态历史，用于预先填充图表，只第一个数据包有值
+	// 最近的自定义指标历史，只第一个数据包有值，游客不可见
+	CustomHistory map[string][]CustomMetricSample `json:"custom_history,omitempty"`
+	// 首次出现时间与最近的生命周期事件，只第一个数据包有值，游客不可见
+	FirstSeen time.Time      `json:"first_seen,omitempty"`
+	Events    []*ServerEvent `json:"events,omitempty"`
+
 	Host        *Host      `json:"host,omitempty"`
 	State       *HostState `json:"state,omitempty"`
 	CountryCode string     `json:"country_code,omitempty"`
 	LastActive  time.Time  `json:"last_active,omitempty"`
+	// 离线且不在预期在线时段内，前端按计划关机展示
+	ScheduledOff bool `json:"scheduled_off,omitempty"`
+	// 心跳模式只上报运行时间与负载，前端隐藏其余指标的面板
+	HeartbeatMode bool `json:"heartbeat_mode,omitempty"`
+
+	Capabilities *AgentCapabilities `json:"capabilities,omitempty"` // Agent 上报的能力，游客不可见
+	Connection   *ServerConnection  `json:"connection,omitempty"`   // Agent 流的连接状态，游客不可见
+	Sockets      *SocketStats       `json:"sockets,omitempty"`      // 连接数的最高值，游客不可见
 
 	// IP和ASN信息
 	IPAddress string `json:"ip_address,omitempty"` // IP地址
@@ -19,20 +34,59 @@ type StreamServer struct {
 }
 
 type StreamServerData struct {
-	Now     int64          `json:"now,omitempty"`
-	Online  int            `json:"online,omitempty"`
-	Servers []StreamServer `json:"servers,omitempty"`
+	Now       int64           `json:"now,omitempty"`
+	Online    int             `json:"online,omitempty"`
+	Servers   []StreamServer  `json:"servers,omitempty"`
+	Aggregate StreamAggregate `json:"aggregate"`
+
+	ReadOnly bool                `json:"read_only,omitempty"`
+	Banner   string              `json:"banner,omitempty"`  // 维护公告
+	Display  *DisplayPreferences `json:"display,omitempty"` // 访客的展示偏好，仅在首帧发送
+}
+
+// StreamAggregate 推送范围内所有服务器的汇总，CPU 与内存为在线服务器的平均使用率
+type StreamAggregate struct {
+	Total        int    `json:"total"`
+	Online       int    `json:"online"`
+	ScheduledOff int    `json:"scheduled_off"` // 计划关机的服务器数量，包含在 Total 中
+	CPU          int    `json:"cpu"`
+	Mem          int    `json:"mem"`
+	NetInSpeed   uint64 `json:"net_in_speed"`
+	NetOutSpeed  uint64 `json:"net_out_speed"`
+}
+
+// StreamServerSummary 精简模式下每台服务器只推送在线状态与取整后的使用率，用于大屏展示
+type StreamServerSummary struct {
+	ID            uint64 `json:"id"`
+	Name          string `json:"name"`
+	Online        bool   `json:"online"`
+	ScheduledOff  bool   `json:"scheduled_off,omitempty"`
+	HeartbeatMode bool   `json:"heartbeat_mode,omitempty"` // 心跳模式下 CPU 与内存没有数据
+	CPU           int    `json:"cpu"`
+	Mem           int    `json:"mem"`
+}
+
+type StreamSummaryData struct {
+	Now       int64                 `json:"now,omitempty"`
+	Servers   []StreamServerSummary `json:"servers,omitempty"`
+	Aggregate StreamAggregate       `json:"aggregate"`
+
+	ReadOnly bool                `json:"read_only,omitempty"`
+	Banner   string              `json:"banner,omitempty"`
+	Display  *DisplayPreferences `json:"display,omitempty"`
 }
 
 type ServerForm struct {
 	Name                string              `json:"name,omitempty"`
-	Note                string              `json:"note,omitempty" validate:"optional"`           // 管理员可见备注
-	PublicNote          string              `json:"public_note,omitempty" validate:"optional"`    // 公开备注
-	DisplayIndex        int                 `json:"display_index,omitempty" default:"0"`          // 展示排序，越大越靠前
-	HideForGuest        bool                `json:"hide_for_guest,omitempty" validate:"optional"` // 对游客隐藏
-	EnableDDNS          bool                `json:"enable_ddns,omitempty" validate:"optional"`    // 启用DDNS
-	DDNSProfiles        []uint64            `json:"ddns_profiles,omitempty" validate:"optional"`  // DDNS配置
+	Note                string              `json:"note,omitempty" validate:"optional"`             // 管理员可见备注
+	PublicNote          string              `json:"public_note,omitempty" validate:"optional"`      // 公开备注
+	DisplayIndex        int                 `json:"display_index,omitempty" default:"0"`            // 展示排序，越大越靠前
+	HideForGuest        bool                `json:"hide_for_guest,omitempty" validate:"optional"`   // 对游客隐藏
+	EnableDDNS          bool                `json:"enable_ddns,omitempty" validate:"optional"`      // 启用DDNS
+	EnableMeshPing      bool                `json:"enable_mesh_ping,omitempty" validate:"optional"` // 参与节点间延迟测试
+	DDNSProfiles        []uint64            `json:"ddns_profiles,omitempty" validate:"optional"`    // DDNS配置
 	OverrideDDNSDomains map[uint64][]string `json:"override_ddns_domains,omitempty" validate:"optional"`
+	Tags                []string            `json:"tags,omitempty" validate:"optional"`
 }
 
 type ServerConfigForm struct {
@@ -0,0 +1,35 @@
+package model
+
+import "time"
+
+const (
+	ConnectionStateConnected    = "connected"
+	ConnectionStateIdle         = "idle"         // 流仍然打开，但一段时间内没有收到任何消息
+	ConnectionStateReconnecting = "reconnecting" // 流刚刚断开，等待 Agent 重新连接
+	ConnectionStateDisconnected = "disconnected"
+)
+
+// ServerConnection Agent 与面板之间 gRPC 流的连接状态，与最后一次上报时间相互独立
+type ServerConnection struct {
+	State          string    `json:"state"`
+	Streams        int       `json:"streams"` // 当前打开的流数量
+	ConnectedAt    time.Time `json:"connected_at,omitempty"`
+	DisconnectedAt time.Time `json:"disconnected_at,omitempty"`
+	LastActivity   time.Time `json:"last_activity,omitempty"` // 任意流最后一次收到消息的时间
+	LastError      string    `json:"last_error,omitempty"`    // 最近一次流异常结束的原因
+	LastErrorAt    time.Time `json:"last_error_at,omitempty"`
+}
+
+// Evaluate 根据流的活动情况计算连接状态，idleAfter 内没有消息视为空闲，断开后 reconnectWithin 内视为重连中
+func (c *ServerConnection) Evaluate(now time.Time, idleAfter, reconnectWithin time.Duration) string {
+	switch {
+	case c.Streams > 0 && now.Sub(c.LastActivity) > idleAfter:
+		return ConnectionStateIdle
+	case c.Streams > 0:
+		return ConnectionStateConnected
+	case !c.DisconnectedAt.IsZero() && now.Sub(c.DisconnectedAt) < reconnectWithin:
+		return ConnectionStateReconnecting
+	default:
+		return ConnectionStateDisconnected
+	}
+}
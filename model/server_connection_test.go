@@ -0,0 +1,25 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestServerConnectionEvaluate(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	cases := []struct {
+		conn ServerConnection
+		want string
+	}{
+		{ServerConnection{Streams: 2, LastActivity: now.Add(-5 * time.Second)}, ConnectionStateConnected},
+		{ServerConnection{Streams: 1, LastActivity: now.Add(-time.Minute)}, ConnectionStateIdle},
+		{ServerConnection{DisconnectedAt: now.Add(-time.Minute)}, ConnectionStateReconnecting},
+		{ServerConnection{DisconnectedAt: now.Add(-time.Hour)}, ConnectionStateDisconnected},
+		{ServerConnection{}, ConnectionStateDisconnected},
+	}
+	for i, c := range cases {
+		if got := c.conn.Evaluate(now, 30*time.Second, 2*time.Minute); got != c.want {
+			t.Errorf("case %d: expected %s, got %s", i, c.want, got)
+		}
+	}
+}
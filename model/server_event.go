@@ -0,0 +1,120 @@
+package model
+
+import (
+	"slices"
+	"time"
+
+	"github.com/goccy/go-json"
+	"gorm.io/gorm"
+)
+
+const (
+	ServerEventRegistered      = "registered" // Agent 自动注册或通过接口预先创建
+	ServerEventRenamed         = "renamed"
+	ServerEventUpdated         = "updated"
+	ServerEventGroupJoined     = "group_joined"
+	ServerEventGroupLeft       = "group_left"
+	ServerEventAgentVersion    = "agent_version"
+	ServerEventIPChanged       = "ip_changed"
+	ServerEventTransferAnomaly = "transfer_anomaly" // 流量增量超过线路速率，已截断
+	ServerEventBackfilled      = "backfilled"       // Agent 补报断线期间的状态，from 至 to 期间视为在线
+	ServerEventConnection      = "connection"       // Agent gRPC 流的连接状态变化
+	ServerEventDeleted         = "deleted"          // 服务器被删除，事件保留至保留期结束，Old 为删除前的名称
+)
+
+const (
+	ServerEventActorSystem = "system"
+	ServerEventActorAgent  = "agent"
+	ServerEventActorUser   = "user"
+)
+
+// ServerEventChange 事件中变化的字段，首次记录时 Old 为空
+type ServerEventChange struct {
+	Field string `json:"field"`
+	Old   any    `json:"old,omitempty"`
+	New   any    `json:"new,omitempty"`
+}
+
+// ServerEvent 服务器的生命周期事件
+type ServerEvent struct {
+	ID         uint64              `gorm:"primaryKey" json:"id"`
+	CreatedAt  time.Time           `gorm:"index" json:"created_at"`
+	ServerID   uint64              `gorm:"index" json:"server_id"`
+	Type       string              `json:"type"`
+	ActorType  string              `json:"actor_type"`
+	ActorID    uint64              `json:"actor_id,omitempty"` // 操作用户的 ID
+	ChangesRaw string              `json:"-"`
+	Changes    []ServerEventChange `gorm:"-" json:"changes,omitempty"`
+}
+
+func (e *ServerEvent) BeforeSave(tx *gorm.DB) error {
+	data, err := json.Marshal(e.Changes)
+	if err != nil {
+		return err
+	}
+	e.ChangesRaw = string(data)
+	return nil
+}
+
+func (e *ServerEvent) AfterFind(tx *gorm.DB) error {
+	if e.ChangesRaw == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(e.ChangesRaw), &e.Changes)
+}
+
+// Change 返回指定字段的变化
+func (e *ServerEvent) Change(field string) (ServerEventChange, bool) {
+	i := slices.IndexFunc(e.Changes, func(c ServerEventChange) bool { return c.Field == field })
+	if i < 0 {
+		return ServerEventChange{}, false
+	}
+	return e.Changes[i], true
+}
+
+// BackfilledRange 返回补报事件覆盖的时间段
+func (e *ServerEvent) BackfilledRange() (TimeRange, bool) {
+	from, ok := e.changeMillis("from")
+	if !ok {
+		return TimeRange{}, false
+	}
+	to, ok := e.changeMillis("to")
+	if !ok {
+		return TimeRange{}, false
+	}
+	return TimeRange{Start: time.UnixMilli(from), End: time.UnixMilli(to)}, true
+}
+
+// changeMillis 读取字段中的毫秒时间戳，从数据库读取后数值为 float64
+func (e *ServerEvent) changeMillis(field string) (int64, bool) {
+	c, ok := e.Change(field)
+	if !ok {
+		return 0, false
+	}
+	switch v := c.New.(type) {
+	case float64:
+		return int64(v), true
+	case int64:
+		return v, true
+	}
+	return 0, false
+}
+
+// DiffServer 比较通过接口修改的服务器字段，不包含仅管理员可见的备注
+func DiffServer(old, new *Server) []ServerEventChange {
+	var changes []ServerEventChange
+	add := func(field string, o, n any, equal bool) {
+		if !equal {
+			changes = append(changes, ServerEventChange{Field: field, Old: o, New: n})
+		}
+	}
+	add("name", old.Name, new.Name, old.Name == new.Name)
+	add("public_note", old.PublicNote, new.PublicNote, old.PublicNote == new.PublicNote)
+	add("display_index", old.DisplayIndex, new.DisplayIndex, old.DisplayIndex == new.DisplayIndex)
+	add("hide_for_guest", old.HideForGuest, new.HideForGuest, old.HideForGuest == new.HideForGuest)
+	add("enable_ddns", old.EnableDDNS, new.EnableDDNS, old.EnableDDNS == new.EnableDDNS)
+	add("enable_mesh_ping", old.EnableMeshPing, new.EnableMeshPing, old.EnableMeshPing == new.EnableMeshPing)
+	add("ddns_profiles", old.DDNSProfiles, new.DDNSProfiles, slices.Equal(old.DDNSProfiles, new.DDNSProfiles))
+	add("tags", old.Tags, new.Tags, slices.Equal(old.Tags, new.Tags))
+	return changes
+}
@@ -0,0 +1,21 @@
+package model
+
+import "testing"
+
+func TestDiffServer(t *testing.T) {
+	old := &Server{Name: "a", Tags: []string{"x"}}
+	old.Note = "private"
+	new := &Server{Name: "b", Tags: []string{"x", "y"}}
+	new.Note = "changed"
+
+	changes := DiffServer(old, new)
+	if len(changes) != 2 || changes[0].Field != "name" || changes[1].Field != "tags" {
+		t.Fatalf("unexpected changes: %+v", changes)
+	}
+	if changes[0].Old != "a" || changes[0].New != "b" {
+		t.Errorf("unexpected name change: %+v", changes[0])
+	}
+	if len(DiffServer(old, old)) != 0 {
+		t.Error("expected no changes")
+	}
+}
@@ -0,0 +1,14 @@
+package model
+
+// ServerSelector 按条件选择服务器，在执行时解析为具体的服务器，新加入的服务器会自动包含在内
+type ServerSelector struct {
+	All          bool     `json:"all,omitempty"`           // 全部服务器
+	Servers      []uint64 `json:"servers,omitempty"`       // 指定服务器
+	ServerGroups []uint64 `json:"server_groups,omitempty"` // 指定服务器分组
+	Tags         []string `json:"tags,omitempty"`          // 包含任一标签的服务器
+	Exclude      []uint64 `json:"exclude,omitempty"`       // 排除的服务器
+}
+
+func (s *ServerSelector) IsEmpty() bool {
+	return s == nil || (!s.All && len(s.Servers) == 0 && len(s.ServerGroups) == 0 && len(s.Tags) == 0)
+}
@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// ServerUptimeSampleInterval 面板采样服务器在线状态的间隔
+const ServerUptimeSampleInterval = time.Minute
+
+// ServerUptime 服务器每小时的在线采样计数，用于计算服务器分组的可用率
+type ServerUptime struct {
+	ID       uint64    `gorm:"primaryKey" json:"-"`
+	ServerID uint64    `gorm:"uniqueIndex:idx_server_uptime" json:"server_id"`
+	Start    time.Time `gorm:"uniqueIndex:idx_server_uptime" json:"start"`
+	Up       uint64    `json:"up"`
+	Down     uint64    `json:"down"`
+}
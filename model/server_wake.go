@@ -0,0 +1,66 @@
+package model
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// WakeSchedule 服务器预期在线的每周时段，时段外离线视为计划关机，不触发离线报警
+type WakeSchedule struct {
+	Timezone string              `json:"timezone,omitempty"` // 为空时使用面板时区
+	Windows  []ActiveHoursWindow `json:"windows"`
+
+	hours *ActiveHours
+}
+
+// Validate 校验并解析预期在线时段，时段之间不能重叠，loc 为面板时区
+func (w *WakeSchedule) Validate(loc *time.Location) error {
+	hours := &ActiveHours{Timezone: w.Timezone, Windows: w.Windows, Policy: QuietHoursPolicyDrop}
+	if err := hours.Validate(loc); err != nil {
+		return err
+	}
+
+	// 按一周内的分钟标记每个时段，跨越午夜的时段延续到次日，周六跨到周日
+	owner := make([]int, 7*24*60)
+	for i, win := range hours.Windows {
+		length := win.end - win.start
+		if length <= 0 {
+			length += 24 * 60
+		}
+		for _, d := range win.Days {
+			begin := int(d)*24*60 + win.start
+			for m := begin; m < begin+length; m++ {
+				k := m % len(owner)
+				if owner[k] != 0 && owner[k] != i+1 {
+					return fmt.Errorf("window %d overlaps window %d", i, owner[k]-1)
+				}
+				owner[k] = i + 1
+			}
+		}
+	}
+
+	w.Windows = hours.Windows
+	w.hours = hours
+	return nil
+}
+
+// Expected 判断 t 是否在预期在线时段内，未设置时段时始终预期在线
+func (w *WakeSchedule) Expected(t time.Time) bool {
+	if w == nil || w.hours == nil {
+		return true
+	}
+	return w.hours.Active(t)
+}
+
+// ScheduledOff 服务器离线且不在预期在线时段内
+func (s *Server) ScheduledOff(now time.Time, online bool) bool {
+	return !online && !s.WakeSchedule.Expected(now)
+}
+
+// PausedBySchedule 包含离线检查的报警规则在服务器计划关机期间暂停检查，报警状态保持不变：
+// 时段外离线不报警，时段结束时也不会因跳过检查而误发恢复通知
+func (r *AlertRule) PausedBySchedule(server *Server, now time.Time) bool {
+	online := now.Sub(server.LastActive) <= server.OnlineTimeout(ruleOnlineTimeout)
+	return server.ScheduledOff(now, online) && slices.ContainsFunc(r.Rules, (*Rule).IsOfflineRule)
+}
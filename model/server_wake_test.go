@@ -0,0 +1,84 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWakeSchedule(t *testing.T) {
+	w := &WakeSchedule{
+		Timezone: "Asia/Shanghai",
+		Windows: []ActiveHoursWindow{
+			{Days: []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}, Start: "09:00", End: "19:00"},
+			{Days: []time.Weekday{time.Friday}, Start: "20:00", End: "02:00"},
+		},
+	}
+	if err := w.Validate(time.UTC); err != nil {
+		t.Fatal(err)
+	}
+	loc, _ := time.LoadLocation("Asia/Shanghai")
+
+	cases := []struct {
+		t        time.Time
+		expected bool
+	}{
+		{time.Date(2026, 10, 16, 8, 59, 0, 0, loc), false}, // 周五
+		{time.Date(2026, 10, 16, 9, 0, 0, 0, loc), true},
+		{time.Date(2026, 10, 16, 19, 30, 0, 0, loc), false},
+		{time.Date(2026, 10, 17, 1, 0, 0, 0, loc), true}, // 周五晚跨午夜
+		{time.Date(2026, 10, 17, 9, 30, 0, 0, loc), false},
+		{time.Date(2026, 10, 16, 1, 30, 0, 0, time.UTC), true}, // 上海 09:30
+	}
+	for _, c := range cases {
+		if got := w.Expected(c.t); got != c.expected {
+			t.Errorf("Expected(%v) = %v, want %v", c.t, got, c.expected)
+		}
+	}
+
+	s := &Server{WakeSchedule: w}
+	night := time.Date(2026, 10, 16, 23, 59, 0, 0, time.UTC)
+	if !s.ScheduledOff(night, false) || s.ScheduledOff(night, true) {
+		t.Error("expected offline server outside the windows to be scheduled off")
+	}
+	if (&Server{}).ScheduledOff(night, false) {
+		t.Error("expected server without schedule to never be scheduled off")
+	}
+
+	offline := &AlertRule{Rules: []*Rule{{Type: "offline", Duration: 60}}}
+	if !offline.PausedBySchedule(s, night) {
+		t.Error("expected offline rule to be paused outside the windows")
+	}
+	if offline.PausedBySchedule(s, time.Date(2026, 10, 16, 10, 0, 0, 0, loc)) {
+		t.Error("expected offline rule to be checked inside the windows")
+	}
+	if (&AlertRule{Rules: []*Rule{{Type: "cpu", Max: 90}}}).PausedBySchedule(s, night) {
+		t.Error("expected rules without offline check to keep running")
+	}
+}
+
+func TestWakeScheduleOverlap(t *testing.T) {
+	for _, windows := range [][]ActiveHoursWindow{
+		{
+			{Days: []time.Weekday{time.Monday}, Start: "09:00", End: "18:00"},
+			{Days: []time.Weekday{time.Monday}, Start: "17:00", End: "20:00"},
+		},
+		// 周六跨午夜到周日
+		{
+			{Days: []time.Weekday{time.Saturday}, Start: "22:00", End: "03:00"},
+			{Days: []time.Weekday{time.Sunday}, Start: "02:00", End: "04:00"},
+		},
+	} {
+		w := &WakeSchedule{Windows: windows}
+		if err := w.Validate(time.UTC); err == nil {
+			t.Errorf("expected overlap error for %+v", windows)
+		}
+	}
+
+	w := &WakeSchedule{Windows: []ActiveHoursWindow{
+		{Days: []time.Weekday{time.Saturday}, Start: "22:00", End: "03:00"},
+		{Days: []time.Weekday{time.Sunday}, Start: "03:00", End: "04:00"},
+	}}
+	if err := w.Validate(time.UTC); err != nil {
+		t.Errorf("adjacent windows should not overlap: %v", err)
+	}
+}
@@ -26,6 +26,16 @@ const (
 	TaskTypeFM
 	TaskTypeReportConfig
 	TaskTypeApplyConfig
+	TaskTypeReportNetInterfaces
+	TaskTypeDNS
+	TaskTypeComposite        // 组合服务，由面板汇总子服务状态，不下发给 Agent
+	TaskTypeReportContainers // Agent 上报容器列表
+	TaskTypeProcessSnapshot  // 按需获取进程快照
+	TaskTypeFilePush         // 分块推送文件
+	TaskTypeProbe            // 从面板发起的临时网络探测
+	TaskTypeAgentLogs        // 按需获取 Agent 的近期日志
+	TaskTypeReportBackfill   // Agent 重新连接后补报断线期间的状态
+	TaskTypeHeartbeat        // 心跳模式下 Agent 上报的最小状态
 )
 
 type TerminalTask struct {
@@ -41,9 +51,25 @@ type TaskFM struct {
 	StreamID string
 }
 
+// ProbeTaskData 配置了超时时间的 TCP/ICMP 监控任务数据
+type ProbeTaskData struct {
+	Target   string `json:"target"`
+	Timeout  uint32 `json:"timeout,omitempty"`  // 秒
+	Count    uint8  `json:"count,omitempty"`    // ICMP 每次检查发送的包数
+	Size     uint16 `json:"size,omitempty"`     // ICMP 包大小（字节）
+	Interval uint16 `json:"interval,omitempty"` // ICMP 发包间隔（毫秒）
+}
+
+// 服务监控检查间隔的范围（秒）
+const (
+	ServiceIntervalMin = 5
+	ServiceIntervalMax = 86400
+)
+
 const (
 	ServiceCoverAll = iota
 	ServiceCoverIgnoreAll
+	ServiceCoverSelected // 按 ProbeSelector 选择监测点
 )
 
 type Service struct {
@@ -52,15 +78,27 @@ type Service struct {
 	Type                uint8  `json:"type"`
 	Target              string `json:"target"`
 	SkipServersRaw      string `json:"-"`
-	Duration            uint64 `json:"duration"`
+	Duration            uint64 `json:"duration"`                     // 检查间隔（秒）
+	TimeoutSeconds      uint32 `json:"timeout_seconds,omitempty"`    // 单次检查超时（秒），0 为 Agent 默认值
+	FailureThreshold    uint8  `json:"failure_threshold,omitempty"`  // 连续失败多少次后判定为故障，0 为按在线率判定
+	RecoveryThreshold   uint8  `json:"recovery_threshold,omitempty"` // 故障后连续成功多少次判定为恢复，默认 1 次
+	QuorumCount         uint8  `json:"quorum_count,omitempty"`       // 至少多少个监测点失败时判定为故障
+	QuorumPercent       uint8  `json:"quorum_percent,omitempty"`     // 至少多少比例的监测点失败时判定为故障
+	QuorumWindow        uint32 `json:"quorum_window,omitempty"`      // 监测点结果的有效期（秒），默认 3 个检查间隔
 	Notify              bool   `json:"notify,omitempty"`
 	NotificationGroupID uint64 `json:"notification_group_id"` // 当前服务监控所属的通知组 ID
 	Cover               uint8  `json:"cover"`
 
 	EnableTriggerTask      bool   `gorm:"default: false" json:"enable_trigger_task,omitempty"`
 	EnableShowInService    bool   `gorm:"default: false" json:"enable_show_in_service,omitempty"`
+	RecordResults          bool   `gorm:"default: false" json:"record_results,omitempty"` // 保存每次检查的原始结果，用于导出
 	FailTriggerTasksRaw    string `gorm:"default:'[]'" json:"-"`
 	RecoverTriggerTasksRaw string `gorm:"default:'[]'" json:"-"`
+	HTTPAssertionRaw       string `gorm:"default:'{}'" json:"-"`
+	DNSConfigRaw           string `gorm:"default:'{}'" json:"-"`
+	HTTPConfigRaw          string `gorm:"default:'{}'" json:"-"`
+	ProbeSelectorRaw       string `gorm:"default:'{}'" json:"-"`
+	CompositeRaw           string `gorm:"default:'{}'" json:"-"`
 
 	FailTriggerTasks    []uint64 `gorm:"-" json:"fail_trigger_tasks"`    // 失败时执行的触发任务id
 	RecoverTriggerTasks []uint64 `gorm:"-" json:"recover_trigger_tasks"` // 恢复时执行的触发任务id
@@ -69,16 +107,126 @@ type Service struct {
 	MaxLatency    float32 `json:"max_latency"`
 	LatencyNotify bool    `json:"latency_notify,omitempty"`
 
+	ICMPCount    uint8   `json:"icmp_count,omitempty"`    // 每次检查发送的包数，默认 3 个
+	ICMPSize     uint16  `json:"icmp_size,omitempty"`     // 包大小（字节），0 为 Agent 默认值
+	ICMPInterval uint16  `json:"icmp_interval,omitempty"` // 发包间隔（毫秒），0 为 Agent 默认值
+	MaxLoss      float32 `json:"max_loss,omitempty"`      // 丢包率超过该值时提醒，0 为不提醒
+	MaxJitter    float32 `json:"max_jitter,omitempty"`    // 抖动超过该值时提醒（毫秒），0 为不提醒
+
+	WebhookURL        string `json:"webhook_url,omitempty"`         // 状态变更时推送检查结果
+	WebhookEveryCheck bool   `json:"webhook_every_check,omitempty"` // 每次检查都推送
+
+	TLSWarnDays     uint16 `json:"tls_warn_days,omitempty"`     // 证书剩余天数不足时提醒
+	TLSCriticalDays uint16 `json:"tls_critical_days,omitempty"` // 证书剩余天数不足时紧急提醒，默认 7 天
+
+	HTTPAssertion *HTTPAssertion          `gorm:"-" json:"http_assertion,omitempty"` // HTTP 响应断言
+	DNSConfig     *DNSMonitorConfig       `gorm:"-" json:"dns_config,omitempty"`     // DNS 监控配置
+	HTTPConfig    *HTTPMonitorConfig      `gorm:"-" json:"http_config,omitempty"`    // HTTP 监控连接选项
+	ProbeSelector *ServiceProbeSelector   `gorm:"-" json:"probe_selector,omitempty"` // 监测点选择
+	Composite     *CompositeServiceConfig `gorm:"-" json:"composite,omitempty"`      // 组合服务配置
+
 	SkipServers map[uint64]bool `gorm:"-" json:"skip_servers"`
 	CronJobID   cron.EntryID    `gorm:"-" json:"-"`
 }
 
-func (m *Service) PB() *pb.Task {
+// PB 返回下发给服务器的任务，任务数据的格式取决于 Agent 上报的能力
+func (m *Service) PB(s *Server) *pb.Task {
 	return &pb.Task{
 		Id:   m.ID,
 		Type: uint64(m.Type),
-		Data: m.Target,
+		Data: m.TaskData(s),
+	}
+}
+
+// 证书剩余天数不足时紧急提醒的默认阈值
+const tlsCriticalDaysDefault = 7
+
+// CanRunOn 判断服务器的 Agent 是否具备执行该监控所需的能力
+func (m *Service) CanRunOn(s *Server) bool {
+	if m.Type != TaskTypeHTTPGet {
+		return true
+	}
+	for _, c := range m.HTTPConfig.AgentCapabilities() {
+		if !s.Reports(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// TLSExpiryThresholds 返回证书过期提醒阈值（天），未设置紧急提醒阈值时使用默认的 7 天，不超过提醒阈值
+func (m *Service) TLSExpiryThresholds() (warn, critical int) {
+	warn, critical = int(m.TLSWarnDays), int(m.TLSCriticalDays)
+	if critical == 0 {
+		critical = tlsCriticalDaysDefault
+		if warn > 0 {
+			critical = min(critical, warn)
+		}
+	}
+	return
+}
+
+// AgentEvaluatesAssertion 服务器的 Agent 会执行 HTTP 断言，旧版 Agent 只做普通请求，断言由面板补充检查
+func (m *Service) AgentEvaluatesAssertion(s *Server) bool {
+	return m.HTTPAssertion.IsEmpty() || s.Reports(AgentCapabilityMonitorOptions) || !m.HTTPConfig.IsEmpty()
+}
+
+// TaskData 返回下发给 Agent 的任务数据，配置了断言、连接选项或超时时间时以 JSON 格式下发。
+// 旧版 Agent 会把 JSON 当作目标地址，未上报相应能力时只下发目标地址
+func (m *Service) TaskData(s *Server) string {
+	options := s.Reports(AgentCapabilityMonitorOptions)
+	var v any
+	switch {
+	case m.Type == TaskTypeHTTPGet && (!m.HTTPConfig.IsEmpty() || (options && (!m.HTTPAssertion.IsEmpty() || m.TimeoutSeconds > 0))):
+		v = HTTPGetTaskData{
+			URL:       m.Target,
+			Timeout:   m.TimeoutSeconds,
+			Assertion: m.HTTPAssertion,
+			HTTP:      m.HTTPConfig,
+		}
+	case m.Type == TaskTypeICMPPing && options && (m.TimeoutSeconds > 0 || m.ICMPCount > 0 || m.ICMPSize > 0 || m.ICMPInterval > 0):
+		v = ProbeTaskData{
+			Target:   m.Target,
+			Timeout:  m.TimeoutSeconds,
+			Count:    m.ICMPPacketCount(),
+			Size:     m.ICMPSize,
+			Interval: m.ICMPInterval,
+		}
+	case m.Type == TaskTypeTCPPing && options && m.TimeoutSeconds > 0:
+		v = ProbeTaskData{
+			Target:  m.Target,
+			Timeout: m.TimeoutSeconds,
+		}
+	case m.Type == TaskTypeDNS && m.DNSConfig != nil:
+		v = DNSTaskData{
+			Name:             m.Target,
+			Timeout:          m.TimeoutSeconds,
+			DNSMonitorConfig: *m.DNSConfig,
+		}
+	default:
+		return m.Target
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return m.Target
+	}
+	return string(data)
+}
+
+func (m *Service) ICMPPacketCount() uint8 {
+	if m.ICMPCount == 0 {
+		return DefaultICMPPacketCount
 	}
+	return m.ICMPCount
+}
+
+// StateByStreak 按连续失败/成功次数判定状态，未启用时返回 false
+func (m *Service) StateByStreak() bool {
+	return m.FailureThreshold > 0
+}
+
+func (m *Service) RecoveryStreak() uint8 {
+	return max(m.RecoveryThreshold, 1)
 }
 
 // CronSpec 返回服务监控请求间隔对应的 cron 表达式
@@ -106,6 +254,41 @@ func (m *Service) BeforeSave(tx *gorm.DB) error {
 	} else {
 		m.RecoverTriggerTasksRaw = string(data)
 	}
+	if m.HTTPAssertion.IsEmpty() {
+		m.HTTPAssertionRaw = "{}"
+	} else if data, err := json.Marshal(m.HTTPAssertion); err != nil {
+		return err
+	} else {
+		m.HTTPAssertionRaw = string(data)
+	}
+	if m.DNSConfig == nil {
+		m.DNSConfigRaw = "{}"
+	} else if data, err := json.Marshal(m.DNSConfig); err != nil {
+		return err
+	} else {
+		m.DNSConfigRaw = string(data)
+	}
+	if m.HTTPConfig.IsEmpty() {
+		m.HTTPConfigRaw = "{}"
+	} else if data, err := json.Marshal(m.HTTPConfig); err != nil {
+		return err
+	} else {
+		m.HTTPConfigRaw = string(data)
+	}
+	if m.ProbeSelector.IsEmpty() {
+		m.ProbeSelectorRaw = "{}"
+	} else if data, err := json.Marshal(m.ProbeSelector); err != nil {
+		return err
+	} else {
+		m.ProbeSelectorRaw = string(data)
+	}
+	if m.Composite == nil {
+		m.CompositeRaw = "{}"
+	} else if data, err := json.Marshal(m.Composite); err != nil {
+		return err
+	} else {
+		m.CompositeRaw = string(data)
+	}
 	return nil
 }
 
@@ -123,6 +306,41 @@ func (m *Service) AfterFind(tx *gorm.DB) error {
 	if err := json.Unmarshal([]byte(m.RecoverTriggerTasksRaw), &m.RecoverTriggerTasks); err != nil {
 		return err
 	}
+	if m.HTTPAssertionRaw != "" && m.HTTPAssertionRaw != "{}" {
+		m.HTTPAssertion = new(HTTPAssertion)
+		if err := json.Unmarshal([]byte(m.HTTPAssertionRaw), m.HTTPAssertion); err != nil {
+			log.Println("NEZHA>> Service.AfterFind:", err)
+			m.HTTPAssertion = nil
+		}
+	}
+	if m.DNSConfigRaw != "" && m.DNSConfigRaw != "{}" {
+		m.DNSConfig = new(DNSMonitorConfig)
+		if err := json.Unmarshal([]byte(m.DNSConfigRaw), m.DNSConfig); err != nil {
+			log.Println("NEZHA>> Service.AfterFind:", err)
+			m.DNSConfig = nil
+		}
+	}
+	if m.HTTPConfigRaw != "" && m.HTTPConfigRaw != "{}" {
+		m.HTTPConfig = new(HTTPMonitorConfig)
+		if err := json.Unmarshal([]byte(m.HTTPConfigRaw), m.HTTPConfig); err != nil {
+			log.Println("NEZHA>> Service.AfterFind:", err)
+			m.HTTPConfig = nil
+		}
+	}
+	if m.ProbeSelectorRaw != "" && m.ProbeSelectorRaw != "{}" {
+		m.ProbeSelector = new(ServiceProbeSelector)
+		if err := json.Unmarshal([]byte(m.ProbeSelectorRaw), m.ProbeSelector); err != nil {
+			log.Println("NEZHA>> Service.AfterFind:", err)
+			m.ProbeSelector = nil
+		}
+	}
+	if m.CompositeRaw != "" && m.CompositeRaw != "{}" {
+		m.Composite = new(CompositeServiceConfig)
+		if err := json.Unmarshal([]byte(m.CompositeRaw), m.Composite); err != nil {
+			log.Println("NEZHA>> Service.AfterFind:", err)
+			m.Composite = nil
+		}
+	}
 
 	return nil
 }
@@ -132,7 +350,9 @@ func IsServiceSentinelNeeded(t uint64) bool {
 	switch t {
 	case TaskTypeCommand, TaskTypeTerminalGRPC, TaskTypeUpgrade,
 		TaskTypeKeepalive, TaskTypeNAT, TaskTypeFM,
-		TaskTypeReportConfig, TaskTypeApplyConfig:
+		TaskTypeReportConfig, TaskTypeApplyConfig, TaskTypeComposite,
+		TaskTypeReportContainers, TaskTypeProcessSnapshot, TaskTypeFilePush,
+		TaskTypeProbe, TaskTypeAgentLogs, TaskTypeReportBackfill, TaskTypeHeartbeat:
 		return false
 	default:
 		return true
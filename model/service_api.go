@@ -3,21 +3,51 @@ package model
 import "time"
 
 type ServiceForm struct {
-	Name                string          `json:"name,omitempty" minLength:"1"`
-	Target              string          `json:"target,omitempty"`
-	Type                uint8           `json:"type,omitempty"`
-	Cover               uint8           `json:"cover,omitempty"`
-	Notify              bool            `json:"notify,omitempty" validate:"optional"`
-	Duration            uint64          `json:"duration,omitempty"`
-	MinLatency          float32         `json:"min_latency,omitempty" default:"0.0"`
-	MaxLatency          float32         `json:"max_latency,omitempty" default:"0.0"`
-	LatencyNotify       bool            `json:"latency_notify,omitempty" validate:"optional"`
-	EnableTriggerTask   bool            `json:"enable_trigger_task,omitempty" validate:"optional"`
-	EnableShowInService bool            `json:"enable_show_in_service,omitempty" validate:"optional"`
-	FailTriggerTasks    []uint64        `json:"fail_trigger_tasks,omitempty"`
-	RecoverTriggerTasks []uint64        `json:"recover_trigger_tasks,omitempty"`
-	SkipServers         map[uint64]bool `json:"skip_servers,omitempty"`
-	NotificationGroupID uint64          `json:"notification_group_id,omitempty"`
+	Name                string                  `json:"name,omitempty" minLength:"1"`
+	Target              string                  `json:"target,omitempty"`
+	Type                uint8                   `json:"type,omitempty"`
+	Cover               uint8                   `json:"cover,omitempty"`
+	Notify              bool                    `json:"notify,omitempty" validate:"optional"`
+	IntervalSeconds     uint64                  `json:"interval_seconds,omitempty" validate:"optional"` // 检查间隔（秒）
+	Duration            uint64                  `json:"duration,omitempty" validate:"optional"`         // 检查间隔（秒），旧版本的字段名，未设置 interval_seconds 时使用
+	TimeoutSeconds      uint32                  `json:"timeout_seconds,omitempty" validate:"optional"`
+	FailureThreshold    uint8                   `json:"failure_threshold,omitempty" validate:"optional"`
+	RecoveryThreshold   uint8                   `json:"recovery_threshold,omitempty" validate:"optional"`
+	QuorumCount         uint8                   `json:"quorum_count,omitempty" validate:"optional"`
+	QuorumPercent       uint8                   `json:"quorum_percent,omitempty" validate:"optional"`
+	QuorumWindow        uint32                  `json:"quorum_window,omitempty" validate:"optional"`
+	MinLatency          float32                 `json:"min_latency,omitempty" default:"0.0"`
+	MaxLatency          float32                 `json:"max_latency,omitempty" default:"0.0"`
+	LatencyNotify       bool                    `json:"latency_notify,omitempty" validate:"optional"`
+	EnableTriggerTask   bool                    `json:"enable_trigger_task,omitempty" validate:"optional"`
+	EnableShowInService bool                    `json:"enable_show_in_service,omitempty" validate:"optional"`
+	RecordResults       bool                    `json:"record_results,omitempty" validate:"optional"`
+	FailTriggerTasks    []uint64                `json:"fail_trigger_tasks,omitempty"`
+	RecoverTriggerTasks []uint64                `json:"recover_trigger_tasks,omitempty"`
+	SkipServers         map[uint64]bool         `json:"skip_servers,omitempty"`
+	NotificationGroupID uint64                  `json:"notification_group_id,omitempty"`
+	HTTPAssertion       *HTTPAssertion          `json:"http_assertion,omitempty" validate:"optional"`
+	DNSConfig           *DNSMonitorConfig       `json:"dns_config,omitempty" validate:"optional"`
+	HTTPConfig          *HTTPMonitorConfig      `json:"http_config,omitempty" validate:"optional"`
+	ProbeSelector       *ServiceProbeSelector   `json:"probe_selector,omitempty" validate:"optional"`
+	Composite           *CompositeServiceConfig `json:"composite,omitempty" validate:"optional"`
+	ICMPCount           uint8                   `json:"icmp_count,omitempty" validate:"optional"`
+	ICMPSize            uint16                  `json:"icmp_size,omitempty" validate:"optional"`
+	ICMPInterval        uint16                  `json:"icmp_interval,omitempty" validate:"optional"`
+	MaxLoss             float32                 `json:"max_loss,omitempty" validate:"optional"`
+	MaxJitter           float32                 `json:"max_jitter,omitempty" validate:"optional"`
+	WebhookURL          string                  `json:"webhook_url,omitempty" validate:"optional"`
+	WebhookEveryCheck   bool                    `json:"webhook_every_check,omitempty" validate:"optional"`
+	TLSWarnDays         uint16                  `json:"tls_warn_days,omitempty" validate:"optional"`
+	TLSCriticalDays     uint16                  `json:"tls_critical_days,omitempty" validate:"optional"`
+}
+
+// Interval 返回检查间隔，兼容旧版本的 duration 字段
+func (f *ServiceForm) Interval() uint64 {
+	if f.IntervalSeconds > 0 {
+		return f.IntervalSeconds
+	}
+	return f.Duration
 }
 
 type ServiceResponseItem struct {
@@ -29,6 +59,7 @@ type ServiceResponseItem struct {
 	Delay       *[30]float32 `json:"delay,omitempty"`
 	Up          *[30]uint64  `json:"up,omitempty"`
 	Down        *[30]uint64  `json:"down,omitempty"`
+	TLSCert     *ServiceCert `json:"tls_cert,omitempty"`
 }
 
 func (r ServiceResponseItem) TotalUptime() float32 {
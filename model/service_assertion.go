@@ -0,0 +1,150 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const DefaultHTTPAssertionMaxBodySize = 1 << 20
+
+// HTTPAssertion HTTP 监控的响应断言，由 Agent 在执行任务时进行判断
+type HTTPAssertion struct {
+	StatusCodes     string `json:"status_codes,omitempty"`      // 期望的状态码，如 "200"、"200-299"、"200,301-302"
+	BodyKeyword     string `json:"body_keyword,omitempty"`      // 响应体关键字
+	BodyRegex       bool   `json:"body_regex,omitempty"`        // 关键字是否为正则表达式
+	BodyNotContains bool   `json:"body_not_contains,omitempty"` // 为真时响应体不能包含关键字
+	HeaderName      string `json:"header_name,omitempty"`
+	HeaderValue     string `json:"header_value,omitempty"` // 为空时仅要求响应头存在
+	MaxBodySize     int64  `json:"max_body_size,omitempty"`
+	FollowRedirects *bool  `json:"follow_redirects,omitempty"` // 默认跟随重定向
+}
+
+// HTTPGetTaskData 带断言或连接选项的 HTTP 监控任务数据
+type HTTPGetTaskData struct {
+	URL       string             `json:"url"`
+	Timeout   uint32             `json:"timeout,omitempty"` // 秒
+	Assertion *HTTPAssertion     `json:"assertion,omitempty"`
+	HTTP      *HTTPMonitorConfig `json:"http,omitempty"`
+}
+
+func (a *HTTPAssertion) IsEmpty() bool {
+	return a == nil || (a.StatusCodes == "" && a.BodyKeyword == "" && a.HeaderName == "" &&
+		a.MaxBodySize == 0 && a.FollowRedirects == nil)
+}
+
+func (a *HTTPAssertion) ShouldFollowRedirects() bool {
+	return a == nil || a.FollowRedirects == nil || *a.FollowRedirects
+}
+
+func (a *HTTPAssertion) BodyLimit() int64 {
+	if a == nil || a.MaxBodySize <= 0 {
+		return DefaultHTTPAssertionMaxBodySize
+	}
+	return a.MaxBodySize
+}
+
+func (a *HTTPAssertion) Validate() error {
+	if a == nil {
+		return nil
+	}
+	if _, err := parseStatusCodeRanges(a.StatusCodes); err != nil {
+		return err
+	}
+	if a.BodyRegex && a.BodyKeyword != "" {
+		if _, err := regexp.Compile(a.BodyKeyword); err != nil {
+			return err
+		}
+	}
+	if a.MaxBodySize < 0 {
+		return errors.New("max_body_size must not be negative")
+	}
+	return nil
+}
+
+// Evaluate 对 HTTP 响应进行断言，失败时返回原因
+func (a *HTTPAssertion) Evaluate(statusCode int, header http.Header, body []byte) error {
+	if a == nil {
+		return nil
+	}
+
+	ranges, err := parseStatusCodeRanges(a.StatusCodes)
+	if err != nil {
+		return err
+	}
+	if len(ranges) > 0 {
+		var matched bool
+		for _, r := range ranges {
+			if statusCode >= r[0] && statusCode <= r[1] {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("unexpected status code %d, expected %s", statusCode, a.StatusCodes)
+		}
+	}
+
+	if a.HeaderName != "" {
+		values := header.Values(a.HeaderName)
+		if len(values) == 0 {
+			return fmt.Errorf("response header %s is missing", a.HeaderName)
+		}
+		if a.HeaderValue != "" && !strings.Contains(strings.Join(values, ", "), a.HeaderValue) {
+			return fmt.Errorf("response header %s does not contain %q", a.HeaderName, a.HeaderValue)
+		}
+	}
+
+	if a.BodyKeyword != "" {
+		if int64(len(body)) > a.BodyLimit() {
+			body = body[:a.BodyLimit()]
+		}
+		var found bool
+		if a.BodyRegex {
+			re, err := regexp.Compile(a.BodyKeyword)
+			if err != nil {
+				return err
+			}
+			found = re.Match(body)
+		} else {
+			found = strings.Contains(string(body), a.BodyKeyword)
+		}
+		if found && a.BodyNotContains {
+			return fmt.Errorf("response body contains forbidden keyword %q", a.BodyKeyword)
+		}
+		if !found && !a.BodyNotContains {
+			return fmt.Errorf("response body does not contain keyword %q", a.BodyKeyword)
+		}
+	}
+
+	return nil
+}
+
+func parseStatusCodeRanges(s string) ([][2]int, error) {
+	var ranges [][2]int
+	for part := range strings.SplitSeq(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		lo, hi, isRange := strings.Cut(part, "-")
+		from, err := strconv.Atoi(strings.TrimSpace(lo))
+		if err != nil {
+			return nil, fmt.Errorf("invalid status code %q", part)
+		}
+		to := from
+		if isRange {
+			if to, err = strconv.Atoi(strings.TrimSpace(hi)); err != nil {
+				return nil, fmt.Errorf("invalid status code %q", part)
+			}
+		}
+		if from < 100 || to > 599 || from > to {
+			return nil, fmt.Errorf("invalid status code %q", part)
+		}
+		ranges = append(ranges, [2]int{from, to})
+	}
+	return ranges, nil
+}
@@ -0,0 +1,59 @@
+package model
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestHTTPAssertion(t *testing.T) {
+	header := http.Header{}
+	header.Set("Content-Type", "text/html; charset=utf-8")
+
+	cases := []struct {
+		name      string
+		assertion *HTTPAssertion
+		status    int
+		body      string
+		pass      bool
+	}{
+		{"Nil", nil, 500, "", true},
+		{"StatusExact", &HTTPAssertion{StatusCodes: "200"}, 200, "", true},
+		{"StatusMismatch", &HTTPAssertion{StatusCodes: "200"}, 500, "", false},
+		{"StatusRange", &HTTPAssertion{StatusCodes: "200-299,301"}, 301, "", true},
+		{"Keyword", &HTTPAssertion{BodyKeyword: "ok"}, 200, "status: ok", true},
+		{"KeywordMissing", &HTTPAssertion{BodyKeyword: "ok"}, 200, "error page", false},
+		{"KeywordForbidden", &HTTPAssertion{BodyKeyword: "error", BodyNotContains: true}, 200, "error page", false},
+		{"Regex", &HTTPAssertion{BodyKeyword: `^v\d+`, BodyRegex: true}, 200, "v12", true},
+		{"KeywordBeyondLimit", &HTTPAssertion{BodyKeyword: "ok", MaxBodySize: 4}, 200, "12345ok", false},
+		{"Header", &HTTPAssertion{HeaderName: "content-type", HeaderValue: "text/html"}, 200, "", true},
+		{"HeaderMissing", &HTTPAssertion{HeaderName: "X-Cache"}, 200, "", false},
+	}
+
+	for _, c := range cases {
+		err := c.assertion.Evaluate(c.status, header, []byte(c.body))
+		if (err == nil) != c.pass {
+			t.Fatalf("%s: expected pass=%v, but got %v", c.name, c.pass, err)
+		}
+	}
+
+	for _, valid := range []string{"", "200", "200-299,301", " 200 , 302 "} {
+		if err := (&HTTPAssertion{StatusCodes: valid}).Validate(); err != nil {
+			t.Fatalf("expected %q to be valid: %v", valid, err)
+		}
+	}
+	for _, invalid := range []string{"abc", "99", "300-200", "200-"} {
+		if err := (&HTTPAssertion{StatusCodes: invalid}).Validate(); err == nil {
+			t.Fatalf("expected %q to be invalid", invalid)
+		}
+	}
+
+	if (&HTTPAssertion{BodyKeyword: "(", BodyRegex: true}).Validate() == nil {
+		t.Fatal("expected invalid regex to be rejected")
+	}
+	if (&HTTPAssertion{MaxBodySize: -1}).Validate() == nil {
+		t.Fatal("expected negative body size to be rejected")
+	}
+	if l := (&HTTPAssertion{}).BodyLimit(); l != DefaultHTTPAssertionMaxBodySize {
+		t.Fatalf("unexpected default body limit: %d", l)
+	}
+}
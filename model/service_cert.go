@@ -0,0 +1,89 @@
+package model
+
+import (
+	"log"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-json"
+	"gorm.io/gorm"
+)
+
+const legacyCertTimeLayout = "2006-01-02 15:04:05 -0700 MST"
+
+// ServiceCert 服务监控最近一次获取到的 TLS 证书信息
+type ServiceCert struct {
+	ServiceID  uint64    `gorm:"primaryKey" json:"service_id"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	Issuer     string    `json:"issuer"`
+	NotAfter   time.Time `json:"not_after"`
+	ChainValid bool      `json:"chain_valid"` // 证书链完整
+	Trusted    bool      `json:"trusted"`     // 证书链可被系统根证书信任，自签证书为 false
+	SANsRaw    string    `gorm:"default:'[]'" json:"-"`
+
+	SANs          []string `gorm:"-" json:"sans,omitempty"`
+	DaysRemaining int      `gorm:"-" json:"days_remaining"`
+}
+
+func (c *ServiceCert) BeforeSave(tx *gorm.DB) error {
+	data, err := json.Marshal(c.SANs)
+	if err != nil {
+		return err
+	}
+	c.SANsRaw = string(data)
+	return nil
+}
+
+func (c *ServiceCert) AfterFind(tx *gorm.DB) error {
+	if c.SANsRaw != "" {
+		if err := json.Unmarshal([]byte(c.SANsRaw), &c.SANs); err != nil {
+			log.Println("NEZHA>> ServiceCert.AfterFind:", err)
+		}
+	}
+	c.RefreshDaysRemaining()
+	return nil
+}
+
+func (c *ServiceCert) RefreshDaysRemaining() {
+	c.DaysRemaining = int(math.Floor(time.Until(c.NotAfter).Hours() / 24))
+}
+
+// IsChanged 签发者与过期时间均变化时视为证书更换
+func (c *ServiceCert) IsChanged(other *ServiceCert) bool {
+	return c.Issuer != other.Issuer && !c.NotAfter.Equal(other.NotAfter)
+}
+
+func (c *ServiceCert) Equal(other *ServiceCert) bool {
+	return c.Issuer == other.Issuer && c.NotAfter.Equal(other.NotAfter) &&
+		c.ChainValid == other.ChainValid && c.Trusted == other.Trusted &&
+		slices.Equal(c.SANs, other.SANs)
+}
+
+// ParseServiceCert 解析 Agent 上报的证书信息
+// 支持 JSON 格式，以及旧版 Agent 的 "签发者|过期时间" 格式
+func ParseServiceCert(serviceID uint64, data string) (*ServiceCert, bool) {
+	cert := &ServiceCert{ServiceID: serviceID}
+	if strings.HasPrefix(data, "{") {
+		if err := json.Unmarshal([]byte(data), cert); err != nil || cert.NotAfter.IsZero() {
+			return nil, false
+		}
+	} else {
+		issuer, expires, ok := strings.Cut(data, "|")
+		if !ok {
+			return nil, false
+		}
+		notAfter, err := time.Parse(legacyCertTimeLayout, expires)
+		if err != nil {
+			return nil, false
+		}
+		cert.Issuer = issuer
+		cert.NotAfter = notAfter
+		cert.ChainValid = true
+		cert.Trusted = true
+	}
+	cert.ServiceID = serviceID
+	cert.RefreshDaysRemaining()
+	return cert, true
+}
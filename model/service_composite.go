@@ -0,0 +1,83 @@
+package model
+
+import (
+	"errors"
+	"slices"
+)
+
+const (
+	CompositePolicyAll      = "all"      // 全部子服务正常
+	CompositePolicyQuorum   = "quorum"   // 至少 Quorum 个子服务正常
+	CompositePolicyWeighted = "weighted" // 正常子服务的权重占比不低于 Threshold
+)
+
+// CompositeServiceConfig 组合服务配置，状态由子服务的状态汇总得出
+type CompositeServiceConfig struct {
+	Children  []uint64           `json:"children"`
+	Policy    string             `json:"policy"`
+	Quorum    uint8              `json:"quorum,omitempty"`
+	Weights   map[uint64]float32 `json:"weights,omitempty"`   // 未配置权重的子服务权重为 1
+	Threshold float32            `json:"threshold,omitempty"` // 百分比，默认 50
+}
+
+// CompositeChildStatus 子服务当前状态
+type CompositeChildStatus struct {
+	ServiceID   uint64  `json:"service_id"`
+	ServiceName string  `json:"service_name"`
+	Status      string  `json:"status"`
+	Weight      float32 `json:"weight,omitempty"`
+}
+
+// CompositeServiceStatus 组合服务及其子服务的当前状态
+type CompositeServiceStatus struct {
+	ServiceID uint64                 `json:"service_id"`
+	Policy    string                 `json:"policy"`
+	Status    string                 `json:"status"`
+	Summary   string                 `json:"summary,omitempty"`
+	Children  []CompositeChildStatus `json:"children"`
+}
+
+func (c *CompositeServiceConfig) Validate(serviceID uint64) error {
+	if c == nil || len(c.Children) == 0 {
+		return errors.New("composite service requires at least one child service")
+	}
+	if slices.Contains(c.Children, serviceID) {
+		return errors.New("composite service cannot reference itself")
+	}
+	children := slices.Clone(c.Children)
+	slices.Sort(children)
+	if len(slices.Compact(children)) != len(c.Children) {
+		return errors.New("duplicate child service")
+	}
+
+	switch c.Policy {
+	case "", CompositePolicyAll:
+		c.Policy = CompositePolicyAll
+	case CompositePolicyQuorum:
+		if c.Quorum == 0 || int(c.Quorum) > len(c.Children) {
+			return errors.New("quorum must be between 1 and the number of child services")
+		}
+	case CompositePolicyWeighted:
+		for id, w := range c.Weights {
+			if w < 0 || !slices.Contains(c.Children, id) {
+				return errors.New("invalid child service weight")
+			}
+		}
+		if c.Threshold < 0 || c.Threshold > 100 {
+			return errors.New("threshold must be between 0 and 100")
+		}
+		if c.Threshold == 0 {
+			c.Threshold = 50
+		}
+	default:
+		return errors.New("unsupported composite policy " + c.Policy)
+	}
+	return nil
+}
+
+func (c *CompositeServiceConfig) Weight(id uint64) float32 {
+	if w, ok := c.Weights[id]; ok {
+		return w
+	}
+	return 1
+}
@@ -0,0 +1,93 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	DNSMatchExact    = "exact"    // 解析结果集合与期望值完全一致
+	DNSMatchContains = "contains" // 解析结果包含全部期望值
+)
+
+const (
+	DNSFailureNXDomain = "nxdomain"
+	DNSFailureTimeout  = "timeout"
+	DNSFailureMismatch = "mismatch"
+	DNSFailureError    = "error"
+)
+
+var DNSRecordTypes = []string{"A", "AAAA", "CNAME", "MX", "TXT"}
+
+// DNSMonitorConfig DNS 监控配置，查询域名为 Service.Target
+type DNSMonitorConfig struct {
+	RecordType string   `json:"record_type"`
+	Resolver   string   `json:"resolver,omitempty"` // 为空时使用 Agent 系统解析器，格式 host:port
+	Expected   []string `json:"expected,omitempty"`
+	MatchMode  string   `json:"match_mode,omitempty"`
+}
+
+// DNSTaskData 下发给 Agent 的 DNS 监控任务数据
+type DNSTaskData struct {
+	Name    string `json:"name"`
+	Timeout uint32 `json:"timeout,omitempty"` // 秒
+	DNSMonitorConfig
+}
+
+// DNSTaskResult Agent 上报的 DNS 查询结果
+type DNSTaskResult struct {
+	Answers []string `json:"answers"`
+	Reason  string   `json:"reason,omitempty"` // 失败原因：nxdomain、timeout、mismatch、error
+	Error   string   `json:"error,omitempty"`
+}
+
+// DNSProbeResult 各监测点最近一次 DNS 查询结果
+type DNSProbeResult struct {
+	ServerID   uint64    `json:"server_id"`
+	Successful bool      `json:"successful"`
+	Delay      float32   `json:"delay"`
+	Answers    []string  `json:"answers"`
+	Reason     string    `json:"reason,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+func (c *DNSMonitorConfig) Validate() error {
+	if c == nil {
+		return errors.New("dns config is required")
+	}
+	c.RecordType = strings.ToUpper(c.RecordType)
+	if !slices.Contains(DNSRecordTypes, c.RecordType) {
+		return fmt.Errorf("unsupported record type %q", c.RecordType)
+	}
+	if c.MatchMode == "" {
+		c.MatchMode = DNSMatchExact
+	}
+	if c.MatchMode != DNSMatchExact && c.MatchMode != DNSMatchContains {
+		return fmt.Errorf("unsupported match mode %q", c.MatchMode)
+	}
+	if c.Resolver != "" {
+		if _, _, err := net.SplitHostPort(c.Resolver); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *DNSTaskResult) String() string {
+	switch r.Reason {
+	case "":
+		return strings.Join(r.Answers, ", ")
+	case DNSFailureMismatch:
+		return fmt.Sprintf("%s: got [%s]", r.Reason, strings.Join(r.Answers, ", "))
+	default:
+		if r.Error != "" {
+			return fmt.Sprintf("%s: %s", r.Reason, r.Error)
+		}
+		return r.Reason
+	}
+}
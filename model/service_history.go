@@ -13,5 +13,38 @@ type ServiceHistory struct {
 	AvgDelay  float32   `gorm:"index:idx_server_id_created_at_service_id_avg_delay" json:"avg_delay,omitempty"` // 平均延迟，毫秒
 	Up        uint64    `json:"up,omitempty"`                                                                   // 检查状态良好计数
 	Down      uint64    `json:"down,omitempty"`                                                                 // 检查状态异常计数
+	Loss      float32   `json:"loss,omitempty"`                                                                 // ICMP 平均丢包率，百分比
+	Jitter    float32   `json:"jitter,omitempty"`                                                               // ICMP 平均抖动，毫秒
 	Data      string    `json:"data,omitempty"`
 }
+
+const (
+	HistoryIntervalHourly = "hourly"
+	HistoryIntervalDaily  = "daily"
+)
+
+// ServiceHistoryRollup 超出保留期的监控记录按小时或按天汇总后的数据
+type ServiceHistoryRollup struct {
+	ID        uint64    `gorm:"primaryKey" json:"-"`
+	ServiceID uint64    `gorm:"uniqueIndex:idx_service_history_rollup" json:"service_id"`
+	ServerID  uint64    `gorm:"uniqueIndex:idx_service_history_rollup" json:"server_id"`
+	Interval  string    `gorm:"uniqueIndex:idx_service_history_rollup" json:"interval"`
+	Start     time.Time `gorm:"uniqueIndex:idx_service_history_rollup" json:"start"`
+	AvgDelay  float32   `json:"avg_delay"`
+	Up        uint64    `json:"up"`
+	Down      uint64    `json:"down"`
+	Loss      float32   `json:"loss"`
+	Jitter    float32   `json:"jitter"`
+	Samples   uint64    `json:"samples"` // 汇总的原始记录数，用于计算加权平均值
+}
+
+type RetentionTier struct {
+	Name   string    `json:"name"`   // raw, hourly, daily
+	Before time.Time `json:"before"` // 早于该时间的记录会被汇总或删除
+	Rows   int64     `json:"rows"`
+}
+
+type RetentionReport struct {
+	DryRun bool             `json:"dry_run"`
+	Tiers  []*RetentionTier `json:"tiers"`
+}
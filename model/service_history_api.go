@@ -7,4 +7,6 @@ type ServiceInfos struct {
 	ServerName  string    `json:"server_name"`
 	CreatedAt   []int64   `json:"created_at"`
 	AvgDelay    []float32 `json:"avg_delay"`
+	Loss        []float32 `json:"loss,omitempty"`
+	Jitter      []float32 `json:"jitter,omitempty"`
 }
@@ -0,0 +1,181 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"strings"
+
+	"github.com/goccy/go-json"
+)
+
+// HTTP 监控的协议偏好，为空时由 Agent 自动协商
+const (
+	HTTPProtocolAuto = ""
+	HTTPProtocol11   = "http1.1"
+	HTTPProtocolH2   = "h2"
+	HTTPProtocolH3   = "h3" // 基于 QUIC，需要 Agent 支持
+)
+
+const (
+	defaultHTTPResolverPort = 53
+	maxInterfaceNameLen     = 15 // Linux IFNAMSIZ - 1
+)
+
+// HTTPMonitorConfig HTTP 监控的连接选项，仅作用于当前监控
+type HTTPMonitorConfig struct {
+	Resolver        string `json:"resolver,omitempty"`         // 解析目标域名使用的 DNS 服务器，如 "1.1.1.1" 或 "[2606:4700::1111]:53"
+	ConnectIP       string `json:"connect_ip,omitempty"`       // 直接连接该 IP，Host 头与 SNI 仍使用 URL 中的域名
+	Protocol        string `json:"protocol,omitempty"`         // 协议偏好
+	SourceAddr      string `json:"source_addr,omitempty"`      // 发起连接使用的本地地址
+	SourceInterface string `json:"source_interface,omitempty"` // 发起连接使用的网卡
+}
+
+// HTTPPath Agent 实际测试的连接路径
+type HTTPPath struct {
+	Protocol   string `json:"protocol,omitempty"`    // 协商得到的协议，如 "HTTP/2.0"
+	RemoteAddr string `json:"remote_addr,omitempty"` // 连接的目标地址
+	LocalAddr  string `json:"local_addr,omitempty"`  // 使用的本地地址
+	Resolver   string `json:"resolver,omitempty"`    // 解析域名使用的 DNS 服务器
+}
+
+// HTTPTaskResult 配置了连接选项的 HTTP 监控上报结果，
+// 成功时与证书信息合并在同一个 JSON 对象中上报
+type HTTPTaskResult struct {
+	Error string    `json:"error,omitempty"`
+	Path  *HTTPPath `json:"path,omitempty"`
+}
+
+func (c *HTTPMonitorConfig) IsEmpty() bool {
+	return c == nil || (c.Resolver == "" && c.ConnectIP == "" && c.Protocol == "" &&
+		c.SourceAddr == "" && c.SourceInterface == "")
+}
+
+// Validate 校验连接选项，并将 DNS 服务器规范为 IP:端口 的形式
+func (c *HTTPMonitorConfig) Validate(target string) error {
+	if c.IsEmpty() {
+		return nil
+	}
+
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return errors.New("invalid http target")
+	}
+
+	switch c.Protocol {
+	case HTTPProtocolAuto, HTTPProtocol11:
+	case HTTPProtocolH2, HTTPProtocolH3:
+		if u.Scheme != "https" {
+			return fmt.Errorf("protocol %s requires an https target", c.Protocol)
+		}
+	default:
+		return fmt.Errorf("unsupported protocol %q", c.Protocol)
+	}
+
+	if c.Resolver != "" && c.ConnectIP != "" {
+		return errors.New("resolver and connect_ip cannot be used together")
+	}
+	if c.Resolver != "" {
+		resolver, err := parseResolverAddr(c.Resolver)
+		if err != nil {
+			return err
+		}
+		c.Resolver = resolver.String()
+	}
+
+	var connectIP, sourceAddr netip.Addr
+	if c.ConnectIP != "" {
+		if connectIP, err = netip.ParseAddr(c.ConnectIP); err != nil || connectIP.Zone() != "" {
+			return fmt.Errorf("invalid connect_ip %q", c.ConnectIP)
+		}
+		c.ConnectIP = connectIP.String()
+	}
+	if c.SourceAddr != "" {
+		if sourceAddr, err = netip.ParseAddr(c.SourceAddr); err != nil || sourceAddr.Zone() != "" {
+			return fmt.Errorf("invalid source_addr %q", c.SourceAddr)
+		}
+		c.SourceAddr = sourceAddr.String()
+	}
+	if connectIP.IsValid() && sourceAddr.IsValid() && connectIP.Is4() != sourceAddr.Is4() {
+		return errors.New("connect_ip and source_addr must be of the same address family")
+	}
+	if ip, err := netip.ParseAddr(u.Hostname()); err == nil && sourceAddr.IsValid() && ip.Is4() != sourceAddr.Is4() {
+		return errors.New("target and source_addr must be of the same address family")
+	}
+
+	if name := c.SourceInterface; name != "" {
+		if len(name) > maxInterfaceNameLen || strings.ContainsAny(name, "/: \t") {
+			return fmt.Errorf("invalid source_interface %q", name)
+		}
+	}
+	return nil
+}
+
+// AgentCapabilities 返回执行监控所需的 Agent 能力。旧版 Agent 会忽略连接选项，
+// 测试的并不是配置的路径，因此只下发给明确上报了这些能力的 Agent
+func (c *HTTPMonitorConfig) AgentCapabilities() []string {
+	if c.IsEmpty() {
+		return nil
+	}
+	caps := []string{AgentCapabilityHTTPOptions}
+	if c.Protocol == HTTPProtocolH3 {
+		caps = append(caps, AgentCapabilityHTTP3)
+	}
+	return caps
+}
+
+func parseResolverAddr(s string) (netip.AddrPort, error) {
+	if ip, err := netip.ParseAddr(s); err == nil && ip.Zone() == "" {
+		return netip.AddrPortFrom(ip, defaultHTTPResolverPort), nil
+	}
+	addr, err := netip.ParseAddrPort(s)
+	if err != nil || addr.Port() == 0 || addr.Addr().Zone() != "" {
+		return netip.AddrPort{}, fmt.Errorf("invalid resolver %q", s)
+	}
+	return addr, nil
+}
+
+func (p *HTTPPath) String() string {
+	if p == nil {
+		return ""
+	}
+	var parts []string
+	if p.Protocol != "" {
+		parts = append(parts, p.Protocol)
+	}
+	if p.RemoteAddr != "" {
+		parts = append(parts, "to "+p.RemoteAddr)
+	}
+	if p.LocalAddr != "" {
+		parts = append(parts, "from "+p.LocalAddr)
+	}
+	if p.Resolver != "" {
+		parts = append(parts, "resolved by "+p.Resolver)
+	}
+	return strings.Join(parts, " ")
+}
+
+// ParseHTTPTaskResult 解析带有连接路径的 HTTP 监控结果，旧版 Agent 或未配置连接选项时返回 false
+func ParseHTTPTaskResult(data string) (*HTTPTaskResult, bool) {
+	if !strings.HasPrefix(data, "{") {
+		return nil, false
+	}
+	var res HTTPTaskResult
+	if err := json.Unmarshal([]byte(data), &res); err != nil || res.Path == nil {
+		return nil, false
+	}
+	return &res, true
+}
+
+// String 返回附带连接路径的错误信息
+func (r *HTTPTaskResult) String() string {
+	path := r.Path.String()
+	switch {
+	case path == "":
+		return r.Error
+	case r.Error == "":
+		return path
+	}
+	return fmt.Sprintf("%s [%s]", r.Error, path)
+}
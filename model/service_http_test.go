@@ -0,0 +1,135 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/goccy/go-json"
+)
+
+func TestHTTPMonitorConfigValidate(t *testing.T) {
+	c := &HTTPMonitorConfig{Resolver: "2606:4700::1111", Protocol: HTTPProtocolH3, SourceInterface: "eth0"}
+	if err := c.Validate("https://example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if c.Resolver != "[2606:4700::1111]:53" {
+		t.Errorf("expected resolver to be normalized, got %s", c.Resolver)
+	}
+
+	c = &HTTPMonitorConfig{ConnectIP: "203.0.113.10", SourceAddr: "198.51.100.2"}
+	if err := c.Validate("http://example.com/health"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, invalid := range []struct {
+		target string
+		config HTTPMonitorConfig
+	}{
+		{"https://example.com", HTTPMonitorConfig{Protocol: "spdy"}},
+		{"http://example.com", HTTPMonitorConfig{Protocol: HTTPProtocolH3}},
+		{"ftp://example.com", HTTPMonitorConfig{Protocol: HTTPProtocol11}},
+		{"https://example.com", HTTPMonitorConfig{Resolver: "1.1.1.1", ConnectIP: "203.0.113.10"}},
+		{"https://example.com", HTTPMonitorConfig{Resolver: "dns.google"}},
+		{"https://example.com", HTTPMonitorConfig{Resolver: "1.1.1.1:0"}},
+		{"https://example.com", HTTPMonitorConfig{ConnectIP: "example.org"}},
+		{"https://example.com", HTTPMonitorConfig{ConnectIP: "2001:db8::1", SourceAddr: "198.51.100.2"}},
+		{"https://203.0.113.10", HTTPMonitorConfig{SourceAddr: "2001:db8::2"}},
+		{"https://example.com", HTTPMonitorConfig{SourceInterface: "a-very-long-interface"}},
+		{"https://example.com", HTTPMonitorConfig{SourceInterface: "eth0/1"}},
+	} {
+		if err := invalid.config.Validate(invalid.target); err == nil {
+			t.Errorf("expected error for %s %+v", invalid.target, invalid.config)
+		}
+	}
+}
+
+func TestParseHTTPTaskResult(t *testing.T) {
+	if _, ok := ParseHTTPTaskResult("connection refused"); ok {
+		t.Error("expected plain error to be ignored")
+	}
+	// 旧版 Agent 上报的证书信息不带连接路径
+	if _, ok := ParseHTTPTaskResult(`{"issuer":"R3","not_after":"2026-01-01T00:00:00Z"}`); ok {
+		t.Error("expected result without path to be ignored")
+	}
+
+	res, ok := ParseHTTPTaskResult(`{"error":"unexpected status 502","path":{"protocol":"HTTP/3.0","remote_addr":"203.0.113.10:443","local_addr":"198.51.100.2:51234"}}`)
+	if !ok {
+		t.Fatal("expected result to be parsed")
+	}
+	if want := "unexpected status 502 [HTTP/3.0 to 203.0.113.10:443 from 198.51.100.2:51234]"; res.String() != want {
+		t.Errorf("String() = %q, want %q", res.String(), want)
+	}
+}
+
+func TestServiceCanRunOn(t *testing.T) {
+	s := &Server{Capabilities: ParseAgentCapabilities("exec,http_options")}
+	m := &Service{Type: TaskTypeHTTPGet}
+	if !m.CanRunOn(&Server{}) {
+		t.Fatal("expected service without options to run on any agent")
+	}
+
+	m.HTTPConfig = &HTTPMonitorConfig{ConnectIP: "203.0.113.10"}
+	if m.CanRunOn(&Server{}) {
+		t.Fatal("expected connection options to require an explicitly reported capability")
+	}
+	if !m.CanRunOn(s) {
+		t.Fatal("expected agent reporting http_options to run the service")
+	}
+
+	m.HTTPConfig.Protocol = HTTPProtocolH3
+	if m.CanRunOn(s) {
+		t.Fatal("expected HTTP/3 to require the http3 capability")
+	}
+	s.Capabilities.HTTP3 = true
+	if !m.CanRunOn(s) {
+		t.Fatal("expected agent reporting http3 to run the service")
+	}
+}
+
+func TestServiceTaskData(t *testing.T) {
+	legacy := &Server{}
+	s := &Server{Capabilities: ParseAgentCapabilities("monitor_options")}
+	m := &Service{Type: TaskTypeHTTPGet, Target: "https://example.com", HTTPAssertion: &HTTPAssertion{StatusCodes: "200"}}
+
+	if data := m.TaskData(legacy); data != m.Target {
+		t.Fatalf("expected legacy agent to get the plain target, got %s", data)
+	}
+	if m.AgentEvaluatesAssertion(legacy) {
+		t.Fatal("expected the assertion to be evaluated by the dashboard for legacy agents")
+	}
+	var task HTTPGetTaskData
+	if err := json.Unmarshal([]byte(m.TaskData(s)), &task); err != nil || task.URL != m.Target || task.Assertion == nil {
+		t.Fatalf("expected JSON task data, got %s", m.TaskData(s))
+	}
+	if !m.AgentEvaluatesAssertion(s) {
+		t.Fatal("expected agent reporting monitor_options to evaluate the assertion")
+	}
+
+	for _, m := range []*Service{
+		{Type: TaskTypeHTTPGet, Target: "https://example.com", TimeoutSeconds: 5},
+		{Type: TaskTypeTCPPing, Target: "example.com:443", TimeoutSeconds: 5},
+	} {
+		if data := m.TaskData(legacy); data != m.Target {
+			t.Errorf("expected legacy agent to get the plain target, got %s", data)
+		}
+		if data := m.TaskData(s); data == m.Target {
+			t.Errorf("expected timeout to be sent as JSON to agent reporting monitor_options")
+		}
+	}
+}
+
+func TestTLSExpiryThresholds(t *testing.T) {
+	for _, c := range []struct {
+		warn, critical         uint16
+		wantWarn, wantCritical int
+	}{
+		{0, 0, 0, 7},
+		{21, 0, 21, 7},
+		{3, 0, 3, 3},
+		{21, 10, 21, 10},
+	} {
+		s := &Service{TLSWarnDays: c.warn, TLSCriticalDays: c.critical}
+		if warn, critical := s.TLSExpiryThresholds(); warn != c.wantWarn || critical != c.wantCritical {
+			t.Errorf("thresholds for %d/%d: got %d/%d, want %d/%d", c.warn, c.critical, warn, critical, c.wantWarn, c.wantCritical)
+		}
+	}
+}
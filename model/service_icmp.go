@@ -0,0 +1,74 @@
+package model
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/goccy/go-json"
+)
+
+const DefaultICMPPacketCount = 3
+
+// ICMPTaskResult Agent 上报的 ICMP 监控统计结果，延迟单位为毫秒
+type ICMPTaskResult struct {
+	Sent     int     `json:"sent"`
+	Received int     `json:"received"`
+	Min      float32 `json:"min"`
+	Avg      float32 `json:"avg"`
+	Max      float32 `json:"max"`
+	Loss     float32 `json:"loss"`   // 丢包率，百分比
+	Jitter   float32 `json:"jitter"` // 延迟与平均值的平均偏差
+}
+
+// NewICMPTaskResult 根据发送的包数及收到回复的延迟计算统计结果
+func NewICMPTaskResult(sent int, rtts []float32) *ICMPTaskResult {
+	r := &ICMPTaskResult{
+		Sent:     sent,
+		Received: len(rtts),
+	}
+	if r.Sent < r.Received {
+		r.Sent = r.Received
+	}
+	if r.Received == 0 {
+		r.Loss = 100
+		return r
+	}
+	r.Loss = float32(r.Sent-r.Received) / float32(r.Sent) * 100
+
+	r.Min, r.Max = float32(math.MaxFloat32), 0
+	var sum float32
+	for _, v := range rtts {
+		sum += v
+		r.Min = min(r.Min, v)
+		r.Max = max(r.Max, v)
+	}
+	r.Avg = sum / float32(r.Received)
+
+	var dev float32
+	for _, v := range rtts {
+		dev += float32(math.Abs(float64(v - r.Avg)))
+	}
+	r.Jitter = dev / float32(r.Received)
+	return r
+}
+
+// ParseICMPTaskResult 解析 Agent 上报的数据，旧版 Agent 不上报统计结果
+func ParseICMPTaskResult(data string) (*ICMPTaskResult, bool) {
+	if !strings.HasPrefix(data, "{") {
+		return nil, false
+	}
+	var r ICMPTaskResult
+	if err := json.Unmarshal([]byte(data), &r); err != nil {
+		return nil, false
+	}
+	return &r, true
+}
+
+func (r *ICMPTaskResult) String() string {
+	if r.Received == 0 {
+		return fmt.Sprintf("%d/%d packets received, loss 100%%", r.Received, r.Sent)
+	}
+	return fmt.Sprintf("%d/%d packets received, loss %.1f%%, min/avg/max %.2f/%.2f/%.2f ms, jitter %.2f ms",
+		r.Received, r.Sent, r.Loss, r.Min, r.Avg, r.Max, r.Jitter)
+}
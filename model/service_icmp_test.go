@@ -0,0 +1,56 @@
+package model
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNewICMPTaskResult(t *testing.T) {
+	cases := []struct {
+		name   string
+		sent   int
+		rtts   []float32
+		expect ICMPTaskResult
+	}{
+		{"AllLost", 3, nil, ICMPTaskResult{Sent: 3, Loss: 100}},
+		{"NothingSent", 0, nil, ICMPTaskResult{Loss: 100}},
+		{"SinglePacket", 1, []float32{12}, ICMPTaskResult{Sent: 1, Received: 1, Min: 12, Avg: 12, Max: 12}},
+		{"PartialLoss", 4, []float32{10, 20, 30}, ICMPTaskResult{Sent: 4, Received: 3, Min: 10, Avg: 20, Max: 30, Loss: 25, Jitter: 20.0 / 3}},
+		{"MoreRepliesThanSent", 1, []float32{5, 5}, ICMPTaskResult{Sent: 2, Received: 2, Min: 5, Avg: 5, Max: 5}},
+	}
+
+	for _, c := range cases {
+		r := NewICMPTaskResult(c.sent, c.rtts)
+		for _, v := range []float32{r.Min, r.Avg, r.Max, r.Loss, r.Jitter} {
+			if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+				t.Fatalf("%s: unexpected non-finite value in %+v", c.name, r)
+			}
+		}
+		if r.Sent != c.expect.Sent || r.Received != c.expect.Received ||
+			r.Min != c.expect.Min || r.Avg != c.expect.Avg || r.Max != c.expect.Max ||
+			r.Loss != c.expect.Loss || math.Abs(float64(r.Jitter-c.expect.Jitter)) > 1e-4 {
+			t.Fatalf("%s: expected %+v, but got %+v", c.name, c.expect, *r)
+		}
+	}
+}
+
+func TestParseICMPTaskResult(t *testing.T) {
+	if _, ok := ParseICMPTaskResult(""); ok {
+		t.Fatal("legacy empty data should not be parsed")
+	}
+	r, ok := ParseICMPTaskResult(`{"sent":3,"received":2,"avg":1.5,"loss":33.3}`)
+	if !ok || r.Sent != 3 || r.Received != 2 || r.Avg != 1.5 {
+		t.Fatalf("unexpected result %+v", r)
+	}
+}
+
+func TestICMPTaskData(t *testing.T) {
+	m := &Service{Type: TaskTypeICMPPing, Target: "example.com", ICMPCount: 5, ICMPInterval: 200}
+	if data := m.TaskData(&Server{}); data != m.Target {
+		t.Fatalf("expected legacy agent to get the plain host, got %s", data)
+	}
+	s := &Server{Capabilities: ParseAgentCapabilities("monitor_options")}
+	if data := m.TaskData(s); data == m.Target {
+		t.Fatal("expected packet options to be sent to agent reporting monitor_options")
+	}
+}
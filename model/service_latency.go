@@ -0,0 +1,146 @@
+package model
+
+import (
+	"log"
+	"slices"
+	"time"
+
+	"github.com/goccy/go-json"
+	"gorm.io/gorm"
+)
+
+const (
+	LatencyIntervalRaw    = 5 * 60       // 最近数据的汇总间隔（秒）
+	LatencyIntervalHourly = 60 * 60      // 降采样后的汇总间隔（秒）
+	LatencyIntervalDaily  = 24 * 60 * 60 // 小时汇总超出保留期后的汇总间隔（秒）
+)
+
+const (
+	LatencyGroupByServer = "server"
+	LatencyGroupByRegion = "region"
+	LatencyGroupByNone   = "none"
+)
+
+// LatencyBucketBounds 延迟直方图各桶上界（毫秒），最后一个桶为无穷大
+var LatencyBucketBounds = []float32{1, 2, 5, 10, 20, 30, 50, 75, 100, 150, 200, 300, 500, 750, 1000, 1500, 2000, 3000, 5000, 10000}
+
+// LatencyHistogram 固定分桶的延迟直方图，可增量累加与合并
+type LatencyHistogram struct {
+	Buckets []uint64 `json:"buckets"`
+	Count   uint64   `json:"count"`
+	Sum     float64  `json:"sum"`
+	Min     float32  `json:"min"`
+	Max     float32  `json:"max"`
+}
+
+func NewLatencyHistogram() *LatencyHistogram {
+	return &LatencyHistogram{Buckets: make([]uint64, len(LatencyBucketBounds)+1)}
+}
+
+func (h *LatencyHistogram) Add(delay float32) {
+	if len(h.Buckets) != len(LatencyBucketBounds)+1 {
+		h.Buckets = make([]uint64, len(LatencyBucketBounds)+1)
+	}
+	i, _ := slices.BinarySearch(LatencyBucketBounds, delay)
+	h.Buckets[i]++
+	if h.Count == 0 || delay < h.Min {
+		h.Min = delay
+	}
+	if delay > h.Max {
+		h.Max = delay
+	}
+	h.Count++
+	h.Sum += float64(delay)
+}
+
+func (h *LatencyHistogram) Merge(o *LatencyHistogram) {
+	if o == nil || o.Count == 0 {
+		return
+	}
+	if len(h.Buckets) != len(LatencyBucketBounds)+1 {
+		h.Buckets = make([]uint64, len(LatencyBucketBounds)+1)
+	}
+	for i := range min(len(h.Buckets), len(o.Buckets)) {
+		h.Buckets[i] += o.Buckets[i]
+	}
+	if h.Count == 0 || o.Min < h.Min {
+		h.Min = o.Min
+	}
+	if o.Max > h.Max {
+		h.Max = o.Max
+	}
+	h.Count += o.Count
+	h.Sum += o.Sum
+}
+
+// Percentile 估算百分位延迟，在命中的桶内线性插值
+func (h *LatencyHistogram) Percentile(p float64) float32 {
+	if h.Count == 0 {
+		return 0
+	}
+	rank := p / 100 * float64(h.Count)
+	var cum uint64
+	for i, n := range h.Buckets {
+		if n == 0 || float64(cum+n) < rank {
+			cum += n
+			continue
+		}
+		lower, upper := h.Min, h.Max
+		if i > 0 {
+			lower = max(lower, LatencyBucketBounds[i-1])
+		}
+		if i < len(LatencyBucketBounds) {
+			upper = min(upper, LatencyBucketBounds[i])
+		}
+		frac := (rank - float64(cum)) / float64(n)
+		return lower + (upper-lower)*float32(frac)
+	}
+	return h.Max
+}
+
+// ServiceLatencySummary 每个监测点在一个汇总间隔内的延迟分布
+type ServiceLatencySummary struct {
+	ID        uint64    `gorm:"primaryKey" json:"-"`
+	ServiceID uint64    `gorm:"index:idx_latency_summary" json:"service_id"`
+	ServerID  uint64    `gorm:"index:idx_latency_summary" json:"server_id"`
+	Start     time.Time `gorm:"index:idx_latency_summary" json:"start"`
+	Interval  uint32    `json:"interval"` // 汇总间隔（秒）
+	HistRaw   string    `json:"-"`
+
+	Histogram *LatencyHistogram `gorm:"-" json:"-"`
+}
+
+func (s *ServiceLatencySummary) BeforeSave(tx *gorm.DB) error {
+	data, err := json.Marshal(s.Histogram)
+	if err != nil {
+		return err
+	}
+	s.HistRaw = string(data)
+	return nil
+}
+
+func (s *ServiceLatencySummary) AfterFind(tx *gorm.DB) error {
+	s.Histogram = NewLatencyHistogram()
+	if err := json.Unmarshal([]byte(s.HistRaw), s.Histogram); err != nil {
+		log.Println("NEZHA>> ServiceLatencySummary.AfterFind:", err)
+	}
+	return nil
+}
+
+type ServiceLatencyPoint struct {
+	Time  int64   `json:"time"` // 毫秒时间戳
+	Value float32 `json:"value"`
+	Count uint64  `json:"count"`
+}
+
+type ServiceLatencySeries struct {
+	Group  string                `json:"group"`
+	Points []ServiceLatencyPoint `json:"points"`
+}
+
+type ServiceLatencyResponse struct {
+	ServiceID  uint64                 `json:"service_id"`
+	GroupBy    string                 `json:"group_by"`
+	Percentile float64                `json:"percentile"`
+	Series     []ServiceLatencySeries `json:"series"`
+}
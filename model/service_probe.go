@@ -0,0 +1,62 @@
+package model
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// ServiceProbeSelector 指定执行监控任务的 Agent，仅在 Cover 为 ServiceCoverSelected 时生效。
+// 指定的服务器按顺序优先选择，未指定服务器、分组与标签时从全部服务器中选择
+type ServiceProbeSelector struct {
+	ServerSelector
+	Countries []string `json:"countries,omitempty"` // 按 GeoIP 国家代码筛选
+	Limit     uint8    `json:"limit,omitempty"`     // 最多选择多少个在线监测点，0 为不限制
+}
+
+// ServiceProbeChange 监测点变更记录
+type ServiceProbeChange struct {
+	Time    time.Time `json:"time"`
+	Added   []uint64  `json:"added,omitempty"`
+	Removed []uint64  `json:"removed,omitempty"`
+}
+
+// ServiceProbeAssignment 服务当前分配的监测点
+type ServiceProbeAssignment struct {
+	ServiceID  uint64               `json:"service_id"`
+	Servers    []uint64             `json:"servers"`
+	LastChecks map[uint64]time.Time `json:"last_checks,omitempty"` // 各监测点最近一次上报结果的时间
+	Changes    []ServiceProbeChange `json:"changes,omitempty"`
+}
+
+func (s *ServiceProbeSelector) IsEmpty() bool {
+	return s == nil || (s.ServerSelector.IsEmpty() && len(s.Exclude) == 0 && len(s.Countries) == 0 && s.Limit == 0)
+}
+
+func (s *ServiceProbeSelector) Validate() error {
+	if s.IsEmpty() {
+		return errors.New("probe selector is required")
+	}
+	for i, c := range s.Countries {
+		c = strings.ToLower(strings.TrimSpace(c))
+		if len(c) != 2 {
+			return errors.New("invalid country code " + c)
+		}
+		s.Countries[i] = c
+	}
+	return nil
+}
+
+// MatchCountry 判断国家代码是否符合筛选条件
+func (s *ServiceProbeSelector) MatchCountry(code string) bool {
+	if len(s.Countries) == 0 {
+		return true
+	}
+	code = strings.ToLower(code)
+	for _, c := range s.Countries {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
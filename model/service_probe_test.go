@@ -0,0 +1,34 @@
+package model
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/goccy/go-json"
+)
+
+func TestServiceProbeSelectorJSON(t *testing.T) {
+	// 旧版本保存的选择条件中服务器与分组位于顶层
+	var sel ServiceProbeSelector
+	if err := json.Unmarshal([]byte(`{"servers":[3,1],"server_groups":[2],"countries":["us"],"limit":2}`), &sel); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(sel.Servers, []uint64{3, 1}) || !slices.Equal(sel.ServerGroups, []uint64{2}) || sel.Limit != 2 {
+		t.Fatalf("unexpected selector: %+v", sel)
+	}
+
+	sel = ServiceProbeSelector{ServerSelector: ServerSelector{Tags: []string{"edge"}}}
+	data, err := json.Marshal(&sel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"tags":["edge"]}` {
+		t.Fatalf("unexpected encoding: %s", data)
+	}
+	if sel.IsEmpty() {
+		t.Fatal("expected selector with tags to be non-empty")
+	}
+	if !(&ServiceProbeSelector{}).IsEmpty() {
+		t.Fatal("expected zero selector to be empty")
+	}
+}
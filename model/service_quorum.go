@@ -0,0 +1,101 @@
+package model
+
+import (
+	"cmp"
+	"errors"
+	"slices"
+	"time"
+)
+
+const defaultQuorumWindowChecks = 3 // 未配置评估窗口时按 3 个检查间隔计算
+
+// ServiceProbeVote 监测点最近一次上报的检查结果
+type ServiceProbeVote struct {
+	ServerID   uint64
+	Successful bool
+	Data       string
+	Time       time.Time
+}
+
+// ServiceQuorum 按多个监测点的结果共同判定的服务状态
+type ServiceQuorum struct {
+	Required  int                // 判定为故障需要的失败监测点数
+	Total     int                // 参与判定的监测点数
+	Failed    []ServiceProbeVote // 按服务器 ID 排序
+	Succeeded []ServiceProbeVote // 按服务器 ID 排序
+}
+
+// QuorumEnabled 配置了故障判定所需的监测点数或比例时，单个监测点失败不再改变服务状态
+func (m *Service) QuorumEnabled() bool {
+	return m.QuorumCount > 0 || m.QuorumPercent > 0
+}
+
+// QuorumWindowDuration 监测点结果的有效期，超过后不再参与判定
+func (m *Service) QuorumWindowDuration() time.Duration {
+	if m.QuorumWindow > 0 {
+		return time.Duration(m.QuorumWindow) * time.Second
+	}
+	interval := m.Duration
+	if interval == 0 {
+		interval = 30
+	}
+	return time.Duration(interval*defaultQuorumWindowChecks) * time.Second
+}
+
+func (m *Service) ValidateQuorum() error {
+	if m.QuorumCount > 0 && m.QuorumPercent > 0 {
+		return errors.New("quorum_count and quorum_percent cannot be used together")
+	}
+	if m.QuorumPercent > 100 {
+		return errors.New("quorum_percent must not exceed 100")
+	}
+	if m.QuorumWindow > 0 && uint64(m.QuorumWindow) < m.Duration {
+		return errors.New("quorum_window must not be shorter than the check interval")
+	}
+	return nil
+}
+
+// QuorumRequired 返回判定为故障需要的失败监测点数，不超过参与判定的监测点数
+func (m *Service) QuorumRequired(total int) int {
+	var required int
+	if m.QuorumCount > 0 {
+		required = int(m.QuorumCount)
+	} else {
+		required = (total*int(m.QuorumPercent) + 99) / 100
+	}
+	return max(min(required, total), 1)
+}
+
+// EvaluateQuorum 按评估窗口内各监测点最近一次的结果判定服务状态，过期的结果会从 votes 中移除。
+// assigned 为当前分配的监测点数，尚未上报的监测点同样计入比例的分母
+func (m *Service) EvaluateQuorum(votes map[uint64]ServiceProbeVote, assigned int, now time.Time) *ServiceQuorum {
+	window := m.QuorumWindowDuration()
+	q := &ServiceQuorum{}
+	for id, v := range votes {
+		if now.Sub(v.Time) > window {
+			delete(votes, id)
+			continue
+		}
+		if v.Successful {
+			q.Succeeded = append(q.Succeeded, v)
+		} else {
+			q.Failed = append(q.Failed, v)
+		}
+	}
+	sortProbeVotes(q.Failed)
+	sortProbeVotes(q.Succeeded)
+	q.Total = max(len(votes), assigned)
+	q.Required = m.QuorumRequired(q.Total)
+	return q
+}
+
+// Down 失败的监测点数达到要求时判定为故障
+func (q *ServiceQuorum) Down() bool {
+	return len(q.Failed) > 0 && len(q.Failed) >= q.Required
+}
+
+func sortProbeVotes(votes []ServiceProbeVote) {
+	slices.SortFunc(votes, func(a, b ServiceProbeVote) int {
+		return cmp.Compare(a.ServerID, b.ServerID)
+	})
+}
@@ -0,0 +1,63 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestServiceQuorum(t *testing.T) {
+	now := time.Now()
+	s := &Service{Duration: 30, QuorumCount: 2}
+	votes := map[uint64]ServiceProbeVote{
+		1: {ServerID: 1, Successful: false, Time: now},
+		2: {ServerID: 2, Successful: true, Time: now},
+		3: {ServerID: 3, Successful: true, Time: now},
+	}
+	if q := s.EvaluateQuorum(votes, 0, now); q.Down() {
+		t.Error("expected a single failing probe not to be down")
+	}
+
+	// 过期的结果不参与判定
+	votes[3] = ServiceProbeVote{ServerID: 3, Successful: false, Time: now.Add(-2 * time.Minute)}
+	q := s.EvaluateQuorum(votes, 0, now)
+	if q.Down() || q.Total != 2 {
+		t.Errorf("expected stale vote to be dropped, got %+v", q)
+	}
+	if _, ok := votes[3]; ok {
+		t.Error("expected stale vote to be removed")
+	}
+
+	votes[3] = ServiceProbeVote{ServerID: 3, Successful: false, Time: now}
+	q = s.EvaluateQuorum(votes, 0, now)
+	if !q.Down() || len(q.Failed) != 2 || q.Failed[0].ServerID != 1 || q.Failed[1].ServerID != 3 {
+		t.Errorf("expected two failing probes to be down, got %+v", q)
+	}
+
+	// 按比例判定时尚未上报的监测点计入分母
+	s = &Service{Duration: 30, QuorumPercent: 50}
+	if q := s.EvaluateQuorum(votes, 8, now); q.Required != 4 || q.Down() {
+		t.Errorf("expected 4 of 8 probes to be required, got %+v", q)
+	}
+	if q := s.EvaluateQuorum(votes, 0, now); q.Required != 2 || !q.Down() {
+		t.Errorf("expected 2 of 3 probes to be required, got %+v", q)
+	}
+
+	// 要求的数量超过监测点数时，所有监测点失败即判定为故障
+	s = &Service{Duration: 30, QuorumCount: 5}
+	all := map[uint64]ServiceProbeVote{1: {ServerID: 1, Time: now}, 2: {ServerID: 2, Time: now}}
+	if q := s.EvaluateQuorum(all, 0, now); !q.Down() {
+		t.Errorf("expected all failing probes to be down, got %+v", q)
+	}
+}
+
+func TestServiceQuorumValidate(t *testing.T) {
+	for _, s := range []*Service{
+		{QuorumCount: 2, QuorumPercent: 50},
+		{QuorumPercent: 120},
+		{Duration: 60, QuorumCount: 2, QuorumWindow: 30},
+	} {
+		if err := s.ValidateQuorum(); err == nil {
+			t.Errorf("expected error for %+v", s)
+		}
+	}
+}
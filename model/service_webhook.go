@@ -0,0 +1,41 @@
+package model
+
+import "time"
+
+const (
+	ServiceEventCheck       = "check"        // 单次检查结果
+	ServiceEventStateChange = "state_change" // 服务状态变更
+)
+
+// ServiceCheckEvent 推送到服务监控 Webhook 的数据
+type ServiceCheckEvent struct {
+	Event       string    `json:"event"`
+	ServiceID   uint64    `json:"service_id"`
+	ServiceName string    `json:"service_name"`
+	ServerID    uint64    `json:"server_id"`
+	ServerName  string    `json:"server_name,omitempty"`
+	Successful  bool      `json:"successful"`
+	Delay       float32   `json:"delay"`
+	Reason      string    `json:"reason,omitempty"`
+	Status      string    `json:"status,omitempty"`
+	LastStatus  string    `json:"last_status,omitempty"`
+	Time        time.Time `json:"time"`
+}
+
+// ServiceResult 开启了原始结果记录的服务的单次检查结果，未经汇总
+type ServiceResult struct {
+	ID         uint64    `gorm:"primaryKey" json:"id"`
+	CreatedAt  time.Time `gorm:"index:idx_service_result,priority:2" json:"created_at"`
+	ServiceID  uint64    `gorm:"index:idx_service_result,priority:1" json:"service_id"`
+	ServerID   uint64    `json:"server_id"`
+	Successful bool      `json:"successful"`
+	Delay      float32   `json:"delay"`
+	Data       string    `json:"data,omitempty"` // 失败原因或检查详情
+}
+
+// ServiceResultsResponse 服务监控原始结果分页，NextCursor 为空时表示没有更多数据，
+// 游标为上一页最后一条记录的 ID，调用方原样传回即可
+type ServiceResultsResponse struct {
+	Results    []ServiceResult `json:"results"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
@@ -0,0 +1,20 @@
+package model
+
+import "time"
+
+// CtxKeySessionID 当前请求所属的登录会话
+const CtxKeySessionID = "cksid"
+
+// JWTClaimSessionID JWT 中保存会话 ID 的字段
+const JWTClaimSessionID = "sid"
+
+// Session 登录会话，撤销后携带该会话 JWT 的请求与 WebSocket 连接立即失效
+type Session struct {
+	ID         string    `gorm:"primaryKey;type:char(32)" json:"id"`
+	UserID     uint64    `gorm:"index" json:"user_id"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `gorm:"index" json:"last_seen_at"`
+	IP         string    `json:"ip,omitempty"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+	Current    bool      `gorm:"-" json:"current"` // 是否为发起请求的会话
+}
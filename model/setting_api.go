@@ -12,11 +12,32 @@ type SettingForm struct {
 	CustomCodeDashboard         string `json:"custom_code_dashboard,omitempty" validate:"optional"`
 	WebRealIPHeader                string `json:"web_real_ip_header,omitempty" validate:"optional"` // 前端真实IP
 	AgentRealIPHeader                string `json:"agent_real_ip_header,omitempty" validate:"optional"` // Agent真实IP
+	TrustedProxies                []string `json:"trusted_proxies,omitempty" validate:"optional"` // 可信代理的 IP 或 CIDR
 	UserTemplate                string `json:"user_template,omitempty" validate:"optional"`
+	TrafficRetentionDays        int    `json:"traffic_retention_days,omitempty" validate:"optional"` // 每日流量汇总保留天数
+	CronHistoryRetentionDays    int    `json:"cron_history_retention_days,omitempty" validate:"optional"` // 计划任务执行记录保留天数
+	CronHistoryMaxRows          int    `json:"cron_history_max_rows,omitempty" validate:"optional"`       // 每个计划任务最多保留的执行记录数
+	AuditLogRetentionDays       int    `json:"audit_log_retention_days,omitempty" validate:"optional"`    // 审计日志保留天数
+	ServiceHistoryRawDays       int    `json:"service_history_raw_days,omitempty" validate:"optional"`    // 监控原始记录保留天数
+	ServiceHistoryHourlyDays    int    `json:"service_history_hourly_days,omitempty" validate:"optional"` // 监控小时汇总保留天数
+	ServiceHistoryDailyDays     *int   `json:"service_history_daily_days,omitempty" validate:"optional"`  // 监控每日汇总保留天数，0 为永久保留，为空时不修改
+	NATStatResetSchedule        string `json:"nat_stat_reset_schedule,omitempty" validate:"optional"`     // NAT 流量统计的重置周期
+	MeshPingInterval            int    `json:"mesh_ping_interval,omitempty" validate:"optional"`          // 节点间延迟测试的间隔（秒）
+	MeshPingFanOut              int    `json:"mesh_ping_fan_out,omitempty" validate:"optional"`           // 每个节点每轮测试的节点数
+	WAFUnresolvedAction         string `json:"waf_unresolved_action,omitempty" validate:"optional"`       // 无法确定国家的地址的默认动作，allow 或 deny
+	WAFNotificationGroupID      uint64 `json:"waf_notification_group_id,omitempty" validate:"optional"`   // WAF 封禁通知的通知组，0 为不通知
+
+	WAFNotifyReasons []string `json:"waf_notify_reasons,omitempty" validate:"optional"` // 发送通知的封禁原因，为空时为全部
 
 	AgentTLS                    bool `json:"tls,omitempty" validate:"optional"`
 	EnableIPChangeNotification  bool `json:"enable_ip_change_notification,omitempty" validate:"optional"`
 	EnablePlainIPInNotification bool `json:"enable_plain_ip_in_notification,omitempty" validate:"optional"`
+	RequireAdminForShellTasks   bool `json:"require_admin_for_shell_tasks,omitempty" validate:"optional"`
+	DisablePasswordLogin        bool `json:"disable_password_login,omitempty" validate:"optional"`
+	ViewerShowNote              bool `json:"viewer_show_note,omitempty" validate:"optional"`
+	StrictTenant                bool `json:"strict_tenant,omitempty" validate:"optional"`
+	AuditRejectedReports        bool `json:"audit_rejected_reports,omitempty" validate:"optional"`
+	WAFDailySummary             bool `json:"waf_daily_summary,omitempty" validate:"optional"`
 }
 
 type Setting struct {
@@ -43,3 +64,19 @@ type SettingResponse struct {
 	Version           string             `json:"version,omitempty"`
 	FrontendTemplates []FrontendTemplate `json:"frontend_templates,omitempty"`
 }
+
+// ReadOnlyForm 切换只读模式，Banner 为空时不展示维护公告
+type ReadOnlyForm struct {
+	Enabled      bool   `json:"enabled" validate:"optional"`
+	PauseReports bool   `json:"pause_reports" validate:"optional"` // 同时暂停接收 Agent 上报
+	Banner       string `json:"banner" validate:"optional"`
+}
+
+// PublicSettingResponse 无需登录即可获取的设置，用于前端展示维护公告与站点标识
+type PublicSettingResponse struct {
+	SiteName          string             `json:"site_name"`
+	ReadOnly          bool               `json:"read_only"`
+	MaintenanceBanner string             `json:"maintenance_banner,omitempty"`
+	Branding          Branding           `json:"branding"`
+	Display           DisplayPreferences `json:"display"` // 访客的展示偏好，登录用户以个人资料中的为准
+}
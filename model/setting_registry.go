@@ -0,0 +1,62 @@
+package model
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	SettingTypeBool       = "bool"
+	SettingTypeInt        = "int"
+	SettingTypeFloat      = "float"
+	SettingTypeString     = "string"
+	SettingTypeStringList = "string_list"
+	SettingTypeSecret     = "secret" // 只写，接口不返回值
+)
+
+// SettingOverride 通过接口修改的设置，优先于配置文件中的值
+type SettingOverride struct {
+	Key       string    `gorm:"primaryKey" json:"key"`
+	Value     string    `gorm:"type:longtext;serializer:secret" json:"-"` // JSON 编码的值
+	UserID    uint64    `json:"user_id"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (s *SettingOverride) TableName() string {
+	return "settings"
+}
+
+// SettingItem 设置项及其当前值
+type SettingItem struct {
+	Key             string `json:"key"`
+	Type            string `json:"type"`
+	Value           any    `json:"value,omitempty"`   // secret 类型不返回
+	Default         any    `json:"default,omitempty"` // 未修改时使用的值，即配置文件中的值
+	Overridden      bool   `json:"overridden,omitempty"`
+	RestartRequired bool   `json:"restart_required,omitempty"` // 修改后需要重启才能生效
+}
+
+// SettingsUpdateResponse 值为 null 的设置项恢复为配置文件中的值
+type SettingsUpdateResponse struct {
+	Updated         []string `json:"updated,omitempty"`
+	RestartRequired []string `json:"restart_required,omitempty"`
+}
+
+// SettingErrors 按设置项返回的校验错误
+type SettingErrors map[string]string
+
+func (e SettingErrors) Error() string {
+	keys := make([]string, 0, len(e))
+	for k := range e {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	msgs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		msgs = append(msgs, fmt.Sprintf("%s: %s", k, e[k]))
+	}
+	return strings.Join(msgs, "; ")
+}
@@ -0,0 +1,258 @@
+package model
+
+import (
+	"log"
+	"time"
+
+	"github.com/goccy/go-json"
+	"gorm.io/gorm"
+)
+
+const (
+	SLAReportPeriodMonthly = "monthly"
+	SLAReportPeriodWeekly  = "weekly"
+)
+
+// SLAReportConfig SLA 报告配置，每个周期结束后生成上一周期的报告
+type SLAReportConfig struct {
+	Common
+
+	Name            string  `json:"name"`
+	ServicesRaw     string  `gorm:"default:'[]'" json:"-"`
+	ServerGroupsRaw string  `gorm:"default:'[]'" json:"-"`
+	Target          float64 `json:"target"`                                         // SLA 目标可用率，百分比
+	Period          string  `json:"period"`                                         // monthly、weekly
+	Enabled         bool    `json:"enabled"`                                        // 是否按周期自动生成
+	WebhookURL      string  `gorm:"serializer:secret" json:"webhook_url,omitempty"` // 生成后以 JSON 推送报告，为空时不推送
+
+	Services     []uint64 `gorm:"-" json:"services"`
+	ServerGroups []uint64 `gorm:"-" json:"server_groups"`
+}
+
+func (c *SLAReportConfig) BeforeSave(tx *gorm.DB) error {
+	data, err := json.Marshal(c.Services)
+	if err != nil {
+		return err
+	}
+	c.ServicesRaw = string(data)
+	if data, err = json.Marshal(c.ServerGroups); err != nil {
+		return err
+	}
+	c.ServerGroupsRaw = string(data)
+	return nil
+}
+
+func (c *SLAReportConfig) AfterFind(tx *gorm.DB) error {
+	if err := json.Unmarshal([]byte(c.ServicesRaw), &c.Services); err != nil {
+		log.Println("NEZHA>> SLAReportConfig.AfterFind:", err)
+	}
+	if c.ServerGroupsRaw != "" {
+		if err := json.Unmarshal([]byte(c.ServerGroupsRaw), &c.ServerGroups); err != nil {
+			log.Println("NEZHA>> SLAReportConfig.AfterFind:", err)
+		}
+	}
+	return nil
+}
+
+// Redacted 返回隐藏 Webhook 地址的副本，用于 API 输出
+func (c *SLAReportConfig) Redacted() *SLAReportConfig {
+	p := *c
+	p.WebhookURL = RedactURL(p.WebhookURL)
+	return &p
+}
+
+// PeriodRange 返回 now 之前最近一个完整周期的起止时间
+func (c *SLAReportConfig) PeriodRange(now time.Time) (start, end time.Time) {
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	if c.Period == SLAReportPeriodWeekly {
+		// 以周一为一周的开始
+		end = day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+		return end.AddDate(0, 0, -7), end
+	}
+	end = day.AddDate(0, 0, 1-day.Day())
+	return end.AddDate(0, -1, 0), end
+}
+
+// SLAReport 已生成的报告，JSON 与 HTML 均在生成时保存，之后的数据变化不影响历史报告
+type SLAReport struct {
+	Common
+
+	ConfigID    uint64    `gorm:"index" json:"config_id"`
+	Name        string    `json:"name"`
+	PeriodStart time.Time `gorm:"index" json:"period_start"`
+	PeriodEnd   time.Time `json:"period_end"`
+	Data        string    `gorm:"type:longtext" json:"-"`
+	HTML        string    `gorm:"type:longtext" json:"-"`
+}
+
+type SLAReportData struct {
+	Name         string                  `json:"name"`
+	PeriodStart  time.Time               `json:"period_start"`
+	PeriodEnd    time.Time               `json:"period_end"`
+	Target       float64                 `json:"target"`
+	GeneratedAt  time.Time               `json:"generated_at"`
+	Services     []*SLAServiceReport     `json:"services"`
+	ServerGroups []*SLAServerGroupReport `json:"server_groups"`
+}
+
+type SLAServiceReport struct {
+	ID          uint64         `json:"id"`
+	Name        string         `json:"name"`
+	Uptime      float64        `json:"uptime"` // 百分比，没有数据时为 -1
+	MetTarget   bool           `json:"met_target"`
+	Up          uint64         `json:"up"`
+	Down        uint64         `json:"down"`
+	Downtime    int64          `json:"downtime"`    // 故障时长，秒
+	Maintenance int64          `json:"maintenance"` // 被排除的维护时长，秒
+	MeanLatency float32        `json:"mean_latency"`
+	Incidents   []*SLAIncident `json:"incidents"`
+}
+
+// SLAServerGroupReport 服务器分组的可用率，由分组内各服务器的在线采样合计
+type SLAServerGroupReport struct {
+	ID        uint64             `json:"id"`
+	Name      string             `json:"name"`
+	Uptime    float64            `json:"uptime"` // 百分比，没有数据时为 -1
+	MetTarget bool               `json:"met_target"`
+	Up        uint64             `json:"up"`
+	Down      uint64             `json:"down"`
+	Servers   []*SLAServerReport `json:"servers"`
+}
+
+type SLAServerReport struct {
+	ID       uint64  `json:"id"`
+	Name     string  `json:"name"`
+	Uptime   float64 `json:"uptime"` // 百分比，没有数据时为 -1
+	Up       uint64  `json:"up"`
+	Down     uint64  `json:"down"`
+	Downtime int64   `json:"downtime"` // 离线时长，秒
+}
+
+type SLAIncident struct {
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Duration int64     `json:"duration"` // 秒
+}
+
+type TimeRange struct {
+	Start, End time.Time
+}
+
+func (r TimeRange) Contains(t time.Time) bool {
+	return !t.Before(r.Start) && t.Before(r.End)
+}
+
+// Overlap 返回两个时间段重叠的时长
+func (r TimeRange) Overlap(o TimeRange) time.Duration {
+	start, end := r.Start, r.End
+	if o.Start.After(start) {
+		start = o.Start
+	}
+	if o.End.Before(end) {
+		end = o.End
+	}
+	return max(end.Sub(start), 0)
+}
+
+// ComputeServiceSLA 根据按时间排序的服务汇总记录计算可用率、故障与平均延迟。
+// 每条记录统计的是上一条记录到该记录之间的检查，故障数多于正常数的记录视为故障；
+// 处于维护窗口内的记录不计入可用率与故障
+func ComputeServiceSLA(rows []ServiceHistory, maintenance []TimeRange) *SLAServiceReport {
+	r := &SLAServiceReport{Uptime: -1, Incidents: make([]*SLAIncident, 0)}
+	var open *SLAIncident
+	closeIncident := func(end time.Time) {
+		if open == nil {
+			return
+		}
+		open.End = end
+		open.Duration = int64(end.Sub(open.Start).Seconds())
+		r.Downtime += open.Duration
+		r.Incidents = append(r.Incidents, open)
+		open = nil
+	}
+
+	var latencySum float64
+	var latencyWeight uint64
+	for i, row := range rows {
+		t := row.CreatedAt
+		bucketStart := t
+		if i > 0 {
+			bucketStart = rows[i-1].CreatedAt
+		} else if len(rows) > 1 {
+			bucketStart = t.Add(-rows[1].CreatedAt.Sub(t))
+		}
+
+		inMaintenance := false
+		for _, m := range maintenance {
+			if m.Contains(t) {
+				inMaintenance = true
+				break
+			}
+		}
+		if inMaintenance {
+			r.Maintenance += int64(t.Sub(bucketStart).Seconds())
+			closeIncident(bucketStart)
+			continue
+		}
+
+		r.Up += row.Up
+		r.Down += row.Down
+		if row.AvgDelay > 0 && row.Up > 0 {
+			latencySum += float64(row.AvgDelay) * float64(row.Up)
+			latencyWeight += row.Up
+		}
+
+		if row.Down > row.Up {
+			if open == nil {
+				open = &SLAIncident{Start: bucketStart}
+			}
+		} else {
+			closeIncident(bucketStart)
+		}
+	}
+	if open != nil {
+		closeIncident(rows[len(rows)-1].CreatedAt)
+	}
+
+	if total := r.Up + r.Down; total > 0 {
+		r.Uptime = float64(r.Up) / float64(total) * 100
+	}
+	if latencyWeight > 0 {
+		r.MeanLatency = float32(latencySum / float64(latencyWeight))
+	}
+	return r
+}
+
+// ComputeServerSLA 根据服务器的小时在线采样计算可用率，每个离线采样计为一个采样间隔的离线时长。
+// online 为 Agent 补报过状态的时间段，期间的离线采样按重叠的时长改为在线
+func ComputeServerSLA(rows []ServerUptime, online []TimeRange) *SLAServerReport {
+	r := &SLAServerReport{Uptime: -1}
+	for _, row := range rows {
+		hour := TimeRange{Start: row.Start, End: row.Start.Add(time.Hour)}
+		var overlap time.Duration
+		for _, o := range online {
+			overlap += hour.Overlap(o)
+		}
+		backfilled := min(uint64(overlap/ServerUptimeSampleInterval), row.Down)
+		r.Up += row.Up + backfilled
+		r.Down += row.Down - backfilled
+	}
+	r.Downtime = int64(r.Down) * int64(ServerUptimeSampleInterval/time.Second)
+	if total := r.Up + r.Down; total > 0 {
+		r.Uptime = float64(r.Up) / float64(total) * 100
+	}
+	return r
+}
+
+// ComputeServerGroupSLA 合计分组内各服务器的在线采样
+func ComputeServerGroupSLA(servers []*SLAServerReport) *SLAServerGroupReport {
+	r := &SLAServerGroupReport{Uptime: -1, Servers: servers}
+	for _, s := range servers {
+		r.Up += s.Up
+		r.Down += s.Down
+	}
+	if total := r.Up + r.Down; total > 0 {
+		r.Uptime = float64(r.Up) / float64(total) * 100
+	}
+	return r
+}
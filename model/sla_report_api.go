@@ -0,0 +1,23 @@
+package model
+
+type SLAReportConfigForm struct {
+	Name         string   `json:"name" minLength:"1"`
+	Services     []uint64 `json:"services" validate:"optional"`
+	ServerGroups []uint64 `json:"server_groups" validate:"optional"`
+	Target       float64  `json:"target" default:"99.9"`
+	Period       string   `json:"period" default:"monthly"` // monthly、weekly
+	Enabled      bool     `json:"enabled" validate:"optional"`
+	WebhookURL   string   `json:"webhook_url" validate:"optional"`
+}
+
+type SLAReportGenerateForm struct {
+	// 为空时生成上一个完整周期的报告
+	PeriodStart *int64 `json:"period_start,omitempty" validate:"optional"`
+	PeriodEnd   *int64 `json:"period_end,omitempty" validate:"optional"`
+}
+
+// SLAReportResponse 报告详情，HTML 版本通过 format=html 下载
+type SLAReportResponse struct {
+	SLAReport
+	Data *SLAReportData `json:"data"`
+}
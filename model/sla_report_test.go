@@ -0,0 +1,87 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestComputeServiceSLA(t *testing.T) {
+	base := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	at := func(m int) time.Time { return base.Add(time.Duration(m) * time.Minute) }
+	rows := []ServiceHistory{
+		{CreatedAt: at(10), Up: 10, AvgDelay: 20},
+		{CreatedAt: at(20), Up: 2, Down: 8},
+		{CreatedAt: at(30), Down: 10},
+		{CreatedAt: at(40), Up: 10, AvgDelay: 40},
+		{CreatedAt: at(50), Down: 10},
+		{CreatedAt: at(60), Up: 10, AvgDelay: 30},
+	}
+	// 第 5 条记录处于维护窗口内
+	maintenance := []TimeRange{{Start: at(45), End: at(55)}}
+
+	r := ComputeServiceSLA(rows, maintenance)
+	if r.Up != 32 || r.Down != 18 {
+		t.Fatalf("unexpected counts up=%d down=%d", r.Up, r.Down)
+	}
+	if r.Uptime != 64 {
+		t.Fatalf("unexpected uptime %v", r.Uptime)
+	}
+	if len(r.Incidents) != 1 || !r.Incidents[0].Start.Equal(at(10)) || !r.Incidents[0].End.Equal(at(30)) {
+		t.Fatalf("unexpected incidents %+v", r.Incidents)
+	}
+	if r.Downtime != 1200 || r.Maintenance != 600 {
+		t.Fatalf("unexpected downtime %d maintenance %d", r.Downtime, r.Maintenance)
+	}
+	if r.MeanLatency != 30 {
+		t.Fatalf("unexpected latency %v", r.MeanLatency)
+	}
+
+	if r := ComputeServiceSLA(nil, nil); r.Uptime != -1 || len(r.Incidents) != 0 {
+		t.Fatalf("unexpected empty report %+v", r)
+	}
+}
+
+func TestComputeServerSLA(t *testing.T) {
+	base := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	rows := []ServerUptime{
+		{Start: base, Up: 60},
+		{Start: base.Add(time.Hour), Up: 30, Down: 30},
+	}
+	a := ComputeServerSLA(rows, nil)
+	if a.Up != 90 || a.Down != 30 || a.Uptime != 75 || a.Downtime != 1800 {
+		t.Fatalf("unexpected server report %+v", a)
+	}
+	// 补报覆盖的 20 分钟计为在线
+	backfilled := []TimeRange{{Start: base.Add(50 * time.Minute), End: base.Add(80 * time.Minute)}}
+	if r := ComputeServerSLA(rows, backfilled); r.Up != 110 || r.Down != 10 || r.Downtime != 600 {
+		t.Fatalf("unexpected server report with backfill %+v", r)
+	}
+
+	b := ComputeServerSLA([]ServerUptime{{Start: base, Up: 40}}, nil)
+	empty := ComputeServerSLA(nil, nil)
+	if empty.Uptime != -1 {
+		t.Fatalf("unexpected empty report %+v", empty)
+	}
+
+	g := ComputeServerGroupSLA([]*SLAServerReport{a, b, empty})
+	if g.Up != 130 || g.Down != 30 || g.Uptime != 81.25 || len(g.Servers) != 3 {
+		t.Fatalf("unexpected group report %+v", g)
+	}
+	if g := ComputeServerGroupSLA(nil); g.Uptime != -1 {
+		t.Fatalf("unexpected empty group report %+v", g)
+	}
+}
+
+func TestSLAReportPeriodRange(t *testing.T) {
+	now := time.Date(2024, 3, 6, 12, 0, 0, 0, time.UTC) // 周三
+	c := &SLAReportConfig{Period: SLAReportPeriodMonthly}
+	start, end := c.PeriodRange(now)
+	if !start.Equal(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)) || !end.Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected monthly range %v - %v", start, end)
+	}
+	c.Period = SLAReportPeriodWeekly
+	start, end = c.PeriodRange(now)
+	if !start.Equal(time.Date(2024, 2, 26, 0, 0, 0, 0, time.UTC)) || !end.Equal(time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected weekly range %v - %v", start, end)
+	}
+}
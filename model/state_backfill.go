@@ -0,0 +1,80 @@
+package model
+
+import (
+	"cmp"
+	"slices"
+	"time"
+)
+
+const (
+	StateBackfillMaxSamples = 5000             // 每批最多合并的采样数
+	StateBackfillMaxSize    = 8 << 20          // 每批的最大字节数
+	StateBackfillMaxAge     = 24 * time.Hour   // 早于该时长的采样被丢弃
+	StateBackfillMaxSkew    = 60 * time.Second // 允许的 Agent 时钟超前
+)
+
+// StateBackfill Agent 断线期间缓存的状态，重新连接后按批补报，应在恢复实时上报前发送
+type StateBackfill struct {
+	Samples []BackfillSample `json:"samples"`
+}
+
+type BackfillSample struct {
+	Time  int64     `json:"time"` // 毫秒时间戳，同时用于去重
+	State HostState `json:"state"`
+}
+
+// Filter 丢弃时间超前、过旧或不晚于 after（已合并的最后一个采样）的采样，按时间排序并去除重复的时间戳
+func (b *StateBackfill) Filter(now time.Time, after int64) (samples []BackfillSample, rejected int) {
+	from := now.Add(-StateBackfillMaxAge).UnixMilli()
+	to := now.Add(StateBackfillMaxSkew).UnixMilli()
+	for _, s := range b.Samples {
+		switch {
+		case s.Time < from || s.Time > to:
+			rejected++
+		case s.Time > after:
+			samples = append(samples, s)
+		}
+	}
+	slices.SortStableFunc(samples, func(a, b BackfillSample) int { return cmp.Compare(a.Time, b.Time) })
+	samples = slices.CompactFunc(samples, func(a, b BackfillSample) bool { return a.Time == b.Time })
+	return samples, rejected
+}
+
+// BackfillCustomMetrics 按指标名称返回采样中的自定义指标
+func BackfillCustomMetrics(samples []BackfillSample) map[string][]CustomMetricSample {
+	ret := make(map[string][]CustomMetricSample)
+	for _, s := range samples {
+		for name, v := range s.State.CustomMetrics {
+			ret[name] = append(ret[name], CustomMetricSample{Time: s.Time, Value: v})
+		}
+	}
+	return ret
+}
+
+// MergeStateSamples 将补报的采样按时间合并到已有采样中，时间相同或间隔小于 resolution（毫秒）时保留先出现的采样，
+// 超出 capacity 时丢弃最早的采样
+func MergeStateSamples(existing, added []StateSample, resolution int64, capacity int) []StateSample {
+	return mergeSamples(existing, added, func(s StateSample) int64 { return s.Time }, resolution, capacity)
+}
+
+// MergeCustomMetricSamples 同 MergeStateSamples，用于自定义指标
+func MergeCustomMetricSamples(existing, added []CustomMetricSample, resolution int64, capacity int) []CustomMetricSample {
+	return mergeSamples(existing, added, func(s CustomMetricSample) int64 { return s.Time }, resolution, capacity)
+}
+
+func mergeSamples[T any](existing, added []T, timeOf func(T) int64, resolution int64, capacity int) []T {
+	merged := slices.Concat(existing, added)
+	slices.SortStableFunc(merged, func(a, b T) int { return cmp.Compare(timeOf(a), timeOf(b)) })
+
+	ret := merged[:0]
+	for _, s := range merged {
+		if n := len(ret); n > 0 && timeOf(s)-timeOf(ret[n-1]) < resolution {
+			continue
+		}
+		ret = append(ret, s)
+	}
+	if len(ret) > capacity {
+		ret = ret[len(ret)-capacity:]
+	}
+	return ret
+}
@@ -0,0 +1,72 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStateBackfillFilter(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	ms := func(d time.Duration) int64 { return now.Add(d).UnixMilli() }
+
+	b := &StateBackfill{Samples: []BackfillSample{
+		{Time: ms(-time.Minute)},
+		{Time: ms(-3 * time.Minute)},
+		{Time: ms(-2 * time.Minute)},
+		{Time: ms(-2 * time.Minute)}, // 重复投递
+		{Time: ms(-25 * time.Hour)},  // 过旧
+		{Time: ms(time.Hour)},        // 时钟超前
+		{Time: ms(-10 * time.Minute)},
+	}}
+
+	samples, rejected := b.Filter(now, ms(-5*time.Minute))
+	if rejected != 2 {
+		t.Fatalf("expected 2 rejected samples, got %d", rejected)
+	}
+	want := []int64{ms(-3 * time.Minute), ms(-2 * time.Minute), ms(-time.Minute)}
+	if len(samples) != len(want) {
+		t.Fatalf("expected %d samples, got %d", len(want), len(samples))
+	}
+	for i := range want {
+		if samples[i].Time != want[i] {
+			t.Fatalf("sample %d: expected %d, got %d", i, want[i], samples[i].Time)
+		}
+	}
+
+	// 再次投递同一批时全部视为已合并
+	if samples, _ := b.Filter(now, ms(-time.Minute)); len(samples) != 0 {
+		t.Fatalf("expected duplicate delivery to be ignored, got %d samples", len(samples))
+	}
+}
+
+func TestMergeStateSamples(t *testing.T) {
+	existing := []StateSample{{Time: 1000, CPU: 1}, {Time: 9000, CPU: 9}}
+	added := []StateSample{{Time: 3000, CPU: 3}, {Time: 4000, CPU: 4}, {Time: 6000, CPU: 6}, {Time: 9000, CPU: 90}}
+
+	got := MergeStateSamples(existing, added, 3000, 10)
+	want := []StateSample{{Time: 1000, CPU: 1}, {Time: 4000, CPU: 4}, {Time: 9000, CPU: 9}}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d samples, got %+v", len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("sample %d: expected %+v, got %+v", i, want[i], got[i])
+		}
+	}
+
+	if got := MergeStateSamples(existing, added, 1, 2); len(got) != 2 || got[0].Time != 6000 {
+		t.Fatalf("expected only the latest samples to be kept, got %+v", got)
+	}
+
+	custom := BackfillCustomMetrics([]BackfillSample{
+		{Time: 3000, State: HostState{CustomMetrics: map[string]float64{"queue": 3}}},
+		{Time: 6000, State: HostState{}},
+	})
+	if len(custom) != 1 || len(custom["queue"]) != 1 {
+		t.Fatalf("unexpected custom metrics %+v", custom)
+	}
+	merged := MergeCustomMetricSamples([]CustomMetricSample{{Time: 1000, Value: 1}}, custom["queue"], 1000, 10)
+	if len(merged) != 2 || merged[1] != (CustomMetricSample{Time: 3000, Value: 3}) {
+		t.Fatalf("unexpected merged custom metrics %+v", merged)
+	}
+}
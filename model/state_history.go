@@ -0,0 +1,91 @@
+package model
+
+import "time"
+
+// StateSample 服务器状态历史中的一个采样点，只保留绘图需要的数值，每个采样点占用的内存固定
+type StateSample struct {
+	Time         int64   `json:"time"` // 毫秒时间戳
+	CPU          float64 `json:"cpu"`
+	MemUsed      uint64  `json:"mem_used"`
+	SwapUsed     uint64  `json:"swap_used"`
+	DiskUsed     uint64  `json:"disk_used"`
+	NetInSpeed   uint64  `json:"net_in_speed"`
+	NetOutSpeed  uint64  `json:"net_out_speed"`
+	Load1        float64 `json:"load_1"`
+	TcpConnCount uint64  `json:"tcp_conn_count"`
+	UdpConnCount uint64  `json:"udp_conn_count"`
+	ProcessCount uint64  `json:"process_count"`
+}
+
+// ServerStateHourly 服务器状态的小时汇总，用于超出内存状态历史范围的统计
+type ServerStateHourly struct {
+	ID       uint64    `gorm:"primaryKey" json:"-"`
+	ServerID uint64    `gorm:"uniqueIndex:idx_server_state_hourly" json:"server_id"`
+	Start    time.Time `gorm:"uniqueIndex:idx_server_state_hourly" json:"start"`
+	CPU      float64   `json:"cpu"`     // 平均 CPU 占用百分比
+	Samples  uint64    `json:"samples"` // 参与平均的采样点数
+}
+
+func NewStateSample(t time.Time, s *HostState) StateSample {
+	return StateSample{
+		Time:         t.UnixMilli(),
+		CPU:          s.CPU,
+		MemUsed:      s.MemUsed,
+		SwapUsed:     s.SwapUsed,
+		DiskUsed:     s.DiskUsed,
+		NetInSpeed:   s.NetInSpeed,
+		NetOutSpeed:  s.NetOutSpeed,
+		Load1:        s.Load1,
+		TcpConnCount: s.TcpConnCount,
+		UdpConnCount: s.UdpConnCount,
+		ProcessCount: s.ProcessCount,
+	}
+}
+
+// DownsampleStateSamples 将按时间排序的采样点按数量均分为最多 points 组，每组取平均值，时间取组内最后一个采样点
+func DownsampleStateSamples(samples []StateSample, points int) []StateSample {
+	if points <= 0 || len(samples) <= points {
+		return samples
+	}
+
+	ret := make([]StateSample, 0, points)
+	for i := range points {
+		start, end := i*len(samples)/points, (i+1)*len(samples)/points
+		if start == end {
+			continue
+		}
+		ret = append(ret, averageStateSamples(samples[start:end]))
+	}
+	return ret
+}
+
+func averageStateSamples(group []StateSample) StateSample {
+	var cpu, load1 float64
+	var mem, swap, disk, in, out, tcp, udp, proc uint64
+	for _, s := range group {
+		cpu += s.CPU
+		load1 += s.Load1
+		mem += s.MemUsed
+		swap += s.SwapUsed
+		disk += s.DiskUsed
+		in += s.NetInSpeed
+		out += s.NetOutSpeed
+		tcp += s.TcpConnCount
+		udp += s.UdpConnCount
+		proc += s.ProcessCount
+	}
+	n := uint64(len(group))
+	return StateSample{
+		Time:         group[len(group)-1].Time,
+		CPU:          cpu / float64(n),
+		MemUsed:      mem / n,
+		SwapUsed:     swap / n,
+		DiskUsed:     disk / n,
+		NetInSpeed:   in / n,
+		NetOutSpeed:  out / n,
+		Load1:        load1 / float64(n),
+		TcpConnCount: tcp / n,
+		UdpConnCount: udp / n,
+		ProcessCount: proc / n,
+	}
+}
@@ -0,0 +1,30 @@
+package model
+
+import "testing"
+
+func TestDownsampleStateSamples(t *testing.T) {
+	samples := make([]StateSample, 10)
+	for i := range samples {
+		samples[i] = StateSample{Time: int64(i), CPU: float64(i), MemUsed: uint64(i * 10)}
+	}
+
+	if got := DownsampleStateSamples(samples, 20); len(got) != 10 {
+		t.Fatalf("expected samples to be kept as is, got %d points", len(got))
+	}
+
+	got := DownsampleStateSamples(samples, 3)
+	if len(got) != 3 {
+		t.Fatalf("expected 3 points, got %d", len(got))
+	}
+	// 分组为 [0,1,2] [3,4,5] [6,7,8,9]
+	exp := []StateSample{
+		{Time: 2, CPU: 1, MemUsed: 10},
+		{Time: 5, CPU: 4, MemUsed: 40},
+		{Time: 9, CPU: 7.5, MemUsed: 75},
+	}
+	for i := range exp {
+		if got[i] != exp[i] {
+			t.Fatalf("point %d: expected %+v, got %+v", i, exp[i], got[i])
+		}
+	}
+}
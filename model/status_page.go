@@ -0,0 +1,110 @@
+package model
+
+import (
+	"log"
+	"time"
+
+	"github.com/goccy/go-json"
+	"gorm.io/gorm"
+)
+
+const (
+	IncidentSeverityMinor = iota
+	IncidentSeverityMajor
+	IncidentSeverityCritical
+	IncidentSeverityMaintenance
+)
+
+const (
+	IncidentStatusInvestigating = "investigating"
+	IncidentStatusIdentified    = "identified"
+	IncidentStatusMonitoring    = "monitoring"
+	IncidentStatusResolved      = "resolved"
+)
+
+// StatusPageSection 公开状态页中的分区，仅展示被显式加入的服务与服务器分组
+type StatusPageSection struct {
+	Common
+
+	Name            string `json:"name"`
+	DisplayIndex    int    `json:"display_index"` // 展示排序，越大越靠前
+	ServicesRaw     string `gorm:"default:'[]'" json:"-"`
+	ServerGroupsRaw string `gorm:"default:'[]'" json:"-"`
+
+	Services     []uint64 `gorm:"-" json:"services"`
+	ServerGroups []uint64 `gorm:"-" json:"server_groups"`
+}
+
+func (s *StatusPageSection) BeforeSave(tx *gorm.DB) error {
+	if data, err := json.Marshal(s.Services); err != nil {
+		return err
+	} else {
+		s.ServicesRaw = string(data)
+	}
+	if data, err := json.Marshal(s.ServerGroups); err != nil {
+		return err
+	} else {
+		s.ServerGroupsRaw = string(data)
+	}
+	return nil
+}
+
+func (s *StatusPageSection) AfterFind(tx *gorm.DB) error {
+	if err := json.Unmarshal([]byte(s.ServicesRaw), &s.Services); err != nil {
+		log.Println("NEZHA>> StatusPageSection.AfterFind:", err)
+	}
+	if err := json.Unmarshal([]byte(s.ServerGroupsRaw), &s.ServerGroups); err != nil {
+		log.Println("NEZHA>> StatusPageSection.AfterFind:", err)
+	}
+	return nil
+}
+
+// Incident 状态页事件公告
+type Incident struct {
+	Common
+
+	Title           string     `json:"title"`
+	Body            string     `json:"body"`
+	Severity        uint8      `json:"severity"`
+	Status          string     `json:"status"`
+	ResolvedAt      *time.Time `json:"resolved_at,omitempty"`
+	ServicesRaw     string     `gorm:"default:'[]'" json:"-"`
+	ServerGroupsRaw string     `gorm:"default:'[]'" json:"-"`
+
+	Services     []uint64         `gorm:"-" json:"services"`      // 受影响的服务
+	ServerGroups []uint64         `gorm:"-" json:"server_groups"` // 受影响的服务器分组
+	Updates      []IncidentUpdate `gorm:"-" json:"updates,omitempty"`
+}
+
+func (i *Incident) BeforeSave(tx *gorm.DB) error {
+	if data, err := json.Marshal(i.Services); err != nil {
+		return err
+	} else {
+		i.ServicesRaw = string(data)
+	}
+	if data, err := json.Marshal(i.ServerGroups); err != nil {
+		return err
+	} else {
+		i.ServerGroupsRaw = string(data)
+	}
+	return nil
+}
+
+func (i *Incident) AfterFind(tx *gorm.DB) error {
+	if err := json.Unmarshal([]byte(i.ServicesRaw), &i.Services); err != nil {
+		log.Println("NEZHA>> Incident.AfterFind:", err)
+	}
+	if err := json.Unmarshal([]byte(i.ServerGroupsRaw), &i.ServerGroups); err != nil {
+		log.Println("NEZHA>> Incident.AfterFind:", err)
+	}
+	return nil
+}
+
+// IncidentUpdate 事件时间线上的进展更新
+type IncidentUpdate struct {
+	ID         uint64    `gorm:"primaryKey" json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	IncidentID uint64    `gorm:"index" json:"incident_id"`
+	Status     string    `json:"status"`
+	Body       string    `json:"body"`
+}
@@ -0,0 +1,60 @@
+package model
+
+import "time"
+
+type StatusPageSectionForm struct {
+	Name         string   `json:"name" minLength:"1"`
+	DisplayIndex int      `json:"display_index" default:"0"`
+	Services     []uint64 `json:"services" validate:"optional"`
+	ServerGroups []uint64 `json:"server_groups" validate:"optional"`
+}
+
+type IncidentForm struct {
+	Title        string   `json:"title" minLength:"1"`
+	Body         string   `json:"body" validate:"optional"`
+	Severity     uint8    `json:"severity" default:"0"`
+	Status       string   `json:"status" validate:"optional"`
+	Services     []uint64 `json:"services" validate:"optional"`
+	ServerGroups []uint64 `json:"server_groups" validate:"optional"`
+}
+
+type IncidentUpdateForm struct {
+	Status string `json:"status" minLength:"1"`
+	Body   string `json:"body" validate:"optional"`
+}
+
+type StatusPageUptimeBar struct {
+	Date   time.Time `json:"date"`
+	Up     uint64    `json:"up"`
+	Down   uint64    `json:"down"`
+	Uptime float32   `json:"uptime"` // 无数据时为 -1
+}
+
+type StatusPageService struct {
+	ID     uint64                `json:"id"`
+	Name   string                `json:"name"`
+	Status uint8                 `json:"status"`
+	Uptime float32               `json:"uptime"`
+	Bars   []StatusPageUptimeBar `json:"bars"`
+}
+
+type StatusPageServerGroup struct {
+	ID           uint64 `json:"id"`
+	Name         string `json:"name"`
+	Total        int    `json:"total"` // 不包含计划关机的服务器
+	Online       int    `json:"online"`
+	ScheduledOff int    `json:"scheduled_off"`
+}
+
+type StatusPageSectionItem struct {
+	Name         string                  `json:"name"`
+	Services     []StatusPageService     `json:"services"`
+	ServerGroups []StatusPageServerGroup `json:"server_groups"`
+}
+
+type StatusPageResponse struct {
+	SiteName  string                  `json:"site_name"`
+	Sections  []StatusPageSectionItem `json:"sections"`
+	Incidents []*Incident             `json:"incidents"`
+	UpdatedAt time.Time               `json:"updated_at"`
+}
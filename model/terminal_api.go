@@ -9,4 +9,5 @@ type CreateTerminalResponse struct {
 	SessionID  string `json:"session_id,omitempty"`
 	ServerID   uint64 `json:"server_id,omitempty"`
 	ServerName string `json:"server_name,omitempty"`
+	Recording  bool   `json:"recording,omitempty"` // 会话输出将被录制
 }
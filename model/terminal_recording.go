@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// TerminalRecording Web 终端会话的录像，文件为 asciicast v2 格式，只包含终端的输出
+type TerminalRecording struct {
+	ID         uint64    `gorm:"primaryKey" json:"id"`
+	CreatedAt  time.Time `gorm:"index" json:"created_at"`
+	UserID     uint64    `gorm:"index" json:"user_id"`
+	ServerID   uint64    `gorm:"index" json:"server_id"`
+	ServerName string    `json:"server_name"`
+	Duration   uint64    `json:"duration"`            // 会话时长（秒），会话结束时写入
+	Size       int64     `json:"size"`                // 录像文件大小（字节）
+	Truncated  bool      `json:"truncated,omitempty"` // 超出大小上限后停止了录制
+}
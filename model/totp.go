@@ -0,0 +1,77 @@
+package model
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/goccy/go-json"
+
+	"github.com/nezhahq/nezha/pkg/utils"
+)
+
+// 每次生成的恢复码数量
+const RecoveryCodeCount = 10
+
+type TOTPEnrollResponse struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+type TOTPCodeForm struct {
+	Code string `json:"code" minLength:"6"` // 验证码或恢复码
+}
+
+type TOTPActivateResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+// GenerateRecoveryCodes 生成新的恢复码并替换原有的恢复码，仅保存哈希
+func (u *User) GenerateRecoveryCodes() ([]string, error) {
+	codes := make([]string, RecoveryCodeCount)
+	hashes := make([]string, RecoveryCodeCount)
+	for i := range codes {
+		code, err := utils.GenerateRandomString(10)
+		if err != nil {
+			return nil, err
+		}
+		code = strings.ToLower(code)
+		codes[i] = code[:5] + "-" + code[5:]
+		hashes[i] = HashAPIToken(normalizeRecoveryCode(code))
+	}
+	data, err := json.Marshal(hashes)
+	if err != nil {
+		return nil, err
+	}
+	u.RecoveryCodesRaw = string(data)
+	return codes, nil
+}
+
+// UseRecoveryCode 校验恢复码，匹配时将其移除
+func (u *User) UseRecoveryCode(code string) bool {
+	var hashes []string
+	if u.RecoveryCodesRaw == "" || json.Unmarshal([]byte(u.RecoveryCodesRaw), &hashes) != nil {
+		return false
+	}
+	i := slices.Index(hashes, HashAPIToken(normalizeRecoveryCode(code)))
+	if i < 0 {
+		return false
+	}
+	data, err := json.Marshal(slices.Delete(hashes, i, i+1))
+	if err != nil {
+		return false
+	}
+	u.RecoveryCodesRaw = string(data)
+	return true
+}
+
+// ResetTOTP 关闭两步验证并清除密钥与恢复码
+func (u *User) ResetTOTP() {
+	u.TOTPEnabled = false
+	u.TOTPSecret = ""
+	u.TOTPLastStep = 0
+	u.RecoveryCodesRaw = ""
+}
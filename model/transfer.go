@@ -1,8 +1,154 @@
 package model
 
+import (
+	"sync"
+	"time"
+)
+
+const (
+	TrafficGranularityDaily   = "daily"
+	TrafficGranularityMonthly = "monthly"
+)
+
 type Transfer struct {
 	Common
 	ServerID uint64 `json:"server_id"`
 	In       uint64 `json:"in"`
 	Out      uint64 `json:"out"`
 }
+
+// TransferDaily 每日流量汇总，Interface 为空时表示服务器总流量
+type TransferDaily struct {
+	ID        uint64    `gorm:"primaryKey" json:"-"`
+	ServerID  uint64    `gorm:"uniqueIndex:idx_transfer_daily" json:"server_id"`
+	Date      time.Time `gorm:"uniqueIndex:idx_transfer_daily" json:"date"`
+	Interface string    `gorm:"uniqueIndex:idx_transfer_daily" json:"interface,omitempty"`
+	In        uint64    `json:"in"`
+	Out       uint64    `json:"out"`
+}
+
+// NetInterfaceTransfer Agent 上报的单网卡累计流量
+type NetInterfaceTransfer struct {
+	Name string `json:"name"`
+	In   uint64 `json:"in"`
+	Out  uint64 `json:"out"`
+}
+
+// TransferBaseline 最近一次入库时的累计计数器，面板重启后据此补上停机期间的流量
+type TransferBaseline struct {
+	ServerID  uint64    `gorm:"primaryKey" json:"server_id"`
+	BootTime  uint64    `json:"boot_time"`
+	In        uint64    `json:"in"`
+	Out       uint64    `json:"out"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TransferAnomaly 超出线路速率的增量，已按上限截断
+type TransferAnomaly struct {
+	Direction string // in 或 out
+	Delta     uint64 // 原始增量
+	Limit     uint64 // 截断后的增量
+}
+
+// TransferCounter 根据 Agent 上报的单向累计计数器计算尚未入库的增量
+type TransferCounter struct {
+	Last    uint64    // 上次上报的计数器值
+	LastAt  time.Time // 为零时下次上报仅作为基准
+	Pending uint64    // 尚未入库的增量
+}
+
+// Observe 累加本次上报的增量，早于上次上报的采样被忽略。计数器变小说明 Agent 重启或计数器溢出，此时当前值即为增量；
+// maxRate（字节/秒）大于 0 时增量不超过 maxRate 与间隔秒数之积，超出时按上限截断并返回原始增量
+func (c *TransferCounter) Observe(cur uint64, at time.Time, maxRate uint64) (delta, raw uint64, clamped bool) {
+	if c.LastAt.IsZero() {
+		c.Last, c.LastAt = cur, at
+		return 0, 0, false
+	}
+	// 补报的采样早于已处理的上报时，期间的流量已计入
+	if !at.After(c.LastAt) {
+		return 0, 0, false
+	}
+
+	if cur < c.Last {
+		delta = cur
+	} else {
+		delta = cur - c.Last
+	}
+	raw = delta
+	if maxRate > 0 {
+		seconds := uint64(max(at.Sub(c.LastAt).Seconds(), 1))
+		if limit := maxRate * seconds; limit/seconds == maxRate && delta > limit {
+			delta, clamped = limit, true
+		}
+	}
+
+	c.Last, c.LastAt = cur, at
+	c.Pending += delta
+	return delta, raw, clamped
+}
+
+// ServerTransfer 服务器入站、出站计数器，在上报与定时入库间共享
+type ServerTransfer struct {
+	mu  sync.Mutex
+	in  TransferCounter
+	out TransferCounter
+}
+
+// Observe 处理一次状态上报，返回被截断的增量
+func (t *ServerTransfer) Observe(in, out uint64, at time.Time, maxRate uint64) []TransferAnomaly {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var anomalies []TransferAnomaly
+	if delta, raw, clamped := t.in.Observe(in, at, maxRate); clamped {
+		anomalies = append(anomalies, TransferAnomaly{Direction: "in", Delta: raw, Limit: delta})
+	}
+	if delta, raw, clamped := t.out.Observe(out, at, maxRate); clamped {
+		anomalies = append(anomalies, TransferAnomaly{Direction: "out", Delta: raw, Limit: delta})
+	}
+	return anomalies
+}
+
+// Restore 以持久化的基准作为上次上报。bootTime 与基准不同时说明期间服务器重启过，计数器从零开始计算；
+// 任一开机时间未知（为 0）时不作判断
+func (t *ServerTransfer) Restore(b TransferBaseline, bootTime uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.in.LastAt.IsZero() || b.UpdatedAt.IsZero() {
+		return
+	}
+	t.in.Last, t.in.LastAt = b.In, b.UpdatedAt
+	t.out.Last, t.out.LastAt = b.Out, b.UpdatedAt
+	if bootTime != 0 && b.BootTime != 0 && bootTime != b.BootTime {
+		t.in.Last, t.out.Last = 0, 0
+	}
+}
+
+// Started 是否已有上次上报的计数器
+func (t *ServerTransfer) Started() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return !t.in.LastAt.IsZero()
+}
+
+// Pending 尚未入库的流量
+func (t *ServerTransfer) Pending() (in, out uint64) {
+	if t == nil {
+		return 0, 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.in.Pending, t.out.Pending
+}
+
+// Take 取出尚未入库的流量并清零，同时返回当前计数器作为新的基准
+func (t *ServerTransfer) Take() (in, out uint64, baseline TransferBaseline) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	in, out = t.in.Pending, t.out.Pending
+	t.in.Pending, t.out.Pending = 0, 0
+	baseline = TransferBaseline{In: t.in.Last, Out: t.out.Last, UpdatedAt: t.in.LastAt}
+	return in, out, baseline
+}
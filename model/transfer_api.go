@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+type TrafficDataPoint struct {
+	Date time.Time `json:"date"`
+	In   uint64    `json:"in"`
+	Out  uint64    `json:"out"`
+}
+
+type ServerTrafficResponse struct {
+	ServerID    uint64                        `json:"server_id"`
+	Granularity string                        `json:"granularity"`
+	Total       []TrafficDataPoint            `json:"total"`
+	Interfaces  map[string][]TrafficDataPoint `json:"interfaces,omitempty"`
+}
@@ -0,0 +1,95 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTransferCounter(t *testing.T) {
+	start := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	const rate = 1000 // 字节/秒
+
+	cases := []struct {
+		name    string
+		reports []uint64
+		gaps    []time.Duration // 与上次上报的间隔，为空时均为 2 秒
+		want    uint64
+		clamped int
+	}{
+		{name: "steady", reports: []uint64{100, 600, 1100, 1600}, want: 1500},
+		// Agent 重启后计数器从零开始
+		{name: "restart", reports: []uint64{5000, 6000, 300, 900}, want: 1000 + 300 + 600},
+		// 32 位计数器溢出
+		{name: "wrap", reports: []uint64{1<<32 - 500, 1<<32 - 100, 200, 1200}, want: 400 + 200 + 1000},
+		// 漏报期间计数器持续增长，按间隔放宽上限
+		{name: "missed reports", reports: []uint64{0, 1000, 61000}, gaps: []time.Duration{0, 2 * time.Second, 60 * time.Second}, want: 61000},
+		// 超出线路速率的增量按上限截断
+		{name: "spike", reports: []uint64{0, 1000, 1 << 40, 1<<40 + 500}, want: 1000 + 2000 + 500, clamped: 1},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var counter TransferCounter
+			at, clamped := start, 0
+			for i, v := range c.reports {
+				gap := 2 * time.Second
+				if c.gaps != nil {
+					gap = c.gaps[i]
+				}
+				at = at.Add(gap)
+				if _, _, ok := counter.Observe(v, at, rate); ok {
+					clamped++
+				}
+			}
+			if counter.Pending != c.want || clamped != c.clamped {
+				t.Fatalf("pending = %d, clamped = %d, want %d, %d", counter.Pending, clamped, c.want, c.clamped)
+			}
+		})
+	}
+}
+
+func TestServerTransferRestore(t *testing.T) {
+	saved := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	b := TransferBaseline{ServerID: 1, BootTime: 100, In: 10000, Out: 20000, UpdatedAt: saved}
+
+	// 面板重启期间服务器未重启，补上停机期间的流量
+	var st ServerTransfer
+	st.Restore(b, 100)
+	st.Observe(15000, 26000, saved.Add(time.Minute), 0)
+	if in, out := st.Pending(); in != 5000 || out != 6000 {
+		t.Fatalf("unexpected pending after restore: %d, %d", in, out)
+	}
+	in, out, baseline := st.Take()
+	if in != 5000 || out != 6000 || baseline.In != 15000 || baseline.Out != 26000 {
+		t.Fatalf("unexpected take result: %d, %d, %+v", in, out, baseline)
+	}
+	if in, out := st.Pending(); in != 0 || out != 0 {
+		t.Fatalf("pending should be cleared after take: %d, %d", in, out)
+	}
+
+	// 服务器重启过，计数器即使大于基准也视为从零开始
+	st = ServerTransfer{}
+	st.Restore(b, 200)
+	st.Observe(30000, 40000, saved.Add(time.Hour), 0)
+	if in, out := st.Pending(); in != 30000 || out != 40000 {
+		t.Fatalf("unexpected pending after reboot: %d, %d", in, out)
+	}
+
+	// 开机时间未知时不视为重启
+	st = ServerTransfer{}
+	st.Restore(b, 0)
+	st.Observe(15000, 26000, saved.Add(time.Minute), 0)
+	if in, out := st.Pending(); in != 5000 || out != 6000 {
+		t.Fatalf("unexpected pending with unknown boot time: %d, %d", in, out)
+	}
+
+	// 没有基准时首次上报仅作为基准，之后计数器变小时才视为从零开始
+	st = ServerTransfer{}
+	st.Observe(30000, 40000, saved, 0)
+	if in, out := st.Pending(); in != 0 || out != 0 {
+		t.Fatalf("first report should only set the baseline: %d, %d", in, out)
+	}
+	st.Observe(30100, 100, saved.Add(time.Second), 0)
+	if in, out := st.Pending(); in != 100 || out != 100 {
+		t.Fatalf("unexpected pending after counter reset: %d, %d", in, out)
+	}
+}
@@ -1,8 +1,10 @@
 package model
 
 import (
+	"log"
 	"time"
 
+	"github.com/goccy/go-json"
 	"github.com/gorilla/websocket"
 	"github.com/nezhahq/nezha/pkg/utils"
 	"gorm.io/gorm"
@@ -11,8 +13,13 @@ import (
 const (
 	RoleAdmin uint8 = iota
 	RoleMember
+	RoleViewer // 只读用户，可以查看全部数据但不能修改
 )
 
+func ValidRole(role uint8) bool {
+	return role <= RoleViewer
+}
+
 const DefaultAgentSecretLength = 32
 
 type User struct {
@@ -22,14 +29,33 @@ type User struct {
 	Role           uint8  `json:"role,omitempty"`
 	AgentSecret    string `json:"agent_secret,omitempty" gorm:"type:char(32)"`
 	RejectPassword bool   `json:"reject_password,omitempty"`
+	Language       string `json:"language,omitempty"`                                                                     // 接口错误信息使用的语言，为空时按 Accept-Language 协商
+	PublicSlug     string `json:"public_slug,omitempty" gorm:"uniqueIndex:idx_users_public_slug,where:public_slug <> ''"` // 严格租户模式下访客页面的地址标识
+
+	DisplayPreferencesRaw string              `gorm:"default:'{}'" json:"-"`
+	DisplayPreferences    *DisplayPreferences `gorm:"-" json:"display_preferences,omitempty"` // 为空时使用站点的默认展示偏好
+
+	TOTPEnabled      bool   `json:"totp_enabled,omitempty"`
+	TOTPSecret       string `json:"-" gorm:"serializer:secret"`
+	TOTPLastStep     int64  `json:"-"` // 最近一次使用的验证码周期，防止重放
+	RecoveryCodesRaw string `json:"-"`
 }
 
 type UserInfo struct {
 	Role        uint8
 	AgentSecret string
+	PublicSlug  string
 }
 
 func (u *User) BeforeSave(tx *gorm.DB) error {
+	if u.DisplayPreferences == nil {
+		u.DisplayPreferencesRaw = "{}"
+	} else if data, err := json.Marshal(u.DisplayPreferences); err != nil {
+		return err
+	} else {
+		u.DisplayPreferencesRaw = string(data)
+	}
+
 	if u.AgentSecret != "" {
 		return nil
 	}
@@ -43,10 +69,23 @@ func (u *User) BeforeSave(tx *gorm.DB) error {
 	return nil
 }
 
+func (u *User) AfterFind(tx *gorm.DB) error {
+	if u.DisplayPreferencesRaw != "" && u.DisplayPreferencesRaw != "{}" {
+		u.DisplayPreferences = new(DisplayPreferences)
+		if err := json.Unmarshal([]byte(u.DisplayPreferencesRaw), u.DisplayPreferences); err != nil {
+			log.Println("NEZHA>> User.AfterFind:", err)
+			u.DisplayPreferences = nil
+		}
+	}
+	return nil
+}
+
 type Profile struct {
 	User
 	LoginIP    string            `json:"login_ip,omitempty"`
 	Oauth2Bind map[string]string `json:"oauth2_bind,omitempty"`
+
+	Display DisplayPreferences `json:"display"` // 实际生效的展示偏好
 }
 
 type OnlineUser struct {
@@ -6,9 +6,17 @@ type UserForm struct {
 	Password string `json:"password,omitempty" gorm:"type:char(72)"`
 }
 
+type UserRoleForm struct {
+	Role uint8 `json:"role"`
+}
+
 type ProfileForm struct {
 	OriginalPassword string `json:"original_password,omitempty"`
 	NewUsername      string `json:"new_username,omitempty"`
 	NewPassword      string `json:"new_password,omitempty"`
 	RejectPassword   bool   `json:"reject_password,omitempty" validate:"optional"`
+	Language         string `json:"language,omitempty" validate:"optional"`
+	PublicSlug       string `json:"public_slug,omitempty" validate:"optional"`
+
+	DisplayPreferences *DisplayPreferences `json:"display_preferences,omitempty" validate:"optional"` // 为空时恢复为站点的默认展示偏好
 }
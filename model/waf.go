@@ -2,7 +2,8 @@ package model
 
 import (
 	"errors"
-	"math/big"
+	"net/netip"
+	"slices"
 	"time"
 
 	"github.com/nezhahq/nezha/pkg/utils"
@@ -16,6 +17,9 @@ const (
 	WAFBlockReasonTypeAgentAuthFail
 	WAFBlockReasonTypeManual
 	WAFBlockReasonTypeBruteForceOauth2
+	WAFBlockReasonTypeNATDenied
+	WAFBlockReasonTypeBruteForceOTP
+	WAFBlockReasonTypeRateLimit
 )
 
 const (
@@ -23,6 +27,8 @@ const (
 	BlockIDToken
 	BlockIDUnknownUser
 	BlockIDManual
+	BlockIDNAT
+	BlockIDRateLimit
 )
 
 type WAFApiMock struct {
@@ -31,6 +37,7 @@ type WAFApiMock struct {
 	BlockReason     uint8  `json:"block_reason,omitempty"`
 	BlockTimestamp  uint64 `json:"block_timestamp,omitempty"`
 	Count           uint64 `json:"count,omitempty"`
+	ExpiresAt       uint64 `json:"expires_at,omitempty"`
 }
 
 type WAF struct {
@@ -39,12 +46,83 @@ type WAF struct {
 	BlockReason     uint8  `json:"block_reason,omitempty"`
 	BlockTimestamp  uint64 `gorm:"index" json:"block_timestamp,omitempty"`
 	Count           uint64 `json:"count,omitempty"`
+	ExpiresAt       uint64 `gorm:"index" json:"expires_at,omitempty"` // 封禁结束的时间戳，0 为永久
 }
 
 func (w *WAF) TableName() string {
 	return "nz_waf"
 }
 
+// WAFBlock 管理员手动添加的封禁，支持 CIDR 与过期时间
+type WAFBlock struct {
+	ID         uint64     `gorm:"primaryKey" json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UserID     uint64     `json:"user_id"`
+	CIDR       string     `json:"cidr"`
+	RangeStart []byte     `gorm:"type:binary(16);index" json:"-"`
+	RangeEnd   []byte     `gorm:"type:binary(16)" json:"-"`
+	Note       string     `json:"note,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+}
+
+func (b *WAFBlock) Active(now time.Time) bool {
+	return b.ExpiresAt == nil || b.ExpiresAt.After(now)
+}
+
+type WAFBlockForm struct {
+	CIDR      string     `json:"cidr" minLength:"1"` // IP 或 CIDR
+	Note      string     `json:"note,omitempty" validate:"optional"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty" validate:"optional"` // 为空时永久封禁
+}
+
+// WAFBlockEntry 封禁列表中的一项，手动封禁有 ID 与 CIDR，自动封禁为单个 IP
+type WAFBlockEntry struct {
+	ID              uint64     `json:"id,omitempty"`
+	IP              string     `json:"ip"`
+	Manual          bool       `json:"manual"`
+	BlockIdentifier int64      `json:"block_identifier,omitempty"`
+	BlockReason     uint8      `json:"block_reason"`
+	Count           uint64     `json:"count,omitempty"`
+	Note            string     `json:"note,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	ExpiresAt       *time.Time `json:"expires_at,omitempty"`
+	Active          bool       `json:"active"`
+}
+
+// ParseBlockRange 解析 IP 或 CIDR，返回规范化的 CIDR 与地址范围
+func ParseBlockRange(s string) (string, []byte, []byte, error) {
+	prefix, err := netip.ParsePrefix(s)
+	if err != nil {
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return "", nil, nil, err
+		}
+		prefix = netip.PrefixFrom(addr, addr.BitLen())
+	}
+	prefix = prefix.Masked()
+	start, end := prefixRange(prefix)
+	s16, e16 := start.As16(), end.As16()
+	return prefix.String(), s16[:], e16[:], nil
+}
+
+// prefixRange 返回 CIDR 的第一个与最后一个地址
+func prefixRange(prefix netip.Prefix) (netip.Addr, netip.Addr) {
+	prefix = prefix.Masked()
+	first := prefix.Addr().AsSlice()
+	last := slices.Clone(first)
+	for i := prefix.Bits(); i < len(last)*8; i++ {
+		last[i/8] |= 0x80 >> (i % 8)
+	}
+	start, _ := netip.AddrFromSlice(first)
+	end, _ := netip.AddrFromSlice(last)
+	return start, end
+}
+
+// Active 封禁是否仍然生效，过期后记录保留一段时间用于累计违规次数
+func (w *WAF) Active(now time.Time) bool {
+	return w.ExpiresAt == 0 || w.ExpiresAt > uint64(now.Unix())
+}
+
 func CheckIP(db *gorm.DB, ip string) error {
 	if ip == "" {
 		return nil
@@ -54,24 +132,21 @@ func CheckIP(db *gorm.DB, ip string) error {
 		return err
 	}
 
-	var blockTimestamp uint64
-	result := db.Model(&WAF{}).Order("block_timestamp desc").Select("block_timestamp").Where("ip = ?", ipBinary).Limit(1).Find(&blockTimestamp)
-	if result.Error != nil {
-		return result.Error
+	var manual int64
+	if err := db.Model(&WAFBlock{}).Where("range_start <= ? AND range_end >= ? AND (expires_at IS NULL OR expires_at > ?)",
+		ipBinary, ipBinary, time.Now()).Count(&manual).Error; err != nil {
+		return err
 	}
-
-	// 检查是否未找到记录
-	if result.RowsAffected < 1 {
-		return nil
+	if manual > 0 {
+		return errors.New("you were blocked by nezha WAF")
 	}
 
-	var count uint64
-	if err := db.Model(&WAF{}).Select("SUM(count)").Where("ip = ?", ipBinary).Scan(&count).Error; err != nil {
+	var blocked int64
+	if err := db.Model(&WAF{}).Where("ip = ? AND (expires_at = 0 OR expires_at > ?)", ipBinary, time.Now().Unix()).
+		Count(&blocked).Error; err != nil {
 		return err
 	}
-
-	now := time.Now().Unix()
-	if powAdd(count, 4, blockTimestamp) > uint64(now) {
+	if blocked > 0 {
 		return errors.New("you were blocked by nezha WAF")
 	}
 	return nil
@@ -124,26 +199,85 @@ func BlockIP(db *gorm.DB, ip string, reason uint8, uid int64) error {
 		count = gorm.Expr("count + 1")
 	}
 
-	return db.Transaction(func(tx *gorm.DB) error {
-		if err := tx.Where(&w).Attrs(WAF{
+	var newlyBlocked bool
+	err = db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where(&w).Attrs(WAF{
 			BlockReason:    reason,
 			BlockTimestamp: now,
-		}).FirstOrCreate(&w).Error; err != nil {
+		}).FirstOrCreate(&w)
+		if result.Error != nil {
+			return result.Error
+		}
+		// 新建的记录，或之前的封禁已过期
+		newlyBlocked = result.RowsAffected > 0 || !w.Active(time.Unix(int64(now), 0))
+		if err := tx.Exec("UPDATE nz_waf SET count = ?, block_reason = ?, block_timestamp = ? WHERE ip = ? and block_identifier = ?", count, reason, now, ipBinary, uid).Error; err != nil {
 			return err
 		}
-		return tx.Exec("UPDATE nz_waf SET count = ?, block_reason = ?, block_timestamp = ? WHERE ip = ? and block_identifier = ?", count, reason, now, ipBinary, uid).Error
+
+		// 手动封禁不会过期
+		var expiresAt uint64
+		if reason != WAFBlockReasonTypeManual {
+			var offenses uint64
+			if err := tx.Model(&WAF{}).Select("count").Where("ip = ? and block_identifier = ?", ipBinary, uid).Scan(&offenses).Error; err != nil {
+				return err
+			}
+			expiresAt = now + BlockPolicy.Duration(reason, offenses)
+		}
+		return tx.Exec("UPDATE nz_waf SET expires_at = ? WHERE ip = ? and block_identifier = ?", expiresAt, ipBinary, uid).Error
 	})
+	if err == nil && newlyBlocked && OnIPBlocked != nil {
+		OnIPBlocked(ip, reason)
+	}
+	return err
 }
 
-func powAdd(x, y, z uint64) uint64 {
-	base := big.NewInt(0).SetUint64(x)
-	exp := big.NewInt(0).SetUint64(y)
-	result := big.NewInt(1)
-	result.Exp(base, exp, nil)
-	result.Add(result, big.NewInt(0).SetUint64(z))
-	if !result.IsUint64() {
-		return ^uint64(0) // return max uint64 value on overflow
+// OnIPBlocked 在 IP 被新封禁时调用，用于发送通知
+var OnIPBlocked func(ip string, reason uint8)
+
+// WAFBlockPolicy 自动封禁的时长：首次封禁 Base 秒，之后每次违规翻倍，最长 Max 秒
+type WAFBlockPolicy struct {
+	Base map[uint8]uint64
+	Max  uint64
+}
+
+const defaultWAFBlockBase = 10
+
+// BlockPolicy 当前的封禁策略，启动时按配置设置
+var BlockPolicy = WAFBlockPolicy{Max: 86400}
+
+// WAFBlockReasons 配置文件中使用的封禁原因名称
+var WAFBlockReasons = map[string]uint8{
+	"login_fail":         WAFBlockReasonTypeLoginFail,
+	"brute_force_token":  WAFBlockReasonTypeBruteForceToken,
+	"agent_auth_fail":    WAFBlockReasonTypeAgentAuthFail,
+	"brute_force_oauth2": WAFBlockReasonTypeBruteForceOauth2,
+	"nat_denied":         WAFBlockReasonTypeNATDenied,
+	"brute_force_otp":    WAFBlockReasonTypeBruteForceOTP,
+	"rate_limit":         WAFBlockReasonTypeRateLimit,
+}
+
+// WAFBlockReasonName 返回封禁原因在配置文件中使用的名称
+func WAFBlockReasonName(reason uint8) string {
+	for name, r := range WAFBlockReasons {
+		if r == reason {
+			return name
+		}
+	}
+	if reason == WAFBlockReasonTypeManual {
+		return "manual"
+	}
+	return "unknown"
+}
+
+// Duration 返回第 offenses 次违规的封禁秒数
+func (p *WAFBlockPolicy) Duration(reason uint8, offenses uint64) uint64 {
+	base := p.Base[reason]
+	if base == 0 {
+		base = defaultWAFBlockBase
+	}
+	d := base
+	for i := uint64(1); i < offenses && d < p.Max; i++ {
+		d *= 2
 	}
-	ret := result.Uint64()
-	return utils.IfOr(ret < z+3, z+3, ret)
+	return min(d, p.Max)
 }
@@ -0,0 +1,172 @@
+package model
+
+import (
+	"cmp"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/goccy/go-json"
+	"gorm.io/gorm"
+)
+
+const (
+	WAFRuleAllow = "allow"
+	WAFRuleDeny  = "deny"
+)
+
+const (
+	WAFSurfaceAll  uint8 = iota // 面板与 Agent 连接
+	WAFSurfaceHTTP              // 面板 HTTP
+	WAFSurfaceGRPC              // Agent gRPC
+)
+
+// WAFRule 按 CIDR 或国家放行、拦截请求
+// 命中任一 allow 规则时放行，否则命中 deny 规则时拦截；存在 allow 规则时未命中任何 allow 规则的请求也会被拦截
+type WAFRule struct {
+	Common
+	Name      string   `json:"name"`
+	Action    string   `json:"action"`
+	Surface   uint8    `json:"surface"`
+	Enabled   bool     `json:"enabled"`
+	CIDRs     []string `gorm:"-" json:"cidrs"`
+	Countries []string `gorm:"-" json:"countries"` // 小写的 ISO 3166 国家代码
+	Hits      uint64   `json:"hits"`
+
+	CIDRsRaw     string `json:"-"`
+	CountriesRaw string `json:"-"`
+}
+
+type WAFRuleForm struct {
+	Name      string   `json:"name" minLength:"1"`
+	Action    string   `json:"action" enums:"allow,deny"`
+	Surface   uint8    `json:"surface" enums:"0,1,2"` // 0 面板与 Agent，1 面板，2 Agent
+	Enabled   bool     `json:"enabled,omitempty" validate:"optional"`
+	CIDRs     []string `json:"cidrs,omitempty" validate:"optional"`
+	Countries []string `json:"countries,omitempty" validate:"optional"`
+}
+
+func (r *WAFRule) BeforeSave(tx *gorm.DB) error {
+	data, err := json.Marshal(r.CIDRs)
+	if err != nil {
+		return err
+	}
+	r.CIDRsRaw = string(data)
+	if data, err = json.Marshal(r.Countries); err != nil {
+		return err
+	}
+	r.CountriesRaw = string(data)
+	return nil
+}
+
+func (r *WAFRule) AfterFind(tx *gorm.DB) error {
+	if r.CIDRsRaw != "" {
+		if err := json.Unmarshal([]byte(r.CIDRsRaw), &r.CIDRs); err != nil {
+			return err
+		}
+	}
+	if r.CountriesRaw != "" {
+		return json.Unmarshal([]byte(r.CountriesRaw), &r.Countries)
+	}
+	return nil
+}
+
+// AppliesTo 规则是否作用于该入口
+func (r *WAFRule) AppliesTo(surface uint8) bool {
+	return r.Enabled && (r.Surface == WAFSurfaceAll || r.Surface == surface)
+}
+
+// NormalizeCountries 国家代码统一为小写并去重
+func NormalizeCountries(codes []string) []string {
+	out := make([]string, 0, len(codes))
+	for _, c := range codes {
+		c = strings.ToLower(strings.TrimSpace(c))
+		if c != "" && !slices.Contains(out, c) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// IPRangeSet 按地址区间查找规则，构建时将可能重叠的区间切分为互不重叠的片段，查找为二分搜索
+type IPRangeSet struct {
+	starts []netip.Addr
+	ends   []netip.Addr
+	values [][]uint64
+}
+
+type ipRange struct {
+	start, end netip.Addr
+	value      uint64
+}
+
+// IPRangeSetBuilder 收集区间后构建 IPRangeSet
+type IPRangeSetBuilder struct {
+	ranges []ipRange
+}
+
+// Add 添加一个 CIDR，IPv4 地址统一转换为 IPv4-mapped IPv6
+func (b *IPRangeSetBuilder) Add(prefix netip.Prefix, value uint64) {
+	start, end := prefixRange(prefix)
+	b.ranges = append(b.ranges, ipRange{
+		start: netip.AddrFrom16(start.As16()),
+		end:   netip.AddrFrom16(end.As16()),
+		value: value,
+	})
+}
+
+func (b *IPRangeSetBuilder) Build() *IPRangeSet {
+	set := &IPRangeSet{}
+	if len(b.ranges) == 0 {
+		return set
+	}
+
+	// 所有区间的边界，每两个相邻边界之间的片段被同一组区间覆盖
+	var bounds []netip.Addr
+	for _, r := range b.ranges {
+		bounds = append(bounds, r.start)
+		if next := r.end.Next(); next.IsValid() {
+			bounds = append(bounds, next)
+		}
+	}
+	slices.SortFunc(bounds, netip.Addr.Compare)
+	bounds = slices.Compact(bounds)
+
+	for i, start := range bounds {
+		var end netip.Addr
+		if i+1 < len(bounds) {
+			end = bounds[i+1].Prev()
+		} else {
+			end = netip.AddrFrom16([16]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+		}
+		var values []uint64
+		for _, r := range b.ranges {
+			if r.start.Compare(start) <= 0 && r.end.Compare(end) >= 0 {
+				values = append(values, r.value)
+			}
+		}
+		if len(values) == 0 {
+			continue
+		}
+		slices.Sort(values)
+		set.starts = append(set.starts, start)
+		set.ends = append(set.ends, end)
+		set.values = append(set.values, slices.Compact(values))
+	}
+	return set
+}
+
+// Lookup 返回包含该地址的所有区间的值
+func (s *IPRangeSet) Lookup(addr netip.Addr) []uint64 {
+	addr = netip.AddrFrom16(addr.As16())
+	i, found := slices.BinarySearchFunc(s.starts, addr, func(a, t netip.Addr) int {
+		return cmp.Compare(a.Compare(t), 0)
+	})
+	if !found {
+		i--
+	}
+	if i < 0 || s.ends[i].Compare(addr) < 0 {
+		return nil
+	}
+	return s.values[i]
+}
@@ -0,0 +1,87 @@
+package model
+
+import (
+	"net/netip"
+	"slices"
+	"testing"
+)
+
+func TestParseBlockRange(t *testing.T) {
+	cases := []struct {
+		in, cidr, start, end string
+	}{
+		{"192.0.2.7", "192.0.2.7/32", "192.0.2.7", "192.0.2.7"},
+		{"192.0.2.7/24", "192.0.2.0/24", "192.0.2.0", "192.0.2.255"},
+		{"2001:db8::1/48", "2001:db8::/48", "2001:db8::", "2001:db8:0:ffff:ffff:ffff:ffff:ffff"},
+	}
+	for _, c := range cases {
+		cidr, start, end, err := ParseBlockRange(c.in)
+		if err != nil {
+			t.Fatalf("%s: %v", c.in, err)
+		}
+		s, e := netip.AddrFrom16([16]byte(start)).Unmap(), netip.AddrFrom16([16]byte(end)).Unmap()
+		if cidr != c.cidr || s.String() != c.start || e.String() != c.end {
+			t.Fatalf("%s: got %s %s-%s", c.in, cidr, s, e)
+		}
+	}
+
+	if _, _, _, err := ParseBlockRange("not-an-ip"); err == nil {
+		t.Fatal("expected error for invalid input")
+	}
+}
+
+func TestIPRangeSet(t *testing.T) {
+	var b IPRangeSetBuilder
+	b.Add(netip.MustParsePrefix("10.0.0.0/8"), 1)
+	b.Add(netip.MustParsePrefix("10.1.0.0/16"), 2)
+	b.Add(netip.MustParsePrefix("2001:db8::/32"), 3)
+	b.Add(netip.MustParsePrefix("192.0.2.1/32"), 4)
+	set := b.Build()
+
+	cases := []struct {
+		ip   string
+		want []uint64
+	}{
+		{"10.0.0.1", []uint64{1}},
+		{"10.1.255.255", []uint64{1, 2}},
+		{"10.2.0.0", []uint64{1}},
+		{"10.255.255.255", []uint64{1}},
+		{"11.0.0.0", nil},
+		{"9.255.255.255", nil},
+		{"::ffff:10.1.0.1", []uint64{1, 2}},
+		{"2001:db8:ffff::1", []uint64{3}},
+		{"2001:db9::", nil},
+		{"192.0.2.1", []uint64{4}},
+		{"192.0.2.2", nil},
+	}
+	for _, c := range cases {
+		if got := set.Lookup(netip.MustParseAddr(c.ip)); !slices.Equal(got, c.want) {
+			t.Fatalf("%s: expected %v, got %v", c.ip, c.want, got)
+		}
+	}
+
+	if got := (&IPRangeSetBuilder{}).Build().Lookup(netip.MustParseAddr("10.0.0.1")); got != nil {
+		t.Fatalf("expected empty set, got %v", got)
+	}
+}
+
+func TestWAFBlockPolicy(t *testing.T) {
+	p := WAFBlockPolicy{Base: map[uint8]uint64{WAFBlockReasonTypeRateLimit: 60}, Max: 3600}
+	cases := []struct {
+		reason   uint8
+		offenses uint64
+		want     uint64
+	}{
+		{WAFBlockReasonTypeLoginFail, 1, defaultWAFBlockBase},
+		{WAFBlockReasonTypeLoginFail, 3, defaultWAFBlockBase * 4},
+		{WAFBlockReasonTypeRateLimit, 1, 60},
+		{WAFBlockReasonTypeRateLimit, 2, 120},
+		{WAFBlockReasonTypeRateLimit, 10, 3600},
+		{WAFBlockReasonTypeRateLimit, 1 << 40, 3600},
+	}
+	for _, c := range cases {
+		if got := p.Duration(c.reason, c.offenses); got != c.want {
+			t.Fatalf("reason %d offenses %d: expected %d, got %d", c.reason, c.offenses, c.want, got)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package cloudflare
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/libdns/libdns"
+
+	"github.com/nezhahq/nezha/pkg/utils"
+)
+
+const (
+	defaultEndpoint = "https://api.cloudflare.com/client/v4"
+	// Cloudflare 允许的最小 TTL，代理记录的 TTL 固定为 1（自动）
+	minTTL     = 60 * time.Second
+	proxiedTTL = 1
+	pageSize   = 5000
+)
+
+// Provider 通过 Cloudflare API 更新记录。libdns.Address 的 ProviderData 为 true 时开启代理
+type Provider struct {
+	APIToken string
+
+	endpoint string
+}
+
+type record struct {
+	ID      string `json:"id,omitempty"`
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Content string `json:"content"`
+	TTL     int    `json:"ttl"`
+	Proxied bool   `json:"proxied"`
+}
+
+type apiError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type response struct {
+	Success    bool            `json:"success"`
+	Errors     []apiError      `json:"errors"`
+	Result     json.RawMessage `json:"result"`
+	ResultInfo struct {
+		TotalPages int `json:"total_pages"`
+	} `json:"result_info"`
+}
+
+func (provider *Provider) SetRecords(ctx context.Context, zoneName string,
+	recs []libdns.Record) ([]libdns.Record, error) {
+	zoneName = strings.TrimSuffix(zoneName, ".")
+	zoneID, err := provider.zoneID(ctx, zoneName)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := provider.records(ctx, zoneID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 使用批量接口，每个区域最多一次请求
+	var posts, puts []record
+	for _, rec := range recs {
+		addr, ok := rec.(libdns.Address)
+		if !ok {
+			return nil, fmt.Errorf("unsupported record type: %T", rec)
+		}
+		r, existing := newRecord(zoneName, records, addr)
+		switch {
+		case existing == nil:
+			posts = append(posts, r)
+		case existing.Content != r.Content || existing.TTL != r.TTL || existing.Proxied != r.Proxied:
+			r.ID = existing.ID
+			puts = append(puts, r)
+		}
+	}
+	if len(posts) == 0 && len(puts) == 0 {
+		return recs, nil
+	}
+
+	body := map[string]any{}
+	if len(posts) > 0 {
+		body["posts"] = posts
+	}
+	if len(puts) > 0 {
+		body["puts"] = puts
+	}
+	if _, err := provider.call(ctx, http.MethodPost, "/zones/"+url.PathEscape(zoneID)+"/dns_records/batch", body, nil); err != nil {
+		return nil, fmt.Errorf("failed to update records in %s: %v", zoneName, err)
+	}
+	return recs, nil
+}
+
+// newRecord 转换为 Cloudflare 的记录，并查找名称与类型相同的现有记录
+func newRecord(zoneName string, records []record, addr libdns.Address) (record, *record) {
+	rr := addr.RR()
+	name := zoneName
+	if rr.Name != "" && rr.Name != "@" {
+		name = rr.Name + "." + zoneName
+	}
+	r := record{
+		Type:    rr.Type,
+		Name:    name,
+		Content: rr.Data,
+		TTL:     int(max(rr.TTL, minTTL).Seconds()),
+	}
+	if proxied, _ := addr.ProviderData.(bool); proxied {
+		r.Proxied = true
+		r.TTL = proxiedTTL
+	}
+	for i := range records {
+		if records[i].Name == name && records[i].Type == rr.Type {
+			return r, &records[i]
+		}
+	}
+	return r, nil
+}
+
+func (provider *Provider) zoneID(ctx context.Context, name string) (string, error) {
+	var zones []struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}
+	if _, err := provider.call(ctx, http.MethodGet, "/zones?name="+url.QueryEscape(name), nil, &zones); err != nil {
+		return "", err
+	}
+	for _, z := range zones {
+		if z.Name == name {
+			return z.ID, nil
+		}
+	}
+	return "", fmt.Errorf("zone %s not found", name)
+}
+
+func (provider *Provider) records(ctx context.Context, zoneID string) ([]record, error) {
+	var ret []record
+	for page := 1; ; page++ {
+		var records []record
+		path := fmt.Sprintf("/zones/%s/dns_records?per_page=%d&page=%d", url.PathEscape(zoneID), pageSize, page)
+		resp, err := provider.call(ctx, http.MethodGet, path, nil, &records)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, records...)
+		if page >= resp.ResultInfo.TotalPages {
+			return ret, nil
+		}
+	}
+}
+
+func (provider *Provider) call(ctx context.Context, method, path string, body, out any) (*response, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	endpoint := provider.endpoint
+	if endpoint == "" {
+		endpoint = defaultEndpoint
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+provider.APIToken)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := utils.HttpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var r response
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return nil, fmt.Errorf("cloudflare returned %d: %v", resp.StatusCode, err)
+	}
+	if !r.Success {
+		msgs := make([]string, len(r.Errors))
+		for i, e := range r.Errors {
+			msgs[i] = fmt.Sprintf("%d %s", e.Code, e.Message)
+		}
+		return nil, fmt.Errorf("cloudflare returned %d: %s", resp.StatusCode, strings.Join(msgs, "; "))
+	}
+	if out != nil {
+		if err := json.Unmarshal(r.Result, out); err != nil {
+			return nil, err
+		}
+	}
+	return &r, nil
+}
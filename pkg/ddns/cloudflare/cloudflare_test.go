@@ -0,0 +1,70 @@
+package cloudflare
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/libdns/libdns"
+)
+
+func TestSetRecords(t *testing.T) {
+	var batch struct {
+		Posts []record `json:"posts"`
+		Puts  []record `json:"puts"`
+	}
+	var batches int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			json.NewEncoder(w).Encode(map[string]any{"success": false, "errors": []apiError{{Code: 10000, Message: "Authentication error"}}})
+			return
+		}
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/zones":
+			json.NewEncoder(w).Encode(map[string]any{"success": true, "result": []map[string]string{{"id": "z1", "name": "example.com"}}})
+		case r.Method == http.MethodGet && r.URL.Path == "/zones/z1/dns_records":
+			json.NewEncoder(w).Encode(map[string]any{"success": true, "result_info": map[string]int{"total_pages": 1}, "result": []record{
+				{ID: "r1", Type: "A", Name: "home.example.com", Content: "2.2.2.2", TTL: 60},
+				{ID: "r2", Type: "A", Name: "example.com", Content: "2.2.2.2", TTL: 60},
+			}})
+		case r.Method == http.MethodPost && r.URL.Path == "/zones/z1/dns_records/batch":
+			batches++
+			json.NewDecoder(r.Body).Decode(&batch)
+			json.NewEncoder(w).Encode(map[string]any{"success": true, "result": map[string]any{}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	p := &Provider{APIToken: "token", endpoint: srv.URL}
+	_, err := p.SetRecords(context.Background(), "example.com.", []libdns.Record{
+		// 内容不变但需要开启代理
+		libdns.Address{Name: "home", IP: netip.MustParseAddr("2.2.2.2"), TTL: time.Minute, ProviderData: true},
+		libdns.Address{Name: "@", IP: netip.MustParseAddr("2.2.2.2"), TTL: time.Minute},
+		libdns.Address{Name: "*.home", IP: netip.MustParseAddr("2001:db8::1"), TTL: time.Minute},
+	})
+	if err != nil {
+		t.Fatalf("SetRecords: %v", err)
+	}
+	if batches != 1 {
+		t.Fatalf("expected a single batch request, got %d", batches)
+	}
+	if len(batch.Puts) != 1 || batch.Puts[0].ID != "r1" || !batch.Puts[0].Proxied || batch.Puts[0].TTL != proxiedTTL {
+		t.Fatalf("expected record to be updated with proxy enabled, got %+v", batch.Puts)
+	}
+	if len(batch.Posts) != 1 || batch.Posts[0].Name != "*.home.example.com" || batch.Posts[0].Type != "AAAA" || batch.Posts[0].Proxied {
+		t.Fatalf("expected wildcard AAAA record to be created, got %+v", batch.Posts)
+	}
+
+	p.APIToken = "invalid"
+	if _, err := p.SetRecords(context.Background(), "example.com.", []libdns.Record{
+		libdns.Address{Name: "home", IP: netip.MustParseAddr("2.2.2.2")},
+	}); err == nil {
+		t.Fatal("expected error with invalid token")
+	}
+}
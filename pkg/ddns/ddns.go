@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"log"
 	"net/netip"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/libdns/libdns"
@@ -18,75 +20,296 @@ type DNSServerKey struct{}
 
 const (
 	dnsTimeOut = 10 * time.Second
+	// 记录到历史中的服务商返回内容的最大长度
+	responseSnippetLength = 512
+	// 更新后检查记录是否生效的次数及间隔
+	verifyAttempts = 3
+	verifyInterval = 10 * time.Second
 )
 
 type Provider struct {
-	prefix string
-	zone   string
-
 	DDNSProfile *model.DDNSProfile
 	IPAddrs     *model.IP
 	Setter      libdns.RecordSetter
+
+	ServerID uint64
+	Record   func(*model.DDNSHistory) // 每条记录更新后调用
 }
 
 func (provider *Provider) GetProfileID() uint64 {
 	return provider.DDNSProfile.ID
 }
 
+// pendingRecord 待更新的一条记录
+type pendingRecord struct {
+	spec     model.DDNSRecord
+	recType  string
+	addr     string
+	domain   string
+	prefix   string
+	oldValue string
+}
+
+// UpdateDomain 更新配置的记录，同一区域的记录合并提交，失败时逐条记录结果
 func (provider *Provider) UpdateDomain(ctx context.Context, overrideDomains ...string) {
-	for _, domain := range utils.IfOr(len(overrideDomains) > 0, overrideDomains, provider.DDNSProfile.Domains) {
-		for retries := range int(provider.DDNSProfile.MaxRetries) {
-			log.Printf("NEZHA>> Updating DNS Record of domain %s: %d/%d", domain, retries+1, provider.DDNSProfile.MaxRetries)
-			if err := provider.updateDomain(ctx, domain); err != nil {
-				log.Printf("NEZHA>> Failed to update DNS record of domain %s: %v", domain, err)
-			} else {
-				log.Printf("NEZHA>> Update DNS record of domain %s succeeded", domain)
-				break
-			}
+	provider.updateRecords(ctx, provider.pendingRecords(overrideDomains))
+}
+
+// VerifyDomain 通过公共解析器检查记录，仅更新与期望 IP 不一致的记录
+func (provider *Provider) VerifyDomain(ctx context.Context, overrideDomains ...string) {
+	var mismatched []pendingRecord
+	for _, r := range provider.pendingRecords(overrideDomains) {
+		if provider.recordMismatch(ctx, r) {
+			mismatched = append(mismatched, r)
 		}
 	}
+	if len(mismatched) > 0 {
+		provider.updateRecords(ctx, mismatched)
+	}
 }
 
-func (provider *Provider) updateDomain(ctx context.Context, domain string) error {
-	var err error
-	provider.prefix, provider.zone, err = provider.splitDomainSOA(ctx, domain)
+// recordMismatch 解析失败时视为一致，避免解析器故障导致反复更新
+func (provider *Provider) recordMismatch(ctx context.Context, r pendingRecord) bool {
+	values, err := provider.lookup(ctx, r.domain, r.recType)
 	if err != nil {
-		return err
+		log.Printf("NEZHA>> [DDNS %s] Failed to resolve %s record of %s: %v", provider.DDNSProfile.Name, r.recType, r.domain, err)
+		return false
+	}
+	if !slices.Contains(values, r.addr) {
+		log.Printf("NEZHA>> [DDNS %s] %s record of %s is %v, expected %s", provider.DDNSProfile.Name, r.recType, r.domain, values, r.addr)
+		return true
+	}
+	return false
+}
+
+// pendingRecords 展开记录配置，未指定类型时按配置的 IPv4/IPv6 开关，缺少对应地址的记录跳过
+func (provider *Provider) pendingRecords(overrideDomains []string) []pendingRecord {
+	var records []pendingRecord
+	for _, spec := range provider.DDNSProfile.RecordSpecs(overrideDomains...) {
+		for _, recType := range []string{"A", "AAAA"} {
+			if spec.Type != "" && spec.Type != recType {
+				continue
+			}
+			addr := provider.IPAddrs.IPv4Addr
+			enabled := *provider.DDNSProfile.EnableIPv4
+			if recType == "AAAA" {
+				addr = provider.IPAddrs.IPv6Addr
+				enabled = *provider.DDNSProfile.EnableIPv6
+			}
+			if spec.Type == "" && !enabled {
+				continue
+			}
+			if addr == "" {
+				if spec.Type != "" {
+					log.Printf("NEZHA>> [DDNS %s] No address for %s record of %s", provider.DDNSProfile.Name, recType, spec.Name)
+				}
+				continue
+			}
+			records = append(records, pendingRecord{
+				spec:    spec,
+				recType: recType,
+				addr:    addr,
+				domain:  strings.TrimSuffix(spec.Name, "."),
+			})
+		}
 	}
+	return records
+}
 
-	// 当IPv4和IPv6同时成功才算作成功
-	if *provider.DDNSProfile.EnableIPv4 {
-		if err = provider.addDomainRecord(ctx, "A", provider.IPAddrs.IPv4Addr); err != nil {
-			return err
+func (provider *Provider) updateRecords(ctx context.Context, records []pendingRecord) {
+	zones := make(map[string][]pendingRecord)
+	var order []string
+	for _, r := range records {
+		var zone string
+		var err error
+		r.prefix, zone, err = provider.splitDomainSOA(ctx, r.domain)
+		if err != nil {
+			log.Printf("NEZHA>> [DDNS %s] Failed to update DNS record of domain %s: %v", provider.DDNSProfile.Name, r.domain, err)
+			provider.record(ctx, r, err)
+			continue
+		}
+		if values, err := provider.lookup(ctx, r.domain, r.recType); err == nil {
+			r.oldValue = strings.Join(values, ",")
 		}
+		if _, ok := zones[zone]; !ok {
+			order = append(order, zone)
+		}
+		zones[zone] = append(zones[zone], r)
+	}
+	for _, zone := range order {
+		provider.updateZone(ctx, zone, zones[zone])
+	}
+}
+
+// updateZone 先整体提交，失败时逐条重试以确定失败的记录
+func (provider *Provider) updateZone(ctx context.Context, zone string, records []pendingRecord) {
+	errs := make([]error, len(records))
+	pending := make([]int, len(records))
+	for i := range records {
+		pending[i] = i
 	}
 
-	if *provider.DDNSProfile.EnableIPv6 {
-		if err = provider.addDomainRecord(ctx, "AAAA", provider.IPAddrs.IPv6Addr); err != nil {
-			return err
+	for retries := range int(provider.DDNSProfile.MaxRetries) {
+		log.Printf("NEZHA>> [DDNS %s] Updating %d DNS records in zone %s: %d/%d", provider.DDNSProfile.Name, len(pending), zone, retries+1, provider.DDNSProfile.MaxRetries)
+		batch := make([]pendingRecord, len(pending))
+		for n, i := range pending {
+			batch[n] = records[i]
+		}
+		err := provider.setRecords(ctx, zone, batch)
+		if err == nil {
+			for _, i := range pending {
+				errs[i] = nil
+			}
+			pending = nil
+			break
+		}
+		log.Printf("NEZHA>> [DDNS %s] Failed to update DNS records in zone %s: %v", provider.DDNSProfile.Name, zone, err)
+		if len(pending) == 1 {
+			errs[pending[0]] = err
+			continue
+		}
+
+		var failed []int
+		for _, i := range pending {
+			errs[i] = provider.setRecords(ctx, zone, records[i:i+1])
+			if errs[i] != nil {
+				failed = append(failed, i)
+			}
+		}
+		pending = failed
+		if len(pending) == 0 {
+			break
 		}
 	}
 
-	return nil
+	for i, r := range records {
+		if errs[i] == nil {
+			log.Printf("NEZHA>> [DDNS %s] Update %s record of domain %s succeeded", provider.DDNSProfile.Name, r.recType, r.domain)
+		}
+		provider.record(ctx, r, errs[i])
+	}
 }
 
-func (provider *Provider) addDomainRecord(ctx context.Context, recType, addr string) error {
-	netipAddr, err := netip.ParseAddr(addr)
-	if err != nil {
-		return fmt.Errorf("parse error: %v", err)
+// record 记录单条记录的更新结果
+func (provider *Provider) record(ctx context.Context, r pendingRecord, err error) {
+	h := &model.DDNSHistory{
+		ProfileID:  provider.DDNSProfile.ID,
+		ServerID:   provider.ServerID,
+		Domain:     r.domain,
+		RecordType: r.recType,
+		OldValue:   r.oldValue,
+		NewValue:   r.addr,
+	}
+	switch {
+	case err != nil:
+		h.Result = model.DDNSResultFailure
+		h.Response = err.Error()
+	case provider.DDNSProfile.DryRun:
+		h.Result = model.DDNSResultDryRun
+		log.Printf("NEZHA>> [DDNS %s] Dry run: %s record of %s would change from %q to %q", provider.DDNSProfile.Name, r.recType, r.domain, r.oldValue, r.addr)
+	default:
+		h.Result = model.DDNSResultSuccess
+		if provider.DDNSProfile.Verify {
+			h.Verification = provider.verifyPropagation(ctx, r.domain, r.recType, r.addr)
+		}
+	}
+	if len(h.Response) > responseSnippetLength {
+		h.Response = strings.ToValidUTF8(h.Response[:responseSnippetLength], "")
+	}
+	if provider.Record != nil {
+		provider.Record(h)
+	}
+}
+
+func (provider *Provider) setRecords(ctx context.Context, zone string, records []pendingRecord) error {
+	recs := make([]libdns.Record, 0, len(records))
+	for _, r := range records {
+		ip, err := netip.ParseAddr(r.addr)
+		if err != nil {
+			return fmt.Errorf("parse error: %v", err)
+		}
+		ttl := time.Minute
+		if r.spec.TTL > 0 {
+			ttl = time.Duration(r.spec.TTL) * time.Second
+		}
+		addr := libdns.Address{Name: r.prefix, IP: ip, TTL: ttl}
+		if r.spec.Proxied {
+			// 代理开关由服务商驱动通过 ProviderData 读取，目前仅 pkg/ddns/cloudflare 支持
+			addr.ProviderData = true
+		}
+		recs = append(recs, addr)
+	}
+	if provider.DDNSProfile.DryRun {
+		return nil
 	}
 
-	_, err = provider.Setter.SetRecords(ctx, provider.zone,
-		[]libdns.Record{
-			libdns.Address{
-				Name: provider.prefix,
-				IP:   netipAddr,
-				TTL:  time.Minute,
-			},
-		})
+	_, err := provider.Setter.SetRecords(ctx, zone, recs)
 	return err
 }
 
+// resolvers 配置了验证用的解析器时优先使用
+func (provider *Provider) resolvers(ctx context.Context) []string {
+	if provider.DDNSProfile.VerifyResolver != "" {
+		return strings.Split(provider.DDNSProfile.VerifyResolver, ",")
+	}
+	customDNSServers, _ := ctx.Value(DNSServerKey{}).([]string)
+	if len(customDNSServers) > 0 {
+		return customDNSServers
+	}
+	return utils.DNSServers
+}
+
+// lookup 查询记录当前解析到的值，所有解析器均失败时返回错误
+func (provider *Provider) lookup(ctx context.Context, domain, recType string) ([]string, error) {
+	c := &dns.Client{Timeout: dnsTimeOut}
+
+	var m dns.Msg
+	m.SetQuestion(dns.Fqdn(domain), dns.StringToType[recType])
+
+	var lastErr error
+	for _, server := range provider.resolvers(ctx) {
+		r, _, err := c.ExchangeContext(ctx, &m, server)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if r.Rcode != dns.RcodeSuccess && r.Rcode != dns.RcodeNameError {
+			lastErr = fmt.Errorf("%s returned %s", server, dns.RcodeToString[r.Rcode])
+			continue
+		}
+		values := make([]string, 0, len(r.Answer))
+		for _, ans := range r.Answer {
+			switch rr := ans.(type) {
+			case *dns.A:
+				values = append(values, rr.A.String())
+			case *dns.AAAA:
+				values = append(values, rr.AAAA.String())
+			}
+		}
+		return values, nil
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no resolver available")
+	}
+	return nil, lastErr
+}
+
+// verifyPropagation 更新后检查记录是否已生效，重试次数有限
+func (provider *Provider) verifyPropagation(ctx context.Context, domain, recType, addr string) string {
+	for range verifyAttempts {
+		select {
+		case <-ctx.Done():
+			return model.DDNSUnverified
+		case <-time.After(verifyInterval):
+		}
+		if values, err := provider.lookup(ctx, domain, recType); err == nil && slices.Contains(values, addr) {
+			return model.DDNSVerified
+		}
+	}
+	log.Printf("NEZHA>> [DDNS %s] %s record of %s has not propagated after %d checks", provider.DDNSProfile.Name, recType, domain, verifyAttempts)
+	return model.DDNSUnverified
+}
+
 func (provider *Provider) splitDomainSOA(ctx context.Context, domain string) (prefix string, zone string, err error) {
 	c := &dns.Client{Timeout: dnsTimeOut}
 
@@ -4,6 +4,10 @@ import (
 	"context"
 	"os"
 	"testing"
+
+	"github.com/libdns/libdns"
+
+	"github.com/nezhahq/nezha/model"
 )
 
 type testSt struct {
@@ -50,3 +54,33 @@ func TestSplitDomainSOA(t *testing.T) {
 		}
 	}
 }
+
+type recordingSetter struct {
+	recs []libdns.Record
+}
+
+func (s *recordingSetter) SetRecords(ctx context.Context, zone string, recs []libdns.Record) ([]libdns.Record, error) {
+	s.recs = append(s.recs, recs...)
+	return recs, nil
+}
+
+func TestSetRecordsProxied(t *testing.T) {
+	setter := &recordingSetter{}
+	provider := &Provider{DDNSProfile: &model.DDNSProfile{}, Setter: setter}
+	err := provider.setRecords(context.Background(), "example.com.", []pendingRecord{
+		{spec: model.DDNSRecord{Name: "home.example.com", Proxied: true}, recType: "A", addr: "2.2.2.2", prefix: "home"},
+		{spec: model.DDNSRecord{Name: "example.com"}, recType: "A", addr: "2.2.2.2"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(setter.recs) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(setter.recs))
+	}
+	if addr, ok := setter.recs[0].(libdns.Address); !ok || addr.ProviderData != true {
+		t.Fatalf("expected proxied flag to be passed to the provider, got %#v", setter.recs[0])
+	}
+	if addr, ok := setter.recs[1].(libdns.Address); !ok || addr.ProviderData != nil {
+		t.Fatalf("expected no provider data for record without proxy, got %#v", setter.recs[1])
+	}
+}
@@ -0,0 +1,117 @@
+package duckdns
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/libdns/libdns"
+
+	"github.com/nezhahq/nezha/pkg/utils"
+)
+
+const defaultEndpoint = "https://www.duckdns.org/update"
+
+// Provider DuckDNS 的子域名总是存在，只需更新地址
+type Provider struct {
+	Token string
+
+	endpoint string
+}
+
+func (provider *Provider) SetRecords(ctx context.Context, zone string,
+	recs []libdns.Record) ([]libdns.Record, error) {
+	// 一次请求可以更新多个子域名的 IPv4 和 IPv6 地址，地址相同的子域名合并请求
+	addrs := make(map[string]*[2]string)
+	var order []string
+	for _, rec := range recs {
+		if _, ok := rec.(libdns.Address); !ok {
+			return nil, fmt.Errorf("unsupported record type: %T", rec)
+		}
+		rr := rec.RR()
+		domain := subdomain(rr.Name)
+		if domain == "" || domain == "@" || domain == "*" {
+			return nil, fmt.Errorf("failed to update %s.%s: subdomain is required", rr.Name, strings.TrimSuffix(zone, "."))
+		}
+		a, ok := addrs[domain]
+		if !ok {
+			a = new([2]string)
+			addrs[domain] = a
+			order = append(order, domain)
+		}
+		switch rr.Type {
+		case "A":
+			a[0] = rr.Data
+		case "AAAA":
+			a[1] = rr.Data
+		default:
+			return nil, fmt.Errorf("unsupported record type: %s", rr.Type)
+		}
+	}
+
+	groups := make(map[[2]string][]string)
+	var keys [][2]string
+	for _, domain := range order {
+		key := *addrs[domain]
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], domain)
+	}
+	for _, key := range keys {
+		if err := provider.update(ctx, groups[key], key[0], key[1]); err != nil {
+			return nil, fmt.Errorf("failed to update %s: %v", strings.Join(groups[key], ","), err)
+		}
+	}
+	return recs, nil
+}
+
+// subdomain DuckDNS 只接受 duckdns.org 下的一级子域名
+func subdomain(name string) string {
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
+
+func (provider *Provider) update(ctx context.Context, domains []string, ipv4, ipv6 string) error {
+	q := url.Values{}
+	q.Set("domains", strings.Join(domains, ","))
+	q.Set("token", provider.Token)
+	switch {
+	case ipv4 == "":
+		// 省略 ip 参数时 DuckDNS 会用请求来源的 IPv4 覆盖 A 记录，只更新 IPv6 时将地址放在 ip 参数中
+		q.Set("ip", ipv6)
+	case ipv6 == "":
+		q.Set("ip", ipv4)
+	default:
+		q.Set("ip", ipv4)
+		q.Set("ipv6", ipv6)
+	}
+
+	endpoint := provider.endpoint
+	if endpoint == "" {
+		endpoint = defaultEndpoint
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"?"+q.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := utils.HttpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(string(body), "OK") {
+		return fmt.Errorf("duckdns returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package duckdns
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/libdns/libdns"
+)
+
+func TestSetRecords(t *testing.T) {
+	var queries []url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.Query())
+		w.Write([]byte("OK"))
+	}))
+	defer srv.Close()
+
+	p := &Provider{Token: "token", endpoint: srv.URL}
+	_, err := p.SetRecords(context.Background(), "duckdns.org.", []libdns.Record{
+		libdns.Address{Name: "home", IP: netip.MustParseAddr("2.2.2.2"), TTL: time.Minute},
+		libdns.Address{Name: "home", IP: netip.MustParseAddr("2001:db8::1"), TTL: time.Minute},
+		libdns.Address{Name: "v6only", IP: netip.MustParseAddr("2001:db8::2"), TTL: time.Minute},
+	})
+	if err != nil {
+		t.Fatalf("SetRecords: %v", err)
+	}
+	if len(queries) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(queries))
+	}
+
+	if q := queries[0]; q.Get("domains") != "home" || q.Get("ip") != "2.2.2.2" || q.Get("ipv6") != "2001:db8::1" {
+		t.Errorf("unexpected query for dual-stack update: %v", q)
+	}
+	// 只更新 IPv6 时必须带上 ip 参数，否则 DuckDNS 会用请求来源的地址覆盖 A 记录
+	if q := queries[1]; q.Get("domains") != "v6only" || q.Get("ip") != "2001:db8::2" || q.Has("ipv6") {
+		t.Errorf("unexpected query for AAAA-only update: %v", q)
+	}
+}
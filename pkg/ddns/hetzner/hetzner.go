@@ -0,0 +1,185 @@
+package hetzner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/goccy/go-json"
+	"github.com/libdns/libdns"
+
+	"github.com/nezhahq/nezha/pkg/utils"
+)
+
+const defaultEndpoint = "https://dns.hetzner.com/api/v1"
+
+type Provider struct {
+	APIToken string
+
+	endpoint string
+}
+
+type zone struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type record struct {
+	ID     string `json:"id,omitempty"`
+	ZoneID string `json:"zone_id"`
+	Type   string `json:"type"`
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	TTL    uint64 `json:"ttl,omitempty"`
+}
+
+func (provider *Provider) SetRecords(ctx context.Context, zoneName string,
+	recs []libdns.Record) ([]libdns.Record, error) {
+	zoneName = strings.TrimSuffix(zoneName, ".")
+	zoneID, err := provider.zoneID(ctx, zoneName)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := provider.records(ctx, zoneID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 使用批量接口，每个区域最多一次创建和一次更新请求
+	var create, update []record
+	for _, rec := range recs {
+		if _, ok := rec.(libdns.Address); !ok {
+			return nil, fmt.Errorf("unsupported record type: %T", rec)
+		}
+		r, existing := newRecord(zoneID, records, rec.RR())
+		switch {
+		case existing == nil:
+			create = append(create, r)
+		case existing.Value != r.Value || existing.TTL != r.TTL:
+			r.ID = existing.ID
+			update = append(update, r)
+		}
+	}
+
+	if len(create) > 0 {
+		var resp struct {
+			InvalidRecords []record `json:"invalid_records"`
+		}
+		if err := provider.call(ctx, http.MethodPost, "/records/bulk", map[string]any{"records": create}, &resp); err != nil {
+			return nil, fmt.Errorf("failed to create records in %s: %v", zoneName, err)
+		}
+		if len(resp.InvalidRecords) > 0 {
+			return nil, fmt.Errorf("invalid records in %s: %s", zoneName, recordNames(resp.InvalidRecords))
+		}
+	}
+	if len(update) > 0 {
+		var resp struct {
+			FailedRecords []record `json:"failed_records"`
+		}
+		if err := provider.call(ctx, http.MethodPut, "/records/bulk", map[string]any{"records": update}, &resp); err != nil {
+			return nil, fmt.Errorf("failed to update records in %s: %v", zoneName, err)
+		}
+		if len(resp.FailedRecords) > 0 {
+			return nil, fmt.Errorf("failed to update records in %s: %s", zoneName, recordNames(resp.FailedRecords))
+		}
+	}
+	return recs, nil
+}
+
+// newRecord 转换为 Hetzner 的记录，并查找名称与类型相同的现有记录
+func newRecord(zoneID string, records []record, rr libdns.RR) (record, *record) {
+	name := rr.Name
+	if name == "" {
+		name = "@"
+	}
+	r := record{
+		ZoneID: zoneID,
+		Type:   rr.Type,
+		Name:   name,
+		Value:  rr.Data,
+		TTL:    uint64(rr.TTL.Seconds()),
+	}
+	for i := range records {
+		if records[i].Name == name && records[i].Type == rr.Type {
+			return r, &records[i]
+		}
+	}
+	return r, nil
+}
+
+func recordNames(records []record) string {
+	names := make([]string, len(records))
+	for i, r := range records {
+		names[i] = r.Type + " " + r.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+func (provider *Provider) zoneID(ctx context.Context, name string) (string, error) {
+	var resp struct {
+		Zones []zone `json:"zones"`
+	}
+	if err := provider.call(ctx, http.MethodGet, "/zones?name="+url.QueryEscape(name), nil, &resp); err != nil {
+		return "", err
+	}
+	for _, z := range resp.Zones {
+		if z.Name == name {
+			return z.ID, nil
+		}
+	}
+	return "", fmt.Errorf("zone %s not found", name)
+}
+
+func (provider *Provider) records(ctx context.Context, zoneID string) ([]record, error) {
+	var resp struct {
+		Records []record `json:"records"`
+	}
+	if err := provider.call(ctx, http.MethodGet, "/records?zone_id="+url.QueryEscape(zoneID), nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Records, nil
+}
+
+func (provider *Provider) call(ctx context.Context, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	endpoint := provider.endpoint
+	if endpoint == "" {
+		endpoint = defaultEndpoint
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Auth-API-Token", provider.APIToken)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := utils.HttpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("hetzner returned %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package hetzner
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/libdns/libdns"
+)
+
+func TestSetRecords(t *testing.T) {
+	var created, updated []record
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Auth-API-Token") != "token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/zones":
+			json.NewEncoder(w).Encode(map[string]any{"zones": []zone{{ID: "z1", Name: "example.com"}}})
+		case r.Method == http.MethodGet && r.URL.Path == "/records":
+			json.NewEncoder(w).Encode(map[string]any{"records": []record{
+				{ID: "r1", ZoneID: "z1", Type: "A", Name: "home", Value: "1.1.1.1", TTL: 60},
+			}})
+		case r.Method == http.MethodPut && r.URL.Path == "/records/bulk":
+			var req struct{ Records []record }
+			json.NewDecoder(r.Body).Decode(&req)
+			updated = append(updated, req.Records...)
+			json.NewEncoder(w).Encode(map[string]any{"records": req.Records})
+		case r.Method == http.MethodPost && r.URL.Path == "/records/bulk":
+			var req struct{ Records []record }
+			json.NewDecoder(r.Body).Decode(&req)
+			var invalid []record
+			for _, rec := range req.Records {
+				if rec.Name == "bad" {
+					invalid = append(invalid, rec)
+				} else {
+					created = append(created, rec)
+				}
+			}
+			json.NewEncoder(w).Encode(map[string]any{"invalid_records": invalid})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	p := &Provider{APIToken: "token", endpoint: srv.URL}
+	_, err := p.SetRecords(context.Background(), "example.com.", []libdns.Record{
+		libdns.Address{Name: "home", IP: netip.MustParseAddr("2.2.2.2"), TTL: time.Minute},
+		libdns.Address{Name: "home", IP: netip.MustParseAddr("2001:db8::1"), TTL: time.Minute},
+		libdns.Address{Name: "*.home", IP: netip.MustParseAddr("2.2.2.2"), TTL: time.Minute},
+	})
+	if err != nil {
+		t.Fatalf("SetRecords: %v", err)
+	}
+	if len(updated) != 1 || updated[0].ID != "r1" || updated[0].Value != "2.2.2.2" {
+		t.Fatalf("expected A record to be updated, got %+v", updated)
+	}
+	if len(created) != 2 || created[0].Type != "AAAA" || created[1].Name != "*.home" {
+		t.Fatalf("expected AAAA and wildcard records to be created, got %+v", created)
+	}
+
+	if _, err := p.SetRecords(context.Background(), "example.com.", []libdns.Record{
+		libdns.Address{Name: "bad", IP: netip.MustParseAddr("2.2.2.2")},
+	}); err == nil {
+		t.Fatal("expected error for invalid record")
+	}
+
+	p.APIToken = "invalid"
+	if _, err := p.SetRecords(context.Background(), "example.com.", []libdns.Record{
+		libdns.Address{Name: "home", IP: netip.MustParseAddr("2.2.2.2")},
+	}); err == nil {
+		t.Fatal("expected error with invalid token")
+	}
+}
@@ -0,0 +1,138 @@
+package porkbun
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/libdns/libdns"
+
+	"github.com/nezhahq/nezha/pkg/utils"
+)
+
+const (
+	defaultEndpoint = "https://api.porkbun.com/api/json/v3"
+	// Porkbun 允许的最小 TTL
+	minTTL = 600 * time.Second
+)
+
+type Provider struct {
+	APIKey       string
+	SecretAPIKey string
+
+	endpoint string
+}
+
+type record struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Content string `json:"content"`
+	TTL     string `json:"ttl"`
+}
+
+type response struct {
+	Status  string   `json:"status"`
+	Message string   `json:"message,omitempty"`
+	Records []record `json:"records,omitempty"`
+}
+
+func (provider *Provider) SetRecords(ctx context.Context, zone string,
+	recs []libdns.Record) ([]libdns.Record, error) {
+	domain := strings.TrimSuffix(zone, ".")
+	// Porkbun 没有批量接口，先一次取回全部记录，只对有变化的记录发起请求
+	existing, err := provider.call(ctx, "/dns/retrieve/"+domain, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, rec := range recs {
+		if _, ok := rec.(libdns.Address); !ok {
+			return nil, fmt.Errorf("unsupported record type: %T", rec)
+		}
+		rr := rec.RR()
+		if err := provider.setRecord(ctx, domain, existing.Records, rr); err != nil {
+			return nil, fmt.Errorf("failed to update %s record of %s in %s: %v", rr.Type, rr.Name, domain, err)
+		}
+	}
+	return recs, nil
+}
+
+// setRecord 更新名称与类型相同的记录，不存在时创建
+func (provider *Provider) setRecord(ctx context.Context, domain string, records []record, rr libdns.RR) error {
+	name := rr.Name
+	if name == "@" {
+		name = ""
+	}
+	ttl := fmt.Sprintf("%d", int64(max(rr.TTL, minTTL).Seconds()))
+
+	// 返回的记录名称为完整域名
+	fqdn := domain
+	if name != "" {
+		fqdn = name + "." + domain
+	}
+	for _, r := range records {
+		if r.Name != fqdn || r.Type != rr.Type {
+			continue
+		}
+		if r.Content == rr.Data && r.TTL == ttl {
+			return nil
+		}
+		path := fmt.Sprintf("/dns/editByNameType/%s/%s/%s", domain, rr.Type, name)
+		_, err := provider.call(ctx, path, map[string]string{
+			"content": rr.Data,
+			"ttl":     ttl,
+		})
+		return err
+	}
+
+	_, err := provider.call(ctx, "/dns/create/"+domain, map[string]string{
+		"name":    name,
+		"type":    rr.Type,
+		"content": rr.Data,
+		"ttl":     ttl,
+	})
+	return err
+}
+
+func (provider *Provider) call(ctx context.Context, path string, params map[string]string) (*response, error) {
+	body := map[string]string{
+		"apikey":       provider.APIKey,
+		"secretapikey": provider.SecretAPIKey,
+	}
+	for k, v := range params {
+		body[k] = v
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := provider.endpoint
+	if endpoint == "" {
+		endpoint = defaultEndpoint
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint+path, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := utils.HttpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var r response
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return nil, fmt.Errorf("porkbun returned %d: %v", resp.StatusCode, err)
+	}
+	if r.Status != "SUCCESS" {
+		return nil, fmt.Errorf("porkbun returned %d: %s", resp.StatusCode, r.Message)
+	}
+	return &r, nil
+}
@@ -60,6 +60,48 @@ var (
 	minRequestInterval = 2 * time.Second
 )
 
+// Stats 查询 API 的请求统计，用于诊断
+type Stats struct {
+	Provider     string    `json:"provider"`
+	Requests     uint64    `json:"requests"`
+	Failures     uint64    `json:"failures"`
+	LastSuccess  time.Time `json:"last_success"`
+	LastFailure  time.Time `json:"last_failure"`
+	LastError    string    `json:"last_error,omitempty"`
+	CacheEntries int       `json:"cache_entries"`
+	CacheExpired int       `json:"cache_expired"`
+}
+
+var (
+	stats   Stats
+	statsMu sync.Mutex
+)
+
+func recordRequest(err error) {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+
+	stats.Requests++
+	if err != nil {
+		stats.Failures++
+		stats.LastFailure = time.Now()
+		stats.LastError = err.Error()
+		return
+	}
+	stats.LastSuccess = time.Now()
+}
+
+// GetStats 返回请求统计与缓存状态
+func GetStats() Stats {
+	statsMu.Lock()
+	ret := stats
+	statsMu.Unlock()
+
+	ret.Provider = apiBaseURL
+	ret.CacheEntries, ret.CacheExpired = GetCacheStats()
+	return ret
+}
+
 // 检查缓存
 func getCachedResult(ip string) (countryCode, asn string, found bool) {
 	cacheMu.RLock()
@@ -90,6 +132,17 @@ func setCachedResult(ip, countryCode, asn string) {
 	}
 }
 
+// SetOptions 修改缓存时间与两次请求的最小间隔
+func SetOptions(cacheTTL, requestInterval time.Duration) {
+	cacheMu.Lock()
+	cacheExpiry = cacheTTL
+	cacheMu.Unlock()
+
+	requestMu.Lock()
+	minRequestInterval = requestInterval
+	requestMu.Unlock()
+}
+
 // 频率限制检查
 func checkRateLimit() {
 	requestMu.Lock()
@@ -124,6 +177,26 @@ func queryIPAPI(ip net.IP) (*APIResponse, error) {
 	// 应用频率限制
 	checkRateLimit()
 
+	result, err := fetchIPAPI(ipStr)
+	recordRequest(err)
+	if err != nil {
+		return nil, err
+	}
+
+	// 存储到缓存
+	var asn string
+	if result.AS != "" {
+		asn = parseASN(result.AS)
+	} else if result.Org != "" {
+		asn = parseASN(result.Org)
+	}
+
+	setCachedResult(ipStr, result.CountryCode, asn)
+
+	return result, nil
+}
+
+func fetchIPAPI(ipStr string) (*APIResponse, error) {
 	url := apiBaseURL + ipStr
 
 	resp, err := httpClient.Get(url)
@@ -144,17 +217,6 @@ func queryIPAPI(ip net.IP) (*APIResponse, error) {
 	if result.Status != "success" {
 		return nil, fmt.Errorf("API returned error status: %s", result.Status)
 	}
-
-	// 存储到缓存
-	var asn string
-	if result.AS != "" {
-		asn = parseASN(result.AS)
-	} else if result.Org != "" {
-		asn = parseASN(result.Org)
-	}
-
-	setCachedResult(ipStr, result.CountryCode, asn)
-
 	return &result, nil
 }
 
@@ -172,6 +234,18 @@ func Lookup(ip net.IP) (string, error) {
 	return "", fmt.Errorf("country code not found for IP: %s", ip.String())
 }
 
+// LookupCached 只从缓存中查询IP的国家代码，不发起请求
+func LookupCached(ip net.IP) (string, bool) {
+	if ip == nil {
+		return "", false
+	}
+	countryCode, _, found := getCachedResult(ip.String())
+	if !found || countryCode == "" {
+		return "", false
+	}
+	return strings.ToLower(countryCode), true
+}
+
 // LookupASN 查询IP的ASN组织名称
 func LookupASN(ip net.IP) (string, error) {
 	result, err := queryIPAPI(ip)
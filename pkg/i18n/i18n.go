@@ -1,10 +1,16 @@
 package i18n
 
 import (
+	"cmp"
 	"embed"
+	"errors"
 	"fmt"
 	"io/fs"
+	"os"
 	"path"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/leonelquinteros/gotext"
@@ -14,25 +20,29 @@ import (
 var Translations embed.FS
 
 type Localizer struct {
-	intlMap map[string]gotext.Translator
-	lang    string
-	domain  string
-	path    string
-	fs      fs.FS
+	intlMap   map[string]gotext.Translator
+	overrides map[string]gotext.Translator // 从目录加载的翻译，优先于内置翻译
+	missing   map[string]bool              // 没有内置翻译的语言，避免重复查找
+	lang      string
+	domain    string
+	path      string
+	fs        fs.FS
 
 	mu sync.RWMutex
 }
 
 func NewLocalizer(lang, domain, path string, fs fs.FS) *Localizer {
 	loc := &Localizer{
-		intlMap: make(map[string]gotext.Translator),
-		lang:    lang,
-		domain:  domain,
-		path:    path,
-		fs:      fs,
+		intlMap:   make(map[string]gotext.Translator),
+		overrides: make(map[string]gotext.Translator),
+		missing:   make(map[string]bool),
+		lang:      lang,
+		domain:    domain,
+		path:      path,
+		fs:        fs,
 	}
 
-	file := loc.findExt(lang, "mo")
+	file := findExt(loc.fs, loc.path, loc.domain, lang, "mo")
 	if file == "" {
 		return loc
 	}
@@ -62,8 +72,11 @@ func (l *Localizer) Exists(lang string) bool {
 }
 
 func (l *Localizer) AppendIntl(lang string) {
-	file := l.findExt(lang, "mo")
+	file := findExt(l.fs, l.path, l.domain, lang, "mo")
 	if file == "" {
+		l.mu.Lock()
+		l.missing[lang] = true
+		l.mu.Unlock()
 		return
 	}
 
@@ -76,19 +89,150 @@ func (l *Localizer) AppendIntl(lang string) {
 	l.intlMap[lang] = mo
 }
 
-// Modified from k8s.io/kubectl/pkg/util/i18n
+// LoadDir 从目录加载翻译文件，用于覆盖内置翻译或增加新的语言，无需重新编译。
+// 支持 <dir>/<lang>.po 与 <dir>/<lang>/LC_MESSAGES/<domain>.po 两种布局，也可以使用 .mo 文件。
+// 目录不存在时不做任何修改
+func (l *Localizer) LoadDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	dirFS := os.DirFS(dir)
+	overrides := make(map[string]gotext.Translator)
+	for _, e := range entries {
+		var lang, file string
+		if e.IsDir() {
+			lang = e.Name()
+			if file = findExt(dirFS, ".", l.domain, lang, "po"); file == "" {
+				file = findExt(dirFS, ".", l.domain, lang, "mo")
+			}
+		} else if ext := path.Ext(e.Name()); ext == ".po" || ext == ".mo" {
+			lang, file = strings.TrimSuffix(e.Name(), ext), e.Name()
+		}
+		if file == "" {
+			continue
+		}
+
+		var tr gotext.Translator
+		if path.Ext(file) == ".po" {
+			tr = gotext.NewPoFS(dirFS)
+		} else {
+			tr = gotext.NewMoFS(dirFS)
+		}
+		tr.ParseFile(file)
+		overrides[normalize(lang)] = tr
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.overrides = overrides
+	return nil
+}
+
+// Languages 返回内置及从目录加载的所有语言
+func (l *Localizer) Languages() []string {
+	var langs []string
+	if entries, err := fs.ReadDir(l.fs, l.path); err == nil {
+		for _, e := range entries {
+			if e.IsDir() {
+				langs = append(langs, e.Name())
+			}
+		}
+	}
 
-func (l *Localizer) T(orig string) string {
 	l.mu.RLock()
-	intl, ok := l.intlMap[l.lang]
+	for lang := range l.overrides {
+		langs = append(langs, lang)
+	}
+	l.mu.RUnlock()
+
+	slices.Sort(langs)
+	return slices.Compact(langs)
+}
+
+// Negotiate 根据 Accept-Language 请求头选择可用的语言，没有匹配时返回空字符串
+func (l *Localizer) Negotiate(acceptLanguage string) string {
+	type tag struct {
+		lang string
+		q    float64
+	}
+	var tags []tag
+	for part := range strings.SplitSeq(acceptLanguage, ",") {
+		lang, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if lang == "" || lang == "*" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		if q > 0 {
+			tags = append(tags, tag{normalize(lang), q})
+		}
+	}
+	slices.SortStableFunc(tags, func(a, b tag) int { return cmp.Compare(b.q, a.q) })
+
+	available := l.Languages()
+	for _, t := range tags {
+		for _, lang := range available {
+			if strings.EqualFold(lang, t.lang) {
+				return lang
+			}
+		}
+		// 只指定了语言时，选择该语言的第一个地区
+		base, _, _ := strings.Cut(t.lang, "_")
+		for _, lang := range available {
+			if b, _, _ := strings.Cut(lang, "_"); strings.EqualFold(b, base) {
+				return lang
+			}
+		}
+	}
+	return ""
+}
+
+// Lang 返回使用指定语言的翻译，lang 为空时使用默认语言
+func (l *Localizer) Lang(lang string) *Lang {
+	return &Lang{l: l, lang: normalize(lang)}
+}
+
+func (l *Localizer) lookup(lang, orig string) string {
+	l.mu.RLock()
+	if lang == "" {
+		lang = l.lang
+	}
+	override, hasOverride := l.overrides[lang]
+	intl, ok := l.intlMap[lang]
+	missing := l.missing[lang]
 	l.mu.RUnlock()
+
+	if hasOverride && override.GetDomain().IsTranslated(orig) {
+		return override.Get(orig)
+	}
+	if !ok && !missing {
+		l.AppendIntl(lang)
+		l.mu.RLock()
+		intl, ok = l.intlMap[lang]
+		l.mu.RUnlock()
+	}
 	if !ok {
 		return orig
 	}
-
 	return intl.Get(orig)
 }
 
+// Modified from k8s.io/kubectl/pkg/util/i18n
+
+func (l *Localizer) T(orig string) string {
+	return l.lookup("", orig)
+}
+
 // N translates a string, possibly substituting arguments into it along
 // the way. If len(args) is > 0, args1 is assumed to be the plural value
 // and plural translation is used.
@@ -109,37 +253,91 @@ func (l *Localizer) N(orig string, args ...int) string {
 
 // ErrorT produces an error with a translated error string.
 // Substitution is performed via the `T` function above, following
-// the same rules.
+// the same rules. The message is translated when it is read, so
+// Localize can render it in another language.
 func (l *Localizer) ErrorT(defaultValue string, args ...any) error {
-	return fmt.Errorf(l.T(defaultValue), args...)
+	return &Error{l: l, format: defaultValue, args: args}
 }
 
 func (l *Localizer) Tf(defaultValue string, args ...any) string {
 	return fmt.Sprintf(l.T(defaultValue), args...)
 }
 
+// Lang 绑定了语言的翻译
+type Lang struct {
+	l    *Localizer
+	lang string
+}
+
+func (t *Lang) T(orig string) string {
+	return t.l.lookup(t.lang, orig)
+}
+
+func (t *Lang) Tf(defaultValue string, args ...any) string {
+	return fmt.Sprintf(t.T(defaultValue), args...)
+}
+
+func (t *Lang) ErrorT(defaultValue string, args ...any) error {
+	return &Error{l: t.l, format: defaultValue, args: args, lang: t.lang}
+}
+
+// Error 由 ErrorT 生成的错误，保留原文以便按请求的语言重新翻译
+type Error struct {
+	l      *Localizer
+	format string
+	args   []any
+	lang   string
+}
+
+func (e *Error) Error() string {
+	return e.Localize(e.lang)
+}
+
+// Localize 使用指定语言生成错误信息，lang 为空时使用生成错误时的语言
+func (e *Error) Localize(lang string) string {
+	if lang == "" {
+		lang = e.lang
+	}
+	return fmt.Errorf(e.l.lookup(normalize(lang), e.format), e.args...).Error()
+}
+
+func (e *Error) Unwrap() []error {
+	var errs []error
+	for _, a := range e.args {
+		if err, ok := a.(error); ok {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// normalize 将 zh-CN 等格式统一为 zh_CN
+func normalize(lang string) string {
+	return strings.ReplaceAll(lang, "-", "_")
+}
+
 // https://github.com/leonelquinteros/gotext/blob/v1.7.1/locale.go
-func (l *Localizer) findExt(lang, ext string) string {
-	filename := path.Join(l.path, lang, "LC_MESSAGES", l.domain+"."+ext)
-	if l.fileExists(filename) {
+func findExt(fsys fs.FS, base, domain, lang, ext string) string {
+	filename := path.Join(base, lang, "LC_MESSAGES", domain+"."+ext)
+	if fileExists(fsys, filename) {
 		return filename
 	}
 
 	if len(lang) > 2 {
-		filename = path.Join(l.path, lang[:2], "LC_MESSAGES", l.domain+"."+ext)
-		if l.fileExists(filename) {
+		filename = path.Join(base, lang[:2], "LC_MESSAGES", domain+"."+ext)
+		if fileExists(fsys, filename) {
 			return filename
 		}
 	}
 
-	filename = path.Join(l.path, lang, l.domain+"."+ext)
-	if l.fileExists(filename) {
+	filename = path.Join(base, lang, domain+"."+ext)
+	if fileExists(fsys, filename) {
 		return filename
 	}
 
 	if len(lang) > 2 {
-		filename = path.Join(l.path, lang[:2], l.domain+"."+ext)
-		if l.fileExists(filename) {
+		filename = path.Join(base, lang[:2], domain+"."+ext)
+		if fileExists(fsys, filename) {
 			return filename
 		}
 	}
@@ -147,7 +345,7 @@ func (l *Localizer) findExt(lang, ext string) string {
 	return ""
 }
 
-func (l *Localizer) fileExists(filename string) bool {
-	_, err := fs.Stat(l.fs, filename)
+func fileExists(fsys fs.FS, filename string) bool {
+	_, err := fs.Stat(fsys, filename)
 	return err == nil
 }
@@ -1,6 +1,8 @@
 package i18n
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -30,3 +32,74 @@ func TestI18n(t *testing.T) {
 		}
 	})
 }
+
+func TestLocalizerLang(t *testing.T) {
+	loc := NewLocalizer("zh_CN", "nezha", "translations", Translations)
+
+	err := loc.ErrorT("alert id %d does not exist", 1)
+	if err.Error() != "告警 ID 1 不存在" {
+		t.Fatalf("unexpected default message %s", err)
+	}
+	if got := loc.ErrorT("database error").(*Error).Localize("zh-TW"); got != "資料庫錯誤" {
+		t.Fatalf("expected a zh_TW translation, got %s", got)
+	}
+	if got := loc.Lang("en_US").Tf("alert id %d does not exist", 2); got != "alert id 2 does not exist" {
+		t.Fatalf("unexpected en_US message %s", got)
+	}
+
+	for header, want := range map[string]string{
+		"en-US,en;q=0.9":         "en_US",
+		"fr;q=0.9, zh-TW;q=0.95": "zh_TW",
+		"ru":                     "ru_RU",
+		"fr, *;q=0.1":            "",
+	} {
+		if got := loc.Negotiate(header); got != want {
+			t.Errorf("Negotiate(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestLocalizerLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	po := `msgid ""
+msgstr ""
+"Content-Type: text/plain; charset=UTF-8\n"
+
+msgid "database error"
+msgstr "erreur de base de données"
+`
+	if err := os.WriteFile(filepath.Join(dir, "fr_FR.po"), []byte(po), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	override := `msgid ""
+msgstr ""
+"Content-Type: text/plain; charset=UTF-8\n"
+
+msgid "database error"
+msgstr "数据库出错了"
+`
+	if err := os.MkdirAll(filepath.Join(dir, "zh_CN", "LC_MESSAGES"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "zh_CN", "LC_MESSAGES", "nezha.po"), []byte(override), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	loc := NewLocalizer("zh_CN", "nezha", "translations", Translations)
+	if err := loc.LoadDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if got := loc.T("database error"); got != "数据库出错了" {
+		t.Fatalf("override not applied: %s", got)
+	}
+	// 未覆盖的条目仍使用内置翻译
+	if got := loc.Tf("alert id %d does not exist", 1); got != "告警 ID 1 不存在" {
+		t.Fatalf("unexpected fallback %s", got)
+	}
+	if got := loc.Lang("fr_FR").T("database error"); got != "erreur de base de données" {
+		t.Fatalf("added language not loaded: %s", got)
+	}
+	if loc.Negotiate("fr") != "fr_FR" {
+		t.Fatalf("added language is not negotiable")
+	}
+}
@@ -0,0 +1,163 @@
+// Package migrate 按版本顺序执行可回滚的数据库迁移，已执行的版本记录在 schema_migrations 表中
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrIrreversible = errors.New("migration is irreversible")
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error // 为空时不可回滚
+}
+
+type SchemaMigration struct {
+	Version   int64     `gorm:"primaryKey;autoIncrement:false" json:"version"`
+	Name      string    `json:"name"`
+	AppliedAt time.Time `json:"applied_at"`
+}
+
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+type Status struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+// New 创建 Migrator，migrations 需按版本号递增排列
+func New(db *gorm.DB, migrations []Migration) (*Migrator, error) {
+	for i, m := range migrations {
+		if m.Version <= 0 || m.Up == nil {
+			return nil, fmt.Errorf("invalid migration %d %s", m.Version, m.Name)
+		}
+		if i > 0 && m.Version <= migrations[i-1].Version {
+			return nil, fmt.Errorf("migration %d is not in ascending order", m.Version)
+		}
+	}
+	if err := db.AutoMigrate(&SchemaMigration{}); err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Latest 返回已知的最新版本
+func (m *Migrator) Latest() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+func (m *Migrator) applied() ([]SchemaMigration, error) {
+	var applied []SchemaMigration
+	err := m.db.Order("version").Find(&applied).Error
+	return applied, err
+}
+
+// Check 数据库中存在未知的较新版本时返回错误，避免旧版本程序操作新结构的数据库
+func (m *Migrator) Check() error {
+	applied, err := m.applied()
+	if err != nil {
+		return err
+	}
+	for _, a := range applied {
+		if !slices.ContainsFunc(m.migrations, func(mg Migration) bool { return mg.Version == a.Version }) {
+			return fmt.Errorf("database schema version %d (%s) is newer than this program supports (%d), please upgrade", a.Version, a.Name, m.Latest())
+		}
+	}
+	return nil
+}
+
+// Status 返回各迁移的执行情况
+func (m *Migrator) Status() ([]Status, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	status := make([]Status, len(m.migrations))
+	for i, mg := range m.migrations {
+		status[i] = Status{Version: mg.Version, Name: mg.Name}
+		if j := slices.IndexFunc(applied, func(a SchemaMigration) bool { return a.Version == mg.Version }); j >= 0 {
+			status[i].AppliedAt = &applied[j].AppliedAt
+		}
+	}
+	return status, nil
+}
+
+// Up 依次执行未执行且不超过 target 的迁移，target 为 0 时执行到最新版本
+func (m *Migrator) Up(target int64) ([]Migration, error) {
+	if err := m.Check(); err != nil {
+		return nil, err
+	}
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, mg := range m.migrations {
+		if target > 0 && mg.Version > target {
+			break
+		}
+		if slices.ContainsFunc(applied, func(a SchemaMigration) bool { return a.Version == mg.Version }) {
+			continue
+		}
+		if err := m.db.Transaction(func(tx *gorm.DB) error {
+			if err := mg.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{Version: mg.Version, Name: mg.Name, AppliedAt: time.Now()}).Error
+		}); err != nil {
+			return done, fmt.Errorf("migration %d %s: %w", mg.Version, mg.Name, err)
+		}
+		done = append(done, mg)
+	}
+	return done, nil
+}
+
+// Down 按版本倒序回滚最近执行的 steps 个迁移
+func (m *Migrator) Down(steps int) ([]Migration, error) {
+	if err := m.Check(); err != nil {
+		return nil, err
+	}
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for i := len(applied) - 1; i >= 0 && len(done) < steps; i-- {
+		j := slices.IndexFunc(m.migrations, func(mg Migration) bool { return mg.Version == applied[i].Version })
+		mg := m.migrations[j]
+		if mg.Down == nil {
+			return done, fmt.Errorf("migration %d %s: %w", mg.Version, mg.Name, ErrIrreversible)
+		}
+		if err := m.db.Transaction(func(tx *gorm.DB) error {
+			if err := mg.Down(tx); err != nil {
+				return err
+			}
+			return tx.Delete(&SchemaMigration{}, "version = ?", mg.Version).Error
+		}); err != nil {
+			return done, fmt.Errorf("migration %d %s: %w", mg.Version, mg.Name, err)
+		}
+		done = append(done, mg)
+	}
+	return done, nil
+}
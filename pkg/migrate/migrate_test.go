@@ -0,0 +1,146 @@
+package migrate
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type widget struct {
+	ID   uint64
+	Name string
+}
+
+func openTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "sqlite.db")), &gorm.Config{
+		Logger: logger.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+var testMigrations = []Migration{
+	{
+		Version: 1,
+		Name:    "create_widgets",
+		Up:      func(tx *gorm.DB) error { return tx.Migrator().CreateTable(&widget{}) },
+		Down:    func(tx *gorm.DB) error { return tx.Migrator().DropTable(&widget{}) },
+	},
+	{
+		Version: 2,
+		Name:    "seed_widgets",
+		Up:      func(tx *gorm.DB) error { return tx.Create(&widget{Name: "a"}).Error },
+		Down:    func(tx *gorm.DB) error { return tx.Where("1 = 1").Delete(&widget{}).Error },
+	},
+}
+
+func TestUpDown(t *testing.T) {
+	db := openTestDB(t)
+	m, err := New(db, testMigrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done, err := m.Up(1)
+	if err != nil || len(done) != 1 {
+		t.Fatalf("up to 1: %v, applied %d", err, len(done))
+	}
+	if done, err = m.Up(0); err != nil || len(done) != 1 || done[0].Version != 2 {
+		t.Fatalf("up: %v, applied %v", err, done)
+	}
+	// 重复执行不会再次应用
+	if done, err = m.Up(0); err != nil || len(done) != 0 {
+		t.Fatalf("up again: %v, applied %d", err, len(done))
+	}
+
+	var count int64
+	db.Model(&widget{}).Count(&count)
+	if count != 1 {
+		t.Fatalf("expected 1 widget, got %d", count)
+	}
+
+	if done, err = m.Down(2); err != nil || len(done) != 2 {
+		t.Fatalf("down: %v, rolled back %d", err, len(done))
+	}
+	if db.Migrator().HasTable(&widget{}) {
+		t.Fatal("expected widgets table to be dropped")
+	}
+
+	status, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range status {
+		if s.AppliedAt != nil {
+			t.Fatalf("expected migration %d to be pending", s.Version)
+		}
+	}
+}
+
+func TestFailedMigrationRollsBack(t *testing.T) {
+	db := openTestDB(t)
+	m, err := New(db, append(testMigrations[:1:1], Migration{
+		Version: 2,
+		Name:    "broken",
+		Up: func(tx *gorm.DB) error {
+			tx.Create(&widget{Name: "a"})
+			return errors.New("broken")
+		},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.Up(0); err == nil {
+		t.Fatal("expected error")
+	}
+	var count int64
+	db.Model(&widget{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("expected failed migration to be rolled back, got %d widgets", count)
+	}
+	status, _ := m.Status()
+	if status[0].AppliedAt == nil || status[1].AppliedAt != nil {
+		t.Fatal("expected only the first migration to be applied")
+	}
+}
+
+func TestIrreversible(t *testing.T) {
+	db := openTestDB(t)
+	m, err := New(db, []Migration{{Version: 1, Name: "baseline", Up: func(*gorm.DB) error { return nil }}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Down(1); !errors.Is(err, ErrIrreversible) {
+		t.Fatalf("expected ErrIrreversible, got %v", err)
+	}
+}
+
+func TestRefuseNewerSchema(t *testing.T) {
+	db := openTestDB(t)
+	m, err := New(db, testMigrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(0); err != nil {
+		t.Fatal(err)
+	}
+
+	// 旧版本程序只知道第一个迁移
+	old, err := New(db, testMigrations[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := old.Up(0); err == nil {
+		t.Fatal("expected error for newer schema")
+	}
+}
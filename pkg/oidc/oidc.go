@@ -0,0 +1,266 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// ClockSkew 校验 ID Token 时间时允许的误差
+const ClockSkew = 2 * time.Minute
+
+const (
+	providerTTL      = time.Hour
+	jwksRefreshDelay = time.Minute
+)
+
+var supportedAlgs = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+var (
+	providersMu sync.Mutex
+	providers   = make(map[string]*Provider)
+
+	httpClient = &http.Client{Timeout: 10 * time.Second}
+)
+
+// Provider OIDC 提供方的 Discovery 信息及签名公钥
+type Provider struct {
+	Issuer             string `json:"issuer"`
+	AuthURL            string `json:"authorization_endpoint"`
+	TokenURL           string `json:"token_endpoint"`
+	UserInfoURL        string `json:"userinfo_endpoint"`
+	JWKSURL            string `json:"jwks_uri"`
+	EndSessionEndpoint string `json:"end_session_endpoint"`
+
+	fetchedAt time.Time
+
+	keysMu        sync.Mutex
+	keys          map[string]crypto.PublicKey
+	keysFetchedAt time.Time
+}
+
+// Get 获取 issuer 的 Discovery 信息，结果缓存一小时
+func Get(ctx context.Context, issuer string) (*Provider, error) {
+	issuer = strings.TrimSuffix(issuer, "/")
+
+	providersMu.Lock()
+	p, ok := providers[issuer]
+	providersMu.Unlock()
+	if ok && time.Since(p.fetchedAt) < providerTTL {
+		return p, nil
+	}
+
+	p = new(Provider)
+	if err := getJSON(ctx, issuer+"/.well-known/openid-configuration", p); err != nil {
+		return nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+	if strings.TrimSuffix(p.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("oidc discovery: issuer mismatch, expected %s, got %s", issuer, p.Issuer)
+	}
+	if p.AuthURL == "" || p.TokenURL == "" || p.JWKSURL == "" {
+		return nil, errors.New("oidc discovery: missing endpoints")
+	}
+	p.fetchedAt = time.Now()
+
+	providersMu.Lock()
+	providers[issuer] = p
+	providersMu.Unlock()
+	return p, nil
+}
+
+func getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: unexpected status %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// Verify 校验 ID Token 的签名、签发者、受众、有效期及 nonce，返回其中的声明
+func (p *Provider) Verify(ctx context.Context, rawIDToken, clientID, nonce string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	parser := jwt.NewParser(jwt.WithValidMethods(supportedAlgs), jwt.WithoutClaimsValidation())
+	if _, err := parser.ParseWithClaims(rawIDToken, claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.key(ctx, kid)
+	}); err != nil {
+		return nil, fmt.Errorf("oidc: invalid id token: %w", err)
+	}
+
+	now := time.Now()
+	switch {
+	case !claims.VerifyIssuer(p.Issuer, true):
+		return nil, errors.New("oidc: issuer mismatch")
+	case !claims.VerifyAudience(clientID, true):
+		return nil, errors.New("oidc: audience mismatch")
+	case !claims.VerifyExpiresAt(now.Add(-ClockSkew).Unix(), true):
+		return nil, errors.New("oidc: id token expired")
+	case !claims.VerifyIssuedAt(now.Add(ClockSkew).Unix(), false),
+		!claims.VerifyNotBefore(now.Add(ClockSkew).Unix(), false):
+		return nil, errors.New("oidc: id token used before issued")
+	}
+	if n, _ := claims["nonce"].(string); n != nonce {
+		return nil, errors.New("oidc: nonce mismatch")
+	}
+	if sub, _ := claims["sub"].(string); sub == "" {
+		return nil, errors.New("oidc: missing subject")
+	}
+	return claims, nil
+}
+
+// LogoutURL 返回 RP 发起登出的地址，提供方不支持时返回空
+func (p *Provider) LogoutURL(idToken, clientID, redirectURL string) string {
+	u, err := url.Parse(p.EndSessionEndpoint)
+	if p.EndSessionEndpoint == "" || err != nil {
+		return ""
+	}
+	q := u.Query()
+	if idToken != "" {
+		q.Set("id_token_hint", idToken)
+	}
+	q.Set("client_id", clientID)
+	if redirectURL != "" {
+		q.Set("post_logout_redirect_uri", redirectURL)
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// key 按 kid 查找公钥，找不到时重新获取 JWKS 以支持密钥轮换
+func (p *Provider) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	p.keysMu.Lock()
+	defer p.keysMu.Unlock()
+
+	if k, ok := p.lookup(kid); ok {
+		return k, nil
+	}
+	if time.Since(p.keysFetchedAt) < jwksRefreshDelay {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+
+	keys, err := fetchJWKS(ctx, p.JWKSURL)
+	p.keysFetchedAt = time.Now()
+	if err != nil {
+		return nil, err
+	}
+	p.keys = keys
+
+	if k, ok := p.lookup(kid); ok {
+		return k, nil
+	}
+	return nil, fmt.Errorf("unknown key id %q", kid)
+}
+
+func (p *Provider) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid != "" {
+		k, ok := p.keys[kid]
+		return k, ok
+	}
+	// 未指定 kid 时仅在只有一个密钥时使用
+	if len(p.keys) == 1 {
+		for _, k := range p.keys {
+			return k, true
+		}
+	}
+	return nil, false
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func fetchJWKS(ctx context.Context, url string) (map[string]crypto.PublicKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := getJSON(ctx, url, &set); err != nil {
+		return nil, fmt.Errorf("oidc jwks: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	return keys, nil
+}
+
+func (k *jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %s", k.Kty)
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,11 @@
+// Package pubsub 多个面板节点之间的消息广播
+package pubsub
+
+import "context"
+
+type PubSub interface {
+	Publish(ctx context.Context, channel string, payload []byte) error
+	// Subscribe 订阅频道并对每条消息调用 handler，阻塞直到 ctx 结束或连接出错
+	Subscribe(ctx context.Context, channel string, handler func(payload []byte)) error
+	Close() error
+}
@@ -0,0 +1,239 @@
+package pubsub
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const redisTimeout = 5 * time.Second
+
+var _ PubSub = (*Redis)(nil)
+
+// Redis 基于 Redis PUBLISH/SUBSCRIBE 的实现，只实现了所需的少量 RESP 协议
+type Redis struct {
+	addr     string
+	username string
+	password string
+	db       int
+
+	mu   sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// NewRedis 解析形如 redis://[user:password@]host:port[/db] 的地址
+func NewRedis(dsn string) (*Redis, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "redis" {
+		return nil, fmt.Errorf("unsupported redis scheme: %s", u.Scheme)
+	}
+	r := &Redis{addr: u.Host}
+	if u.Port() == "" {
+		r.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		r.username = u.User.Username()
+		r.password, _ = u.User.Password()
+	}
+	if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+		if r.db, err = strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("invalid redis db: %s", db)
+		}
+	}
+	return r, nil
+}
+
+func (r *Redis) dial(ctx context.Context) (net.Conn, *bufio.Reader, error) {
+	var d net.Dialer
+	dctx, cancel := context.WithTimeout(ctx, redisTimeout)
+	defer cancel()
+	conn, err := d.DialContext(dctx, "tcp", r.addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	br := bufio.NewReader(conn)
+
+	var cmds [][]string
+	if r.password != "" {
+		if r.username != "" {
+			cmds = append(cmds, []string{"AUTH", r.username, r.password})
+		} else {
+			cmds = append(cmds, []string{"AUTH", r.password})
+		}
+	}
+	if r.db != 0 {
+		cmds = append(cmds, []string{"SELECT", strconv.Itoa(r.db)})
+	}
+	conn.SetDeadline(time.Now().Add(redisTimeout))
+	for _, cmd := range cmds {
+		if _, err := do(conn, br, cmd...); err != nil {
+			conn.Close()
+			return nil, nil, err
+		}
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, br, nil
+}
+
+func (r *Redis) Publish(ctx context.Context, channel string, payload []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.conn == nil {
+		conn, br, err := r.dial(ctx)
+		if err != nil {
+			return err
+		}
+		r.conn, r.r = conn, br
+	}
+	r.conn.SetDeadline(time.Now().Add(redisTimeout))
+	if _, err := do(r.conn, r.r, "PUBLISH", channel, string(payload)); err != nil {
+		// 连接可能已失效，下次重新建立
+		var re redisError
+		if !errors.As(err, &re) {
+			r.conn.Close()
+			r.conn = nil
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *Redis) Subscribe(ctx context.Context, channel string, handler func(payload []byte)) error {
+	conn, br, err := r.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if err := writeCommand(conn, "SUBSCRIBE", channel); err != nil {
+		return err
+	}
+	for {
+		reply, err := readReply(br)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		msg, ok := reply.([]any)
+		if !ok || len(msg) != 3 {
+			continue
+		}
+		if kind, _ := msg[0].([]byte); string(kind) != "message" {
+			continue
+		}
+		if payload, ok := msg[2].([]byte); ok {
+			handler(payload)
+		}
+	}
+}
+
+func (r *Redis) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.conn == nil {
+		return nil
+	}
+	err := r.conn.Close()
+	r.conn = nil
+	return err
+}
+
+func do(w io.Writer, br *bufio.Reader, args ...string) (any, error) {
+	if err := writeCommand(w, args...); err != nil {
+		return nil, err
+	}
+	reply, err := readReply(br)
+	if err != nil {
+		return nil, err
+	}
+	if e, ok := reply.(redisError); ok {
+		return nil, e
+	}
+	return reply, nil
+}
+
+func writeCommand(w io.Writer, args ...string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(a), a)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// readReply 读取一个 RESP 回复，bulk string 返回 []byte，数组返回 []any
+func readReply(br *bufio.Reader) (any, error) {
+	line, err := br.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return redisError(line[1:]), nil
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(br, buf); err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]any, n)
+		for i := range items {
+			if items[i], err = readReply(br); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("redis: unexpected reply %q", line)
+	}
+}
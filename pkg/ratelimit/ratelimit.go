@@ -0,0 +1,109 @@
+package ratelimit
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Limiter 按 key 区分的令牌桶限流器，只保留最近使用的 capacity 个 key
+type Limiter struct {
+	rate  float64 // 每秒补充的令牌数
+	burst float64
+
+	mu       sync.Mutex
+	capacity int
+	ll       *list.List
+	buckets  map[string]*list.Element
+}
+
+type bucket struct {
+	key      string
+	tokens   float64
+	last     time.Time
+	exceeded int // 连续超限次数
+}
+
+// New 创建限流器，rate 为 0 时不限制
+func New(rate float64, burst, capacity int) *Limiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &Limiter{
+		rate:     rate,
+		burst:    float64(burst),
+		capacity: capacity,
+		ll:       list.New(),
+		buckets:  make(map[string]*list.Element),
+	}
+}
+
+// Allow 消耗一个令牌，超限时返回需要等待的时间及连续超限的次数
+func (l *Limiter) Allow(key string, now time.Time) (bool, time.Duration, int) {
+	if l == nil {
+		return true, 0, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.rate <= 0 {
+		return true, 0, 0
+	}
+
+	var b *bucket
+	if e, ok := l.buckets[key]; ok {
+		l.ll.MoveToFront(e)
+		b = e.Value.(*bucket)
+		b.tokens = min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+		b.last = now
+	} else {
+		b = &bucket{key: key, tokens: l.burst, last: now}
+		l.buckets[key] = l.ll.PushFront(b)
+		l.evict()
+	}
+
+	if b.tokens >= 1 {
+		b.tokens--
+		b.exceeded = 0
+		return true, 0, 0
+	}
+	b.exceeded++
+	wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	return false, wait, b.exceeded
+}
+
+// Len 返回当前保留的 key 数量
+func (l *Limiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.ll.Len()
+}
+
+// SetLimit 修改限流参数，已有的令牌桶保留，令牌数不超过新的 burst
+func (l *Limiter) SetLimit(rate float64, burst, capacity int) {
+	if burst < 1 {
+		burst = 1
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.rate = rate
+	l.burst = float64(burst)
+	l.capacity = capacity
+	for e := l.ll.Front(); e != nil; e = e.Next() {
+		b := e.Value.(*bucket)
+		b.tokens = min(b.tokens, l.burst)
+	}
+	l.evict()
+}
+
+// evict 淘汰超出容量的 key，调用方需持有 l.mu
+func (l *Limiter) evict() {
+	for l.capacity > 0 && l.ll.Len() > l.capacity {
+		oldest := l.ll.Back()
+		l.ll.Remove(oldest)
+		delete(l.buckets, oldest.Value.(*bucket).key)
+	}
+}
@@ -0,0 +1,59 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	l := New(1, 2, 2)
+	now := time.Unix(0, 0)
+
+	for i := range 2 {
+		if ok, _, _ := l.Allow("a", now); !ok {
+			t.Fatalf("request %d should be allowed", i)
+		}
+	}
+	ok, wait, exceeded := l.Allow("a", now)
+	if ok || wait != time.Second || exceeded != 1 {
+		t.Fatalf("got %v %v %d, want rejected after burst", ok, wait, exceeded)
+	}
+	if ok, _, exceeded := l.Allow("a", now); ok || exceeded != 2 {
+		t.Fatalf("got %v %d, want consecutive rejection", ok, exceeded)
+	}
+	if ok, _, _ := l.Allow("a", now.Add(time.Second)); !ok {
+		t.Fatal("token should be refilled")
+	}
+
+	l.Allow("b", now)
+	l.Allow("c", now)
+	if l.Len() != 2 {
+		t.Fatalf("got %d keys, want 2", l.Len())
+	}
+	// a 已被淘汰，重新获得完整的令牌桶
+	if ok, _, _ := l.Allow("a", now); !ok {
+		t.Fatal("evicted key should start with a full bucket")
+	}
+}
+
+func TestLimiterSetLimit(t *testing.T) {
+	l := New(1, 1, 3)
+	now := time.Unix(0, 0)
+
+	l.Allow("a", now)
+	l.Allow("b", now)
+	l.Allow("c", now)
+	l.SetLimit(1, 5, 2)
+	if l.Len() != 2 {
+		t.Fatalf("got %d keys, want 2", l.Len())
+	}
+	// 修改参数后保留已消耗的令牌
+	if ok, _, _ := l.Allow("c", now); ok {
+		t.Fatal("existing bucket should not be reset")
+	}
+
+	l.SetLimit(0, 1, 2)
+	if ok, _, _ := l.Allow("c", now); !ok {
+		t.Fatal("zero rate should not limit")
+	}
+}
@@ -0,0 +1,132 @@
+package utils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// CertReloader 从文件加载证书及可选的客户端 CA，文件变化后可重新加载而无需重启监听
+type CertReloader struct {
+	certPath, keyPath, caPath string
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	modTime   time.Time
+}
+
+// NewCertReloader 加载证书，caPath 不为空时要求客户端提供由该 CA 签发的证书
+func NewCertReloader(certPath, keyPath, caPath string) (*CertReloader, error) {
+	r := &CertReloader{certPath: certPath, keyPath: keyPath, caPath: caPath}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload 重新读取证书文件，失败时继续使用原有证书
+func (r *CertReloader) Reload() error {
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certPath, r.keyPath)
+	if err != nil {
+		return fmt.Errorf("load certificate: %w", err)
+	}
+	var pool *x509.CertPool
+	if r.caPath != "" {
+		pem, err := os.ReadFile(r.caPath)
+		if err != nil {
+			return fmt.Errorf("load client ca: %w", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return errors.New("load client ca: no certificate found in " + r.caPath)
+		}
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.clientCAs = pool
+	r.modTime = modTime
+	r.mu.Unlock()
+	return nil
+}
+
+// ReloadIfChanged 文件修改时间变化时重新加载
+func (r *CertReloader) ReloadIfChanged() (bool, error) {
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return false, err
+	}
+	r.mu.RLock()
+	changed := !modTime.Equal(r.modTime)
+	r.mu.RUnlock()
+	if !changed {
+		return false, nil
+	}
+	return true, r.Reload()
+}
+
+func (r *CertReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, path := range []string{r.certPath, r.keyPath, r.caPath} {
+		if path == "" {
+			continue
+		}
+		fi, err := os.Stat(path)
+		if err != nil {
+			return latest, err
+		}
+		if fi.ModTime().After(latest) {
+			latest = fi.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// TLSConfig 每次握手使用最新加载的证书
+func (r *CertReloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: []string{"h2"},
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+			cfg := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				NextProtos:   []string{"h2"},
+				Certificates: []tls.Certificate{*r.cert},
+			}
+			if r.clientCAs != nil {
+				cfg.ClientCAs = r.clientCAs
+				cfg.ClientAuth = tls.RequireAndVerifyClientCert
+			}
+			return cfg, nil
+		},
+	}
+}
+
+// CertMatchesIdentity 证书的 CN 或任一 SAN（DNS 名称、URI，含 urn:uuid: 形式）与 id 相同
+func CertMatchesIdentity(cert *x509.Certificate, id string) bool {
+	if cert.Subject.CommonName == id {
+		return true
+	}
+	for _, name := range cert.DNSNames {
+		if name == id {
+			return true
+		}
+	}
+	for _, u := range cert.URIs {
+		if s := u.String(); s == id || s == "urn:uuid:"+id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,149 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func issueCert(t *testing.T, cn string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, tls.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"localhost"},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage = x509.KeyUsageCertSign
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return cert, key, tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func writePEM(t *testing.T, path string, cert *x509.Certificate, key *ecdsa.PrivateKey) {
+	t.Helper()
+	var data []byte
+	if cert != nil {
+		data = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	} else {
+		der, _ := x509.MarshalECPrivateKey(key)
+		data = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath, caPath := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"), filepath.Join(dir, "ca.pem")
+
+	ca, caKey, _ := issueCert(t, "ca", nil, nil)
+	server, serverKey, _ := issueCert(t, "server", ca, caKey)
+	_, _, client := issueCert(t, "agent-uuid", ca, caKey)
+	writePEM(t, caPath, ca, nil)
+	writePEM(t, certPath, server, nil)
+	writePEM(t, keyPath, nil, serverKey)
+
+	if _, err := NewCertReloader(certPath, keyPath, filepath.Join(dir, "missing.pem")); err == nil {
+		t.Fatal("expected error for missing client ca")
+	}
+	r, err := NewCertReloader(certPath, keyPath, caPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := tls.Listen("tcp", "127.0.0.1:0", r.TLSConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	dial := func(certs []tls.Certificate) (*x509.Certificate, error) {
+		conn, err := tls.Dial("tcp", l.Addr().String(), &tls.Config{RootCAs: roots, ServerName: "localhost", Certificates: certs})
+		if err != nil {
+			return nil, err
+		}
+		defer conn.Close()
+		// TLS 1.3 中服务端在客户端完成握手后才校验客户端证书
+		if _, err = conn.Read(make([]byte, 1)); err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		return conn.ConnectionState().PeerCertificates[0], nil
+	}
+
+	if _, err := dial(nil); err == nil {
+		t.Fatal("expected handshake without client certificate to fail")
+	}
+	got, err := dial([]tls.Certificate{client})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Subject.CommonName != "server" {
+		t.Fatalf("unexpected server certificate %s", got.Subject.CommonName)
+	}
+
+	// 替换证书后重新加载
+	renewed, renewedKey, _ := issueCert(t, "renewed", ca, caKey)
+	writePEM(t, certPath, renewed, nil)
+	writePEM(t, keyPath, nil, renewedKey)
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(certPath, future, future)
+	if changed, err := r.ReloadIfChanged(); !changed || err != nil {
+		t.Fatalf("expected reload, got %v %v", changed, err)
+	}
+	if got, err = dial([]tls.Certificate{client}); err != nil || got.Subject.CommonName != "renewed" {
+		t.Fatalf("expected renewed certificate, got %v %v", got, err)
+	}
+	if changed, _ := r.ReloadIfChanged(); changed {
+		t.Fatal("unexpected reload without changes")
+	}
+}
+
+func TestCertMatchesIdentity(t *testing.T) {
+	u, _ := url.Parse("urn:uuid:1f0e")
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "agent"}, DNSNames: []string{"abcd"}, URIs: []*url.URL{u}}
+	for id, want := range map[string]bool{"agent": true, "abcd": true, "1f0e": true, "other": false} {
+		if got := CertMatchesIdentity(cert, id); got != want {
+			t.Errorf("%s: expected %v, got %v", id, want, got)
+		}
+	}
+}
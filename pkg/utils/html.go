@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"slices"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// 允许保留的标签，其他标签去掉后保留其中的内容
+var sanitizeAllowedTags = []atom.Atom{
+	atom.A, atom.B, atom.Br, atom.Code, atom.Div, atom.Em, atom.Hr, atom.I, atom.Img,
+	atom.Li, atom.Ol, atom.P, atom.Small, atom.Span, atom.Strong, atom.Sub, atom.Sup, atom.U, atom.Ul,
+}
+
+// 连同内容一起删除的标签
+var sanitizeDroppedTags = []atom.Atom{
+	atom.Script, atom.Style, atom.Iframe, atom.Frame, atom.Frameset, atom.Object, atom.Embed,
+	atom.Noscript, atom.Template, atom.Svg, atom.Math, atom.Form, atom.Link, atom.Meta, atom.Base, atom.Title,
+}
+
+var sanitizeAllowedAttrs = []string{"href", "src", "alt", "title", "target", "rel", "class", "width", "height"}
+
+// SanitizeHTML 按白名单过滤 HTML 片段，去除脚本、事件处理属性以及非 http(s)/mailto 的链接
+func SanitizeHTML(s string) string {
+	nodes, err := html.ParseFragment(strings.NewReader(s), &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body})
+	if err != nil {
+		return html.EscapeString(s)
+	}
+
+	var b strings.Builder
+	for _, n := range nodes {
+		sanitizeNode(&b, n)
+	}
+	return b.String()
+}
+
+func sanitizeNode(b *strings.Builder, n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		b.WriteString(html.EscapeString(n.Data))
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+
+	if slices.Contains(sanitizeDroppedTags, n.DataAtom) {
+		return
+	}
+	allowed := slices.Contains(sanitizeAllowedTags, n.DataAtom)
+	if allowed {
+		b.WriteByte('<')
+		b.WriteString(n.Data)
+		for _, attr := range n.Attr {
+			key := strings.ToLower(attr.Key)
+			if attr.Namespace != "" || !slices.Contains(sanitizeAllowedAttrs, key) {
+				continue
+			}
+			if (key == "href" || key == "src") && !safeURL(attr.Val) {
+				continue
+			}
+			b.WriteByte(' ')
+			b.WriteString(key)
+			b.WriteString(`="`)
+			b.WriteString(html.EscapeString(attr.Val))
+			b.WriteByte('"')
+		}
+		b.WriteByte('>')
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		sanitizeNode(b, c)
+	}
+	if allowed && n.DataAtom != atom.Br && n.DataAtom != atom.Hr && n.DataAtom != atom.Img {
+		b.WriteString("</")
+		b.WriteString(n.Data)
+		b.WriteByte('>')
+	}
+}
+
+// safeURL 只允许相对地址以及 http、https、mailto 协议
+func safeURL(u string) bool {
+	u = strings.ToLower(strings.TrimSpace(u))
+	scheme, _, found := strings.Cut(u, ":")
+	if !found || strings.ContainsAny(scheme, "/?#") {
+		return true
+	}
+	return scheme == "http" || scheme == "https" || scheme == "mailto"
+}
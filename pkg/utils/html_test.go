@@ -0,0 +1,19 @@
+package utils
+
+import "testing"
+
+func TestSanitizeHTML(t *testing.T) {
+	cases := []testSt{
+		{input: `<a href="https://example.com" onclick="x()">Home</a>`, output: `<a href="https://example.com">Home</a>`},
+		{input: `Powered by <b>nezha</b><script>alert(1)</script>`, output: `Powered by <b>nezha</b>`},
+		{input: `<a href=" JavaScript:alert(1)">x</a>`, output: `<a>x</a>`},
+		{input: `<img src="/logo.png" onerror="alert(1)">`, output: `<img src="/logo.png">`},
+		{input: `<center><i>&lt;3</i></center>`, output: `<i>&lt;3</i>`},
+		{input: `<svg onload="alert(1)"><text>x</text></svg>`, output: ``},
+	}
+	for _, c := range cases {
+		if got := SanitizeHTML(c.input); got != c.output {
+			t.Errorf("SanitizeHTML(%q) = %q, expected %q", c.input, got, c.output)
+		}
+	}
+}
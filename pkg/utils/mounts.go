@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"bufio"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// 网络文件系统，SQLite 的文件锁在这些文件系统上不可靠
+var networkFilesystems = []string{"nfs", "nfs4", "cifs", "smb3", "smbfs", "9p", "afs", "ceph", "glusterfs", "fuse.sshfs", "fuse.rclone", "fuse.glusterfs"}
+
+// MountFSType 从 /proc/mounts 格式的内容中找出路径所在的挂载点及其文件系统类型，path 需为绝对路径
+func MountFSType(mounts, path string) (mountPoint, fsType string) {
+	path = filepath.Clean(path)
+	scanner := bufio.NewScanner(strings.NewReader(mounts))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 {
+			continue
+		}
+		// 挂载点中的空格等字符以八进制转义
+		mp := unescapeMountPath(fields[1])
+		if !pathHasPrefix(path, mp) || len(mp) < len(mountPoint) {
+			continue
+		}
+		// 同一挂载点多次挂载时以最后一次为准
+		mountPoint, fsType = mp, fields[2]
+	}
+	return
+}
+
+// IsNetworkFS 文件系统类型是否为网络文件系统
+func IsNetworkFS(fsType string) bool {
+	return slices.Contains(networkFilesystems, fsType)
+}
+
+func pathHasPrefix(path, prefix string) bool {
+	if prefix == "/" {
+		return true
+	}
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+func unescapeMountPath(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) && isOctal(s[i+1]) && isOctal(s[i+2]) && isOctal(s[i+3]) {
+			b.WriteByte((s[i+1]-'0')<<6 | (s[i+2]-'0')<<3 | (s[i+3] - '0'))
+			i += 3
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func isOctal(c byte) bool {
+	return c >= '0' && c <= '7'
+}
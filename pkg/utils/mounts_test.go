@@ -0,0 +1,30 @@
+package utils
+
+import "testing"
+
+func TestMountFSType(t *testing.T) {
+	mounts := `/dev/sda1 / ext4 rw,relatime 0 0
+proc /proc proc rw,nosuid 0 0
+nas:/export/nezha /opt/nezha nfs4 rw,relatime 0 0
+//fileserver/share /mnt/my\040share cifs rw 0 0
+tmpfs /opt/nezha/tmp tmpfs rw 0 0
+`
+	cases := []struct {
+		path       string
+		mountPoint string
+		fsType     string
+		network    bool
+	}{
+		{"/opt/nezha/data/sqlite.db", "/opt/nezha", "nfs4", true},
+		{"/opt/nezha/tmp/sqlite.db", "/opt/nezha/tmp", "tmpfs", false},
+		{"/opt/nezhadata/sqlite.db", "/", "ext4", false},
+		{"/mnt/my share/sqlite.db", "/mnt/my share", "cifs", true},
+		{"/opt/nezha/../data/sqlite.db", "/", "ext4", false},
+	}
+	for _, c := range cases {
+		mp, fs := MountFSType(mounts, c.path)
+		if mp != c.mountPoint || fs != c.fsType || IsNetworkFS(fs) != c.network {
+			t.Errorf("%s: got %q %q, want %q %q", c.path, mp, fs, c.mountPoint, c.fsType)
+		}
+	}
+}
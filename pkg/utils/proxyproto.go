@@ -0,0 +1,172 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const proxyProtoTimeout = 5 * time.Second
+
+var (
+	proxyProtoV1Sig = []byte("PROXY ")
+	proxyProtoV2Sig = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+	errProxyProtoHeader = errors.New("invalid PROXY protocol header")
+)
+
+// ProxyProtoListener 解析来自可信代理的连接上的 PROXY 协议头（v1 与 v2），
+// 之后连接的 RemoteAddr 为代理转发的客户端地址。不可信来源的连接保持原样，
+// Trusted 为空时不信任任何来源
+type ProxyProtoListener struct {
+	net.Listener
+	Trusted func(netip.Addr) bool
+}
+
+func (l *ProxyProtoListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	addr, err := ParseAddrPort(conn.RemoteAddr().String())
+	if err != nil || l.Trusted == nil || !l.Trusted(addr) {
+		return conn, nil
+	}
+	return &proxyProtoConn{Conn: conn}, nil
+}
+
+// proxyProtoConn 在第一次读取或获取地址时解析协议头，避免阻塞 Accept
+type proxyProtoConn struct {
+	net.Conn
+
+	once   sync.Once
+	r      *bufio.Reader
+	remote net.Addr
+	err    error
+}
+
+func (c *proxyProtoConn) init() {
+	c.once.Do(func() {
+		c.Conn.SetReadDeadline(time.Now().Add(proxyProtoTimeout))
+		c.r = bufio.NewReader(c.Conn)
+		c.remote, c.err = readProxyProtoHeader(c.r)
+		c.Conn.SetReadDeadline(time.Time{})
+	})
+}
+
+func (c *proxyProtoConn) Read(p []byte) (int, error) {
+	c.init()
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.r.Read(p)
+}
+
+func (c *proxyProtoConn) RemoteAddr() net.Addr {
+	c.init()
+	if c.remote != nil {
+		return c.remote
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// readProxyProtoHeader 读取协议头，返回客户端地址，LOCAL 与 UNKNOWN 连接返回 nil
+func readProxyProtoHeader(r *bufio.Reader) (net.Addr, error) {
+	sig, err := r.Peek(len(proxyProtoV1Sig))
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(sig, proxyProtoV1Sig) {
+		return readProxyProtoV1(r)
+	}
+	sig, err = r.Peek(len(proxyProtoV2Sig))
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(sig, proxyProtoV2Sig) {
+		return readProxyProtoV2(r)
+	}
+	return nil, errProxyProtoHeader
+}
+
+// readProxyProtoV1 PROXY TCP4 <src> <dst> <sport> <dport>\r\n
+func readProxyProtoV1(r *bufio.Reader) (net.Addr, error) {
+	var line []byte
+	for len(line) < 107 {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+	}
+	s, ok := strings.CutSuffix(string(line), "\r\n")
+	if !ok {
+		return nil, errProxyProtoHeader
+	}
+	fields := strings.Fields(s)
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, errProxyProtoHeader
+	}
+	addr, err := netip.ParseAddr(fields[2])
+	if err != nil {
+		return nil, errProxyProtoHeader
+	}
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if err != nil {
+		return nil, errProxyProtoHeader
+	}
+	return net.TCPAddrFromAddrPort(netip.AddrPortFrom(addr, uint16(port))), nil
+}
+
+func readProxyProtoV2(r *bufio.Reader) (net.Addr, error) {
+	var hdr [16]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, err
+	}
+	verCmd, family := hdr[12], hdr[13]
+	if verCmd>>4 != 2 {
+		return nil, errProxyProtoHeader
+	}
+	payload := make([]byte, binary.BigEndian.Uint16(hdr[14:]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	// LOCAL 为代理自身发起的连接（如健康检查）
+	if verCmd&0xf == 0 {
+		return nil, nil
+	}
+
+	var addr netip.Addr
+	var port uint16
+	switch family >> 4 {
+	case 1: // AF_INET
+		if len(payload) < 12 {
+			return nil, errProxyProtoHeader
+		}
+		addr = netip.AddrFrom4([4]byte(payload[:4]))
+		port = binary.BigEndian.Uint16(payload[8:])
+	case 2: // AF_INET6
+		if len(payload) < 36 {
+			return nil, errProxyProtoHeader
+		}
+		addr = netip.AddrFrom16([16]byte(payload[:16]))
+		port = binary.BigEndian.Uint16(payload[32:])
+	default:
+		return nil, nil
+	}
+	return net.TCPAddrFromAddrPort(netip.AddrPortFrom(addr, port)), nil
+}
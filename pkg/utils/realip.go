@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"strings"
+)
+
+// TrustedProxies 可信代理的地址段，为空时不信任任何来源
+type TrustedProxies []netip.Prefix
+
+// ParseTrustedProxies 解析 IP 或 CIDR 列表
+func ParseTrustedProxies(list []string) (TrustedProxies, error) {
+	proxies := make(TrustedProxies, 0, len(list))
+	for _, s := range list {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			addr, err := netip.ParseAddr(s)
+			if err != nil {
+				return nil, err
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		proxies = append(proxies, prefix.Masked())
+	}
+	return proxies, nil
+}
+
+// Trusted 连接地址是否来自可信代理
+func (t TrustedProxies) Trusted(addr netip.Addr) bool {
+	return t.contains(addr)
+}
+
+func (t TrustedProxies) contains(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, p := range t {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// RealIP 根据连接地址与请求头的值解析客户端的真实 IP。
+// 连接不是来自可信代理时忽略请求头，直接使用连接地址；
+// X-Forwarded-For 等多级的值从右向左跳过可信代理，取第一个不可信的地址。
+// 未配置可信代理时不信任任何来源，总是使用连接地址
+func (t TrustedProxies) RealIP(peer string, header string) (string, error) {
+	peerAddr, err := ParseAddrPort(peer)
+	if err != nil {
+		return "", err
+	}
+	if !t.Trusted(peerAddr) {
+		return peerAddr.String(), nil
+	}
+
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return "", errors.New("real ip header not found")
+	}
+	hops := strings.Split(header, ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := ParseAddrPort(hops[i])
+		if err != nil {
+			return "", err
+		}
+		if i > 0 && t.contains(addr) {
+			continue
+		}
+		return addr.String(), nil
+	}
+	return "", errors.New("invalid ip")
+}
+
+// ParseAddrPort 解析带或不带端口的地址
+func ParseAddrPort(s string) (netip.Addr, error) {
+	s = strings.TrimSpace(s)
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	addr, err := netip.ParseAddr(strings.Trim(s, "[]"))
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	return addr.Unmap(), nil
+}
@@ -0,0 +1,130 @@
+package utils
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestTrustedProxiesRealIP(t *testing.T) {
+	proxies, err := ParseTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1", "fd00::/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name   string
+		peer   string
+		header string
+		want   string
+		err    bool
+	}{
+		{"untrusted peer ignores header", "203.0.113.9:1234", "1.1.1.1", "203.0.113.9", false},
+		{"untrusted peer without header", "203.0.113.9:1234", "", "203.0.113.9", false},
+		{"trusted peer single hop", "10.0.0.1:1234", "1.1.1.1", "1.1.1.1", false},
+		{"chain skips trusted hops", "10.0.0.1:1234", "6.6.6.6, 1.1.1.1, 10.0.0.2, 192.168.1.1", "1.1.1.1", false},
+		{"spoofed leftmost value ignored", "10.0.0.1:1234", "8.8.8.8, 1.1.1.1", "1.1.1.1", false},
+		{"all hops trusted", "10.0.0.1:1234", "10.0.0.3, 10.0.0.2", "10.0.0.3", false},
+		{"ipv6 peer", "[fd00::1]:1234", "2001:db8::1", "2001:db8::1", false},
+		{"mapped ipv4 peer", "[::ffff:10.0.0.1]:1234", "1.1.1.1", "1.1.1.1", false},
+		{"hop with port", "10.0.0.1:1234", "1.1.1.1:5678", "1.1.1.1", false},
+		{"missing header", "10.0.0.1:1234", "", "", true},
+		{"invalid hop", "10.0.0.1:1234", "1.1.1.1, bogus", "", true},
+	}
+	for _, c := range cases {
+		got, err := proxies.RealIP(c.peer, c.header)
+		if (err != nil) != c.err || got != c.want {
+			t.Errorf("%s: got %q, %v, want %q", c.name, got, err, c.want)
+		}
+	}
+
+	// 未配置可信代理时不信任任何来源，忽略请求头
+	var none TrustedProxies
+	if got, _ := none.RealIP("203.0.113.9:1234", "8.8.8.8, 1.1.1.1"); got != "203.0.113.9" {
+		t.Errorf("empty proxies: got %q", got)
+	}
+}
+
+func TestReadProxyProtoHeader(t *testing.T) {
+	v2 := "\r\n\r\n\x00\r\nQUIT\n" + "\x21\x11\x00\x0c" +
+		"\x01\x02\x03\x04" + "\x05\x06\x07\x08" + "\x1f\x90" + "\x00\x50"
+	cases := []struct {
+		name string
+		data string
+		want string
+		err  bool
+	}{
+		{"v1 tcp4", "PROXY TCP4 1.2.3.4 5.6.7.8 8080 80\r\nGET /", "1.2.3.4:8080", false},
+		{"v1 tcp6", "PROXY TCP6 2001:db8::1 2001:db8::2 8080 80\r\nGET /", "[2001:db8::1]:8080", false},
+		{"v1 unknown", "PROXY UNKNOWN\r\nGET /", "", false},
+		{"v2 tcp4", v2 + "GET /", "1.2.3.4:8080", false},
+		{"v2 local", "\r\n\r\n\x00\r\nQUIT\n\x20\x00\x00\x00GET /", "", false},
+		{"missing header", "GET / HTTP/1.1\r\n", "", true},
+		{"v1 malformed", "PROXY TCP4 1.2.3.4\r\n", "", true},
+	}
+	for _, c := range cases {
+		r := bufio.NewReader(strings.NewReader(c.data))
+		addr, err := readProxyProtoHeader(r)
+		if (err != nil) != c.err {
+			t.Errorf("%s: unexpected error %v", c.name, err)
+			continue
+		}
+		var got string
+		if addr != nil {
+			got = addr.String()
+		}
+		if got != c.want {
+			t.Errorf("%s: got %q, want %q", c.name, got, c.want)
+		}
+		if err == nil {
+			if rest, _ := r.ReadString('/'); rest != "GET /" {
+				t.Errorf("%s: header not consumed, got %q", c.name, rest)
+			}
+		}
+	}
+}
+
+func TestProxyProtoListener(t *testing.T) {
+	const payload = "PROXY TCP4 1.2.3.4 5.6.7.8 8080 80\r\nhello"
+	cases := []struct {
+		name    string
+		trusted func(netip.Addr) bool
+		remote  string
+		data    string
+	}{
+		{"trusted proxy", func(netip.Addr) bool { return true }, "1.2.3.4:8080", "hello"},
+		// 不可信来源的协议头不解析，连接地址与数据保持原样
+		{"untrusted peer", func(netip.Addr) bool { return false }, "127.0.0.1", payload},
+		{"no trusted proxies", nil, "127.0.0.1", payload},
+	}
+	for _, c := range cases {
+		inner, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		l := &ProxyProtoListener{Listener: inner, Trusted: c.trusted}
+
+		client, err := net.Dial("tcp", inner.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		client.Write([]byte(payload))
+		client.Close()
+
+		conn, err := l.Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := conn.RemoteAddr().String(); !strings.HasPrefix(got, c.remote) {
+			t.Errorf("%s: remote address %q, want %q", c.name, got, c.remote)
+		}
+		if data, _ := io.ReadAll(conn); string(data) != c.data {
+			t.Errorf("%s: got data %q, want %q", c.name, data, c.data)
+		}
+		conn.Close()
+		l.Close()
+	}
+}
@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestClockOffset(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer conn.Close()
+
+	// 模拟比本机快一分钟的服务器
+	const skew = time.Minute
+	go func() {
+		buf := make([]byte, 48)
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil || n < 48 {
+			return
+		}
+		resp := make([]byte, 48)
+		resp[0] = 0x24 // LI = 0, VN = 4, Mode = 4（服务器）
+		resp[1] = 2
+		now := toNTPTime(time.Now().Add(skew))
+		binary.BigEndian.PutUint64(resp[32:], now)
+		binary.BigEndian.PutUint64(resp[40:], now)
+		conn.WriteTo(resp, addr)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	offset, err := ClockOffset(ctx, conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := offset - skew; d < -time.Second || d > time.Second {
+		t.Errorf("offset = %v, want about %v", offset, skew)
+	}
+
+	ts := time.Unix(1700000000, 123456789)
+	if got := fromNTPTime(toNTPTime(ts)); got.Sub(ts).Abs() > time.Microsecond {
+		t.Errorf("ntp time round trip: got %v, want %v", got, ts)
+	}
+}
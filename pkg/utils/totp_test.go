@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+func TestValidateTOTP(t *testing.T) {
+	// RFC 6238 附录 B 中 SHA1 的测试向量，取后 6 位
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+	cases := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, c := range cases {
+		step, ok := ValidateTOTP(secret, c.code, time.Unix(c.unix, 0))
+		if !ok || step != c.unix/totpPeriod {
+			t.Errorf("ValidateTOTP(%d, %s) = %d, %v", c.unix, c.code, step, ok)
+		}
+	}
+
+	if _, ok := ValidateTOTP(secret, "287082", time.Unix(59+3*totpPeriod, 0)); ok {
+		t.Error("expired code should be rejected")
+	}
+	if _, ok := ValidateTOTP(secret, "28708", time.Unix(59, 0)); ok {
+		t.Error("short code should be rejected")
+	}
+}
@@ -3,7 +3,6 @@ package utils
 import (
 	"cmp"
 	"crypto/rand"
-	"errors"
 	"fmt"
 	"iter"
 	"maps"
@@ -12,7 +11,6 @@ import (
 	"regexp"
 	"slices"
 	"strconv"
-	"strings"
 
 	"golang.org/x/exp/constraints"
 )
@@ -58,19 +56,6 @@ func BinaryToIPString(b []byte) string {
 	return addr.Unmap().String()
 }
 
-func GetIPFromHeader(headerValue string) (string, error) {
-	a := strings.Split(headerValue, ",")
-	h := strings.TrimSpace(a[len(a)-1])
-	ip, err := netip.ParseAddr(h)
-	if err != nil {
-		return "", err
-	}
-	if !ip.IsValid() {
-		return "", errors.New("invalid ip")
-	}
-	return ip.String(), nil
-}
-
 func GenerateRandomString(n int) (string, error) {
 	const letters = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
 	lettersLength := big.NewInt(int64(len(letters)))
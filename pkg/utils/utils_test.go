@@ -180,3 +180,22 @@ func TestUnique(t *testing.T) {
 		}
 	}
 }
+
+func TestEncryptString(t *testing.T) {
+	ciphertext, err := EncryptString("secret", "token=abc")
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if ciphertext == "token=abc" {
+		t.Fatal("Expected ciphertext to differ from plaintext")
+	}
+
+	plaintext, err := DecryptString("secret", ciphertext)
+	if err != nil || plaintext != "token=abc" {
+		t.Fatalf("Expected %s, but got %s, %v", "token=abc", plaintext, err)
+	}
+
+	if _, err := DecryptString("another", ciphertext); err == nil {
+		t.Fatal("Expected decryption with wrong secret to fail")
+	}
+}
@@ -0,0 +1,63 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestColumnName(t *testing.T) {
+	cases := map[int]string{0: "A", 25: "Z", 26: "AA", 51: "AZ", 52: "BA", 701: "ZZ", 702: "AAA"}
+	for i, want := range cases {
+		if got := ColumnName(i); got != want {
+			t.Fatalf("ColumnName(%d) = %s, want %s", i, got, want)
+		}
+	}
+}
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, "servers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteRow("name", "mem"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteRow("a<b>&\"c\"", uint64(1024)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sheet string
+	for _, f := range zr.File {
+		if f.Name != "xl/worksheets/sheet1.xml" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		sheet = string(data)
+	}
+
+	for _, want := range []string{
+		`<c r="A2" t="inlineStr"><is><t xml:space="preserve">a&lt;b&gt;&amp;&#34;c&#34;</t></is></c>`,
+		`<c r="B2"><v>1024</v></c>`,
+		`</sheetData></worksheet>`,
+	} {
+		if !strings.Contains(sheet, want) {
+			t.Fatalf("sheet does not contain %s:\n%s", want, sheet)
+		}
+	}
+}
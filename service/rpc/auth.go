@@ -23,7 +23,7 @@ type authHandler struct {
 func (a *authHandler) Check(ctx context.Context) (uint64, error) {
 	md, ok := metadata.FromIncomingContext(ctx)
 	if !ok {
-		return 0, status.Errorf(codes.Unauthenticated, "获取 metaData 失败")
+		return 0, status.Error(codes.Unauthenticated, singleton.Localizer.T("failed to read metadata"))
 	}
 
 	var clientSecret string
@@ -32,17 +32,21 @@ func (a *authHandler) Check(ctx context.Context) (uint64, error) {
 	}
 
 	if clientSecret == "" {
-		return 0, status.Error(codes.Unauthenticated, "客户端认证失败")
+		return 0, status.Error(codes.Unauthenticated, singleton.Localizer.T("client authentication failed"))
 	}
 
 	ip, _ := ctx.Value(model.CtxKeyRealIP{}).(string)
 
 	singleton.UserLock.RLock()
 	userId, ok := singleton.AgentSecretToUserId[clientSecret]
+	if ok && singleton.UserInfoMap[userId].Role == model.RoleViewer {
+		// 只读用户不能接入服务器
+		ok = false
+	}
 	if !ok {
 		singleton.UserLock.RUnlock()
 		model.BlockIP(singleton.DB, ip, model.WAFBlockReasonTypeAgentAuthFail, model.BlockIDgRPC)
-		return 0, status.Error(codes.Unauthenticated, "客户端认证失败")
+		return 0, status.Error(codes.Unauthenticated, singleton.Localizer.T("client authentication failed"))
 	}
 	singleton.UserLock.RUnlock()
 
@@ -55,7 +59,12 @@ func (a *authHandler) Check(ctx context.Context) (uint64, error) {
 
 	// 验证客户端标识符不为空且长度合理（1-64个字符）
 	if clientUUID == "" || len(clientUUID) > 64 {
-		return 0, status.Error(codes.Unauthenticated, "客户端标识符不合法，必须为1-64个字符")
+		return 0, status.Error(codes.Unauthenticated, singleton.Localizer.T("invalid client uuid, must be 1-64 characters"))
+	}
+
+	if err := singleton.CheckAgentCertificate(ctx, clientUUID); err != nil {
+		log.Printf("NEZHA>> Agent %s from %s rejected: %v", clientUUID, ip, err)
+		return 0, status.Error(codes.Unauthenticated, singleton.Localizer.T("client certificate authentication failed"))
 	}
 
 	clientID, hasID := singleton.ServerShared.UUIDToID(clientUUID)
@@ -89,15 +98,15 @@ func (a *authHandler) Check(ctx context.Context) (uint64, error) {
 							// 管理员可以使用任意分组，查找所有用户的分组
 							if err := singleton.DB.Where("name = ?", groupName).First(&serverGroup).Error; err != nil {
 								if err == gorm.ErrRecordNotFound {
-									return 0, status.Error(codes.Unauthenticated, "指定的服务器分组不存在")
+									return 0, status.Error(codes.Unauthenticated, singleton.Localizer.T("the specified server group does not exist"))
 								}
-								return 0, status.Error(codes.Unauthenticated, "查询服务器分组失败")
+								return 0, status.Error(codes.Unauthenticated, singleton.Localizer.T("failed to query server group"))
 							}
 						} else {
-							return 0, status.Error(codes.Unauthenticated, "指定的服务器分组不存在或无权限访问")
+							return 0, status.Error(codes.Unauthenticated, singleton.Localizer.T("the specified server group does not exist or is not accessible"))
 						}
 					} else {
-						return 0, status.Error(codes.Unauthenticated, "查询服务器分组失败")
+						return 0, status.Error(codes.Unauthenticated, singleton.Localizer.T("failed to query server group"))
 					}
 				}
 
@@ -149,12 +158,18 @@ func (a *authHandler) Check(ctx context.Context) (uint64, error) {
 			if value, ok := md["server_group_name"]; ok {
 				groupName = strings.TrimSpace(value[0])
 			}
-			log.Printf("NEZHA>> 自动注册服务器: UUID=%s, Name=%s, Group=%s (ID:%d), UserID=%d",
-				clientUUID, serverName, groupName, serverGroupID, userId)
+			log.Print("NEZHA>> ", singleton.Localizer.Tf("Server auto-registered: UUID=%s, Name=%s, Group=%s (ID:%d), UserID=%d",
+				clientUUID, serverName, groupName, serverGroupID, userId))
 		} else {
-			log.Printf("NEZHA>> 自动注册服务器: UUID=%s, Name=%s, UserID=%d",
-				clientUUID, serverName, userId)
+			log.Print("NEZHA>> ", singleton.Localizer.Tf("Server auto-registered: UUID=%s, Name=%s, UserID=%d",
+				clientUUID, serverName, userId))
+		}
+
+		changes := []model.ServerEventChange{{Field: "name", New: serverName}}
+		if serverGroupID > 0 {
+			changes = append(changes, model.ServerEventChange{Field: "server_group", New: serverGroupID})
 		}
+		singleton.RecordServerEvent(s.ID, model.ServerEventRegistered, model.ServerEventActorAgent, 0, changes...)
 
 		model.InitServer(&s)
 		singleton.ServerShared.Update(&s, clientUUID)
@@ -162,5 +177,12 @@ func (a *authHandler) Check(ctx context.Context) (uint64, error) {
 		clientID = s.ID
 	}
 
+	// Agent 每次连接时上报能力，重启后按新的本地配置更新
+	var capabilities *string
+	if value, ok := md["capabilities"]; ok && len(value) > 0 {
+		capabilities = &value[0]
+	}
+	singleton.ServerShared.UpdateCapabilities(clientID, capabilities)
+
 	return clientID, nil
 }
@@ -17,6 +17,16 @@ type ioStreamContext struct {
 	agentIoConnectCh chan struct{}
 	userIoChOnce     sync.Once
 	agentIoChOnce    sync.Once
+	recorder         io.WriteCloser // 录制 Agent 端的输出，关闭流时一并关闭
+
+	// 创建流的用户及目标服务器，用户连接时再次校验权限
+	userID   uint64
+	serverID uint64
+}
+
+// Owner 返回创建流的用户及目标服务器，未记录时均为 0
+func (ctx *ioStreamContext) Owner() (userID, serverID uint64) {
+	return ctx.userID, ctx.serverID
 }
 
 type bp struct {
@@ -63,12 +73,37 @@ func (s *NezhaHandler) CloseStream(streamId string) error {
 		if ctx.agentIo != nil {
 			ctx.agentIo.Close()
 		}
+		if ctx.recorder != nil {
+			ctx.recorder.Close()
+		}
 		delete(s.ioStreams, streamId)
 	}
 
 	return nil
 }
 
+// RecordStream 将 Agent 端的输出同时写入 recorder，需在 StartStream 前调用
+func (s *NezhaHandler) RecordStream(streamId string, recorder io.WriteCloser) error {
+	stream, err := s.GetStream(streamId)
+	if err != nil {
+		return err
+	}
+
+	stream.recorder = recorder
+	return nil
+}
+
+// SetStreamOwner 记录创建流的用户及目标服务器
+func (s *NezhaHandler) SetStreamOwner(streamId string, userID, serverID uint64) error {
+	stream, err := s.GetStream(streamId)
+	if err != nil {
+		return err
+	}
+
+	stream.userID, stream.serverID = userID, serverID
+	return nil
+}
+
 func (s *NezhaHandler) UserConnected(streamId string, userIo io.ReadWriteCloser) error {
 	stream, err := s.GetStream(streamId)
 	if err != nil {
@@ -137,10 +172,15 @@ LOOP:
 	isDone := new(atomic.Bool)
 	endCh := make(chan struct{})
 
+	var userIo io.Writer = stream.userIo
+	if stream.recorder != nil {
+		userIo = io.MultiWriter(stream.userIo, stream.recorder)
+	}
+
 	go func() {
 		bp := bufPool.Get().(*bp)
 		defer bufPool.Put(bp)
-		_, innerErr := io.CopyBuffer(stream.userIo, stream.agentIo, bp.buf)
+		_, innerErr := io.CopyBuffer(userIo, stream.agentIo, bp.buf)
 		if innerErr != nil {
 			err = innerErr
 		}
@@ -9,6 +9,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/goccy/go-json"
 	"github.com/jinzhu/copier"
 	geoipx "github.com/nezhahq/nezha/pkg/geoip"
 	"github.com/nezhahq/nezha/pkg/grpcx"
@@ -36,15 +37,26 @@ func NewNezhaHandler() *NezhaHandler {
 	}
 }
 
-func (s *NezhaHandler) RequestTask(stream pb.NezhaService_RequestTaskServer) error {
+func (s *NezhaHandler) RequestTask(stream pb.NezhaService_RequestTaskServer) (err error) {
 	var clientID uint64
-	var err error
 	if clientID, err = s.Auth.Check(stream.Context()); err != nil {
 		return err
 	}
+	singleton.ServerShared.StreamOpened(clientID)
+	defer func() {
+		singleton.ServerShared.StreamClosed(clientID, err)
+	}()
 
 	server, _ := singleton.ServerShared.Get(clientID)
 	server.TaskStream = stream
+	singleton.ClusterShared.ClaimServer(clientID)
+	singleton.FilePushShared.Resume(clientID)
+	// 上报模式保存在面板，Agent 重新连接时下发
+	if server.HeartbeatMode {
+		if err := singleton.PushReportConfig(server); err != nil {
+			log.Printf("NEZHA>> Failed to push report config: %v, clientID: %d\n", err, clientID)
+		}
+	}
 	var result *pb.TaskResult
 	for {
 		result, err = stream.Recv()
@@ -52,21 +64,32 @@ func (s *NezhaHandler) RequestTask(stream pb.NezhaService_RequestTaskServer) err
 			log.Printf("NEZHA>> RequestTask error: %v, clientID: %d\n", err, clientID)
 			return err
 		}
+		singleton.ServerShared.StreamActive(clientID)
+		// 恢复备份期间丢弃任务结果，避免写入即将被覆盖的数据库
+		if singleton.IsRestoring() {
+			continue
+		}
+		// 节点间延迟测试的结果不属于服务监控
+		if result.GetType() == model.TaskTypeICMPPing && result.GetId()&model.MeshTaskIDFlag != 0 {
+			singleton.MeshShared.Report(clientID, result)
+			continue
+		}
 		switch result.GetType() {
 		case model.TaskTypeCommand:
 			// 处理上报的计划任务
-			cr, _ := singleton.CronShared.Get(result.GetId())
+			cr, status, output := singleton.FinishCronExecution(clientID, result)
 			if cr != nil {
 				// 保存当前服务器状态信息
 				var curServer model.Server
 				copier.Copy(&curServer, server)
-				if cr.PushSuccessful && result.GetSuccessful() {
-					singleton.NotificationShared.SendNotification(cr.NotificationGroupID, fmt.Sprintf("[%s] %s, %s\n%s", singleton.Localizer.T("Scheduled Task Executed Successfully"),
-						cr.Name, server.Name, result.GetData()), "", &curServer)
+				if cr.PushSuccessful && status == model.CronExecutionSuccess {
+					singleton.NotificationShared.SendNotification(cr.NotificationGroupID, fmt.Sprintf("[%s] %s, %s\n%s", singleton.NotificationShared.Lang(cr.NotificationGroupID).T("Scheduled Task Executed Successfully"),
+						cr.Name, server.Name, output), "", &curServer)
 				}
-				if !result.GetSuccessful() {
-					singleton.NotificationShared.SendNotification(cr.NotificationGroupID, fmt.Sprintf("[%s] %s, %s\n%s", singleton.Localizer.T("Scheduled Task Executed Failed"),
-						cr.Name, server.Name, result.GetData()), "", &curServer)
+				// 超时由 FinishCronExecution 单独通知
+				if status == model.CronExecutionFailure {
+					singleton.NotificationShared.SendNotification(cr.NotificationGroupID, fmt.Sprintf("[%s] %s, %s\n%s", singleton.NotificationShared.Lang(cr.NotificationGroupID).T("Scheduled Task Executed Failed"),
+						cr.Name, server.Name, output), "", &curServer)
 				}
 				singleton.DB.Model(cr).Updates(model.Cron{
 					LastExecutedAt: time.Now().Add(time.Second * -1 * time.Duration(result.GetDelay())),
@@ -81,6 +104,79 @@ func (s *NezhaHandler) RequestTask(stream pb.NezhaService_RequestTaskServer) err
 				}
 				server.ConfigCache <- result.Data
 			}
+		case model.TaskTypeProcessSnapshot:
+			singleton.FinishProcessSnapshot(clientID, result)
+		case model.TaskTypeAgentLogs:
+			singleton.FinishAgentLogs(clientID, result)
+		case model.TaskTypeFilePush:
+			singleton.FilePushShared.Report(clientID, result)
+		case model.TaskTypeProbe:
+			singleton.FinishProbe(clientID, result)
+		case model.TaskTypeReportNetInterfaces:
+			var counters []model.NetInterfaceTransfer
+			if err := json.Unmarshal([]byte(result.GetData()), &counters); err != nil {
+				log.Printf("NEZHA>> Invalid net interface report: %v, clientID: %d\n", err, clientID)
+				continue
+			}
+			singleton.RecordInterfaceTransfer(clientID, counters)
+		case model.TaskTypeReportBackfill:
+			if singleton.Conf.ReportsPaused() {
+				continue
+			}
+			if len(result.GetData()) > model.StateBackfillMaxSize {
+				singleton.RejectReport(clientID, "backfill", errors.New("backfill is too large"))
+				continue
+			}
+			var backfill model.StateBackfill
+			if err := json.Unmarshal([]byte(result.GetData()), &backfill); err != nil {
+				singleton.RejectReport(clientID, "backfill", err)
+				continue
+			}
+			if len(backfill.Samples) > model.StateBackfillMaxSamples {
+				singleton.RejectReport(clientID, "backfill", fmt.Errorf("too many samples: %d", len(backfill.Samples)))
+				continue
+			}
+			if err := singleton.ServerShared.BackfillState(server, &backfill); err != nil {
+				singleton.RejectReport(clientID, "backfill", err)
+			}
+		case model.TaskTypeHeartbeat:
+			if singleton.Conf.ReportsPaused() {
+				continue
+			}
+			var report model.HeartbeatReport
+			if err := json.Unmarshal([]byte(result.GetData()), &report); err != nil {
+				singleton.RejectReport(clientID, "heartbeat", err)
+				continue
+			}
+			// 修改上报模式后服务器会被替换，每次重新获取
+			current, ok := singleton.ServerShared.Get(clientID)
+			if !ok || current == nil {
+				continue
+			}
+			if err := singleton.ServerShared.RecordHeartbeat(current, &report); err != nil {
+				singleton.RejectReport(clientID, "heartbeat", err)
+				continue
+			}
+			// 面板已关闭心跳模式，重新下发让 Agent 恢复完整上报
+			if !current.HeartbeatMode {
+				if err := singleton.PushReportConfig(current); err != nil {
+					log.Printf("NEZHA>> Failed to push report config: %v, clientID: %d\n", err, clientID)
+				}
+			}
+		case model.TaskTypeReportContainers:
+			// 容器信息变化较慢，忽略过于频繁的上报
+			if server.Containers != nil && time.Since(server.Containers.UpdatedAt) < model.ContainerReportMinInterval {
+				continue
+			}
+			var report model.ContainerReport
+			if err := json.Unmarshal([]byte(result.GetData()), &report); err != nil {
+				singleton.RejectReport(clientID, "containers", err)
+				continue
+			}
+			report.Sanitize()
+			report.UpdatedAt = time.Now()
+			server.Containers = &report
+			singleton.ClusterShared.PublishServerContainers(server)
 		default:
 			if model.IsServiceSentinelNeeded(result.GetType()) {
 				singleton.ServiceSentinelShared.Dispatch(singleton.ReportData{
@@ -92,11 +188,15 @@ func (s *NezhaHandler) RequestTask(stream pb.NezhaService_RequestTaskServer) err
 	}
 }
 
-func (s *NezhaHandler) ReportSystemState(stream pb.NezhaService_ReportSystemStateServer) error {
+func (s *NezhaHandler) ReportSystemState(stream pb.NezhaService_ReportSystemStateServer) (err error) {
 	clientID, err := s.Auth.Check(stream.Context())
 	if err != nil {
 		return err
 	}
+	singleton.ServerShared.StreamOpened(clientID)
+	defer func() {
+		singleton.ServerShared.StreamClosed(clientID, err)
+	}()
 	var state *pb.State
 	for {
 		state, err = stream.Recv()
@@ -104,6 +204,13 @@ func (s *NezhaHandler) ReportSystemState(stream pb.NezhaService_ReportSystemStat
 			log.Printf("NEZHA>> ReportSystemState error: %v, clientID: %d\n", err, clientID)
 			return err
 		}
+		singleton.ServerShared.StreamActive(clientID)
+		if singleton.Conf.ReportsPaused() {
+			if err = stream.Send(&pb.Receipt{Proced: true}); err != nil {
+				return err
+			}
+			continue
+		}
 		innerState := model.PB2State(state)
 
 		server, ok := singleton.ServerShared.Get(clientID)
@@ -111,14 +218,24 @@ func (s *NezhaHandler) ReportSystemState(stream pb.NezhaService_ReportSystemStat
 			return errors.New("server not found")
 		}
 
+		// 丢弃异常的上报，保留上次的状态
+		if err := innerState.Sanitize(server.Host); err != nil {
+			singleton.RejectReport(clientID, "state", err)
+			if err = stream.Send(&pb.Receipt{Proced: true}); err != nil {
+				return err
+			}
+			continue
+		}
+
 		server.LastActive = time.Now()
+		server.HeartbeatAt = time.Time{}
 		server.State = &innerState
+		singleton.ServerShared.RecordState(server)
+		singleton.CountReport()
+		singleton.ClusterShared.PublishServerState(server)
 
-		// 应对 dashboard / agent 重启的情况，如果从未记录过，先打点，等到小时时间点时入库
-		if server.PrevTransferInSnapshot == 0 || server.PrevTransferOutSnapshot == 0 {
-			server.PrevTransferInSnapshot = state.NetInTransfer
-			server.PrevTransferOutSnapshot = state.NetOutTransfer
-		}
+		// 累加两次上报间的流量，等到小时时间点时入库
+		singleton.ObserveTransfer(server, innerState.NetInTransfer, innerState.NetOutTransfer, server.LastActive)
 
 		if err = stream.Send(&pb.Receipt{Proced: true}); err != nil {
 			return err
@@ -132,6 +249,9 @@ func (s *NezhaHandler) onReportSystemInfo(c context.Context, r *pb.Host) error {
 	if clientID, err = s.Auth.Check(c); err != nil {
 		return err
 	}
+	if singleton.Conf.ReportsPaused() {
+		return nil
+	}
 	host := model.PB2Host(r)
 
 	server, ok := singleton.ServerShared.Get(clientID)
@@ -139,18 +259,32 @@ func (s *NezhaHandler) onReportSystemInfo(c context.Context, r *pb.Host) error {
 		return errors.New("server not found")
 	}
 
+	if err := host.Sanitize(); err != nil {
+		singleton.RejectReport(clientID, "host", err)
+		return nil
+	}
+
 	/**
 	 * 这里的 singleton 中的数据都是关机前的旧数据
 	 * 当 agent 重启时，bootTime 变大，agent 端会先上报 host 信息，然后上报 state 信息
-	 * 这时可以借助上报顺序的空档，立即记录停机前的数据并重置 Prev* 数据，并由接下来的 state 方法重新赋值
+	 * 这时可以借助上报顺序的空档，立即记录停机前的数据。计数器是否归零由下次上报的值是否变小判断，
+	 * 开机时间的抖动不会使整个计数器被重复计入
 	 */
 	if !server.LastActive.IsZero() && host.BootTime > server.Host.BootTime {
 		singleton.RecordTransferHourlyUsage(server)
-		server.PrevTransferInSnapshot = 0
-		server.PrevTransferOutSnapshot = 0
+		singleton.ResetInterfaceTransfer(server.ID)
 	}
 
+	singleton.RecordAgentVersion(server, host.Version)
 	server.Host = &host
+	singleton.ClusterShared.PublishServerState(server)
+
+	// 使用网卡地址作为来源的配置在网卡地址变化时更新
+	if server.EnableDDNS {
+		if err := singleton.ServerShared.CheckDDNS(server, nil); err != nil {
+			log.Printf("NEZHA>> Failed to update DDNS for server %d: %v", server.ID, err)
+		}
+	}
 	return nil
 }
 
@@ -211,6 +345,9 @@ func (s *NezhaHandler) ReportGeoIP(c context.Context, r *pb.GeoIP) (*pb.GeoIP, e
 	if clientID, err = s.Auth.Check(c); err != nil {
 		return nil, err
 	}
+	if singleton.Conf.ReportsPaused() {
+		return &pb.GeoIP{DashboardBootTime: singleton.DashboardBootTime}, nil
+	}
 
 	geoip := model.PB2GeoIP(r)
 	use6 := r.GetUse6()
@@ -230,33 +367,17 @@ func (s *NezhaHandler) ReportGeoIP(c context.Context, r *pb.GeoIP) (*pb.GeoIP, e
 		return nil, fmt.Errorf("server not found")
 	}
 
-	// 检查并更新DDNS
-	if server.EnableDDNS && joinedIP != "" &&
-		(server.GeoIP == nil || server.GeoIP.IP != geoip.IP) {
-		ipv4 := geoip.IP.IPv4Addr
-		ipv6 := geoip.IP.IPv6Addr
-
-		if err := singleton.ServerShared.UpdateDDNS(server, &model.IP{IPv4Addr: ipv4, IPv6Addr: ipv6}); err != nil {
-			log.Printf("NEZHA>> Failed to update DDNS for server %d: %v", err, server.ID)
-		}
+	connectionIP, _ := c.Value(model.CtxKeyRealIP{}).(string)
+	if connectionIP == "" {
+		connectionIP, _ = c.Value(model.CtxKeyConnectingIP{}).(string)
 	}
+	singleton.ServerShared.SetConnectionIP(server, connectionIP)
 
-	// 发送IP变动通知
-	if server.GeoIP != nil && singleton.Conf.EnableIPChangeNotification &&
-		((singleton.Conf.Cover == model.ConfigCoverAll && !singleton.Conf.IgnoredIPNotificationServerIDs[clientID]) ||
-			(singleton.Conf.Cover == model.ConfigCoverIgnoreAll && singleton.Conf.IgnoredIPNotificationServerIDs[clientID])) &&
-		server.GeoIP.IP.Join() != "" &&
-		joinedIP != "" &&
-		server.GeoIP.IP != geoip.IP {
-
-		singleton.NotificationShared.SendNotification(singleton.Conf.IPChangeNotificationGroupID,
-			fmt.Sprintf(
-				"[%s] %s, %s => %s",
-				singleton.Localizer.T("IP Changed"),
-				server.Name, singleton.IPDesensitize(server.GeoIP.IP.Join()),
-				singleton.IPDesensitize(joinedIP),
-			),
-			"")
+	// 检查并更新DDNS，Agent 上报的 IP 或连接 IP 变化时更新，未变化时检查公网解析结果是否一致
+	if server.EnableDDNS && joinedIP != "" {
+		if err := singleton.ServerShared.CheckDDNS(server, &model.IP{IPv4Addr: geoip.IP.IPv4Addr, IPv6Addr: geoip.IP.IPv6Addr}); err != nil {
+			log.Printf("NEZHA>> Failed to update DDNS for server %d: %v", server.ID, err)
+		}
 	}
 
 	// 智能查询 IP 地理位置和ASN信息 - 只在IP变化或首次连接时查询
@@ -285,7 +406,7 @@ func (s *NezhaHandler) ReportGeoIP(c context.Context, r *pb.GeoIP) (*pb.GeoIP, e
 					location = server.GeoIP.CountryCode
 				}
 			} else {
-				log.Printf("NEZHA>> API查询成功 - IP: %s, 国家: %s, ASN: %s", ip, countryCode, asnOrg)
+				log.Printf("NEZHA>> GeoIP lookup succeeded - IP: %s, country: %s, ASN: %s", ip, countryCode, asnOrg)
 				geoip.CountryCode = countryCode
 				geoip.ASN = asnOrg
 				location = countryCode
@@ -298,11 +419,15 @@ func (s *NezhaHandler) ReportGeoIP(c context.Context, r *pb.GeoIP) (*pb.GeoIP, e
 			geoip.ASN = server.GeoIP.ASN
 			location = server.GeoIP.CountryCode
 		}
-		log.Printf("NEZHA>> IP未变化，跳过API查询 - 复用现有数据")
+		log.Printf("NEZHA>> IP unchanged, reusing existing GeoIP data")
 	}
 
+	// 记录 IP 变动并发送提醒
+	singleton.ServerShared.ObserveIP(server, geoip)
+
 	// 将地区码写入到 Host
 	server.GeoIP = &geoip
+	singleton.ClusterShared.PublishServerState(server)
 
 	return &pb.GeoIP{Ip: nil, CountryCode: location, DashboardBootTime: singleton.DashboardBootTime}, nil
 }
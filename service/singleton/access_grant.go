@@ -0,0 +1,145 @@
+package singleton
+
+import (
+	"cmp"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/utils"
+)
+
+type AccessGrantClass struct {
+	class[uint64, *model.AccessGrant]
+
+	// 按用户、服务器展开的授权，分组授权按当前成员展开，打开终端时只查内存
+	effectiveMu sync.RWMutex
+	effective   map[uint64]map[uint64][]*model.AccessGrant // [target_user_id] -> [server_id] -> 授权
+}
+
+func NewAccessGrantClass() *AccessGrantClass {
+	var sortedList []*model.AccessGrant
+
+	DB.Find(&sortedList)
+	list := make(map[uint64]*model.AccessGrant, len(sortedList))
+	for _, g := range sortedList {
+		list[g.ID] = g
+	}
+
+	c := &AccessGrantClass{
+		class: class[uint64, *model.AccessGrant]{
+			list:       list,
+			sortedList: sortedList,
+			userList:   groupByUser(sortedList),
+		},
+	}
+	c.expand()
+	return c
+}
+
+// Reload 从数据库重新加载授权
+func (c *AccessGrantClass) Reload() {
+	gc := NewAccessGrantClass()
+
+	c.listMu.Lock()
+	c.list = gc.list
+	c.listMu.Unlock()
+	c.sortList()
+}
+
+func (c *AccessGrantClass) Update(g *model.AccessGrant) {
+	c.listMu.Lock()
+	c.list[g.ID] = g
+	c.listMu.Unlock()
+
+	c.sortList()
+	ClusterShared.PublishChange("access-grant")
+}
+
+func (c *AccessGrantClass) Delete(idList []uint64) {
+	c.listMu.Lock()
+	for _, id := range idList {
+		delete(c.list, id)
+	}
+	c.listMu.Unlock()
+
+	c.sortList()
+	ClusterShared.PublishChange("access-grant")
+}
+
+// RefreshMembers 服务器分组成员变化后重新展开分组授权
+func (c *AccessGrantClass) RefreshMembers() {
+	c.expand()
+}
+
+// Allowed 判断用户当前是否被授予对服务器执行该操作的权限
+func (c *AccessGrantClass) Allowed(userID, serverID uint64, access string) bool {
+	c.effectiveMu.RLock()
+	defer c.effectiveMu.RUnlock()
+
+	now := time.Now()
+	return slices.ContainsFunc(c.effective[userID][serverID], func(g *model.AccessGrant) bool {
+		return g.Allows(access, now)
+	})
+}
+
+func (c *AccessGrantClass) expand() {
+	grants := c.GetSortedList()
+
+	var groups []uint64
+	for _, g := range grants {
+		if g.ServerGroupID != 0 {
+			groups = append(groups, g.ServerGroupID)
+		}
+	}
+	members := make(map[uint64][]uint64)
+	if len(groups) > 0 {
+		var sgs []model.ServerGroupServer
+		if err := DB.Where("server_group_id IN (?)", groups).Find(&sgs).Error; err != nil {
+			log.Printf("NEZHA>> Failed to load server group members of access grants: %v", err)
+		}
+		for _, sg := range sgs {
+			members[sg.ServerGroupId] = append(members[sg.ServerGroupId], sg.ServerId)
+		}
+	}
+
+	effective := make(map[uint64]map[uint64][]*model.AccessGrant)
+	add := func(g *model.AccessGrant, serverID uint64) {
+		servers, ok := effective[g.TargetUserID]
+		if !ok {
+			servers = make(map[uint64][]*model.AccessGrant)
+			effective[g.TargetUserID] = servers
+		}
+		servers[serverID] = append(servers[serverID], g)
+	}
+	for _, g := range grants {
+		if g.ServerID != 0 {
+			add(g, g.ServerID)
+		}
+		for _, id := range members[g.ServerGroupID] {
+			add(g, id)
+		}
+	}
+
+	c.effectiveMu.Lock()
+	c.effective = effective
+	c.effectiveMu.Unlock()
+}
+
+func (c *AccessGrantClass) sortList() {
+	c.listMu.RLock()
+	sortedList := utils.MapValuesToSlice(c.list)
+	c.listMu.RUnlock()
+
+	slices.SortFunc(sortedList, func(a, b *model.AccessGrant) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	c.sortedListMu.Lock()
+	c.setSortedList(sortedList)
+	c.sortedListMu.Unlock()
+
+	c.expand()
+}
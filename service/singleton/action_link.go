@@ -0,0 +1,279 @@
+package singleton
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+const (
+	ActionSilenceServer = "silence"
+	ActionAckServer     = "ack"
+
+	actionLinkTTL         = 24 * time.Hour
+	serverSilenceDuration = time.Hour
+
+	// 其他节点收到后重新加载静音与确认
+	alertActionsEntity = "alert-actions"
+)
+
+type alertAckKey struct {
+	alert, server uint64
+}
+
+type pendingAlertAck struct {
+	channel uint64
+	issued  time.Time // 链接生成的时间，只确认此前已经开始报警的规则
+	at      time.Time
+}
+
+// alertActions 通过操作链接设置的静音与确认，保存在数据库中，重启后或其他节点修改后重新加载
+var alertActions = struct {
+	sync.Mutex
+	silences map[uint64]time.Time
+	acks     map[alertAckKey]struct{}
+	pending  map[uint64]pendingAlertAck // 等待报警检查确认的服务器
+	firing   map[alertAckKey]time.Time  // 本节点检查的报警开始的时间，不随重新加载清空
+}{
+	silences: make(map[uint64]time.Time),
+	acks:     make(map[alertAckKey]struct{}),
+	pending:  make(map[uint64]pendingAlertAck),
+	firing:   make(map[alertAckKey]time.Time),
+}
+
+// validDashboardURL 操作链接需要完整的 http(s) 地址
+func validDashboardURL(v string) error {
+	if v == "" {
+		return nil
+	}
+	if u, err := url.Parse(v); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return Localizer.ErrorT("invalid url: %s", v)
+	}
+	return nil
+}
+
+// actionLinkSignature 签名绑定操作、对象、来源通知方式与过期时间
+func actionLinkSignature(action string, id, channel uint64, exp int64) string {
+	mac := hmac.New(sha256.New, []byte(Conf.JWTSecretKey))
+	fmt.Fprintf(mac, "%s:%d:%d:%d", action, id, channel, exp)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ActionLink 生成通知中使用的免登录操作链接，未设置面板地址时返回空字符串
+func ActionLink(action string, id, channel uint64) string {
+	if Conf.DashboardURL == "" {
+		return ""
+	}
+	exp := time.Now().Add(actionLinkTTL).Unix()
+	q := url.Values{}
+	q.Set("channel", strconv.FormatUint(channel, 10))
+	q.Set("exp", strconv.FormatInt(exp, 10))
+	q.Set("sig", actionLinkSignature(action, id, channel, exp))
+	return fmt.Sprintf("%s/api/v1/server/%d/%s?%s", strings.TrimSuffix(Conf.DashboardURL, "/"), id, action, q.Encode())
+}
+
+// VerifyActionLink 校验操作链接的签名与有效期，返回链接生成的时间
+func VerifyActionLink(action string, id, channel uint64, exp int64, sig string) (time.Time, error) {
+	if !hmac.Equal([]byte(sig), []byte(actionLinkSignature(action, id, channel, exp))) {
+		return time.Time{}, Localizer.ErrorT("invalid link")
+	}
+	if time.Now().Unix() > exp {
+		return time.Time{}, Localizer.ErrorT("the link has expired")
+	}
+	return time.Unix(exp, 0).Add(-actionLinkTTL), nil
+}
+
+// loadAlertActions 从数据库加载静音与确认，并清理已过期的静音
+func loadAlertActions() error {
+	now := time.Now()
+	if err := DB.Where("until < ?", now).Delete(&model.AlertSilence{}).Error; err != nil {
+		return err
+	}
+	var silences []model.AlertSilence
+	if err := DB.Find(&silences).Error; err != nil {
+		return err
+	}
+	var acks []model.AlertAck
+	if err := DB.Find(&acks).Error; err != nil {
+		return err
+	}
+
+	alertActions.Lock()
+	defer alertActions.Unlock()
+	clear(alertActions.silences)
+	clear(alertActions.acks)
+	clear(alertActions.pending)
+	for _, s := range silences {
+		alertActions.silences[s.ServerID] = s.Until
+	}
+	for _, a := range acks {
+		if a.AlertID == 0 {
+			alertActions.pending[a.ServerID] = pendingAlertAck{channel: a.NotificationID, issued: a.IssuedAt, at: a.CreatedAt}
+		} else {
+			alertActions.acks[alertAckKey{a.AlertID, a.ServerID}] = struct{}{}
+		}
+	}
+	return nil
+}
+
+// actionChannel 返回操作链接来源的通知方式
+func actionChannel(channel uint64) (*model.Notification, bool) {
+	NotificationShared.listMu.RLock()
+	defer NotificationShared.listMu.RUnlock()
+	n, ok := NotificationShared.list[channel]
+	return n, ok
+}
+
+// SilenceServer 在一段时间内不再发送该服务器的报警通知，channel 为链接来源的通知方式
+func SilenceServer(id, channel uint64) (time.Time, error) {
+	until := time.Now().Add(serverSilenceDuration)
+	if err := DB.Save(&model.AlertSilence{ServerID: id, NotificationID: channel, Until: until}).Error; err != nil {
+		return until, err
+	}
+	alertActions.Lock()
+	alertActions.silences[id] = until
+	alertActions.Unlock()
+	ClusterShared.PublishChange(alertActionsEntity)
+
+	var name string
+	if n, ok := actionChannel(channel); ok {
+		name = n.Name
+	}
+	log.Printf("NEZHA>> Alerts of server %d silenced until %s via notification %d (%s)", id, until.Format(time.RFC3339), channel, name)
+	return until, nil
+}
+
+// ServerSilenced 服务器的报警通知是否已通过操作链接静音
+func ServerSilenced(id uint64) bool {
+	alertActions.Lock()
+	defer alertActions.Unlock()
+	until, ok := alertActions.silences[id]
+	return ok && time.Now().Before(until)
+}
+
+// AckServerAlerts 确认服务器上在链接生成前已经开始且尚未恢复的报警，下一次报警检查时生效，报警恢复前不再重复通知。
+// 确认记录会发送到链接来源的通知方式
+func AckServerAlerts(s *model.Server, channel uint64, issued time.Time) error {
+	// 服务器由其他节点检查时由该节点在检查时判断
+	if ClusterShared.OwnsServer(s.ID) && !serverFiringSince(s.ID, issued) {
+		return Localizer.ErrorT("the alerts of this link have resolved")
+	}
+	now := time.Now()
+	if err := DB.Save(&model.AlertAck{ServerID: s.ID, NotificationID: channel, IssuedAt: issued, CreatedAt: now}).Error; err != nil {
+		return err
+	}
+	alertActions.Lock()
+	alertActions.pending[s.ID] = pendingAlertAck{channel: channel, issued: issued, at: now}
+	alertActions.Unlock()
+	ClusterShared.PublishChange(alertActionsEntity)
+
+	n, ok := actionChannel(channel)
+	if !ok {
+		log.Printf("NEZHA>> Alerts of server %d acknowledged via unknown notification %d", s.ID, channel)
+		return nil
+	}
+	log.Printf("NEZHA>> Alerts of server %d acknowledged via notification %d (%s)", s.ID, channel, n.Name)
+	go sendNotification(n, fmt.Sprintf("[%s] %s", Localizer.T("Acknowledged"),
+		Localizer.Tf("Alerts of server %s were acknowledged from this channel", s.Name)), s)
+	return nil
+}
+
+// serverFiringSince 服务器上是否有在 t 之前开始且仍在报警的规则
+func serverFiringSince(serverID uint64, t time.Time) bool {
+	alertActions.Lock()
+	defer alertActions.Unlock()
+	for key, since := range alertActions.firing {
+		if key.server == serverID && !since.After(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// markAlertFiring 报警检查未通过时调用，记录报警开始的时间
+func markAlertFiring(alertID, serverID uint64, now time.Time) {
+	key := alertAckKey{alertID, serverID}
+	alertActions.Lock()
+	defer alertActions.Unlock()
+	if _, ok := alertActions.firing[key]; !ok {
+		alertActions.firing[key] = now
+	}
+}
+
+// ackAlert 报警是否已确认，服务器有等待确认的请求且报警在链接生成前已经开始时记录对该报警的确认
+func ackAlert(alertID, serverID uint64) bool {
+	key := alertAckKey{alertID, serverID}
+	alertActions.Lock()
+	if _, ok := alertActions.acks[key]; ok {
+		alertActions.Unlock()
+		return true
+	}
+	p, ok := alertActions.pending[serverID]
+	since, firing := alertActions.firing[key]
+	if !ok || !firing || since.After(p.issued) {
+		alertActions.Unlock()
+		return false
+	}
+	alertActions.acks[key] = struct{}{}
+	alertActions.Unlock()
+
+	if err := DB.Save(&model.AlertAck{AlertID: alertID, ServerID: serverID, NotificationID: p.channel, IssuedAt: p.issued, CreatedAt: p.at}).Error; err != nil {
+		log.Printf("NEZHA>> Failed to save acknowledgement of alert %d on server %d: %v", alertID, serverID, err)
+	}
+	return true
+}
+
+// clearAlertAck 报警恢复后清除确认，下次报警时重新通知
+func clearAlertAck(alertID, serverID uint64) {
+	key := alertAckKey{alertID, serverID}
+	alertActions.Lock()
+	_, ok := alertActions.acks[key]
+	delete(alertActions.acks, key)
+	delete(alertActions.firing, key)
+	alertActions.Unlock()
+	if !ok {
+		return
+	}
+	if err := DB.Delete(&model.AlertAck{}, "alert_id = ? AND server_id = ?", alertID, serverID).Error; err != nil {
+		log.Printf("NEZHA>> Failed to delete acknowledgement of alert %d on server %d: %v", alertID, serverID, err)
+	}
+}
+
+// finishAlertAcks 一轮报警检查结束后移除本节点负责的服务器在 start 之前的确认请求，之后新触发的报警仍会通知
+func finishAlertAcks(start time.Time) {
+	var done []uint64
+	alertActions.Lock()
+	for id, p := range alertActions.pending {
+		if p.at.Before(start) && ClusterShared.OwnsServer(id) {
+			delete(alertActions.pending, id)
+			done = append(done, id)
+		}
+	}
+	alertActions.Unlock()
+	if len(done) == 0 {
+		return
+	}
+	if err := DB.Delete(&model.AlertAck{}, "alert_id = 0 AND server_id IN (?)", done).Error; err != nil {
+		log.Printf("NEZHA>> Failed to delete acknowledgement requests: %v", err)
+	}
+}
+
+// actionLinksText 附加在报警通知末尾的确认与静音链接
+func actionLinksText(n *model.Notification, server *model.Server, gid uint64) string {
+	ack := ActionLink(ActionAckServer, server.ID, n.ID)
+	if ack == "" {
+		return ""
+	}
+	lang := NotificationShared.Lang(gid)
+	return "\n" + lang.T("Acknowledge alerts of this server") + ": " + ack +
+		"\n" + lang.T("Silence this server for 1 hour") + ": " + ActionLink(ActionSilenceServer, server.ID, n.ID)
+}
@@ -0,0 +1,131 @@
+package singleton
+
+import (
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/i18n"
+)
+
+func TestActionLink(t *testing.T) {
+	old, oldLocalizer := Conf, Localizer
+	Conf = &ConfigClass{Config: &model.Config{}}
+	Conf.JWTSecretKey = "secret"
+	Localizer = i18n.NewLocalizer("en_US", domain, "translations", i18n.Translations)
+	t.Cleanup(func() { Conf, Localizer = old, oldLocalizer })
+
+	if link := ActionLink(ActionSilenceServer, 1, 2); link != "" {
+		t.Fatalf("expected no link without dashboard url, got %s", link)
+	}
+
+	Conf.DashboardURL = "https://dash.example.com/"
+	u, err := url.Parse(ActionLink(ActionSilenceServer, 1, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Path != "/api/v1/server/1/silence" {
+		t.Fatalf("unexpected path %s", u.Path)
+	}
+	q := u.Query()
+	exp, _ := strconv.ParseInt(q.Get("exp"), 10, 64)
+	issued, err := VerifyActionLink(ActionSilenceServer, 1, 2, exp, q.Get("sig"))
+	if err != nil {
+		t.Fatalf("expected valid link, got %v", err)
+	}
+	if d := time.Since(issued); d < 0 || d > time.Minute {
+		t.Fatalf("unexpected issue time %s", issued)
+	}
+	if _, err := VerifyActionLink(ActionSilenceServer, 3, 2, exp, q.Get("sig")); err == nil {
+		t.Fatal("signature must be bound to the server")
+	}
+	if _, err := VerifyActionLink(ActionSilenceServer, 1, 2, exp+1, q.Get("sig")); err == nil {
+		t.Fatal("signature must be bound to the expiry")
+	}
+	expired := exp - 2*int64(actionLinkTTL.Seconds())
+	if _, err := VerifyActionLink(ActionSilenceServer, 1, 2, expired, actionLinkSignature(ActionSilenceServer, 1, 2, expired)); err == nil {
+		t.Fatal("expired link must be rejected")
+	}
+}
+
+func TestAlertActions(t *testing.T) {
+	setupTestDB(t, &model.AlertAck{}, &model.AlertSilence{})
+	oldNotification, oldLocalizer := NotificationShared, Localizer
+	NotificationShared = &NotificationClass{class: class[uint64, *model.Notification]{list: map[uint64]*model.Notification{}}}
+	Localizer = i18n.NewLocalizer("en_US", domain, "translations", i18n.Translations)
+	t.Cleanup(func() {
+		NotificationShared, Localizer = oldNotification, oldLocalizer
+		alertActions.Lock()
+		clear(alertActions.firing)
+		alertActions.Unlock()
+	})
+
+	// 静音保存在数据库中，重新加载后仍然有效
+	if _, err := SilenceServer(1, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadAlertActions(); err != nil {
+		t.Fatal(err)
+	}
+	if !ServerSilenced(1) || ServerSilenced(2) {
+		t.Fatal("expected only server 1 to be silenced after reload")
+	}
+
+	// 确认请求只作用于链接生成前已经开始报警的规则
+	issued := time.Now().Add(-time.Minute)
+	markAlertFiring(11, 1, issued.Add(-time.Minute))
+	markAlertFiring(13, 1, issued.Add(-time.Minute))
+	if err := DB.Create(&model.AlertAck{ServerID: 1, NotificationID: 2, IssuedAt: issued, CreatedAt: time.Now().Add(-time.Second)}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := loadAlertActions(); err != nil {
+		t.Fatal(err)
+	}
+	markAlertFiring(10, 1, time.Now())
+	if ackAlert(10, 1) {
+		t.Fatal("alert that started firing after the link was issued must not be acknowledged")
+	}
+	if !ackAlert(11, 1) {
+		t.Fatal("firing alert should be acknowledged")
+	}
+	// 恢复后再次报警的规则不属于链接生成时的报警
+	clearAlertAck(13, 1)
+	markAlertFiring(13, 1, time.Now())
+	if ackAlert(13, 1) {
+		t.Fatal("alert that resolved after the link was issued must not be acknowledged")
+	}
+	finishAlertAcks(time.Now())
+	if err := loadAlertActions(); err != nil {
+		t.Fatal(err)
+	}
+	if !ackAlert(11, 1) {
+		t.Fatal("acknowledgement should survive a reload")
+	}
+	markAlertFiring(12, 1, issued.Add(-time.Minute))
+	if ackAlert(12, 1) {
+		t.Fatal("acknowledgement request should be consumed after a check")
+	}
+
+	// 报警恢复后清除确认
+	clearAlertAck(11, 1)
+	markAlertFiring(11, 1, time.Now())
+	if ackAlert(11, 1) {
+		t.Fatal("acknowledgement should be cleared after the alert resolves")
+	}
+
+	// 链接生成时的报警都已恢复时拒绝确认
+	server := &model.Server{Common: model.Common{ID: 2}, Name: "server"}
+	if err := AckServerAlerts(server, 2, issued); err == nil {
+		t.Fatal("expected link to be rejected without alerts firing since it was issued")
+	}
+	markAlertFiring(11, 2, time.Now())
+	if err := AckServerAlerts(server, 2, issued); err == nil {
+		t.Fatal("expected link to be rejected for alerts that started after it was issued")
+	}
+	markAlertFiring(12, 2, issued.Add(-time.Minute))
+	if err := AckServerAlerts(server, 2, issued); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,115 @@
+package singleton
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/utils"
+)
+
+// 通过应急链接后放行的时长
+const AdminBypassDuration = 12 * time.Hour
+
+// adminNetworks 允许访问管理区域的网段
+var adminNetworks atomic.Pointer[utils.TrustedProxies]
+
+// updateAdminNetworks 解析配置中允许访问管理区域的网段
+func (c *ConfigClass) updateAdminNetworks() error {
+	networks, err := utils.ParseTrustedProxies(c.AdminAllowedCIDRs)
+	if err != nil {
+		return err
+	}
+	adminNetworks.Store(&networks)
+	return nil
+}
+
+// AdminNetworkAllowed 来源 IP 是否可以访问管理区域，未配置网段时不限制
+func AdminNetworkAllowed(ip string) bool {
+	networks := adminNetworks.Load()
+	if networks == nil || len(*networks) == 0 {
+		return true
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	return networks.Trusted(addr)
+}
+
+// IssueAdminBypass 生成有效期为 ttl 的一次性应急链接令牌
+func IssueAdminBypass(ttl time.Duration) string {
+	payload := utils.MustGenerateRandomString(32) + "." + strconv.FormatInt(time.Now().Add(ttl).Unix(), 10)
+	return signAdminBypass("link", payload)
+}
+
+// ConsumeAdminBypass 校验并使用应急链接令牌，每个令牌只能使用一次
+func ConsumeAdminBypass(token string) error {
+	payload, ok := verifyAdminBypass("link", token)
+	if !ok {
+		return errors.New("invalid token")
+	}
+	nonce, exp, _ := strings.Cut(payload, ".")
+	expiresAt, err := parseAdminBypassExpiry(exp)
+	if err != nil {
+		return err
+	}
+	if err := DB.Create(&model.AdminBypassUse{Nonce: nonce, ExpiresAt: expiresAt}).Error; err != nil {
+		return errors.New("token already used")
+	}
+	return nil
+}
+
+// AdminBypassCookieValue 生成应急放行 Cookie 的值
+func AdminBypassCookieValue() string {
+	return signAdminBypass("cookie", strconv.FormatInt(time.Now().Add(AdminBypassDuration).Unix(), 10))
+}
+
+// ValidAdminBypassCookie 应急放行 Cookie 是否有效
+func ValidAdminBypassCookie(value string) bool {
+	payload, ok := verifyAdminBypass("cookie", value)
+	if !ok {
+		return false
+	}
+	_, err := parseAdminBypassExpiry(payload)
+	return err == nil
+}
+
+func parseAdminBypassExpiry(s string) (time.Time, error) {
+	exp, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, errors.New("invalid token")
+	}
+	expiresAt := time.Unix(exp, 0)
+	if time.Now().After(expiresAt) {
+		return time.Time{}, errors.New("token expired")
+	}
+	return expiresAt, nil
+}
+
+// signAdminBypass 返回 payload.签名，usage 区分链接与 Cookie，避免二者互相替代
+func signAdminBypass(usage, payload string) string {
+	mac := hmac.New(sha256.New, []byte(Conf.JWTSecretKey))
+	mac.Write([]byte("admin-bypass:" + usage + ":" + payload))
+	return payload + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func verifyAdminBypass(usage, token string) (string, bool) {
+	i := strings.LastIndexByte(token, '.')
+	if i < 0 {
+		return "", false
+	}
+	payload := token[:i]
+	return payload, hmac.Equal([]byte(signAdminBypass(usage, payload)), []byte(token))
+}
+
+func cleanAdminBypassUses() {
+	DB.Delete(&model.AdminBypassUse{}, "expires_at < ?", time.Now())
+}
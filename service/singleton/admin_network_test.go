@@ -0,0 +1,95 @@
+package singleton
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+func setupAdminNetworkTest(t *testing.T, cidrs ...string) {
+	oldConf, oldNetworks := Conf, adminNetworks.Load()
+	Conf = &ConfigClass{Config: &model.Config{AdminAllowedCIDRs: cidrs, JWTSecretKey: "secret"}}
+	t.Cleanup(func() {
+		Conf = oldConf
+		adminNetworks.Store(oldNetworks)
+	})
+	if err := Conf.updateAdminNetworks(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAdminNetworkAllowed(t *testing.T) {
+	setupAdminNetworkTest(t, "10.0.0.0/8", "2001:db8::/32")
+
+	cases := map[string]bool{
+		"10.1.2.3":    true,
+		"2001:db8::1": true,
+		"203.0.113.1": false,
+		"2001:db9::1": false,
+		"":            false,
+		"bad":         false,
+	}
+	for ip, allowed := range cases {
+		if got := AdminNetworkAllowed(ip); got != allowed {
+			t.Errorf("%q: expected allowed=%v, got %v", ip, allowed, got)
+		}
+	}
+
+	// 未配置网段时不限制
+	setupAdminNetworkTest(t)
+	if !AdminNetworkAllowed("203.0.113.1") {
+		t.Error("expected any address to be allowed without configured networks")
+	}
+}
+
+func TestConsumeAdminBypass(t *testing.T) {
+	setupAdminNetworkTest(t, "10.0.0.0/8")
+	setupTestDB(t, model.AdminBypassUse{})
+
+	token := IssueAdminBypass(time.Minute)
+	if err := ConsumeAdminBypass(token); err != nil {
+		t.Fatalf("expected fresh token to be accepted: %v", err)
+	}
+	if err := ConsumeAdminBypass(token); err == nil {
+		t.Fatal("expected token to be single use")
+	}
+
+	if err := ConsumeAdminBypass(IssueAdminBypass(-time.Minute)); err == nil {
+		t.Fatal("expected expired token to be rejected")
+	}
+	if err := ConsumeAdminBypass(IssueAdminBypass(time.Minute) + "x"); err == nil {
+		t.Fatal("expected tampered token to be rejected")
+	}
+	if err := ConsumeAdminBypass(""); err == nil {
+		t.Fatal("expected empty token to be rejected")
+	}
+
+	// 其他密钥签发的令牌无效
+	other := IssueAdminBypass(time.Minute)
+	Conf.JWTSecretKey = "rotated"
+	if err := ConsumeAdminBypass(other); err == nil {
+		t.Fatal("expected token signed with another key to be rejected")
+	}
+}
+
+func TestAdminBypassUsageSeparation(t *testing.T) {
+	setupAdminNetworkTest(t, "10.0.0.0/8")
+	setupTestDB(t, model.AdminBypassUse{})
+
+	cookie := AdminBypassCookieValue()
+	if !ValidAdminBypassCookie(cookie) {
+		t.Fatal("expected cookie to be valid")
+	}
+	if err := ConsumeAdminBypass(cookie); err == nil {
+		t.Fatal("expected cookie to be rejected as a link token")
+	}
+
+	token := IssueAdminBypass(time.Minute)
+	if ValidAdminBypassCookie(token) {
+		t.Fatal("expected link token to be rejected as a cookie")
+	}
+	if ValidAdminBypassCookie(cookie + "x") {
+		t.Fatal("expected tampered cookie to be rejected")
+	}
+}
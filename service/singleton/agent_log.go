@@ -0,0 +1,61 @@
+package singleton
+
+import (
+	"time"
+
+	"github.com/goccy/go-json"
+
+	"github.com/nezhahq/nezha/model"
+	pb "github.com/nezhahq/nezha/proto"
+)
+
+// agentLogRequests 等待 Agent 返回的日志，不同行数的请求可能同时进行
+var agentLogRequests = newPendingTasks()
+
+// RunAgentLogs 向 Agent 请求最近 lines 行日志并等待结果，结果不保存
+func RunAgentLogs(s *model.Server, lines int) (*model.AgentLogs, error) {
+	data, err := json.Marshal(model.AgentLogsRequest{Lines: lines, MaxBytes: model.AgentLogsMaxBytes})
+	if err != nil {
+		return nil, err
+	}
+
+	id, p := agentLogRequests.add(s.ID)
+	defer agentLogRequests.done(id)
+
+	if err := s.TaskStream.Send(&pb.Task{
+		Id:   id,
+		Type: model.TaskTypeAgentLogs,
+		Data: string(data),
+	}); err != nil {
+		return nil, err
+	}
+
+	timeout := time.NewTimer(model.AgentLogsTimeout)
+	defer timeout.Stop()
+
+	var result *pb.TaskResult
+	select {
+	case <-timeout.C:
+		return nil, Localizer.ErrorT("operation timeout")
+	case result = <-p.result:
+	}
+
+	if !result.GetSuccessful() {
+		return nil, Localizer.ErrorT("get agent logs failed: %v", result.GetData())
+	}
+	if len(result.GetData()) > 2*model.AgentLogsMaxBytes {
+		return nil, Localizer.ErrorT("get agent logs failed: %v", "agent logs are too large")
+	}
+	var logs model.AgentLogs
+	if err := json.Unmarshal([]byte(result.GetData()), &logs); err != nil {
+		return nil, Localizer.ErrorT("get agent logs failed: %v", err)
+	}
+	logs.Sanitize()
+	logs.CreatedAt = time.Now()
+	return &logs, nil
+}
+
+// FinishAgentLogs 处理 Agent 返回的日志，忽略已超时或来自其他服务器的结果
+func FinishAgentLogs(serverID uint64, result *pb.TaskResult) {
+	agentLogRequests.finish(serverID, result)
+}
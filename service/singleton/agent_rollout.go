@@ -0,0 +1,321 @@
+package singleton
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-json"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/utils"
+	pb "github.com/nezhahq/nezha/proto"
+)
+
+const (
+	// Agent 超过该时间仍未以新版本重新连接时视为升级失败
+	agentUpgradeTimeout = 10 * time.Minute
+	agentRolloutTick    = 5 * time.Second
+
+	agentLatestReleaseURL = "https://api.github.com/repos/nezhahq/agent/releases/latest"
+	// 缓存的最新版本的有效期，失败时同样等待该时间后重试
+	agentLatestVersionTTL = time.Hour
+)
+
+// knownAgentVersion 缓存的 Agent 最新版本，供报警规则比较
+var knownAgentVersion struct {
+	sync.Mutex
+	version    string
+	checkedAt  time.Time
+	refreshing bool
+}
+
+type rolloutServerKey struct {
+	rolloutID uint64
+	serverID  uint64
+}
+
+// AgentRolloutClass 按批次向服务器下发升级任务并跟踪升级结果
+type AgentRolloutClass struct {
+	mu      sync.Mutex
+	active  map[uint64]*model.AgentRollout
+	streams map[rolloutServerKey]pb.NezhaService_RequestTaskServer // 下发升级任务时的连接，用于判断 Agent 是否已重启，面板重启后为空
+}
+
+var AgentRolloutShared *AgentRolloutClass
+
+func NewAgentRolloutClass() *AgentRolloutClass {
+	c := &AgentRolloutClass{
+		active:  make(map[uint64]*model.AgentRollout),
+		streams: make(map[rolloutServerKey]pb.NezhaService_RequestTaskServer),
+	}
+	var rollouts []*model.AgentRollout
+	DB.Where("status IN (?)", []string{model.RolloutRunning, model.RolloutPaused}).Find(&rollouts)
+	for _, r := range rollouts {
+		c.active[r.ID] = r
+	}
+	go c.worker()
+	return c
+}
+
+func (c *AgentRolloutClass) worker() {
+	for range time.Tick(agentRolloutTick) {
+		c.mu.Lock()
+		for id, r := range c.active {
+			if r.Status == model.RolloutRunning && c.step(r) {
+				c.save(r)
+			}
+			if r.Finished() {
+				delete(c.active, id)
+			}
+		}
+		c.mu.Unlock()
+	}
+}
+
+func (c *AgentRolloutClass) save(r *model.AgentRollout) {
+	if err := DB.Save(r).Error; err != nil {
+		log.Printf("NEZHA>> Failed to save agent rollout %d: %v", r.ID, err)
+	}
+}
+
+// Create 解析目标版本与服务器并开始升级
+func (c *AgentRolloutClass) Create(userID uint64, rf *model.AgentRolloutForm) (*model.AgentRollout, error) {
+	version := rf.TargetVersion
+	if version == "latest" {
+		var err error
+		if version, err = latestAgentVersion(); err != nil {
+			return nil, Localizer.ErrorT("failed to resolve the latest agent version: %v", err)
+		}
+	}
+	ids := ResolveServerSelector(rf.Selector, userID)
+	if len(ids) == 0 {
+		return nil, Localizer.ErrorT("no server matches the selector")
+	}
+
+	r := &model.AgentRollout{
+		TargetVersion: version,
+		BatchSize:     max(rf.BatchSize, 1),
+		MaxFailures:   max(rf.MaxFailures, 0),
+		Status:        model.RolloutRunning,
+		Selector:      rf.Selector,
+	}
+	r.UserID = userID
+	for _, id := range ids {
+		r.Servers = append(r.Servers, &model.AgentRolloutServer{ServerID: id, Status: model.RolloutServerPending})
+	}
+
+	if err := DB.Create(r).Error; err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.step(r)
+	c.save(r)
+	if !r.Finished() {
+		c.active[r.ID] = r
+	}
+	return r, nil
+}
+
+// step 更新升级中服务器的状态并下发下一批，状态有变化时返回 true，调用时需持有锁
+func (c *AgentRolloutClass) step(r *model.AgentRollout) bool {
+	changed := false
+	now := time.Now()
+
+	for _, rs := range r.Servers {
+		if rs.Status != model.RolloutServerUpgrading {
+			continue
+		}
+		key := rolloutServerKey{r.ID, rs.ServerID}
+		stream, tracked := c.streams[key]
+		s, ok := ServerShared.Get(rs.ServerID)
+		var version string
+		if ok && s.Host != nil {
+			version = s.Host.Version
+		}
+		switch {
+		case !ok:
+			rs.Status, rs.Error = model.RolloutServerFailed, Localizer.T("server has been deleted")
+		case tracked && s.TaskStream != nil && s.TaskStream != stream:
+			// Agent 已重新连接，连接时会先上报主机信息
+			rs.Version = version
+			if model.SameAgentVersion(rs.Version, r.TargetVersion) {
+				rs.Status = model.RolloutServerSucceeded
+			} else {
+				rs.Status = model.RolloutServerVersionMismatch
+			}
+		case !tracked && model.SameAgentVersion(version, r.TargetVersion):
+			// 面板重启后不知道下发任务时的连接，无法判断 Agent 是否已重启，只按上报的版本判断
+			rs.Status, rs.Version = model.RolloutServerSucceeded, version
+		case now.Sub(*rs.StartedAt) > agentUpgradeTimeout:
+			if !tracked && s.TaskStream != nil && version != "" {
+				rs.Status, rs.Version = model.RolloutServerVersionMismatch, version
+			} else {
+				rs.Status, rs.Error = model.RolloutServerFailed, Localizer.T("operation timeout")
+			}
+		default:
+			continue
+		}
+		delete(c.streams, key)
+		changed = true
+	}
+
+	failed := r.Count(model.RolloutServerFailed, model.RolloutServerVersionMismatch) - r.AcceptedFailures
+	if r.MaxFailures > 0 && failed >= r.MaxFailures {
+		r.Status = model.RolloutPaused
+		r.Reason = Localizer.Tf("%d servers failed to upgrade", failed)
+		return true
+	}
+
+	upgrading := r.Count(model.RolloutServerUpgrading)
+	for _, rs := range r.Servers {
+		if upgrading >= r.BatchSize {
+			break
+		}
+		if rs.Status != model.RolloutServerPending {
+			continue
+		}
+		changed = true
+		s, ok := ServerShared.Get(rs.ServerID)
+		if !ok {
+			rs.Status, rs.Error = model.RolloutServerFailed, Localizer.T("server has been deleted")
+			continue
+		}
+		if s.Host != nil {
+			rs.FromVersion = s.Host.Version
+		}
+		if model.SameAgentVersion(rs.FromVersion, r.TargetVersion) {
+			rs.Status, rs.Version = model.RolloutServerSkipped, rs.FromVersion
+			continue
+		}
+		stream := s.TaskStream
+		if stream == nil {
+			rs.Status, rs.Error = model.RolloutServerFailed, Localizer.T("server not found or not connected")
+			continue
+		}
+		if err := stream.Send(&pb.Task{Type: model.TaskTypeUpgrade, Data: r.TargetVersion}); err != nil {
+			rs.Status, rs.Error = model.RolloutServerFailed, err.Error()
+			continue
+		}
+		rs.Status = model.RolloutServerUpgrading
+		rs.StartedAt = &now
+		c.streams[rolloutServerKey{r.ID, rs.ServerID}] = stream
+		upgrading++
+	}
+
+	if r.Count(model.RolloutServerPending, model.RolloutServerUpgrading) == 0 {
+		r.Status = model.RolloutCompleted
+		changed = true
+	}
+	return changed
+}
+
+// Get 返回升级记录
+func (c *AgentRolloutClass) Get(id uint64) (*model.AgentRollout, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if r, ok := c.active[id]; ok {
+		cp := *r
+		cp.Servers = make([]*model.AgentRolloutServer, len(r.Servers))
+		for i, rs := range r.Servers {
+			s := *rs
+			cp.Servers[i] = &s
+		}
+		return &cp, nil
+	}
+	var r model.AgentRollout
+	if err := DB.First(&r, id).Error; err != nil {
+		return nil, Localizer.ErrorT("rollout id %d does not exist", id)
+	}
+	return &r, nil
+}
+
+// Control 暂停、继续或中止升级，已下发的升级任务不会被撤回
+func (c *AgentRolloutClass) Control(id uint64, action string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r, ok := c.active[id]
+	if !ok {
+		return Localizer.ErrorT("rollout id %d is not running", id)
+	}
+
+	switch action {
+	case "pause":
+		r.Status = model.RolloutPaused
+	case "resume":
+		if r.Status == model.RolloutPaused {
+			r.Status, r.Reason = model.RolloutRunning, ""
+			r.AcceptedFailures = r.Count(model.RolloutServerFailed, model.RolloutServerVersionMismatch)
+		}
+	case "abort":
+		r.Status = model.RolloutAborted
+		for key := range c.streams {
+			if key.rolloutID == id {
+				delete(c.streams, key)
+			}
+		}
+		delete(c.active, id)
+	default:
+		return Localizer.ErrorT("invalid action")
+	}
+	c.save(r)
+	return nil
+}
+
+// latestAgentVersion 返回 GitHub 上最新发布的 Agent 版本
+func latestAgentVersion() (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, agentLatestReleaseURL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := utils.HttpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	var release struct {
+		TagName string `json:"tag_name"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return "", err
+	}
+	if release.TagName == "" {
+		return "", fmt.Errorf("empty release tag")
+	}
+
+	knownAgentVersion.Lock()
+	knownAgentVersion.version, knownAgentVersion.checkedAt = release.TagName, time.Now()
+	knownAgentVersion.Unlock()
+	return release.TagName, nil
+}
+
+// LatestAgentVersion 返回缓存的 Agent 最新版本，缓存过期时在后台刷新，尚未获取到时返回空字符串
+func LatestAgentVersion() string {
+	knownAgentVersion.Lock()
+	defer knownAgentVersion.Unlock()
+
+	if !knownAgentVersion.refreshing && time.Since(knownAgentVersion.checkedAt) > agentLatestVersionTTL {
+		knownAgentVersion.refreshing = true
+		go func() {
+			_, err := latestAgentVersion()
+			knownAgentVersion.Lock()
+			knownAgentVersion.refreshing = false
+			if err != nil {
+				knownAgentVersion.checkedAt = time.Now()
+				log.Printf("NEZHA>> Failed to check the latest agent version: %v", err)
+			}
+			knownAgentVersion.Unlock()
+		}()
+	}
+	return knownAgentVersion.version
+}
@@ -3,6 +3,8 @@ package singleton
 import (
 	"fmt"
 	"log"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
@@ -128,10 +130,31 @@ func OnDeleteAlert(id []uint64) {
 	}
 }
 
+func reloadAlerts() error {
+	var alerts []*model.AlertRule
+	if err := DB.Find(&alerts).Error; err != nil {
+		return err
+	}
+
+	AlertsLock.RLock()
+	ids := make([]uint64, 0, len(Alerts))
+	for _, alert := range Alerts {
+		ids = append(ids, alert.ID)
+	}
+	AlertsLock.RUnlock()
+
+	OnDeleteAlert(ids)
+	for _, alert := range alerts {
+		OnRefreshOrAddAlert(alert)
+	}
+	return nil
+}
+
 // checkStatus 检查报警规则并发送报警
 func checkStatus() {
 	AlertsLock.RLock()
 	defer AlertsLock.RUnlock()
+	defer finishAlertAcks(time.Now())
 	m := ServerShared.GetList()
 
 	for _, alert := range Alerts {
@@ -139,22 +162,35 @@ func checkStatus() {
 		if !alert.Enabled() {
 			continue
 		}
+		if alert.UsesLatestAgentVersion() {
+			alert.SetLatestAgentVersion(LatestAgentVersion())
+		}
+		if alert.IsServiceAlert() {
+			checkServiceAlert(alert)
+			continue
+		}
+		// 汇总规则由 leader 统一检查
+		if alert.IsAggregate() {
+			if ClusterShared.IsLeader() {
+				checkAggregateAlert(alert, m)
+			}
+			continue
+		}
 		for _, server := range m {
+			// 多节点部署时由负责该服务器的节点检查
+			if !ClusterShared.OwnsServer(server.ID) {
+				continue
+			}
 			// 监测点
-			UserLock.RLock()
-			var role uint8
-			if u, ok := UserInfoMap[server.UserID]; !ok {
-				role = model.RoleMember
-			} else {
-				role = u.Role
+			if !alertCoversServer(alert, server) {
+				continue
 			}
-			UserLock.RUnlock()
-			if alert.UserID != server.UserID && role != model.RoleAdmin {
+			if alert.PausedBySchedule(server, time.Now()) {
 				continue
 			}
 			alertsStore[alert.ID][server.ID] = append(alertsStore[alert.
 				ID][server.ID], alert.Snapshot(AlertsCycleTransferStatsStore[alert.ID], server, DB))
-			// 发送通知，分为触发报警和恢复通知
+			// 发送通知，分为触发报警和恢复通知，同一服务器的多条报警会合并发送
 			max, passed := alert.Check(alertsStore[alert.ID][server.ID])
 			// 保存当前服务器状态信息
 			curServer := model.Server{}
@@ -165,20 +201,25 @@ func checkStatus() {
 				// 始终触发模式或上次检查不为失败时触发报警（跳过单次触发+上次失败的情况）
 				if alert.TriggerMode == model.ModeAlwaysTrigger || alertsPrevState[alert.ID][server.ID] != _RuleCheckFail {
 					alertsPrevState[alert.ID][server.ID] = _RuleCheckFail
-					message := fmt.Sprintf("[%s] %s(%s) %s", Localizer.T("Incident"),
-						server.Name, IPDesensitize(server.GeoIP.IP.Join()), alert.Name)
+					markAlertFiring(alert.ID, server.ID, time.Now())
+					title := fmt.Sprintf("[%s] %s(%s)", NotificationShared.Lang(alert.NotificationGroupID).T("Incident"),
+						server.Name, IPDesensitize(server.GeoIP.IP.Join()))
 					go CronShared.SendTriggerTasks(alert.FailTriggerTasks, curServer.ID)
-					go NotificationShared.SendNotification(alert.NotificationGroupID, message, NotificationMuteLabel.ServerIncident(server.ID, alert.ID), &curServer)
+					// 已通过操作链接确认的报警在恢复前不再重复通知
+					if !ackAlert(alert.ID, server.ID) {
+						go NotificationShared.SendAlertNotification(alert.NotificationGroupID, title, alert.Name+staleFieldDetails(alert, server), NotificationMuteLabel.ServerIncident(server.ID, alert.ID), &curServer, false)
+					}
 					// 清除恢复通知的静音缓存
 					NotificationShared.UnMuteNotification(alert.NotificationGroupID, NotificationMuteLabel.ServerIncidentResolved(server.ID, alert.ID))
 				}
 			} else {
 				// 本次通过检查但上一次的状态为失败，则发送恢复通知
 				if alertsPrevState[alert.ID][server.ID] == _RuleCheckFail {
-					message := fmt.Sprintf("[%s] %s(%s) %s", Localizer.T("Resolved"),
-						server.Name, IPDesensitize(server.GeoIP.IP.Join()), alert.Name)
+					clearAlertAck(alert.ID, server.ID)
+					title := fmt.Sprintf("[%s] %s(%s)", NotificationShared.Lang(alert.NotificationGroupID).T("Resolved"),
+						server.Name, IPDesensitize(server.GeoIP.IP.Join()))
 					go CronShared.SendTriggerTasks(alert.RecoverTriggerTasks, curServer.ID)
-					go NotificationShared.SendNotification(alert.NotificationGroupID, message, NotificationMuteLabel.ServerIncidentResolved(server.ID, alert.ID), &curServer)
+					go NotificationShared.SendAlertNotification(alert.NotificationGroupID, title, alert.Name, NotificationMuteLabel.ServerIncidentResolved(server.ID, alert.ID), &curServer, true)
 					// 清除失败通知的静音缓存
 					NotificationShared.UnMuteNotification(alert.NotificationGroupID, NotificationMuteLabel.ServerIncident(server.ID, alert.ID))
 				}
@@ -192,3 +233,149 @@ func checkStatus() {
 		}
 	}
 }
+
+// alertCoversServer 报警规则只检查规则所有者的服务器，服务器所有者为管理员时对所有规则可见
+func alertCoversServer(alert *model.AlertRule, server *model.Server) bool {
+	UserLock.RLock()
+	var role uint8
+	if u, ok := UserInfoMap[server.UserID]; !ok {
+		role = model.RoleMember
+	} else {
+		role = u.Role
+	}
+	UserLock.RUnlock()
+	return alert.UserID == server.UserID || role == model.RoleAdmin
+}
+
+// checkAggregateAlert 检查汇总规则，检查结果记录在服务器 ID 0 下，通知中列出计入的服务器
+func checkAggregateAlert(alert *model.AlertRule, m map[uint64]*model.Server) {
+	point := make([]bool, len(alert.Rules))
+	var contributors []*model.Server
+	for i, rule := range alert.Rules {
+		ids := ResolveServerSelector(rule.Selector, alert.UserID)
+		servers := make([]*model.Server, 0, len(ids))
+		for _, id := range ids {
+			if s, ok := m[id]; ok && alertCoversServer(alert, s) {
+				servers = append(servers, s)
+			}
+		}
+		var matched []*model.Server
+		point[i], matched = rule.Aggregate(servers, DB)
+		for _, s := range matched {
+			if !slices.Contains(contributors, s) {
+				contributors = append(contributors, s)
+			}
+		}
+	}
+
+	alertsStore[alert.ID][0] = append(alertsStore[alert.ID][0], point)
+	max, passed := alert.Check(alertsStore[alert.ID][0])
+	t := NotificationShared.Lang(alert.NotificationGroupID)
+
+	if !passed {
+		if alert.TriggerMode == model.ModeAlwaysTrigger || alertsPrevState[alert.ID][0] != _RuleCheckFail {
+			alertsPrevState[alert.ID][0] = _RuleCheckFail
+			message := fmt.Sprintf("[%s] %s\n%s: %s", t.T("Incident"), alert.Name,
+				t.T("Servers"), aggregateServerNames(contributors))
+			for _, s := range contributors {
+				go CronShared.SendTriggerTasks(alert.FailTriggerTasks, s.ID)
+			}
+			go NotificationShared.SendNotification(alert.NotificationGroupID, message, NotificationMuteLabel.ServerIncident(0, alert.ID))
+			NotificationShared.UnMuteNotification(alert.NotificationGroupID, NotificationMuteLabel.ServerIncidentResolved(0, alert.ID))
+		}
+	} else {
+		if alertsPrevState[alert.ID][0] == _RuleCheckFail {
+			message := fmt.Sprintf("[%s] %s", t.T("Resolved"), alert.Name)
+			go CronShared.SendTriggerTasks(alert.RecoverTriggerTasks, 0)
+			go NotificationShared.SendNotification(alert.NotificationGroupID, message, NotificationMuteLabel.ServerIncidentResolved(0, alert.ID))
+			NotificationShared.UnMuteNotification(alert.NotificationGroupID, NotificationMuteLabel.ServerIncident(0, alert.ID))
+		}
+		alertsPrevState[alert.ID][0] = _RuleCheckPass
+	}
+	if max > 0 && max < len(alertsStore[alert.ID][0]) {
+		alertsStore[alert.ID][0] = alertsStore[alert.ID][0][len(alertsStore[alert.ID][0])-max:]
+	}
+}
+
+// alertCoversService 与服务器相同，报警规则只检查规则所有者的服务，服务所有者为管理员时对所有规则可见
+func alertCoversService(alert *model.AlertRule, service *model.Service) bool {
+	UserLock.RLock()
+	defer UserLock.RUnlock()
+	u, ok := UserInfoMap[service.UserID]
+	return alert.UserID == service.UserID || (ok && u.Role == model.RoleAdmin)
+}
+
+// checkServiceAlert 检查服务规则，检查结果按服务 ID 记录
+// 服务状态由收到监控结果的节点各自判定，与服务监控原有的通知一样由各节点分别检查
+func checkServiceAlert(alert *model.AlertRule) {
+	for id, service := range ServiceSentinelShared.GetList() {
+		if !alert.CoversService(id) || !alertCoversService(alert, service) {
+			continue
+		}
+		down, reason, ok := ServiceSentinelShared.Outage(id)
+		if !ok {
+			continue
+		}
+
+		alertsStore[alert.ID][id] = append(alertsStore[alert.ID][id], alert.ServiceSnapshot(id, down))
+		max, passed := alert.Check(alertsStore[alert.ID][id])
+		t := NotificationShared.Lang(alert.NotificationGroupID)
+
+		if !passed {
+			if alert.TriggerMode == model.ModeAlwaysTrigger || alertsPrevState[alert.ID][id] != _RuleCheckFail {
+				alertsPrevState[alert.ID][id] = _RuleCheckFail
+				title := fmt.Sprintf("[%s] %s", t.T("Incident"), service.Name)
+				alertName := alert.Name
+				if reason != "" {
+					alertName += "\n" + reason
+				}
+				go CronShared.SendTriggerTasks(alert.FailTriggerTasks, 0)
+				go NotificationShared.SendServiceAlertNotification(alert.NotificationGroupID, title, alertName, NotificationMuteLabel.ServiceIncident(id, alert.ID), service, false)
+				NotificationShared.UnMuteNotification(alert.NotificationGroupID, NotificationMuteLabel.ServiceIncidentResolved(id, alert.ID))
+			}
+		} else {
+			if alertsPrevState[alert.ID][id] == _RuleCheckFail {
+				title := fmt.Sprintf("[%s] %s", t.T("Resolved"), service.Name)
+				go CronShared.SendTriggerTasks(alert.RecoverTriggerTasks, 0)
+				go NotificationShared.SendServiceAlertNotification(alert.NotificationGroupID, title, alert.Name, NotificationMuteLabel.ServiceIncidentResolved(id, alert.ID), service, true)
+				NotificationShared.UnMuteNotification(alert.NotificationGroupID, NotificationMuteLabel.ServiceIncident(id, alert.ID))
+			}
+			alertsPrevState[alert.ID][id] = _RuleCheckPass
+		}
+		if max > 0 && max < len(alertsStore[alert.ID][id]) {
+			alertsStore[alert.ID][id] = alertsStore[alert.ID][id][len(alertsStore[alert.ID][id])-max:]
+		}
+	}
+}
+
+// aggregateServerNames 列出计入汇总的服务器，过多时只列出前若干台
+func aggregateServerNames(servers []*model.Server) string {
+	const limit = 20
+	names := make([]string, 0, min(len(servers), limit))
+	for _, s := range servers[:min(len(servers), limit)] {
+		names = append(names, s.Name)
+	}
+	if len(servers) > limit {
+		names = append(names, fmt.Sprintf("+%d", len(servers)-limit))
+	}
+	return strings.Join(names, ", ")
+}
+
+// staleFieldDetails 列出 field_stale 规则检查的字段及其最近一次有效的值与时间，字段缺失期间内容不变，不影响通知去重
+func staleFieldDetails(alert *model.AlertRule, server *model.Server) string {
+	t := NotificationShared.Lang(alert.NotificationGroupID)
+	now := time.Now()
+	var b strings.Builder
+	for _, rule := range alert.Rules {
+		if !rule.IsFieldStaleRule() {
+			continue
+		}
+		b.WriteByte('\n')
+		if _, value, at := server.Freshness.Stale(rule.StaleField, now); at.IsZero() {
+			b.WriteString(t.Tf("%s: not reported", rule.StaleField))
+		} else {
+			b.WriteString(t.Tf("%s: last reported %s at %s", rule.StaleField, value, at.In(Loc).Format(time.DateTime)))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,138 @@
+package singleton
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+// 最后使用时间写入数据库的最小间隔
+const apiTokenTouchInterval = time.Minute
+
+var errInvalidAPIToken = errors.New("invalid api token")
+
+// APITokenClass 缓存已验证的 API Token 及其所属用户，撤销 Token 或更新用户时从缓存中移除
+type APITokenClass struct {
+	mu    sync.RWMutex
+	cache map[string]*apiTokenEntry
+}
+
+type apiTokenEntry struct {
+	token *model.APIToken
+	user  *model.User
+}
+
+func NewAPITokenClass() *APITokenClass {
+	return &APITokenClass{
+		cache: make(map[string]*apiTokenEntry),
+	}
+}
+
+// reset 清空缓存，下次验证时从数据库读取
+func (c *APITokenClass) reset() {
+	c.mu.Lock()
+	c.cache = make(map[string]*apiTokenEntry)
+	c.mu.Unlock()
+}
+
+// forgetUser 移除用户的所有 Token，下次验证时重新读取用户
+func (c *APITokenClass) forgetUser(uid uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for hash, e := range c.cache {
+		if e.token.UserID == uid {
+			delete(c.cache, hash)
+		}
+	}
+}
+
+// Resolve 验证 Token 并返回其所属用户
+func (c *APITokenClass) Resolve(token string) (*model.APIToken, *model.User, error) {
+	hash := model.HashAPIToken(token)
+
+	c.mu.RLock()
+	e, ok := c.cache[hash]
+	c.mu.RUnlock()
+
+	if !ok {
+		var (
+			t    model.APIToken
+			user model.User
+		)
+		if err := DB.Where("token_hash = ?", hash).First(&t).Error; err != nil {
+			return nil, nil, errInvalidAPIToken
+		}
+		if err := DB.First(&user, t.UserID).Error; err != nil {
+			return nil, nil, errInvalidAPIToken
+		}
+		e = &apiTokenEntry{token: &t, user: &user}
+		c.mu.Lock()
+		c.cache[hash] = e
+		c.mu.Unlock()
+	}
+	if e.token.Expired() {
+		return nil, nil, errInvalidAPIToken
+	}
+
+	c.touch(e.token)
+	// 返回副本，避免请求修改缓存中的用户
+	user := *e.user
+	return e.token, &user, nil
+}
+
+func (c *APITokenClass) touch(t *model.APIToken) {
+	now := time.Now()
+	c.mu.Lock()
+	if t.LastUsedAt != nil && now.Sub(*t.LastUsedAt) < apiTokenTouchInterval {
+		c.mu.Unlock()
+		return
+	}
+	t.LastUsedAt = &now
+	c.mu.Unlock()
+
+	if err := DB.Model(&model.APIToken{}).Where("id = ?", t.ID).Update("last_used_at", now).Error; err != nil {
+		log.Printf("NEZHA>> Failed to update API token last used time: %v", err)
+	}
+}
+
+// Revoke 删除 Token，之后的请求立即失效
+func (c *APITokenClass) Revoke(uid, id uint64) error {
+	result := DB.Unscoped().Delete(&model.APIToken{}, "id = ? AND user_id = ?", id, uid)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return Localizer.ErrorT("token id %d does not exist", id)
+	}
+
+	c.mu.Lock()
+	for hash, e := range c.cache {
+		if e.token.ID == id {
+			delete(c.cache, hash)
+		}
+	}
+	c.mu.Unlock()
+	ClusterShared.PublishChange("user/tokens")
+	return nil
+}
+
+func (c *APITokenClass) List(uid uint64) ([]*model.APIToken, error) {
+	var tokens []*model.APIToken
+	if err := DB.Where("user_id = ?", uid).Order("id").Find(&tokens).Error; err != nil {
+		return nil, err
+	}
+
+	// 返回缓存中较新的最后使用时间
+	c.mu.RLock()
+	for _, t := range tokens {
+		if cached, ok := c.cache[t.TokenHash]; ok && cached.token.LastUsedAt != nil {
+			lastUsedAt := *cached.token.LastUsedAt
+			t.LastUsedAt = &lastUsedAt
+		}
+	}
+	c.mu.RUnlock()
+	return tokens, nil
+}
@@ -0,0 +1,118 @@
+package singleton
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/i18n"
+)
+
+func setupAPITokenTest(t *testing.T) (*APITokenClass, *model.User) {
+	setupTestDB(t, model.User{}, model.APIToken{})
+	oldLocalizer, oldShared := Localizer, APITokenShared
+	oldInfo, oldSecrets, oldSlugs := UserInfoMap, AgentSecretToUserId, PublicSlugToUserId
+	Localizer = i18n.NewLocalizer("en_US", domain, "translations", i18n.Translations)
+	UserInfoMap = make(map[uint64]model.UserInfo)
+	AgentSecretToUserId = make(map[string]uint64)
+	PublicSlugToUserId = make(map[string]uint64)
+	t.Cleanup(func() {
+		Localizer, APITokenShared = oldLocalizer, oldShared
+		UserInfoMap, AgentSecretToUserId, PublicSlugToUserId = oldInfo, oldSecrets, oldSlugs
+	})
+
+	user := &model.User{Username: "member", Role: model.RoleMember, AgentSecret: "secret"}
+	if err := DB.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	APITokenShared = NewAPITokenClass()
+	return APITokenShared, user
+}
+
+func createTestAPIToken(t *testing.T, uid uint64, token string, expiresAt *time.Time) *model.APIToken {
+	at := &model.APIToken{
+		Common:    model.Common{UserID: uid},
+		Name:      token,
+		TokenHash: model.HashAPIToken(token),
+		Scopes:    []string{model.APITokenScopeRead},
+		ExpiresAt: expiresAt,
+	}
+	if err := DB.Create(at).Error; err != nil {
+		t.Fatal(err)
+	}
+	return at
+}
+
+func TestAPITokenResolve(t *testing.T) {
+	c, user := setupAPITokenTest(t)
+	createTestAPIToken(t, user.ID, "nzt_valid", nil)
+	expired := time.Now().Add(-time.Minute)
+	createTestAPIToken(t, user.ID, "nzt_expired", &expired)
+
+	at, u, err := c.Resolve("nzt_valid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.ID != user.ID || !at.HasScope(model.APITokenScopeRead) || at.HasScope(model.APITokenScopeServerWrite) {
+		t.Fatalf("unexpected token %+v of user %d", at, u.ID)
+	}
+	if _, _, err := c.Resolve("nzt_expired"); err == nil {
+		t.Fatal("expected expired token to be rejected")
+	}
+	if _, _, err := c.Resolve("nzt_unknown"); err == nil {
+		t.Fatal("expected unknown token to be rejected")
+	}
+
+	// 用户从缓存读取，修改返回值不影响之后的请求
+	u.Role = model.RoleAdmin
+	if err := DB.Model(user).Update("role", model.RoleViewer).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, u, _ := c.Resolve("nzt_valid"); u == nil || u.Role != model.RoleMember {
+		t.Fatalf("expected cached user to be returned, got %+v", u)
+	}
+
+	// 更新用户后重新读取
+	user.Role = model.RoleViewer
+	OnUserUpdate(user)
+	if _, u, _ := c.Resolve("nzt_valid"); u == nil || u.Role != model.RoleViewer {
+		t.Fatalf("expected updated user to be returned, got %+v", u)
+	}
+}
+
+func TestAPITokenRevoke(t *testing.T) {
+	c, user := setupAPITokenTest(t)
+	at := createTestAPIToken(t, user.ID, "nzt_revoked", nil)
+	createTestAPIToken(t, user.ID, "nzt_kept", nil)
+
+	for _, token := range []string{"nzt_revoked", "nzt_kept"} {
+		if _, _, err := c.Resolve(token); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := c.Revoke(user.ID+1, at.ID); err == nil {
+		t.Fatal("expected token of another user to not be revoked")
+	}
+	if _, _, err := c.Resolve("nzt_revoked"); err != nil {
+		t.Fatalf("expected token to stay valid after a rejected revoke: %v", err)
+	}
+
+	if err := c.Revoke(user.ID, at.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := c.Resolve("nzt_revoked"); err == nil {
+		t.Fatal("expected revoked token to be rejected")
+	}
+	if _, _, err := c.Resolve("nzt_kept"); err != nil {
+		t.Fatalf("expected other token to stay valid: %v", err)
+	}
+
+	tokens, err := c.List(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) != 1 || tokens[0].LastUsedAt == nil {
+		t.Fatalf("expected the remaining token with its last used time, got %+v", tokens)
+	}
+}
@@ -0,0 +1,68 @@
+package singleton
+
+import (
+	"log"
+	"time"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+const (
+	auditLogQueueSize     = 1024
+	auditLogBatchSize     = 100
+	auditLogFlushInterval = time.Second
+)
+
+// AuditLogClass 异步批量写入审计日志，避免影响请求耗时
+type AuditLogClass struct {
+	queue chan *model.AuditLog
+}
+
+func NewAuditLogClass() *AuditLogClass {
+	c := &AuditLogClass{
+		queue: make(chan *model.AuditLog, auditLogQueueSize),
+	}
+	go c.worker()
+	return c
+}
+
+// Record 将审计日志加入写入队列，队列已满时丢弃
+func (c *AuditLogClass) Record(l *model.AuditLog) {
+	select {
+	case c.queue <- l:
+	default:
+		log.Printf("NEZHA>> Audit log queue is full, dropped: user %d %s %s", l.UserID, l.Method, l.Route)
+	}
+}
+
+func (c *AuditLogClass) worker() {
+	ticker := time.NewTicker(auditLogFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*model.AuditLog, 0, auditLogBatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := DB.CreateInBatches(batch, auditLogBatchSize).Error; err != nil {
+			log.Printf("NEZHA>> Failed to save audit logs: %v", err)
+		}
+		batch = make([]*model.AuditLog, 0, auditLogBatchSize)
+	}
+
+	for {
+		select {
+		case l := <-c.queue:
+			batch = append(batch, l)
+			if len(batch) >= auditLogBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+func cleanAuditLog() {
+	DB.Unscoped().Delete(&model.AuditLog{}, "created_at < ?", time.Now().AddDate(0, 0, -Conf.AuditLogRetentionDays))
+}
@@ -0,0 +1,344 @@
+package singleton
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+const (
+	backupFilePrefix = "nezha-"
+	backupFileSuffix = ".db"
+
+	BackupTriggerManual   = "manual"
+	BackupTriggerSchedule = "schedule"
+	BackupTriggerRestore  = "restore"
+)
+
+// 恢复时不覆盖的表，保留恢复操作本身的审计记录与当前的结构版本
+var restoreSkipTables = []string{"audit_logs", "schema_migrations"}
+
+var (
+	dbPath string
+
+	backupMu  sync.Mutex
+	restoring atomic.Bool
+)
+
+// IsRestoring 是否正在恢复备份，恢复期间拒绝修改操作
+func IsRestoring() bool {
+	return restoring.Load()
+}
+
+func backupDir() string {
+	if Conf.Backup.Dir != "" {
+		return Conf.Backup.Dir
+	}
+	return filepath.Join(filepath.Dir(dbPath), "backup")
+}
+
+// SnapshotDB 使用 VACUUM INTO 生成数据库的一致性快照，目标文件不能已存在
+func SnapshotDB(path string) error {
+	return DB.Exec("VACUUM INTO ?", path).Error
+}
+
+// CreateBackup 将数据库快照写入备份目录，并按配置清理旧备份
+func CreateBackup(trigger string) (*model.BackupInfo, error) {
+	backupMu.Lock()
+	defer backupMu.Unlock()
+
+	return createBackup(trigger)
+}
+
+func createBackup(trigger string) (*model.BackupInfo, error) {
+	info, err := snapshotToBackupDir()
+	if err != nil {
+		log.Printf("NEZHA>> Database backup failed: %v", err)
+		notifyBackup(trigger, nil, err)
+		return nil, err
+	}
+
+	if err := rotateBackups(); err != nil {
+		log.Printf("NEZHA>> Failed to rotate backups: %v", err)
+	}
+	notifyBackup(trigger, info, nil)
+	return info, nil
+}
+
+func snapshotToBackupDir() (*model.BackupInfo, error) {
+	dir := backupDir()
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+
+	name := backupFilePrefix + time.Now().Format("20060102-150405") + backupFileSuffix
+	path := filepath.Join(dir, name)
+	if err := SnapshotDB(path); err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	return &model.BackupInfo{Name: name, Size: fi.Size(), CreatedAt: fi.ModTime()}, nil
+}
+
+// ScheduledBackup 定时备份任务
+func ScheduledBackup() {
+	CreateBackup(BackupTriggerSchedule)
+}
+
+// ListBackups 列出备份目录中的备份，按时间倒序
+func ListBackups() ([]*model.BackupInfo, error) {
+	entries, err := os.ReadDir(backupDir())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var backups []*model.BackupInfo
+	for _, e := range entries {
+		if e.IsDir() || !isBackupName(e.Name()) {
+			continue
+		}
+		fi, err := e.Info()
+		if err != nil {
+			continue
+		}
+		backups = append(backups, &model.BackupInfo{Name: e.Name(), Size: fi.Size(), CreatedAt: fi.ModTime()})
+	}
+	slices.SortFunc(backups, func(a, b *model.BackupInfo) int {
+		return cmp.Or(b.CreatedAt.Compare(a.CreatedAt), cmp.Compare(b.Name, a.Name))
+	})
+	return backups, nil
+}
+
+func rotateBackups() error {
+	backups, err := ListBackups()
+	if err != nil || len(backups) <= Conf.Backup.Keep {
+		return err
+	}
+
+	var errs []error
+	for _, b := range backups[Conf.Backup.Keep:] {
+		errs = append(errs, os.Remove(filepath.Join(backupDir(), b.Name)))
+	}
+	return errors.Join(errs...)
+}
+
+func isBackupName(name string) bool {
+	return name == filepath.Base(name) && strings.HasPrefix(name, backupFilePrefix) && strings.HasSuffix(name, backupFileSuffix)
+}
+
+// BackupPath 返回备份目录中指定备份的路径
+func BackupPath(name string) (string, error) {
+	if !isBackupName(name) {
+		return "", fmt.Errorf("invalid backup name: %s", name)
+	}
+	path := filepath.Join(backupDir(), name)
+	if _, err := os.Stat(path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func notifyBackup(trigger string, info *model.BackupInfo, err error) {
+	if Conf.Backup.WebhookURL == "" {
+		return
+	}
+
+	event := &model.BackupEvent{
+		Event:   "backup_succeeded",
+		Backup:  info,
+		Trigger: trigger,
+		Time:    time.Now(),
+	}
+	if err != nil {
+		event.Event = "backup_failed"
+		event.Error = err.Error()
+	}
+	go func() {
+		if err := postWebhook(Conf.Backup.WebhookURL, event); err != nil {
+			log.Printf("NEZHA>> Failed to send backup webhook: %v", err)
+		}
+	}()
+}
+
+// RestoreBackup 校验并恢复备份，恢复前会先备份当前数据库，恢复后重新加载各子服务的缓存
+func RestoreBackup(path string) error {
+	if !backupMu.TryLock() {
+		return errors.New("another backup or restore is in progress")
+	}
+	defer backupMu.Unlock()
+
+	if err := validateBackup(path); err != nil {
+		return err
+	}
+
+	restoring.Store(true)
+	defer restoring.Store(false)
+
+	if _, err := createBackup(BackupTriggerRestore); err != nil {
+		return fmt.Errorf("failed to backup current database: %w", err)
+	}
+	if err := applyBackup(path); err != nil {
+		return err
+	}
+	if err := ReloadSingleton(); err != nil {
+		return err
+	}
+	ClusterShared.PublishChange("admin/restore")
+	return nil
+}
+
+func validateBackup(path string) error {
+	bak, err := gorm.Open(sqlite.Open("file:"+path+"?mode=ro"), &gorm.Config{
+		Logger: logger.Discard,
+	})
+	if err != nil {
+		return err
+	}
+	if sqlDB, err := bak.DB(); err == nil {
+		defer sqlDB.Close()
+	}
+
+	var result string
+	if err := bak.Raw("PRAGMA integrity_check").Scan(&result).Error; err != nil {
+		return fmt.Errorf("invalid backup: %w", err)
+	}
+	if result != "ok" {
+		return fmt.Errorf("invalid backup: integrity check failed: %s", result)
+	}
+
+	for _, table := range []string{"users", "servers"} {
+		if !bak.Migrator().HasTable(table) {
+			return fmt.Errorf("invalid backup: missing table %s", table)
+		}
+	}
+	var users int64
+	if err := bak.Table("users").Count(&users).Error; err != nil {
+		return fmt.Errorf("invalid backup: %w", err)
+	}
+	if users == 0 {
+		return errors.New("invalid backup: no user found")
+	}
+	return nil
+}
+
+// applyBackup 将备份中的数据逐表复制到当前数据库，只复制两边都存在的列，以兼容不同版本的备份
+func applyBackup(path string) error {
+	release := holdDBWriter()
+	defer release()
+
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return err
+	}
+
+	// ATTACH 只对当前连接生效，需使用同一连接完成恢复
+	ctx := context.Background()
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "ATTACH DATABASE ? AS bak", path); err != nil {
+		return err
+	}
+	defer conn.ExecContext(ctx, "DETACH DATABASE bak")
+
+	tables, err := queryNames(ctx, conn, "SELECT name FROM main.sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%'")
+	if err != nil {
+		return err
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, table := range tables {
+		if slices.Contains(restoreSkipTables, table) {
+			continue
+		}
+
+		mainCols, err := queryNames(ctx, tx, "SELECT name FROM pragma_table_info(?, 'main')", table)
+		if err != nil {
+			return err
+		}
+		bakCols, err := queryNames(ctx, tx, "SELECT name FROM pragma_table_info(?, 'bak')", table)
+		if err != nil {
+			return err
+		}
+
+		var cols []string
+		for _, col := range mainCols {
+			if slices.Contains(bakCols, col) {
+				cols = append(cols, quoteIdent(col))
+			}
+		}
+
+		if _, err := tx.ExecContext(ctx, "DELETE FROM main."+quoteIdent(table)); err != nil {
+			return err
+		}
+		// 备份中不存在的表恢复为空表
+		if len(cols) == 0 {
+			continue
+		}
+		colList := strings.Join(cols, ", ")
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("INSERT INTO main.%s (%s) SELECT %s FROM bak.%s",
+			quoteIdent(table), colList, colList, quoteIdent(table))); err != nil {
+			return fmt.Errorf("failed to restore table %s: %w", table, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+func queryNames(ctx context.Context, q queryer, query string, args ...any) ([]string, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
@@ -0,0 +1,138 @@
+package singleton
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/goccy/go-json"
+	"gorm.io/gorm/clause"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/utils"
+)
+
+// validBrandingURL 允许为空、站内路径或 http(s) 地址
+func validBrandingURL(v string) error {
+	if v == "" || strings.HasPrefix(v, "/") && !strings.HasPrefix(v, "//") {
+		return nil
+	}
+	if u, err := url.Parse(v); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return Localizer.ErrorT("invalid url: %s", v)
+	}
+	return nil
+}
+
+// validCustomCSS 自定义样式直接插入 style 标签，不允许出现 < 以免闭合标签
+func validCustomCSS(v string) error {
+	if strings.Contains(v, "<") {
+		return Localizer.ErrorT("custom css must not contain <")
+	}
+	return nil
+}
+
+func validBrandingLanguage(v string) error {
+	if Localizer != nil && !ValidLanguage(v) {
+		return Localizer.ErrorT("unsupported language: %s", v)
+	}
+	return nil
+}
+
+func validBrandingTheme(v string) error {
+	if v != "" && !slices.Contains(model.BrandingThemes, v) {
+		return Localizer.ErrorT("invalid theme: %s", v)
+	}
+	return nil
+}
+
+// PublicSettings 返回无需登录即可获取的设置及其 ETag
+func PublicSettings() (*model.PublicSettingResponse, string, error) {
+	b := Conf.Branding
+	resp := &model.PublicSettingResponse{
+		SiteName:          Conf.SiteName,
+		ReadOnly:          Conf.ReadOnly,
+		MaintenanceBanner: Conf.MaintenanceBanner,
+		Branding: model.Branding{
+			SiteTitle:       b.SiteTitle,
+			LogoURL:         b.LogoURL,
+			FaviconURL:      b.FaviconURL,
+			FooterHTML:      utils.SanitizeHTML(b.FooterHTML),
+			CustomCSS:       b.CustomCSS,
+			DefaultLanguage: strings.Replace(b.DefaultLanguage, "_", "-", -1),
+			DefaultTheme:    b.DefaultTheme,
+		},
+		Display: Conf.Display,
+	}
+	if resp.Branding.SiteTitle == "" {
+		resp.Branding.SiteTitle = Conf.SiteName
+	}
+
+	var assets []model.BrandingAsset
+	if err := DB.Select("name", "sha256").Find(&assets).Error; err != nil {
+		return nil, "", err
+	}
+	for _, a := range assets {
+		// 地址中带上文件摘要，更换后浏览器不会使用旧的缓存
+		u := "/api/v1/branding/" + a.Name + "?v=" + a.SHA256[:12]
+		switch a.Name {
+		case model.BrandingAssetLogo:
+			resp.Branding.LogoURL = u
+		case model.BrandingAssetFavicon:
+			resp.Branding.FaviconURL = u
+		}
+	}
+
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return nil, "", err
+	}
+	sum := sha1.Sum(data)
+	return resp, `W/"` + hex.EncodeToString(sum[:]) + `"`, nil
+}
+
+// SaveBrandingAsset 校验并保存上传的图标，文件类型按内容判断
+func SaveBrandingAsset(name string, content []byte) (*model.BrandingAsset, error) {
+	if !slices.Contains(model.BrandingAssetNames, name) {
+		return nil, Localizer.ErrorT("unknown branding asset: %s", name)
+	}
+	if len(content) > model.BrandingAssetMaxSize {
+		return nil, Localizer.ErrorT("file size exceeds %d bytes", model.BrandingAssetMaxSize)
+	}
+	contentType := http.DetectContentType(content)
+	if !slices.Contains(model.BrandingAssetTypes, contentType) {
+		return nil, Localizer.ErrorT("unsupported file type: %s", contentType)
+	}
+
+	sum := sha256.Sum256(content)
+	asset := &model.BrandingAsset{
+		Name:        name,
+		ContentType: contentType,
+		Size:        int64(len(content)),
+		SHA256:      hex.EncodeToString(sum[:]),
+		Content:     content,
+	}
+	if err := DB.Clauses(clause.OnConflict{UpdateAll: true}).Create(asset).Error; err != nil {
+		return nil, err
+	}
+	return asset, nil
+}
+
+// GetBrandingAsset 未上传时返回 nil
+func GetBrandingAsset(name string) (*model.BrandingAsset, error) {
+	var assets []*model.BrandingAsset
+	if err := DB.Where("name = ?", name).Limit(1).Find(&assets).Error; err != nil {
+		return nil, err
+	}
+	if len(assets) == 0 {
+		return nil, nil
+	}
+	return assets[0], nil
+}
+
+func DeleteBrandingAsset(name string) error {
+	return DB.Delete(&model.BrandingAsset{}, "name = ?", name).Error
+}
@@ -0,0 +1,419 @@
+package singleton
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-json"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/pubsub"
+	"github.com/nezhahq/nezha/pkg/utils"
+)
+
+const (
+	clusterHeartbeatInterval = 5 * time.Second
+	clusterPeerTimeout       = 3 * clusterHeartbeatInterval
+	clusterRetryInterval     = 3 * time.Second
+	clusterStateBuffer       = 1024
+)
+
+const (
+	clusterEventHeartbeat = "heartbeat"
+	clusterEventChange    = "change"
+	clusterEventClaim     = "claim"
+	clusterEventState     = "state"
+	clusterEventPurge     = "purge"
+)
+
+// clusterReloaders 其他节点修改数据后需要重新加载的缓存，键为 PublishChange 的数据类型。
+// 修改数据的代码在写入数据库后调用 PublishChange，重新加载时不再发出通知
+var clusterReloaders = map[string]func() error{
+	"server":             func() error { ServerShared.reload(); return nil },
+	"service":            func() error { return ServiceSentinelShared.reload() },
+	"notification":       func() error { NotificationShared.reload(); return nil },
+	"notification-group": func() error { NotificationShared.reload(); return nil },
+	"alert-rule":         reloadAlerts,
+	"cron":               func() error { return CronShared.reload() },
+	"ddns":               func() error { DDNSShared.reload(); return nil },
+	"nat":                func() error { NATShared.reload(); return nil },
+	"command-policy":     func() error { CommandPolicyShared.reload(); return nil },
+	"access-grant":       func() error { AccessGrantShared.Reload(); return nil },
+	"server-group":       func() error { ServerShared.RefreshGroups(); AccessGrantShared.Reload(); return nil },
+	"user":               reloadUsers,
+	"user/tokens":        func() error { APITokenShared.reset(); return nil },
+	"user/sessions":      func() error { SessionShared.reload(); return nil },
+	"waf/rules":          func() error { WAFRuleShared.Reload(); return nil },
+	alertActionsEntity:   loadAlertActions,
+	"admin/restore":      ReloadSingleton,
+	"admin/settings":     reloadSettings,
+}
+
+type clusterEvent struct {
+	Node string `json:"node"`
+	Type string `json:"type"`
+
+	Entity string `json:"entity,omitempty"`
+	Online int    `json:"online,omitempty"`
+
+	ServerID   uint64           `json:"server_id,omitempty"`
+	Host       *model.Host      `json:"host,omitempty"`
+	State      *model.HostState `json:"state,omitempty"`
+	GeoIP      *model.GeoIP     `json:"geoip,omitempty"`
+	LastActive time.Time        `json:"last_active"`
+	// 状态来自心跳上报时为 Agent 的上报时间，Unix 秒
+	HeartbeatAt int64 `json:"heartbeat_at,omitempty"`
+
+	Containers *model.ContainerReport `json:"containers,omitempty"`
+
+	Purge *model.PurgeJob `json:"purge,omitempty"`
+}
+
+type clusterPeer struct {
+	online   int
+	lastSeen time.Time
+}
+
+// ClusterClass 多节点部署时在节点间广播缓存失效与服务器状态，为 nil 时以单节点运行
+type ClusterClass struct {
+	ps      pubsub.PubSub
+	node    string
+	channel string
+	cancel  context.CancelFunc
+
+	mu     sync.RWMutex
+	peers  map[string]*clusterPeer
+	owners map[uint64]string // 服务器 ID 到持有 Agent 连接的节点
+
+	states chan *clusterEvent // 等待发送的服务器状态，避免上报处理等待消息广播
+}
+
+var ClusterShared *ClusterClass
+
+// InitCluster 根据配置连接消息广播，未配置时不启用
+func InitCluster() error {
+	conf := &Conf.Cluster
+	var ps pubsub.PubSub
+	switch conf.Type {
+	case "":
+		return nil
+	case model.ClusterTypeRedis:
+		r, err := pubsub.NewRedis(conf.DSN)
+		if err != nil {
+			return fmt.Errorf("init cluster: %w", err)
+		}
+		ps = r
+	default:
+		return fmt.Errorf("unsupported cluster type: %s", conf.Type)
+	}
+
+	node := conf.NodeID
+	if node == "" {
+		hostname, _ := os.Hostname()
+		node = hostname + "-" + utils.MustGenerateRandomString(6)
+	}
+	channel := conf.Channel
+	if channel == "" {
+		channel = "nezha"
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ClusterShared = &ClusterClass{
+		ps:      ps,
+		node:    node,
+		channel: channel,
+		cancel:  cancel,
+		peers:   make(map[string]*clusterPeer),
+		owners:  make(map[uint64]string),
+		states:  make(chan *clusterEvent, clusterStateBuffer),
+	}
+	go ClusterShared.subscribe(ctx)
+	go ClusterShared.heartbeat(ctx)
+	go ClusterShared.publishStates(ctx)
+	log.Printf("NEZHA>> Cluster enabled, node %s", node)
+	return nil
+}
+
+func (c *ClusterClass) Close() {
+	if c == nil {
+		return
+	}
+	c.cancel()
+	c.ps.Close()
+}
+
+func (c *ClusterClass) subscribe(ctx context.Context) {
+	for {
+		err := c.ps.Subscribe(ctx, c.channel, c.handle)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("NEZHA>> Cluster subscription lost: %v", err)
+		time.Sleep(clusterRetryInterval)
+	}
+}
+
+func (c *ClusterClass) heartbeat(ctx context.Context) {
+	ticker := time.NewTicker(clusterHeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		c.publish(&clusterEvent{Type: clusterEventHeartbeat, Online: GetOnlineUserCount()})
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// publishStates 依次发送缓冲中的服务器状态
+func (c *ClusterClass) publishStates(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e := <-c.states:
+			c.publish(e)
+		}
+	}
+}
+
+func (c *ClusterClass) publish(e *clusterEvent) {
+	e.Node = c.node
+	payload, err := json.Marshal(e)
+	if err != nil {
+		log.Printf("NEZHA>> Failed to encode cluster event: %v", err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), clusterRetryInterval)
+	defer cancel()
+	if err := c.ps.Publish(ctx, c.channel, payload); err != nil {
+		log.Printf("NEZHA>> Failed to publish cluster event %s: %v", e.Type, err)
+	}
+}
+
+func (c *ClusterClass) handle(payload []byte) {
+	var e clusterEvent
+	if err := json.Unmarshal(payload, &e); err != nil || e.Node == c.node {
+		return
+	}
+
+	c.mu.Lock()
+	peer, ok := c.peers[e.Node]
+	if !ok {
+		peer = &clusterPeer{}
+		c.peers[e.Node] = peer
+	}
+	peer.lastSeen = time.Now()
+	if e.Type == clusterEventHeartbeat {
+		peer.online = e.Online
+	}
+	if e.Type == clusterEventClaim {
+		c.owners[e.ServerID] = e.Node
+	}
+	c.mu.Unlock()
+
+	// 子服务加载完成前收到的事件无需处理，加载时会读取最新数据
+	if !Loaded() {
+		return
+	}
+
+	switch e.Type {
+	case clusterEventChange:
+		if reload, ok := clusterReloaders[e.Entity]; ok {
+			if err := reload(); err != nil {
+				log.Printf("NEZHA>> Failed to reload %s from cluster event: %v", e.Entity, err)
+			}
+		}
+	case clusterEventClaim:
+		// Agent 已连接到其他节点，本节点上的连接已失效
+		ServerShared.listMu.Lock()
+		if s, ok := ServerShared.list[e.ServerID]; ok {
+			s.TaskStream = nil
+		}
+		ServerShared.listMu.Unlock()
+	case clusterEventState:
+		if s, ok := applyClusterState(&e); ok && e.Containers == nil && e.State != nil && e.HeartbeatAt == 0 {
+			ServerShared.RecordState(s)
+		}
+	case clusterEventPurge:
+		if e.Purge != nil {
+			PurgeShared.applyRemote(e.Purge)
+		}
+	}
+}
+
+// applyClusterState 在服务器列表的写锁内更新其他节点同步的状态
+func applyClusterState(e *clusterEvent) (*model.Server, bool) {
+	ServerShared.listMu.Lock()
+	defer ServerShared.listMu.Unlock()
+
+	s, ok := ServerShared.list[e.ServerID]
+	if !ok {
+		return nil, false
+	}
+	if e.Host != nil {
+		s.Host = e.Host
+	}
+	if e.State != nil {
+		s.State = e.State
+		s.HeartbeatAt = time.Time{}
+		if e.HeartbeatAt > 0 {
+			s.HeartbeatAt = time.Unix(e.HeartbeatAt, 0)
+		}
+	}
+	if e.Containers != nil {
+		s.Containers = e.Containers
+		return s, true
+	}
+	if e.GeoIP != nil {
+		s.GeoIP = e.GeoIP
+	}
+	s.LastActive = e.LastActive
+	return s, true
+}
+
+// PublishChange 通知其他节点重新加载被修改的数据
+func (c *ClusterClass) PublishChange(entity string) {
+	if c == nil {
+		return
+	}
+	if _, ok := clusterReloaders[entity]; ok {
+		c.publish(&clusterEvent{Type: clusterEventChange, Entity: entity})
+	}
+}
+
+// ClaimServer Agent 连接到本节点时声明由本节点负责该服务器
+func (c *ClusterClass) ClaimServer(id uint64) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	c.owners[id] = c.node
+	c.mu.Unlock()
+	c.publish(&clusterEvent{Type: clusterEventClaim, ServerID: id})
+}
+
+// PublishServerState 将 Agent 上报的状态异步同步到其他节点，缓冲已满时丢弃本次状态，下次上报会再次同步
+func (c *ClusterClass) PublishServerState(s *model.Server) {
+	if c == nil {
+		return
+	}
+	e := &clusterEvent{
+		Type:       clusterEventState,
+		ServerID:   s.ID,
+		Host:       s.Host,
+		State:      s.State,
+		GeoIP:      s.GeoIP,
+		LastActive: s.LastActive,
+	}
+	if !s.HeartbeatAt.IsZero() {
+		e.HeartbeatAt = s.HeartbeatAt.Unix()
+	}
+	select {
+	case c.states <- e:
+	default:
+		log.Printf("NEZHA>> Cluster state buffer is full, dropping state of server %d", s.ID)
+	}
+}
+
+// PublishServerContainers 将 Agent 上报的容器列表同步到其他节点
+func (c *ClusterClass) PublishServerContainers(s *model.Server) {
+	if c == nil {
+		return
+	}
+	c.publish(&clusterEvent{
+		Type:       clusterEventState,
+		ServerID:   s.ID,
+		Containers: s.Containers,
+	})
+}
+
+// PublishPurge 将清理任务的进度同步到其他节点，任务结束后其他节点清理各自内存中的数据
+func (c *ClusterClass) PublishPurge(job *model.PurgeJob) {
+	if c == nil {
+		return
+	}
+	c.publish(&clusterEvent{Type: clusterEventPurge, Purge: job})
+}
+
+func (c *ClusterClass) alivePeers() []string {
+	var nodes []string
+	for node, p := range c.peers {
+		if time.Since(p.lastSeen) < clusterPeerTimeout {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+// IsLeader 节点名称最小的存活节点负责执行清理、备份等全局任务
+func (c *ClusterClass) IsLeader() bool {
+	if c == nil {
+		return true
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.isLeader()
+}
+
+func (c *ClusterClass) isLeader() bool {
+	return !slices.ContainsFunc(c.alivePeers(), func(node string) bool { return node < c.node })
+}
+
+// OwnsServer 服务器的报警检查与流量统计是否由本节点负责
+// 由持有 Agent 连接的节点负责，Agent 离线或所在节点失联时由 leader 负责
+func (c *ClusterClass) OwnsServer(id uint64) bool {
+	if c == nil {
+		return true
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if owner, ok := c.owners[id]; ok {
+		if owner == c.node {
+			return true
+		}
+		if p, ok := c.peers[owner]; ok && time.Since(p.lastSeen) < clusterPeerTimeout {
+			return false
+		}
+	}
+	return c.isLeader()
+}
+
+// peerOnlineUsers 其他存活节点上的在线用户数
+func (c *ClusterClass) peerOnlineUsers() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var n int
+	for _, node := range c.alivePeers() {
+		n += c.peers[node].online
+	}
+	return n
+}
+
+// OnClusterLeader 包装全局任务，多节点部署时仅在 leader 上执行
+func OnClusterLeader(f func()) func() {
+	return func() {
+		if ClusterShared.IsLeader() {
+			f()
+		}
+	}
+}
+
+func reloadUsers() error {
+	APITokenShared.reset()
+	UserLock.Lock()
+	defer UserLock.Unlock()
+	initUser()
+	return nil
+}
@@ -0,0 +1,109 @@
+package singleton
+
+import (
+	"context"
+	"testing"
+
+	"github.com/goccy/go-json"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+func newTestCluster(node string) *ClusterClass {
+	return &ClusterClass{
+		node:   node,
+		peers:  make(map[string]*clusterPeer),
+		owners: make(map[uint64]string),
+	}
+}
+
+func sendClusterEvent(c *ClusterClass, e clusterEvent) {
+	payload, _ := json.Marshal(e)
+	c.handle(payload)
+}
+
+func TestClusterOwnership(t *testing.T) {
+	var single *ClusterClass
+	if !single.IsLeader() || !single.OwnsServer(1) || single.peerOnlineUsers() != 0 {
+		t.Fatal("expected single node to own everything")
+	}
+
+	c := newTestCluster("b")
+	sendClusterEvent(c, clusterEvent{Node: "a", Type: clusterEventHeartbeat, Online: 3})
+	if c.IsLeader() {
+		t.Fatal("expected node a to be the leader")
+	}
+	if c.peerOnlineUsers() != 3 {
+		t.Fatalf("expected 3 online users on peers, got %d", c.peerOnlineUsers())
+	}
+
+	// 未被任何节点声明的服务器由 leader 负责
+	if c.OwnsServer(1) {
+		t.Fatal("expected unclaimed server to belong to the leader")
+	}
+	c.owners[1] = "b"
+	if !c.OwnsServer(1) {
+		t.Fatal("expected claimed server to belong to this node")
+	}
+	sendClusterEvent(c, clusterEvent{Node: "a", Type: clusterEventClaim, ServerID: 1})
+	if c.OwnsServer(1) {
+		t.Fatal("expected server to move to node a")
+	}
+
+	// 自己发出的事件被忽略
+	sendClusterEvent(c, clusterEvent{Node: "b", Type: clusterEventClaim, ServerID: 2})
+	if _, ok := c.owners[2]; ok {
+		t.Fatal("expected own event to be ignored")
+	}
+}
+
+// loopbackPubSub 将发布的消息直接交给另一个节点处理
+type loopbackPubSub struct {
+	peer *ClusterClass
+}
+
+func (p *loopbackPubSub) Publish(_ context.Context, _ string, payload []byte) error {
+	p.peer.handle(payload)
+	return nil
+}
+
+func (p *loopbackPubSub) Subscribe(ctx context.Context, _ string, _ func([]byte)) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (p *loopbackPubSub) Close() error { return nil }
+
+func TestClusterPublishChangeOutsideHTTP(t *testing.T) {
+	setupTestDB(t, model.Session{})
+	oldCluster, oldSessions, wasLoaded := ClusterShared, SessionShared, loaded.Load()
+	oldReloader := clusterReloaders["user/sessions"]
+	t.Cleanup(func() {
+		ClusterShared, SessionShared = oldCluster, oldSessions
+		loaded.Store(wasLoaded)
+		clusterReloaders["user/sessions"] = oldReloader
+	})
+	loaded.Store(true)
+
+	var reloaded int
+	clusterReloaders["user/sessions"] = func() error { reloaded++; return nil }
+	peer := newTestCluster("b")
+	ClusterShared = newTestCluster("a")
+	ClusterShared.ps = &loopbackPubSub{peer: peer}
+
+	// 会话由 OAuth2 登出等非审计路径撤销时，其他节点同样需要重新加载
+	SessionShared = NewSessionClass()
+	s, err := SessionShared.Create(1, "127.0.0.1", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloaded != 0 {
+		t.Fatal("expected creating a session to not notify peers")
+	}
+	if err := SessionShared.Revoke(1, s.ID); err != nil {
+		t.Fatal(err)
+	}
+	if reloaded != 1 {
+		t.Fatalf("expected peer to reload sessions once, got %d", reloaded)
+	}
+}
@@ -0,0 +1,132 @@
+package singleton
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/utils"
+)
+
+type CommandPolicyClass struct {
+	class[uint64, *model.CommandPolicy]
+}
+
+func NewCommandPolicyClass() *CommandPolicyClass {
+	var sortedList []*model.CommandPolicy
+
+	DB.Find(&sortedList)
+	list := make(map[uint64]*model.CommandPolicy, len(sortedList))
+	for _, p := range sortedList {
+		list[p.ID] = p
+	}
+
+	return &CommandPolicyClass{
+		class: class[uint64, *model.CommandPolicy]{
+			list:       list,
+			sortedList: sortedList,
+			userList:   groupByUser(sortedList),
+		},
+	}
+}
+
+func (c *CommandPolicyClass) reload() {
+	pc := NewCommandPolicyClass()
+
+	c.listMu.Lock()
+	c.list = pc.list
+	c.listMu.Unlock()
+	c.sortList()
+}
+
+func (c *CommandPolicyClass) Update(p *model.CommandPolicy) {
+	c.listMu.Lock()
+	c.list[p.ID] = p
+	c.listMu.Unlock()
+
+	c.sortList()
+	ClusterShared.PublishChange("command-policy")
+}
+
+func (c *CommandPolicyClass) Delete(idList []uint64) {
+	c.listMu.Lock()
+	for _, id := range idList {
+		delete(c.list, id)
+	}
+	c.listMu.Unlock()
+
+	c.sortList()
+	ClusterShared.PublishChange("command-policy")
+}
+
+// Check 检查用户能否使用该命令，存在针对该用户的策略时不再检查角色策略
+func (c *CommandPolicyClass) Check(userID uint64, role uint8, command string) error {
+	var userPolicies, rolePolicies []*model.CommandPolicy
+	for _, p := range c.GetSortedList() {
+		if !p.AppliesTo(userID, role) {
+			continue
+		}
+		if p.TargetUserID != 0 {
+			userPolicies = append(userPolicies, p)
+		} else {
+			rolePolicies = append(rolePolicies, p)
+		}
+	}
+
+	policies := rolePolicies
+	if len(userPolicies) > 0 {
+		policies = userPolicies
+	}
+	for _, p := range policies {
+		pattern, violated := p.Violation(command)
+		if !violated {
+			continue
+		}
+		if p.Invalid() {
+			return Localizer.ErrorT("policy %s has invalid patterns, all commands are denied", p.Name)
+		}
+		if pattern != "" {
+			return Localizer.ErrorT("command matches denied pattern %s of policy %s", pattern, p.Name)
+		}
+		return Localizer.ErrorT("command does not match any allowed pattern of policy %s", p.Name)
+	}
+	return nil
+}
+
+// CheckOwner 按资源所有者的当前角色检查命令
+func (c *CommandPolicyClass) CheckOwner(userID uint64, command string) error {
+	role := ownerRole(userID)
+	if Conf.RequireAdminForShellTasks && role != model.RoleAdmin {
+		return Localizer.ErrorT("only administrators can manage shell tasks")
+	}
+	return c.Check(userID, role, command)
+}
+
+// CheckRendered 按资源所有者的当前角色检查展开模板后的命令，模板中的服务器名称等内容不受保存时的检查约束
+func (c *CommandPolicyClass) CheckRendered(userID uint64, command string) error {
+	return c.Check(userID, ownerRole(userID), command)
+}
+
+// ownerRole 返回用户的当前角色，不存在的用户按普通成员处理
+func ownerRole(userID uint64) uint8 {
+	UserLock.RLock()
+	defer UserLock.RUnlock()
+	if u, ok := UserInfoMap[userID]; ok {
+		return u.Role
+	}
+	return model.RoleMember
+}
+
+func (c *CommandPolicyClass) sortList() {
+	c.listMu.RLock()
+	defer c.listMu.RUnlock()
+
+	sortedList := utils.MapValuesToSlice(c.list)
+	slices.SortFunc(sortedList, func(a, b *model.CommandPolicy) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	c.sortedListMu.Lock()
+	defer c.sortedListMu.Unlock()
+	c.setSortedList(sortedList)
+}
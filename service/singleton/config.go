@@ -1,6 +1,7 @@
 package singleton
 
 import (
+	"errors"
 	"strconv"
 	"strings"
 
@@ -28,13 +29,34 @@ func InitConfigFromPath(path string) error {
 	}
 
 	Conf.updateIgnoredIPNotificationID()
+	if err := Conf.updateTrustedProxies(); err != nil {
+		return err
+	}
+	if Conf.ProxyProtocol && len(Conf.TrustedProxies) == 0 {
+		return errors.New("proxy_protocol requires trusted_proxies to be set")
+	}
+	if err := Conf.validateEncryption(); err != nil {
+		return err
+	}
+	if err := Conf.validateGRPCTLS(); err != nil {
+		return err
+	}
+	if err := Conf.updateAdminNetworks(); err != nil {
+		return err
+	}
+	if err := Conf.updateProbeDenyNetworks(); err != nil {
+		return err
+	}
 	Conf.Oauth2Providers = utils.MapKeysToSlice(Conf.Oauth2)
 	return nil
 }
 
 func (c *ConfigClass) Save() error {
 	c.updateIgnoredIPNotificationID()
-	return c.Config.Save()
+	if err := c.updateTrustedProxies(); err != nil {
+		return err
+	}
+	return fileConfig(c.Config).Save()
 }
 
 // updateIgnoredIPNotificationID 更新用于判断服务器ID是否属于特定服务器的map
@@ -51,3 +73,8 @@ func (c *ConfigClass) updateIgnoredIPNotificationID() {
 		}
 	}
 }
+
+// ReportsPaused 只读模式下或恢复备份期间暂停处理 Agent 上报
+func (c *ConfigClass) ReportsPaused() bool {
+	return IsRestoring() || (c.ReadOnly && c.ReadOnlyPauseReports)
+}
@@ -0,0 +1,521 @@
+package singleton
+
+import (
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/jinzhu/copier"
+
+	"github.com/nezhahq/nezha/model"
+	pb "github.com/nezhahq/nezha/proto"
+)
+
+const (
+	// Agent 超时后仍未上报结果时，面板额外等待的时间
+	cronTimeoutGrace = 30 * time.Second
+	// 未配置超时时间的任务，超过该时间未上报结果视为超时
+	cronMaxRunning = 24 * time.Hour
+	// 任务链的最大深度，防止配置错误导致无限执行
+	cronMaxChainDepth = 8
+	// 临时命令使用的任务 ID 起始值，与计划任务的 ID 区分
+	adhocTaskIDBase = 1 << 48
+	// 下发给 Agent 的任务 ID 为该值加执行记录的 ID，Agent 原样返回，用于对应执行记录
+	cronTaskIDBase = 1 << 56
+)
+
+type cronRunKey struct {
+	cronID   uint64
+	serverID uint64
+}
+
+type cronRunState struct {
+	running   []*model.CronExecution
+	queued    bool
+	queuedRun uint64 // 排队执行所属的触发记录
+
+	queuedParent *model.CronExecution
+}
+
+type cronTask struct {
+	key       cronRunKey
+	execution *model.CronExecution
+}
+
+// cronExecutionTracker 记录各服务器上正在执行的计划任务
+type cronExecutionTracker struct {
+	mu    sync.Mutex
+	runs  map[cronRunKey]*cronRunState
+	tasks map[uint64]cronTask // 已下发等待结果的任务，按任务 ID 索引
+
+	// 执行中的临时命令，不保存到数据库
+	adhoc    map[uint64]*model.Cron
+	adhocSeq uint64
+
+	stats *cronStatStore
+}
+
+func newCronExecutionTracker() *cronExecutionTracker {
+	return &cronExecutionTracker{
+		runs:  make(map[cronRunKey]*cronRunState),
+		tasks: make(map[uint64]cronTask),
+		adhoc: make(map[uint64]*model.Cron),
+		stats: newCronStatStore(),
+	}
+}
+
+func isAdhocTask(id uint64) bool {
+	return id >= adhocTaskIDBase
+}
+
+func (t *cronExecutionTracker) save(e *model.CronExecution) {
+	if err := DB.Save(e).Error; err != nil {
+		log.Printf("NEZHA>> Failed to save cron execution: %v", err)
+		return
+	}
+	t.stats.add(e)
+}
+
+// track 保存执行中的记录并返回下发使用的任务 ID
+func (t *cronExecutionTracker) track(key cronRunKey, e *model.CronExecution) uint64 {
+	t.save(e)
+	id := cronTaskIDBase + e.ID
+	t.mu.Lock()
+	t.tasks[id] = cronTask{key: key, execution: e}
+	t.mu.Unlock()
+	return id
+}
+
+// startRun 记录一次触发及本次选中的服务器
+func (t *cronExecutionTracker) startRun(cr *model.Cron, targets []uint64) *model.CronRun {
+	run := &model.CronRun{
+		CronID:  cr.ID,
+		Targets: targets,
+	}
+	if err := DB.Create(run).Error; err != nil {
+		log.Printf("NEZHA>> Failed to save cron run: %v", err)
+	}
+	return run
+}
+
+func newCronExecution(cr *model.Cron, serverID, runID uint64, parent *model.CronExecution) *model.CronExecution {
+	e := &model.CronExecution{
+		RunID:     runID,
+		ServerID:  serverID,
+		StartedAt: time.Now(),
+	}
+	if !isAdhocTask(cr.ID) {
+		e.CronID = cr.ID
+	}
+	if parent != nil {
+		e.ParentID = parent.ID
+		e.Depth = parent.Depth + 1
+	}
+	return e
+}
+
+// dispatch 按并发策略向服务器下发计划任务，并记录执行状态，parent 为触发本次执行的上一个任务的执行。
+// 排队等待时返回 nil
+func (t *cronExecutionTracker) dispatch(cr *model.Cron, s *model.Server, runID uint64, parent *model.CronExecution) *model.CronExecution {
+	command, err := cr.RenderCommand(s)
+	if err != nil {
+		return t.record(cr, s.ID, runID, model.CronExecutionFailure, err.Error(), parent)
+	}
+	if cr.CommandTemplate {
+		if err := CommandPolicyShared.CheckRendered(cr.UserID, command); err != nil {
+			return t.record(cr, s.ID, runID, model.CronExecutionFailure, err.Error(), parent)
+		}
+	}
+	env, err := cronTaskEnv(cr)
+	if err != nil {
+		return t.record(cr, s.ID, runID, model.CronExecutionFailure, err.Error(), parent)
+	}
+	if err := CheckCapability(s, model.AgentCapabilityExec); err != nil {
+		return t.record(cr, s.ID, runID, model.CronExecutionFailure, err.Error(), parent)
+	}
+	if cr.NeedsTaskOptions() {
+		if err := CheckTaskOptions(s); err != nil {
+			return t.record(cr, s.ID, runID, model.CronExecutionFailure, err.Error(), parent)
+		}
+	}
+
+	key := cronRunKey{cr.ID, s.ID}
+
+	t.mu.Lock()
+	state, ok := t.runs[key]
+	if !ok {
+		state = &cronRunState{}
+		t.runs[key] = state
+	}
+	if len(state.running) > 0 {
+		switch cr.ConcurrencyPolicy {
+		case model.CronConcurrencySkip:
+			t.mu.Unlock()
+			return t.record(cr, s.ID, runID, model.CronExecutionSkipped, Localizer.T("previous execution is still running"), parent)
+		case model.CronConcurrencyQueue:
+			queued := state.queued
+			if !queued {
+				state.queued, state.queuedRun, state.queuedParent = true, runID, parent
+			}
+			t.mu.Unlock()
+			if queued {
+				return t.record(cr, s.ID, runID, model.CronExecutionSkipped, Localizer.T("previous execution is still running"), parent)
+			}
+			return nil
+		}
+	}
+
+	e := newCronExecution(cr, s.ID, runID, parent)
+	e.Status = model.CronExecutionRunning
+	state.running = append(state.running, e)
+	t.mu.Unlock()
+
+	task := &pb.Task{
+		Id:   t.track(key, e),
+		Data: cr.TaskData(command, env),
+		Type: model.TaskTypeCommand,
+	}
+	if cr.PushFileID != 0 {
+		t.pushFile(cr, s, task)
+		return e
+	}
+	s.TaskStream.Send(task)
+	return e
+}
+
+// pushFile 先推送任务引用的文件，推送成功后再下发命令
+func (t *cronExecutionTracker) pushFile(cr *model.Cron, s *model.Server, task *pb.Task) {
+	fail := func(msg string) {
+		t.Finish(s.ID, &pb.TaskResult{
+			Id:   task.Id,
+			Type: model.TaskTypeCommand,
+			Data: Localizer.Tf("push file failed: %s", msg),
+		})
+	}
+	_, err := FilePushShared.Start(0, s, cr.PushFileID, cr.PushFilePath, cr.PushFileMode, func(p *model.FilePush) {
+		if p.Status != model.FilePushSuccess {
+			fail(p.Error)
+			return
+		}
+		if s.TaskStream == nil {
+			fail(Localizer.T("server not found or not connected"))
+			return
+		}
+		s.TaskStream.Send(task)
+	})
+	if err != nil {
+		go fail(err.Error())
+	}
+}
+
+// cronTaskEnv 解密环境变量，仅在下发任务时使用明文
+func cronTaskEnv(cr *model.Cron) (map[string]string, error) {
+	if len(cr.Env) == 0 {
+		return nil, nil
+	}
+	env := make(map[string]string, len(cr.Env))
+	for _, e := range cr.Env {
+		v, err := DecryptSecret(e.Value)
+		if err != nil {
+			return nil, err
+		}
+		env[e.Key] = v
+	}
+	return env, nil
+}
+
+// record 记录未实际下发的执行，如跳过或服务器离线
+func (t *cronExecutionTracker) record(cr *model.Cron, serverID, runID uint64, status, output string, parent *model.CronExecution) *model.CronExecution {
+	e := newCronExecution(cr, serverID, runID, parent)
+	e.Finish(status, output)
+	t.save(e)
+	return e
+}
+
+// Finish 按任务 ID 记录 Agent 上报的执行结果，返回对应的计划任务及执行状态。
+// 找不到执行中的记录时返回的计划任务为 nil，如超时后才上报的结果
+func (t *cronExecutionTracker) Finish(serverID uint64, result *pb.TaskResult) (*model.Cron, string, string) {
+	res := model.ParseCronTaskResult(result.GetData())
+	status := model.CronExecutionFailure
+	switch {
+	case res.TimedOut:
+		status = model.CronExecutionTimeout
+	case result.GetSuccessful():
+		status = model.CronExecutionSuccess
+	}
+
+	t.mu.Lock()
+	var cr *model.Cron
+	var e *model.CronExecution
+	var runQueued bool
+	var queuedRun uint64
+	var queuedParent *model.CronExecution
+	if task, ok := t.tasks[result.GetId()]; ok && task.key.serverID == serverID {
+		delete(t.tasks, result.GetId())
+		key := task.key
+		e = task.execution
+		if cr, ok = t.adhoc[key.cronID]; !ok {
+			cr, _ = CronShared.Get(key.cronID)
+		}
+		if state, ok := t.runs[key]; ok {
+			if i := slices.Index(state.running, e); i >= 0 {
+				state.running = slices.Delete(state.running, i, i+1)
+			}
+			if len(state.running) == 0 {
+				runQueued, queuedRun, queuedParent = state.queued, state.queuedRun, state.queuedParent
+				state.queued, state.queuedRun, state.queuedParent = false, 0, nil
+				if !runQueued {
+					delete(t.runs, key)
+					delete(t.adhoc, key.cronID)
+				}
+			}
+		}
+	}
+	t.mu.Unlock()
+
+	if e == nil {
+		// 面板重启前下发的任务从数据库读取执行记录
+		e, cr = loadRunningCronExecution(serverID, result.GetId())
+	}
+	if e == nil || cr == nil {
+		return nil, status, res.Output
+	}
+	e.Finish(status, res.Output)
+	t.save(e)
+
+	if runQueued {
+		if s, ok := ServerShared.Get(serverID); ok && s.TaskStream != nil {
+			go t.dispatch(cr, s, queuedRun, queuedParent)
+		}
+	}
+	t.chain(cr, e)
+	return cr, status, res.Output
+}
+
+// loadRunningCronExecution 读取仍在执行中的记录，已标记为超时的执行不再更新
+func loadRunningCronExecution(serverID, taskID uint64) (*model.CronExecution, *model.Cron) {
+	if taskID <= cronTaskIDBase {
+		return nil, nil
+	}
+	var e model.CronExecution
+	if err := DB.First(&e, taskID-cronTaskIDBase).Error; err != nil {
+		return nil, nil
+	}
+	if e.ServerID != serverID || e.Status != model.CronExecutionRunning {
+		return nil, nil
+	}
+	cr, ok := CronShared.Get(e.CronID)
+	if !ok {
+		return nil, nil
+	}
+	return &e, cr
+}
+
+// sweep 将超时仍未上报结果的执行标记为超时
+func (t *cronExecutionTracker) sweep() {
+	now := time.Now()
+	var timedOut []*model.CronExecution
+
+	t.mu.Lock()
+	for key, state := range t.runs {
+		cr, ok := t.adhoc[key.cronID]
+		if !ok {
+			cr, ok = CronShared.Get(key.cronID)
+		}
+		if !ok {
+			delete(t.runs, key)
+			continue
+		}
+		limit := cronMaxRunning
+		if cr.TimeoutSeconds > 0 {
+			limit = time.Duration(cr.TimeoutSeconds)*time.Second + cronTimeoutGrace
+		}
+		running := state.running[:0]
+		for _, e := range state.running {
+			if now.Sub(e.StartedAt) > limit {
+				timedOut = append(timedOut, e)
+			} else {
+				running = append(running, e)
+			}
+		}
+		state.running = running
+		if len(state.running) == 0 && !state.queued {
+			delete(t.runs, key)
+			delete(t.adhoc, key.cronID)
+		}
+	}
+	// 超时后才上报的结果不再对应到执行记录
+	for id, task := range t.tasks {
+		if slices.Contains(timedOut, task.execution) {
+			delete(t.tasks, id)
+		}
+	}
+	t.mu.Unlock()
+
+	for _, e := range timedOut {
+		e.Finish(model.CronExecutionTimeout, Localizer.T("no result reported before timeout"))
+		t.save(e)
+		if cr, ok := CronShared.Get(e.CronID); ok {
+			notifyCronTimeout(cr, e.ServerID, e.Output)
+			t.chain(cr, e)
+		}
+	}
+}
+
+// chain 按执行结果在同一服务器上执行后续任务
+func (t *cronExecutionTracker) chain(cr *model.Cron, e *model.CronExecution) {
+	var nextID uint64
+	switch e.Status {
+	case model.CronExecutionSuccess:
+		nextID = cr.OnSuccessCronID
+	case model.CronExecutionFailure, model.CronExecutionTimeout:
+		nextID = cr.OnFailureCronID
+	}
+	if nextID == 0 {
+		return
+	}
+	if e.Depth >= cronMaxChainDepth {
+		log.Printf("NEZHA>> Task chain stopped at task %d: max depth %d reached", cr.ID, cronMaxChainDepth)
+		return
+	}
+	next, ok := CronShared.Get(nextID)
+	if !ok {
+		return
+	}
+	s, ok := ServerShared.Get(e.ServerID)
+	if !ok {
+		return
+	}
+	if s.TaskStream == nil {
+		t.record(next, s.ID, e.RunID, model.CronExecutionOffline, "", e)
+		return
+	}
+	go t.dispatch(next, s, e.RunID, e)
+}
+
+// exec 在服务器上执行一次临时命令，结果与计划任务一样记录在执行历史中
+func (t *cronExecutionTracker) exec(s *model.Server, command string, timeout uint32) *model.CronExecution {
+	t.mu.Lock()
+	t.adhocSeq++
+	cr := &model.Cron{
+		Name:              Localizer.T("Ad-hoc command"),
+		Command:           command,
+		TimeoutSeconds:    timeout,
+		ConcurrencyPolicy: model.CronConcurrencyAllow,
+	}
+	cr.ID = adhocTaskIDBase + t.adhocSeq
+	t.adhoc[cr.ID] = cr
+	t.mu.Unlock()
+
+	e := t.dispatch(cr, s, 0, nil)
+	if e.Status != model.CronExecutionRunning {
+		t.mu.Lock()
+		delete(t.adhoc, cr.ID)
+		t.mu.Unlock()
+	}
+	return e
+}
+
+func (t *cronExecutionTracker) Delete(cronIDs []uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for key := range t.runs {
+		if slices.Contains(cronIDs, key.cronID) {
+			delete(t.runs, key)
+		}
+	}
+	for id, task := range t.tasks {
+		if slices.Contains(cronIDs, task.key.cronID) {
+			delete(t.tasks, id)
+		}
+	}
+	t.stats.delete(cronIDs)
+}
+
+func notifyCronTimeout(cr *model.Cron, serverID uint64, output string) {
+	if !cr.NotifyTimeout {
+		return
+	}
+	s, ok := ServerShared.Get(serverID)
+	if !ok {
+		return
+	}
+	var curServer model.Server
+	copier.Copy(&curServer, s)
+	go NotificationShared.SendNotification(cr.NotificationGroupID, NotificationShared.Lang(cr.NotificationGroupID).Tf("[Task timed out] %s, %s\n%s", cr.Name, s.Name, output), "", &curServer)
+}
+
+// FinishCronExecution 处理 Agent 上报的计划任务结果，返回计划任务、执行状态及命令输出，
+// 临时命令或找不到对应执行时返回的计划任务为 nil
+func FinishCronExecution(serverID uint64, result *pb.TaskResult) (*model.Cron, string, string) {
+	cr, status, output := CronShared.executions.Finish(serverID, result)
+	if cr == nil || isAdhocTask(cr.ID) {
+		return nil, status, output
+	}
+	if status == model.CronExecutionTimeout {
+		notifyCronTimeout(cr, serverID, output)
+	}
+	return cr, status, output
+}
+
+// CleanCronHistory 按保留天数及每个计划任务的最大条数清理执行记录
+func CleanCronHistory() {
+	before := time.Now().AddDate(0, 0, -Conf.CronHistoryRetentionDays)
+	DB.Unscoped().Delete(&model.CronExecution{}, "started_at < ?", before)
+	DB.Unscoped().Delete(&model.CronRun{}, "created_at < ?", before)
+
+	// 临时命令的执行记录 cron_id 为 0
+	cronIDs := []uint64{0}
+	for _, cr := range CronShared.GetSortedList() {
+		cronIDs = append(cronIDs, cr.ID)
+	}
+	for _, id := range cronIDs {
+		var boundary []uint64
+		if err := DB.Model(&model.CronExecution{}).Where("cron_id = ?", id).Order("id DESC").
+			Offset(Conf.CronHistoryMaxRows).Limit(1).Pluck("id", &boundary).Error; err != nil || len(boundary) == 0 {
+			continue
+		}
+		DB.Unscoped().Delete(&model.CronExecution{}, "cron_id = ? AND id <= ?", id, boundary[0])
+	}
+
+	CronShared.executions.stats.clean()
+}
+
+// GetCronStats 获取计划任务近 30 天整体及各服务器的执行统计
+func GetCronStats(cronID uint64) (*model.CronStats, map[uint64]*model.CronStats) {
+	return CronShared.executions.stats.Get(cronID)
+}
+
+// ExecCommand 在服务器上执行一次临时命令，不保存计划任务
+func ExecCommand(s *model.Server, command string, timeout uint32) *model.CronExecution {
+	return CronShared.executions.exec(s, command, timeout)
+}
+
+// GetCronExecution 获取单次执行记录
+func GetCronExecution(id uint64) (*model.CronExecution, error) {
+	var e model.CronExecution
+	if err := DB.First(&e, id).Error; err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// ListCronRuns 获取计划任务最近的触发记录
+func ListCronRuns(cronID uint64, limit int) ([]model.CronRun, error) {
+	var runs []model.CronRun
+	if err := DB.Where("cron_id = ?", cronID).Order("id DESC").Limit(limit).Find(&runs).Error; err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+// ListCronExecutions 获取计划任务最近的执行记录
+func ListCronExecutions(cronID uint64, limit int) ([]model.CronExecution, error) {
+	var executions []model.CronExecution
+	if err := DB.Where("cron_id = ?", cronID).Order("started_at DESC").Limit(limit).Find(&executions).Error; err != nil {
+		return nil, err
+	}
+	return executions, nil
+}
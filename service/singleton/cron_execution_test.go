@@ -0,0 +1,127 @@
+package singleton
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/i18n"
+	pb "github.com/nezhahq/nezha/proto"
+)
+
+func setupCronExecutionTest(t *testing.T, cr *model.Cron) *cronExecutionTracker {
+	setupTestDB(t, model.CronExecution{})
+	oldShared, oldLoc, oldLocalizer := CronShared, Loc, Localizer
+	t.Cleanup(func() { CronShared, Loc, Localizer = oldShared, oldLoc, oldLocalizer })
+	Loc = time.UTC
+	Localizer = i18n.NewLocalizer("en_US", domain, "translations", i18n.Translations)
+
+	tracker := newCronExecutionTracker()
+	CronShared = &CronClass{
+		class: class[uint64, *model.Cron]{
+			list:       map[uint64]*model.Cron{cr.ID: cr},
+			sortedList: []*model.Cron{cr},
+		},
+		executions: tracker,
+	}
+	return tracker
+}
+
+// startTestExecution 与 dispatch 一样记录执行中的任务，返回下发使用的任务 ID
+func startTestExecution(tracker *cronExecutionTracker, cr *model.Cron, serverID uint64, startedAt time.Time) (*model.CronExecution, uint64) {
+	key := cronRunKey{cr.ID, serverID}
+	e := newCronExecution(cr, serverID, 0, nil)
+	e.Status, e.StartedAt = model.CronExecutionRunning, startedAt
+
+	tracker.mu.Lock()
+	state, ok := tracker.runs[key]
+	if !ok {
+		state = &cronRunState{}
+		tracker.runs[key] = state
+	}
+	state.running = append(state.running, e)
+	tracker.mu.Unlock()
+	return e, tracker.track(key, e)
+}
+
+func TestCronExecutionFinishByTaskID(t *testing.T) {
+	cr := &model.Cron{Common: model.Common{ID: 1}, TimeoutSeconds: 60}
+	tracker := setupCronExecutionTest(t, cr)
+
+	first, firstID := startTestExecution(tracker, cr, 1, time.Now())
+	second, secondID := startTestExecution(tracker, cr, 1, time.Now())
+
+	// 结果按任务 ID 对应执行，与下发顺序无关
+	if got, status, _ := tracker.Finish(1, &pb.TaskResult{Id: secondID, Successful: true}); got != cr || status != model.CronExecutionSuccess {
+		t.Fatalf("expected result to finish the second execution, got %v %s", got, status)
+	}
+	if second.Status != model.CronExecutionSuccess || first.Status != model.CronExecutionRunning {
+		t.Fatalf("unexpected statuses %s and %s", first.Status, second.Status)
+	}
+
+	// 其他服务器上报的结果不对应
+	if got, _, _ := tracker.Finish(2, &pb.TaskResult{Id: firstID, Successful: true}); got != nil {
+		t.Fatal("expected result from another server to be ignored")
+	}
+	if got, _, _ := tracker.Finish(1, &pb.TaskResult{Id: firstID}); got != cr || first.Status != model.CronExecutionFailure {
+		t.Fatalf("expected result to finish the first execution, got %s", first.Status)
+	}
+	if got, _, _ := tracker.Finish(1, &pb.TaskResult{Id: firstID, Successful: true}); got != nil {
+		t.Fatal("expected duplicate result to be ignored")
+	}
+	if len(tracker.runs) != 0 || len(tracker.tasks) != 0 {
+		t.Fatalf("expected no tracked executions, got %d runs and %d tasks", len(tracker.runs), len(tracker.tasks))
+	}
+}
+
+func TestCronExecutionLateResult(t *testing.T) {
+	cr := &model.Cron{Common: model.Common{ID: 1}, TimeoutSeconds: 1}
+	tracker := setupCronExecutionTest(t, cr)
+
+	stale, staleID := startTestExecution(tracker, cr, 1, time.Now().Add(-time.Hour))
+	next, nextID := startTestExecution(tracker, cr, 1, time.Now())
+	tracker.sweep()
+	if stale.Status != model.CronExecutionTimeout || next.Status != model.CronExecutionRunning {
+		t.Fatalf("unexpected statuses %s and %s after sweep", stale.Status, next.Status)
+	}
+
+	// 超时后才上报的结果不会记到下一次执行上
+	if got, _, _ := tracker.Finish(1, &pb.TaskResult{Id: staleID, Successful: true}); got != nil {
+		t.Fatal("expected late result to be ignored")
+	}
+	if next.Status != model.CronExecutionRunning {
+		t.Fatalf("expected next execution to keep running, got %s", next.Status)
+	}
+	var saved model.CronExecution
+	if err := DB.First(&saved, stale.ID).Error; err != nil || saved.Status != model.CronExecutionTimeout {
+		t.Fatalf("expected stale execution to stay timed out, got %s (%v)", saved.Status, err)
+	}
+
+	if got, _, _ := tracker.Finish(1, &pb.TaskResult{Id: nextID, Successful: true}); got != cr || next.Status != model.CronExecutionSuccess {
+		t.Fatalf("expected result to finish the next execution, got %s", next.Status)
+	}
+}
+
+func TestCronExecutionFinishAfterRestart(t *testing.T) {
+	cr := &model.Cron{Common: model.Common{ID: 1}}
+	tracker := setupCronExecutionTest(t, cr)
+
+	e, taskID := startTestExecution(tracker, cr, 1, time.Now())
+	// 重启后内存中没有执行记录
+	CronShared.executions = newCronExecutionTracker()
+	tracker = CronShared.executions
+
+	if got, _, _ := tracker.Finish(2, &pb.TaskResult{Id: taskID, Successful: true}); got != nil {
+		t.Fatal("expected result from another server to be ignored")
+	}
+	if got, status, _ := tracker.Finish(1, &pb.TaskResult{Id: taskID, Successful: true}); got != cr || status != model.CronExecutionSuccess {
+		t.Fatalf("expected result to finish the saved execution, got %v %s", got, status)
+	}
+	var saved model.CronExecution
+	if err := DB.First(&saved, e.ID).Error; err != nil || saved.Status != model.CronExecutionSuccess {
+		t.Fatalf("expected saved execution to be finished, got %s (%v)", saved.Status, err)
+	}
+	if got, _, _ := tracker.Finish(1, &pb.TaskResult{Id: taskID, Successful: true}); got != nil {
+		t.Fatal("expected finished execution to not be updated again")
+	}
+}
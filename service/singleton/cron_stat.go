@@ -0,0 +1,162 @@
+package singleton
+
+import (
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+// 执行统计的时间范围
+const cronStatDays = 30
+
+type cronStatKey struct {
+	cronID   uint64
+	serverID uint64
+	date     time.Time
+}
+
+// cronStatStore 在内存中维护近期的每日执行统计，查询时无需聚合执行记录
+type cronStatStore struct {
+	mu    sync.RWMutex
+	daily map[cronStatKey]*model.CronStatDaily
+}
+
+func newCronStatStore() *cronStatStore {
+	st := &cronStatStore{
+		daily: make(map[cronStatKey]*model.CronStatDaily),
+	}
+	var rows []*model.CronStatDaily
+	if err := DB.Where("date >= ?", cronStatStart()).Find(&rows).Error; err != nil {
+		log.Printf("NEZHA>> Failed to load cron stats: %v", err)
+	}
+	for _, row := range rows {
+		st.daily[cronStatKey{row.CronID, row.ServerID, row.Date.In(Loc)}] = row
+	}
+	return st
+}
+
+func cronStatStart() time.Time {
+	return transferDay(time.Now()).AddDate(0, 0, -cronStatDays+1)
+}
+
+// add 累加一次已结束的执行，跳过的执行与临时命令不计入统计
+func (st *cronStatStore) add(e *model.CronExecution) {
+	if e.CronID == 0 || e.FinishedAt == nil || e.Status == model.CronExecutionSkipped {
+		return
+	}
+
+	key := cronStatKey{e.CronID, e.ServerID, transferDay(e.StartedAt)}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	row, ok := st.daily[key]
+	if !ok {
+		row = &model.CronStatDaily{
+			CronID:   e.CronID,
+			ServerID: e.ServerID,
+			Date:     key.date,
+		}
+		st.daily[key] = row
+	}
+	row.Total++
+	switch e.Status {
+	case model.CronExecutionSuccess:
+		row.Success++
+	default:
+		finishedAt := *e.FinishedAt
+		row.LastFailureAt = &finishedAt
+	}
+	if e.Status != model.CronExecutionOffline {
+		row.Duration += uint64(e.FinishedAt.Sub(e.StartedAt).Milliseconds())
+		row.DurationCount++
+	}
+	if err := DB.Save(row).Error; err != nil {
+		log.Printf("NEZHA>> Failed to save cron stats: %v", err)
+	}
+}
+
+// Get 获取计划任务整体及各服务器近期的执行统计
+func (st *cronStatStore) Get(cronID uint64) (*model.CronStats, map[uint64]*model.CronStats) {
+	start := cronStatStart()
+	total := new(cronStatSum)
+	servers := make(map[uint64]*cronStatSum)
+
+	st.mu.RLock()
+	for key, row := range st.daily {
+		if key.cronID != cronID || key.date.Before(start) {
+			continue
+		}
+		total.add(row)
+		sum, ok := servers[key.serverID]
+		if !ok {
+			sum = new(cronStatSum)
+			servers[key.serverID] = sum
+		}
+		sum.add(row)
+	}
+	st.mu.RUnlock()
+
+	if total.Total == 0 {
+		return nil, nil
+	}
+	serverStats := make(map[uint64]*model.CronStats, len(servers))
+	for id, sum := range servers {
+		serverStats[id] = sum.stats()
+	}
+	return total.stats(), serverStats
+}
+
+// clean 清理统计范围以外的数据
+func (st *cronStatStore) clean() {
+	start := cronStatStart()
+	st.mu.Lock()
+	for key := range st.daily {
+		if key.date.Before(start) {
+			delete(st.daily, key)
+		}
+	}
+	st.mu.Unlock()
+	DB.Unscoped().Delete(&model.CronStatDaily{}, "date < ? OR cron_id NOT IN (SELECT `id` FROM crons)", start)
+}
+
+func (st *cronStatStore) delete(cronIDs []uint64) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	for key := range st.daily {
+		if slices.Contains(cronIDs, key.cronID) {
+			delete(st.daily, key)
+		}
+	}
+}
+
+type cronStatSum struct {
+	model.CronStatDaily
+}
+
+func (s *cronStatSum) add(row *model.CronStatDaily) {
+	s.Total += row.Total
+	s.Success += row.Success
+	s.Duration += row.Duration
+	s.DurationCount += row.DurationCount
+	if row.LastFailureAt != nil && (s.LastFailureAt == nil || row.LastFailureAt.After(*s.LastFailureAt)) {
+		s.LastFailureAt = row.LastFailureAt
+	}
+}
+
+func (s *cronStatSum) stats() *model.CronStats {
+	stats := &model.CronStats{
+		Total:         s.Total,
+		LastFailureAt: s.LastFailureAt,
+	}
+	if s.Total > 0 {
+		stats.SuccessRate = float64(s.Success) / float64(s.Total) * 100
+	}
+	if s.DurationCount > 0 {
+		stats.AvgDuration = s.Duration / s.DurationCount
+	}
+	return stats
+}
@@ -3,6 +3,8 @@ package singleton
 import (
 	"cmp"
 	"fmt"
+	"log"
+	"maps"
 	"slices"
 	"strings"
 
@@ -12,12 +14,13 @@ import (
 
 	"github.com/nezhahq/nezha/model"
 	"github.com/nezhahq/nezha/pkg/utils"
-	pb "github.com/nezhahq/nezha/proto"
 )
 
 type CronClass struct {
 	class[uint64, *model.Cron]
 	*cron.Cron
+
+	executions *cronExecutionTracker
 }
 
 func NewCronClass() *CronClass {
@@ -38,7 +41,7 @@ func NewCronClass() *CronClass {
 			continue
 		}
 		// 注册计划任务
-		cron.CronJobID, err = cronx.AddFunc(cron.Scheduler, CronTrigger(cron))
+		cron.CronJobID, err = cronx.AddFunc(cron.Spec(), CronTrigger(cron))
 		if err == nil {
 			list[cron.ID] = cron
 		} else {
@@ -46,7 +49,7 @@ func NewCronClass() *CronClass {
 			if _, ok := notificationMsgMap[cron.NotificationGroupID]; !ok {
 				notificationGroupList = append(notificationGroupList, cron.NotificationGroupID)
 				notificationMsgMap[cron.NotificationGroupID] = new(strings.Builder)
-				notificationMsgMap[cron.NotificationGroupID].WriteString(Localizer.T("Tasks failed to register: ["))
+				notificationMsgMap[cron.NotificationGroupID].WriteString(NotificationShared.Lang(cron.NotificationGroupID).T("Tasks failed to register: ["))
 			}
 			notificationMsgMap[cron.NotificationGroupID].WriteString(fmt.Sprintf("%d,", cron.ID))
 		}
@@ -54,18 +57,44 @@ func NewCronClass() *CronClass {
 
 	// 向注册错误的计划任务所在通知组发送通知
 	for _, gid := range notificationGroupList {
-		notificationMsgMap[gid].WriteString(Localizer.T("] These tasks will not execute properly. Fix them in the admin dashboard."))
+		notificationMsgMap[gid].WriteString(NotificationShared.Lang(gid).T("] These tasks will not execute properly. Fix them in the admin dashboard."))
 		NotificationShared.SendNotification(gid, notificationMsgMap[gid].String(), "")
 	}
-	cronx.Start()
-
-	return &CronClass{
+	c := &CronClass{
 		class: class[uint64, *model.Cron]{
 			list:       list,
 			sortedList: sortedList,
+			userList:   groupByUser(sortedList),
 		},
-		Cron: cronx,
+		Cron:       cronx,
+		executions: newCronExecutionTracker(),
+	}
+	// 检查超时未上报结果的执行
+	cronx.AddFunc("*/30 * * * * *", c.executions.sweep)
+	cronx.Start()
+
+	return c
+}
+
+// reload 重新注册数据库中的计划任务
+func (c *CronClass) reload() error {
+	var crons []*model.Cron
+	if err := DB.Find(&crons).Error; err != nil {
+		return err
 	}
+
+	c.Delete(slices.Collect(maps.Keys(c.GetList())))
+	for _, cr := range crons {
+		if cr.TaskType != model.CronTypeTriggerTask {
+			var err error
+			if cr.CronJobID, err = c.AddFunc(cr.Spec(), CronTrigger(cr)); err != nil {
+				log.Printf("NEZHA>> Failed to register cron %d: %v", cr.ID, err)
+				continue
+			}
+		}
+		c.Update(cr)
+	}
+	return nil
 }
 
 func (c *CronClass) Update(cr *model.Cron) {
@@ -92,10 +121,55 @@ func (c *CronClass) Delete(idList []uint64) {
 		delete(c.list, id)
 	}
 	c.listMu.Unlock()
+	c.executions.Delete(idList)
 
 	c.sortList()
 }
 
+// FillRunTimes 填充计划任务在调度器中的下次及上次执行时间
+func (c *CronClass) FillRunTimes(cr *model.Cron) {
+	if cr.CronJobID == 0 {
+		return
+	}
+	entry := c.Cron.Entry(cr.CronJobID)
+	if !entry.Next.IsZero() {
+		cr.NextRunAt = &entry.Next
+	}
+	if !entry.Prev.IsZero() {
+		cr.LastRunAt = &entry.Prev
+	}
+}
+
+// CheckChainCycle 检查任务链中是否存在循环
+func (c *CronClass) CheckChainCycle(cr *model.Cron) error {
+	visited := make(map[uint64]bool)
+	var visit func(ids ...uint64) error
+	visit = func(ids ...uint64) error {
+		for _, id := range ids {
+			if id == 0 {
+				continue
+			}
+			if id == cr.ID {
+				return Localizer.ErrorT("task chain cannot contain a cycle")
+			}
+			if visited[id] {
+				continue
+			}
+			visited[id] = true
+
+			next, ok := c.Get(id)
+			if !ok {
+				continue
+			}
+			if err := visit(next.OnSuccessCronID, next.OnFailureCronID); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return visit(cr.OnSuccessCronID, cr.OnFailureCronID)
+}
+
 func (c *CronClass) sortList() {
 	c.listMu.RLock()
 	defer c.listMu.RUnlock()
@@ -107,7 +181,7 @@ func (c *CronClass) sortList() {
 
 	c.sortedListMu.Lock()
 	defer c.sortedListMu.Unlock()
-	c.sortedList = sortedList
+	c.setSortedList(sortedList)
 }
 
 func (c *CronClass) SendTriggerTasks(taskIDs []uint64, triggerServer uint64) {
@@ -131,51 +205,85 @@ func ManualTrigger(cr *model.Cron) {
 }
 
 func CronTrigger(cr *model.Cron, triggerServer ...uint64) func() {
+	return func() {
+		// 多节点部署时各节点只在自己负责的服务器上执行
+		targets := slices.DeleteFunc(CronTargets(cr, triggerServer...), func(id uint64) bool {
+			return !ClusterShared.OwnsServer(id)
+		})
+		if len(targets) == 0 {
+			return
+		}
+		runCron(cr, targets)
+	}
+}
+
+// RunCron 立即执行计划任务，targets 为空时在任务配置的服务器上执行
+func RunCron(cr *model.Cron, targets []uint64) (*model.CronRun, error) {
+	if len(targets) == 0 {
+		targets = CronTargets(cr)
+	}
+	if len(targets) == 0 {
+		return nil, Localizer.ErrorT("no server matches the task")
+	}
+	return runCron(cr, targets), nil
+}
+
+// CronTargets 获取计划任务本次执行的服务器，只包含任务所有者有权访问的服务器，告警触发的任务没有触发服务器时返回 nil
+func CronTargets(cr *model.Cron, triggerServer ...uint64) []uint64 {
+	switch cr.Cover {
+	case model.CronCoverAlertTrigger:
+		if len(triggerServer) == 0 {
+			return nil
+		}
+		if s, ok := ServerShared.Get(triggerServer[0]); !ok || (s.UserID != cr.UserID && !isAdminUser(cr.UserID)) {
+			return nil
+		}
+		return triggerServer[:1]
+	case model.CronCoverSelector:
+		return ResolveServerSelector(cr.Selector, cr.UserID)
+	}
+
 	crIgnoreMap := make(map[uint64]bool)
 	for _, server := range cr.Servers {
 		crIgnoreMap[server] = true
 	}
-	return func() {
-		if cr.Cover == model.CronCoverAlertTrigger {
-			if len(triggerServer) == 0 {
-				return
-			}
-			if s, ok := ServerShared.Get(triggerServer[0]); ok {
-				if s.TaskStream != nil {
-					s.TaskStream.Send(&pb.Task{
-						Id:   cr.ID,
-						Data: cr.Command,
-						Type: model.TaskTypeCommand,
-					})
-				} else {
-					// 保存当前服务器状态信息
-					curServer := model.Server{}
-					copier.Copy(&curServer, s)
-					go NotificationShared.SendNotification(cr.NotificationGroupID, Localizer.Tf("[Task failed] %s: server %s is offline and cannot execute the task", cr.Name, s.Name), "", &curServer)
-				}
-			}
-			return
+	admin := isAdminUser(cr.UserID)
+	targets := make([]uint64, 0)
+	for _, s := range ServerShared.Range {
+		if cr.Cover == model.CronCoverAll && crIgnoreMap[s.ID] {
+			continue
+		}
+		if cr.Cover == model.CronCoverIgnoreAll && !crIgnoreMap[s.ID] {
+			continue
 		}
+		if !admin && s.UserID != cr.UserID {
+			continue
+		}
+		targets = append(targets, s.ID)
+	}
+	slices.Sort(targets)
+	return targets
+}
 
-		for _, s := range ServerShared.Range {
-			if cr.Cover == model.CronCoverAll && crIgnoreMap[s.ID] {
-				continue
-			}
-			if cr.Cover == model.CronCoverIgnoreAll && !crIgnoreMap[s.ID] {
-				continue
-			}
-			if s.TaskStream != nil {
-				s.TaskStream.Send(&pb.Task{
-					Id:   cr.ID,
-					Data: cr.Command,
-					Type: model.TaskTypeCommand,
-				})
-			} else {
-				// 保存当前服务器状态信息
-				curServer := model.Server{}
-				copier.Copy(&curServer, s)
-				go NotificationShared.SendNotification(cr.NotificationGroupID, Localizer.Tf("[Task failed] %s: server %s is offline and cannot execute the task", cr.Name, s.Name), "", &curServer)
-			}
+func runCron(cr *model.Cron, targets []uint64) *model.CronRun {
+	run := CronShared.executions.startRun(cr, targets)
+	for _, id := range targets {
+		s, ok := ServerShared.Get(id)
+		if !ok {
+			continue
+		}
+		switch {
+		case s.TaskStream != nil:
+			CronShared.executions.dispatch(cr, s, run.ID, nil)
+		case !ClusterShared.OwnsServer(s.ID):
+			CronShared.executions.record(cr, s.ID, run.ID, model.CronExecutionFailure, "the agent is connected to another dashboard node", nil)
+		default:
+			CronShared.executions.record(cr, s.ID, run.ID, model.CronExecutionOffline, "", nil)
+			// 保存当前服务器状态信息
+			curServer := model.Server{}
+			copier.Copy(&curServer, s)
+			go NotificationShared.SendNotification(cr.NotificationGroupID, NotificationShared.Lang(cr.NotificationGroupID).Tf("[Task failed] %s: server %s is offline and cannot execute the task", cr.Name, s.Name), "", &curServer)
 		}
 	}
+	return run
 }
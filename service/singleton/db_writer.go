@@ -0,0 +1,145 @@
+package singleton
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// sqliteBusyTimeout 毫秒，串行写入后只有备份等直接使用连接的操作可能需要等待
+const sqliteBusyTimeout = 5000
+
+var dbWriterShared *dbWriter
+
+// dbWriter 在单个协程中依次执行写操作，读操作仍可并发
+// SQLite 同一时间只允许一个写事务，写操作串行后不会再出现 database is locked
+type dbWriter struct {
+	queue chan func()
+}
+
+func newDBWriter() *dbWriter {
+	w := &dbWriter{queue: make(chan func(), 256)}
+	go w.run()
+	return w
+}
+
+func (w *dbWriter) run() {
+	for f := range w.queue {
+		f()
+	}
+}
+
+// do 在写入协程中执行 f 并等待完成
+func (w *dbWriter) do(f func()) {
+	done := make(chan struct{})
+	w.queue <- func() {
+		defer close(done)
+		f()
+	}
+	<-done
+}
+
+// hold 占用写入协程，直到调用返回的 release，用于事务
+func (w *dbWriter) hold() (release func()) {
+	acquired := make(chan struct{})
+	released := make(chan struct{})
+	w.queue <- func() {
+		close(acquired)
+		<-released
+	}
+	<-acquired
+
+	var once sync.Once
+	return func() { once.Do(func() { close(released) }) }
+}
+
+// serialConnPool 将事务与 Exec 交给 dbWriter 执行，查询直接使用连接池
+// gorm 的 Create、Update、Delete 默认在事务中执行，因此同样经过 dbWriter
+type serialConnPool struct {
+	*sql.DB
+	writer *dbWriter
+}
+
+func (p *serialConnPool) ExecContext(ctx context.Context, query string, args ...any) (res sql.Result, err error) {
+	p.writer.do(func() { res, err = p.DB.ExecContext(ctx, query, args...) })
+	return
+}
+
+func (p *serialConnPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (gorm.ConnPool, error) {
+	release := p.writer.hold()
+	tx, err := p.DB.BeginTx(ctx, opts)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	return &serialTx{Tx: tx, db: p.DB, release: release}, nil
+}
+
+func (p *serialConnPool) GetDBConn() (*sql.DB, error) {
+	return p.DB, nil
+}
+
+type serialTx struct {
+	*sql.Tx
+	db      *sql.DB
+	release func()
+}
+
+func (tx *serialTx) Commit() error {
+	defer tx.release()
+	return tx.Tx.Commit()
+}
+
+func (tx *serialTx) Rollback() error {
+	defer tx.release()
+	return tx.Tx.Rollback()
+}
+
+func (tx *serialTx) GetDBConn() (*sql.DB, error) {
+	return tx.db, nil
+}
+
+// sqliteDSN 为每个连接启用 WAL、NORMAL 同步级别与忙等待
+func sqliteDSN(path string) string {
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	return path + sep + "_journal_mode=WAL&_synchronous=NORMAL&_txlock=immediate&_busy_timeout=" + strconv.Itoa(sqliteBusyTimeout)
+}
+
+// openDB 打开数据库，SQLite 的写操作经由单个写入协程串行执行
+func openDB(path string) (*gorm.DB, error) {
+	db, err := gorm.Open(sqlite.Open(sqliteDSN(path)), &gorm.Config{
+		CreateBatchSize: 200,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if db.Dialector.Name() != "sqlite" {
+		return db, nil
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	dbWriterShared = newDBWriter()
+	pool := &serialConnPool{DB: sqlDB, writer: dbWriterShared}
+	db.ConnPool = pool
+	db.Statement.ConnPool = pool
+	return db, nil
+}
+
+// holdDBWriter 暂停其他写操作，用于直接使用连接的维护操作
+func holdDBWriter() (release func()) {
+	if dbWriterShared == nil {
+		return func() {}
+	}
+	return dbWriterShared.hold()
+}
@@ -0,0 +1,115 @@
+package singleton
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"gorm.io/gorm"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+func isLockError(err error) bool {
+	return err != nil && (strings.Contains(err.Error(), "database is locked") || strings.Contains(err.Error(), "busy"))
+}
+
+// 并发写入监控记录与 API 修改时不应出现锁错误
+func TestConcurrentWritesNoLockErrors(t *testing.T) {
+	setupTestDB(t, model.ServiceHistory{}, model.Server{})
+
+	server := model.Server{Name: "test"}
+	if err := DB.Create(&server).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	const (
+		reporters        = 8
+		reportsPerWorker = 200
+		apiWorkers       = 4
+		updatesPerWorker = 100
+		readers          = 4
+		readsPerReader   = 100
+	)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, reporters*reportsPerWorker+apiWorkers*updatesPerWorker+readers*readsPerReader)
+
+	// 模拟 Agent 上报监控结果
+	for i := range reporters {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range reportsPerWorker {
+				errs <- DB.Create(newTestHistory(i*reportsPerWorker + j)).Error
+			}
+		}()
+	}
+
+	// 模拟 API 在事务中修改数据
+	for range apiWorkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range updatesPerWorker {
+				errs <- DB.Transaction(func(tx *gorm.DB) error {
+					if err := tx.Model(&model.Server{}).Where("id = ?", server.ID).Update("note", strconv.Itoa(j)).Error; err != nil {
+						return err
+					}
+					return tx.Exec("UPDATE servers SET display_index = display_index + 1 WHERE id = ?", server.ID).Error
+				})
+			}
+		}()
+	}
+
+	// 读取不经过写入协程
+	for range readers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range readsPerReader {
+				var count int64
+				errs <- DB.Model(&model.ServiceHistory{}).Count(&count).Error
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	var lockErrors int
+	for err := range errs {
+		if isLockError(err) {
+			lockErrors++
+		} else if err != nil {
+			t.Error(err)
+		}
+	}
+	if lockErrors > 0 {
+		t.Fatalf("got %d lock error(s)", lockErrors)
+	}
+
+	var count int64
+	DB.Model(&model.ServiceHistory{}).Count(&count)
+	if count != reporters*reportsPerWorker {
+		t.Fatalf("expected %d service history rows, got %d", reporters*reportsPerWorker, count)
+	}
+	var s model.Server
+	DB.First(&s, server.ID)
+	if s.DisplayIndex != apiWorkers*updatesPerWorker {
+		t.Fatalf("expected display_index %d, got %d", apiWorkers*updatesPerWorker, s.DisplayIndex)
+	}
+}
+
+func TestSQLiteWAL(t *testing.T) {
+	setupTestDB(t)
+
+	var mode string
+	if err := DB.Raw("PRAGMA journal_mode").Scan(&mode).Error; err != nil {
+		t.Fatal(err)
+	}
+	if mode != "wal" {
+		t.Fatalf("expected wal journal mode, got %s", mode)
+	}
+}
@@ -3,21 +3,41 @@ package singleton
 import (
 	"cmp"
 	"fmt"
+	"log"
 	"slices"
+	"sync"
+	"time"
 
-	"github.com/libdns/cloudflare"
 	"github.com/libdns/he"
 	tencentcloud "github.com/nezhahq/libdns-tencentcloud"
 
 	"github.com/nezhahq/nezha/model"
 	ddns2 "github.com/nezhahq/nezha/pkg/ddns"
+	"github.com/nezhahq/nezha/pkg/ddns/cloudflare"
+	"github.com/nezhahq/nezha/pkg/ddns/duckdns"
 	"github.com/nezhahq/nezha/pkg/ddns/dummy"
+	"github.com/nezhahq/nezha/pkg/ddns/hetzner"
+	"github.com/nezhahq/nezha/pkg/ddns/porkbun"
 	"github.com/nezhahq/nezha/pkg/ddns/webhook"
 	"github.com/nezhahq/nezha/pkg/utils"
 )
 
+const (
+	// DDNS 更新记录保留天数
+	ddnsHistoryDays = 30
+	// 同一服务器的同一配置两次验证的最小间隔
+	ddnsVerifyInterval = 10 * time.Minute
+)
+
 type DDNSClass struct {
 	class[uint64, *model.DDNSProfile]
+
+	verifyMu   sync.Mutex
+	lastVerify map[[2]uint64]time.Time
+
+	// 每台服务器的每个配置最近一次更新时使用的地址，任一来源的地址变化时才重新更新
+	addrsMu   sync.Mutex
+	lastAddrs map[[2]uint64]model.IP
 }
 
 func NewDDNSClass() *DDNSClass {
@@ -33,17 +53,30 @@ func NewDDNSClass() *DDNSClass {
 		class: class[uint64, *model.DDNSProfile]{
 			list:       list,
 			sortedList: sortedList,
+			userList:   groupByUser(sortedList),
 		},
+		lastVerify: make(map[[2]uint64]time.Time),
+		lastAddrs:  make(map[[2]uint64]model.IP),
 	}
 	return dc
 }
 
+func (c *DDNSClass) reload() {
+	dc := NewDDNSClass()
+
+	c.listMu.Lock()
+	c.list = dc.list
+	c.listMu.Unlock()
+	c.sortList()
+}
+
 func (c *DDNSClass) Update(p *model.DDNSProfile) {
 	c.listMu.Lock()
 	c.list[p.ID] = p
 	c.listMu.Unlock()
 
 	c.sortList()
+	ClusterShared.PublishChange("ddns")
 }
 
 func (c *DDNSClass) Delete(idList []uint64) {
@@ -54,6 +87,7 @@ func (c *DDNSClass) Delete(idList []uint64) {
 	c.listMu.Unlock()
 
 	c.sortList()
+	ClusterShared.PublishChange("ddns")
 }
 
 func (c *DDNSClass) GetDDNSProvidersFromProfiles(profileId []uint64, ip *model.IP) ([]*ddns2.Provider, error) {
@@ -89,6 +123,15 @@ func (c *DDNSClass) GetDDNSProvidersFromProfiles(profileId []uint64, ip *model.I
 		case model.ProviderHE:
 			provider.Setter = &he.Provider{APIKey: profile.AccessSecret}
 			providers = append(providers, provider)
+		case model.ProviderDuckDNS:
+			provider.Setter = &duckdns.Provider{Token: profile.AccessSecret}
+			providers = append(providers, provider)
+		case model.ProviderPorkbun:
+			provider.Setter = &porkbun.Provider{APIKey: profile.AccessID, SecretAPIKey: profile.AccessSecret}
+			providers = append(providers, provider)
+		case model.ProviderHetzner:
+			provider.Setter = &hetzner.Provider{APIToken: profile.AccessSecret}
+			providers = append(providers, provider)
 		default:
 			return nil, fmt.Errorf("cannot find DDNS provider %s", profile.Provider)
 		}
@@ -96,6 +139,66 @@ func (c *DDNSClass) GetDDNSProvidersFromProfiles(profileId []uint64, ip *model.I
 	return providers, nil
 }
 
+// allowVerify 限制验证频率，避免 Agent 频繁上报时反复查询
+func (c *DDNSClass) allowVerify(serverID, profileID uint64) bool {
+	c.verifyMu.Lock()
+	defer c.verifyMu.Unlock()
+
+	key := [2]uint64{serverID, profileID}
+	if time.Since(c.lastVerify[key]) < ddnsVerifyInterval {
+		return false
+	}
+	c.lastVerify[key] = time.Now()
+	return true
+}
+
+// addrsChanged 判断服务器的该配置解析出的地址与最近一次更新时是否不同
+func (c *DDNSClass) addrsChanged(serverID, profileID uint64, ip *model.IP) bool {
+	c.addrsMu.Lock()
+	defer c.addrsMu.Unlock()
+
+	last, ok := c.lastAddrs[[2]uint64{serverID, profileID}]
+	return !ok || last != *ip
+}
+
+func (c *DDNSClass) setAddrs(serverID, profileID uint64, ip *model.IP) {
+	c.addrsMu.Lock()
+	defer c.addrsMu.Unlock()
+
+	c.lastAddrs[[2]uint64{serverID, profileID}] = *ip
+}
+
+// forgetServers 删除服务器后清理其地址记录
+func (c *DDNSClass) forgetServers(idList ...uint64) {
+	c.addrsMu.Lock()
+	defer c.addrsMu.Unlock()
+
+	for key := range c.lastAddrs {
+		if slices.Contains(idList, key[0]) {
+			delete(c.lastAddrs, key)
+		}
+	}
+}
+
+func saveDDNSHistory(h *model.DDNSHistory) {
+	if err := DB.Create(h).Error; err != nil {
+		log.Printf("NEZHA>> Failed to save DDNS history: %v", err)
+	}
+}
+
+// ListDDNSHistory 获取配置最近的更新记录
+func ListDDNSHistory(profileID uint64, limit int) ([]model.DDNSHistory, error) {
+	var history []model.DDNSHistory
+	if err := DB.Where("profile_id = ?", profileID).Order("id DESC").Limit(limit).Find(&history).Error; err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+func cleanDDNSHistory() {
+	DB.Unscoped().Delete(&model.DDNSHistory{}, "created_at < ? OR profile_id NOT IN (SELECT `id` FROM ddns)", time.Now().AddDate(0, 0, -ddnsHistoryDays))
+}
+
 func (c *DDNSClass) sortList() {
 	c.listMu.RLock()
 	defer c.listMu.RUnlock()
@@ -107,5 +210,5 @@ func (c *DDNSClass) sortList() {
 
 	c.sortedListMu.Lock()
 	defer c.sortedListMu.Unlock()
-	c.sortedList = sortedList
+	c.setSortedList(sortedList)
 }
@@ -0,0 +1,271 @@
+package singleton
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/geoip"
+	"github.com/nezhahq/nezha/pkg/migrate"
+	"github.com/nezhahq/nezha/pkg/utils"
+)
+
+const (
+	errorLogSize = 100 // 保留的错误日志条数
+
+	defaultClockCheckServer = "pool.ntp.org:123"
+	clockCheckTimeout       = 5 * time.Second
+	clockSkewThreshold      = 10 * time.Second // 超过该偏差时 Agent 上报与补报的时间会出现错乱
+	selfCheckFindingMax     = 50
+)
+
+var startedAt = time.Now()
+
+// 包含这些词的日志视为错误或警告
+var errorLogPattern = regexp.MustCompile(`(?i)\b(error|errors|fail|failed|failure|panic|warning|warn)\b`)
+
+// 诊断信息可能被发给他人排查问题，日志中的 URL 只保留协议与主机，凭据替换为占位符
+var (
+	logURLPattern        = regexp.MustCompile(`([A-Za-z][A-Za-z0-9+.-]*://)(?:[^\s/@]*@)?([^\s/?#"'<>]+)[^\s"'<>]*`)
+	logCredentialPattern = regexp.MustCompile(`(?i)\b(password|passwd|pwd|secret|token|api[_-]?key|access[_-]?key|authorization)(\s*[=:]\s*)(?:bearer\s+|basic\s+)?[^\s&,;]+`)
+	logBearerPattern     = regexp.MustCompile(`(?i)\b(bearer|basic)\s+[A-Za-z0-9._~+/=-]+`)
+)
+
+// redactLogMessage 隐藏日志中的 URL 路径、查询参数及凭据
+func redactLogMessage(msg string) string {
+	msg = logURLPattern.ReplaceAllStringFunc(msg, func(u string) string {
+		m := logURLPattern.FindStringSubmatch(u)
+		if len(m[0]) == len(m[1])+len(m[2]) {
+			return m[0]
+		}
+		return m[1] + m[2] + "/" + model.SecretPlaceholder
+	})
+	msg = logCredentialPattern.ReplaceAllString(msg, "${1}${2}"+model.SecretPlaceholder)
+	return logBearerPattern.ReplaceAllString(msg, "${1} "+model.SecretPlaceholder)
+}
+
+// errorLogTail 作为标准库 log 的输出，原样写入 out 的同时在内存中保留最近的错误日志
+type errorLogTail struct {
+	mu      sync.Mutex
+	out     io.Writer
+	entries []model.LogEntry
+	next    int
+}
+
+var errorLog = &errorLogTail{}
+
+// CaptureErrorLog 开始在内存中保留最近的错误日志，用于诊断信息
+func CaptureErrorLog() {
+	errorLog.mu.Lock()
+	errorLog.out = log.Writer()
+	errorLog.mu.Unlock()
+	log.SetOutput(errorLog)
+}
+
+func (t *errorLogTail) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	n, err := len(p), error(nil)
+	if t.out != nil {
+		n, err = t.out.Write(p)
+	}
+	if errorLogPattern.Match(p) {
+		entry := model.LogEntry{Time: time.Now(), Message: redactLogMessage(strings.TrimRight(string(p), "\n"))}
+		if len(t.entries) < errorLogSize {
+			t.entries = append(t.entries, entry)
+		} else {
+			t.entries[t.next] = entry
+			t.next = (t.next + 1) % len(t.entries)
+		}
+	}
+	return n, err
+}
+
+func (t *errorLogTail) tail() []model.LogEntry {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append(append([]model.LogEntry{}, t.entries[t.next:]...), t.entries[:t.next]...)
+}
+
+var (
+	jobRunsMu sync.Mutex
+	jobRuns   = make(map[string]model.JobRun)
+)
+
+// markJobRun 记录定时任务的执行，在任务开始时以 defer markJobRun(name, time.Now()) 调用
+func markJobRun(name string, start time.Time) {
+	jobRunsMu.Lock()
+	defer jobRunsMu.Unlock()
+	jobRuns[name] = model.JobRun{StartedAt: start, DurationMs: time.Since(start).Milliseconds()}
+}
+
+var (
+	selfCheckMu       sync.Mutex
+	selfCheckFindings []model.SelfCheckFinding
+	forwardedWarned   atomic.Bool
+)
+
+// selfCheckWarn 记录并输出自检发现的问题
+func selfCheckWarn(check, format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	log.Printf("NEZHA>> WARNING: %s", msg)
+
+	selfCheckMu.Lock()
+	defer selfCheckMu.Unlock()
+	if len(selfCheckFindings) < selfCheckFindingMax {
+		selfCheckFindings = append(selfCheckFindings, model.SelfCheckFinding{Check: check, Message: msg, Time: time.Now()})
+	}
+}
+
+// RunSelfCheck 启动时检查常见的错误配置，只输出警告，不影响启动
+func RunSelfCheck() {
+	checkDatabaseFilesystem()
+	go checkClock()
+}
+
+// checkDatabaseFilesystem SQLite 数据库位于网络文件系统上时文件锁不可靠，可能损坏数据库
+func checkDatabaseFilesystem() {
+	if runtime.GOOS != "linux" || dbPath == "" {
+		return
+	}
+	path, err := filepath.Abs(dbPath)
+	if err != nil {
+		return
+	}
+	if p, err := filepath.EvalSymlinks(path); err == nil {
+		path = p
+	}
+	mounts, err := os.ReadFile("/proc/self/mounts")
+	if err != nil {
+		return
+	}
+	if mp, fs := utils.MountFSType(string(mounts), path); utils.IsNetworkFS(fs) {
+		selfCheckWarn("sqlite_network_fs", "the SQLite database %s is on a network filesystem (%s mounted at %s), file locking is unreliable and the database may be corrupted", path, fs, mp)
+	}
+}
+
+// checkClock 本机时钟与 NTP 服务器偏差过大时警告，无法访问 NTP 服务器时不做判断
+func checkClock() {
+	if Conf.DisableClockCheck {
+		return
+	}
+	server := Conf.ClockCheckServer
+	if server == "" {
+		server = defaultClockCheckServer
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), clockCheckTimeout)
+	defer cancel()
+	offset, err := utils.ClockOffset(ctx, server)
+	if err != nil {
+		log.Printf("NEZHA>> Skipped clock check against %s: %v", server, err)
+		return
+	}
+	if offset.Abs() > clockSkewThreshold {
+		selfCheckWarn("clock_skew", "the system clock is off by %s compared to %s, agent reports and alerts may use wrong times", offset.Round(time.Millisecond), server)
+	}
+}
+
+// CheckForwardedHeader 请求带有 X-Forwarded-For，但真实 IP 最终取自连接地址时警告一次：
+// 未配置真实 IP 请求头，或连接地址不在可信代理中，此时配置的请求头被忽略
+func CheckForwardedHeader(h http.Header, peer string) {
+	if forwardedWarned.Load() || h.Get("X-Forwarded-For") == "" {
+		return
+	}
+	msg := forwardedHeaderWarning(Conf.WebRealIPHeader, peer)
+	if msg != "" && forwardedWarned.CompareAndSwap(false, true) {
+		selfCheckWarn("trusted_proxy", "%s", msg)
+	}
+}
+
+func forwardedHeaderWarning(header, peer string) string {
+	switch header {
+	case "":
+		return "requests carry X-Forwarded-For but web_real_ip_header is not set, the WAF and audit log use the connecting address instead of the client address"
+	case model.ConfigUsePeerIP:
+		return ""
+	}
+	addr, err := utils.ParseAddrPort(peer)
+	if err != nil || TrustedProxy(addr) {
+		return ""
+	}
+	if len(Conf.TrustedProxies) == 0 {
+		return fmt.Sprintf("requests carry X-Forwarded-For but trusted_proxies is empty, %s is ignored and the WAF and audit log use the connecting address instead of the client address", header)
+	}
+	return fmt.Sprintf("requests from %s carry X-Forwarded-For but the address is not in trusted_proxies, %s is ignored and the WAF and audit log use the connecting address instead of the client address", addr, header)
+}
+
+// Diagnostics 汇总面板的诊断信息，gRPC 监听状态由调用方填写
+func Diagnostics() (*model.Diagnostics, error) {
+	d := &model.Diagnostics{
+		GeneratedAt: time.Now(),
+		Build: model.DiagnosticsBuild{
+			Version:   Version,
+			GoVersion: runtime.Version(),
+			OS:        runtime.GOOS,
+			Arch:      runtime.GOARCH,
+			StartedAt: startedAt,
+		},
+		GeoIP:    geoip.GetStats(),
+		ErrorLog: errorLog.tail(),
+		Settings: ListSettings(),
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			if s.Key == "vcs.revision" {
+				d.Build.Revision = s.Value
+			}
+		}
+	}
+
+	d.Database.Driver = DB.Dialector.Name()
+	d.Database.LatestVersion = migrations[len(migrations)-1].Version
+	if usingExternalTSDB() {
+		d.Database.TSDB = Conf.TSDB.Type
+	}
+	if err := DB.Model(&migrate.SchemaMigration{}).Select("COALESCE(MAX(version), 0)").Scan(&d.Database.SchemaVersion).Error; err != nil {
+		return nil, err
+	}
+
+	ServerShared.Range(func(_ uint64, s *model.Server) bool {
+		d.Counts.Servers++
+		if time.Since(s.LastActive) < s.OnlineTimeout(10*time.Second) {
+			d.Counts.OnlineServers++
+		}
+		if s.TaskStream != nil {
+			d.Connections.Agents++
+		}
+		return true
+	})
+	d.Counts.Services = len(ServiceSentinelShared.GetList())
+	if err := DB.Model(&model.AlertRule{}).Count(&d.Counts.AlertRules).Error; err != nil {
+		return nil, err
+	}
+	if err := DB.Model(&model.User{}).Count(&d.Counts.Users).Error; err != nil {
+		return nil, err
+	}
+	d.Connections.WebSocket = GetOnlineUserCount()
+	d.Connections.ClusterWebSocket = GetClusterOnlineUserCount()
+
+	jobRunsMu.Lock()
+	d.Jobs = maps.Clone(jobRuns)
+	jobRunsMu.Unlock()
+
+	selfCheckMu.Lock()
+	d.SelfCheck = append([]model.SelfCheckFinding{}, selfCheckFindings...)
+	selfCheckMu.Unlock()
+	return d, nil
+}
@@ -0,0 +1,79 @@
+package singleton
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+func TestErrorLogTail(t *testing.T) {
+	var out bytes.Buffer
+	tail := &errorLogTail{out: &out}
+
+	fmt.Fprintln(tail, "NEZHA>> Dashboard::START ON :8008")
+	for i := range errorLogSize + 5 {
+		fmt.Fprintf(tail, "NEZHA>> Failed to send notification %d: timeout\n", i)
+	}
+	fmt.Fprintln(tail, "NEZHA>> WARNING: clock is off")
+
+	if got := bytes.Count(out.Bytes(), []byte("\n")); got != errorLogSize+7 {
+		t.Fatalf("expected all lines to be passed through, got %d", got)
+	}
+	entries := tail.tail()
+	if len(entries) != errorLogSize {
+		t.Fatalf("expected %d entries, got %d", errorLogSize, len(entries))
+	}
+	if entries[0].Message != "NEZHA>> Failed to send notification 6: timeout" {
+		t.Errorf("unexpected oldest entry %q", entries[0].Message)
+	}
+	if last := entries[len(entries)-1].Message; last != "NEZHA>> WARNING: clock is off" {
+		t.Errorf("unexpected newest entry %q", last)
+	}
+}
+
+func TestRedactLogMessage(t *testing.T) {
+	cases := map[string]string{
+		`NEZHA>> Failed to send notification: Post "https://api.telegram.org/bot123:ABC/sendMessage?chat_id=1": timeout`: `NEZHA>> Failed to send notification: Post "https://api.telegram.org/******": timeout`,
+		"NEZHA>> error connecting to redis://:pass@10.0.0.1:6379/0":                                                      "NEZHA>> error connecting to redis://10.0.0.1:6379/******",
+		"NEZHA>> failed login password=hunter2 token: abc123":                                                            "NEZHA>> failed login password=****** token: ******",
+		"NEZHA>> error at https://example.com":                                                                           "NEZHA>> error at https://example.com",
+	}
+	for in, want := range cases {
+		if got := redactLogMessage(in); got != want {
+			t.Errorf("redactLogMessage(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestForwardedHeaderWarning(t *testing.T) {
+	old, oldProxies := Conf, trustedProxies.Load()
+	Conf = &ConfigClass{Config: &model.Config{}}
+	t.Cleanup(func() {
+		Conf = old
+		trustedProxies.Store(oldProxies)
+	})
+
+	if msg := forwardedHeaderWarning("", "10.0.0.1:1234"); !strings.Contains(msg, "web_real_ip_header") {
+		t.Fatalf("expected warning about web_real_ip_header, got %q", msg)
+	}
+	if msg := forwardedHeaderWarning(model.ConfigUsePeerIP, "10.0.0.1:1234"); msg != "" {
+		t.Fatalf("expected no warning when using the peer address, got %q", msg)
+	}
+	if msg := forwardedHeaderWarning("X-Real-IP", "10.0.0.1:1234"); !strings.Contains(msg, "trusted_proxies is empty") {
+		t.Fatalf("expected warning about empty trusted_proxies, got %q", msg)
+	}
+
+	Conf.TrustedProxies = []string{"10.0.0.0/8"}
+	if err := Conf.updateTrustedProxies(); err != nil {
+		t.Fatal(err)
+	}
+	if msg := forwardedHeaderWarning("X-Real-IP", "10.0.0.1:1234"); msg != "" {
+		t.Fatalf("expected no warning for a trusted proxy, got %q", msg)
+	}
+	if msg := forwardedHeaderWarning("X-Real-IP", "192.0.2.1:1234"); !strings.Contains(msg, "192.0.2.1") {
+		t.Fatalf("expected warning about the untrusted proxy, got %q", msg)
+	}
+}
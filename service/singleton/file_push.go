@@ -0,0 +1,251 @@
+package singleton
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-json"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/utils"
+	pb "github.com/nezhahq/nezha/proto"
+)
+
+const (
+	// Agent 超过该时间未确认分块时推送失败
+	filePushIdleTimeout = 5 * time.Minute
+	// 已结束的推送保留的时间，用于查询结果
+	filePushRetention = time.Hour
+)
+
+type filePushTransfer struct {
+	push    model.FilePush
+	userID  uint64
+	content []byte
+	sha256  string
+	done    func(*model.FilePush)
+}
+
+// FilePushClass 将面板保存的文件分块推送到 Agent，Agent 重新连接后从已确认的位置继续
+type FilePushClass struct {
+	mu        sync.Mutex
+	transfers map[string]*filePushTransfer
+}
+
+var FilePushShared *FilePushClass
+
+func NewFilePushClass() *FilePushClass {
+	c := &FilePushClass{transfers: make(map[string]*filePushTransfer)}
+	go c.worker()
+	return c
+}
+
+func (c *FilePushClass) worker() {
+	for range time.Tick(time.Minute) {
+		c.sweep()
+	}
+}
+
+// PushFileChecksum 返回文件内容的 SHA256
+func PushFileChecksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// Start 开始向服务器推送文件，done 在推送结束后调用
+func (c *FilePushClass) Start(userID uint64, s *model.Server, fileID uint64, path string, mode uint32, done func(*model.FilePush)) (*model.FilePush, error) {
+	if err := model.ValidatePushPath(path); err != nil {
+		return nil, Localizer.ErrorT("invalid destination path: %v", err)
+	}
+	if mode == 0 {
+		mode = 0o644
+	}
+	if err := model.ValidatePushMode(mode); err != nil {
+		return nil, Localizer.ErrorT("invalid file mode: %v", err)
+	}
+
+	var f model.PushFile
+	if err := DB.First(&f, fileID).Error; err != nil {
+		return nil, Localizer.ErrorT("file id %d does not exist", fileID)
+	}
+	if PushFileChecksum(f.Content) != f.SHA256 {
+		return nil, Localizer.ErrorT("file %s is corrupted", f.Name)
+	}
+	if s.TaskStream == nil {
+		return nil, Localizer.ErrorT("server not found or not connected")
+	}
+	if err := CheckCapability(s, model.AgentCapabilityFilePush); err != nil {
+		return nil, err
+	}
+
+	tr := &filePushTransfer{
+		push: model.FilePush{
+			ID:        utils.MustGenerateRandomString(16),
+			ServerID:  s.ID,
+			FileID:    f.ID,
+			Path:      path,
+			Mode:      mode,
+			Size:      f.Size,
+			Status:    model.FilePushRunning,
+			UpdatedAt: time.Now(),
+		},
+		userID:  userID,
+		content: f.Content,
+		sha256:  f.SHA256,
+		done:    done,
+	}
+
+	c.mu.Lock()
+	c.transfers[tr.push.ID] = tr
+	err := c.sendChunk(tr)
+	if err != nil {
+		c.finish(tr, model.FilePushFailure, err.Error())
+	}
+	push := tr.push
+	c.mu.Unlock()
+	return &push, nil
+}
+
+// sendChunk 下发已确认位置之后的一个分块，调用时需持有锁
+func (c *FilePushClass) sendChunk(tr *filePushTransfer) error {
+	s, ok := ServerShared.Get(tr.push.ServerID)
+	if !ok || s.TaskStream == nil {
+		return Localizer.ErrorT("server not found or not connected")
+	}
+	end := min(tr.push.Offset+model.PushFileChunkSize, tr.push.Size)
+	data, err := json.Marshal(&model.FilePushTask{
+		TransferID: tr.push.ID,
+		Path:       tr.push.Path,
+		Mode:       tr.push.Mode,
+		Size:       tr.push.Size,
+		SHA256:     tr.sha256,
+		Offset:     tr.push.Offset,
+		Data:       tr.content[tr.push.Offset:end],
+	})
+	if err != nil {
+		return err
+	}
+	return s.TaskStream.Send(&pb.Task{
+		Id:   tr.push.FileID,
+		Type: model.TaskTypeFilePush,
+		Data: string(data),
+	})
+}
+
+// Report 处理 Agent 对分块的确认
+func (c *FilePushClass) Report(serverID uint64, result *pb.TaskResult) {
+	var r model.FilePushResult
+	if err := json.Unmarshal([]byte(result.GetData()), &r); err != nil {
+		log.Printf("NEZHA>> Invalid file push result from server %d: %v", serverID, err)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tr, ok := c.transfers[r.TransferID]
+	if !ok || tr.push.ServerID != serverID || tr.push.Status != model.FilePushRunning {
+		return
+	}
+
+	switch {
+	case !result.GetSuccessful() || r.Error != "":
+		c.finish(tr, model.FilePushFailure, r.Error)
+		return
+	case r.Offset < 0 || r.Offset > tr.push.Size:
+		c.finish(tr, model.FilePushFailure, Localizer.Tf("invalid offset %d", r.Offset))
+		return
+	}
+
+	tr.push.Offset = r.Offset
+	tr.push.UpdatedAt = time.Now()
+	if r.Offset < tr.push.Size {
+		if err := c.sendChunk(tr); err != nil {
+			// 等待 Agent 重新连接后继续
+			log.Printf("NEZHA>> Failed to send file chunk to server %d: %v", serverID, err)
+		}
+		return
+	}
+
+	tr.push.SHA256 = r.SHA256
+	if r.SHA256 != tr.sha256 {
+		c.finish(tr, model.FilePushFailure, Localizer.T("checksum mismatch"))
+		return
+	}
+	c.finish(tr, model.FilePushSuccess, "")
+}
+
+// Resume 在 Agent 重新连接后继续未完成的推送，Agent 会返回其实际收到的字节数
+func (c *FilePushClass) Resume(serverID uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, tr := range c.transfers {
+		if tr.push.ServerID != serverID || tr.push.Status != model.FilePushRunning {
+			continue
+		}
+		if err := c.sendChunk(tr); err != nil {
+			log.Printf("NEZHA>> Failed to resume file push %s: %v", tr.push.ID, err)
+		}
+	}
+}
+
+// Get 返回推送的状态
+func (c *FilePushClass) Get(id string) (*model.FilePush, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tr, ok := c.transfers[id]
+	if !ok {
+		return nil, false
+	}
+	push := tr.push
+	return &push, true
+}
+
+// finish 结束推送并写入审计日志，调用时需持有锁
+func (c *FilePushClass) finish(tr *filePushTransfer, status, errMsg string) {
+	tr.push.Status = status
+	tr.push.Error = errMsg
+	tr.push.UpdatedAt = time.Now()
+	tr.content = nil
+
+	entry := &model.AuditLog{
+		CreatedAt:  tr.push.UpdatedAt,
+		UserID:     tr.userID,
+		Method:     "TASK",
+		Route:      "agent/file-push",
+		EntityType: "push_file",
+		EntityID:   strconv.FormatUint(tr.push.FileID, 10),
+		Summary:    fmt.Sprintf("server: %d, path: %s, mode: %o, size: %d", tr.push.ServerID, tr.push.Path, tr.push.Mode, tr.push.Size),
+		Status:     http.StatusOK,
+		Success:    status == model.FilePushSuccess,
+		Error:      errMsg,
+	}
+	if !entry.Success {
+		entry.Status = http.StatusBadGateway
+	}
+	AuditLogShared.Record(entry)
+
+	if tr.done != nil {
+		push := tr.push
+		go tr.done(&push)
+	}
+}
+
+func (c *FilePushClass) sweep() {
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, tr := range c.transfers {
+		switch {
+		case tr.push.Status == model.FilePushRunning && now.Sub(tr.push.UpdatedAt) > filePushIdleTimeout:
+			c.finish(tr, model.FilePushFailure, Localizer.T("operation timeout"))
+		case tr.push.Status != model.FilePushRunning && now.Sub(tr.push.UpdatedAt) > filePushRetention:
+			delete(c.transfers, id)
+		}
+	}
+}
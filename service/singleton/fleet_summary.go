@@ -0,0 +1,313 @@
+package singleton
+
+import (
+	"cmp"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/i18n"
+)
+
+const (
+	fleetSummaryTopN          = 5
+	fleetSummaryCertDays      = 30 // 证书在该天数内过期时列入汇总
+	fleetSummaryRetryInterval = time.Minute
+)
+
+const fleetSummaryDefaultTemplate = `[{{T "Fleet Summary"}}] {{date .Start}} - {{date .End}}
+{{- if .Sections.servers}}
+
+{{T "Servers"}}
+{{- range .ServersAdded}}
++ {{.Name}}
+{{- end}}
+{{- range .ServersRemoved}}
+- {{.Name}}
+{{- end}}
+{{- if and (not .ServersAdded) (not .ServersRemoved)}}
+{{T "No changes"}}
+{{- end}}
+{{- end}}
+{{- if .Sections.downtime}}
+
+{{T "Downtime events"}}: {{.DowntimeEvents}}
+{{- range .DowntimeServices}}
+{{.Name}}: {{printf "%.0f" .Value}}
+{{- end}}
+{{- end}}
+{{- if .Sections.cpu}}
+
+{{T "Top servers by CPU"}}
+{{- range .TopCPU}}
+{{.Name}}: {{printf "%.2f" .Value}}%
+{{- else}}
+{{T "No data"}}
+{{- end}}
+{{- end}}
+{{- if .Sections.bandwidth}}
+
+{{T "Top servers by bandwidth"}}
+{{- range .TopBandwidth}}
+{{.Name}}: {{bytes .Value}}
+{{- else}}
+{{T "No data"}}
+{{- end}}
+{{- end}}
+{{- if .Sections.certificates}}
+
+{{T "Expiring certificates"}}
+{{- range .Certificates}}
+{{if .RenewalDue}}! {{end}}{{.Service}}: {{date .NotAfter}} ({{T "days remaining"}}: {{.DaysRemaining}})
+{{- else}}
+{{T "None"}}
+{{- end}}
+{{- end}}`
+
+// parseFleetSummaryTemplate 解析汇总模板，t 为通知组使用的语言
+func parseFleetSummaryTemplate(text string, t *i18n.Lang) (*template.Template, error) {
+	if text == "" {
+		text = fleetSummaryDefaultTemplate
+	}
+	return template.New("fleet_summary").Funcs(template.FuncMap{
+		"T": t.T,
+		"date": func(t time.Time) string {
+			return t.In(Loc).Format(time.DateOnly)
+		},
+		"bytes": formatFleetSummaryBytes,
+	}).Parse(text)
+}
+
+// ValidateFleetSummary 启动时检查汇总配置
+func ValidateFleetSummary() error {
+	if err := Conf.FleetSummary.Validate(); err != nil {
+		return err
+	}
+	_, err := parseFleetSummaryTemplate(Conf.FleetSummary.Template, NotificationShared.Lang(0))
+	return err
+}
+
+// SendFleetSummary 定时任务入口，在后台生成汇总，失败时重试一次
+func SendFleetSummary() {
+	if Conf.FleetSummary.NotificationGroupID == 0 {
+		return
+	}
+	go func() {
+		end := time.Now()
+		start := end.AddDate(0, 0, -Conf.FleetSummary.Days)
+		err := sendFleetSummary(start, end)
+		if err != nil {
+			log.Printf("NEZHA>> Failed to send fleet summary, retrying in %v: %v", fleetSummaryRetryInterval, err)
+			time.Sleep(fleetSummaryRetryInterval)
+			err = sendFleetSummary(start, end)
+		}
+		if err != nil {
+			log.Printf("NEZHA>> Failed to send fleet summary: %v", err)
+		}
+	}()
+}
+
+func sendFleetSummary(start, end time.Time) error {
+	conf := Conf.FleetSummary
+	data, err := computeFleetSummary(&conf, start, end)
+	if err != nil {
+		return err
+	}
+	tmpl, err := parseFleetSummaryTemplate(conf.Template, NotificationShared.Lang(conf.NotificationGroupID))
+	if err != nil {
+		return err
+	}
+	var msg strings.Builder
+	if err := tmpl.Execute(&msg, data); err != nil {
+		return err
+	}
+	NotificationShared.SendNotification(conf.NotificationGroupID, msg.String(), "")
+	return nil
+}
+
+func computeFleetSummary(conf *model.FleetSummaryConf, start, end time.Time) (*model.FleetSummaryData, error) {
+	data := &model.FleetSummaryData{
+		Start:    start,
+		End:      end,
+		Sections: make(map[string]bool),
+	}
+	for _, s := range model.FleetSummarySections {
+		data.Sections[s] = conf.Has(s)
+	}
+
+	if data.Sections[model.FleetSummaryServers] {
+		if err := fleetSummaryServers(data); err != nil {
+			return nil, err
+		}
+	}
+	if data.Sections[model.FleetSummaryDowntime] {
+		if err := fleetSummaryDowntime(data); err != nil {
+			return nil, err
+		}
+	}
+	if data.Sections[model.FleetSummaryCPU] {
+		if err := fleetSummaryCPU(data); err != nil {
+			return nil, err
+		}
+	}
+	if data.Sections[model.FleetSummaryBandwidth] {
+		if err := fleetSummaryBandwidth(data); err != nil {
+			return nil, err
+		}
+	}
+	if data.Sections[model.FleetSummaryCertificates] {
+		if err := fleetSummaryCertificates(data); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// fleetSummaryServers 新增的服务器来自创建时间，删除的服务器来自服务器删除事件
+func fleetSummaryServers(data *model.FleetSummaryData) error {
+	var added []model.Server
+	if err := DB.Select("id, name").Where("created_at >= ? AND created_at < ?", data.Start, data.End).
+		Order("id").Find(&added).Error; err != nil {
+		return err
+	}
+	for _, s := range added {
+		data.ServersAdded = append(data.ServersAdded, model.FleetSummaryItem{Name: s.Name, Value: float64(s.ID)})
+	}
+
+	var events []*model.ServerEvent
+	if err := DB.Where("type = ? AND created_at >= ? AND created_at < ?", model.ServerEventDeleted, data.Start, data.End).
+		Order("id").Find(&events).Error; err != nil {
+		return err
+	}
+	for _, e := range events {
+		name := fmt.Sprintf("#%d", e.ServerID)
+		if change, ok := e.Change("name"); ok {
+			if n, _ := change.Old.(string); n != "" {
+				name = n
+			}
+		}
+		data.ServersRemoved = append(data.ServersRemoved, model.FleetSummaryItem{Name: name, Value: float64(e.ServerID)})
+	}
+	return nil
+}
+
+// fleetSummaryDowntime 按汇总的服务监控记录统计故障次数，连续的故障记录算作一次
+func fleetSummaryDowntime(data *model.FleetSummaryData) error {
+	var rows []model.ServiceHistory
+	if err := DB.Select("service_id, created_at, up, down").
+		Where("server_id = 0 AND created_at >= ? AND created_at < ?", data.Start, data.End).
+		Order("service_id, created_at").Find(&rows).Error; err != nil {
+		return err
+	}
+
+	counts := make(map[uint64]int)
+	var prev uint64
+	var wasDown bool
+	for _, r := range rows {
+		if r.ServiceID != prev {
+			prev, wasDown = r.ServiceID, false
+		}
+		down := r.Down > r.Up
+		if down && !wasDown {
+			counts[r.ServiceID]++
+			data.DowntimeEvents++
+		}
+		wasDown = down
+	}
+
+	for id, n := range counts {
+		name := fmt.Sprintf("#%d", id)
+		if s, ok := ServiceSentinelShared.Get(id); ok {
+			name = s.Name
+		}
+		data.DowntimeServices = append(data.DowntimeServices, model.FleetSummaryItem{Name: name, Value: float64(n)})
+	}
+	data.DowntimeServices = topFleetSummaryItems(data.DowntimeServices)
+	return nil
+}
+
+// fleetSummaryCPU 按小时汇总计算平均 CPU 占用，每小时按采样点数加权
+func fleetSummaryCPU(data *model.FleetSummaryData) error {
+	var rows []struct {
+		ServerID uint64
+		CPU      float64
+	}
+	if err := DB.Model(&model.ServerStateHourly{}).Select("server_id, SUM(cpu * samples) / SUM(samples) AS cpu").
+		Where("start >= ? AND start < ? AND samples > 0", data.Start, data.End).
+		Group("server_id").Scan(&rows).Error; err != nil {
+		return err
+	}
+	for _, r := range rows {
+		if s, ok := ServerShared.Get(r.ServerID); ok {
+			data.TopCPU = append(data.TopCPU, model.FleetSummaryItem{Name: s.Name, Value: r.CPU})
+		}
+	}
+	data.TopCPU = topFleetSummaryItems(data.TopCPU)
+	return nil
+}
+
+// fleetSummaryBandwidth 按每日流量汇总统计，只包含已结束的整天
+func fleetSummaryBandwidth(data *model.FleetSummaryData) error {
+	var rows []struct {
+		ServerID uint64
+		Total    uint64
+	}
+	if err := DB.Model(&model.TransferDaily{}).Select("server_id, SUM(`in` + `out`) AS total").
+		Where("interface = '' AND date >= ? AND date < ?", transferDay(data.Start), transferDay(data.End)).
+		Group("server_id").Scan(&rows).Error; err != nil {
+		return err
+	}
+	for _, r := range rows {
+		if s, ok := ServerShared.Get(r.ServerID); ok && r.Total > 0 {
+			data.TopBandwidth = append(data.TopBandwidth, model.FleetSummaryItem{Name: s.Name, Value: float64(r.Total)})
+		}
+	}
+	data.TopBandwidth = topFleetSummaryItems(data.TopBandwidth)
+	return nil
+}
+
+// fleetSummaryCertificates 列出即将过期的证书，已进入服务过期提醒阈值的标记为需要续期
+func fleetSummaryCertificates(data *model.FleetSummaryData) error {
+	var certs []*model.ServiceCert
+	if err := DB.Where("not_after < ?", data.End.AddDate(0, 0, fleetSummaryCertDays)).
+		Order("not_after").Find(&certs).Error; err != nil {
+		return err
+	}
+	for _, c := range certs {
+		s, ok := ServiceSentinelShared.Get(c.ServiceID)
+		if !ok {
+			continue
+		}
+		warn, critical := s.TLSExpiryThresholds()
+		data.Certificates = append(data.Certificates, model.FleetSummaryCert{
+			Service:       s.Name,
+			Issuer:        c.Issuer,
+			NotAfter:      c.NotAfter,
+			DaysRemaining: c.DaysRemaining,
+			RenewalDue:    c.DaysRemaining <= max(warn, critical),
+		})
+	}
+	return nil
+}
+
+func topFleetSummaryItems(items []model.FleetSummaryItem) []model.FleetSummaryItem {
+	slices.SortStableFunc(items, func(a, b model.FleetSummaryItem) int {
+		return cmp.Or(cmp.Compare(b.Value, a.Value), cmp.Compare(a.Name, b.Name))
+	})
+	return items[:min(len(items), fleetSummaryTopN)]
+}
+
+func formatFleetSummaryBytes(v float64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB"}
+	i := 0
+	for v >= 1024 && i < len(units)-1 {
+		v /= 1024
+		i++
+	}
+	return strconv.FormatFloat(v, 'f', 2, 64) + " " + units[i]
+}
@@ -0,0 +1,96 @@
+package singleton
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+
+	"github.com/nezhahq/nezha/pkg/utils"
+)
+
+// 检查证书文件是否变化的间隔
+const grpcTLSReloadInterval = 30 * time.Second
+
+// GRPCTLSShared Agent TLS 端口使用的证书，未启用时为 nil
+var GRPCTLSShared *utils.CertReloader
+
+// validateGRPCTLS 检查 Agent TLS 端口配置，避免配置错误时静默回退为明文
+func (c *ConfigClass) validateGRPCTLS() error {
+	conf := c.GRPCTLS
+	if conf.ListenPort == 0 {
+		if conf.CertPath != "" || conf.KeyPath != "" || conf.ClientCAPath != "" {
+			return errors.New("grpc_tls.listen_port is required when grpc_tls certificates are configured")
+		}
+		return nil
+	}
+	if conf.CertPath == "" || conf.KeyPath == "" {
+		return errors.New("grpc_tls.cert_path and grpc_tls.key_path are required")
+	}
+	if conf.ListenPort == c.ListenPort || conf.ListenPort == c.HTTPS.ListenPort {
+		return errors.New("grpc_tls.listen_port conflicts with another listener")
+	}
+	return nil
+}
+
+// InitGRPCTLS 加载 Agent TLS 端口的证书，收到 SIGHUP 或证书文件变化时重新加载
+func InitGRPCTLS() error {
+	conf := Conf.GRPCTLS
+	if conf.ListenPort == 0 {
+		return nil
+	}
+	r, err := utils.NewCertReloader(conf.CertPath, conf.KeyPath, conf.ClientCAPath)
+	if err != nil {
+		return err
+	}
+	GRPCTLSShared = r
+
+	go func() {
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		ticker := time.NewTicker(grpcTLSReloadInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-hup:
+				if err := r.Reload(); err != nil {
+					log.Printf("NEZHA>> Failed to reload gRPC TLS certificate: %v", err)
+				} else {
+					log.Println("NEZHA>> gRPC TLS certificate reloaded")
+				}
+			case <-ticker.C:
+				if changed, err := r.ReloadIfChanged(); err != nil {
+					log.Printf("NEZHA>> Failed to reload gRPC TLS certificate: %v", err)
+				} else if changed {
+					log.Println("NEZHA>> gRPC TLS certificate reloaded")
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// CheckAgentCertificate 启用双向认证时，Agent 必须通过 TLS 端口接入，且证书的 CN 或 SAN 与其 UUID 一致
+func CheckAgentCertificate(ctx context.Context, uuid string) error {
+	if Conf.GRPCTLS.ClientCAPath == "" {
+		return nil
+	}
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return errors.New("no peer information")
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 {
+		return errors.New("client certificate required")
+	}
+	if !utils.CertMatchesIdentity(info.State.VerifiedChains[0][0], uuid) {
+		return errors.New("client certificate does not match uuid " + uuid)
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package singleton
+
+import (
+	"time"
+
+	"github.com/goccy/go-json"
+
+	"github.com/nezhahq/nezha/model"
+	pb "github.com/nezhahq/nezha/proto"
+)
+
+// PushReportConfig 向 Agent 下发上报模式，未上报心跳能力的 Agent 不下发
+func PushReportConfig(s *model.Server) error {
+	if s.TaskStream == nil || s.Capabilities == nil || !s.Capabilities.Heartbeat {
+		return nil
+	}
+	data, err := json.Marshal(s.ReportConfig())
+	if err != nil {
+		return err
+	}
+	return s.TaskStream.Send(&pb.Task{Type: model.TaskTypeApplyConfig, Data: string(data)})
+}
+
+// RecordHeartbeat 记录心跳模式下的最小上报，状态中只有运行时间与负载。
+// 心跳不记入状态历史、连接数与字段更新时间，缺失的指标不会被当作 0
+func (c *ServerClass) RecordHeartbeat(s *model.Server, report *model.HeartbeatReport) error {
+	if err := report.Validate(s.HeartbeatAt); err != nil {
+		return err
+	}
+	state := report.State()
+	if err := state.Sanitize(s.Host); err != nil {
+		return err
+	}
+
+	s.LastActive = time.Now()
+	s.HeartbeatAt = time.Unix(report.Timestamp, 0)
+	s.State = &state
+	CountReport()
+	ClusterShared.PublishServerState(s)
+	return nil
+}
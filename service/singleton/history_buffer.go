@@ -0,0 +1,172 @@
+package singleton
+
+import (
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+const (
+	historyFlushInterval  = 5 * time.Second
+	historyFlushThreshold = 1000  // 缓冲的记录数达到该值时提前写入
+	historyBufferCapacity = 20000 // 缓冲已满时直接写入数据库
+	historyInsertBatch    = 500
+)
+
+// historyBuffer 按服务缓冲监控记录，定期在一个事务中批量写入，减少 SQLite 的写入次数
+type historyBuffer struct {
+	mu       sync.Mutex
+	rows     map[uint64][]*model.ServiceHistory // [service_id] -> 未写入的记录
+	size     int
+	capacity int
+	flushMu  sync.Mutex // 保证同一时间只有一次批量写入
+	flushCh  chan struct{}
+}
+
+func newHistoryBuffer(capacity int) *historyBuffer {
+	return &historyBuffer{
+		rows:     make(map[uint64][]*model.ServiceHistory),
+		capacity: capacity,
+		flushCh:  make(chan struct{}, 1),
+	}
+}
+
+// run 定期或在缓冲的记录数达到阈值时写入数据库
+func (hb *historyBuffer) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-hb.flushCh:
+		}
+		hb.Flush()
+	}
+}
+
+// Add 缓冲一条监控记录，缓冲已满时直接写入
+func (hb *historyBuffer) Add(h *model.ServiceHistory) {
+	if h.CreatedAt.IsZero() {
+		h.CreatedAt = time.Now()
+	}
+
+	hb.mu.Lock()
+	if hb.size >= hb.capacity {
+		hb.mu.Unlock()
+		if _, err := writeServiceHistory([]*model.ServiceHistory{h}); err != nil {
+			log.Printf("NEZHA>> Failed to save service monitor metrics: %v", err)
+		}
+		invalidateServerHistoryCache([]*model.ServiceHistory{h})
+		return
+	}
+	hb.rows[h.ServiceID] = append(hb.rows[h.ServiceID], h)
+	hb.size++
+	full := hb.size >= historyFlushThreshold
+	hb.mu.Unlock()
+
+	if full {
+		select {
+		case hb.flushCh <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Flush 将缓冲的记录在一个事务中写入数据库，失败时放回缓冲等待下次写入
+func (hb *historyBuffer) Flush() {
+	hb.flushMu.Lock()
+	defer hb.flushMu.Unlock()
+
+	hb.mu.Lock()
+	if hb.size == 0 {
+		hb.mu.Unlock()
+		return
+	}
+	pending := hb.rows
+	hb.rows = make(map[uint64][]*model.ServiceHistory)
+	hb.size = 0
+	hb.mu.Unlock()
+
+	var rows []*model.ServiceHistory
+	for _, r := range pending {
+		rows = append(rows, r...)
+	}
+	if failed, err := writeServiceHistory(rows); err != nil {
+		log.Printf("NEZHA>> Failed to save %d service monitor metrics: %v", len(failed), err)
+		hb.requeue(failed)
+	}
+	invalidateServerHistoryCache(rows)
+}
+
+// invalidateServerHistoryCache 记录写入后使涉及的服务器的监控历史缓存失效，
+// 每次批量写入只失效一次，两次写入之间的新记录在缓存过期后可见
+func invalidateServerHistoryCache(rows []*model.ServiceHistory) {
+	var ids []uint64
+	for _, r := range rows {
+		if r.ServerID != 0 && !slices.Contains(ids, r.ServerID) {
+			ids = append(ids, r.ServerID)
+		}
+	}
+	if len(ids) > 0 {
+		QueryCacheShared.Invalidate(QueryCacheServerServiceHistory, ids...)
+	}
+}
+
+func (hb *historyBuffer) requeue(failed []*model.ServiceHistory) {
+	pending := make(map[uint64][]*model.ServiceHistory)
+	for _, r := range failed {
+		pending[r.ServiceID] = append(pending[r.ServiceID], r)
+	}
+
+	hb.mu.Lock()
+	defer hb.mu.Unlock()
+
+	for id, rows := range pending {
+		n := min(len(rows), hb.capacity-hb.size)
+		if n <= 0 {
+			log.Printf("NEZHA>> Service history buffer is full, dropped %d metrics", len(rows))
+			continue
+		}
+		// 保留较新的记录
+		rows = rows[len(rows)-n:]
+		for _, r := range rows {
+			r.ID = 0
+		}
+		hb.rows[id] = append(rows, hb.rows[id]...)
+		hb.size += n
+	}
+}
+
+func (hb *historyBuffer) Delete(serviceID uint64) {
+	hb.mu.Lock()
+	defer hb.mu.Unlock()
+
+	hb.size -= len(hb.rows[serviceID])
+	delete(hb.rows, serviceID)
+}
+
+// pending 返回尚未写入数据库、满足条件的记录副本
+func (hb *historyBuffer) pending(filter func(h *model.ServiceHistory) bool) []*model.ServiceHistory {
+	hb.mu.Lock()
+	defer hb.mu.Unlock()
+
+	var rows []*model.ServiceHistory
+	for _, rs := range hb.rows {
+		for _, r := range rs {
+			if filter(r) {
+				h := *r
+				rows = append(rows, &h)
+			}
+		}
+	}
+	return rows
+}
+
+// FlushHistory 将缓冲的监控记录与原始检查结果全部写入数据库
+func (ss *ServiceSentinel) FlushHistory() {
+	ss.history.Flush()
+	ss.results.Flush()
+}
@@ -0,0 +1,91 @@
+package singleton
+
+import (
+	"path/filepath"
+	"testing"
+
+	"gorm.io/gorm/logger"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+func setupTestDB(tb testing.TB, models ...any) {
+	db, err := openDB(filepath.Join(tb.TempDir(), "sqlite.db"))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	db.Logger = logger.Discard
+	if err := db.AutoMigrate(models...); err != nil {
+		tb.Fatal(err)
+	}
+	old := DB
+	DB = db
+	tb.Cleanup(func() {
+		DB = old
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+}
+
+func newTestHistory(i int) *model.ServiceHistory {
+	return &model.ServiceHistory{
+		ServiceID: uint64(i%200 + 1),
+		ServerID:  uint64(i%20 + 1),
+		AvgDelay:  float32(i % 100),
+	}
+}
+
+func TestHistoryBuffer(t *testing.T) {
+	setupTestDB(t, model.ServiceHistory{})
+
+	hb := newHistoryBuffer(3)
+	for i := range 5 {
+		hb.Add(newTestHistory(i))
+	}
+
+	// 超出容量的记录直接写入
+	var count int64
+	DB.Model(&model.ServiceHistory{}).Count(&count)
+	if count != 2 {
+		t.Fatalf("expected 2 rows written directly, got %d", count)
+	}
+	if n := len(hb.pending(func(*model.ServiceHistory) bool { return true })); n != 3 {
+		t.Fatalf("expected 3 pending rows, got %d", n)
+	}
+
+	hb.Delete(1)
+	hb.Flush()
+	DB.Model(&model.ServiceHistory{}).Count(&count)
+	if count != 4 {
+		t.Fatalf("expected 4 rows after flush, got %d", count)
+	}
+	if n := len(hb.pending(func(*model.ServiceHistory) bool { return true })); n != 0 {
+		t.Fatalf("expected empty buffer after flush, got %d", n)
+	}
+}
+
+// 逐条写入，每条记录一个事务
+func BenchmarkServiceHistoryDirectInsert(b *testing.B) {
+	setupTestDB(b, model.ServiceHistory{})
+	b.ResetTimer()
+	for i := range b.N {
+		if err := DB.Create(newTestHistory(i)).Error; err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// 经缓冲批量写入，每 historyFlushThreshold 条记录一个事务
+func BenchmarkServiceHistoryBufferedInsert(b *testing.B) {
+	setupTestDB(b, model.ServiceHistory{})
+	hb := newHistoryBuffer(historyBufferCapacity)
+	b.ResetTimer()
+	for i := range b.N {
+		hb.Add(newTestHistory(i))
+		if (i+1)%historyFlushThreshold == 0 {
+			hb.Flush()
+		}
+	}
+	hb.Flush()
+}
@@ -2,6 +2,8 @@ package singleton
 
 import (
 	"log"
+	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/nezhahq/nezha/pkg/i18n"
@@ -23,13 +25,20 @@ func loadTranslation() error {
 		lang = "zh_CN"
 	}
 
-	lang = strings.Replace(lang, "-", "_", 1)
+	lang = NormalizeLanguage(lang)
 	Localizer = i18n.NewLocalizer(lang, domain, "translations", i18n.Translations)
-	return nil
+	return Localizer.LoadDir(i18nDir())
+}
+
+func i18nDir() string {
+	if Conf.I18nDir != "" {
+		return Conf.I18nDir
+	}
+	return filepath.Join(Conf.Dir(), "i18n")
 }
 
 func OnUpdateLang(lang string) error {
-	lang = strings.Replace(lang, "-", "_", 1)
+	lang = NormalizeLanguage(lang)
 	if Localizer.Exists(lang) {
 		Localizer.SetLanguage(lang)
 		return nil
@@ -39,3 +48,13 @@ func OnUpdateLang(lang string) error {
 	Localizer.SetLanguage(lang)
 	return nil
 }
+
+// NormalizeLanguage 将 zh-CN 等格式统一为 zh_CN
+func NormalizeLanguage(lang string) string {
+	return strings.Replace(lang, "-", "_", 1)
+}
+
+// ValidLanguage 语言为空或存在对应的翻译
+func ValidLanguage(lang string) bool {
+	return lang == "" || slices.Contains(Localizer.Languages(), NormalizeLanguage(lang))
+}
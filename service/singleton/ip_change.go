@@ -0,0 +1,119 @@
+package singleton
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+// ipChangeTracker 按服务器记录已确认的公网 IP
+type ipChangeTracker struct {
+	mu        sync.Mutex
+	detectors map[uint64]*model.IPChangeDetector
+}
+
+func newIPChangeTracker() *ipChangeTracker {
+	return &ipChangeTracker{detectors: make(map[uint64]*model.IPChangeDetector)}
+}
+
+// Delete 删除服务器的 IP 记录
+func (t *ipChangeTracker) Delete(ids ...uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, id := range ids {
+		delete(t.detectors, id)
+	}
+}
+
+func validIPFamily(v string) error {
+	switch v {
+	case "", model.IPFamilyV4, model.IPFamilyV6:
+		return nil
+	}
+	return Localizer.ErrorT("invalid ip family: %s", v)
+}
+
+// ObserveIP 检测 Agent 上报的公网 IP 变化，需在更新 server.GeoIP 之前调用。
+// 变化得到确认后记录生命周期事件，并按设置发送 IP 变更提醒
+func (c *ServerClass) ObserveIP(server *model.Server, geoip model.GeoIP) {
+	if geoip.IP.Join() == "" {
+		return
+	}
+
+	t := c.ipChanges
+	t.mu.Lock()
+	d, ok := t.detectors[server.ID]
+	if !ok {
+		d = &model.IPChangeDetector{}
+		if server.GeoIP != nil && server.GeoIP.IP.Join() != "" {
+			d.Stable = *server.GeoIP
+		} else {
+			// 面板重启后内存中没有地址，以最近一次记录为准，首次上报时不产生事件
+			d.Stable = lastRecordedIP(server.ID, geoip)
+		}
+		t.detectors[server.ID] = d
+	}
+	old, changed := d.Observe(geoip, time.Now(), time.Duration(Conf.IPChangeStableWindow)*time.Second)
+	t.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	changes := model.IPChanges(old, geoip)
+	RecordServerEvent(server.ID, model.ServerEventIPChanged, model.ServerEventActorAgent, 0, changes...)
+	notifyIPChange(server, old, geoip, changes)
+}
+
+func lastRecordedIP(serverID uint64, current model.GeoIP) model.GeoIP {
+	var last model.ServerEvent
+	if err := DB.Where("server_id = ? AND type = ?", serverID, model.ServerEventIPChanged).
+		Order("id DESC").Limit(1).Find(&last).Error; err != nil || last.ID == 0 {
+		return current
+	}
+
+	// 事件只记录变化的字段，未变化的沿用本次上报的值
+	ip := current
+	if change, ok := last.Change(model.IPFamilyV4); ok {
+		ip.IP.IPv4Addr, _ = change.New.(string)
+	}
+	if change, ok := last.Change(model.IPFamilyV6); ok {
+		ip.IP.IPv6Addr, _ = change.New.(string)
+	}
+	if change, ok := last.Change("country_code"); ok {
+		ip.CountryCode, _ = change.New.(string)
+	}
+	return ip
+}
+
+func notifyIPChange(server *model.Server, old, new model.GeoIP, changes []model.ServerEventChange) {
+	if !Conf.EnableIPChangeNotification || old.IP.Join() == "" {
+		return
+	}
+	ignored := Conf.IgnoredIPNotificationServerIDs[server.ID]
+	if !(Conf.Cover == model.ConfigCoverAll && !ignored) && !(Conf.Cover == model.ConfigCoverIgnoreAll && ignored) {
+		return
+	}
+	if family := Conf.IPChangeNotifyFamily; family != "" &&
+		!slices.ContainsFunc(changes, func(c model.ServerEventChange) bool { return c.Field == family }) {
+		return
+	}
+
+	gid := Conf.IPChangeNotificationGroupID
+	NotificationShared.SendNotification(gid,
+		fmt.Sprintf("[%s] %s, %s => %s",
+			NotificationShared.Lang(gid).T("IP Changed"), server.Name,
+			ipWithCountry(old), ipWithCountry(new)),
+		"")
+}
+
+func ipWithCountry(geoip model.GeoIP) string {
+	ip := IPDesensitize(geoip.IP.Join())
+	if geoip.CountryCode == "" {
+		return ip
+	}
+	return fmt.Sprintf("%s (%s)", ip, geoip.CountryCode)
+}
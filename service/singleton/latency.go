@@ -0,0 +1,274 @@
+package singleton
+
+import (
+	"cmp"
+	"log"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+type latencyKey struct {
+	serviceID uint64
+	serverID  uint64
+	start     int64
+}
+
+// latencyAggregator 按监测点增量汇总延迟分布，间隔结束后持久化
+type latencyAggregator struct {
+	mu      sync.Mutex
+	current map[latencyKey]*model.LatencyHistogram
+}
+
+func newLatencyAggregator() *latencyAggregator {
+	return &latencyAggregator{
+		current: make(map[latencyKey]*model.LatencyHistogram),
+	}
+}
+
+func latencyIntervalStart(t time.Time, interval int64) int64 {
+	return t.Unix() / interval * interval
+}
+
+func (la *latencyAggregator) Add(serviceID, serverID uint64, delay float32, at time.Time) {
+	k := latencyKey{serviceID, serverID, latencyIntervalStart(at, model.LatencyIntervalRaw)}
+
+	la.mu.Lock()
+	defer la.mu.Unlock()
+
+	h, ok := la.current[k]
+	if !ok {
+		h = model.NewLatencyHistogram()
+		la.current[k] = h
+	}
+	h.Add(delay)
+}
+
+// Flush 持久化已经结束的汇总间隔，force 为真时持久化全部数据
+func (la *latencyAggregator) Flush(force bool) {
+	now := latencyIntervalStart(time.Now(), model.LatencyIntervalRaw)
+
+	la.mu.Lock()
+	var rows []model.ServiceLatencySummary
+	for k, h := range la.current {
+		if !force && k.start >= now {
+			continue
+		}
+		rows = append(rows, model.ServiceLatencySummary{
+			ServiceID: k.serviceID,
+			ServerID:  k.serverID,
+			Start:     time.Unix(k.start, 0),
+			Interval:  model.LatencyIntervalRaw,
+			Histogram: h,
+		})
+		delete(la.current, k)
+	}
+	la.mu.Unlock()
+
+	if len(rows) == 0 {
+		return
+	}
+	if err := DB.Create(&rows).Error; err != nil {
+		log.Printf("NEZHA>> Failed to save service latency summaries: %v", err)
+	}
+}
+
+func (la *latencyAggregator) Delete(serviceID uint64) {
+	la.mu.Lock()
+	defer la.mu.Unlock()
+
+	for k := range la.current {
+		if k.serviceID == serviceID {
+			delete(la.current, k)
+		}
+	}
+}
+
+func (la *latencyAggregator) pending(serviceID uint64, from time.Time) []model.ServiceLatencySummary {
+	la.mu.Lock()
+	defer la.mu.Unlock()
+
+	var rows []model.ServiceLatencySummary
+	for k, h := range la.current {
+		if k.serviceID != serviceID || k.start < from.Unix() {
+			continue
+		}
+		hc := model.NewLatencyHistogram()
+		hc.Merge(h)
+		rows = append(rows, model.ServiceLatencySummary{
+			ServiceID: k.serviceID,
+			ServerID:  k.serverID,
+			Start:     time.Unix(k.start, 0),
+			Interval:  model.LatencyIntervalRaw,
+			Histogram: hc,
+		})
+	}
+	return rows
+}
+
+// QueryLatency 按分组计算服务在时间范围内各汇总间隔的百分位延迟
+// groupFn 返回空字符串时忽略该监测点的数据
+func (ss *ServiceSentinel) QueryLatency(serviceID uint64, from time.Time, percentile float64, groupBy string, groupFn func(serverID uint64) string) (*model.ServiceLatencyResponse, error) {
+	var rows []model.ServiceLatencySummary
+	if err := DB.Where("service_id = ? AND start >= ?", serviceID, from).Order("start").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	rows = append(rows, ss.latency.pending(serviceID, from)...)
+
+	type pointKey struct {
+		group string
+		start int64
+	}
+	merged := make(map[pointKey]*model.LatencyHistogram)
+	for _, r := range rows {
+		if r.Histogram == nil {
+			continue
+		}
+		group := groupFn(r.ServerID)
+		if group == "" {
+			continue
+		}
+		k := pointKey{group, r.Start.Unix()}
+		h, ok := merged[k]
+		if !ok {
+			h = model.NewLatencyHistogram()
+			merged[k] = h
+		}
+		h.Merge(r.Histogram)
+	}
+
+	series := make(map[string]*model.ServiceLatencySeries)
+	for k, h := range merged {
+		s, ok := series[k.group]
+		if !ok {
+			s = &model.ServiceLatencySeries{Group: k.group}
+			series[k.group] = s
+		}
+		s.Points = append(s.Points, model.ServiceLatencyPoint{
+			Time:  k.start * 1000,
+			Value: h.Percentile(percentile),
+			Count: h.Count,
+		})
+	}
+
+	resp := &model.ServiceLatencyResponse{
+		ServiceID:  serviceID,
+		GroupBy:    groupBy,
+		Percentile: percentile,
+		Series:     make([]model.ServiceLatencySeries, 0, len(series)),
+	}
+	for _, s := range series {
+		slices.SortFunc(s.Points, func(a, b model.ServiceLatencyPoint) int {
+			return cmp.Compare(a.Time, b.Time)
+		})
+		resp.Series = append(resp.Series, *s)
+	}
+	slices.SortFunc(resp.Series, func(a, b model.ServiceLatencySeries) int {
+		return cmp.Compare(a.Group, b.Group)
+	})
+	return resp, nil
+}
+
+// LatencyGroupKey 返回监测点在指定分组方式下的分组名
+func LatencyGroupKey(server *model.Server, groupBy string) string {
+	switch groupBy {
+	case model.LatencyGroupByRegion:
+		if server.GeoIP != nil && server.GeoIP.CountryCode != "" {
+			return server.GeoIP.CountryCode
+		}
+		return "unknown"
+	case model.LatencyGroupByNone:
+		return "all"
+	default:
+		return strconv.FormatUint(server.ID, 10)
+	}
+}
+
+// retainLatencySummaries 按监控记录的保留策略处理延迟汇总：原始间隔合并为小时粒度，小时合并为每日粒度，
+// 每日汇总超出保留期后删除，返回被合并或删除的行数
+func retainLatencySummaries(rawBefore, hourlyBefore, dailyBefore time.Time) (int64, error) {
+	total, err := downsampleLatencySummaries(model.LatencyIntervalRaw, model.LatencyIntervalHourly, rawBefore)
+	if err != nil {
+		return total, err
+	}
+	n, err := downsampleLatencySummaries(model.LatencyIntervalHourly, model.LatencyIntervalDaily, hourlyBefore)
+	total += n
+	if err != nil {
+		return total, err
+	}
+	if !dailyBefore.IsZero() {
+		n, err := deleteInBatches(&model.ServiceLatencySummary{}, "`interval` = ? AND start < ?", model.LatencyIntervalDaily, dailyBefore)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	_, err = deleteInBatches(&model.ServiceLatencySummary{}, "service_id NOT IN (SELECT `id` FROM services)")
+	return total, err
+}
+
+// countLatencyRetention 统计 retainLatencySummaries 将合并或删除的行数
+func countLatencyRetention(rawBefore, hourlyBefore, dailyBefore time.Time) (int64, error) {
+	var total int64
+	tx := DB.Model(&model.ServiceLatencySummary{}).Where("(`interval` = ? AND start < ?) OR (`interval` = ? AND start < ?)",
+		model.LatencyIntervalRaw, rawBefore, model.LatencyIntervalHourly, hourlyBefore)
+	if !dailyBefore.IsZero() {
+		tx = tx.Or("`interval` = ? AND start < ?", model.LatencyIntervalDaily, dailyBefore)
+	}
+	err := tx.Count(&total).Error
+	return total, err
+}
+
+// downsampleLatencySummaries 将 before 之前 from 粒度的汇总合并为 to 粒度，每批在一个事务中写入合并结果并删除原汇总，
+// 同一时段跨批次时会留下多行，查询时按时段合并
+func downsampleLatencySummaries(from, to uint32, before time.Time) (int64, error) {
+	var total int64
+	for {
+		var rows []model.ServiceLatencySummary
+		if err := DB.Where("`interval` = ? AND start < ?", from, before).Order("id").Limit(retentionBatchSize).Find(&rows).Error; err != nil {
+			return total, err
+		}
+		if len(rows) == 0 {
+			return total, nil
+		}
+
+		merged := make(map[latencyKey]*model.LatencyHistogram)
+		ids := make([]uint64, 0, len(rows))
+		for _, r := range rows {
+			ids = append(ids, r.ID)
+			k := latencyKey{r.ServiceID, r.ServerID, latencyIntervalStart(r.Start, int64(to))}
+			h, ok := merged[k]
+			if !ok {
+				h = model.NewLatencyHistogram()
+				merged[k] = h
+			}
+			h.Merge(r.Histogram)
+		}
+
+		downsampled := make([]model.ServiceLatencySummary, 0, len(merged))
+		for k, h := range merged {
+			downsampled = append(downsampled, model.ServiceLatencySummary{
+				ServiceID: k.serviceID,
+				ServerID:  k.serverID,
+				Start:     time.Unix(k.start, 0),
+				Interval:  to,
+				Histogram: h,
+			})
+		}
+
+		if err := DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.CreateInBatches(downsampled, 200).Error; err != nil {
+				return err
+			}
+			return tx.Unscoped().Delete(&model.ServiceLatencySummary{}, "id IN (?)", ids).Error
+		}); err != nil {
+			return total, err
+		}
+		total += int64(len(rows))
+	}
+}
@@ -0,0 +1,158 @@
+package singleton
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+// LoginGuardClass 按 IP 与账号统计登录失败次数，数据保存在内存中并定期写入数据库，
+// 重启后不会清空计数。集群中各节点分别计数，只有主节点写入数据库，避免节点之间互相覆盖
+type LoginGuardClass struct {
+	mu       sync.Mutex
+	failures map[string]*model.LoginFailure
+	dirty    map[string]struct{}
+}
+
+var LoginGuardShared *LoginGuardClass
+
+func NewLoginGuardClass() *LoginGuardClass {
+	g := &LoginGuardClass{
+		failures: make(map[string]*model.LoginFailure),
+		dirty:    make(map[string]struct{}),
+	}
+	var list []*model.LoginFailure
+	if err := DB.Find(&list).Error; err != nil {
+		log.Printf("NEZHA>> Failed to load login failures: %v", err)
+	}
+	for _, f := range list {
+		g.failures[f.Key] = f
+	}
+	return g
+}
+
+// Check 返回登录前需要等待的时间，IP 或账号任一受限时拒绝登录
+func (g *LoginGuardClass) Check(ip string, userID uint64) time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+	var wait time.Duration
+	for _, key := range loginGuardKeys(ip, userID) {
+		if f, ok := g.failures[key]; ok {
+			wait = max(wait, f.RetryAfter(now, &Conf.LoginGuard))
+		}
+	}
+	return wait
+}
+
+// Fail 记录一次登录失败，IP 达到阈值时加入 WAF，账号达到阈值时锁定并通知账号所有者
+func (g *LoginGuardClass) Fail(ip string, userID uint64) {
+	conf := Conf.LoginGuard
+	now := time.Now()
+
+	g.mu.Lock()
+	var ipCount int
+	var locked bool
+	for _, key := range loginGuardKeys(ip, userID) {
+		f, ok := g.failures[key]
+		if !ok {
+			f = &model.LoginFailure{Key: key}
+			g.failures[key] = f
+		}
+		f.Fail(now, conf.WindowDuration())
+		g.dirty[key] = struct{}{}
+
+		if key == model.LoginFailureIPKey(ip) {
+			ipCount = f.Count
+		} else if conf.LockAfter > 0 && f.Count >= conf.LockAfter && !now.Before(f.LockedUntil) {
+			f.LockedUntil = now.Add(time.Duration(conf.LockDuration) * time.Second)
+			f.Count = 0
+			f.WindowStart = now
+			locked = true
+		}
+	}
+	g.mu.Unlock()
+
+	if conf.BlockAfter > 0 && ipCount >= conf.BlockAfter {
+		blockID := int64(userID)
+		if userID == 0 {
+			blockID = model.BlockIDUnknownUser
+		}
+		if err := model.BlockIP(DB, ip, model.WAFBlockReasonTypeLoginFail, blockID); err != nil {
+			log.Printf("NEZHA>> Failed to block %s after login failures: %v", ip, err)
+		}
+	}
+	if locked {
+		log.Printf("NEZHA>> User %d locked after %d failed login attempts", userID, conf.LockAfter)
+		go NotificationShared.SendUserNotification(userID, fmt.Sprintf("[%s] %s", Localizer.T("Account Locked"),
+			Localizer.Tf("Your account was locked for %d minutes after %d failed login attempts, the last one from %s",
+				conf.LockDuration/60, conf.LockAfter, IPDesensitize(ip))))
+	}
+}
+
+// Succeed 登录成功后清除 IP 与账号的失败记录
+func (g *LoginGuardClass) Succeed(ip string, userID uint64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for _, key := range loginGuardKeys(ip, userID) {
+		if _, ok := g.failures[key]; ok {
+			delete(g.failures, key)
+			g.dirty[key] = struct{}{}
+		}
+	}
+}
+
+// Flush 将变化的记录写入数据库，并清理已过期的记录，非主节点只清理内存中的记录
+func (g *LoginGuardClass) Flush() {
+	now := time.Now()
+	window := Conf.LoginGuard.WindowDuration()
+
+	g.mu.Lock()
+	var save []model.LoginFailure
+	var remove []string
+	for key, f := range g.failures {
+		if f.Expired(now, window) {
+			delete(g.failures, key)
+			g.dirty[key] = struct{}{}
+		}
+	}
+	for key := range g.dirty {
+		if f, ok := g.failures[key]; ok {
+			save = append(save, *f)
+		} else {
+			remove = append(remove, key)
+		}
+	}
+	clear(g.dirty)
+	g.mu.Unlock()
+
+	if !ClusterShared.IsLeader() {
+		return
+	}
+	if len(save) > 0 {
+		if err := DB.Save(&save).Error; err != nil {
+			log.Printf("NEZHA>> Failed to save login failures: %v", err)
+		}
+	}
+	if len(remove) > 0 {
+		if err := DB.Delete(&model.LoginFailure{}, "`key` IN (?)", remove).Error; err != nil {
+			log.Printf("NEZHA>> Failed to delete login failures: %v", err)
+		}
+	}
+}
+
+func loginGuardKeys(ip string, userID uint64) []string {
+	var keys []string
+	if ip != "" {
+		keys = append(keys, model.LoginFailureIPKey(ip))
+	}
+	if userID != 0 {
+		keys = append(keys, model.LoginFailureUserKey(userID))
+	}
+	return keys
+}
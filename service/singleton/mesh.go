@@ -0,0 +1,183 @@
+package singleton
+
+import (
+	"cmp"
+	"log"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/nezhahq/nezha/model"
+	pb "github.com/nezhahq/nezha/proto"
+)
+
+// 节点间延迟测试的历史记录保留天数
+const meshHistoryDays = 7
+
+type meshPair struct {
+	source, target uint64
+}
+
+// MeshClass 定期让参与的节点互相 Ping，结果保存为节点间的延迟矩阵
+type MeshClass struct {
+	cycle atomic.Int64
+}
+
+var MeshShared *MeshClass
+
+func NewMeshClass() *MeshClass {
+	c := &MeshClass{}
+	go c.worker()
+	return c
+}
+
+func (c *MeshClass) worker() {
+	for {
+		time.Sleep(time.Duration(max(Conf.MeshPingInterval, 10)) * time.Second)
+		c.dispatch()
+	}
+}
+
+func (c *MeshClass) dispatch() {
+	nodes := meshNodes()
+	if len(nodes) < 2 {
+		return
+	}
+	cycle := int(c.cycle.Add(1))
+	for _, source := range nodes {
+		if source.TaskStream == nil || !ClusterShared.OwnsServer(source.ID) {
+			continue
+		}
+		for _, target := range meshTargets(nodes, source, cycle, Conf.MeshPingFanOut) {
+			if err := source.TaskStream.Send(&pb.Task{
+				Id:   model.MeshTaskIDFlag | target.ID,
+				Type: model.TaskTypeICMPPing,
+				Data: meshTargetIP(target),
+			}); err != nil {
+				log.Printf("NEZHA>> Failed to dispatch mesh ping from server %d: %v", source.ID, err)
+				break
+			}
+		}
+	}
+}
+
+// meshNodes 参与延迟测试的服务器，按 ID 排序
+func meshNodes() []*model.Server {
+	var nodes []*model.Server
+	for _, s := range ServerShared.Range {
+		if s.EnableMeshPing {
+			nodes = append(nodes, s)
+		}
+	}
+	slices.SortFunc(nodes, func(a, b *model.Server) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return nodes
+}
+
+func meshTargetIP(s *model.Server) string {
+	if s.GeoIP == nil {
+		return ""
+	}
+	if s.GeoIP.IP.IPv4Addr != "" {
+		return s.GeoIP.IP.IPv4Addr
+	}
+	return s.GeoIP.IP.IPv6Addr
+}
+
+// meshTargets 选出 source 本轮需要测试的节点，每轮最多 fanOut 个并依次轮换，
+// 避免节点较多时每轮的测试量按节点数的平方增长。
+// 只测试 source 所有者有权访问的节点，不会把其他用户服务器的 IP 下发给 Agent
+func meshTargets(nodes []*model.Server, source *model.Server, cycle, fanOut int) []*model.Server {
+	admin := isAdminUser(source.UserID)
+	var candidates []*model.Server
+	for _, n := range nodes {
+		if n.ID != source.ID && meshTargetIP(n) != "" && (admin || n.UserID == source.UserID) {
+			candidates = append(candidates, n)
+		}
+	}
+	if len(candidates) <= fanOut {
+		return candidates
+	}
+
+	targets := make([]*model.Server, 0, fanOut)
+	start := cycle * fanOut % len(candidates)
+	for i := range fanOut {
+		targets = append(targets, candidates[(start+i)%len(candidates)])
+	}
+	return targets
+}
+
+// meshWindow 所有节点对至少被测试一次所需的时间
+func meshWindow(nodes int) time.Duration {
+	fanOut := max(Conf.MeshPingFanOut, 1)
+	cycles := (nodes-1+fanOut-1)/fanOut + 1
+	return time.Duration(cycles*max(Conf.MeshPingInterval, 10)) * time.Second
+}
+
+// Report 保存 Agent 返回的节点间延迟测试结果，Ping 失败的节点对记录为不可达
+func (c *MeshClass) Report(source uint64, r *pb.TaskResult) {
+	l := &model.MeshLatency{
+		CreatedAt: time.Now(),
+		SourceID:  source,
+		TargetID:  r.GetId() &^ model.MeshTaskIDFlag,
+		Reachable: r.GetSuccessful(),
+	}
+	if l.Reachable {
+		l.Delay = r.GetDelay()
+		if icmp, ok := model.ParseICMPTaskResult(r.GetData()); ok {
+			l.Loss = icmp.Loss
+		}
+	}
+	if err := DB.Create(l).Error; err != nil {
+		log.Printf("NEZHA>> Failed to save mesh latency: %v", err)
+	}
+}
+
+// Matrix 返回 filter 通过的节点及其之间的最新测试结果
+func (c *MeshClass) Matrix(filter func(*model.Server) bool) (*model.MeshMatrix, error) {
+	matrix := &model.MeshMatrix{Nodes: []model.MeshNode{}, Links: []*model.MeshLatency{}}
+	nodes := meshNodes()
+	included := make(map[uint64]bool)
+	for _, s := range nodes {
+		if filter(s) {
+			included[s.ID] = true
+			matrix.Nodes = append(matrix.Nodes, model.MeshNode{ID: s.ID, Name: s.Name})
+		}
+	}
+	if len(matrix.Nodes) < 2 {
+		return matrix, nil
+	}
+
+	var rows []*model.MeshLatency
+	if err := DB.Where("created_at > ?", time.Now().Add(-meshWindow(len(nodes)))).
+		Order("id").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	latest := make(map[meshPair]*model.MeshLatency)
+	for _, l := range rows {
+		if included[l.SourceID] && included[l.TargetID] {
+			latest[meshPair{l.SourceID, l.TargetID}] = l
+		}
+	}
+	for _, l := range latest {
+		matrix.Links = append(matrix.Links, l)
+	}
+	slices.SortFunc(matrix.Links, func(a, b *model.MeshLatency) int {
+		return cmp.Or(cmp.Compare(a.SourceID, b.SourceID), cmp.Compare(a.TargetID, b.TargetID))
+	})
+	return matrix, nil
+}
+
+// History 返回两个节点间在时间范围内的测试记录
+func (c *MeshClass) History(source, target uint64, from, to time.Time) ([]*model.MeshLatency, error) {
+	var rows []*model.MeshLatency
+	err := DB.Where("source_id = ? AND target_id = ? AND created_at >= ? AND created_at < ?", source, target, from, to).
+		Order("id").Find(&rows).Error
+	return rows, err
+}
+
+func cleanMeshLatency() {
+	DB.Unscoped().Delete(&model.MeshLatency{}, "created_at < ? OR source_id NOT IN (SELECT `id` FROM servers) OR target_id NOT IN (SELECT `id` FROM servers)",
+		time.Now().AddDate(0, 0, -meshHistoryDays))
+}
@@ -0,0 +1,46 @@
+package singleton
+
+import (
+	"testing"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+func TestMeshTargets(t *testing.T) {
+	var nodes []*model.Server
+	for id := uint64(1); id <= 7; id++ {
+		nodes = append(nodes, &model.Server{Common: model.Common{ID: id}, GeoIP: &model.GeoIP{IP: model.IP{IPv4Addr: "192.0.2.1"}}})
+	}
+	// 没有公网 IP 的节点不作为目标
+	nodes[6].GeoIP = &model.GeoIP{}
+
+	seen := make(map[uint64]bool)
+	for cycle := range 3 {
+		targets := meshTargets(nodes, nodes[0], cycle, 2)
+		if len(targets) != 2 {
+			t.Fatalf("cycle %d: expected 2 targets, got %d", cycle, len(targets))
+		}
+		for _, s := range targets {
+			if s.ID == 1 || s.ID == 7 {
+				t.Fatalf("cycle %d: unexpected target %d", cycle, s.ID)
+			}
+			seen[s.ID] = true
+		}
+	}
+	if len(seen) != 5 {
+		t.Fatalf("expected all 5 candidates to be covered in 3 cycles, got %v", seen)
+	}
+
+	if targets := meshTargets(nodes[:3], nodes[0], 0, 5); len(targets) != 2 {
+		t.Fatalf("expected all candidates when fan-out is larger, got %d", len(targets))
+	}
+
+	// 其他用户的服务器不作为目标
+	nodes[1].UserID, nodes[2].UserID = 2, 2
+	if targets := meshTargets(nodes[:3], nodes[0], 0, 5); len(targets) != 0 {
+		t.Fatalf("expected servers of other users to be skipped, got %d", len(targets))
+	}
+	if targets := meshTargets(nodes[:3], nodes[1], 0, 5); len(targets) != 1 || targets[0].ID != 3 {
+		t.Fatalf("expected only the server of the same user, got %v", targets)
+	}
+}
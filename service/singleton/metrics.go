@@ -0,0 +1,277 @@
+package singleton
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+// 指标的缓存时间，频繁抓取时直接返回上次的结果
+const metricsCacheTTL = 5 * time.Second
+
+var (
+	metricsReports              atomic.Uint64
+	metricsNotificationFailures atomic.Uint64
+
+	metricsMu    sync.Mutex
+	metricsCache = make(map[MetricsScope]*metricsCacheEntry)
+)
+
+// MetricsScope 指标导出的可见范围
+type MetricsScope struct {
+	Guest  bool   // 不包含对游客隐藏的内容
+	UserID uint64 // 不为 0 时只包含该用户的服务器与服务
+}
+
+type metricsCacheEntry struct {
+	data []byte
+	at   time.Time
+}
+
+// CountReport 记录一次 Agent 状态上报
+func CountReport() {
+	metricsReports.Add(1)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+type metricsWriter struct {
+	bytes.Buffer
+}
+
+func (w *metricsWriter) family(name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// sample 写入一个样本，labels 为交替的标签名与值
+func (w *metricsWriter) sample(name string, value float64, labels ...string) {
+	w.WriteString(name)
+	if len(labels) > 0 {
+		w.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, `%s="%s"`, labels[i], labelEscaper.Replace(labels[i+1]))
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+	w.WriteByte('\n')
+}
+
+type serverMetric struct {
+	name  string
+	help  string
+	value func(s *model.Server) float64
+}
+
+var serverMetrics = []serverMetric{
+	{"nezha_server_online", "Whether the agent reported in the last 10 seconds.", func(s *model.Server) float64 {
+		return boolToFloat(time.Since(s.LastActive) < s.OnlineTimeout(10*time.Second))
+	}},
+	{"nezha_server_last_active_timestamp_seconds", "Time of the last report from the agent.", func(s *model.Server) float64 {
+		if s.LastActive.IsZero() {
+			return 0
+		}
+		return float64(s.LastActive.Unix())
+	}},
+	{"nezha_server_cpu_usage_percent", "CPU usage in percent.", func(s *model.Server) float64 { return s.State.CPU }},
+	{"nezha_server_memory_used_bytes", "Used memory in bytes.", func(s *model.Server) float64 { return float64(s.State.MemUsed) }},
+	{"nezha_server_memory_total_bytes", "Total memory in bytes.", func(s *model.Server) float64 { return float64(s.Host.MemTotal) }},
+	{"nezha_server_swap_used_bytes", "Used swap in bytes.", func(s *model.Server) float64 { return float64(s.State.SwapUsed) }},
+	{"nezha_server_swap_total_bytes", "Total swap in bytes.", func(s *model.Server) float64 { return float64(s.Host.SwapTotal) }},
+	{"nezha_server_disk_used_bytes", "Used disk space in bytes.", func(s *model.Server) float64 { return float64(s.State.DiskUsed) }},
+	{"nezha_server_disk_total_bytes", "Total disk space in bytes.", func(s *model.Server) float64 { return float64(s.Host.DiskTotal) }},
+	{"nezha_server_network_receive_bytes_per_second", "Inbound network speed.", func(s *model.Server) float64 { return float64(s.State.NetInSpeed) }},
+	{"nezha_server_network_transmit_bytes_per_second", "Outbound network speed.", func(s *model.Server) float64 { return float64(s.State.NetOutSpeed) }},
+	{"nezha_server_network_receive_bytes", "Inbound traffic reported by the agent.", func(s *model.Server) float64 { return float64(s.State.NetInTransfer) }},
+	{"nezha_server_network_transmit_bytes", "Outbound traffic reported by the agent.", func(s *model.Server) float64 { return float64(s.State.NetOutTransfer) }},
+	{"nezha_server_load1", "1 minute load average.", func(s *model.Server) float64 { return s.State.Load1 }},
+	{"nezha_server_load5", "5 minute load average.", func(s *model.Server) float64 { return s.State.Load5 }},
+	{"nezha_server_load15", "15 minute load average.", func(s *model.Server) float64 { return s.State.Load15 }},
+	{"nezha_server_tcp_connections", "Number of TCP connections.", func(s *model.Server) float64 { return float64(s.State.TcpConnCount) }},
+	{"nezha_server_udp_connections", "Number of UDP connections.", func(s *model.Server) float64 { return float64(s.State.UdpConnCount) }},
+	{"nezha_server_processes", "Number of processes.", func(s *model.Server) float64 { return float64(s.State.ProcessCount) }},
+	{"nezha_server_uptime_seconds", "Uptime of the server.", func(s *model.Server) float64 { return float64(s.State.Uptime) }},
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// RenderMetrics 以 Prometheus 文本格式导出 scope 范围内服务器与服务的状态
+func RenderMetrics(scope MetricsScope) []byte {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+
+	if cache, ok := metricsCache[scope]; ok && time.Since(cache.at) < metricsCacheTTL {
+		return cache.data
+	}
+	// 按用户缓存，清理过期的结果避免缓存随用户数增长
+	for k, cache := range metricsCache {
+		if time.Since(cache.at) >= metricsCacheTTL {
+			delete(metricsCache, k)
+		}
+	}
+
+	var w metricsWriter
+	renderServerMetrics(&w, scope)
+	renderServiceMetrics(&w, scope)
+	renderDashboardMetrics(&w)
+
+	metricsCache[scope] = &metricsCacheEntry{data: w.Bytes(), at: time.Now()}
+	return metricsCache[scope].data
+}
+
+// ServerGroupNames 返回服务器所属分组名称，多个分组以逗号分隔
+func ServerGroupNames() map[uint64]string {
+	var groups []model.ServerGroup
+	var sgs []model.ServerGroupServer
+	if err := DB.Find(&groups).Error; err != nil {
+		log.Printf("NEZHA>> Failed to load server groups for metrics: %v", err)
+		return nil
+	}
+	if err := DB.Find(&sgs).Error; err != nil {
+		log.Printf("NEZHA>> Failed to load server groups for metrics: %v", err)
+		return nil
+	}
+
+	groupName := make(map[uint64]string, len(groups))
+	for _, g := range groups {
+		groupName[g.ID] = g.Name
+	}
+	serverGroups := make(map[uint64][]string)
+	for _, sg := range sgs {
+		if name, ok := groupName[sg.ServerGroupId]; ok {
+			serverGroups[sg.ServerId] = append(serverGroups[sg.ServerId], name)
+		}
+	}
+
+	names := make(map[uint64]string, len(serverGroups))
+	for id, list := range serverGroups {
+		slices.Sort(list)
+		names[id] = strings.Join(list, ",")
+	}
+	return names
+}
+
+func renderServerMetrics(w *metricsWriter, scope MetricsScope) {
+	var servers []*model.Server
+	if scope.Guest {
+		servers = ServerShared.GetSortedListForGuest()
+	} else {
+		servers = ServerShared.GetSortedList()
+	}
+	if scope.UserID != 0 {
+		servers = slices.DeleteFunc(servers, func(s *model.Server) bool {
+			return s.UserID != scope.UserID
+		})
+	}
+	groups := ServerGroupNames()
+
+	labels := make([][]string, len(servers))
+	for i, s := range servers {
+		var country string
+		if s.GeoIP != nil {
+			country = s.GeoIP.CountryCode
+		}
+		labels[i] = []string{
+			"id", strconv.FormatUint(s.ID, 10),
+			"name", s.Name,
+			"group", groups[s.ID],
+			"country", country,
+		}
+	}
+
+	for _, m := range serverMetrics {
+		w.family(m.name, "gauge", m.help)
+		for i, s := range servers {
+			if s.Host == nil || s.State == nil {
+				continue
+			}
+			w.sample(m.name, m.value(s), labels[i]...)
+		}
+	}
+
+	w.family("nezha_server_rejected_reports_total", "counter", "Agent reports dropped by validation.")
+	for i, s := range servers {
+		w.sample("nezha_server_rejected_reports_total", float64(ReportRejections(s.ID)), labels[i]...)
+	}
+}
+
+func renderServiceMetrics(w *metricsWriter, scope MetricsScope) {
+	services := ServiceSentinelShared.GetSortedList()
+	services = slices.DeleteFunc(services, func(s *model.Service) bool {
+		return (scope.Guest && !s.EnableShowInService) || (scope.UserID != 0 && s.UserID != scope.UserID)
+	})
+
+	w.family("nezha_service_up", "gauge", "Whether the service is available in the last 15 minutes.")
+	for _, s := range services {
+		status := ServiceSentinelShared.CurrentStatus(s.ID)
+		if status == StatusNoData {
+			continue
+		}
+		up := status == StatusGood || status == StatusLowAvailability
+		w.sample("nezha_service_up", boolToFloat(up), "id", strconv.FormatUint(s.ID, 10), "name", s.Name)
+	}
+
+	today := make(map[uint64]_TodayStatsOfService, len(services))
+	for _, s := range services {
+		if stats, ok := ServiceSentinelShared.TodayStats(s.ID); ok {
+			today[s.ID] = stats
+		}
+	}
+
+	w.family("nezha_service_latency_milliseconds", "gauge", "Average latency of the service today.")
+	for _, s := range services {
+		if stats, ok := today[s.ID]; ok {
+			w.sample("nezha_service_latency_milliseconds", float64(stats.Delay), "id", strconv.FormatUint(s.ID, 10), "name", s.Name)
+		}
+	}
+
+	w.family("nezha_service_checks_today", "gauge", "Number of checks of the service today.")
+	for _, s := range services {
+		if stats, ok := today[s.ID]; ok {
+			id := strconv.FormatUint(s.ID, 10)
+			w.sample("nezha_service_checks_today", float64(stats.Up), "id", id, "name", s.Name, "result", "up")
+			w.sample("nezha_service_checks_today", float64(stats.Down), "id", id, "name", s.Name, "result", "down")
+		}
+	}
+}
+
+func renderDashboardMetrics(w *metricsWriter) {
+	OnlineUserMapLock.Lock()
+	onlineUsers := len(OnlineUserMap)
+	OnlineUserMapLock.Unlock()
+
+	w.family("nezha_dashboard_info", "gauge", "Dashboard version.")
+	w.sample("nezha_dashboard_info", 1, "version", Version)
+	w.family("nezha_dashboard_start_time_seconds", "gauge", "Start time of the dashboard.")
+	w.sample("nezha_dashboard_start_time_seconds", float64(DashboardBootTime))
+	w.family("nezha_dashboard_online_users", "gauge", "Number of connected websocket users.")
+	w.sample("nezha_dashboard_online_users", float64(onlineUsers))
+	w.family("nezha_dashboard_agent_reports_total", "counter", "Number of state reports received from agents.")
+	w.sample("nezha_dashboard_agent_reports_total", float64(metricsReports.Load()))
+	w.family("nezha_dashboard_notification_failures_total", "counter", "Number of failed notification deliveries.")
+	w.sample("nezha_dashboard_notification_failures_total", float64(metricsNotificationFailures.Load()))
+
+	hits, misses, size := QueryCacheShared.Stats()
+	w.family("nezha_dashboard_query_cache_requests_total", "counter", "Number of aggregate query cache lookups.")
+	w.sample("nezha_dashboard_query_cache_requests_total", float64(hits), "result", "hit")
+	w.sample("nezha_dashboard_query_cache_requests_total", float64(misses), "result", "miss")
+	w.family("nezha_dashboard_query_cache_entries", "gauge", "Number of cached aggregate query results.")
+	w.sample("nezha_dashboard_query_cache_entries", float64(size))
+}
@@ -0,0 +1,502 @@
+package singleton
+
+import (
+	"log"
+
+	"gorm.io/gorm"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/migrate"
+)
+
+// migrations 数据库结构的变更记录，只能在末尾追加，已发布的迁移不能修改
+// 新增表或字段时需要同时添加迁移，不再依赖启动时的 AutoMigrate
+var migrations = []migrate.Migration{
+	{
+		// 引入迁移之前由 AutoMigrate 维护的结构，对已有数据库只补齐缺少的表与字段
+		Version: 1,
+		Name:    "baseline",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(model.Server{}, model.User{}, model.ServerGroup{}, model.NotificationGroup{},
+				model.Notification{}, model.AlertRule{}, model.Service{}, model.NotificationGroupNotification{},
+				model.ServiceHistory{}, model.Cron{}, model.Transfer{}, model.ServerGroupServer{},
+				model.NAT{}, model.DDNSProfile{}, model.WAF{}, model.Oauth2Bind{}, model.TransferDaily{},
+				model.ServiceCert{}, model.ServiceLatencySummary{}, model.StatusPageSection{}, model.Incident{},
+				model.IncidentUpdate{}, model.CronExecution{}, model.CronRun{}, model.CronStatDaily{},
+				model.CommandPolicy{}, model.DDNSHistory{}, model.NATStatDaily{}, model.APIToken{})
+		},
+	},
+	createTableMigration(2, "create_audit_logs", &model.AuditLog{}),
+	createTableMigration(3, "create_service_history_rollups", &model.ServiceHistoryRollup{}),
+	createTableMigration(4, "create_terminal_recordings", &model.TerminalRecording{}),
+	createTableMigration(5, "create_mesh_latencies", &model.MeshLatency{}),
+	{
+		Version: 6,
+		Name:    "add_server_enable_mesh_ping",
+		Up: func(tx *gorm.DB) error {
+			if tx.Migrator().HasColumn(&model.Server{}, "EnableMeshPing") {
+				return nil
+			}
+			return tx.Migrator().AddColumn(&model.Server{}, "EnableMeshPing")
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropColumn(&model.Server{}, "EnableMeshPing")
+		},
+	},
+	createTableMigration(7, "create_push_files", &model.PushFile{}),
+	{
+		Version: 8,
+		Name:    "add_cron_push_file",
+		Up: func(tx *gorm.DB) error {
+			for _, field := range []string{"PushFileID", "PushFilePath", "PushFileMode"} {
+				if tx.Migrator().HasColumn(&model.Cron{}, field) {
+					continue
+				}
+				if err := tx.Migrator().AddColumn(&model.Cron{}, field); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, field := range []string{"PushFileID", "PushFilePath", "PushFileMode"} {
+				if err := tx.Migrator().DropColumn(&model.Cron{}, field); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	createTableMigration(9, "create_agent_rollouts", &model.AgentRollout{}),
+	createTableMigration(10, "create_waf_blocks", &model.WAFBlock{}),
+	createTableMigration(11, "create_waf_rules", &model.WAFRule{}),
+	{
+		// 已有的自动封禁按原来的计算方式设置过期时间，最长一天
+		Version: 12,
+		Name:    "add_waf_expires_at",
+		Up: func(tx *gorm.DB) error {
+			if !tx.Migrator().HasColumn(&model.WAF{}, "ExpiresAt") {
+				if err := tx.Migrator().AddColumn(&model.WAF{}, "ExpiresAt"); err != nil {
+					return err
+				}
+				if err := tx.Migrator().CreateIndex(&model.WAF{}, "ExpiresAt"); err != nil {
+					return err
+				}
+			}
+			return tx.Exec("UPDATE nz_waf SET expires_at = block_timestamp + MIN(MAX(count * count * count * count, 3), 86400) WHERE block_reason <> ?",
+				model.WAFBlockReasonTypeManual).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropColumn(&model.WAF{}, "ExpiresAt")
+		},
+	},
+	createTableMigration(13, "create_login_failures", &model.LoginFailure{}),
+	createTableMigration(14, "create_sessions", &model.Session{}),
+	createTableMigration(15, "create_admin_bypass_uses", &model.AdminBypassUse{}),
+	{
+		Version: 16,
+		Name:    "add_notification_telegram",
+		Up: func(tx *gorm.DB) error {
+			for _, field := range notificationTelegramFields {
+				if tx.Migrator().HasColumn(&model.Notification{}, field) {
+					continue
+				}
+				if err := tx.Migrator().AddColumn(&model.Notification{}, field); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, field := range notificationTelegramFields {
+				if err := tx.Migrator().DropColumn(&model.Notification{}, field); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	createTableMigration(17, "create_annotations", &model.Annotation{}),
+	{
+		Version: 18,
+		Name:    "add_server_external_id",
+		Up: func(tx *gorm.DB) error {
+			if tx.Migrator().HasColumn(&model.Server{}, "ExternalID") {
+				return nil
+			}
+			if err := tx.Migrator().AddColumn(&model.Server{}, "ExternalID"); err != nil {
+				return err
+			}
+			return tx.Migrator().CreateIndex(&model.Server{}, "ExternalID")
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropColumn(&model.Server{}, "ExternalID")
+		},
+	},
+	createTableMigration(19, "create_sla_report_configs", &model.SLAReportConfig{}),
+	createTableMigration(20, "create_sla_reports", &model.SLAReport{}),
+	{
+		Version: 21,
+		Name:    "add_user_language",
+		Up: func(tx *gorm.DB) error {
+			if tx.Migrator().HasColumn(&model.User{}, "Language") {
+				return nil
+			}
+			return tx.Migrator().AddColumn(&model.User{}, "Language")
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropColumn(&model.User{}, "Language")
+		},
+	},
+	{
+		Version: 22,
+		Name:    "add_notification_group_language",
+		Up: func(tx *gorm.DB) error {
+			if tx.Migrator().HasColumn(&model.NotificationGroup{}, "Language") {
+				return nil
+			}
+			return tx.Migrator().AddColumn(&model.NotificationGroup{}, "Language")
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropColumn(&model.NotificationGroup{}, "Language")
+		},
+	},
+	{
+		Version: 23,
+		Name:    "add_user_public_slug",
+		Up: func(tx *gorm.DB) error {
+			if tx.Migrator().HasColumn(&model.User{}, "PublicSlug") {
+				return nil
+			}
+			if err := tx.Migrator().AddColumn(&model.User{}, "PublicSlug"); err != nil {
+				return err
+			}
+			return tx.Migrator().CreateIndex(&model.User{}, "PublicSlug")
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropColumn(&model.User{}, "PublicSlug")
+		},
+	},
+	{
+		Version: 24,
+		Name:    "add_server_capabilities",
+		Up: func(tx *gorm.DB) error {
+			if tx.Migrator().HasColumn(&model.Server{}, "CapabilitiesRaw") {
+				return nil
+			}
+			return tx.Migrator().AddColumn(&model.Server{}, "CapabilitiesRaw")
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropColumn(&model.Server{}, "CapabilitiesRaw")
+		},
+	},
+	{
+		Version: 25,
+		Name:    "add_notification_disable_dedup",
+		Up: func(tx *gorm.DB) error {
+			if tx.Migrator().HasColumn(&model.Notification{}, "DisableDedup") {
+				return nil
+			}
+			return tx.Migrator().AddColumn(&model.Notification{}, "DisableDedup")
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropColumn(&model.Notification{}, "DisableDedup")
+		},
+	},
+	createTableMigration(26, "create_settings", &model.SettingOverride{}),
+	createTableMigration(27, "create_server_events", &model.ServerEvent{}),
+	createTableMigration(28, "create_branding_assets", &model.BrandingAsset{}),
+	{
+		Version: 29,
+		Name:    "add_notification_group_active_hours",
+		Up: func(tx *gorm.DB) error {
+			if tx.Migrator().HasColumn(&model.NotificationGroup{}, "ActiveHoursRaw") {
+				return nil
+			}
+			return tx.Migrator().AddColumn(&model.NotificationGroup{}, "ActiveHoursRaw")
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropColumn(&model.NotificationGroup{}, "ActiveHoursRaw")
+		},
+	},
+	createTableMigration(30, "create_transfer_baselines", &model.TransferBaseline{}),
+	{
+		Version: 31,
+		Name:    "add_server_wake_schedule",
+		Up: func(tx *gorm.DB) error {
+			if tx.Migrator().HasColumn(&model.Server{}, "WakeScheduleRaw") {
+				return nil
+			}
+			return tx.Migrator().AddColumn(&model.Server{}, "WakeScheduleRaw")
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropColumn(&model.Server{}, "WakeScheduleRaw")
+		},
+	},
+	{
+		Version: 32,
+		Name:    "add_service_http_config",
+		Up: func(tx *gorm.DB) error {
+			if tx.Migrator().HasColumn(&model.Service{}, "HTTPConfigRaw") {
+				return nil
+			}
+			return tx.Migrator().AddColumn(&model.Service{}, "HTTPConfigRaw")
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropColumn(&model.Service{}, "HTTPConfigRaw")
+		},
+	},
+	{
+		Version: 33,
+		Name:    "add_service_quorum",
+		Up: func(tx *gorm.DB) error {
+			for _, field := range serviceQuorumFields {
+				if tx.Migrator().HasColumn(&model.Service{}, field) {
+					continue
+				}
+				if err := tx.Migrator().AddColumn(&model.Service{}, field); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, field := range serviceQuorumFields {
+				if err := tx.Migrator().DropColumn(&model.Service{}, field); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	createTableMigration(34, "create_access_grants", &model.AccessGrant{}),
+	{
+		Version: 35,
+		Name:    "add_user_display_preferences",
+		Up: func(tx *gorm.DB) error {
+			if tx.Migrator().HasColumn(&model.User{}, "DisplayPreferencesRaw") {
+				return nil
+			}
+			return tx.Migrator().AddColumn(&model.User{}, "DisplayPreferencesRaw")
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropColumn(&model.User{}, "DisplayPreferencesRaw")
+		},
+	},
+	{
+		Version: 36,
+		Name:    "add_server_heartbeat_mode",
+		Up: func(tx *gorm.DB) error {
+			for _, field := range serverHeartbeatFields {
+				if tx.Migrator().HasColumn(&model.Server{}, field) {
+					continue
+				}
+				if err := tx.Migrator().AddColumn(&model.Server{}, field); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, field := range serverHeartbeatFields {
+				if err := tx.Migrator().DropColumn(&model.Server{}, field); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 37,
+		Name:    "create_service_down_alert_rules",
+		Up: func(tx *gorm.DB) error {
+			if !tx.Migrator().HasColumn(&model.AlertRule{}, "ServiceID") {
+				if err := tx.Migrator().AddColumn(&model.AlertRule{}, "ServiceID"); err != nil {
+					return err
+				}
+			}
+			// 服务监控的故障通知改由等价的 service_down 报警规则发送
+			var services []model.Service
+			if err := tx.Where("notify = ?", true).Find(&services).Error; err != nil {
+				return err
+			}
+			for i := range services {
+				var count int64
+				if err := tx.Model(&model.AlertRule{}).Where("service_id = ?", services[i].ID).Count(&count).Error; err != nil {
+					return err
+				}
+				if count > 0 {
+					continue
+				}
+				if err := tx.Create(model.ServiceAlertRule(&services[i])).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Unscoped().Delete(&model.AlertRule{}, "service_id > 0").Error; err != nil {
+				return err
+			}
+			return tx.Migrator().DropColumn(&model.AlertRule{}, "ServiceID")
+		},
+	},
+	createTableMigration(38, "create_alert_silences", &model.AlertSilence{}),
+	createTableMigration(39, "create_alert_acks", &model.AlertAck{}),
+	{
+		Version: 40,
+		Name:    "encrypt_totp_secrets",
+		Up: func(tx *gorm.DB) error {
+			// 旧版本明文保存的 TOTP 密钥通过 secret 序列化器重新写入以加密
+			var users []*model.User
+			if err := tx.Select("id", "totp_secret").Where("totp_secret <> '' AND totp_secret NOT LIKE ?", model.EncryptedPrefix+"%").Find(&users).Error; err != nil {
+				return err
+			}
+			for _, u := range users {
+				if err := tx.Model(u).Select("totp_secret").Updates(u).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			return nil
+		},
+	},
+	{
+		Version: 41,
+		Name:    "add_cron_command_template",
+		Up: func(tx *gorm.DB) error {
+			if tx.Migrator().HasColumn(&model.Cron{}, "CommandTemplate") {
+				return nil
+			}
+			return tx.Migrator().AddColumn(&model.Cron{}, "CommandTemplate")
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropColumn(&model.Cron{}, "CommandTemplate")
+		},
+	},
+	createTableMigration(42, "create_server_state_hourlies", &model.ServerStateHourly{}),
+	{
+		// 单次检查超时的字段改名为 timeout_seconds；检查间隔限制在 5 秒至 1 天之间，已有的超出范围的服务调整到边界，
+		// 否则这些服务无法再保存
+		Version: 43,
+		Name:    "rename_service_timeout_and_clamp_interval",
+		Up: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			if m.HasColumn(&model.Service{}, "timeout") && !m.HasColumn(&model.Service{}, "TimeoutSeconds") {
+				if err := m.RenameColumn(&model.Service{}, "timeout", "TimeoutSeconds"); err != nil {
+					return err
+				}
+			}
+			if err := tx.Model(&model.Service{}).Where("duration > 0 AND duration < ?", model.ServiceIntervalMin).
+				Update("duration", model.ServiceIntervalMin).Error; err != nil {
+				return err
+			}
+			return tx.Model(&model.Service{}).Where("duration > ?", model.ServiceIntervalMax).
+				Update("duration", model.ServiceIntervalMax).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().RenameColumn(&model.Service{}, "TimeoutSeconds", "timeout")
+		},
+	},
+	createTableMigration(44, "create_service_results", &model.ServiceResult{}),
+	{
+		Version: 45,
+		Name:    "add_service_record_results",
+		Up: func(tx *gorm.DB) error {
+			if tx.Migrator().HasColumn(&model.Service{}, "RecordResults") {
+				return nil
+			}
+			return tx.Migrator().AddColumn(&model.Service{}, "RecordResults")
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropColumn(&model.Service{}, "RecordResults")
+		},
+	},
+	createTableMigration(46, "create_server_uptimes", &model.ServerUptime{}),
+	{
+		Version: 47,
+		Name:    "add_sla_report_server_groups",
+		Up: func(tx *gorm.DB) error {
+			if tx.Migrator().HasColumn(&model.SLAReportConfig{}, "ServerGroupsRaw") {
+				return nil
+			}
+			return tx.Migrator().AddColumn(&model.SLAReportConfig{}, "ServerGroupsRaw")
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropColumn(&model.SLAReportConfig{}, "ServerGroupsRaw")
+		},
+	},
+	{
+		Version: 48,
+		Name:    "unique_user_public_slug",
+		Up: func(tx *gorm.DB) error {
+			// 重复的地址标识只保留最早的用户
+			if err := tx.Exec("UPDATE users SET public_slug = '' WHERE public_slug <> '' AND id NOT IN " +
+				"(SELECT MIN(id) FROM users WHERE public_slug <> '' GROUP BY public_slug)").Error; err != nil {
+				return err
+			}
+			if tx.Migrator().HasIndex(&model.User{}, "idx_users_public_slug") {
+				if err := tx.Migrator().DropIndex(&model.User{}, "idx_users_public_slug"); err != nil {
+					return err
+				}
+			}
+			return tx.Migrator().CreateIndex(&model.User{}, "idx_users_public_slug")
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropIndex(&model.User{}, "idx_users_public_slug")
+		},
+	},
+	{
+		Version: 49,
+		Name:    "add_alert_ack_issued_at",
+		Up: func(tx *gorm.DB) error {
+			if tx.Migrator().HasColumn(&model.AlertAck{}, "IssuedAt") {
+				return nil
+			}
+			return tx.Migrator().AddColumn(&model.AlertAck{}, "IssuedAt")
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropColumn(&model.AlertAck{}, "IssuedAt")
+		},
+	},
+}
+
+var notificationTelegramFields = []string{"Type", "TelegramBotToken", "TelegramChatID", "TelegramThreadID", "TelegramParseMode"}
+
+var serviceQuorumFields = []string{"QuorumCount", "QuorumPercent", "QuorumWindow"}
+
+var serverHeartbeatFields = []string{"HeartbeatMode", "HeartbeatInterval"}
+
+// createTableMigration 新建表的迁移，表已由旧版本的 AutoMigrate 创建时只补齐字段
+func createTableMigration(version int64, name string, value any) migrate.Migration {
+	return migrate.Migration{
+		Version: version,
+		Name:    name,
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(value)
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(value)
+		},
+	}
+}
+
+// NewMigrator 返回当前数据库的 Migrator
+func NewMigrator() (*migrate.Migrator, error) {
+	return migrate.New(DB, migrations)
+}
+
+// MigrateDB 将数据库结构升级到最新版本，数据库版本比程序新时拒绝启动
+func MigrateDB() error {
+	m, err := NewMigrator()
+	if err != nil {
+		return err
+	}
+	done, err := m.Up(0)
+	for _, mg := range done {
+		log.Printf("NEZHA>> Applied database migration %d %s", mg.Version, mg.Name)
+	}
+	return err
+}
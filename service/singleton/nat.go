@@ -12,6 +12,7 @@ type NATClass struct {
 	class[string, *model.NAT]
 
 	idToDomain map[uint64]string
+	stats      *natStatStore
 }
 
 func NewNATClass() *NATClass {
@@ -29,11 +30,22 @@ func NewNATClass() *NATClass {
 		class: class[string, *model.NAT]{
 			list:       list,
 			sortedList: sortedList,
+			userList:   groupByUser(sortedList),
 		},
 		idToDomain: idToDomain,
+		stats:      newNATStatStore(),
 	}
 }
 
+func (c *NATClass) reload() {
+	nc := NewNATClass()
+
+	c.listMu.Lock()
+	c.list, c.idToDomain = nc.list, nc.idToDomain
+	c.listMu.Unlock()
+	c.sortList()
+}
+
 func (c *NATClass) Update(n *model.NAT) {
 	c.listMu.Lock()
 
@@ -46,6 +58,7 @@ func (c *NATClass) Update(n *model.NAT) {
 
 	c.listMu.Unlock()
 	c.sortList()
+	ClusterShared.PublishChange("nat")
 }
 
 func (c *NATClass) Delete(idList []uint64) {
@@ -59,7 +72,45 @@ func (c *NATClass) Delete(idList []uint64) {
 	}
 
 	c.listMu.Unlock()
+	c.stats.delete(idList)
 	c.sortList()
+	ClusterShared.PublishChange("nat")
+}
+
+// OpenConn 开始统计一个转发连接，结束时需调用 CloseConn
+func (c *NATClass) OpenConn(id uint64) *NATConn {
+	return c.stats.open(id)
+}
+
+func (c *NATClass) CloseConn(conn *NATConn) {
+	c.stats.close(conn)
+}
+
+// Deny 记录一次被访问控制拒绝的请求
+func (c *NATClass) Deny(id uint64) {
+	c.stats.deny(id)
+}
+
+func (c *NATClass) FlushStats() {
+	c.stats.flush()
+}
+
+// RescheduleStats 在统计的重置周期修改后调用
+func (c *NATClass) RescheduleStats() {
+	c.stats.reschedule()
+}
+
+// GetStats 获取自上次重置以来的统计
+func (c *NATClass) GetStats(id uint64) *model.NATStats {
+	return c.stats.get(id)
+}
+
+// GetStatsDetail 获取当前统计及最近 30 天的每日统计
+func (c *NATClass) GetStatsDetail(id uint64) *model.NATStatsDetail {
+	return &model.NATStatsDetail{
+		NATStats: *c.stats.get(id),
+		History:  c.stats.history(id),
+	}
 }
 
 func (c *NATClass) GetNATConfigByDomain(domain string) *model.NAT {
@@ -87,5 +138,5 @@ func (c *NATClass) sortList() {
 
 	c.sortedListMu.Lock()
 	defer c.sortedListMu.Unlock()
-	c.sortedList = sortedList
+	c.setSortedList(sortedList)
 }
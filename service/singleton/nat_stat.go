@@ -0,0 +1,280 @@
+package singleton
+
+import (
+	"log"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+// NAT 每日统计保留的天数
+const natStatDays = 30
+
+// NATConn 单个转发连接的流量计数，转发时只做原子累加，由 NATClass.FlushStats 定期汇总
+type NATConn struct {
+	natID    uint64
+	BytesIn  atomic.Uint64
+	BytesOut atomic.Uint64
+}
+
+type natStatKey struct {
+	natID uint64
+	date  time.Time
+}
+
+type natCounter struct {
+	conns map[*NATConn]struct{}
+	stats model.NATStats
+}
+
+// natStatStore 维护 NAT 配置的当前统计及近期的每日统计
+type natStatStore struct {
+	mu        sync.Mutex
+	counters  map[uint64]*natCounter
+	daily     map[natStatKey]*model.NATStatDaily
+	dirty     map[natStatKey]bool
+	since     time.Time
+	nextReset time.Time
+}
+
+func newNATStatStore() *natStatStore {
+	st := &natStatStore{
+		counters: make(map[uint64]*natCounter),
+		daily:    make(map[natStatKey]*model.NATStatDaily),
+		dirty:    make(map[natStatKey]bool),
+	}
+	var rows []*model.NATStatDaily
+	if err := DB.Where("date >= ?", natStatStart()).Find(&rows).Error; err != nil {
+		log.Printf("NEZHA>> Failed to load NAT stats: %v", err)
+	}
+	for _, row := range rows {
+		st.daily[natStatKey{row.NATID, row.Date.In(Loc)}] = row
+	}
+
+	// 重启后按天恢复本周期的统计
+	st.restore(time.Now())
+	return st
+}
+
+// restore 按新的重置周期从每日统计重新累计当前统计，调用时需持有锁或尚未共享
+func (st *natStatStore) restore(now time.Time) {
+	st.since, st.nextReset = natResetPeriod(now)
+	for _, c := range st.counters {
+		c.stats = model.NATStats{ActiveConnections: c.stats.ActiveConnections}
+	}
+	sinceDay := transferDay(st.since)
+	for key, row := range st.daily {
+		if key.date.Before(sinceDay) {
+			continue
+		}
+		c := st.counter(key.natID)
+		c.stats.BytesIn += row.BytesIn
+		c.stats.BytesOut += row.BytesOut
+		c.stats.TotalConnections += row.Connections
+		c.stats.Denied += row.Denied
+	}
+}
+
+// reschedule 重置周期修改后立即按新周期重新计算当前统计
+func (st *natStatStore) reschedule() {
+	now := time.Now()
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	for _, c := range st.counters {
+		for conn := range c.conns {
+			st.collect(conn, now)
+		}
+	}
+	st.restore(now)
+}
+
+func natStatStart() time.Time {
+	return transferDay(time.Now()).AddDate(0, 0, -natStatDays+1)
+}
+
+// natResetPeriod 返回当前重置周期的开始时间与下次重置时间，周期开始早于统计范围时取统计范围的开始
+func natResetPeriod(now time.Time) (time.Time, time.Time) {
+	schedule, err := model.ParseCronSchedule(Conf.NATStatResetSchedule)
+	if err != nil {
+		log.Printf("NEZHA>> Invalid NAT stat reset schedule: %v", err)
+		return natStatStart(), time.Time{}
+	}
+	since := natStatStart()
+	next := schedule.Next(since)
+	for !next.IsZero() && !next.After(now) {
+		since = next
+		next = schedule.Next(next)
+	}
+	return since, next
+}
+
+func (st *natStatStore) counter(natID uint64) *natCounter {
+	c, ok := st.counters[natID]
+	if !ok {
+		c = &natCounter{conns: make(map[*NATConn]struct{})}
+		st.counters[natID] = c
+	}
+	return c
+}
+
+func (st *natStatStore) row(natID uint64, now time.Time) *model.NATStatDaily {
+	key := natStatKey{natID, transferDay(now)}
+	row, ok := st.daily[key]
+	if !ok {
+		row = &model.NATStatDaily{NATID: natID, Date: key.date}
+		st.daily[key] = row
+	}
+	st.dirty[key] = true
+	return row
+}
+
+// collect 取出连接尚未汇总的流量，调用时需持有锁
+func (st *natStatStore) collect(conn *NATConn, now time.Time) {
+	in, out := conn.BytesIn.Swap(0), conn.BytesOut.Swap(0)
+	if in == 0 && out == 0 {
+		return
+	}
+	c := st.counter(conn.natID)
+	c.stats.BytesIn += in
+	c.stats.BytesOut += out
+	row := st.row(conn.natID, now)
+	row.BytesIn += in
+	row.BytesOut += out
+}
+
+func (st *natStatStore) open(natID uint64) *NATConn {
+	conn := &NATConn{natID: natID}
+	now := time.Now()
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	c := st.counter(natID)
+	c.conns[conn] = struct{}{}
+	c.stats.ActiveConnections++
+	c.stats.TotalConnections++
+	st.row(natID, now).Connections++
+	return conn
+}
+
+func (st *natStatStore) deny(natID uint64) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.counter(natID).stats.Denied++
+	st.row(natID, time.Now()).Denied++
+}
+
+func (st *natStatStore) close(conn *NATConn) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.collect(conn, time.Now())
+	c := st.counter(conn.natID)
+	if _, ok := c.conns[conn]; ok {
+		delete(c.conns, conn)
+		c.stats.ActiveConnections--
+	}
+}
+
+// flush 汇总活动连接的流量并保存每日统计，到达重置时间时清零当前统计
+func (st *natStatStore) flush() {
+	now := time.Now()
+	st.mu.Lock()
+	for _, c := range st.counters {
+		for conn := range c.conns {
+			st.collect(conn, now)
+		}
+	}
+	if !st.nextReset.IsZero() && !now.Before(st.nextReset) {
+		st.since, st.nextReset = natResetPeriod(now)
+		for _, c := range st.counters {
+			c.stats = model.NATStats{ActiveConnections: c.stats.ActiveConnections}
+		}
+	}
+	rows := make([]*model.NATStatDaily, 0, len(st.dirty))
+	for key := range st.dirty {
+		row := *st.daily[key]
+		rows = append(rows, &row)
+	}
+	clear(st.dirty)
+	st.mu.Unlock()
+
+	for _, row := range rows {
+		if err := DB.Save(row).Error; err != nil {
+			log.Printf("NEZHA>> Failed to save NAT stats: %v", err)
+			continue
+		}
+		st.mu.Lock()
+		if r, ok := st.daily[natStatKey{row.NATID, row.Date}]; ok && r.ID == 0 {
+			r.ID = row.ID
+		}
+		st.mu.Unlock()
+	}
+}
+
+func (st *natStatStore) get(natID uint64) *model.NATStats {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	stats := model.NATStats{Since: st.since}
+	if c, ok := st.counters[natID]; ok {
+		stats = c.stats
+		stats.Since = st.since
+	}
+	return &stats
+}
+
+func (st *natStatStore) history(natID uint64) []*model.NATStatDaily {
+	start := natStatStart()
+	history := make([]*model.NATStatDaily, 0, natStatDays)
+
+	st.mu.Lock()
+	for key, row := range st.daily {
+		if key.natID == natID && !key.date.Before(start) {
+			r := *row
+			history = append(history, &r)
+		}
+	}
+	st.mu.Unlock()
+
+	slices.SortFunc(history, func(a, b *model.NATStatDaily) int {
+		return a.Date.Compare(b.Date)
+	})
+	return history
+}
+
+// clean 清理统计范围以外及已删除配置的数据
+func (st *natStatStore) clean() {
+	start := natStatStart()
+	st.mu.Lock()
+	for key := range st.daily {
+		if key.date.Before(start) && !st.dirty[key] {
+			delete(st.daily, key)
+		}
+	}
+	st.mu.Unlock()
+
+	DB.Unscoped().Delete(&model.NATStatDaily{}, "date < ? OR nat_id NOT IN (SELECT `id` FROM nats)", start)
+}
+
+func (st *natStatStore) delete(idList []uint64) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	for _, id := range idList {
+		if c, ok := st.counters[id]; ok && len(c.conns) == 0 {
+			delete(st.counters, id)
+		}
+	}
+	for key := range st.daily {
+		if slices.Contains(idList, key.natID) {
+			delete(st.daily, key)
+			delete(st.dirty, key)
+		}
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/i18n"
 	"github.com/nezhahq/nezha/pkg/utils"
 )
 
@@ -22,8 +23,10 @@ type NotificationClass struct {
 	groupToIDList map[uint64]map[uint64]*model.Notification
 	idToGroupList map[uint64]map[uint64]struct{}
 
-	groupList map[uint64]string
-	groupMu   sync.RWMutex
+	groupList  map[uint64]string
+	groupLang  map[uint64]string             // 通知组发送通知使用的语言，为空时使用系统语言
+	groupHours map[uint64]*model.ActiveHours // 通知组的接收时段
+	groupMu    sync.RWMutex
 }
 
 func NewNotificationClass() *NotificationClass {
@@ -49,8 +52,18 @@ func NewNotificationClass() *NotificationClass {
 	var groups []model.NotificationGroup
 	DB.Find(&groups)
 	groupList := make(map[uint64]string)
+	groupLang := make(map[uint64]string)
+	groupHours := make(map[uint64]*model.ActiveHours)
 	for _, grp := range groups {
 		groupList[grp.ID] = grp.Name
+		groupLang[grp.ID] = grp.Language
+		if grp.ActiveHours != nil {
+			if err := grp.ActiveHours.Validate(Loc); err != nil {
+				log.Printf("NEZHA>> Notification group %d has invalid active hours: %v", grp.ID, err)
+				continue
+			}
+			groupHours[grp.ID] = grp.ActiveHours
+		}
 	}
 
 	for gid, nids := range groupNotifications {
@@ -72,14 +85,30 @@ func NewNotificationClass() *NotificationClass {
 		class: class[uint64, *model.Notification]{
 			list:       list,
 			sortedList: sortedList,
+			userList:   groupByUser(sortedList),
 		},
 		groupToIDList: groupToIDList,
 		idToGroupList: idToGroupList,
 		groupList:     groupList,
+		groupLang:     groupLang,
+		groupHours:    groupHours,
 	}
 	return nc
 }
 
+func (c *NotificationClass) reload() {
+	nc := NewNotificationClass()
+
+	c.groupMu.Lock()
+	c.listMu.Lock()
+	c.list = nc.list
+	c.groupToIDList, c.idToGroupList = nc.groupToIDList, nc.idToGroupList
+	c.groupList, c.groupLang, c.groupHours = nc.groupList, nc.groupLang, nc.groupHours
+	c.listMu.Unlock()
+	c.groupMu.Unlock()
+	c.sortList()
+}
+
 func (c *NotificationClass) Update(n *model.Notification) {
 	c.listMu.Lock()
 
@@ -96,14 +125,22 @@ func (c *NotificationClass) Update(n *model.Notification) {
 
 	c.listMu.Unlock()
 	c.sortList()
+	ClusterShared.PublishChange("notification")
 }
 
 func (c *NotificationClass) UpdateGroup(ng *model.NotificationGroup, ngn []uint64) {
+	defer ClusterShared.PublishChange("notification-group")
 	c.groupMu.Lock()
 	defer c.groupMu.Unlock()
 
 	_, ok := c.groupList[ng.ID]
 	c.groupList[ng.ID] = ng.Name
+	c.groupLang[ng.ID] = ng.Language
+	if ng.ActiveHours != nil {
+		c.groupHours[ng.ID] = ng.ActiveHours
+	} else {
+		delete(c.groupHours, ng.ID)
+	}
 
 	c.listMu.Lock()
 	defer c.listMu.Unlock()
@@ -158,9 +195,11 @@ func (c *NotificationClass) Delete(idList []uint64) {
 
 	c.listMu.Unlock()
 	c.sortList()
+	ClusterShared.PublishChange("notification")
 }
 
 func (c *NotificationClass) DeleteGroup(gids []uint64) {
+	defer ClusterShared.PublishChange("notification-group")
 	c.listMu.Lock()
 	defer c.listMu.Unlock()
 	c.groupMu.Lock()
@@ -168,6 +207,8 @@ func (c *NotificationClass) DeleteGroup(gids []uint64) {
 
 	for _, gid := range gids {
 		delete(c.groupList, gid)
+		delete(c.groupLang, gid)
+		delete(c.groupHours, gid)
 		delete(c.groupToIDList, gid)
 	}
 }
@@ -179,6 +220,14 @@ func (c *NotificationClass) GetGroupName(gid uint64) string {
 	return c.groupList[gid]
 }
 
+// Lang 返回按通知组设置的语言翻译通知内容的 Lang，未设置时使用系统语言
+func (c *NotificationClass) Lang(gid uint64) *i18n.Lang {
+	c.groupMu.RLock()
+	defer c.groupMu.RUnlock()
+
+	return Localizer.Lang(c.groupLang[gid])
+}
+
 func (c *NotificationClass) sortList() {
 	c.listMu.RLock()
 	defer c.listMu.RUnlock()
@@ -190,7 +239,7 @@ func (c *NotificationClass) sortList() {
 
 	c.sortedListMu.Lock()
 	defer c.sortedListMu.Unlock()
-	c.sortedList = sortedList
+	c.setSortedList(sortedList)
 }
 
 func (c *NotificationClass) UnMuteNotification(notificationGroupID uint64, muteLabel string) {
@@ -200,59 +249,97 @@ func (c *NotificationClass) UnMuteNotification(notificationGroupID uint64, muteL
 
 // SendNotification 向指定的通知方式组的所有通知方式发送通知
 func (c *NotificationClass) SendNotification(notificationGroupID uint64, desc string, muteLabel string, ext ...*model.Server) {
-	if muteLabel != "" {
-		// 将通知方式组名称加入静音标志
-		muteLabel := NotificationMuteLabel.AppendNotificationGroupName(muteLabel, c.GetGroupName(notificationGroupID))
-		// 通知防骚扰策略
-		var flag bool
-		if cacheN, has := Cache.Get(muteLabel); has {
-			nHistory := cacheN.(NotificationHistory)
-			// 每次提醒都增加一倍等待时间，最后每天最多提醒一次
-			if time.Now().After(nHistory.Until) {
-				flag = true
-				nHistory.Duration *= 2
-				if nHistory.Duration > time.Hour*24 {
-					nHistory.Duration = time.Hour * 24
-				}
-				nHistory.Until = time.Now().Add(nHistory.Duration)
-				// 缓存有效期加 10 分钟
-				Cache.Set(muteLabel, nHistory, nHistory.Duration+time.Minute*10)
-			}
-		} else {
-			// 新提醒直接通知
-			flag = true
-			Cache.Set(muteLabel, NotificationHistory{
-				Duration: firstNotificationDelay,
-				Until:    time.Now().Add(firstNotificationDelay),
-			}, firstNotificationDelay+time.Minute*10)
-		}
+	if c.muted(notificationGroupID, desc, muteLabel) {
+		return
+	}
+	if !c.deliverNow(notificationGroupID, desc) {
+		return
+	}
+	var server *model.Server
+	if len(ext) > 0 {
+		server = ext[0]
+	}
+	// 向该通知方式组的所有通知方式发出通知，发送可能因限流重试而等待，不持有锁
+	c.listMu.RLock()
+	list := utils.MapValuesToSlice(c.groupToIDList[notificationGroupID])
+	c.listMu.RUnlock()
+	for _, n := range list {
+		log.Printf("NEZHA>> Try to notify %s", n.Name)
+	}
+	for _, n := range list {
+		sendNotification(n, desc, server)
+	}
+}
 
-		if !flag {
-			if Conf.Debug {
-				log.Println("NEZHA>> Muted repeated notification", desc, muteLabel)
+// muted 通知防骚扰策略，相同静音标志的通知按递增的间隔发送
+func (c *NotificationClass) muted(notificationGroupID uint64, desc string, muteLabel string) bool {
+	if muteLabel == "" {
+		return false
+	}
+	// 将通知方式组名称加入静音标志
+	muteLabel = NotificationMuteLabel.AppendNotificationGroupName(muteLabel, c.GetGroupName(notificationGroupID))
+	var flag bool
+	if cacheN, has := Cache.Get(muteLabel); has {
+		nHistory := cacheN.(NotificationHistory)
+		// 每次提醒都增加一倍等待时间，最后每天最多提醒一次
+		if time.Now().After(nHistory.Until) {
+			flag = true
+			nHistory.Duration *= 2
+			if nHistory.Duration > time.Hour*24 {
+				nHistory.Duration = time.Hour * 24
 			}
-			return
+			nHistory.Until = time.Now().Add(nHistory.Duration)
+			// 缓存有效期加 10 分钟
+			Cache.Set(muteLabel, nHistory, nHistory.Duration+time.Minute*10)
 		}
+	} else {
+		// 新提醒直接通知
+		flag = true
+		Cache.Set(muteLabel, NotificationHistory{
+			Duration: firstNotificationDelay,
+			Until:    time.Now().Add(firstNotificationDelay),
+		}, firstNotificationDelay+time.Minute*10)
 	}
-	// 向该通知方式组的所有通知方式发出通知
+
+	if !flag && Conf.Debug {
+		log.Println("NEZHA>> Muted repeated notification", desc, muteLabel)
+	}
+	return !flag
+}
+
+func sendNotification(n *model.Notification, desc string, server *model.Server) {
+	ns := model.NotificationServerBundle{
+		Notification: n,
+		Server:       server,
+		Loc:          Loc,
+	}
+	if err := ns.Send(desc); err != nil {
+		metricsNotificationFailures.Add(1)
+		log.Printf("NEZHA>> Sending notification to %s failed: %v", n.Name, err)
+	} else {
+		log.Printf("NEZHA>> Sending notification to %s succeeded", n.Name)
+	}
+}
+
+// SendUserNotification 通过用户自己添加的所有通知方式发出通知
+func (c *NotificationClass) SendUserNotification(userID uint64, desc string) {
+	var list []*model.Notification
 	c.listMu.RLock()
-	defer c.listMu.RUnlock()
-	for _, n := range c.groupToIDList[notificationGroupID] {
-		log.Printf("NEZHA>> Try to notify %s", n.Name)
+	for _, n := range c.list {
+		if n.UserID == userID {
+			list = append(list, n)
+		}
 	}
-	for _, n := range c.groupToIDList[notificationGroupID] {
+	c.listMu.RUnlock()
+
+	for _, n := range list {
 		ns := model.NotificationServerBundle{
 			Notification: n,
-			Server:       nil,
 			Loc:          Loc,
 		}
-		if len(ext) > 0 {
-			ns.Server = ext[0]
-		}
 		if err := ns.Send(desc); err != nil {
+			metricsNotificationFailures.Add(1)
 			log.Printf("NEZHA>> Sending notification to %s failed: %v", n.Name, err)
-		} else {
-			log.Printf("NEZHA>> Sending notification to %s succeeded", n.Name)
 		}
 	}
 }
@@ -273,6 +360,14 @@ func (_NotificationMuteLabel) ServerIncidentResolved(alertId uint64, serverId ui
 	return fmt.Sprintf("bf::seir-%d-%d", alertId, serverId)
 }
 
+func (_NotificationMuteLabel) ServiceIncident(serviceId uint64, alertId uint64) string {
+	return fmt.Sprintf("bf::svi-%d-%d", serviceId, alertId)
+}
+
+func (_NotificationMuteLabel) ServiceIncidentResolved(serviceId uint64, alertId uint64) string {
+	return fmt.Sprintf("bf::svir-%d-%d", serviceId, alertId)
+}
+
 func (_NotificationMuteLabel) AppendNotificationGroupName(label string, notificationGroupName string) string {
 	return fmt.Sprintf("%s:%s", label, notificationGroupName)
 }
@@ -285,6 +380,14 @@ func (_NotificationMuteLabel) ServiceLatencyMax(serviceId uint64) string {
 	return fmt.Sprintf("bf::slm-%d", serviceId)
 }
 
+func (_NotificationMuteLabel) ServiceLoss(serviceId uint64) string {
+	return fmt.Sprintf("bf::sls-%d", serviceId)
+}
+
+func (_NotificationMuteLabel) ServiceJitter(serviceId uint64) string {
+	return fmt.Sprintf("bf::sjt-%d", serviceId)
+}
+
 func (_NotificationMuteLabel) ServiceStateChanged(serviceId uint64) string {
 	return fmt.Sprintf("bf::ssc-%d", serviceId)
 }
@@ -0,0 +1,109 @@
+package singleton
+
+import (
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+// alertDigestKey 同一通知方式关于同一服务器或同一服务的报警或恢复通知合并为一条消息
+type alertDigestKey struct {
+	notificationID uint64
+	serverID       uint64
+	serviceID      uint64
+	resolved       bool
+}
+
+type alertDigest struct {
+	notification *model.Notification
+	server       *model.Server
+	title        string
+	alerts       []string
+	footer       string // 静音链接等附加内容
+}
+
+var alertDigests = struct {
+	mu      sync.Mutex
+	pending map[alertDigestKey]*alertDigest
+}{pending: make(map[alertDigestKey]*alertDigest)}
+
+// SendAlertNotification 发送服务器报警或恢复通知，title 为通知标题与服务器信息，
+// 窗口内同一通知方式关于同一服务器的其他报警规则合并到同一条消息中
+func (c *NotificationClass) SendAlertNotification(notificationGroupID uint64, title, alertName, muteLabel string, server *model.Server, resolved bool) {
+	if !resolved && ServerSilenced(server.ID) {
+		return
+	}
+	c.sendAlert(notificationGroupID, title, alertName, muteLabel, alertDigestKey{serverID: server.ID, resolved: resolved}, server)
+}
+
+// SendServiceAlertNotification 发送服务报警或恢复通知，与服务器报警一样经过防骚扰、合并与静默时段处理
+func (c *NotificationClass) SendServiceAlertNotification(notificationGroupID uint64, title, alertName, muteLabel string, service *model.Service, resolved bool) {
+	c.sendAlert(notificationGroupID, title, alertName, muteLabel, alertDigestKey{serviceID: service.ID, resolved: resolved}, nil)
+}
+
+// sendAlert 按 key 合并通知，server 为空时不附加操作链接
+func (c *NotificationClass) sendAlert(notificationGroupID uint64, title, alertName, muteLabel string, key alertDigestKey, server *model.Server) {
+	if c.muted(notificationGroupID, title+" "+alertName, muteLabel) {
+		return
+	}
+	if !c.deliverNow(notificationGroupID, title+" "+alertName) {
+		return
+	}
+
+	c.listMu.RLock()
+	defer c.listMu.RUnlock()
+	for _, n := range c.groupToIDList[notificationGroupID] {
+		var footer string
+		if !key.resolved && server != nil {
+			footer = actionLinksText(n, server, notificationGroupID)
+		}
+		if n.DisableDedup || Conf.NotificationDedupWindow < 0 {
+			log.Printf("NEZHA>> Try to notify %s", n.Name)
+			sendNotification(n, title+" "+alertName+footer, server)
+			continue
+		}
+		key.notificationID = n.ID
+		queueAlertDigest(key, n, server, title, alertName, footer)
+	}
+}
+
+func queueAlertDigest(key alertDigestKey, n *model.Notification, server *model.Server, title, alertName, footer string) {
+	alertDigests.mu.Lock()
+	defer alertDigests.mu.Unlock()
+
+	if d, ok := alertDigests.pending[key]; ok {
+		// 多个通知组包含同一通知方式时只列出一次
+		if !slices.Contains(d.alerts, alertName) {
+			d.alerts = append(d.alerts, alertName)
+		}
+		d.server = server
+		return
+	}
+	alertDigests.pending[key] = &alertDigest{
+		notification: n,
+		server:       server,
+		title:        title,
+		alerts:       []string{alertName},
+		footer:       footer,
+	}
+	time.AfterFunc(time.Duration(Conf.NotificationDedupWindow)*time.Second, func() {
+		flushAlertDigest(key)
+	})
+}
+
+func flushAlertDigest(key alertDigestKey) {
+	alertDigests.mu.Lock()
+	d, ok := alertDigests.pending[key]
+	delete(alertDigests.pending, key)
+	alertDigests.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	log.Printf("NEZHA>> Try to notify %s", d.notification.Name)
+	sendNotification(d.notification, d.title+" "+strings.Join(d.alerts, ", ")+d.footer, d.server)
+}
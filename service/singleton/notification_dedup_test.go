@@ -0,0 +1,39 @@
+package singleton
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+func TestQueueAlertDigest(t *testing.T) {
+	old := Conf
+	Conf = &ConfigClass{Config: &model.Config{NotificationDedupWindow: 3600}}
+	t.Cleanup(func() { Conf = old })
+
+	n := &model.Notification{Common: model.Common{ID: 1}}
+	s := &model.Server{Common: model.Common{ID: 2}}
+	incident := alertDigestKey{notificationID: 1, serverID: 2}
+	resolved := alertDigestKey{notificationID: 1, serverID: 2, resolved: true}
+	t.Cleanup(func() {
+		alertDigests.mu.Lock()
+		delete(alertDigests.pending, incident)
+		delete(alertDigests.pending, resolved)
+		alertDigests.mu.Unlock()
+	})
+
+	queueAlertDigest(incident, n, s, "[Incident] s", "cpu", "")
+	queueAlertDigest(incident, n, s, "[Incident] s", "load", "")
+	queueAlertDigest(incident, n, s, "[Incident] s", "cpu", "")
+	queueAlertDigest(resolved, n, s, "[Resolved] s", "cpu", "")
+
+	alertDigests.mu.Lock()
+	defer alertDigests.mu.Unlock()
+	if d := alertDigests.pending[incident]; d == nil || !slices.Equal(d.alerts, []string{"cpu", "load"}) {
+		t.Fatalf("unexpected incident digest: %+v", d)
+	}
+	if d := alertDigests.pending[resolved]; d == nil || !slices.Equal(d.alerts, []string{"cpu"}) {
+		t.Fatalf("unexpected resolved digest: %+v", d)
+	}
+}
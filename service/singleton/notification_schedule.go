@@ -0,0 +1,119 @@
+package singleton
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+// 静默时段延迟发送的汇总中最多列出的通知条数
+const maxDeferredNotifications = 20
+
+type deferredNotifications struct {
+	messages []string
+	omitted  int
+}
+
+var quietHours = struct {
+	mu      sync.Mutex
+	pending map[uint64]*deferredNotifications
+}{pending: make(map[uint64]*deferredNotifications)}
+
+// activeHours 返回通知组的接收时段，未设置时返回 nil
+func (c *NotificationClass) activeHours(gid uint64) *model.ActiveHours {
+	c.groupMu.RLock()
+	defer c.groupMu.RUnlock()
+
+	return c.groupHours[gid]
+}
+
+// deliverNow 判断通知组当前是否接收通知，不接收时按静默策略丢弃、延迟或转发到备用通知组
+func (c *NotificationClass) deliverNow(gid uint64, desc string) bool {
+	ah := c.activeHours(gid)
+	if ah == nil || ah.Active(time.Now()) {
+		return true
+	}
+
+	switch ah.Policy {
+	case model.QuietHoursPolicyDefer:
+		c.deferNotification(gid, ah, desc)
+	case model.QuietHoursPolicyFallback:
+		log.Printf("NEZHA>> Notification group %d is in quiet hours, rerouting to group %d", gid, ah.FallbackGroupID)
+		c.sendToGroup(ah.FallbackGroupID, desc)
+	default:
+		if Conf.Debug {
+			log.Printf("NEZHA>> Dropped notification during quiet hours of group %d: %s", gid, desc)
+		}
+	}
+	return false
+}
+
+// deferNotification 暂存静默时段内的通知，同一通知组在时段开始时只发送一条汇总
+func (c *NotificationClass) deferNotification(gid uint64, ah *model.ActiveHours, desc string) {
+	quietHours.mu.Lock()
+	defer quietHours.mu.Unlock()
+
+	if d, ok := quietHours.pending[gid]; ok {
+		if len(d.messages) < maxDeferredNotifications {
+			d.messages = append(d.messages, desc)
+		} else {
+			d.omitted++
+		}
+		return
+	}
+
+	next := ah.NextActive(time.Now())
+	if next.IsZero() {
+		return
+	}
+	quietHours.pending[gid] = &deferredNotifications{messages: []string{desc}}
+	time.AfterFunc(time.Until(next), func() {
+		c.flushDeferred(gid)
+	})
+}
+
+func (c *NotificationClass) flushDeferred(gid uint64) {
+	// 等待期间接收时段被修改时按新的时段重新等待
+	if ah := c.activeHours(gid); ah != nil && !ah.Active(time.Now()) {
+		if next := ah.NextActive(time.Now()); !next.IsZero() {
+			time.AfterFunc(time.Until(next), func() {
+				c.flushDeferred(gid)
+			})
+			return
+		}
+	}
+
+	quietHours.mu.Lock()
+	d, ok := quietHours.pending[gid]
+	delete(quietHours.pending, gid)
+	quietHours.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	lang := c.Lang(gid)
+	var msg strings.Builder
+	msg.WriteString(lang.Tf("[Quiet Hours] %d notifications were deferred", len(d.messages)+d.omitted))
+	for _, m := range d.messages {
+		msg.WriteString("\n\n")
+		msg.WriteString(m)
+	}
+	if d.omitted > 0 {
+		msg.WriteString("\n\n")
+		msg.WriteString(lang.Tf("... and %d more", d.omitted))
+	}
+	c.sendToGroup(gid, msg.String())
+}
+
+// sendToGroup 不经过防骚扰和静默时段检查，直接向通知组的所有通知方式发送
+func (c *NotificationClass) sendToGroup(gid uint64, desc string) {
+	c.listMu.RLock()
+	defer c.listMu.RUnlock()
+	for _, n := range c.groupToIDList[gid] {
+		log.Printf("NEZHA>> Try to notify %s", n.Name)
+		sendNotification(n, desc, nil)
+	}
+}
@@ -25,6 +25,20 @@ func RemoveOnlineUser(connId string) {
 	delete(OnlineUserMap, connId)
 }
 
+// CountOnlineUsersByIP 返回该 IP 当前打开的连接数
+func CountOnlineUsersByIP(ip string) int {
+	OnlineUserMapLock.Lock()
+	defer OnlineUserMapLock.Unlock()
+
+	var n int
+	for _, user := range OnlineUserMap {
+		if user.IP == ip {
+			n++
+		}
+	}
+	return n
+}
+
 func BlockByIPs(ipList []string) error {
 	OnlineUserMapLock.Lock()
 	defer OnlineUserMapLock.Unlock()
@@ -64,3 +78,8 @@ func GetOnlineUserCount() int {
 	defer OnlineUserMapLock.Unlock()
 	return len(OnlineUserMap)
 }
+
+// GetClusterOnlineUserCount 所有节点的在线用户数
+func GetClusterOnlineUserCount() int {
+	return GetOnlineUserCount() + ClusterShared.peerOnlineUsers()
+}
@@ -0,0 +1,54 @@
+package singleton
+
+import (
+	"sync"
+
+	pb "github.com/nezhahq/nezha/proto"
+)
+
+// pendingTasks 等待 Agent 返回结果的任务，按下发时分配的任务 ID 对应结果，
+// 超时后才返回的结果找不到对应的任务，不会交给之后的请求
+type pendingTasks struct {
+	mu      sync.Mutex
+	seq     uint64
+	pending map[uint64]*pendingTask
+}
+
+type pendingTask struct {
+	serverID uint64
+	result   chan *pb.TaskResult
+}
+
+func newPendingTasks() *pendingTasks {
+	return &pendingTasks{pending: make(map[uint64]*pendingTask)}
+}
+
+// add 分配任务 ID，等待结束后需调用 done
+func (t *pendingTasks) add(serverID uint64) (uint64, *pendingTask) {
+	p := &pendingTask{serverID: serverID, result: make(chan *pb.TaskResult, 1)}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.seq++
+	t.pending[t.seq] = p
+	return t.seq, p
+}
+
+func (t *pendingTasks) done(id uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.pending, id)
+}
+
+// finish 将结果交给等待中的任务，忽略已超时或来自其他服务器的结果
+func (t *pendingTasks) finish(serverID uint64, result *pb.TaskResult) {
+	t.mu.Lock()
+	p, ok := t.pending[result.GetId()]
+	t.mu.Unlock()
+	if !ok || p.serverID != serverID {
+		return
+	}
+	select {
+	case p.result <- result:
+	default:
+	}
+}
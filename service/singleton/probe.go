@@ -0,0 +1,131 @@
+package singleton
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"sync/atomic"
+	"time"
+
+	"github.com/goccy/go-json"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/utils"
+	pb "github.com/nezhahq/nezha/proto"
+)
+
+const probeResolveTimeout = 5 * time.Second
+
+// probeDenyNetworks 禁止探测的网段
+var probeDenyNetworks atomic.Pointer[utils.TrustedProxies]
+
+// updateProbeDenyNetworks 解析配置中禁止探测的网段
+func (c *ConfigClass) updateProbeDenyNetworks() error {
+	networks, err := utils.ParseTrustedProxies(c.Probe.DenyCIDRs)
+	if err != nil {
+		return err
+	}
+	probeDenyNetworks.Store(&networks)
+	return nil
+}
+
+// CheckProbeTarget 目标解析到禁止探测的网段时拒绝，未配置网段时不限制。
+// 返回下发给 Agent 的目标，配置了网段时域名替换为检查过的地址，避免 Agent 再次解析时得到不同的地址
+func CheckProbeTarget(target string) (string, error) {
+	networks := probeDenyNetworks.Load()
+	if networks == nil || len(*networks) == 0 {
+		return target, nil
+	}
+
+	if addr, err := netip.ParseAddr(target); err == nil {
+		if networks.Trusted(addr.Unmap()) {
+			return "", Localizer.ErrorT("probing %s is not allowed", target)
+		}
+		return target, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), probeResolveTimeout)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", target)
+	if err != nil {
+		return "", Localizer.ErrorT("failed to resolve %s: %v", target, err)
+	}
+	for _, addr := range addrs {
+		if networks.Trusted(addr.Unmap()) {
+			return "", Localizer.ErrorT("probing %s is not allowed", target)
+		}
+	}
+	return addrs[0].Unmap().String(), nil
+}
+
+// probeAddrDenied Agent 返回的地址位于禁止探测的网段
+func probeAddrDenied(ip string) bool {
+	networks := probeDenyNetworks.Load()
+	if networks == nil || len(*networks) == 0 {
+		return false
+	}
+	addr, err := netip.ParseAddr(ip)
+	return err == nil && networks.Trusted(addr.Unmap())
+}
+
+// probes 等待 Agent 返回结果的探测，按任务 ID 区分同一服务器上的并发探测
+var probes = newPendingTasks()
+
+// RunProbe 检查目标后向 Agent 下发探测任务并等待结果
+func RunProbe(s *model.Server, task *model.ProbeTask) (*model.ProbeResult, error) {
+	target := task.Target
+	agentTask := *task
+	var err error
+	if agentTask.Target, err = CheckProbeTarget(task.Target); err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(&agentTask)
+	if err != nil {
+		return nil, err
+	}
+
+	id, p := probes.add(s.ID)
+	defer probes.done(id)
+
+	if err := s.TaskStream.Send(&pb.Task{
+		Id:   id,
+		Type: model.TaskTypeProbe,
+		Data: string(data),
+	}); err != nil {
+		return nil, err
+	}
+
+	timeout := time.NewTimer(task.WaitTimeout())
+	defer timeout.Stop()
+
+	var result *pb.TaskResult
+	select {
+	case <-timeout.C:
+		return nil, Localizer.ErrorT("operation timeout")
+	case result = <-p.result:
+	}
+
+	if !result.GetSuccessful() {
+		return nil, Localizer.ErrorT("probe failed: %s", result.GetData())
+	}
+	if len(result.GetData()) > model.ReportMaxProbeSize {
+		return nil, Localizer.ErrorT("probe result is too large")
+	}
+	var r model.ProbeResult
+	if err := json.Unmarshal([]byte(result.GetData()), &r); err != nil {
+		return nil, Localizer.ErrorT("probe failed: %v", err)
+	}
+	r.Sanitize()
+	// Agent 实际探测的地址同样不能位于禁止的网段
+	if probeAddrDenied(r.IP) {
+		return nil, Localizer.ErrorT("probing %s is not allowed", target)
+	}
+	r.Type, r.Target = task.Type, target
+	r.CreatedAt = time.Now()
+	return &r, nil
+}
+
+// FinishProbe 处理 Agent 返回的探测结果，忽略已超时或来自其他服务器的结果
+func FinishProbe(serverID uint64, result *pb.TaskResult) {
+	probes.finish(serverID, result)
+}
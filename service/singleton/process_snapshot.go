@@ -0,0 +1,57 @@
+package singleton
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/goccy/go-json"
+
+	"github.com/nezhahq/nezha/model"
+	pb "github.com/nezhahq/nezha/proto"
+)
+
+// processSnapshots 等待 Agent 返回的进程快照
+var processSnapshots = newPendingTasks()
+
+// RunProcessSnapshot 向 Agent 下发进程快照任务并等待结果，结果不保存
+func RunProcessSnapshot(s *model.Server) (*model.ProcessSnapshot, error) {
+	id, p := processSnapshots.add(s.ID)
+	defer processSnapshots.done(id)
+
+	if err := s.TaskStream.Send(&pb.Task{
+		Id:   id,
+		Type: model.TaskTypeProcessSnapshot,
+		Data: strconv.Itoa(model.ProcessSnapshotTop),
+	}); err != nil {
+		return nil, err
+	}
+
+	timeout := time.NewTimer(model.ProcessSnapshotTimeout)
+	defer timeout.Stop()
+
+	var result *pb.TaskResult
+	select {
+	case <-timeout.C:
+		return nil, Localizer.ErrorT("operation timeout")
+	case result = <-p.result:
+	}
+
+	if !result.GetSuccessful() {
+		return nil, Localizer.ErrorT("get process snapshot failed: %v", result.GetData())
+	}
+	if len(result.GetData()) > model.ReportMaxSnapshotSize {
+		return nil, Localizer.ErrorT("get process snapshot failed: %v", "process snapshot is too large")
+	}
+	var snapshot model.ProcessSnapshot
+	if err := json.Unmarshal([]byte(result.GetData()), &snapshot); err != nil {
+		return nil, Localizer.ErrorT("get process snapshot failed: %v", err)
+	}
+	snapshot.Sanitize()
+	snapshot.CreatedAt = time.Now()
+	return &snapshot, nil
+}
+
+// FinishProcessSnapshot 处理 Agent 返回的进程快照，忽略已超时或来自其他服务器的结果
+func FinishProcessSnapshot(serverID uint64, result *pb.TaskResult) {
+	processSnapshots.finish(serverID, result)
+}
@@ -0,0 +1,278 @@
+package singleton
+
+import (
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/utils"
+)
+
+// 已结束的清理任务保留的时间，用于查询结果
+const purgeJobRetention = time.Hour
+
+type purgeStep struct {
+	name string
+	// run 执行删除，progress 用于在分批删除时报告进度
+	run func(progress func(rows int64)) (int64, error)
+}
+
+// PurgeClass 在后台按时间分批清理服务器或服务的历史数据，同一对象同时只能有一个清理任务。
+// 多节点部署时任务进度同步到其他节点，任一节点都可以查询进度
+type PurgeClass struct {
+	mu     sync.Mutex
+	jobs   map[string]*model.PurgeJob
+	remote map[string]bool // 由其他节点执行的任务
+}
+
+var PurgeShared *PurgeClass
+
+func NewPurgeClass() *PurgeClass {
+	c := &PurgeClass{
+		jobs:   make(map[string]*model.PurgeJob),
+		remote: make(map[string]bool),
+	}
+	go c.worker()
+	return c
+}
+
+func (c *PurgeClass) worker() {
+	for range time.Tick(time.Minute) {
+		c.sweep()
+	}
+}
+
+// Start 开始清理对象在 before 之前的历史数据，返回的任务可通过 Get 查询进度
+func (c *PurgeClass) Start(userID uint64, entityType string, entityID uint64, before time.Time) (*model.PurgeJob, error) {
+	var steps []purgeStep
+	switch entityType {
+	case model.PurgeEntityServer:
+		steps = serverPurgeSteps(entityID, before)
+	case model.PurgeEntityService:
+		steps = servicePurgeSteps(entityID, before)
+	default:
+		return nil, Localizer.ErrorT("unsupported entity type: %s", entityType)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, j := range c.jobs {
+		if j.Status == model.PurgeJobRunning && j.EntityType == entityType && j.EntityID == entityID {
+			return nil, Localizer.ErrorT("purge job %s is already running for this %s", j.ID, entityType)
+		}
+	}
+
+	now := time.Now()
+	job := &model.PurgeJob{
+		ID:         utils.MustGenerateRandomString(16),
+		EntityType: entityType,
+		EntityID:   entityID,
+		Before:     before,
+		Status:     model.PurgeJobRunning,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	for _, s := range steps {
+		job.Steps = append(job.Steps, model.PurgeStep{Name: s.name})
+	}
+	c.jobs[job.ID] = job
+	go c.run(job, userID, steps)
+	return cloneJob(job), nil
+}
+
+// Get 返回清理任务的进度
+func (c *PurgeClass) Get(id string) (*model.PurgeJob, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	job, ok := c.jobs[id]
+	if !ok {
+		return nil, false
+	}
+	return cloneJob(job), true
+}
+
+func (c *PurgeClass) run(job *model.PurgeJob, userID uint64, steps []purgeStep) {
+	c.publish(job)
+
+	var err error
+	for i, s := range steps {
+		var rows int64
+		rows, err = s.run(func(rows int64) {
+			c.mu.Lock()
+			job.Steps[i].Rows += rows
+			job.UpdatedAt = time.Now()
+			c.mu.Unlock()
+		})
+
+		c.mu.Lock()
+		job.Steps[i].Rows = rows
+		job.Steps[i].Done = err == nil
+		job.UpdatedAt = time.Now()
+		c.mu.Unlock()
+		if err != nil {
+			break
+		}
+		c.publish(job)
+	}
+
+	afterPurge(job.EntityType, job.EntityID, job.Before)
+
+	c.mu.Lock()
+	c.finish(job, userID, err)
+	c.mu.Unlock()
+	c.publish(job)
+}
+
+// publish 将任务当前的进度同步到其他节点
+func (c *PurgeClass) publish(job *model.PurgeJob) {
+	c.mu.Lock()
+	snapshot := cloneJob(job)
+	c.mu.Unlock()
+	ClusterShared.PublishPurge(snapshot)
+}
+
+// applyRemote 保存其他节点同步的任务进度，任务结束时清理本节点内存中对应的数据
+func (c *PurgeClass) applyRemote(job *model.PurgeJob) {
+	c.mu.Lock()
+	old, ok := c.jobs[job.ID]
+	if ok && !c.remote[job.ID] {
+		c.mu.Unlock()
+		return
+	}
+	c.jobs[job.ID] = job
+	c.remote[job.ID] = true
+	finished := job.Status != model.PurgeJobRunning && (!ok || old.Status == model.PurgeJobRunning)
+	c.mu.Unlock()
+
+	if !finished {
+		return
+	}
+	for _, s := range job.Steps {
+		if !s.Done {
+			continue
+		}
+		switch s.Name {
+		case model.PurgeStepStateHistory:
+			ServerShared.PurgeStateHistory(job.EntityID, job.Before)
+		case model.PurgeStepServiceOverview:
+			ServiceSentinelShared.PurgeMonthlyStatus(job.EntityID, job.Before)
+		}
+	}
+	afterPurge(job.EntityType, job.EntityID, job.Before)
+}
+
+// afterPurge 清理结束后丢弃本节点缓存的查询结果，以及尚未写入数据库的当前监控结果
+func afterPurge(entityType string, entityID uint64, before time.Time) {
+	switch entityType {
+	case model.PurgeEntityServer:
+		QueryCacheShared.Invalidate(QueryCacheServerServiceHistory, entityID)
+	case model.PurgeEntityService:
+		ServiceSentinelShared.PurgeCurrentStatus(entityID, before)
+		invalidateServiceQueryCache()
+	}
+}
+
+// finish 结束清理任务并写入审计日志，调用时需持有锁
+func (c *PurgeClass) finish(job *model.PurgeJob, userID uint64, err error) {
+	job.Status = model.PurgeJobSuccess
+	job.UpdatedAt = time.Now()
+	if err != nil {
+		job.Status = model.PurgeJobFailure
+		job.Error = err.Error()
+		log.Printf("NEZHA>> Failed to purge history of %s %d: %v", job.EntityType, job.EntityID, err)
+	} else {
+		log.Printf("NEZHA>> Purged %d row(s) of %s %d before %s", job.Rows(), job.EntityType, job.EntityID, job.Before.Format(time.RFC3339))
+	}
+
+	entry := &model.AuditLog{
+		CreatedAt:  job.UpdatedAt,
+		UserID:     userID,
+		Method:     "TASK",
+		Route:      "purge/" + job.EntityType + "-history",
+		EntityType: job.EntityType,
+		EntityID:   strconv.FormatUint(job.EntityID, 10),
+		Summary:    job.Summary(),
+		Status:     http.StatusOK,
+		Success:    err == nil,
+		Error:      job.Error,
+	}
+	if !entry.Success {
+		entry.Status = http.StatusInternalServerError
+	}
+	AuditLogShared.Record(entry)
+}
+
+func (c *PurgeClass) sweep() {
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, job := range c.jobs {
+		// 其他节点的任务在该节点失联后不会再更新，超时后同样删除
+		if (job.Status != model.PurgeJobRunning || c.remote[id]) && now.Sub(job.UpdatedAt) > purgeJobRetention {
+			delete(c.jobs, id)
+			delete(c.remote, id)
+		}
+	}
+}
+
+func cloneJob(job *model.PurgeJob) *model.PurgeJob {
+	ret := *job
+	ret.Steps = slices.Clone(job.Steps)
+	return &ret
+}
+
+// purgeServiceHistory 删除监控记录，使用外部时序数据库时主数据库中仍可能保留迁移前的记录
+func purgeServiceHistory(serverID, serviceID uint64, before time.Time) func(progress func(int64)) (int64, error) {
+	return func(progress func(int64)) (int64, error) {
+		query, args := serviceHistoryFilter(serverID, serviceID, before)
+		total, err := deleteInBatchesFunc(progress, &model.ServiceHistory{}, query, args...)
+		if err != nil || !usingExternalTSDB() {
+			return total, err
+		}
+		n, err := TSDBShared.DeleteServiceHistory(serverID, serviceID, before)
+		return total + n, err
+	}
+}
+
+// purgeTable 分批删除表中满足条件的记录
+func purgeTable(value any, query string, args ...any) func(progress func(int64)) (int64, error) {
+	return func(progress func(int64)) (int64, error) {
+		return deleteInBatchesFunc(progress, value, query, args...)
+	}
+}
+
+func serverPurgeSteps(serverID uint64, before time.Time) []purgeStep {
+	return []purgeStep{
+		{model.PurgeStepServiceHistory, purgeServiceHistory(serverID, 0, before)},
+		{model.PurgeStepHistoryRollup, purgeTable(&model.ServiceHistoryRollup{}, "server_id = ? AND start < ?", serverID, before)},
+		{model.PurgeStepLatencySummary, purgeTable(&model.ServiceLatencySummary{}, "server_id = ? AND start < ?", serverID, before)},
+		{model.PurgeStepServiceResult, purgeTable(&model.ServiceResult{}, "server_id = ? AND created_at < ?", serverID, before)},
+		{model.PurgeStepTransfer, purgeTable(&model.Transfer{}, "server_id = ? AND created_at < ?", serverID, before)},
+		// 只删除在 before 之前已结束的日期
+		{model.PurgeStepTransferDaily, purgeTable(&model.TransferDaily{}, "server_id = ? AND date < ?", serverID, transferDay(before))},
+		// 保留最近一次补报事件，其中记录了已补报的时间，删除后 Agent 重连时会重复补报
+		{model.PurgeStepServerEvent, purgeTable(&model.ServerEvent{}, "server_id = ? AND created_at < ? AND id NOT IN (?)", serverID, before,
+			DB.Model(&model.ServerEvent{}).Select("COALESCE(MAX(id), 0)").Where("server_id = ? AND type = ?", serverID, model.ServerEventBackfilled))},
+		{model.PurgeStepServerUptime, purgeTable(&model.ServerUptime{}, "server_id = ? AND start < ?", serverID, before)},
+		{model.PurgeStepStateHistory, func(progress func(int64)) (int64, error) {
+			n, err := deleteInBatchesFunc(progress, &model.ServerStateHourly{}, "server_id = ? AND start < ?", serverID, before)
+			return n + ServerShared.PurgeStateHistory(serverID, before), err
+		}},
+	}
+}
+
+func servicePurgeSteps(serviceID uint64, before time.Time) []purgeStep {
+	return []purgeStep{
+		{model.PurgeStepServiceHistory, purgeServiceHistory(0, serviceID, before)},
+		{model.PurgeStepHistoryRollup, purgeTable(&model.ServiceHistoryRollup{}, "service_id = ? AND start < ?", serviceID, before)},
+		{model.PurgeStepLatencySummary, purgeTable(&model.ServiceLatencySummary{}, "service_id = ? AND start < ?", serviceID, before)},
+		{model.PurgeStepServiceResult, purgeTable(&model.ServiceResult{}, "service_id = ? AND created_at < ?", serviceID, before)},
+		{model.PurgeStepServiceOverview, func(func(int64)) (int64, error) {
+			return ServiceSentinelShared.PurgeMonthlyStatus(serviceID, before), nil
+		}},
+	}
+}
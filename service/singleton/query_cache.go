@@ -0,0 +1,130 @@
+package singleton
+
+import (
+	"container/list"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	QueryCacheServiceOverview      = "service_overview"
+	QueryCacheServerServiceHistory = "server_service_history"
+
+	queryCacheTTL        = 10 * time.Second
+	queryCacheMaxEntries = 1024
+)
+
+var QueryCacheShared = NewQueryCache(queryCacheTTL, queryCacheMaxEntries)
+
+// QueryCacheKey ID 为查询涉及的资源，用于按资源失效；Auth 为请求者的权限级别
+type QueryCacheKey struct {
+	Endpoint string
+	ID       uint64
+	Params   string
+	Auth     string
+}
+
+type queryCacheEntry struct {
+	key     QueryCacheKey
+	value   any
+	expires time.Time
+}
+
+// QueryCache 聚合查询结果的缓存，超过条目上限时淘汰最久未使用的条目
+// 缓存的结果由多个请求共享，调用方不能修改
+type QueryCache struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	ll         *list.List
+	items      map[QueryCacheKey]*list.Element
+	generation map[string]uint64 // 每次失效时递增，避免失效前开始的查询写入旧结果
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+func NewQueryCache(ttl time.Duration, maxEntries int) *QueryCache {
+	return &QueryCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		ll:         list.New(),
+		items:      make(map[QueryCacheKey]*list.Element),
+		generation: make(map[string]uint64),
+	}
+}
+
+// GetOrLoad 返回缓存的结果，未命中时调用 load 并缓存成功的结果
+func (qc *QueryCache) GetOrLoad(key QueryCacheKey, load func() (any, error)) (any, error) {
+	qc.mu.Lock()
+	if el, ok := qc.items[key]; ok {
+		entry := el.Value.(*queryCacheEntry)
+		if time.Now().Before(entry.expires) {
+			qc.ll.MoveToFront(el)
+			qc.mu.Unlock()
+			qc.hits.Add(1)
+			return entry.value, nil
+		}
+		qc.removeElement(el)
+	}
+	gen := qc.generation[key.Endpoint]
+	qc.mu.Unlock()
+	qc.misses.Add(1)
+
+	value, err := load()
+	if err != nil {
+		return nil, err
+	}
+
+	qc.mu.Lock()
+	defer qc.mu.Unlock()
+	if qc.generation[key.Endpoint] != gen {
+		return value, nil
+	}
+	if el, ok := qc.items[key]; ok {
+		qc.removeElement(el)
+	}
+	qc.items[key] = qc.ll.PushFront(&queryCacheEntry{key: key, value: value, expires: time.Now().Add(qc.ttl)})
+	for qc.ll.Len() > qc.maxEntries {
+		qc.removeElement(qc.ll.Back())
+	}
+	return value, nil
+}
+
+// Invalidate 使接口的缓存失效，指定 ids 时只清除涉及这些资源的条目
+func (qc *QueryCache) Invalidate(endpoint string, ids ...uint64) {
+	qc.mu.Lock()
+	defer qc.mu.Unlock()
+
+	qc.generation[endpoint]++
+	for key, el := range qc.items {
+		if key.Endpoint != endpoint {
+			continue
+		}
+		if len(ids) > 0 && !slices.Contains(ids, key.ID) {
+			continue
+		}
+		qc.removeElement(el)
+	}
+}
+
+// Stats 返回命中次数、未命中次数与当前条目数
+func (qc *QueryCache) Stats() (hits, misses uint64, size int) {
+	qc.mu.Lock()
+	size = qc.ll.Len()
+	qc.mu.Unlock()
+	return qc.hits.Load(), qc.misses.Load(), size
+}
+
+func (qc *QueryCache) removeElement(el *list.Element) {
+	qc.ll.Remove(el)
+	delete(qc.items, el.Value.(*queryCacheEntry).key)
+}
+
+// invalidateServiceQueryCache 服务变更后清除所有与服务相关的缓存
+func invalidateServiceQueryCache() {
+	QueryCacheShared.Invalidate(QueryCacheServiceOverview)
+	QueryCacheShared.Invalidate(QueryCacheServerServiceHistory)
+}
@@ -0,0 +1,140 @@
+package singleton
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+func loadCount(qc *QueryCache, key QueryCacheKey, calls *int) {
+	qc.GetOrLoad(key, func() (any, error) {
+		*calls++
+		return *calls, nil
+	})
+}
+
+func TestQueryCache(t *testing.T) {
+	qc := NewQueryCache(time.Minute, 2)
+	key := QueryCacheKey{Endpoint: QueryCacheServiceOverview, Auth: "guest"}
+
+	var calls int
+	loadCount(qc, key, &calls)
+	loadCount(qc, key, &calls)
+	if calls != 1 {
+		t.Fatalf("expected 1 load, got %d", calls)
+	}
+	if hits, misses, size := qc.Stats(); hits != 1 || misses != 1 || size != 1 {
+		t.Fatalf("unexpected stats: hits %d, misses %d, size %d", hits, misses, size)
+	}
+
+	// 权限级别不同时分别缓存
+	loadCount(qc, QueryCacheKey{Endpoint: QueryCacheServiceOverview, Auth: "admin"}, &calls)
+	if calls != 2 {
+		t.Fatalf("expected 2 loads, got %d", calls)
+	}
+
+	qc.Invalidate(QueryCacheServiceOverview)
+	loadCount(qc, key, &calls)
+	if calls != 3 {
+		t.Fatalf("expected reload after invalidation, got %d loads", calls)
+	}
+
+	// 超过条目上限时淘汰最久未使用的条目
+	for i := range 3 {
+		loadCount(qc, QueryCacheKey{Endpoint: QueryCacheServerServiceHistory, ID: uint64(i)}, &calls)
+	}
+	if _, _, size := qc.Stats(); size != 2 {
+		t.Fatalf("expected 2 entries, got %d", size)
+	}
+}
+
+func TestQueryCacheExpire(t *testing.T) {
+	qc := NewQueryCache(time.Millisecond, 10)
+	key := QueryCacheKey{Endpoint: QueryCacheServiceOverview}
+
+	var calls int
+	loadCount(qc, key, &calls)
+	time.Sleep(5 * time.Millisecond)
+	loadCount(qc, key, &calls)
+	if calls != 2 {
+		t.Fatalf("expected reload after expiry, got %d loads", calls)
+	}
+}
+
+func TestQueryCacheInvalidateByID(t *testing.T) {
+	qc := NewQueryCache(time.Minute, 10)
+	k1 := QueryCacheKey{Endpoint: QueryCacheServerServiceHistory, ID: 1}
+	k2 := QueryCacheKey{Endpoint: QueryCacheServerServiceHistory, ID: 2}
+
+	var calls int
+	loadCount(qc, k1, &calls)
+	loadCount(qc, k2, &calls)
+	qc.Invalidate(QueryCacheServerServiceHistory, 1)
+	loadCount(qc, k1, &calls)
+	loadCount(qc, k2, &calls)
+	if calls != 3 {
+		t.Fatalf("expected only server 1 to reload, got %d loads", calls)
+	}
+}
+
+// 查询期间发生失效时不缓存旧结果
+func TestQueryCacheInvalidateDuringLoad(t *testing.T) {
+	qc := NewQueryCache(time.Minute, 10)
+	key := QueryCacheKey{Endpoint: QueryCacheServiceOverview}
+
+	qc.GetOrLoad(key, func() (any, error) {
+		qc.Invalidate(QueryCacheServiceOverview)
+		return "stale", nil
+	})
+	v, _ := qc.GetOrLoad(key, func() (any, error) { return "fresh", nil })
+	if v != "fresh" {
+		t.Fatalf("expected fresh value, got %v", v)
+	}
+}
+
+func TestQueryCacheInvalidatedByDataChanges(t *testing.T) {
+	setupTestDB(t, model.Server{}, model.ServiceHistory{})
+	old := QueryCacheShared
+	QueryCacheShared = NewQueryCache(time.Minute, 10)
+	t.Cleanup(func() { QueryCacheShared = old })
+
+	key := QueryCacheKey{Endpoint: QueryCacheServerServiceHistory, ID: 1}
+	var calls int
+
+	// 新的监控结果在批量写入时才使缓存失效
+	loadCount(QueryCacheShared, key, &calls)
+	hb := newHistoryBuffer(10)
+	hb.Add(&model.ServiceHistory{ServiceID: 1, ServerID: 1})
+	hb.Add(&model.ServiceHistory{ServiceID: 2, ServerID: 1})
+	loadCount(QueryCacheShared, key, &calls)
+	if calls != 1 {
+		t.Fatalf("expected cache hit before flush, got %d loads", calls)
+	}
+	hb.Flush()
+	loadCount(QueryCacheShared, key, &calls)
+	if calls != 2 {
+		t.Fatalf("expected reload after new monitor results are written, got %d loads", calls)
+	}
+
+	// 其他服务器的监控结果不影响
+	hb.Add(&model.ServiceHistory{ServiceID: 1, ServerID: 2})
+	hb.Flush()
+	loadCount(QueryCacheShared, key, &calls)
+	if calls != 2 {
+		t.Fatalf("expected cache hit, got %d loads", calls)
+	}
+
+	// 修改与删除服务器
+	sc := NewServerClass()
+	sc.Update(&model.Server{Common: model.Common{ID: 1}, Name: "test"}, "")
+	loadCount(QueryCacheShared, key, &calls)
+	if calls != 3 {
+		t.Fatalf("expected reload after server edit, got %d loads", calls)
+	}
+	sc.Delete([]uint64{1})
+	loadCount(QueryCacheShared, key, &calls)
+	if calls != 4 {
+		t.Fatalf("expected reload after server deletion, got %d loads", calls)
+	}
+}
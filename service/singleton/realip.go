@@ -0,0 +1,51 @@
+package singleton
+
+import (
+	"net/netip"
+	"sync/atomic"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/utils"
+)
+
+// trustedProxies 解析后的可信代理列表
+var trustedProxies atomic.Pointer[utils.TrustedProxies]
+
+// updateTrustedProxies 解析配置中的可信代理
+func (c *ConfigClass) updateTrustedProxies() error {
+	proxies, err := utils.ParseTrustedProxies(c.TrustedProxies)
+	if err != nil {
+		return err
+	}
+	trustedProxies.Store(&proxies)
+	return nil
+}
+
+// TrustedProxy 连接地址是否来自可信代理，未配置可信代理时不信任任何来源
+func TrustedProxy(addr netip.Addr) bool {
+	if p := trustedProxies.Load(); p != nil {
+		return p.Trusted(addr)
+	}
+	return false
+}
+
+// RealIP 按配置的请求头解析客户端真实 IP，HTTP、WebSocket 与 gRPC 共用。
+// header 为空时不解析真实 IP，为 model.ConfigUsePeerIP 时使用连接地址；
+// 否则只接受可信代理发送的请求头，value 返回请求头的值
+func RealIP(header, peer string, value func(string) string) (string, error) {
+	switch header {
+	case "":
+		return "", nil
+	case model.ConfigUsePeerIP:
+		addr, err := utils.ParseAddrPort(peer)
+		if err != nil {
+			return "", err
+		}
+		return addr.String(), nil
+	}
+	var proxies utils.TrustedProxies
+	if p := trustedProxies.Load(); p != nil {
+		proxies = *p
+	}
+	return proxies.RealIP(peer, value(header))
+}
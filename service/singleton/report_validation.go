@@ -0,0 +1,53 @@
+package singleton
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+// 同一服务器的拒绝事件每分钟最多写入一次审计日志
+const reportRejectionAuditInterval = time.Minute
+
+var (
+	reportRejectionsMu     sync.Mutex
+	reportRejections       = make(map[uint64]uint64)
+	reportRejectionAudited = make(map[uint64]time.Time)
+)
+
+// RejectReport 记录一次未通过校验而被丢弃的 Agent 上报，kind 为上报类型
+func RejectReport(serverID uint64, kind string, err error) {
+	reportRejectionsMu.Lock()
+	reportRejections[serverID]++
+	audit := Conf.AuditRejectedReports && time.Since(reportRejectionAudited[serverID]) >= reportRejectionAuditInterval
+	if audit {
+		reportRejectionAudited[serverID] = time.Now()
+	}
+	reportRejectionsMu.Unlock()
+
+	if Conf.Debug {
+		log.Printf("NEZHA>> Rejected %s report from server %d: %v", kind, serverID, err)
+	}
+	if audit {
+		AuditLogShared.Record(&model.AuditLog{
+			CreatedAt:  time.Now(),
+			Method:     "GRPC",
+			Route:      "agent/" + kind,
+			EntityType: "server",
+			EntityID:   strconv.FormatUint(serverID, 10),
+			Status:     http.StatusBadRequest,
+			Error:      err.Error(),
+		})
+	}
+}
+
+// ReportRejections 返回服务器被丢弃的上报次数
+func ReportRejections(serverID uint64) uint64 {
+	reportRejectionsMu.Lock()
+	defer reportRejectionsMu.Unlock()
+	return reportRejections[serverID]
+}
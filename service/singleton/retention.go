@@ -0,0 +1,442 @@
+package singleton
+
+import (
+	"cmp"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/utils"
+)
+
+// 每批汇总或删除的行数，避免长时间占用写锁
+const retentionBatchSize = 5000
+
+var retentionMu sync.Mutex
+
+type historyBucket struct {
+	serviceID uint64
+	serverID  uint64
+	start     int64
+}
+
+type historyAggregate struct {
+	delay, loss, jitter float64
+	up, down, samples   uint64
+}
+
+func (a *historyAggregate) add(delay, loss, jitter float32, up, down, samples uint64) {
+	a.delay += float64(delay) * float64(samples)
+	a.loss += float64(loss) * float64(samples)
+	a.jitter += float64(jitter) * float64(samples)
+	a.up += up
+	a.down += down
+	a.samples += samples
+}
+
+func retentionCutoffs(now time.Time) (raw, hourly, daily time.Time) {
+	raw = now.AddDate(0, 0, -Conf.ServiceHistoryRawDays)
+	hourly = now.AddDate(0, 0, -Conf.ServiceHistoryHourlyDays)
+	if Conf.ServiceHistoryDailyDays > 0 {
+		daily = now.AddDate(0, 0, -Conf.ServiceHistoryDailyDays)
+	}
+	return
+}
+
+// RunHistoryRetention 将超出保留期的监控记录与延迟汇总逐级汇总，并清理过期的流量记录与原始检查结果，dryRun 为真时只统计各级将被汇总或删除的行数
+// server_id 为 0 的记录用于可用性展示，不参与分级
+func RunHistoryRetention(dryRun bool) (*model.RetentionReport, error) {
+	retentionMu.Lock()
+	defer retentionMu.Unlock()
+
+	now := time.Now()
+	rawBefore, hourlyBefore, dailyBefore := retentionCutoffs(now)
+	transferDailyBefore := transferDay(now.AddDate(0, 0, -Conf.TrafficRetentionDays))
+	report := &model.RetentionReport{
+		DryRun: dryRun,
+		Tiers: []*model.RetentionTier{
+			{Name: "raw", Before: rawBefore},
+			{Name: model.HistoryIntervalHourly, Before: hourlyBefore},
+			{Name: model.HistoryIntervalDaily, Before: dailyBefore},
+			{Name: "latency", Before: rawBefore},
+			{Name: "transfer", Before: rawBefore},
+			{Name: "transfer_daily", Before: transferDailyBefore},
+			{Name: "results", Before: rawBefore},
+		},
+	}
+	transferQueries := transferRetentionQueries(rawBefore)
+
+	if dryRun {
+		if err := DB.Model(&model.ServiceHistory{}).Where("server_id != 0 AND created_at < ?", rawBefore).
+			Count(&report.Tiers[0].Rows).Error; err != nil {
+			return nil, err
+		}
+		if store, ok := TSDBShared.(historyRollupStore); ok {
+			rollups, err := store.HourlyServiceHistory(rawBefore)
+			if err != nil {
+				return nil, err
+			}
+			for _, r := range rollups {
+				report.Tiers[0].Rows += int64(r.Samples)
+			}
+		}
+		if err := DB.Model(&model.ServiceHistoryRollup{}).Where("`interval` = ? AND start < ?", model.HistoryIntervalHourly, hourlyBefore).
+			Count(&report.Tiers[1].Rows).Error; err != nil {
+			return nil, err
+		}
+		if !dailyBefore.IsZero() {
+			if err := DB.Model(&model.ServiceHistoryRollup{}).Where("`interval` = ? AND start < ?", model.HistoryIntervalDaily, dailyBefore).
+				Count(&report.Tiers[2].Rows).Error; err != nil {
+				return nil, err
+			}
+		}
+		var err error
+		if report.Tiers[3].Rows, err = countLatencyRetention(rawBefore, hourlyBefore, dailyBefore); err != nil {
+			return nil, err
+		}
+		for _, q := range transferQueries {
+			var n int64
+			if err := DB.Model(&model.Transfer{}).Where(q.query, q.args...).Count(&n).Error; err != nil {
+				return nil, err
+			}
+			report.Tiers[4].Rows += n
+		}
+		if err := DB.Model(&model.TransferDaily{}).Where("date < ?", transferDailyBefore).Count(&report.Tiers[5].Rows).Error; err != nil {
+			return nil, err
+		}
+		if err := DB.Model(&model.ServiceResult{}).Where("created_at < ?", rawBefore).Count(&report.Tiers[6].Rows).Error; err != nil {
+			return nil, err
+		}
+		return report, nil
+	}
+
+	defer QueryCacheShared.Invalidate(QueryCacheServerServiceHistory)
+
+	var err error
+	if report.Tiers[0].Rows, err = rollupRawHistory(rawBefore); err != nil {
+		return report, err
+	}
+	if store, ok := TSDBShared.(historyRollupStore); ok {
+		n, err := rollupTSDBHistory(store, rawBefore)
+		report.Tiers[0].Rows += n
+		if err != nil {
+			return report, err
+		}
+	}
+	if report.Tiers[1].Rows, err = rollupHourlyHistory(hourlyBefore); err != nil {
+		return report, err
+	}
+	if !dailyBefore.IsZero() {
+		if report.Tiers[2].Rows, err = deleteInBatches(&model.ServiceHistoryRollup{}, "`interval` = ? AND start < ?", model.HistoryIntervalDaily, dailyBefore); err != nil {
+			return report, err
+		}
+	}
+	if _, err := deleteInBatches(&model.ServiceHistoryRollup{}, "service_id NOT IN (SELECT `id` FROM services)"); err != nil {
+		return report, err
+	}
+	if report.Tiers[3].Rows, err = retainLatencySummaries(rawBefore, hourlyBefore, dailyBefore); err != nil {
+		return report, err
+	}
+	for _, q := range transferQueries {
+		n, err := deleteInBatches(&model.Transfer{}, q.query, q.args...)
+		report.Tiers[4].Rows += n
+		if err != nil {
+			return report, err
+		}
+	}
+	if report.Tiers[5].Rows, err = deleteInBatches(&model.TransferDaily{}, "date < ?", transferDailyBefore); err != nil {
+		return report, err
+	}
+	// 原始检查结果与各监测点的原始记录保留相同天数
+	if report.Tiers[6].Rows, err = deleteInBatches(&model.ServiceResult{}, "created_at < ? OR service_id NOT IN (SELECT `id` FROM services)", rawBefore); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+type retentionQuery struct {
+	query string
+	args  []any
+}
+
+// transferRetentionQueries 返回可清理的流量记录的条件。流量记录保留 rawBefore 之后的部分，
+// 流量周期规则覆盖的服务器额外保留到周期开始，用于计算周期内的流量
+func transferRetentionQueries(rawBefore time.Time) []retentionQuery {
+	allKeep := rawBefore
+	specialKeep := make(map[uint64]time.Time)
+	var alerts []model.AlertRule
+	DB.Find(&alerts)
+	for _, alert := range alerts {
+		for _, rule := range alert.Rules {
+			if !rule.IsTransferDurationRule() {
+				continue
+			}
+			start := rule.GetTransferDurationStart().UTC()
+			if rule.Cover == model.RuleCoverAll {
+				if start.Before(allKeep) {
+					allKeep = start
+				}
+				continue
+			}
+			for id := range rule.Ignore {
+				if keep, ok := specialKeep[id]; !ok || start.Before(keep) {
+					specialKeep[id] = start
+				}
+			}
+		}
+	}
+
+	queries := make([]retentionQuery, 0, len(specialKeep)+1)
+	specialIDs := make([]uint64, 0, len(specialKeep))
+	for id, keep := range specialKeep {
+		specialIDs = append(specialIDs, id)
+		queries = append(queries, retentionQuery{"server_id = ? AND created_at < ?", []any{id, utils.IfOr(keep.Before(allKeep), keep, allKeep)}})
+	}
+	if len(specialIDs) == 0 {
+		return append(queries, retentionQuery{"created_at < ?", []any{allKeep}})
+	}
+	return append(queries, retentionQuery{"server_id NOT IN (?) AND created_at < ?", []any{specialIDs, allKeep}})
+}
+
+func cleanServiceHistoryTiers() {
+	defer markJobRun("history_retention", time.Now())
+	report, err := RunHistoryRetention(false)
+	if err != nil {
+		log.Printf("NEZHA>> Failed to downsample service history: %v", err)
+		return
+	}
+	tiers := make([]string, 0, len(report.Tiers))
+	for _, t := range report.Tiers {
+		tiers = append(tiers, fmt.Sprintf("%d %s", t.Rows, t.Name))
+	}
+	log.Printf("NEZHA>> Downsampled service history: %s row(s)", strings.Join(tiers, ", "))
+}
+
+// rollupRawHistory 将原始记录汇总为小时数据，每批在一个事务中写入汇总并删除原始记录
+func rollupRawHistory(before time.Time) (int64, error) {
+	var total int64
+	for {
+		var rows []model.ServiceHistory
+		if err := DB.Select("id, created_at, service_id, server_id, avg_delay, up, down, loss, jitter").
+			Where("server_id != 0 AND created_at < ?", before).Order("id").Limit(retentionBatchSize).
+			Find(&rows).Error; err != nil {
+			return total, err
+		}
+		if len(rows) == 0 {
+			return total, nil
+		}
+
+		aggs := make(map[historyBucket]*historyAggregate)
+		ids := make([]uint64, 0, len(rows))
+		for _, r := range rows {
+			ids = append(ids, r.ID)
+			k := historyBucket{r.ServiceID, r.ServerID, r.CreatedAt.Truncate(time.Hour).Unix()}
+			if aggs[k] == nil {
+				aggs[k] = new(historyAggregate)
+			}
+			aggs[k].add(r.AvgDelay, r.Loss, r.Jitter, r.Up, r.Down, 1)
+		}
+
+		if err := DB.Transaction(func(tx *gorm.DB) error {
+			if err := upsertHistoryRollups(tx, model.HistoryIntervalHourly, aggs); err != nil {
+				return err
+			}
+			return tx.Unscoped().Delete(&model.ServiceHistory{}, "id IN (?)", ids).Error
+		}); err != nil {
+			return total, err
+		}
+		total += int64(len(rows))
+	}
+}
+
+// rollupTSDBHistory 将外部时序数据库中的原始记录汇总为小时数据后删除
+func rollupTSDBHistory(store historyRollupStore, before time.Time) (int64, error) {
+	rollups, err := store.HourlyServiceHistory(before)
+	if err != nil || len(rollups) == 0 {
+		return 0, err
+	}
+
+	aggs := make(map[historyBucket]*historyAggregate, len(rollups))
+	for _, r := range rollups {
+		k := historyBucket{r.ServiceID, r.ServerID, r.Start.Unix()}
+		if aggs[k] == nil {
+			aggs[k] = new(historyAggregate)
+		}
+		aggs[k].add(r.AvgDelay, r.Loss, r.Jitter, r.Up, r.Down, r.Samples)
+	}
+	if err := DB.Transaction(func(tx *gorm.DB) error {
+		return upsertHistoryRollups(tx, model.HistoryIntervalHourly, aggs)
+	}); err != nil {
+		return 0, err
+	}
+	return TSDBShared.DeleteServiceHistory(0, 0, before)
+}
+
+// rollupHourlyHistory 将小时数据汇总为每日数据
+func rollupHourlyHistory(before time.Time) (int64, error) {
+	var total int64
+	for {
+		var rows []model.ServiceHistoryRollup
+		if err := DB.Where("`interval` = ? AND start < ?", model.HistoryIntervalHourly, before).
+			Order("id").Limit(retentionBatchSize).Find(&rows).Error; err != nil {
+			return total, err
+		}
+		if len(rows) == 0 {
+			return total, nil
+		}
+
+		aggs := make(map[historyBucket]*historyAggregate)
+		ids := make([]uint64, 0, len(rows))
+		for _, r := range rows {
+			ids = append(ids, r.ID)
+			year, month, day := r.Start.In(Loc).Date()
+			k := historyBucket{r.ServiceID, r.ServerID, time.Date(year, month, day, 0, 0, 0, 0, Loc).Unix()}
+			if aggs[k] == nil {
+				aggs[k] = new(historyAggregate)
+			}
+			aggs[k].add(r.AvgDelay, r.Loss, r.Jitter, r.Up, r.Down, max(r.Samples, 1))
+		}
+
+		if err := DB.Transaction(func(tx *gorm.DB) error {
+			if err := upsertHistoryRollups(tx, model.HistoryIntervalDaily, aggs); err != nil {
+				return err
+			}
+			return tx.Unscoped().Delete(&model.ServiceHistoryRollup{}, "id IN (?)", ids).Error
+		}); err != nil {
+			return total, err
+		}
+		total += int64(len(rows))
+	}
+}
+
+// upsertHistoryRollups 写入汇总数据，已存在同一时段的汇总时按记录数加权合并
+func upsertHistoryRollups(tx *gorm.DB, interval string, aggs map[historyBucket]*historyAggregate) error {
+	rollups := make([]model.ServiceHistoryRollup, 0, len(aggs))
+	for k, a := range aggs {
+		n := float64(a.samples)
+		rollups = append(rollups, model.ServiceHistoryRollup{
+			ServiceID: k.serviceID,
+			ServerID:  k.serverID,
+			Interval:  interval,
+			Start:     time.Unix(k.start, 0),
+			AvgDelay:  float32(a.delay / n),
+			Up:        a.up,
+			Down:      a.down,
+			Loss:      float32(a.loss / n),
+			Jitter:    float32(a.jitter / n),
+			Samples:   a.samples,
+		})
+	}
+
+	weighted := func(col string) clause.Expr {
+		return gorm.Expr("(" + col + " * samples + excluded." + col + " * excluded.samples) / (samples + excluded.samples)")
+	}
+	return tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "service_id"}, {Name: "server_id"}, {Name: "interval"}, {Name: "start"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"avg_delay": weighted("avg_delay"),
+			"loss":      weighted("loss"),
+			"jitter":    weighted("jitter"),
+			"up":        gorm.Expr("up + excluded.up"),
+			"down":      gorm.Expr("down + excluded.down"),
+			"samples":   gorm.Expr("samples + excluded.samples"),
+		}),
+	}).CreateInBatches(rollups, 200).Error
+}
+
+// deleteInBatches 分批删除满足条件的记录
+func deleteInBatches(value any, query string, args ...any) (int64, error) {
+	return deleteInBatchesFunc(nil, value, query, args...)
+}
+
+// deleteInBatchesFunc 同 deleteInBatches，progress 不为空时在每批删除后传入本批删除的行数
+func deleteInBatchesFunc(progress func(rows int64), value any, query string, args ...any) (int64, error) {
+	var total int64
+	for {
+		result := DB.Unscoped().Where("id IN (?)", DB.Model(value).Select("id").Where(query, args...).Limit(retentionBatchSize)).Delete(value)
+		if result.Error != nil {
+			return total, result.Error
+		}
+		total += result.RowsAffected
+		if progress != nil && result.RowsAffected > 0 {
+			progress(result.RowsAffected)
+		}
+		if result.RowsAffected < retentionBatchSize {
+			return total, nil
+		}
+	}
+}
+
+// QueryServiceHistory 查询服务器的监控记录，原始记录已被汇总的时段使用小时或每日数据补齐
+func QueryServiceHistory(serverID uint64, from, to time.Time) ([]*model.ServiceHistory, error) {
+	raw, err := TSDBShared.ServerServiceHistory(serverID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	// 补上尚未写入数据库的记录
+	if ServiceSentinelShared != nil {
+		raw = append(raw, ServiceSentinelShared.history.pending(func(h *model.ServiceHistory) bool {
+			return h.ServerID == serverID && !h.CreatedAt.Before(from) && h.CreatedAt.Before(to)
+		})...)
+	}
+
+	// 原始记录最早的时间之前由汇总数据补齐
+	end := to
+	var earliest model.ServiceHistory
+	if err := DB.Select("created_at").Where("server_id = ?", serverID).Order("created_at").Limit(1).Find(&earliest).Error; err != nil {
+		return nil, err
+	}
+	if !earliest.CreatedAt.IsZero() && earliest.CreatedAt.Before(end) {
+		end = earliest.CreatedAt
+	}
+	if !from.Before(end) {
+		sortServiceHistory(raw)
+		return raw, nil
+	}
+
+	var history []*model.ServiceHistory
+	for _, interval := range []string{model.HistoryIntervalHourly, model.HistoryIntervalDaily} {
+		var rollups []*model.ServiceHistoryRollup
+		if err := DB.Where("server_id = ? AND `interval` = ? AND start >= ? AND start < ?", serverID, interval, from, end).
+			Order("start").Find(&rollups).Error; err != nil {
+			return nil, err
+		}
+		for _, r := range rollups {
+			history = append(history, &model.ServiceHistory{
+				CreatedAt: r.Start,
+				ServiceID: r.ServiceID,
+				ServerID:  r.ServerID,
+				AvgDelay:  r.AvgDelay,
+				Up:        r.Up,
+				Down:      r.Down,
+				Loss:      r.Loss,
+				Jitter:    r.Jitter,
+			})
+		}
+		// 更早的时段使用每日数据
+		if len(rollups) > 0 && rollups[0].Start.Before(end) {
+			end = rollups[0].Start
+		}
+		if !from.Before(end) {
+			break
+		}
+	}
+
+	history = append(history, raw...)
+	sortServiceHistory(history)
+	return history, nil
+}
+
+// sortServiceHistory 按服务与时间排序
+func sortServiceHistory(history []*model.ServiceHistory) {
+	slices.SortStableFunc(history, func(a, b *model.ServiceHistory) int {
+		return cmp.Or(cmp.Compare(a.ServiceID, b.ServiceID), a.CreatedAt.Compare(b.CreatedAt))
+	})
+}
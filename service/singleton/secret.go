@@ -0,0 +1,161 @@
+package singleton
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/goccy/go-json"
+	"gorm.io/gorm"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/utils"
+)
+
+// 密文格式为 enc:<密钥 ID>:<密文>，旧版本写入的 enc:<密文> 使用 jwt_secret_key 加密
+
+// EncryptSecret 使用当前密钥加密需要存储的敏感信息
+func EncryptSecret(plaintext string) (string, error) {
+	kid := Conf.Encryption.Current
+	if kid == "" {
+		ciphertext, err := utils.EncryptString(Conf.JWTSecretKey, plaintext)
+		if err != nil {
+			return "", err
+		}
+		return model.EncryptedPrefix + ciphertext, nil
+	}
+	ciphertext, err := utils.EncryptString(Conf.Encryption.Keys[kid], plaintext)
+	if err != nil {
+		return "", err
+	}
+	return model.EncryptedPrefix + kid + ":" + ciphertext, nil
+}
+
+// DecryptSecret 解密敏感信息，未加密的值原样返回
+func DecryptSecret(value string) (string, error) {
+	ciphertext, ok := strings.CutPrefix(value, model.EncryptedPrefix)
+	if !ok {
+		return value, nil
+	}
+	kid, data, ok := strings.Cut(ciphertext, ":")
+	if !ok {
+		return utils.DecryptString(Conf.JWTSecretKey, ciphertext)
+	}
+	key, ok := Conf.Encryption.Keys[kid]
+	if !ok {
+		return "", fmt.Errorf("unknown encryption key: %s", kid)
+	}
+	return utils.DecryptString(key, data)
+}
+
+// validateEncryption 检查密钥配置，密钥 ID 中不能包含冒号
+func (c *ConfigClass) validateEncryption() error {
+	for kid, key := range c.Encryption.Keys {
+		if kid == "" || strings.Contains(kid, ":") {
+			return fmt.Errorf("invalid encryption key id: %q", kid)
+		}
+		if key == "" {
+			return fmt.Errorf("encryption key %s is empty", kid)
+		}
+	}
+	if c.Encryption.Current != "" {
+		if _, ok := c.Encryption.Keys[c.Encryption.Current]; !ok {
+			return fmt.Errorf("current encryption key %s is not defined", c.Encryption.Current)
+		}
+	}
+	return nil
+}
+
+// initSecretSerializer 使带有 serializer:secret 标签的字段在读写数据库时自动解密、加密
+func initSecretSerializer() {
+	model.SecretEncrypter = EncryptSecret
+	model.SecretDecrypter = DecryptSecret
+}
+
+// ReencryptSecrets 使用当前密钥重新加密数据库中的所有敏感信息，包括尚未加密的旧数据。
+// 逐行处理，某一行无法解密或保存时记录错误并继续处理其余记录
+func ReencryptSecrets() error {
+	var errs []error
+	count := func(n int, e []error) int {
+		errs = append(errs, e...)
+		for _, err := range e {
+			log.Printf("NEZHA>> Failed to re-encrypt %s", err)
+		}
+		return n
+	}
+
+	notifications := count(reencryptRows("notification", "id", nil, func(n *model.Notification) error {
+		return DB.Save(n).Error
+	}))
+	profiles := count(reencryptRows("ddns profile", "id", nil, func(p *model.DDNSProfile) error {
+		return DB.Save(p).Error
+	}))
+	reports := count(reencryptRows("sla report", "id", nil, func(r *model.SLAReportConfig) error {
+		return DB.Save(r).Error
+	}))
+	settings := count(reencryptRows("setting", "key", nil, func(st *model.SettingOverride) error {
+		return DB.Save(st).Error
+	}))
+	users := count(reencryptRows("user", "id", func(tx *gorm.DB) *gorm.DB {
+		return tx.Where("totp_secret <> ''")
+	}, func(u *model.User) error {
+		return DB.Model(u).Select("totp_secret").Updates(u).Error
+	}))
+	crons := count(reencryptRows("cron", "id", func(tx *gorm.DB) *gorm.DB {
+		return tx.Where("env_raw LIKE ?", "%"+model.EncryptedPrefix+"%")
+	}, reencryptCronEnv))
+
+	log.Printf("NEZHA>> Re-encrypted %d notifications, %d DDNS profiles, %d SLA reports, %d settings, %d TOTP secrets and %d cron tasks",
+		notifications, profiles, reports, settings, users, crons)
+	return errors.Join(errs...)
+}
+
+// reencryptRows 按主键逐行读取并保存，返回成功的行数及每一行的错误
+func reencryptRows[T any](kind, pk string, scope func(*gorm.DB) *gorm.DB, save func(*T) error) (int, []error) {
+	tx := DB.Model(new(T))
+	if scope != nil {
+		tx = scope(tx)
+	}
+	var keys []string
+	if err := tx.Pluck(pk, &keys).Error; err != nil {
+		return 0, []error{fmt.Errorf("%s: %w", kind, err)}
+	}
+
+	var n int
+	var errs []error
+	for _, k := range keys {
+		row := new(T)
+		err := DB.Where(pk+" = ?", k).First(row).Error
+		if err == nil {
+			err = save(row)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s %s: %w", kind, k, err))
+			continue
+		}
+		n++
+	}
+	return n, errs
+}
+
+// reencryptCronEnv 环境变量以 JSON 保存，敏感值需要单独解密后重新加密
+func reencryptCronEnv(c *model.Cron) error {
+	for i, e := range c.Env {
+		if !e.Secret {
+			continue
+		}
+		plaintext, err := DecryptSecret(e.Value)
+		if err != nil {
+			return fmt.Errorf("env %s: %w", e.Key, err)
+		}
+		if c.Env[i].Value, err = EncryptSecret(plaintext); err != nil {
+			return fmt.Errorf("env %s: %w", e.Key, err)
+		}
+	}
+	data, err := json.Marshal(c.Env)
+	if err != nil {
+		return err
+	}
+	return DB.Model(&model.Cron{}).Where("id = ?", c.ID).UpdateColumn("env_raw", string(data)).Error
+}
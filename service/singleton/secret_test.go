@@ -0,0 +1,60 @@
+package singleton
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+func TestReencryptSecrets(t *testing.T) {
+	setupTestDB(t, model.Notification{}, model.DDNSProfile{}, model.SLAReportConfig{}, model.SettingOverride{}, model.User{}, model.Cron{})
+	old := Conf
+	oldEncrypter, oldDecrypter := model.SecretEncrypter, model.SecretDecrypter
+	Conf = &ConfigClass{Config: &model.Config{}}
+	Conf.Encryption = model.EncryptionConf{Current: "old", Keys: map[string]string{"old": "old-key", "new": "new-key"}}
+	initSecretSerializer()
+	t.Cleanup(func() {
+		Conf = old
+		model.SecretEncrypter, model.SecretDecrypter = oldEncrypter, oldDecrypter
+	})
+
+	secret, err := EncryptSecret("token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cron := &model.Cron{Name: "backup", Env: []model.CronEnv{{Key: "TOKEN", Value: secret, Secret: true}}}
+	if err := DB.Create(cron).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := DB.Create(&model.SLAReportConfig{Name: "monthly", WebhookURL: "https://example.com/hook"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	broken := &model.Notification{Name: "broken", URL: "https://example.com"}
+	if err := DB.Create(broken).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := DB.Model(&model.Notification{}).Where("id = ?", broken.ID).UpdateColumn("url", "enc:missing:abc").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	Conf.Encryption.Current = "new"
+	if err := ReencryptSecrets(); err == nil || !strings.Contains(err.Error(), "notification") {
+		t.Fatalf("expected the broken notification to be reported, got %v", err)
+	}
+
+	// 无法解密的记录不影响其余记录
+	var raw []string
+	if err := DB.Raw("SELECT env_raw FROM crons").Scan(&raw).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(raw) != 1 || !strings.Contains(raw[0], model.EncryptedPrefix+"new:") {
+		t.Fatalf("expected cron env to be re-encrypted, got %v", raw)
+	}
+	if err := DB.Raw("SELECT webhook_url FROM sla_report_configs").Scan(&raw).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(raw) != 1 || !strings.HasPrefix(raw[0], model.EncryptedPrefix+"new:") {
+		t.Fatalf("expected sla report webhook to be re-encrypted, got %v", raw)
+	}
+}
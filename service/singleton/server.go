@@ -6,6 +6,8 @@ import (
 	"log"
 	"slices"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/nezhahq/nezha/model"
 	"github.com/nezhahq/nezha/pkg/ddns"
@@ -18,6 +20,15 @@ type ServerClass struct {
 	uuidToID map[string]uint64
 
 	sortedListForGuest []*model.Server
+
+	// 服务器分组成员，供服务器选择条件解析时使用，避免每次执行都查询数据库
+	groupsMu     sync.RWMutex
+	groupMembers map[uint64][]uint64
+
+	stateHistory *stateHistory
+	connections  *connectionTracker
+	ipChanges    *ipChangeTracker
+	backfills    *backfillTracker
 }
 
 func NewServerClass() *ServerClass {
@@ -25,7 +36,11 @@ func NewServerClass() *ServerClass {
 		class: class[uint64, *model.Server]{
 			list: make(map[uint64]*model.Server),
 		},
-		uuidToID: make(map[string]uint64),
+		uuidToID:     make(map[string]uint64),
+		stateHistory: newStateHistory(stateHistoryConf()),
+		connections:  newConnectionTracker(),
+		ipChanges:    newIPChangeTracker(),
+		backfills:    newBackfillTracker(),
 	}
 
 	var servers []model.Server
@@ -33,15 +48,67 @@ func NewServerClass() *ServerClass {
 	for _, s := range servers {
 		innerS := s
 		model.InitServer(&innerS)
+		initWakeSchedule(&innerS)
 		sc.list[innerS.ID] = &innerS
 		sc.uuidToID[innerS.UUID] = innerS.ID
 	}
 	sc.sortList()
+	sc.RefreshGroups()
 
 	return sc
 }
 
+// RefreshGroups 从数据库重新加载服务器分组成员
+func (c *ServerClass) RefreshGroups() {
+	var members []model.ServerGroupServer
+	if err := DB.Find(&members).Error; err != nil {
+		log.Printf("NEZHA>> Failed to load server group members: %v", err)
+		return
+	}
+	groups := make(map[uint64][]uint64)
+	for _, m := range members {
+		groups[m.ServerGroupId] = append(groups[m.ServerGroupId], m.ServerId)
+	}
+
+	c.groupsMu.Lock()
+	c.groupMembers = groups
+	c.groupsMu.Unlock()
+}
+
+// GroupMembers 返回指定分组中的服务器 ID
+func (c *ServerClass) GroupMembers(groupIDs ...uint64) []uint64 {
+	c.groupsMu.RLock()
+	defer c.groupsMu.RUnlock()
+
+	var ids []uint64
+	for _, id := range groupIDs {
+		ids = append(ids, c.groupMembers[id]...)
+	}
+	return ids
+}
+
+// reload 重新加载服务器列表，保留在线服务器的运行状态
+func (c *ServerClass) reload() {
+	sc := NewServerClass()
+
+	c.listMu.Lock()
+	for id, s := range sc.list {
+		if old, ok := c.list[id]; ok {
+			s.CopyFromRunningServer(old)
+		}
+	}
+	c.list, c.uuidToID = sc.list, sc.uuidToID
+	c.listMu.Unlock()
+	c.sortList()
+
+	c.groupsMu.Lock()
+	c.groupMembers = sc.groupMembers
+	c.groupsMu.Unlock()
+}
+
 func (c *ServerClass) Update(s *model.Server, uuid string) {
+	initWakeSchedule(s)
+
 	c.listMu.Lock()
 
 	c.list[s.ID] = s
@@ -50,6 +117,7 @@ func (c *ServerClass) Update(s *model.Server, uuid string) {
 	}
 
 	c.listMu.Unlock()
+	QueryCacheShared.Invalidate(QueryCacheServerServiceHistory, s.ID)
 
 	if s.EnableDDNS {
 		if err := c.UpdateDDNS(s, nil); err != nil {
@@ -58,6 +126,18 @@ func (c *ServerClass) Update(s *model.Server, uuid string) {
 	}
 
 	c.sortList()
+	ClusterShared.PublishChange("server")
+}
+
+// initWakeSchedule 按面板时区解析预期在线时段，无效时忽略
+func initWakeSchedule(s *model.Server) {
+	if s.WakeSchedule == nil {
+		return
+	}
+	if err := s.WakeSchedule.Validate(Loc); err != nil {
+		log.Printf("NEZHA>> Invalid wake schedule of server %d: %v", s.ID, err)
+		s.WakeSchedule = nil
+	}
 }
 
 func (c *ServerClass) Delete(idList []uint64) {
@@ -70,8 +150,15 @@ func (c *ServerClass) Delete(idList []uint64) {
 	}
 
 	c.listMu.Unlock()
+	QueryCacheShared.Invalidate(QueryCacheServerServiceHistory, idList...)
+	c.stateHistory.Delete(idList...)
+	c.connections.Delete(idList...)
+	c.ipChanges.Delete(idList...)
+	c.backfills.Delete(idList...)
+	DDNSShared.forgetServers(idList...)
 
 	c.sortList()
+	ClusterShared.PublishChange("server")
 }
 
 func (c *ServerClass) GetSortedListForGuest() []*model.Server {
@@ -81,6 +168,67 @@ func (c *ServerClass) GetSortedListForGuest() []*model.Server {
 	return slices.Clone(c.sortedListForGuest)
 }
 
+// RecordState 将服务器当前的状态记入状态历史
+func (c *ServerClass) RecordState(s *model.Server) {
+	c.stateHistory.Add(s.ID, s.LastActive, s.State)
+	s.ConnStats.Add(s.LastActive, s.State.TcpConnCount, s.State.UdpConnCount)
+	s.Freshness.Update(s.LastActive, s.Host, s.State)
+}
+
+// StateHistory 返回服务器最近一段时间的状态采样
+func (c *ServerClass) StateHistory(id uint64, d time.Duration) []model.StateSample {
+	return c.stateHistory.Since(id, time.Now().Add(-d))
+}
+
+// CustomMetricHistory 返回服务器最近一段时间的自定义指标采样，names 为空时返回全部指标
+func (c *ServerClass) CustomMetricHistory(id uint64, d time.Duration, names ...string) map[string][]model.CustomMetricSample {
+	return c.stateHistory.CustomSince(id, time.Now().Add(-d), names...)
+}
+
+// PurgeStateHistory 删除服务器在 before 之前的状态采样
+func (c *ServerClass) PurgeStateHistory(id uint64, before time.Time) int64 {
+	return c.stateHistory.Purge(id, before)
+}
+
+// UpdateCapabilities 保存 Agent 连接时上报的能力，raw 为空表示 Agent 未上报，仅在变化时写入数据库
+func (c *ServerClass) UpdateCapabilities(id uint64, raw *string) {
+	var caps *model.AgentCapabilities
+	if raw != nil {
+		caps = model.ParseAgentCapabilities(*raw)
+		normalized := caps.String()
+		raw = &normalized
+	}
+
+	c.listMu.Lock()
+	s, ok := c.list[id]
+	if !ok || (s.Capabilities == nil && caps == nil) || (s.Capabilities != nil && caps != nil && *s.Capabilities == *caps) {
+		c.listMu.Unlock()
+		return
+	}
+	s.Capabilities, s.CapabilitiesRaw = caps, raw
+	c.listMu.Unlock()
+
+	if err := DB.Model(&model.Server{}).Where("id = ?", id).Update("capabilities_raw", raw).Error; err != nil {
+		log.Printf("NEZHA>> Failed to save capabilities of server %d: %v", id, err)
+	}
+}
+
+// CheckCapability 检查 Agent 是否允许某项功能
+func CheckCapability(s *model.Server, capability string) error {
+	if !s.Supports(capability) {
+		return Localizer.ErrorT("the agent of server %s does not allow %s", s.Name, capability)
+	}
+	return nil
+}
+
+// CheckTaskOptions 检查 Agent 是否支持超时时间与环境变量，旧版本 Agent 会把 JSON 当作命令执行
+func CheckTaskOptions(s *model.Server) error {
+	if !s.Reports(model.AgentCapabilityTaskOptions) {
+		return Localizer.ErrorT("the agent of server %s does not support task timeout or environment variables", s.Name)
+	}
+	return nil
+}
+
 func (c *ServerClass) UUIDToID(uuid string) (id uint64, ok bool) {
 	c.listMu.RLock()
 	defer c.listMu.RUnlock()
@@ -90,38 +238,125 @@ func (c *ServerClass) UUIDToID(uuid string) (id uint64, ok bool) {
 }
 
 func (c *ServerClass) UpdateDDNS(server *model.Server, ip *model.IP) error {
+	return c.updateDDNS(server, ip, server.DDNSProfiles, false)
+}
+
+// CheckDDNS 按各配置的地址来源解析地址，与最近一次更新时不同的配置立即更新，
+// 其余开启了验证的配置检查公网解析结果，与地址不一致时更新。ip 为空时使用 GeoIP 中的地址
+func (c *ServerClass) CheckDDNS(server *model.Server, ip *model.IP) error {
+	if ip == nil {
+		if server.GeoIP == nil {
+			return nil
+		}
+		ip = &server.GeoIP.IP
+	}
+
+	var changed, verify []uint64
+	c.listMu.RLock()
+	for _, id := range server.DDNSProfiles {
+		p, ok := DDNSShared.Get(id)
+		if !ok {
+			continue
+		}
+		addrs, _ := p.SourceIP(server, ip)
+		if DDNSShared.addrsChanged(server.ID, id, addrs) {
+			changed = append(changed, id)
+		} else if p.Verify && DDNSShared.allowVerify(server.ID, id) {
+			verify = append(verify, id)
+		}
+	}
+	c.listMu.RUnlock()
+
+	if len(changed) > 0 {
+		if err := c.updateDDNS(server, ip, changed, false); err != nil {
+			return err
+		}
+	}
+	if len(verify) > 0 {
+		return c.updateDDNS(server, ip, verify, true)
+	}
+	return nil
+}
+
+// SetConnectionIP 记录面板观察到的 Agent 连接 IP，返回是否变化
+func (c *ServerClass) SetConnectionIP(s *model.Server, ip string) bool {
+	c.listMu.Lock()
+	defer c.listMu.Unlock()
+
+	if s.ConnectionIP == ip {
+		return false
+	}
+	s.ConnectionIP = ip
+	return true
+}
+
+func (c *ServerClass) updateDDNS(server *model.Server, ip *model.IP, profiles []uint64, verify bool) error {
 	confServers := strings.Split(Conf.DNSServers, ",")
 	ctx := context.WithValue(context.Background(), ddns.DNSServerKey{}, utils.IfOr(confServers[0] != "", confServers, utils.DNSServers))
 
-	providers, err := DDNSShared.GetDDNSProvidersFromProfiles(server.DDNSProfiles, utils.IfOr(ip != nil, ip, &server.GeoIP.IP))
+	providers, err := DDNSShared.GetDDNSProvidersFromProfiles(profiles, utils.IfOr(ip != nil, ip, &server.GeoIP.IP))
 	if err != nil {
 		return err
 	}
 
+	// 连接 IP 由 SetConnectionIP 在锁内写入
+	c.listMu.RLock()
+	for _, provider := range providers {
+		var errs []error
+		provider.IPAddrs, errs = provider.DDNSProfile.SourceIP(server, provider.IPAddrs)
+		for _, err := range errs {
+			log.Printf("NEZHA>> [DDNS %s] Skip updating server %d: %v", provider.DDNSProfile.Name, server.ID, err)
+		}
+	}
+	c.listMu.RUnlock()
+
 	for _, provider := range providers {
+		DDNSShared.setAddrs(server.ID, provider.GetProfileID(), provider.IPAddrs)
+		provider.ServerID = server.ID
+		provider.Record = saveDDNSHistory
 		domains := server.OverrideDDNSDomains[provider.GetProfileID()]
 		go func(provider *ddns.Provider) {
-			provider.UpdateDomain(ctx, domains...)
+			if verify {
+				provider.VerifyDomain(ctx, domains...)
+			} else {
+				provider.UpdateDomain(ctx, domains...)
+			}
 		}(provider)
 	}
 
 	return nil
 }
 
+// RunDDNSProfile 立即使用服务器当前 IP 更新该配置的记录，不检查 IP 是否变化，返回触发的服务器
+func (c *ServerClass) RunDDNSProfile(profileID uint64) ([]uint64, error) {
+	servers := make([]uint64, 0)
+	for _, server := range c.GetSortedList() {
+		if !server.EnableDDNS || server.GeoIP == nil || !slices.Contains(server.DDNSProfiles, profileID) {
+			continue
+		}
+		if err := c.updateDDNS(server, nil, []uint64{profileID}, false); err != nil {
+			return nil, err
+		}
+		servers = append(servers, server.ID)
+	}
+	return servers, nil
+}
+
 func (c *ServerClass) sortList() {
 	c.listMu.RLock()
 	defer c.listMu.RUnlock()
 	c.sortedListMu.Lock()
 	defer c.sortedListMu.Unlock()
 
-	c.sortedList = utils.MapValuesToSlice(c.list)
+	sortedList := utils.MapValuesToSlice(c.list)
 	// 按照服务器 ID 排序的具体实现（ID越大越靠前）
-	slices.SortStableFunc(c.sortedList, func(a, b *model.Server) int {
+	slices.SortStableFunc(sortedList, func(a, b *model.Server) int {
 		if a.DisplayIndex == b.DisplayIndex {
 			return cmp.Compare(a.ID, b.ID)
 		}
 		return cmp.Compare(b.DisplayIndex, a.DisplayIndex)
 	})
+	c.setSortedList(sortedList)
 
 	c.sortedListForGuest = make([]*model.Server, 0, len(c.sortedList))
 	for _, s := range c.sortedList {
@@ -0,0 +1,126 @@
+package singleton
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+// connectionReconnectGrace 流断开后在此时间内视为等待 Agent 重连
+const connectionReconnectGrace = 2 * time.Minute
+
+// connectionTracker 按服务器记录 Agent gRPC 流的打开、活动与断开
+type connectionTracker struct {
+	mu    sync.Mutex
+	conns map[uint64]*model.ServerConnection
+}
+
+func newConnectionTracker() *connectionTracker {
+	return &connectionTracker{conns: make(map[uint64]*model.ServerConnection)}
+}
+
+func (t *connectionTracker) get(id uint64) *model.ServerConnection {
+	conn, ok := t.conns[id]
+	if !ok {
+		conn = &model.ServerConnection{}
+		t.conns[id] = conn
+	}
+	return conn
+}
+
+// Delete 删除服务器的连接记录
+func (t *connectionTracker) Delete(ids ...uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, id := range ids {
+		delete(t.conns, id)
+	}
+}
+
+// StreamOpened 记录 Agent 打开了一个流
+func (c *ServerClass) StreamOpened(id uint64) {
+	t := c.connections
+	t.mu.Lock()
+
+	now := time.Now()
+	conn := t.get(id)
+	prev := conn.Evaluate(now, connectionIdleAfter(), connectionReconnectGrace)
+	if conn.Streams == 0 {
+		conn.ConnectedAt = now
+	}
+	conn.Streams++
+	conn.LastActivity = now
+	state := conn.Evaluate(now, connectionIdleAfter(), connectionReconnectGrace)
+	t.mu.Unlock()
+	recordConnectionState(id, prev, state, "")
+}
+
+// StreamActive 记录流收到了消息
+func (c *ServerClass) StreamActive(id uint64) {
+	t := c.connections
+	t.mu.Lock()
+	now := time.Now()
+	conn := t.get(id)
+	prev := conn.Evaluate(now, connectionIdleAfter(), connectionReconnectGrace)
+	conn.LastActivity = now
+	state := conn.Evaluate(now, connectionIdleAfter(), connectionReconnectGrace)
+	t.mu.Unlock()
+	recordConnectionState(id, prev, state, "")
+}
+
+// StreamClosed 记录流结束，err 为流结束的原因
+func (c *ServerClass) StreamClosed(id uint64, err error) {
+	t := c.connections
+	t.mu.Lock()
+	now := time.Now()
+	conn := t.get(id)
+	prev := conn.Evaluate(now, connectionIdleAfter(), connectionReconnectGrace)
+	conn.Streams = max(conn.Streams-1, 0)
+	if conn.Streams == 0 {
+		conn.DisconnectedAt = now
+	}
+	var reason string
+	if err != nil {
+		reason = err.Error()
+		conn.LastError, conn.LastErrorAt = reason, now
+	}
+	state := conn.Evaluate(now, connectionIdleAfter(), connectionReconnectGrace)
+	t.mu.Unlock()
+	recordConnectionState(id, prev, state, reason)
+}
+
+// Connection 返回服务器当前的连接状态
+func (c *ServerClass) Connection(id uint64) *model.ServerConnection {
+	t := c.connections
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var conn model.ServerConnection
+	if p, ok := t.conns[id]; ok {
+		conn = *p
+	}
+	conn.State = conn.Evaluate(time.Now(), connectionIdleAfter(), connectionReconnectGrace)
+	return &conn
+}
+
+func connectionIdleAfter() time.Duration {
+	return time.Duration(Conf.GRPCKeepalive.Time) * time.Second
+}
+
+// recordConnectionState 连接状态变化时记录服务器事件，reason 为流异常结束的原因，需在释放锁之后调用
+func recordConnectionState(id uint64, prev, state, reason string) {
+	if prev == state {
+		return
+	}
+	if Conf.Debug {
+		log.Printf("NEZHA>> Server %d connection state changed: %s -> %s", id, prev, state)
+	}
+	changes := []model.ServerEventChange{{Field: "state", Old: prev, New: state}}
+	if reason != "" {
+		changes = append(changes, model.ServerEventChange{Field: "error", New: reason})
+	}
+	RecordServerEvent(id, model.ServerEventConnection, model.ServerEventActorAgent, 0, changes...)
+}
@@ -0,0 +1,101 @@
+package singleton
+
+import (
+	"log"
+	"slices"
+	"time"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+// RecordServerEvent 记录服务器的生命周期事件，失败时只记录日志
+func RecordServerEvent(serverID uint64, typ, actorType string, actorID uint64, changes ...model.ServerEventChange) {
+	event := &model.ServerEvent{
+		ServerID:  serverID,
+		Type:      typ,
+		ActorType: actorType,
+		ActorID:   actorID,
+		Changes:   changes,
+	}
+	if err := DB.Create(event).Error; err != nil {
+		log.Printf("NEZHA>> Failed to save server event %s of server %d: %v", typ, serverID, err)
+	}
+}
+
+// RecordServerUpdate 按接口修改前后的服务器记录生成事件，只修改名称时记为重命名
+func RecordServerUpdate(old, new *model.Server, userID uint64) {
+	changes := model.DiffServer(old, new)
+	if len(changes) == 0 {
+		return
+	}
+	typ := model.ServerEventUpdated
+	if len(changes) == 1 && changes[0].Field == "name" {
+		typ = model.ServerEventRenamed
+	}
+	RecordServerEvent(new.ID, typ, model.ServerEventActorUser, userID, changes...)
+}
+
+// RecordServerDeletion 记录服务器被删除的事件，需在从 ServerShared 中移除之前调用
+func RecordServerDeletion(ids []uint64, actorType string, actorID uint64) {
+	for _, id := range ids {
+		if s, ok := ServerShared.Get(id); ok {
+			RecordServerEvent(id, model.ServerEventDeleted, actorType, actorID,
+				model.ServerEventChange{Field: "name", Old: s.Name})
+		}
+	}
+}
+
+// RecordAgentVersion 在 Agent 上报的版本变化时记录事件，需在更新 server.Host 之前调用
+func RecordAgentVersion(server *model.Server, version string) {
+	if version == "" {
+		return
+	}
+	var old string
+	if server.Host != nil {
+		old = server.Host.Version
+	}
+	if old == "" {
+		// 面板重启后内存中没有版本，以最近一次记录为准
+		var last model.ServerEvent
+		if err := DB.Where("server_id = ? AND type = ?", server.ID, model.ServerEventAgentVersion).
+			Order("id DESC").Limit(1).Find(&last).Error; err == nil {
+			if change, ok := last.Change("version"); ok {
+				old, _ = change.New.(string)
+			}
+		}
+	}
+	if old == version {
+		return
+	}
+	RecordServerEvent(server.ID, model.ServerEventAgentVersion, model.ServerEventActorAgent, 0,
+		model.ServerEventChange{Field: "version", Old: old, New: version})
+}
+
+// ListServerEvents 按时间倒序返回服务器的生命周期事件
+func ListServerEvents(serverID uint64, limit int) ([]*model.ServerEvent, error) {
+	var events []*model.ServerEvent
+	err := DB.Where("server_id = ?", serverID).Order("id DESC").Limit(limit).Find(&events).Error
+	return events, err
+}
+
+// cleanServerEvents 删除超出保留期的事件，已删除服务器的其余事件随之删除，删除事件保留至保留期结束
+func cleanServerEvents() {
+	DB.Unscoped().Delete(&model.ServerEvent{}, "created_at < ? OR (type <> ? AND server_id NOT IN (SELECT `id` FROM servers))",
+		time.Now().AddDate(0, 0, -Conf.ServerEventRetentionDays), model.ServerEventDeleted)
+}
+
+// RecordGroupMembership 按分组成员的变化记录服务器加入、离开分组的事件
+func RecordGroupMembership(groupID uint64, before, after []uint64, userID uint64) {
+	change := model.ServerEventChange{Field: "server_group", New: groupID}
+	for _, id := range after {
+		if !slices.Contains(before, id) {
+			RecordServerEvent(id, model.ServerEventGroupJoined, model.ServerEventActorUser, userID, change)
+		}
+	}
+	change = model.ServerEventChange{Field: "server_group", Old: groupID}
+	for _, id := range before {
+		if !slices.Contains(after, id) {
+			RecordServerEvent(id, model.ServerEventGroupLeft, model.ServerEventActorUser, userID, change)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package singleton
+
+import (
+	"slices"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+// isAdminUser 判断用户是否为管理员，不存在的用户按普通成员处理
+func isAdminUser(userID uint64) bool {
+	UserLock.RLock()
+	defer UserLock.RUnlock()
+	u, ok := UserInfoMap[userID]
+	return ok && u.Role == model.RoleAdmin
+}
+
+// ResolveServerSelector 将服务器选择条件解析为 userID 有权访问且当前匹配的服务器 ID，按 ID 排序
+func ResolveServerSelector(sel *model.ServerSelector, userID uint64) []uint64 {
+	ids := make([]uint64, 0)
+	if sel.IsEmpty() {
+		return ids
+	}
+
+	admin := isAdminUser(userID)
+	members := ServerShared.GroupMembers(sel.ServerGroups...)
+	for id, s := range ServerShared.Range {
+		if slices.Contains(sel.Exclude, id) || (!admin && s.UserID != userID) {
+			continue
+		}
+		matched := sel.All || slices.Contains(sel.Servers, id) || slices.Contains(members, id)
+		if !matched {
+			matched = slices.ContainsFunc(sel.Tags, s.HasTag)
+		}
+		if matched {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	return ids
+}
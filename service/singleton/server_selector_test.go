@@ -0,0 +1,42 @@
+package singleton
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+func TestResolveServerSelector(t *testing.T) {
+	oldServers, oldUsers := ServerShared, UserInfoMap
+	defer func() { ServerShared, UserInfoMap = oldServers, oldUsers }()
+
+	ServerShared = &ServerClass{
+		class: class[uint64, *model.Server]{list: map[uint64]*model.Server{
+			1: {Common: model.Common{ID: 1, UserID: 10}},
+			2: {Common: model.Common{ID: 2, UserID: 20}},
+			3: {Common: model.Common{ID: 3, UserID: 10}},
+		}},
+		groupMembers: map[uint64][]uint64{5: {1, 2}},
+	}
+	UserInfoMap = map[uint64]model.UserInfo{1: {Role: model.RoleAdmin}, 10: {Role: model.RoleMember}}
+
+	cases := []struct {
+		sel    model.ServerSelector
+		userID uint64
+		want   []uint64
+	}{
+		{model.ServerSelector{All: true}, 1, []uint64{1, 2, 3}},
+		// 成员通过全部、分组匹配时不会解析到其他用户的服务器
+		{model.ServerSelector{All: true}, 10, []uint64{1, 3}},
+		{model.ServerSelector{ServerGroups: []uint64{5}}, 10, []uint64{1}},
+		{model.ServerSelector{ServerGroups: []uint64{5}, Exclude: []uint64{1}}, 1, []uint64{2}},
+		// 不存在的用户按普通成员处理
+		{model.ServerSelector{All: true}, 20, []uint64{2}},
+	}
+	for i, c := range cases {
+		if got := ResolveServerSelector(&c.sel, c.userID); !slices.Equal(got, c.want) {
+			t.Errorf("case %d: expected %v, got %v", i, c.want, got)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package singleton
+
+import (
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+// RecordServerUptime 采样各服务器当前的在线状态，计入所在小时的汇总。
+// 从未上报过的服务器及计划关机时段内离线的服务器不计入，不影响可用率
+func RecordServerUptime() {
+	now := time.Now()
+	start := now.Truncate(time.Hour)
+	var rows []model.ServerUptime
+	ServerShared.Range(func(_ uint64, s *model.Server) bool {
+		if s.LastActive.IsZero() {
+			return true
+		}
+		online := now.Sub(s.LastActive) < s.OnlineTimeout(10*time.Second)
+		if s.ScheduledOff(now, online) {
+			return true
+		}
+		row := model.ServerUptime{ServerID: s.ID, Start: start}
+		if online {
+			row.Up = 1
+		} else {
+			row.Down = 1
+		}
+		rows = append(rows, row)
+		return true
+	})
+	if len(rows) == 0 {
+		return
+	}
+	if err := DB.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "server_id"}, {Name: "start"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"up":   gorm.Expr("server_uptimes.up + excluded.up"),
+			"down": gorm.Expr("server_uptimes.down + excluded.down"),
+		}),
+	}).Create(&rows).Error; err != nil {
+		log.Printf("NEZHA>> Failed to save server uptime: %v", err)
+	}
+}
+
+// cleanServerUptime 删除已删除服务器及超出保留期的在线采样
+func cleanServerUptime() {
+	DB.Unscoped().Delete(&model.ServerUptime{}, "server_id NOT IN (SELECT `id` FROM servers) OR start < ?",
+		time.Now().AddDate(0, 0, -statusPageDays))
+}
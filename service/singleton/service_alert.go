@@ -0,0 +1,56 @@
+package singleton
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+// SyncServiceAlertRule 按服务监控的通知设置创建或删除对应的 service_down 报警规则
+// 规则已存在时只同步名称与通知组，保留在报警规则中修改的持续时间、触发模式等设置
+func SyncServiceAlertRule(s *model.Service) error {
+	var rule model.AlertRule
+	err := DB.Where("service_id = ?", s.ID).First(&rule).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	found := err == nil
+
+	if !s.Notify {
+		if found {
+			return DeleteServiceAlertRules([]uint64{s.ID})
+		}
+		return nil
+	}
+
+	if found {
+		rule.Name, rule.NotificationGroupID = s.Name, s.NotificationGroupID
+	} else {
+		rule = *model.ServiceAlertRule(s)
+	}
+	if err := DB.Save(&rule).Error; err != nil {
+		return err
+	}
+	OnRefreshOrAddAlert(&rule)
+	ClusterShared.PublishChange("alert-rule")
+	return nil
+}
+
+// DeleteServiceAlertRules 删除服务监控的通知设置生成的报警规则
+func DeleteServiceAlertRules(serviceIDs []uint64) error {
+	var ids []uint64
+	if err := DB.Model(&model.AlertRule{}).Where("service_id IN (?)", serviceIDs).Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := DB.Unscoped().Delete(&model.AlertRule{}, "id IN (?)", ids).Error; err != nil {
+		return err
+	}
+	OnDeleteAlert(ids)
+	ClusterShared.PublishChange("alert-rule")
+	return nil
+}
@@ -0,0 +1,93 @@
+package singleton
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/i18n"
+)
+
+func TestCheckServiceAlert(t *testing.T) {
+	oldConf, oldLocalizer, oldCache := Conf, Localizer, Cache
+	oldNotification, oldSentinel := NotificationShared, ServiceSentinelShared
+	oldStore, oldPrev := alertsStore, alertsPrevState
+	Conf = &ConfigClass{Config: &model.Config{NotificationDedupWindow: 3600}}
+	Localizer = i18n.NewLocalizer("en_US", domain, "translations", i18n.Translations)
+	Cache = cache.New(time.Minute, time.Minute)
+	t.Cleanup(func() {
+		Conf, Localizer, Cache = oldConf, oldLocalizer, oldCache
+		NotificationShared, ServiceSentinelShared = oldNotification, oldSentinel
+		alertsStore, alertsPrevState = oldStore, oldPrev
+	})
+	// 触发任务在后台执行，测试结束后可能仍在运行，不恢复
+	if CronShared == nil {
+		CronShared = &CronClass{}
+	}
+
+	n := &model.Notification{Common: model.Common{ID: 1}, Name: "n"}
+	NotificationShared = &NotificationClass{
+		groupToIDList: map[uint64]map[uint64]*model.Notification{2: {1: n}},
+		groupList:     map[uint64]string{2: "g"},
+	}
+	service := &model.Service{Common: model.Common{ID: 3, UserID: 1}, Name: "api"}
+	status := &serviceTaskStatus{lastStatus: StatusDown, lastError: "probe: timeout"}
+	ServiceSentinelShared = &ServiceSentinel{
+		services:                 map[uint64]*model.Service{3: service},
+		serviceCurrentStatusData: map[uint64]*serviceTaskStatus{3: status},
+	}
+
+	enable := true
+	alert := &model.AlertRule{
+		Common:              model.Common{ID: 5, UserID: 1},
+		Name:                "api down",
+		Enable:              &enable,
+		NotificationGroupID: 2,
+		Rules:               []*model.Rule{{Type: "service_down", Services: []uint64{3}, Duration: 1}},
+	}
+	alertsStore = map[uint64]map[uint64][][]bool{5: {}}
+	alertsPrevState = map[uint64]map[uint64]uint8{5: {}}
+
+	incident := alertDigestKey{notificationID: 1, serviceID: 3}
+	resolved := alertDigestKey{notificationID: 1, serviceID: 3, resolved: true}
+	t.Cleanup(func() {
+		alertDigests.mu.Lock()
+		delete(alertDigests.pending, incident)
+		delete(alertDigests.pending, resolved)
+		alertDigests.mu.Unlock()
+	})
+	waitDigest := func(key alertDigestKey) *alertDigest {
+		for range 100 {
+			alertDigests.mu.Lock()
+			d := alertDigests.pending[key]
+			alertDigests.mu.Unlock()
+			if d != nil {
+				return d
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		return nil
+	}
+
+	checkServiceAlert(alert)
+	if alertsPrevState[5][3] != _RuleCheckFail {
+		t.Fatal("expected service down to fail the rule")
+	}
+	// 服务报警与服务器报警一样经过合并发送
+	d := waitDigest(incident)
+	if d == nil || !strings.Contains(d.title, "api") || !strings.Contains(strings.Join(d.alerts, ","), "probe: timeout") {
+		t.Fatalf("unexpected incident digest: %+v", d)
+	}
+
+	status.lastStatus = StatusGood
+	checkServiceAlert(alert)
+	if alertsPrevState[5][3] != _RuleCheckPass {
+		t.Fatal("expected service recovery to pass the rule")
+	}
+	if waitDigest(resolved) == nil {
+		t.Fatal("expected a resolved notification")
+	}
+}
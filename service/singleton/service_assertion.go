@@ -0,0 +1,120 @@
+package singleton
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+const assertionDefaultTimeout = 10 * time.Second
+
+var errAssertionAddrDenied = errors.New("address is not allowed")
+
+// assertionChecker 旧版 Agent 不执行 HTTP 断言，由面板按检查间隔请求目标并缓存断言结果
+type assertionChecker struct {
+	mu      sync.Mutex
+	results map[uint64]*assertionResult
+	client  *http.Client
+}
+
+type assertionResult struct {
+	checked time.Time
+	running bool
+	err     error
+}
+
+func newAssertionChecker() *assertionChecker {
+	dialer := &net.Dialer{
+		// 与探测任务一样不请求禁止探测的网段
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if probeAddrDenied(host) {
+				return errAssertionAddrDenied
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &assertionChecker{
+		results: make(map[uint64]*assertionResult),
+		client:  &http.Client{Transport: transport},
+	}
+}
+
+// Check 返回服务最近一次断言的结果，结果超过检查间隔时在后台重新检查，尚未检查过时视为通过
+func (a *assertionChecker) Check(s *model.Service) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	r, ok := a.results[s.ID]
+	if !ok {
+		r = &assertionResult{}
+		a.results[s.ID] = r
+	}
+	if !r.running && time.Since(r.checked) >= time.Duration(max(s.Duration, 1))*time.Second {
+		r.running = true
+		go a.run(s.ID, s.Target, s.TimeoutSeconds, s.HTTPAssertion)
+	}
+	return r.err
+}
+
+// Forget 删除服务时清理缓存的结果
+func (a *assertionChecker) Forget(id uint64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.results, id)
+}
+
+func (a *assertionChecker) run(id uint64, target string, timeout uint32, assertion *model.HTTPAssertion) {
+	err := a.evaluate(target, timeout, assertion)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if r, ok := a.results[id]; ok {
+		r.checked, r.running, r.err = time.Now(), false, err
+	}
+}
+
+func (a *assertionChecker) evaluate(target string, timeout uint32, assertion *model.HTTPAssertion) error {
+	d := assertionDefaultTimeout
+	if timeout > 0 {
+		d = time.Duration(timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	client := *a.client
+	if !assertion.ShouldFollowRedirects() {
+		client.CheckRedirect = func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var body []byte
+	if assertion.BodyKeyword != "" {
+		if body, err = io.ReadAll(io.LimitReader(resp.Body, assertion.BodyLimit())); err != nil {
+			return err
+		}
+	}
+	return assertion.Evaluate(resp.StatusCode, resp.Header, body)
+}
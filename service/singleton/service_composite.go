@@ -0,0 +1,226 @@
+package singleton
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/nezhahq/nezha/model"
+	pb "github.com/nezhahq/nezha/proto"
+)
+
+type compositeStatus struct {
+	code     uint8
+	summary  string
+	children []model.CompositeChildStatus
+	reporter uint64 // 优先取第一个异常子服务的监测点，没有异常时取第一个有数据的子服务的监测点
+}
+
+func compositeReport(id uint64) ReportData {
+	return ReportData{
+		Data: &pb.TaskResult{
+			Id:   id,
+			Type: model.TaskTypeComposite,
+		},
+	}
+}
+
+func reporterName(m map[uint64]*model.Server, id uint64) string {
+	if server, ok := m[id]; ok && server != nil {
+		return server.Name
+	}
+	if id == 0 {
+		return Localizer.T("Composite")
+	}
+	return fmt.Sprintf("#%d", id)
+}
+
+// evaluateComposite 按组合策略汇总子服务的当前状态，子服务均无数据时返回 StatusNoData
+func (ss *ServiceSentinel) evaluateComposite(cs *model.Service) compositeStatus {
+	var ret compositeStatus
+	cfg := cs.Composite
+	if cfg == nil {
+		ret.code = StatusNoData
+		return ret
+	}
+
+	ss.serviceResponseDataStoreLock.RLock()
+	states := make(map[uint64]uint8, len(cfg.Children))
+	reporters := make(map[uint64]uint64, len(cfg.Children))
+	for _, id := range cfg.Children {
+		if ts := ss.serviceCurrentStatusData[id]; ts != nil && ts.lastStatus != 0 {
+			states[id] = ts.lastStatus
+			reporters[id] = ts.lastReporter
+		} else {
+			states[id] = StatusNoData
+		}
+	}
+	ss.serviceResponseDataStoreLock.RUnlock()
+
+	var up, total int
+	var upWeight, totalWeight float32
+	var worst uint8
+	var failing []string
+	for _, id := range cfg.Children {
+		child := model.CompositeChildStatus{
+			ServiceID: id,
+			Status:    statusCodeName(states[id]),
+			Weight:    cfg.Weight(id),
+		}
+		if s, ok := ss.Get(id); ok {
+			child.ServiceName = s.Name
+		}
+		ret.children = append(ret.children, child)
+
+		if states[id] == StatusNoData {
+			continue
+		}
+		total++
+		totalWeight += child.Weight
+		worst = max(worst, states[id])
+		if ret.reporter == 0 {
+			ret.reporter = reporters[id]
+		}
+		if states[id] == StatusGood || states[id] == StatusLowAvailability {
+			up++
+			upWeight += child.Weight
+		} else {
+			if len(failing) == 0 {
+				ret.reporter = reporters[id]
+			}
+			name := child.ServiceName
+			if name == "" {
+				name = fmt.Sprintf("#%d", id)
+			}
+			failing = append(failing, name)
+		}
+	}
+
+	if total == 0 {
+		ret.code = StatusNoData
+		return ret
+	}
+
+	switch cfg.Policy {
+	case model.CompositePolicyQuorum:
+		ret.code = StatusDown
+		if up >= int(cfg.Quorum) {
+			ret.code = StatusGood
+		}
+	case model.CompositePolicyWeighted:
+		ret.code = StatusDown
+		if totalWeight > 0 && upWeight/totalWeight*100 >= cfg.Threshold {
+			ret.code = StatusGood
+		}
+	default:
+		// 状态码越大越差，取最差的子服务状态
+		ret.code = worst
+	}
+
+	ret.summary = fmt.Sprintf("%d/%d up", up, total)
+	if len(failing) > 0 {
+		ret.summary += ", down: " + strings.Join(failing, ", ")
+	}
+	return ret
+}
+
+// CompositeParents 返回引用了该服务的组合服务
+func (ss *ServiceSentinel) CompositeParents(childID uint64) []uint64 {
+	ss.servicesLock.RLock()
+	defer ss.servicesLock.RUnlock()
+
+	var parents []uint64
+	for id, s := range ss.services {
+		if s.Type == model.TaskTypeComposite && s.Composite != nil && slices.Contains(s.Composite.Children, childID) {
+			parents = append(parents, id)
+		}
+	}
+	return parents
+}
+
+// propagateComposite 重新计算引用了该服务的组合服务状态
+func (ss *ServiceSentinel) propagateComposite(childID uint64) {
+	for _, id := range ss.CompositeParents(childID) {
+		ss.refreshComposite(id)
+	}
+}
+
+// refreshComposite 子服务状态变更后立即更新组合服务的状态并发送通知。
+// 检查记录只由组合服务自身的定时检查写入，避免重复计入在线率
+func (ss *ServiceSentinel) refreshComposite(id uint64) {
+	cs, ok := ss.Get(id)
+	if !ok {
+		return
+	}
+	status := ss.evaluateComposite(cs)
+	if status.code == StatusNoData {
+		return
+	}
+	r := compositeReport(id)
+	r.Reporter = status.reporter
+	r.Data.Successful = status.code == StatusGood || status.code == StatusLowAvailability
+	r.Data.Data = status.summary
+	m := ServerShared.GetList()
+
+	ss.serviceResponseDataStoreLock.Lock()
+	ts := ss.serviceCurrentStatusData[id]
+	if ts == nil || ts.lastStatus == status.code {
+		ss.serviceResponseDataStoreLock.Unlock()
+		return
+	}
+	lastStatus := ts.lastStatus
+	ts.lastStatus = status.code
+	ts.lastReporter = status.reporter
+	notifyCheck(&r, m, cs, r.Data, nil, ts, lastStatus, status.code)
+	ss.serviceResponseDataStoreLock.Unlock()
+
+	QueryCacheShared.Invalidate(QueryCacheServiceOverview)
+	ss.propagateComposite(id)
+}
+
+// CheckCompositeCycle 检查组合服务的子服务中是否存在循环引用
+func (ss *ServiceSentinel) CheckCompositeCycle(id uint64, cfg *model.CompositeServiceConfig) error {
+	visited := make(map[uint64]bool)
+	var visit func(children []uint64) error
+	visit = func(children []uint64) error {
+		for _, c := range children {
+			if c == id && id != 0 {
+				return Localizer.ErrorT("composite service cannot reference itself")
+			}
+			if visited[c] {
+				continue
+			}
+			visited[c] = true
+
+			s, ok := ss.Get(c)
+			if !ok {
+				return Localizer.ErrorT("service id %d does not exist", c)
+			}
+			if s.Type == model.TaskTypeComposite && s.Composite != nil {
+				if err := visit(s.Composite.Children); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	return visit(cfg.Children)
+}
+
+// GetCompositeStatus 获取组合服务及其子服务的当前状态
+func (ss *ServiceSentinel) GetCompositeStatus(cs *model.Service) *model.CompositeServiceStatus {
+	status := ss.evaluateComposite(cs)
+	ret := &model.CompositeServiceStatus{
+		ServiceID: cs.ID,
+		Status:    statusCodeName(status.code),
+		Summary:   status.summary,
+		Children:  status.children,
+	}
+	if cs.Composite != nil {
+		ret.Policy = cs.Composite.Policy
+	}
+	if ret.Children == nil {
+		ret.Children = []model.CompositeChildStatus{}
+	}
+	return ret
+}
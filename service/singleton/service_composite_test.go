@@ -0,0 +1,91 @@
+package singleton
+
+import (
+	"testing"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/i18n"
+)
+
+func newCompositeTestSentinel(states map[uint64]uint8) *ServiceSentinel {
+	ss := &ServiceSentinel{
+		services:                 make(map[uint64]*model.Service),
+		serviceCurrentStatusData: make(map[uint64]*serviceTaskStatus),
+	}
+	for id, code := range states {
+		ss.services[id] = &model.Service{Common: model.Common{ID: id}, Type: model.TaskTypeHTTPGet}
+		ss.serviceCurrentStatusData[id] = &serviceTaskStatus{lastStatus: code, lastReporter: id * 10}
+	}
+	return ss
+}
+
+func TestEvaluateComposite(t *testing.T) {
+	ss := newCompositeTestSentinel(map[uint64]uint8{
+		1: StatusGood,
+		2: StatusGood,
+		3: StatusDown,
+		4: StatusNoData,
+	})
+
+	cases := []struct {
+		name     string
+		cfg      *model.CompositeServiceConfig
+		code     uint8
+		reporter uint64
+	}{
+		{"NoConfig", nil, StatusNoData, 0},
+		{"AllGood", &model.CompositeServiceConfig{Children: []uint64{1, 2}, Policy: model.CompositePolicyAll}, StatusGood, 10},
+		{"AllWithDown", &model.CompositeServiceConfig{Children: []uint64{1, 3}, Policy: model.CompositePolicyAll}, StatusDown, 30},
+		{"AllNoData", &model.CompositeServiceConfig{Children: []uint64{4}, Policy: model.CompositePolicyAll}, StatusNoData, 0},
+		{"QuorumMet", &model.CompositeServiceConfig{Children: []uint64{1, 2, 3}, Policy: model.CompositePolicyQuorum, Quorum: 2}, StatusGood, 30},
+		{"QuorumMissed", &model.CompositeServiceConfig{Children: []uint64{1, 2, 3}, Policy: model.CompositePolicyQuorum, Quorum: 3}, StatusDown, 30},
+		{"WeightedMet", &model.CompositeServiceConfig{Children: []uint64{1, 3}, Policy: model.CompositePolicyWeighted,
+			Weights: map[uint64]float32{1: 3}, Threshold: 75}, StatusGood, 30},
+		{"WeightedMissed", &model.CompositeServiceConfig{Children: []uint64{1, 3}, Policy: model.CompositePolicyWeighted,
+			Weights: map[uint64]float32{3: 3}, Threshold: 50}, StatusDown, 30},
+		{"WeightedIgnoresNoData", &model.CompositeServiceConfig{Children: []uint64{1, 4}, Policy: model.CompositePolicyWeighted,
+			Threshold: 100}, StatusGood, 10},
+	}
+
+	for _, c := range cases {
+		status := ss.evaluateComposite(&model.Service{Type: model.TaskTypeComposite, Composite: c.cfg})
+		if status.code != c.code || status.reporter != c.reporter {
+			t.Errorf("%s: expected status %d reported by %d, got %d by %d (%s)", c.name, c.code, c.reporter, status.code, status.reporter, status.summary)
+		}
+	}
+}
+
+func TestCheckCompositeCycle(t *testing.T) {
+	oldLocalizer := Localizer
+	Localizer = i18n.NewLocalizer("en_US", domain, "translations", i18n.Translations)
+	t.Cleanup(func() { Localizer = oldLocalizer })
+
+	ss := newCompositeTestSentinel(map[uint64]uint8{1: StatusGood, 2: StatusGood})
+	ss.services[10] = &model.Service{Common: model.Common{ID: 10}, Type: model.TaskTypeComposite,
+		Composite: &model.CompositeServiceConfig{Children: []uint64{1, 11}}}
+	ss.services[11] = &model.Service{Common: model.Common{ID: 11}, Type: model.TaskTypeComposite,
+		Composite: &model.CompositeServiceConfig{Children: []uint64{2}}}
+
+	cases := []struct {
+		name  string
+		id    uint64
+		cfg   *model.CompositeServiceConfig
+		valid bool
+	}{
+		{"New", 0, &model.CompositeServiceConfig{Children: []uint64{10, 2}}, true},
+		{"Self", 12, &model.CompositeServiceConfig{Children: []uint64{12}}, false},
+		{"Indirect", 11, &model.CompositeServiceConfig{Children: []uint64{2, 10}}, false},
+		{"SharedChild", 11, &model.CompositeServiceConfig{Children: []uint64{1, 2}}, true},
+		{"MissingChild", 0, &model.CompositeServiceConfig{Children: []uint64{99}}, false},
+	}
+
+	for _, c := range cases {
+		if err := ss.CheckCompositeCycle(c.id, c.cfg); (err == nil) != c.valid {
+			t.Errorf("%s: expected valid=%v, got %v", c.name, c.valid, err)
+		}
+	}
+
+	if parents := ss.CompositeParents(2); len(parents) != 1 || parents[0] != 11 {
+		t.Errorf("expected service 2 to be referenced by 11, got %v", parents)
+	}
+}
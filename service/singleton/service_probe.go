@@ -0,0 +1,136 @@
+package singleton
+
+import (
+	"log"
+	"slices"
+	"time"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+const probeChangeHistorySize = 20
+
+// SelectProbes 按服务的监测点选择规则选出本次执行任务的 Agent
+// 候选顺序固定（指定服务器优先，其余按 ID 排序），离线的监测点会依次由后续候选顶替
+func (ss *ServiceSentinel) SelectProbes(service *model.Service, filter func(*model.Server) bool) []*model.Server {
+	sel := service.ProbeSelector
+	if sel == nil {
+		sel = &model.ServiceProbeSelector{}
+	}
+
+	var selected []*model.Server
+	for _, id := range probeCandidates(sel, service.UserID) {
+		server, _ := ServerShared.Get(id)
+		if server == nil || server.TaskStream == nil || time.Since(server.LastActive) > server.OnlineTimeout(10*time.Second) {
+			continue
+		}
+		var countryCode string
+		if server.GeoIP != nil {
+			countryCode = server.GeoIP.CountryCode
+		}
+		if !sel.MatchCountry(countryCode) || !filter(server) {
+			continue
+		}
+		selected = append(selected, server)
+		if sel.Limit > 0 && len(selected) >= int(sel.Limit) {
+			break
+		}
+	}
+
+	ids := make([]uint64, 0, len(selected))
+	for _, server := range selected {
+		ids = append(ids, server.ID)
+	}
+	ss.updateProbeAssignment(service.ID, ids)
+	return selected
+}
+
+// probeCandidates 返回服务所有者有权访问的候选监测点，指定的服务器按顺序在前，其余按 ID 排序
+func probeCandidates(sel *model.ServiceProbeSelector, userID uint64) []uint64 {
+	match := sel.ServerSelector
+	if match.IsEmpty() {
+		match.All = true
+	}
+	matched := ResolveServerSelector(&match, userID)
+
+	ids := make([]uint64, 0, len(matched))
+	for _, id := range sel.Servers {
+		if slices.Contains(matched, id) && !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	for _, id := range matched {
+		if !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func (ss *ServiceSentinel) updateProbeAssignment(serviceID uint64, servers []uint64) {
+	ss.probeAssignmentLock.Lock()
+	defer ss.probeAssignmentLock.Unlock()
+
+	pa, ok := ss.probeAssignments[serviceID]
+	if !ok {
+		ss.probeAssignments[serviceID] = &model.ServiceProbeAssignment{
+			ServiceID:  serviceID,
+			Servers:    servers,
+			LastChecks: make(map[uint64]time.Time),
+		}
+		return
+	}
+	if slices.Equal(pa.Servers, servers) {
+		return
+	}
+
+	change := model.ServiceProbeChange{Time: time.Now()}
+	for _, id := range servers {
+		if !slices.Contains(pa.Servers, id) {
+			change.Added = append(change.Added, id)
+		}
+	}
+	for _, id := range pa.Servers {
+		if !slices.Contains(servers, id) {
+			change.Removed = append(change.Removed, id)
+		}
+	}
+	log.Printf("NEZHA>> Probes of service %d changed: added %v, removed %v", serviceID, change.Added, change.Removed)
+
+	pa.Servers = servers
+	pa.Changes = append(pa.Changes, change)
+	if len(pa.Changes) > probeChangeHistorySize {
+		pa.Changes = pa.Changes[len(pa.Changes)-probeChangeHistorySize:]
+	}
+}
+
+// recordProbeCheck 记录执行监控任务的监测点
+func (ss *ServiceSentinel) recordProbeCheck(serviceID, serverID uint64) {
+	ss.probeAssignmentLock.Lock()
+	defer ss.probeAssignmentLock.Unlock()
+
+	if pa, ok := ss.probeAssignments[serviceID]; ok {
+		pa.LastChecks[serverID] = time.Now()
+	}
+}
+
+// GetProbeAssignment 获取服务当前分配的监测点及变更记录
+func (ss *ServiceSentinel) GetProbeAssignment(serviceID uint64) *model.ServiceProbeAssignment {
+	ss.probeAssignmentLock.RLock()
+	defer ss.probeAssignmentLock.RUnlock()
+
+	pa, ok := ss.probeAssignments[serviceID]
+	if !ok {
+		return &model.ServiceProbeAssignment{ServiceID: serviceID, Servers: []uint64{}}
+	}
+	ret := &model.ServiceProbeAssignment{
+		ServiceID:  serviceID,
+		Servers:    slices.Clone(pa.Servers),
+		LastChecks: make(map[uint64]time.Time, len(pa.LastChecks)),
+		Changes:    slices.Clone(pa.Changes),
+	}
+	for k, v := range pa.LastChecks {
+		ret.LastChecks[k] = v
+	}
+	return ret
+}
@@ -0,0 +1,74 @@
+package singleton
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nezhahq/nezha/model"
+	pb "github.com/nezhahq/nezha/proto"
+)
+
+// evaluateQuorum 记录监测点本次的结果，并按评估窗口内所有监测点的结果判定服务状态，
+// 调用方需持有 serviceResponseDataStoreLock
+func (ss *ServiceSentinel) evaluateQuorum(cs *model.Service, reporter uint64, mh *pb.TaskResult, ts *serviceTaskStatus) *model.ServiceQuorum {
+	if ts.votes == nil {
+		ts.votes = make(map[uint64]model.ServiceProbeVote)
+	}
+	now := time.Now()
+	ts.votes[reporter] = model.ServiceProbeVote{
+		ServerID:   reporter,
+		Successful: mh.Successful,
+		Data:       mh.Data,
+		Time:       now,
+	}
+
+	return cs.EvaluateQuorum(ts.votes, ss.expectedProbes(cs), now)
+}
+
+// expectedProbes 返回本节点上应当执行该服务检查的监测点数，作为判定比例的分母。
+// 监测结果只会上报到 Agent 所连接的节点，因此只统计连接到本节点的 Agent，
+// 避免尚未上报的监测点被忽略，导致第一个失败的结果就判定服务故障
+func (ss *ServiceSentinel) expectedProbes(cs *model.Service) int {
+	var n int
+	switch cs.Cover {
+	case model.ServiceCoverSelected:
+		ss.probeAssignmentLock.RLock()
+		if pa, ok := ss.probeAssignments[cs.ID]; ok {
+			n = len(pa.Servers)
+		}
+		ss.probeAssignmentLock.RUnlock()
+	case model.ServiceCoverIgnoreAll:
+		for id, server := range ServerShared.Range {
+			if server != nil && server.TaskStream != nil && cs.SkipServers[id] && cs.CanRunOn(server) {
+				n++
+			}
+		}
+	case model.ServiceCoverAll:
+		for id, server := range ServerShared.Range {
+			if server != nil && server.TaskStream != nil && !cs.SkipServers[id] && cs.CanRunOn(server) {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// probeVoteList 列出监测点及其所在地区，withData 为真时附带失败原因
+func probeVoteList(m map[uint64]*model.Server, votes []model.ServiceProbeVote, withData bool) string {
+	if len(votes) == 0 {
+		return "-"
+	}
+	items := make([]string, 0, len(votes))
+	for _, v := range votes {
+		item := reporterName(m, v.ServerID)
+		if server, ok := m[v.ServerID]; ok && server != nil && server.GeoIP != nil && server.GeoIP.CountryCode != "" {
+			item = fmt.Sprintf("%s (%s)", item, strings.ToUpper(server.GeoIP.CountryCode))
+		}
+		if withData && v.Data != "" {
+			item += ": " + v.Data
+		}
+		items = append(items, item)
+	}
+	return strings.Join(items, "; ")
+}
@@ -0,0 +1,114 @@
+package singleton
+
+import (
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+// serviceResultBuffer 缓冲开启了原始结果记录的服务的检查结果，随监控记录一起定期批量写入
+type serviceResultBuffer struct {
+	mu      sync.Mutex
+	rows    []*model.ServiceResult
+	flushMu sync.Mutex
+}
+
+func (b *serviceResultBuffer) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		b.Flush()
+	}
+}
+
+// Add 缓冲一条检查结果，缓冲已满时丢弃
+func (b *serviceResultBuffer) Add(r *model.ServiceResult) {
+	if r.CreatedAt.IsZero() {
+		r.CreatedAt = time.Now()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.rows) >= historyBufferCapacity {
+		log.Printf("NEZHA>> Service result buffer is full, dropped the result of service %d", r.ServiceID)
+		return
+	}
+	b.rows = append(b.rows, r)
+}
+
+// Flush 将缓冲的检查结果写入数据库，失败时放回缓冲等待下次写入
+func (b *serviceResultBuffer) Flush() {
+	b.flushMu.Lock()
+	defer b.flushMu.Unlock()
+
+	b.mu.Lock()
+	rows := b.rows
+	b.rows = nil
+	b.mu.Unlock()
+	if len(rows) == 0 {
+		return
+	}
+
+	if err := DB.CreateInBatches(rows, historyInsertBatch).Error; err != nil {
+		log.Printf("NEZHA>> Failed to save %d service results: %v", len(rows), err)
+		for _, r := range rows {
+			r.ID = 0
+		}
+		b.mu.Lock()
+		n := min(len(rows), historyBufferCapacity-len(b.rows))
+		b.rows = append(rows[len(rows)-n:], b.rows...)
+		b.mu.Unlock()
+	}
+}
+
+func (b *serviceResultBuffer) Delete(serviceID uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	rows := b.rows[:0]
+	for _, r := range b.rows {
+		if r.ServiceID != serviceID {
+			rows = append(rows, r)
+		}
+	}
+	b.rows = rows
+}
+
+// pending 返回服务在时间范围内尚未写入数据库的检查结果副本
+func (b *serviceResultBuffer) pending(serviceID uint64, from, to time.Time) []model.ServiceResult {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var rows []model.ServiceResult
+	for _, r := range b.rows {
+		if r.ServiceID == serviceID && !r.CreatedAt.Before(from) && r.CreatedAt.Before(to) {
+			rows = append(rows, *r)
+		}
+	}
+	return rows
+}
+
+// ServiceResults 按游标分页查询服务在时间范围内的原始检查结果，游标为上一页最后一条记录的 ID，
+// 返回满页时同时返回下一页的游标，最后一页包含尚未写入数据库的结果
+func (ss *ServiceSentinel) ServiceResults(serviceID uint64, from, to time.Time, cursor string, limit int) ([]model.ServiceResult, string, error) {
+	var after uint64
+	if cursor != "" {
+		var err error
+		if after, err = strconv.ParseUint(cursor, 10, 64); err != nil {
+			return nil, "", Localizer.ErrorT("invalid cursor: %s", cursor)
+		}
+	}
+
+	var rows []model.ServiceResult
+	if err := DB.Where("service_id = ? AND created_at >= ? AND created_at < ? AND id > ?", serviceID, from, to, after).
+		Order("id").Limit(limit).Find(&rows).Error; err != nil {
+		return nil, "", err
+	}
+	if len(rows) < limit {
+		return append(rows, ss.results.pending(serviceID, from, to)...), "", nil
+	}
+	return rows, strconv.FormatUint(rows[len(rows)-1].ID, 10), nil
+}
@@ -0,0 +1,133 @@
+package singleton
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/goccy/go-json"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/utils"
+)
+
+const (
+	serviceWebhookQueueSize = 1024
+	serviceWebhookWorkers   = 4
+	serviceWebhookTimeout   = 10 * time.Second
+)
+
+type serviceWebhookJob struct {
+	url   string
+	event *model.ServiceCheckEvent
+}
+
+// serviceWebhookQueue 异步推送服务监控事件，队列满时丢弃事件以免阻塞监控结果处理
+type serviceWebhookQueue struct {
+	jobs chan serviceWebhookJob
+}
+
+func newServiceWebhookQueue() *serviceWebhookQueue {
+	q := &serviceWebhookQueue{
+		jobs: make(chan serviceWebhookJob, serviceWebhookQueueSize),
+	}
+	for range serviceWebhookWorkers {
+		go q.worker()
+	}
+	return q
+}
+
+func (q *serviceWebhookQueue) Push(url string, event *model.ServiceCheckEvent) {
+	select {
+	case q.jobs <- serviceWebhookJob{url: url, event: event}:
+	default:
+		log.Printf("NEZHA>> Service webhook queue is full, dropping %s event of service %d", event.Event, event.ServiceID)
+	}
+}
+
+func (q *serviceWebhookQueue) worker() {
+	for job := range q.jobs {
+		if err := postWebhook(job.url, job.event); err != nil {
+			log.Printf("NEZHA>> Failed to send service webhook of service %d: %v", job.event.ServiceID, err)
+		}
+	}
+}
+
+// postWebhook 以 JSON 格式推送事件
+func postWebhook(url string, event any) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), serviceWebhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := utils.HttpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// statusCodeName 返回状态码对应的英文标识，用于对外推送
+func statusCodeName(statusCode uint8) string {
+	switch statusCode {
+	case StatusNoData:
+		return "no_data"
+	case StatusGood:
+		return "good"
+	case StatusLowAvailability:
+		return "low_availability"
+	case StatusDown:
+		return "down"
+	default:
+		return ""
+	}
+}
+
+// sendServiceWebhook 服务状态变更时推送事件，开启逐次推送时每次检查结果都会推送
+func (ss *ServiceSentinel) sendServiceWebhook(cs *model.Service, r *ReportData, lastStatus, stateCode uint8) {
+	if cs.WebhookURL == "" {
+		return
+	}
+	changed := lastStatus != stateCode
+	if !changed && !cs.WebhookEveryCheck {
+		return
+	}
+
+	event := &model.ServiceCheckEvent{
+		Event:       model.ServiceEventCheck,
+		ServiceID:   cs.ID,
+		ServiceName: cs.Name,
+		ServerID:    r.Reporter,
+		Successful:  r.Data.Successful,
+		Delay:       r.Data.Delay,
+		Status:      statusCodeName(stateCode),
+		Time:        time.Now(),
+	}
+	if changed {
+		event.Event = model.ServiceEventStateChange
+		event.LastStatus = statusCodeName(lastStatus)
+	}
+	if !r.Data.Successful {
+		event.Reason = r.Data.Data
+	}
+	if server, ok := ServerShared.Get(r.Reporter); ok {
+		event.ServerName = server.Name
+	}
+	ss.webhooks.Push(cs.WebhookURL, event)
+}
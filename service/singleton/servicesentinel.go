@@ -12,10 +12,12 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/goccy/go-json"
 	"github.com/jinzhu/copier"
 	"golang.org/x/exp/constraints"
 
 	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/i18n"
 	"github.com/nezhahq/nezha/pkg/utils"
 	pb "github.com/nezhahq/nezha/proto"
 )
@@ -45,14 +47,22 @@ type _TodayStatsOfService struct {
 type serviceResponseData = _TodayStatsOfService
 
 type serviceTaskStatus struct {
-	lastStatus uint8
-	t          time.Time
-	result     []*pb.TaskResult
+	lastStatus   uint8
+	lastError    string // 最近一次失败的原因，用于服务规则的通知
+	lastReporter uint64 // 最近一次上报结果的监测点，组合服务通知与触发任务时使用子服务的监测点
+	t            time.Time
+	result       []*pb.TaskResult
+
+	streaks map[uint64]*reporterStreak // [ClientID] -> 连续结果，仅在配置了连续失败阈值时使用
+
+	votes map[uint64]model.ServiceProbeVote // [ClientID] -> 最近一次结果，仅在配置了多监测点判定时使用
 }
 
 type pingStore struct {
-	count int
-	ping  float32
+	count  int
+	ping   float32
+	loss   float32
+	jitter float32
 }
 
 /*
@@ -73,16 +83,33 @@ type ServiceSentinel struct {
 	serviceResponseDataStore     map[uint64]serviceResponseData   // 当前数据
 
 	serviceResponsePing map[uint64]map[uint64]*pingStore // [service_id] -> ClientID -> delay
-	tlsCertCache        map[uint64]string
+	dnsProbeLock        sync.RWMutex
+	dnsProbeResults     map[uint64]map[uint64]*model.DNSProbeResult // [service_id] -> ClientID -> 最近一次 DNS 查询结果
+	tlsCertCacheLock    sync.RWMutex
+	tlsCertCache        map[uint64]*model.ServiceCert
+	probeAssignmentLock sync.RWMutex
+	probeAssignments    map[uint64]*model.ServiceProbeAssignment // [service_id] -> 按规则选择的监测点
 
 	servicesLock    sync.RWMutex
 	serviceListLock sync.RWMutex
 	services        map[uint64]*model.Service
 	serviceList     []*model.Service
+	userServiceList map[uint64][]*model.Service // 按所属用户分组的 serviceList
 
 	// 30天数据缓存
 	monthlyStatusLock sync.Mutex
 	monthlyStatus     map[uint64]*serviceResponseItem
+
+	// 延迟分布汇总
+	latency *latencyAggregator
+	// 待写入的监控记录
+	history *historyBuffer
+	// 待写入的原始检查结果
+	results *serviceResultBuffer
+	// 监控结果 Webhook 推送队列
+	webhooks *serviceWebhookQueue
+	// 旧版 Agent 上报结果的 HTTP 断言检查
+	assertions *assertionChecker
 }
 
 // NewServiceSentinel 创建服务监控器
@@ -94,10 +121,17 @@ func NewServiceSentinel(serviceSentinelDispatchBus chan<- *model.Service) (*Serv
 		serviceResponseDataStore: make(map[uint64]serviceResponseData),
 		serviceResponsePing:      make(map[uint64]map[uint64]*pingStore),
 		services:                 make(map[uint64]*model.Service),
-		tlsCertCache:             make(map[uint64]string),
+		tlsCertCache:             make(map[uint64]*model.ServiceCert),
+		dnsProbeResults:          make(map[uint64]map[uint64]*model.DNSProbeResult),
+		probeAssignments:         make(map[uint64]*model.ServiceProbeAssignment),
 		// 30天数据缓存
 		monthlyStatus: make(map[uint64]*serviceResponseItem),
 		dispatchBus:   serviceSentinelDispatchBus,
+		latency:       newLatencyAggregator(),
+		history:       newHistoryBuffer(historyBufferCapacity),
+		results:       &serviceResultBuffer{},
+		webhooks:      newServiceWebhookQueue(),
+		assertions:    newAssertionChecker(),
 	}
 
 	// 加载历史记录
@@ -128,6 +162,8 @@ func NewServiceSentinel(serviceSentinelDispatchBus chan<- *model.Service) (*Serv
 
 	// 启动服务监控器
 	go ss.worker()
+	go ss.history.run(historyFlushInterval)
+	go ss.results.run(historyFlushInterval)
 
 	// 每日将游标往后推一天
 	_, err = CronShared.AddFunc("0 0 0 * * *", ss.refreshMonthlyServiceStatus)
@@ -135,10 +171,17 @@ func NewServiceSentinel(serviceSentinelDispatchBus chan<- *model.Service) (*Serv
 		return nil, err
 	}
 
+	// 每分钟持久化已结束间隔的延迟分布
+	_, err = CronShared.AddFunc("0 * * * * *", func() { ss.latency.Flush(false) })
+	if err != nil {
+		return nil, err
+	}
+
 	return ss, nil
 }
 
 func (ss *ServiceSentinel) refreshMonthlyServiceStatus() {
+	defer QueryCacheShared.Invalidate(QueryCacheServiceOverview)
 	// 刷新数据防止无人访问
 	ss.LoadStats()
 	// 将数据往前刷一天
@@ -166,6 +209,17 @@ func (ss *ServiceSentinel) refreshMonthlyServiceStatus() {
 	}
 }
 
+// scheduleFunc 返回服务监控的定时任务，组合服务由面板直接汇总状态
+func (ss *ServiceSentinel) scheduleFunc(m *model.Service) func() {
+	return func() {
+		if m.Type == model.TaskTypeComposite {
+			ss.Dispatch(compositeReport(m.ID))
+			return
+		}
+		ss.dispatchBus <- m
+	}
+}
+
 // Dispatch 将传入的 ReportData 传给 服务状态汇报管道
 func (ss *ServiceSentinel) Dispatch(r ReportData) {
 	ss.serviceReportChannel <- r
@@ -182,6 +236,7 @@ func (ss *ServiceSentinel) UpdateServiceList() {
 	slices.SortFunc(ss.serviceList, func(a, b *model.Service) int {
 		return cmp.Compare(a.ID, b.ID)
 	})
+	ss.userServiceList = groupByUser(ss.serviceList)
 }
 
 // loadServiceHistory 加载服务监控器的历史状态信息
@@ -195,9 +250,7 @@ func (ss *ServiceSentinel) loadServiceHistory() error {
 	for _, service := range services {
 		task := service
 		// 通过cron定时将服务监控任务传递给任务调度管道
-		service.CronJobID, err = CronShared.AddFunc(task.CronSpec(), func() {
-			ss.dispatchBus <- task
-		})
+		service.CronJobID, err = CronShared.AddFunc(task.CronSpec(), ss.scheduleFunc(task))
 		if err != nil {
 			return err
 		}
@@ -207,6 +260,16 @@ func (ss *ServiceSentinel) loadServiceHistory() error {
 		ss.serviceStatusToday[service.ID] = &_TodayStatsOfService{}
 	}
 	ss.serviceList = services
+	ss.userServiceList = groupByUser(services)
+
+	// 加载证书信息
+	var certs []*model.ServiceCert
+	if err := DB.Find(&certs).Error; err != nil {
+		return err
+	}
+	for _, cert := range certs {
+		ss.tlsCertCache[cert.ServiceID] = cert
+	}
 
 	year, month, day := time.Now().Date()
 	today := time.Date(year, month, day, 0, 0, 0, 0, Loc)
@@ -243,6 +306,7 @@ func (ss *ServiceSentinel) loadServiceHistory() error {
 }
 
 func (ss *ServiceSentinel) Update(m *model.Service) error {
+	defer invalidateServiceQueryCache()
 	ss.serviceResponseDataStoreLock.Lock()
 	defer ss.serviceResponseDataStoreLock.Unlock()
 	ss.monthlyStatusLock.Lock()
@@ -250,11 +314,12 @@ func (ss *ServiceSentinel) Update(m *model.Service) error {
 	ss.servicesLock.Lock()
 	defer ss.servicesLock.Unlock()
 
+	// 断言可能已修改，丢弃缓存的断言结果
+	ss.assertions.Forget(m.ID)
+
 	var err error
 	// 写入新任务
-	m.CronJobID, err = CronShared.AddFunc(m.CronSpec(), func() {
-		ss.dispatchBus <- m
-	})
+	m.CronJobID, err = CronShared.AddFunc(m.CronSpec(), ss.scheduleFunc(m))
 	if err != nil {
 		return err
 	}
@@ -282,7 +347,71 @@ func (ss *ServiceSentinel) Update(m *model.Service) error {
 	return nil
 }
 
+// reload 重新加载数据库中的服务监控任务
+func (ss *ServiceSentinel) reload() error {
+	var services []*model.Service
+	if err := DB.Find(&services).Error; err != nil {
+		return err
+	}
+
+	ss.Delete(slices.Collect(maps.Keys(ss.GetList())))
+	for _, s := range services {
+		if err := ss.Update(s); err != nil {
+			log.Printf("NEZHA>> Failed to register service %d: %v", s.ID, err)
+		}
+	}
+	ss.UpdateServiceList()
+	return nil
+}
+
+// PurgeMonthlyStatus 清零服务在 before 之前已结束的日期的 30 天可用性统计，返回清零的天数
+func (ss *ServiceSentinel) PurgeMonthlyStatus(serviceID uint64, before time.Time) int64 {
+	ss.monthlyStatusLock.Lock()
+	defer ss.monthlyStatusLock.Unlock()
+
+	v, ok := ss.monthlyStatus[serviceID]
+	if !ok {
+		return 0
+	}
+	year, month, day := time.Now().Date()
+	today := time.Date(year, month, day, 0, 0, 0, 0, Loc)
+	var days int64
+	// 第 29 项为当天，由 serviceStatusToday 刷新
+	for i := range 29 {
+		if today.AddDate(0, 0, i-28).After(before) {
+			break
+		}
+		v.TotalUp -= v.Up[i]
+		v.TotalDown -= v.Down[i]
+		v.Up[i], v.Down[i], v.Delay[i] = 0, 0, 0
+		days++
+	}
+	return days
+}
+
+// PurgeCurrentStatus 清理范围包含今天时，丢弃尚未写入数据库的当前监控结果与今日统计
+func (ss *ServiceSentinel) PurgeCurrentStatus(serviceID uint64, before time.Time) {
+	year, month, day := time.Now().Date()
+	if before.Before(time.Date(year, month, day, 0, 0, 0, 0, Loc)) {
+		return
+	}
+
+	ss.serviceResponseDataStoreLock.Lock()
+	defer ss.serviceResponseDataStoreLock.Unlock()
+	if status, ok := ss.serviceCurrentStatusData[serviceID]; ok {
+		status.result = status.result[:0]
+		status.t = time.Time{}
+	}
+	if _, ok := ss.serviceResponseDataStore[serviceID]; ok {
+		ss.serviceResponseDataStore[serviceID] = serviceResponseData{}
+	}
+	if stats, ok := ss.serviceStatusToday[serviceID]; ok {
+		*stats = _TodayStatsOfService{}
+	}
+}
+
 func (ss *ServiceSentinel) Delete(ids []uint64) {
+	defer invalidateServiceQueryCache()
 	ss.serviceResponseDataStoreLock.Lock()
 	defer ss.serviceResponseDataStoreLock.Unlock()
 	ss.monthlyStatusLock.Lock()
@@ -293,7 +422,19 @@ func (ss *ServiceSentinel) Delete(ids []uint64) {
 	for _, id := range ids {
 		delete(ss.serviceCurrentStatusData, id)
 		delete(ss.serviceResponseDataStore, id)
+		ss.tlsCertCacheLock.Lock()
 		delete(ss.tlsCertCache, id)
+		ss.tlsCertCacheLock.Unlock()
+		ss.dnsProbeLock.Lock()
+		delete(ss.dnsProbeResults, id)
+		ss.dnsProbeLock.Unlock()
+		ss.probeAssignmentLock.Lock()
+		delete(ss.probeAssignments, id)
+		ss.probeAssignmentLock.Unlock()
+		ss.latency.Delete(id)
+		ss.history.Delete(id)
+		ss.results.Delete(id)
+		ss.assertions.Forget(id)
 		delete(ss.serviceStatusToday, id)
 
 		// 停掉定时任务
@@ -351,12 +492,67 @@ func (ss *ServiceSentinel) CopyStats() map[uint64]model.ServiceResponseItem {
 		}
 
 		service.ServiceName = service.service.Name
+		service.TLSCert = ss.getTLSCert(k)
 		sri[k] = service.ServiceResponseItem
 	}
 
 	return sri
 }
 
+func (ss *ServiceSentinel) getTLSCert(id uint64) *model.ServiceCert {
+	ss.tlsCertCacheLock.RLock()
+	defer ss.tlsCertCacheLock.RUnlock()
+
+	cert, ok := ss.tlsCertCache[id]
+	if !ok {
+		return nil
+	}
+	c := *cert
+	c.RefreshDaysRemaining()
+	return &c
+}
+
+// CurrentStatus 返回服务最近 15 分钟的状态码
+func (ss *ServiceSentinel) CurrentStatus(id uint64) uint8 {
+	ss.serviceResponseDataStoreLock.RLock()
+	defer ss.serviceResponseDataStoreLock.RUnlock()
+
+	rd := ss.serviceResponseDataStore[id]
+	if rd.Up+rd.Down == 0 {
+		return StatusNoData
+	}
+	return GetStatusCode(rd.Up * 100 / (rd.Up + rd.Down))
+}
+
+// Outage 返回服务是否判定为故障及最近一次失败的原因，ok 为 false 时本节点尚未判定过该服务的状态
+func (ss *ServiceSentinel) Outage(id uint64) (down bool, reason string, ok bool) {
+	ss.serviceResponseDataStoreLock.RLock()
+	defer ss.serviceResponseDataStoreLock.RUnlock()
+
+	ts := ss.serviceCurrentStatusData[id]
+	if ts == nil || ts.lastStatus == 0 {
+		return false, "", false
+	}
+	return ts.lastStatus == StatusDown, ts.lastError, true
+}
+
+// TodayStats 返回服务当日的在线统计
+func (ss *ServiceSentinel) TodayStats(id uint64) (_TodayStatsOfService, bool) {
+	ss.serviceResponseDataStoreLock.RLock()
+	defer ss.serviceResponseDataStoreLock.RUnlock()
+
+	stats, ok := ss.serviceStatusToday[id]
+	if !ok {
+		return _TodayStatsOfService{}, false
+	}
+	return *stats, true
+}
+
+// FlushLatency 持久化全部未保存的延迟分布
+func (ss *ServiceSentinel) FlushLatency() {
+	ss.latency.Flush(true)
+}
+
 func (ss *ServiceSentinel) Get(id uint64) (s *model.Service, ok bool) {
 	ss.servicesLock.RLock()
 	defer ss.servicesLock.RUnlock()
@@ -379,6 +575,23 @@ func (ss *ServiceSentinel) GetSortedList() []*model.Service {
 	return slices.Clone(ss.serviceList)
 }
 
+// GetSortedListByTenant 返回属于租户范围内用户的服务
+func (ss *ServiceSentinel) GetSortedListByTenant(scope model.TenantScope) []*model.Service {
+	ss.serviceListLock.RLock()
+	defer ss.serviceListLock.RUnlock()
+
+	if len(scope) == 1 {
+		return slices.Clone(ss.userServiceList[scope[0]])
+	}
+	var list []*model.Service
+	for _, s := range ss.serviceList {
+		if scope.Contains(s.UserID) {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
 func (ss *ServiceSentinel) CheckPermission(c *gin.Context, idList iter.Seq[uint64]) bool {
 	ss.servicesLock.RLock()
 	defer ss.servicesLock.RUnlock()
@@ -398,14 +611,59 @@ func (ss *ServiceSentinel) worker() {
 	// 从服务状态汇报管道获取汇报的服务数据
 	for r := range ss.serviceReportChannel {
 		css, _ := ss.Get(r.Data.GetId())
-		if css == nil || css.ID == 0 {
+		if css == nil || css.ID == 0 || (css.Type == model.TaskTypeComposite) != (r.Data.GetType() == model.TaskTypeComposite) {
 			log.Printf("NEZHA>> Incorrect service monitor report %+v", r)
 			continue
 		}
-		css = nil
 
 		mh := r.Data
-		if mh.Type == model.TaskTypeTCPPing || mh.Type == model.TaskTypeICMPPing {
+		var compositeState uint8
+		if mh.Type == model.TaskTypeComposite {
+			status := ss.evaluateComposite(css)
+			if status.code == StatusNoData {
+				continue
+			}
+			compositeState = status.code
+			mh.Successful = compositeState == StatusGood || compositeState == StatusLowAvailability
+			mh.Data = status.summary
+			r.Reporter = status.reporter
+		}
+		// 旧版 Agent 只做普通请求，请求成功后由面板补充检查断言
+		if mh.Type == model.TaskTypeHTTPGet && mh.Successful {
+			if server, ok := ServerShared.Get(r.Reporter); ok && !css.AgentEvaluatesAssertion(server) {
+				if err := ss.assertions.Check(css); err != nil {
+					mh.Successful = false
+					mh.Data = err.Error()
+				}
+			}
+		}
+		css = nil
+
+		ss.recordProbeCheck(mh.GetId(), r.Reporter)
+		if mh.Type == model.TaskTypeDNS {
+			ss.recordDNSProbe(r.Reporter, mh)
+		}
+		var icmp *model.ICMPTaskResult
+		if mh.Type == model.TaskTypeICMPPing {
+			var ok bool
+			if icmp, ok = model.ParseICMPTaskResult(mh.Data); ok {
+				mh.Data = icmp.String()
+			} else {
+				// 旧版 Agent 只发送一个包，按单包结果统计丢包
+				icmp = model.NewICMPTaskResult(1, utils.IfOr(mh.Successful, []float32{mh.Delay}, nil))
+			}
+		}
+		// 配置了连接选项时 Agent 会上报实际测试的路径，成功时记录该路径，失败原因中附带该路径。
+		// 成功时的证书信息与路径在同一个 JSON 对象中，证书检查使用原始数据
+		var httpResult *model.HTTPTaskResult
+		certData := mh.Data
+		if mh.Type == model.TaskTypeHTTPGet {
+			var ok bool
+			if httpResult, ok = model.ParseHTTPTaskResult(mh.Data); ok {
+				mh.Data = httpResult.String()
+			}
+		}
+		if mh.Type == model.TaskTypeTCPPing || mh.Type == model.TaskTypeICMPPing || mh.Type == model.TaskTypeDNS {
 			serviceTcpMap, ok := ss.serviceResponsePing[mh.GetId()]
 			if !ok {
 				serviceTcpMap = make(map[uint64]*pingStore)
@@ -417,21 +675,30 @@ func (ss *ServiceSentinel) worker() {
 			}
 			ts.count++
 			ts.ping = (ts.ping*float32(ts.count-1) + mh.Delay) / float32(ts.count)
+			if icmp != nil {
+				ts.loss = (ts.loss*float32(ts.count-1) + icmp.Loss) / float32(ts.count)
+				ts.jitter = (ts.jitter*float32(ts.count-1) + icmp.Jitter) / float32(ts.count)
+			}
 			if ts.count == Conf.AvgPingCount {
-				if err := DB.Create(&model.ServiceHistory{
+				ss.history.Add(&model.ServiceHistory{
 					ServiceID: mh.GetId(),
 					AvgDelay:  ts.ping,
+					Loss:      ts.loss,
+					Jitter:    ts.jitter,
 					Data:      mh.Data,
 					ServerID:  r.Reporter,
-				}).Error; err != nil {
-					log.Printf("NEZHA>> Failed to save service monitor metrics: %v", err)
-				}
+				})
 				ts.count = 0
 				ts.ping = mh.Delay
+				ts.loss, ts.jitter = 0, 0
 			}
 			serviceTcpMap[r.Reporter] = ts
 		}
 
+		if mh.Successful && mh.Delay > 0 {
+			ss.latency.Add(mh.GetId(), r.Reporter, mh.Delay, time.Now())
+		}
+
 		ss.serviceResponseDataStoreLock.Lock()
 		// 写入当天状态
 		if mh.Successful {
@@ -443,6 +710,7 @@ func (ss *ServiceSentinel) worker() {
 			ss.serviceStatusToday[mh.GetId()].Down++
 		}
 
+		ss.serviceCurrentStatusData[mh.GetId()].lastReporter = r.Reporter
 		currentTime := time.Now()
 		if ss.serviceCurrentStatusData[mh.GetId()].t.IsZero() {
 			ss.serviceCurrentStatusData[mh.GetId()].t = currentTime
@@ -482,109 +750,288 @@ func (ss *ServiceSentinel) worker() {
 			stateCode = GetStatusCode(upPercent)
 		}
 
+		cs, _ := ss.Get(mh.GetId())
+		if cs.RecordResults {
+			ss.results.Add(&model.ServiceResult{
+				ServiceID:  mh.GetId(),
+				ServerID:   r.Reporter,
+				Successful: mh.Successful,
+				Delay:      mh.Delay,
+				Data:       mh.Data,
+			})
+		}
+
+		// 按连续失败次数判定状态
+		if taskStatus := ss.serviceCurrentStatusData[mh.GetId()]; cs.StateByStreak() {
+			taskStatus.recordStreak(cs, r.Reporter, mh.Successful, currentTime)
+			stateCode = streakStatusCode(cs, taskStatus, currentTime)
+		}
+		if compositeState != 0 {
+			stateCode = compositeState
+		}
+		// 多监测点判定时单个监测点失败只计入历史，不改变服务状态
+		var quorum *model.ServiceQuorum
+		if cs.QuorumEnabled() && compositeState == 0 {
+			quorum = ss.evaluateQuorum(cs, r.Reporter, mh, ss.serviceCurrentStatusData[mh.GetId()])
+			stateCode = StatusGood
+			if quorum.Down() {
+				stateCode = StatusDown
+			}
+		}
+
 		// 数据持久化
 		if len(ss.serviceCurrentStatusData[mh.GetId()].result) == _CurrentStatusSize {
 			ss.serviceCurrentStatusData[mh.GetId()].t = currentTime
 			rd := ss.serviceResponseDataStore[mh.GetId()]
-			if err := DB.Create(&model.ServiceHistory{
+			ss.history.Add(&model.ServiceHistory{
 				ServiceID: mh.GetId(),
 				AvgDelay:  rd.Delay,
-				Data:      mh.Data,
+				Data:      lastFailureReason(ss.serviceCurrentStatusData[mh.GetId()].result, mh.Data),
 				Up:        rd.Up,
 				Down:      rd.Down,
-			}).Error; err != nil {
-				log.Printf("NEZHA>> Failed to save service monitor metrics: %v", err)
-			}
+			})
 
 			ss.serviceCurrentStatusData[mh.GetId()].result = ss.serviceCurrentStatusData[mh.GetId()].result[:0]
 		}
 
-		cs, _ := ss.Get(mh.GetId())
 		m := ServerShared.GetList()
 		// 延迟报警
 		if mh.Delay > 0 {
 			delayCheck(&r, m, cs, mh)
 		}
+		// 丢包率及抖动报警
+		if icmp != nil {
+			icmpQualityCheck(&r, m, cs, icmp)
+		}
+
+		ss.sendServiceWebhook(cs, &r, ss.serviceCurrentStatusData[mh.GetId()].lastStatus, stateCode)
 
+		stateChanged := stateCode != ss.serviceCurrentStatusData[mh.GetId()].lastStatus
 		// 状态变更报警+触发任务执行
-		if stateCode == StatusDown || stateCode != ss.serviceCurrentStatusData[mh.GetId()].lastStatus {
+		if stateCode == StatusDown || stateChanged {
 			lastStatus := ss.serviceCurrentStatusData[mh.GetId()].lastStatus
 			// 存储新的状态值
 			ss.serviceCurrentStatusData[mh.GetId()].lastStatus = stateCode
 
-			notifyCheck(&r, m, cs, mh, lastStatus, stateCode)
+			notifyCheck(&r, m, cs, mh, quorum, ss.serviceCurrentStatusData[mh.GetId()], lastStatus, stateCode)
 		}
 		ss.serviceResponseDataStoreLock.Unlock()
 
+		// 子服务状态变更后立即重新计算所属组合服务的状态，其余结果在概览缓存过期后可见
+		if stateChanged {
+			QueryCacheShared.Invalidate(QueryCacheServiceOverview)
+			ss.propagateComposite(mh.GetId())
+		}
+
 		// TLS 证书报警
 		var errMsg string
-		if strings.HasPrefix(mh.Data, "SSL证书错误：") {
+		certErr := mh.Data
+		if httpResult != nil {
+			certErr = httpResult.Error
+		}
+		if strings.HasPrefix(certErr, "SSL证书错误：") {
 			// i/o timeout、connection timeout、EOF 错误
-			if !strings.HasSuffix(mh.Data, "timeout") &&
-				!strings.HasSuffix(mh.Data, "EOF") &&
-				!strings.HasSuffix(mh.Data, "timed out") {
+			if !strings.HasSuffix(certErr, "timeout") &&
+				!strings.HasSuffix(certErr, "EOF") &&
+				!strings.HasSuffix(certErr, "timed out") {
 				errMsg = mh.Data
 				if cs.Notify {
 					muteLabel := NotificationMuteLabel.ServiceTLS(mh.GetId(), "network")
-					go NotificationShared.SendNotification(cs.NotificationGroupID, Localizer.Tf("[TLS] Fetch cert info failed, Reporter: %s, Error: %s", cs.Name, errMsg), muteLabel)
+					go NotificationShared.SendNotification(cs.NotificationGroupID, NotificationShared.Lang(cs.NotificationGroupID).Tf("[TLS] Fetch cert info failed, Reporter: %s, Error: %s", cs.Name, errMsg), muteLabel)
 				}
 			}
 		} else {
 			// 清除网络错误静音缓存
 			NotificationShared.UnMuteNotification(cs.NotificationGroupID, NotificationMuteLabel.ServiceTLS(mh.GetId(), "network"))
 
-			var newCert = strings.Split(mh.Data, "|")
-			if len(newCert) > 1 {
-				enableNotify := cs.Notify
+			if cert, ok := model.ParseServiceCert(mh.GetId(), certData); ok {
+				ss.checkTLSCert(cs, cert)
+			}
+		}
+	}
+}
+
+// recordDNSProbe 记录各监测点的解析结果，并将上报数据转换为可读的失败原因
+func (ss *ServiceSentinel) recordDNSProbe(reporter uint64, mh *pb.TaskResult) {
+	var res model.DNSTaskResult
+	if err := json.Unmarshal([]byte(mh.Data), &res); err != nil {
+		if !mh.Successful {
+			res.Reason = model.DNSFailureError
+			res.Error = mh.Data
+		}
+	}
+	if !mh.Successful && res.Reason == "" {
+		res.Reason = model.DNSFailureError
+	}
+	mh.Data = res.String()
 
-				// 首次获取证书信息时，缓存证书信息
-				if ss.tlsCertCache[mh.GetId()] == "" {
-					ss.tlsCertCache[mh.GetId()] = mh.Data
-				}
+	ss.dnsProbeLock.Lock()
+	defer ss.dnsProbeLock.Unlock()
 
-				oldCert := strings.Split(ss.tlsCertCache[mh.GetId()], "|")
-				isCertChanged := false
-				expiresOld, _ := time.Parse("2006-01-02 15:04:05 -0700 MST", oldCert[1])
-				expiresNew, _ := time.Parse("2006-01-02 15:04:05 -0700 MST", newCert[1])
+	probes, ok := ss.dnsProbeResults[mh.GetId()]
+	if !ok {
+		probes = make(map[uint64]*model.DNSProbeResult)
+		ss.dnsProbeResults[mh.GetId()] = probes
+	}
+	probes[reporter] = &model.DNSProbeResult{
+		ServerID:   reporter,
+		Successful: mh.Successful,
+		Delay:      mh.Delay,
+		Answers:    res.Answers,
+		Reason:     res.Reason,
+		Error:      res.Error,
+		UpdatedAt:  time.Now(),
+	}
+}
 
-				// 证书变更时，更新缓存
-				if oldCert[0] != newCert[0] && !expiresNew.Equal(expiresOld) {
-					isCertChanged = true
-					ss.tlsCertCache[mh.GetId()] = mh.Data
-				}
+// GetDNSProbeResults 获取各监测点最近一次 DNS 查询结果
+func (ss *ServiceSentinel) GetDNSProbeResults(serviceID uint64) []model.DNSProbeResult {
+	ss.dnsProbeLock.RLock()
+	defer ss.dnsProbeLock.RUnlock()
 
-				notificationGroupID := cs.NotificationGroupID
-				serviceName := cs.Name
-
-				// 需要发送提醒
-				if enableNotify {
-					// 证书过期提醒
-					if expiresNew.Before(time.Now().AddDate(0, 0, 7)) {
-						expiresTimeStr := expiresNew.Format("2006-01-02 15:04:05")
-						errMsg = Localizer.Tf(
-							"The TLS certificate will expire within seven days. Expiration time: %s",
-							expiresTimeStr,
-						)
-
-						// 静音规则： 服务id+证书过期时间
-						// 用于避免多个监测点对相同证书同时报警
-						muteLabel := NotificationMuteLabel.ServiceTLS(mh.GetId(), fmt.Sprintf("expire_%s", expiresTimeStr))
-						go NotificationShared.SendNotification(notificationGroupID, fmt.Sprintf("[TLS] %s %s", serviceName, errMsg), muteLabel)
-					}
-
-					// 证书变更提醒
-					if isCertChanged {
-						errMsg = Localizer.Tf(
-							"TLS certificate changed, old: issuer %s, expires at %s; new: issuer %s, expires at %s",
-							oldCert[0], expiresOld.Format("2006-01-02 15:04:05"), newCert[0], expiresNew.Format("2006-01-02 15:04:05"))
-
-						// 证书变更后会自动更新缓存，所以不需要静音
-						go NotificationShared.SendNotification(notificationGroupID, fmt.Sprintf("[TLS] %s %s", serviceName, errMsg), "")
-					}
-				}
-			}
+	ret := make([]model.DNSProbeResult, 0, len(ss.dnsProbeResults[serviceID]))
+	for _, r := range ss.dnsProbeResults[serviceID] {
+		ret = append(ret, *r)
+	}
+	slices.SortFunc(ret, func(a, b model.DNSProbeResult) int {
+		return cmp.Compare(a.ServerID, b.ServerID)
+	})
+	return ret
+}
+
+// checkTLSCert 更新证书缓存，并按阈值发送过期、变更及不受信任提醒
+func (ss *ServiceSentinel) checkTLSCert(cs *model.Service, cert *model.ServiceCert) {
+	ss.tlsCertCacheLock.Lock()
+	oldCert := ss.tlsCertCache[cs.ID]
+	// 首次获取证书信息时，缓存证书信息
+	if oldCert == nil {
+		oldCert = cert
+	}
+	isCertChanged := cert.IsChanged(oldCert)
+	if oldCert == cert || !cert.Equal(oldCert) {
+		ss.tlsCertCache[cs.ID] = cert
+		if err := DB.Save(cert).Error; err != nil {
+			log.Printf("NEZHA>> Failed to save tls cert info: %v", err)
+		}
+	}
+	ss.tlsCertCacheLock.Unlock()
+
+	// 需要发送提醒
+	if !cs.Notify {
+		return
+	}
+
+	notificationGroupID := cs.NotificationGroupID
+	serviceName := cs.Name
+	t := NotificationShared.Lang(notificationGroupID)
+	expiresTimeStr := cert.NotAfter.Format("2006-01-02 15:04:05")
+
+	// 证书过期提醒
+	warnDays, criticalDays := cs.TLSExpiryThresholds()
+	var level string
+	switch {
+	case criticalDays > 0 && cert.DaysRemaining < criticalDays:
+		level = "critical"
+	case warnDays > 0 && cert.DaysRemaining < warnDays:
+		level = "warning"
+	}
+	if level != "" {
+		errMsg := t.Tf(
+			"The TLS certificate will expire in %d days. Expiration time: %s",
+			cert.DaysRemaining, expiresTimeStr,
+		)
+		// 静音规则： 服务id+提醒级别+证书过期时间
+		// 用于避免多个监测点对相同证书同时报警
+		muteLabel := NotificationMuteLabel.ServiceTLS(cs.ID, fmt.Sprintf("expire_%s_%s", level, expiresTimeStr))
+		go NotificationShared.SendNotification(notificationGroupID, fmt.Sprintf("[TLS][%s] %s %s", level, serviceName, errMsg), muteLabel)
+	}
+
+	// 自签名或证书链不完整时仍视为可用，仅提醒一次
+	if !cert.Trusted || !cert.ChainValid {
+		errMsg := t.T("The TLS certificate is valid but not trusted")
+		muteLabel := NotificationMuteLabel.ServiceTLS(cs.ID, fmt.Sprintf("untrusted_%s", expiresTimeStr))
+		go NotificationShared.SendNotification(notificationGroupID, fmt.Sprintf("[TLS] %s %s", serviceName, errMsg), muteLabel)
+	}
+
+	// 证书变更提醒
+	if isCertChanged {
+		errMsg := t.Tf(
+			"TLS certificate changed, old: issuer %s, expires at %s; new: issuer %s, expires at %s",
+			oldCert.Issuer, oldCert.NotAfter.Format("2006-01-02 15:04:05"), cert.Issuer, expiresTimeStr)
+
+		// 证书变更后会自动更新缓存，所以不需要静音
+		go NotificationShared.SendNotification(notificationGroupID, fmt.Sprintf("[TLS] %s %s", serviceName, errMsg), "")
+	}
+}
+
+// reporterStreak 单个监测点的连续结果，避免其他监测点的成功结果重置故障监测点的计数
+type reporterStreak struct {
+	failure uint64 // 连续失败次数
+	success uint64 // 连续成功次数
+	down    bool   // 连续失败达到阈值，连续成功达到恢复阈值前保持故障
+	t       time.Time
+}
+
+// recordStreak 记录监测点本次的结果，调用方需持有 serviceResponseDataStoreLock
+func (ts *serviceTaskStatus) recordStreak(cs *model.Service, reporter uint64, successful bool, now time.Time) {
+	if ts.streaks == nil {
+		ts.streaks = make(map[uint64]*reporterStreak)
+	}
+	st, ok := ts.streaks[reporter]
+	if !ok {
+		// 新监测点沿用服务当前的故障状态，恢复仍需连续成功
+		st = &reporterStreak{down: ts.lastStatus == StatusDown}
+		ts.streaks[reporter] = st
+	}
+	st.t = now
+	if successful {
+		st.success++
+		st.failure = 0
+		if st.success >= uint64(cs.RecoveryStreak()) {
+			st.down = false
+		}
+	} else {
+		st.failure++
+		st.success = 0
+		if st.failure >= uint64(cs.FailureThreshold) {
+			st.down = true
+		}
+	}
+}
+
+// streakStatusCode 任一监测点连续失败达到阈值时判定为故障，故障的监测点连续成功达到阈值后恢复。
+// 超过三个检查间隔未上报的监测点不再参与判定
+func streakStatusCode(cs *model.Service, ts *serviceTaskStatus, now time.Time) uint8 {
+	stale := 3 * time.Duration(max(cs.Duration, 1)) * time.Second
+	var down, good bool
+	for reporter, st := range ts.streaks {
+		if now.Sub(st.t) > stale {
+			delete(ts.streaks, reporter)
+			continue
+		}
+		down = down || st.down
+		good = good || st.success > 0
+	}
+	switch {
+	case down:
+		return StatusDown
+	case good:
+		return StatusGood
+	case ts.lastStatus != 0:
+		return ts.lastStatus
+	default:
+		return StatusNoData
+	}
+}
+
+// lastFailureReason 返回窗口内最近一次失败的原因，无失败时返回 fallback
+func lastFailureReason(results []*pb.TaskResult, fallback string) string {
+	for _, r := range slices.Backward(results) {
+		if !r.GetSuccessful() && r.GetData() != "" {
+			return r.GetData()
 		}
 	}
+	return fallback
 }
 
 func delayCheck(r *ReportData, m map[uint64]*model.Server, ss *model.Service, mh *pb.TaskResult) {
@@ -598,12 +1045,12 @@ func delayCheck(r *ReportData, m map[uint64]*model.Server, ss *model.Service, mh
 	if mh.Delay > ss.MaxLatency {
 		// 延迟超过最大值
 		reporterServer := m[r.Reporter]
-		msg := Localizer.Tf("[Latency] %s %2f > %2f, Reporter: %s", ss.Name, mh.Delay, ss.MaxLatency, reporterServer.Name)
+		msg := NotificationShared.Lang(notificationGroupID).Tf("[Latency] %s %2f > %2f, Reporter: %s", ss.Name, mh.Delay, ss.MaxLatency, reporterServer.Name)
 		go NotificationShared.SendNotification(notificationGroupID, msg, minMuteLabel)
 	} else if mh.Delay < ss.MinLatency {
 		// 延迟低于最小值
 		reporterServer := m[r.Reporter]
-		msg := Localizer.Tf("[Latency] %s %2f < %2f, Reporter: %s", ss.Name, mh.Delay, ss.MinLatency, reporterServer.Name)
+		msg := NotificationShared.Lang(notificationGroupID).Tf("[Latency] %s %2f < %2f, Reporter: %s", ss.Name, mh.Delay, ss.MinLatency, reporterServer.Name)
 		go NotificationShared.SendNotification(notificationGroupID, msg, maxMuteLabel)
 	} else {
 		// 正常延迟， 清除静音缓存
@@ -612,14 +1059,48 @@ func delayCheck(r *ReportData, m map[uint64]*model.Server, ss *model.Service, mh
 	}
 }
 
+func icmpQualityCheck(r *ReportData, m map[uint64]*model.Server, ss *model.Service, res *model.ICMPTaskResult) {
+	notificationGroupID := ss.NotificationGroupID
+	reporterServer := m[r.Reporter]
+	if ss.MaxLoss > 0 {
+		muteLabel := NotificationMuteLabel.ServiceLoss(ss.ID)
+		if res.Loss > ss.MaxLoss {
+			msg := NotificationShared.Lang(notificationGroupID).Tf("[Packet Loss] %s %.1f%% > %.1f%%, Reporter: %s", ss.Name, res.Loss, ss.MaxLoss, reporterServer.Name)
+			go NotificationShared.SendNotification(notificationGroupID, msg, muteLabel)
+		} else {
+			NotificationShared.UnMuteNotification(notificationGroupID, muteLabel)
+		}
+	}
+	if ss.MaxJitter > 0 && res.Received > 0 {
+		muteLabel := NotificationMuteLabel.ServiceJitter(ss.ID)
+		if res.Jitter > ss.MaxJitter {
+			msg := NotificationShared.Lang(notificationGroupID).Tf("[Jitter] %s %.2f > %.2f, Reporter: %s", ss.Name, res.Jitter, ss.MaxJitter, reporterServer.Name)
+			go NotificationShared.SendNotification(notificationGroupID, msg, muteLabel)
+		} else {
+			NotificationShared.UnMuteNotification(notificationGroupID, muteLabel)
+		}
+	}
+}
+
+// notifyCheck 记录故障原因并执行触发任务，故障与恢复通知由服务监控的通知设置生成的 service_down 报警规则发送，
+// 可用性降低及从降低中恢复的通知仍在此发送
 func notifyCheck(r *ReportData, m map[uint64]*model.Server,
-	ss *model.Service, mh *pb.TaskResult, lastStatus, stateCode uint8) {
-	// 判断是否需要发送通知
-	isNeedSendNotification := ss.Notify && (lastStatus != 0 || stateCode == StatusDown)
-	if isNeedSendNotification {
-		reporterServer := m[r.Reporter]
+	ss *model.Service, mh *pb.TaskResult, quorum *model.ServiceQuorum, ts *serviceTaskStatus, lastStatus, stateCode uint8) {
+	if stateCode == StatusDown {
+		ts.lastError = reporterName(m, r.Reporter) + ": " + mh.GetData()
+		if quorum != nil {
+			ts.lastError = probeVoteList(m, quorum.Failed, true)
+		}
+	}
+
+	if ss.Notify && lastStatus != 0 && (stateCode == StatusLowAvailability || (stateCode == StatusGood && lastStatus == StatusLowAvailability)) {
 		notificationGroupID := ss.NotificationGroupID
-		notificationMsg := Localizer.Tf("[%s] %s Reporter: %s, Error: %s", StatusCodeToString(stateCode), ss.Name, reporterServer.Name, mh.Data)
+		t := NotificationShared.Lang(notificationGroupID)
+		notificationMsg := t.Tf("[%s] %s Reporter: %s, Error: %s", StatusCodeToString(t, stateCode), ss.Name, reporterName(m, r.Reporter), mh.Data)
+		if quorum != nil {
+			notificationMsg = t.Tf("[%s] %s Failed (%d/%d): %s, Succeeded: %s", StatusCodeToString(t, stateCode), ss.Name,
+				len(quorum.Failed), quorum.Total, probeVoteList(m, quorum.Failed, true), probeVoteList(m, quorum.Succeeded, false))
+		}
 		muteLabel := NotificationMuteLabel.ServiceStateChanged(mh.GetId())
 
 		// 状态变更时，清除静音缓存
@@ -633,13 +1114,12 @@ func notifyCheck(r *ReportData, m map[uint64]*model.Server,
 	// 判断是否需要触发任务
 	isNeedTriggerTask := ss.EnableTriggerTask && lastStatus != 0
 	if isNeedTriggerTask {
-		reporterServer := m[r.Reporter]
 		if stateCode == StatusGood && lastStatus != stateCode {
 			// 当前状态正常 前序状态非正常时 触发恢复任务
-			go CronShared.SendTriggerTasks(ss.RecoverTriggerTasks, reporterServer.ID)
+			go CronShared.SendTriggerTasks(ss.RecoverTriggerTasks, r.Reporter)
 		} else if lastStatus == StatusGood && lastStatus != stateCode {
 			// 前序状态正常 当前状态非正常时 触发失败任务
-			go CronShared.SendTriggerTasks(ss.FailTriggerTasks, reporterServer.ID)
+			go CronShared.SendTriggerTasks(ss.FailTriggerTasks, r.Reporter)
 		}
 	}
 }
@@ -665,16 +1145,16 @@ func GetStatusCode[T constraints.Float | constraints.Integer](percent T) uint8 {
 	return StatusDown
 }
 
-func StatusCodeToString(statusCode uint8) string {
+func StatusCodeToString(t *i18n.Lang, statusCode uint8) string {
 	switch statusCode {
 	case StatusNoData:
-		return Localizer.T("No Data")
+		return t.T("No Data")
 	case StatusGood:
-		return Localizer.T("Good")
+		return t.T("Good")
 	case StatusLowAvailability:
-		return Localizer.T("Low Availability")
+		return t.T("Low Availability")
 	case StatusDown:
-		return Localizer.T("Down")
+		return t.T("Down")
 	default:
 		return ""
 	}
@@ -0,0 +1,47 @@
+package singleton
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+func TestStreakStatusCode(t *testing.T) {
+	cs := &model.Service{Duration: 30, FailureThreshold: 3, RecoveryThreshold: 2}
+	ts := &serviceTaskStatus{}
+	now := time.Now()
+	record := func(reporter uint64, successful bool) uint8 {
+		now = now.Add(10 * time.Second)
+		ts.recordStreak(cs, reporter, successful, now)
+		ts.lastStatus = streakStatusCode(cs, ts, now)
+		return ts.lastStatus
+	}
+
+	// 健康的监测点不会重置故障监测点的连续失败次数
+	for range 2 {
+		record(1, false)
+		record(2, true)
+	}
+	if code := record(1, false); code != StatusDown {
+		t.Fatalf("expected third failure of reporter 1 to mark the service down, got %d", code)
+	}
+	if code := record(2, true); code != StatusDown {
+		t.Fatalf("expected another reporter's success to keep the service down, got %d", code)
+	}
+	if code := record(1, true); code != StatusDown {
+		t.Fatalf("expected a single success to not recover the service, got %d", code)
+	}
+	if code := record(1, true); code != StatusGood {
+		t.Fatalf("expected the recovery streak to recover the service, got %d", code)
+	}
+
+	// 不再上报的故障监测点超时后不再参与判定
+	for range 3 {
+		record(3, false)
+	}
+	now = now.Add(2 * time.Minute)
+	if code := record(2, true); code != StatusGood {
+		t.Fatalf("expected stale reporter to be ignored, got %d", code)
+	}
+}
@@ -0,0 +1,225 @@
+package singleton
+
+import (
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/utils"
+)
+
+// 最后活动时间写入数据库的最小间隔
+const sessionTouchInterval = time.Minute
+
+// SessionClass 缓存有效的登录会话，撤销时从缓存中移除并关闭该会话的 WebSocket 连接
+type SessionClass struct {
+	mu    sync.RWMutex
+	cache map[string]*model.Session
+	conns map[string]map[*sessionConn]struct{}
+}
+
+type sessionConn struct {
+	io.Closer
+}
+
+var SessionShared *SessionClass
+
+func NewSessionClass() *SessionClass {
+	return &SessionClass{
+		cache: make(map[string]*model.Session),
+		conns: make(map[string]map[*sessionConn]struct{}),
+	}
+}
+
+// reload 从数据库重新读取会话，关闭已被其他节点撤销的会话的连接
+func (c *SessionClass) reload() {
+	var list []*model.Session
+	if err := DB.Find(&list).Error; err != nil {
+		log.Printf("NEZHA>> Failed to load sessions: %v", err)
+		return
+	}
+	cache := make(map[string]*model.Session, len(list))
+	for _, s := range list {
+		cache[s.ID] = s
+	}
+
+	c.mu.Lock()
+	c.cache = cache
+	var closers []io.Closer
+	for id, conns := range c.conns {
+		if _, ok := cache[id]; !ok {
+			for conn := range conns {
+				closers = append(closers, conn)
+			}
+		}
+	}
+	c.mu.Unlock()
+
+	for _, conn := range closers {
+		conn.Close()
+	}
+}
+
+// Create 登录成功后创建会话
+func (c *SessionClass) Create(userID uint64, ip, userAgent string) (*model.Session, error) {
+	now := time.Now()
+	s := &model.Session{
+		ID:         utils.MustGenerateRandomString(32),
+		UserID:     userID,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		IP:         ip,
+		UserAgent:  userAgent,
+	}
+	if err := DB.Create(s).Error; err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.cache[s.ID] = s
+	c.mu.Unlock()
+	return s, nil
+}
+
+// Validate 会话是否属于该用户且未被撤销、未过期，有效时更新最后活动时间
+func (c *SessionClass) Validate(id string, userID uint64) bool {
+	c.mu.RLock()
+	s, ok := c.cache[id]
+	c.mu.RUnlock()
+
+	if !ok {
+		// 其他节点创建的会话
+		s = new(model.Session)
+		if err := DB.First(s, "id = ?", id).Error; err != nil {
+			return false
+		}
+		c.mu.Lock()
+		c.cache[id] = s
+		c.mu.Unlock()
+	}
+	if s.UserID != userID || time.Since(c.lastSeen(s)) > sessionTimeout() {
+		return false
+	}
+
+	c.touch(s)
+	return true
+}
+
+func (c *SessionClass) lastSeen(s *model.Session) time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return s.LastSeenAt
+}
+
+func (c *SessionClass) touch(s *model.Session) {
+	now := time.Now()
+	c.mu.Lock()
+	if now.Sub(s.LastSeenAt) < sessionTouchInterval {
+		c.mu.Unlock()
+		return
+	}
+	s.LastSeenAt = now
+	c.mu.Unlock()
+
+	if err := DB.Model(&model.Session{}).Where("id = ?", s.ID).Update("last_seen_at", now).Error; err != nil {
+		log.Printf("NEZHA>> Failed to update session last seen time: %v", err)
+	}
+}
+
+// List 返回用户的会话，current 为发起请求的会话
+func (c *SessionClass) List(userID uint64, current string) ([]*model.Session, error) {
+	var list []*model.Session
+	if err := DB.Where("user_id = ? AND last_seen_at > ?", userID, time.Now().Add(-sessionTimeout())).
+		Order("last_seen_at DESC").Find(&list).Error; err != nil {
+		return nil, err
+	}
+
+	c.mu.RLock()
+	for _, s := range list {
+		if cached, ok := c.cache[s.ID]; ok {
+			s.LastSeenAt = cached.LastSeenAt
+		}
+		s.Current = s.ID == current
+	}
+	c.mu.RUnlock()
+	return list, nil
+}
+
+// Revoke 撤销用户的一个会话
+func (c *SessionClass) Revoke(userID uint64, id string) error {
+	result := DB.Delete(&model.Session{}, "id = ? AND user_id = ?", id, userID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return Localizer.ErrorT("session %s does not exist", id)
+	}
+	c.drop(id)
+	ClusterShared.PublishChange("user/sessions")
+	return nil
+}
+
+// RevokeOthers 撤销用户除 current 以外的所有会话，返回撤销的数量
+func (c *SessionClass) RevokeOthers(userID uint64, current string) (int, error) {
+	var ids []string
+	if err := DB.Model(&model.Session{}).Where("user_id = ? AND id <> ?", userID, current).Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	if err := DB.Delete(&model.Session{}, "id IN (?)", ids).Error; err != nil {
+		return 0, err
+	}
+	for _, id := range ids {
+		c.drop(id)
+	}
+	ClusterShared.PublishChange("user/sessions")
+	return len(ids), nil
+}
+
+// drop 从缓存中移除会话并关闭其 WebSocket 连接
+func (c *SessionClass) drop(id string) {
+	c.mu.Lock()
+	delete(c.cache, id)
+	conns := c.conns[id]
+	delete(c.conns, id)
+	c.mu.Unlock()
+
+	for conn := range conns {
+		conn.Close()
+	}
+}
+
+// TrackConn 记录会话的 WebSocket 连接，撤销会话时关闭，返回的函数在连接结束时调用
+func (c *SessionClass) TrackConn(id string, conn io.Closer) func() {
+	if id == "" {
+		return func() {}
+	}
+	sc := &sessionConn{conn}
+	c.mu.Lock()
+	if c.conns[id] == nil {
+		c.conns[id] = make(map[*sessionConn]struct{})
+	}
+	c.conns[id][sc] = struct{}{}
+	c.mu.Unlock()
+
+	return func() {
+		c.mu.Lock()
+		delete(c.conns[id], sc)
+		if len(c.conns[id]) == 0 {
+			delete(c.conns, id)
+		}
+		c.mu.Unlock()
+	}
+}
+
+// sessionTimeout 超过 JWT 有效期未活动的会话已无法使用
+func sessionTimeout() time.Duration {
+	return time.Hour * time.Duration(Conf.JWTTimeout)
+}
+
+func cleanSessions() {
+	DB.Delete(&model.Session{}, "last_seen_at < ?", time.Now().Add(-sessionTimeout()))
+}
@@ -0,0 +1,112 @@
+package singleton
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+type testCloser struct {
+	closed bool
+}
+
+func (c *testCloser) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestSessionValidate(t *testing.T) {
+	setupTestDB(t, model.Session{})
+	oldConf := Conf
+	Conf = &ConfigClass{Config: &model.Config{JWTTimeout: 1}}
+	t.Cleanup(func() { Conf = oldConf })
+
+	c := NewSessionClass()
+	create := func(uid uint64) *model.Session {
+		s, err := c.Create(uid, "127.0.0.1", "test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	active, expired, revoked := create(1), create(1), create(1)
+	if !c.Validate(active.ID, 1) {
+		t.Fatal("expected active session to be valid")
+	}
+	if c.Validate(active.ID, 2) {
+		t.Fatal("expected session of another user to be rejected")
+	}
+	if c.Validate("unknown", 1) {
+		t.Fatal("expected unknown session to be rejected")
+	}
+
+	c.mu.Lock()
+	c.cache[expired.ID].LastSeenAt = time.Now().Add(-2 * time.Hour)
+	c.mu.Unlock()
+	if c.Validate(expired.ID, 1) {
+		t.Fatal("expected expired session to be rejected")
+	}
+
+	if err := c.Revoke(1, revoked.ID); err != nil {
+		t.Fatal(err)
+	}
+	if c.Validate(revoked.ID, 1) {
+		t.Fatal("expected revoked session to be rejected")
+	}
+
+	// 其他节点创建的会话从数据库读取
+	other := NewSessionClass()
+	if !other.Validate(active.ID, 1) {
+		t.Fatal("expected session created on another node to be valid")
+	}
+}
+
+func TestSessionRevokeOthers(t *testing.T) {
+	setupTestDB(t, model.Session{})
+	oldConf := Conf
+	Conf = &ConfigClass{Config: &model.Config{JWTTimeout: 1}}
+	t.Cleanup(func() { Conf = oldConf })
+
+	c := NewSessionClass()
+	var sessions []*model.Session
+	for _, uid := range []uint64{1, 1, 1, 2} {
+		s, err := c.Create(uid, "127.0.0.1", "test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		sessions = append(sessions, s)
+	}
+	current, revoked := sessions[0], sessions[1]
+
+	conn, kept := &testCloser{}, &testCloser{}
+	c.TrackConn(revoked.ID, conn)
+	untrack := c.TrackConn(current.ID, kept)
+
+	n, err := c.RevokeOthers(1, current.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("expected 2 sessions to be revoked, got %d", n)
+	}
+	if !conn.closed {
+		t.Fatal("expected connection of revoked session to be closed")
+	}
+	if kept.closed {
+		t.Fatal("expected connection of current session to stay open")
+	}
+	if !c.Validate(current.ID, 1) || c.Validate(revoked.ID, 1) {
+		t.Fatal("expected only the current session of the user to stay valid")
+	}
+	if !c.Validate(sessions[3].ID, 2) {
+		t.Fatal("expected session of another user to stay valid")
+	}
+
+	untrack()
+	c.drop(current.ID)
+	if kept.closed {
+		t.Fatal("expected untracked connection to not be closed")
+	}
+}
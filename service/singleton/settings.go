@@ -0,0 +1,429 @@
+package singleton
+
+import (
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-json"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/geoip"
+	"github.com/nezhahq/nezha/pkg/utils"
+)
+
+// settingDef 可在运行时修改的设置项，field 返回配置中对应字段的指针
+type settingDef struct {
+	key     string
+	typ     string
+	restart bool
+
+	get    func(c *model.Config) any
+	decode func(raw []byte) (any, error)
+	set    func(c *model.Config, v any)
+}
+
+func newSetting[T any](key, typ string, field func(c *model.Config) *T, validate func(T) error) *settingDef {
+	return &settingDef{
+		key: key,
+		typ: typ,
+		get: func(c *model.Config) any { return *field(c) },
+		decode: func(raw []byte) (any, error) {
+			var v T
+			if err := json.Unmarshal(raw, &v); err != nil {
+				return nil, Localizer.ErrorT("invalid value: %v", err)
+			}
+			if validate != nil {
+				if err := validate(v); err != nil {
+					return nil, err
+				}
+			}
+			return v, nil
+		},
+		set: func(c *model.Config, v any) { *field(c) = v.(T) },
+	}
+}
+
+func (d *settingDef) restartRequired() *settingDef {
+	d.restart = true
+	return d
+}
+
+func atLeast[T int | float64](min T) func(T) error {
+	return func(v T) error {
+		if v < min {
+			return Localizer.ErrorT("must be at least %v", min)
+		}
+		return nil
+	}
+}
+
+func between(min, max int) func(int) error {
+	return func(v int) error {
+		if v < min || v > max {
+			return Localizer.ErrorT("must be between %d and %d", min, max)
+		}
+		return nil
+	}
+}
+
+func oneOf(values []string) func(string) error {
+	return func(v string) error {
+		if !slices.Contains(values, v) {
+			return Localizer.ErrorT("must be one of: %s", strings.Join(values, ", "))
+		}
+		return nil
+	}
+}
+
+func validTimezone(v string) error {
+	if model.ValidTimezone(v) != nil {
+		return Localizer.ErrorT("invalid timezone: %s", v)
+	}
+	return nil
+}
+
+func validCIDRs(v []string) error {
+	if _, err := utils.ParseTrustedProxies(v); err != nil {
+		return Localizer.ErrorT("invalid network: %v", err)
+	}
+	return nil
+}
+
+// settingRegistry 所有可在运行时修改的设置项，键与配置文件中的路径一致
+var settingRegistry = []*settingDef{
+	newSetting("maintenance_banner", model.SettingTypeString, func(c *model.Config) *string { return &c.MaintenanceBanner }, nil),
+	newSetting("read_only", model.SettingTypeBool, func(c *model.Config) *bool { return &c.ReadOnly }, nil),
+	newSetting("read_only_pause_reports", model.SettingTypeBool, func(c *model.Config) *bool { return &c.ReadOnlyPauseReports }, nil),
+	newSetting("notification_dedup_window", model.SettingTypeInt, func(c *model.Config) *int { return &c.NotificationDedupWindow }, nil),
+	newSetting("dashboard_url", model.SettingTypeString, func(c *model.Config) *string { return &c.DashboardURL }, validDashboardURL),
+	newSetting("metrics_token", model.SettingTypeSecret, func(c *model.Config) *string { return &c.MetricsToken }, nil),
+	newSetting("ip_change_stable_window", model.SettingTypeInt, func(c *model.Config) *int { return &c.IPChangeStableWindow }, nil),
+	newSetting("ip_change_notify_family", model.SettingTypeString, func(c *model.Config) *string { return &c.IPChangeNotifyFamily }, validIPFamily),
+	newSetting("require_access_grants", model.SettingTypeBool, func(c *model.Config) *bool { return &c.RequireAccessGrants }, nil),
+
+	newSetting("traffic_retention_days", model.SettingTypeInt, func(c *model.Config) *int { return &c.TrafficRetentionDays }, atLeast(1)),
+	newSetting("transfer_max_rate", model.SettingTypeInt, func(c *model.Config) *int { return &c.TransferMaxRate }, nil),
+	newSetting("cron_history_retention_days", model.SettingTypeInt, func(c *model.Config) *int { return &c.CronHistoryRetentionDays }, atLeast(1)),
+	newSetting("cron_history_max_rows", model.SettingTypeInt, func(c *model.Config) *int { return &c.CronHistoryMaxRows }, atLeast(1)),
+	newSetting("audit_log_retention_days", model.SettingTypeInt, func(c *model.Config) *int { return &c.AuditLogRetentionDays }, atLeast(1)),
+	newSetting("server_event_retention_days", model.SettingTypeInt, func(c *model.Config) *int { return &c.ServerEventRetentionDays }, atLeast(1)),
+	newSetting("service_history_raw_days", model.SettingTypeInt, func(c *model.Config) *int { return &c.ServiceHistoryRawDays }, atLeast(1)),
+	newSetting("service_history_hourly_days", model.SettingTypeInt, func(c *model.Config) *int { return &c.ServiceHistoryHourlyDays }, atLeast(1)),
+	newSetting("service_history_daily_days", model.SettingTypeInt, func(c *model.Config) *int { return &c.ServiceHistoryDailyDays }, atLeast(0)),
+
+	newSetting("mesh_ping_interval", model.SettingTypeInt, func(c *model.Config) *int { return &c.MeshPingInterval }, atLeast(10)),
+	newSetting("mesh_ping_fan_out", model.SettingTypeInt, func(c *model.Config) *int { return &c.MeshPingFanOut }, atLeast(1)),
+
+	newSetting("rate_limit.public.rate", model.SettingTypeFloat, func(c *model.Config) *float64 { return &c.RateLimit.Public.Rate }, atLeast(0.0)),
+	newSetting("rate_limit.public.burst", model.SettingTypeInt, func(c *model.Config) *int { return &c.RateLimit.Public.Burst }, atLeast(0)),
+	newSetting("rate_limit.authenticated.rate", model.SettingTypeFloat, func(c *model.Config) *float64 { return &c.RateLimit.Authenticated.Rate }, atLeast(0.0)),
+	newSetting("rate_limit.authenticated.burst", model.SettingTypeInt, func(c *model.Config) *int { return &c.RateLimit.Authenticated.Burst }, atLeast(0)),
+	newSetting("rate_limit.admin.rate", model.SettingTypeFloat, func(c *model.Config) *float64 { return &c.RateLimit.Admin.Rate }, atLeast(0.0)),
+	newSetting("rate_limit.admin.burst", model.SettingTypeInt, func(c *model.Config) *int { return &c.RateLimit.Admin.Burst }, atLeast(0)),
+	newSetting("rate_limit.max_keys", model.SettingTypeInt, func(c *model.Config) *int { return &c.RateLimit.MaxKeys }, atLeast(1)),
+	newSetting("rate_limit.block_threshold", model.SettingTypeInt, func(c *model.Config) *int { return &c.RateLimit.BlockThreshold }, atLeast(0)),
+
+	newSetting("login_guard.window", model.SettingTypeInt, func(c *model.Config) *int { return &c.LoginGuard.Window }, atLeast(1)),
+	newSetting("login_guard.delay_after", model.SettingTypeInt, func(c *model.Config) *int { return &c.LoginGuard.DelayAfter }, atLeast(0)),
+	newSetting("login_guard.max_delay", model.SettingTypeInt, func(c *model.Config) *int { return &c.LoginGuard.MaxDelay }, atLeast(0)),
+	newSetting("login_guard.block_after", model.SettingTypeInt, func(c *model.Config) *int { return &c.LoginGuard.BlockAfter }, atLeast(0)),
+	newSetting("login_guard.lock_after", model.SettingTypeInt, func(c *model.Config) *int { return &c.LoginGuard.LockAfter }, atLeast(0)),
+	newSetting("login_guard.lock_duration", model.SettingTypeInt, func(c *model.Config) *int { return &c.LoginGuard.LockDuration }, atLeast(0)),
+
+	newSetting("probe.deny_cidrs", model.SettingTypeStringList, func(c *model.Config) *[]string { return &c.Probe.DenyCIDRs }, validCIDRs),
+	newSetting("probe.rate_limit.rate", model.SettingTypeFloat, func(c *model.Config) *float64 { return &c.Probe.RateLimit.Rate }, atLeast(0.0)),
+	newSetting("probe.rate_limit.burst", model.SettingTypeInt, func(c *model.Config) *int { return &c.Probe.RateLimit.Burst }, atLeast(0)),
+
+	newSetting("branding.site_title", model.SettingTypeString, func(c *model.Config) *string { return &c.Branding.SiteTitle }, nil),
+	newSetting("branding.logo_url", model.SettingTypeString, func(c *model.Config) *string { return &c.Branding.LogoURL }, validBrandingURL),
+	newSetting("branding.favicon_url", model.SettingTypeString, func(c *model.Config) *string { return &c.Branding.FaviconURL }, validBrandingURL),
+	newSetting("branding.footer_html", model.SettingTypeString, func(c *model.Config) *string { return &c.Branding.FooterHTML }, nil),
+	newSetting("branding.custom_css", model.SettingTypeString, func(c *model.Config) *string { return &c.Branding.CustomCSS }, validCustomCSS),
+	newSetting("branding.default_language", model.SettingTypeString, func(c *model.Config) *string { return &c.Branding.DefaultLanguage }, validBrandingLanguage),
+	newSetting("branding.default_theme", model.SettingTypeString, func(c *model.Config) *string { return &c.Branding.DefaultTheme }, validBrandingTheme),
+
+	newSetting("display.units", model.SettingTypeString, func(c *model.Config) *string { return &c.Display.Units }, oneOf(model.DisplayUnits)),
+	newSetting("display.temperature_unit", model.SettingTypeString, func(c *model.Config) *string { return &c.Display.TemperatureUnit }, oneOf(model.TemperatureUnits)),
+	newSetting("display.percent_decimals", model.SettingTypeInt, func(c *model.Config) *int { return &c.Display.PercentDecimals }, between(0, model.MaxPercentDecimals)),
+	newSetting("display.network_unit", model.SettingTypeString, func(c *model.Config) *string { return &c.Display.NetworkUnit }, oneOf(model.NetworkUnits)),
+	newSetting("display.timezone", model.SettingTypeString, func(c *model.Config) *string { return &c.Display.Timezone }, validTimezone),
+	newSetting("display.first_day_of_week", model.SettingTypeInt, func(c *model.Config) *int { return &c.Display.FirstDayOfWeek }, between(0, 6)),
+
+	newSetting("public_status.disable", model.SettingTypeBool, func(c *model.Config) *bool { return &c.PublicStatus.Disable }, nil),
+	newSetting("public_status.allowed_origins", model.SettingTypeStringList, func(c *model.Config) *[]string { return &c.PublicStatus.AllowedOrigins }, nil),
+	newSetting("public_status.rate_limit.rate", model.SettingTypeFloat, func(c *model.Config) *float64 { return &c.PublicStatus.RateLimit.Rate }, atLeast(0.0)),
+	newSetting("public_status.rate_limit.burst", model.SettingTypeInt, func(c *model.Config) *int { return &c.PublicStatus.RateLimit.Burst }, atLeast(0)),
+
+	newSetting("geoip.cache_ttl", model.SettingTypeInt, func(c *model.Config) *int { return &c.GeoIP.CacheTTL }, atLeast(1)),
+	newSetting("geoip.min_request_interval", model.SettingTypeInt, func(c *model.Config) *int { return &c.GeoIP.MinRequestInterval }, atLeast(0)),
+
+	newSetting("websocket.stream_interval", model.SettingTypeInt, func(c *model.Config) *int { return &c.WebSocket.StreamInterval }, atLeast(1)),
+	newSetting("websocket.summary_interval", model.SettingTypeInt, func(c *model.Config) *int { return &c.WebSocket.SummaryInterval }, atLeast(1)),
+	newSetting("websocket.max_connections_per_ip", model.SettingTypeInt, func(c *model.Config) *int { return &c.WebSocket.MaxConnectionsPerIP }, atLeast(0)),
+
+	newSetting("backup.keep", model.SettingTypeInt, func(c *model.Config) *int { return &c.Backup.Keep }, atLeast(1)),
+	newSetting("backup.webhook_url", model.SettingTypeSecret, func(c *model.Config) *string { return &c.Backup.WebhookURL }, nil),
+
+	newSetting("state_history_minutes", model.SettingTypeInt, func(c *model.Config) *int { return &c.StateHistoryMinutes }, atLeast(1)).restartRequired(),
+	newSetting("state_history_resolution", model.SettingTypeInt, func(c *model.Config) *int { return &c.StateHistoryResolution }, atLeast(1)).restartRequired(),
+	newSetting("grpc_keepalive.min_time", model.SettingTypeInt, func(c *model.Config) *int { return &c.GRPCKeepalive.MinTime }, atLeast(1)).restartRequired(),
+	newSetting("grpc_keepalive.permit_without_stream", model.SettingTypeBool, func(c *model.Config) *bool { return &c.GRPCKeepalive.PermitWithoutStream }, nil).restartRequired(),
+	newSetting("grpc_keepalive.time", model.SettingTypeInt, func(c *model.Config) *int { return &c.GRPCKeepalive.Time }, atLeast(1)).restartRequired(),
+	newSetting("grpc_keepalive.timeout", model.SettingTypeInt, func(c *model.Config) *int { return &c.GRPCKeepalive.Timeout }, atLeast(1)).restartRequired(),
+}
+
+type settingHook struct {
+	prefix string
+	fn     func()
+}
+
+var settings struct {
+	mu         sync.Mutex
+	defs       map[string]*settingDef
+	fileValues map[string]any // 配置文件中的值，删除修改后恢复
+	overridden map[string]bool
+	hooks      []settingHook
+}
+
+// OnSettingsChange 注册设置变化时的回调，键以 prefix 开头的设置项变化时调用
+func OnSettingsChange(prefix string, fn func()) {
+	settings.mu.Lock()
+	defer settings.mu.Unlock()
+
+	settings.hooks = append(settings.hooks, settingHook{prefix: prefix, fn: fn})
+}
+
+func init() {
+	settings.defs = make(map[string]*settingDef, len(settingRegistry))
+	for _, d := range settingRegistry {
+		settings.defs[d.key] = d
+	}
+	settings.hooks = append(settings.hooks, settingHook{prefix: "probe.deny_cidrs", fn: func() {
+		if err := Conf.updateProbeDenyNetworks(); err != nil {
+			log.Printf("NEZHA>> Failed to update probe deny networks: %v", err)
+		}
+	}}, settingHook{prefix: "geoip.", fn: applyGeoIPOptions})
+}
+
+func applyGeoIPOptions() {
+	geoip.SetOptions(time.Duration(Conf.GeoIP.CacheTTL)*time.Second, time.Duration(Conf.GeoIP.MinRequestInterval)*time.Second)
+}
+
+// loadSettings 记录配置文件中的值并应用设置表中的修改，
+// 启动时配置文件中的值已经生效，这里对被修改的设置项调用回调
+func loadSettings() error {
+	settings.mu.Lock()
+	defer settings.mu.Unlock()
+
+	settings.fileValues = make(map[string]any, len(settingRegistry))
+	for _, d := range settingRegistry {
+		settings.fileValues[d.key] = d.get(Conf.Config)
+	}
+	applied, err := applySettingOverrides()
+	if err != nil {
+		return err
+	}
+	runSettingHooks(applied)
+	return nil
+}
+
+// reloadSettings 其他节点修改设置后重新加载
+func reloadSettings() error {
+	settings.mu.Lock()
+	defer settings.mu.Unlock()
+
+	before := settingValues()
+	for _, d := range settingRegistry {
+		d.set(Conf.Config, settings.fileValues[d.key])
+	}
+	changed, err := applySettingOverrides()
+	if err != nil {
+		return err
+	}
+	after := settingValues()
+	for _, d := range settingRegistry {
+		if !slices.Contains(changed, d.key) && !jsonEqual(before[d.key], after[d.key]) {
+			changed = append(changed, d.key)
+		}
+	}
+	runSettingHooks(changed)
+	return nil
+}
+
+// applySettingOverrides 调用方需持有 settings.mu
+func applySettingOverrides() ([]string, error) {
+	var rows []model.SettingOverride
+	if err := DB.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	settings.overridden = make(map[string]bool, len(rows))
+	var applied []string
+	for _, row := range rows {
+		d, ok := settings.defs[row.Key]
+		if !ok {
+			log.Printf("NEZHA>> Ignoring unknown setting %s", row.Key)
+			continue
+		}
+		v, err := d.decode([]byte(row.Value))
+		if err != nil {
+			log.Printf("NEZHA>> Ignoring invalid setting %s: %v", row.Key, err)
+			continue
+		}
+		d.set(Conf.Config, v)
+		settings.overridden[row.Key] = true
+		applied = append(applied, row.Key)
+	}
+	return applied, nil
+}
+
+// IsSecretSetting 设置项的值不在接口与审计日志中显示
+func IsSecretSetting(key string) bool {
+	d, ok := settings.defs[key]
+	return ok && d.typ == model.SettingTypeSecret
+}
+
+// ListSettings 返回所有设置项，secret 类型只返回是否已修改
+func ListSettings() []model.SettingItem {
+	settings.mu.Lock()
+	defer settings.mu.Unlock()
+
+	items := make([]model.SettingItem, 0, len(settingRegistry))
+	for _, d := range settingRegistry {
+		item := model.SettingItem{
+			Key:             d.key,
+			Type:            d.typ,
+			Overridden:      settings.overridden[d.key],
+			RestartRequired: d.restart,
+		}
+		if d.typ != model.SettingTypeSecret {
+			item.Value = d.get(Conf.Config)
+			item.Default = settings.fileValues[d.key]
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// UpdateSettings 校验并保存修改，值为 null 时恢复配置文件中的值；任一设置项校验失败时不做任何修改
+func UpdateSettings(userID uint64, values map[string]json.RawMessage) (*model.SettingsUpdateResponse, error) {
+	errs := make(model.SettingErrors)
+	decoded := make(map[string]any, len(values))
+	for key, raw := range values {
+		d, ok := settings.defs[key]
+		if !ok {
+			errs[key] = Localizer.T("unknown setting")
+			continue
+		}
+		if string(raw) == "null" {
+			decoded[key] = nil
+			continue
+		}
+		v, err := d.decode(raw)
+		if err != nil {
+			errs[key] = err.Error()
+			continue
+		}
+		decoded[key] = v
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	settings.mu.Lock()
+	defer settings.mu.Unlock()
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		for key, v := range decoded {
+			if v == nil {
+				if err := tx.Delete(&model.SettingOverride{}, "key = ?", key).Error; err != nil {
+					return err
+				}
+				continue
+			}
+			data, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&model.SettingOverride{
+				Key:    key,
+				Value:  string(data),
+				UserID: userID,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp := new(model.SettingsUpdateResponse)
+	for key, v := range decoded {
+		d := settings.defs[key]
+		if v == nil {
+			v = settings.fileValues[key]
+			delete(settings.overridden, key)
+		} else {
+			settings.overridden[key] = true
+		}
+		d.set(Conf.Config, v)
+		resp.Updated = append(resp.Updated, key)
+		if d.restart {
+			resp.RestartRequired = append(resp.RestartRequired, key)
+		}
+	}
+	slices.Sort(resp.Updated)
+	slices.Sort(resp.RestartRequired)
+	runSettingHooks(resp.Updated)
+	ClusterShared.PublishChange("admin/settings")
+	return resp, nil
+}
+
+// fileConfig 返回写入配置文件的配置，通过设置表修改的设置项保持配置文件中的值
+func fileConfig(c *model.Config) *model.Config {
+	settings.mu.Lock()
+	defer settings.mu.Unlock()
+
+	if settings.fileValues == nil {
+		return c
+	}
+	fc := *c
+	for _, d := range settingRegistry {
+		if settings.overridden[d.key] {
+			d.set(&fc, settings.fileValues[d.key])
+		} else {
+			settings.fileValues[d.key] = d.get(c)
+		}
+	}
+	return &fc
+}
+
+// runSettingHooks 调用方需持有 settings.mu，每个回调最多调用一次
+func runSettingHooks(keys []string) {
+	for _, h := range settings.hooks {
+		if slices.ContainsFunc(keys, func(key string) bool { return strings.HasPrefix(key, h.prefix) }) {
+			h.fn()
+		}
+	}
+}
+
+func settingValues() map[string]any {
+	values := make(map[string]any, len(settingRegistry))
+	for _, d := range settingRegistry {
+		values[d.key] = d.get(Conf.Config)
+	}
+	return values
+}
+
+func jsonEqual(a, b any) bool {
+	x, _ := json.Marshal(a)
+	y, _ := json.Marshal(b)
+	return string(x) == string(y)
+}
@@ -0,0 +1,100 @@
+package singleton
+
+import (
+	"testing"
+
+	"github.com/goccy/go-json"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/i18n"
+)
+
+func TestUpdateSettings(t *testing.T) {
+	setupTestDB(t, model.SettingOverride{})
+	old := Conf
+	Conf = &ConfigClass{Config: &model.Config{}}
+	Conf.TrafficRetentionDays = 365
+	Conf.MetricsToken = "file-token"
+	oldLocalizer := Localizer
+	Localizer = i18n.NewLocalizer("en_US", domain, "translations", i18n.Translations)
+	t.Cleanup(func() { Conf, Localizer = old, oldLocalizer })
+	if err := loadSettings(); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := UpdateSettings(1, map[string]json.RawMessage{
+		"traffic_retention_days": json.RawMessage(`0`),
+		"mesh_ping_fan_out":      json.RawMessage(`"x"`),
+		"unknown":                json.RawMessage(`1`),
+	})
+	errs, ok := err.(model.SettingErrors)
+	if !ok || len(errs) != 3 {
+		t.Fatalf("expected 3 setting errors, got %v", err)
+	}
+	if Conf.TrafficRetentionDays != 365 {
+		t.Fatalf("invalid update must not change settings, got %d", Conf.TrafficRetentionDays)
+	}
+
+	resp, err := UpdateSettings(1, map[string]json.RawMessage{
+		"traffic_retention_days": json.RawMessage(`30`),
+		"metrics_token":          json.RawMessage(`"db-token"`),
+		"state_history_minutes":  json.RawMessage(`60`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Updated) != 3 || len(resp.RestartRequired) != 1 {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+	if Conf.TrafficRetentionDays != 30 || Conf.MetricsToken != "db-token" {
+		t.Fatalf("settings not applied: %d %s", Conf.TrafficRetentionDays, Conf.MetricsToken)
+	}
+	for _, item := range ListSettings() {
+		if item.Key == "metrics_token" && item.Value != nil {
+			t.Fatal("secret setting must not be listed")
+		}
+	}
+
+	// 其他节点加载设置表中的修改
+	Conf.TrafficRetentionDays = 365
+	if err := reloadSettings(); err != nil {
+		t.Fatal(err)
+	}
+	if Conf.TrafficRetentionDays != 30 {
+		t.Fatalf("expected reloaded value 30, got %d", Conf.TrafficRetentionDays)
+	}
+
+	if _, err := UpdateSettings(1, map[string]json.RawMessage{"metrics_token": json.RawMessage(`null`)}); err != nil {
+		t.Fatal(err)
+	}
+	if Conf.MetricsToken != "file-token" {
+		t.Fatalf("expected file value to be restored, got %s", Conf.MetricsToken)
+	}
+}
+
+func TestLoadSettingsRunsHooks(t *testing.T) {
+	setupTestDB(t, model.SettingOverride{})
+	old := Conf
+	Conf = &ConfigClass{Config: &model.Config{}}
+	oldNetworks := probeDenyNetworks.Load()
+	oldLocalizer := Localizer
+	Localizer = i18n.NewLocalizer("en_US", domain, "translations", i18n.Translations)
+	t.Cleanup(func() {
+		Conf, Localizer = old, oldLocalizer
+		probeDenyNetworks.Store(oldNetworks)
+	})
+	if err := Conf.updateProbeDenyNetworks(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重启后设置表中的修改同样生效
+	if err := DB.Create(&model.SettingOverride{Key: "probe.deny_cidrs", Value: `["10.0.0.0/8"]`}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := loadSettings(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CheckProbeTarget("10.1.2.3"); err == nil {
+		t.Fatal("expected overridden deny network to be applied on load")
+	}
+}
@@ -7,11 +7,11 @@ import (
 	"maps"
 	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/patrickmn/go-cache"
-	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"sigs.k8s.io/yaml"
 
@@ -34,6 +34,12 @@ var (
 	NotificationShared    *NotificationClass
 	NATShared             *NATClass
 	CronShared            *CronClass
+	CommandPolicyShared   *CommandPolicyClass
+	AccessGrantShared     *AccessGrantClass
+	APITokenShared        *APITokenClass
+	AuditLogShared        *AuditLogClass
+
+	loaded atomic.Bool
 )
 
 //go:embed frontend-templates.yaml
@@ -52,18 +58,75 @@ func InitTimezoneAndCache() error {
 
 // LoadSingleton 加载子服务并执行
 func LoadSingleton(bus chan<- *model.Service) (err error) {
+	if err = loadSettings(); err != nil { // 应用通过接口修改的设置
+		return err
+	}
+	applyGeoIPOptions()
 	initI18n() // 加载本地化服务
 	initUser() // 加载用户ID绑定表
 	NATShared = NewNATClass()
+	CommandPolicyShared = NewCommandPolicyClass()
+	AccessGrantShared = NewAccessGrantClass()
+	APITokenShared = NewAPITokenClass()
+	SessionShared = NewSessionClass()
+	AuditLogShared = NewAuditLogClass()
+	applyWAFBlockPolicy()
+	model.OnIPBlocked = onIPBlocked
+	LoginGuardShared = NewLoginGuardClass()
+	if err = loadAlertActions(); err != nil {
+		return err
+	}
+	WAFRuleShared = NewWAFRuleClass()
 	DDNSShared = NewDDNSClass()
 	NotificationShared = NewNotificationClass()
 	ServerShared = NewServerClass()
+	AgentRolloutShared = NewAgentRolloutClass()
+	FilePushShared = NewFilePushClass()
+	PurgeShared = NewPurgeClass()
 	CronShared = NewCronClass()
+	MeshShared = NewMeshClass()
 	// 最后初始化 ServiceSentinel
 	ServiceSentinelShared, err = NewServiceSentinel(bus)
+	if err == nil {
+		loaded.Store(true)
+	}
 	return
 }
 
+// Loaded 子服务是否已完成初始化
+func Loaded() bool {
+	return loaded.Load()
+}
+
+// ReloadSingleton 从数据库重新加载各子服务的缓存，用于恢复备份后
+func ReloadSingleton() error {
+	defer invalidateServiceQueryCache()
+
+	UserLock.Lock()
+	initUser()
+	UserLock.Unlock()
+
+	APITokenShared.reset()
+	SessionShared.reload()
+	NATShared.reload()
+	WAFRuleShared.Reload()
+	CommandPolicyShared.reload()
+	AccessGrantShared.Reload()
+	DDNSShared.reload()
+	NotificationShared.reload()
+	ServerShared.reload()
+	if err := loadAlertActions(); err != nil {
+		return err
+	}
+	if err := CronShared.reload(); err != nil {
+		return err
+	}
+	if err := ServiceSentinelShared.reload(); err != nil {
+		return err
+	}
+	return reloadAlerts()
+}
+
 // InitFrontendTemplates 从内置文件中加载FrontendTemplates
 func InitFrontendTemplates() error {
 	err := yaml.Unmarshal(frontendTemplatesYAML, &FrontendTemplates)
@@ -73,27 +136,30 @@ func InitFrontendTemplates() error {
 	return nil
 }
 
-// InitDBFromPath 从给出的文件路径中加载数据库
-func InitDBFromPath(path string) error {
+// OpenDBFromPath 打开数据库，不执行迁移
+func OpenDBFromPath(path string) error {
 	var err error
-	DB, err = gorm.Open(sqlite.Open(path), &gorm.Config{
-		CreateBatchSize: 200,
-	})
+	dbPath = path
+	DB, err = openDB(path)
 	if err != nil {
 		return err
 	}
 	if Conf.Debug {
 		DB = DB.Debug()
 	}
-	err = DB.AutoMigrate(model.Server{}, model.User{}, model.ServerGroup{}, model.NotificationGroup{},
-		model.Notification{}, model.AlertRule{}, model.Service{}, model.NotificationGroupNotification{},
-		model.ServiceHistory{}, model.Cron{}, model.Transfer{}, model.ServerGroupServer{},
-		model.NAT{}, model.DDNSProfile{}, model.NotificationGroupNotification{},
-		model.WAF{}, model.Oauth2Bind{})
-	if err != nil {
+	initSecretSerializer()
+	return nil
+}
+
+// InitDBFromPath 从给出的文件路径中加载数据库，并升级到最新的结构
+func InitDBFromPath(path string) error {
+	if err := OpenDBFromPath(path); err != nil {
 		return err
 	}
-	return nil
+	if err := MigrateDB(); err != nil {
+		return err
+	}
+	return backfillTransferDaily()
 }
 
 // RecordTransferHourlyUsage 对流量记录进行打点
@@ -102,28 +168,38 @@ func RecordTransferHourlyUsage(servers ...*model.Server) {
 	nowTrimSeconds := time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), 0, 0, 0, now.Location())
 
 	var txs []model.Transfer
+	var baselines []model.TransferBaseline
 	var slist iter.Seq[*model.Server]
+	var serverIDs []uint64
 	if len(servers) > 0 {
 		slist = slices.Values(servers)
+		for _, s := range servers {
+			serverIDs = append(serverIDs, s.ID)
+		}
 	} else {
 		slist = utils.Seq2To1(ServerShared.Range)
 	}
 
 	for server := range slist {
-		tx := model.Transfer{
-			ServerID: server.ID,
-			In:       utils.SubUintChecked(server.State.NetInTransfer, server.PrevTransferInSnapshot),
-			Out:      utils.SubUintChecked(server.State.NetOutTransfer, server.PrevTransferOutSnapshot),
+		if !ClusterShared.OwnsServer(server.ID) {
+			continue
+		}
+		in, out, baseline := server.Transfer.Take()
+		if !baseline.UpdatedAt.IsZero() {
+			baseline.ServerID, baseline.BootTime = server.ID, server.Host.BootTime
+			baselines = append(baselines, baseline)
 		}
-		if tx.In == 0 && tx.Out == 0 {
+		if in == 0 && out == 0 {
 			continue
 		}
-		server.PrevTransferInSnapshot = server.State.NetInTransfer
-		server.PrevTransferOutSnapshot = server.State.NetOutTransfer
+		tx := model.Transfer{ServerID: server.ID, In: in, Out: out}
 		tx.CreatedAt = nowTrimSeconds
 		txs = append(txs, tx)
 	}
 
+	recordTransferDaily(nowTrimSeconds, txs, serverIDs...)
+	saveTransferBaselines(baselines)
+
 	if len(txs) == 0 {
 		return
 	}
@@ -132,51 +208,28 @@ func RecordTransferHourlyUsage(servers ...*model.Server) {
 
 // CleanServiceHistory 清理无效或过时的 监控记录 和 流量记录
 func CleanServiceHistory() {
+	defer markJobRun("clean_history", time.Now())
+	// 各监测点的原始记录按保留策略汇总为小时、每日数据，并清理过期的流量记录
+	cleanServiceHistoryTiers()
 	// 清理已被删除的服务器的监控记录与流量记录
-	DB.Unscoped().Delete(&model.ServiceHistory{}, "created_at < ? OR service_id NOT IN (SELECT `id` FROM services)", time.Now().AddDate(0, 0, -30))
-	// 由于网络监控记录的数据较多，并且前端仅使用了 1 天的数据
-	// 考虑到 sqlite 数据量问题，仅保留一天数据，
-	// server_id = 0 的数据会用于/service页面的可用性展示
-	DB.Unscoped().Delete(&model.ServiceHistory{}, "(created_at < ? AND server_id != 0) OR service_id NOT IN (SELECT `id` FROM services)", time.Now().AddDate(0, 0, -1))
+	// server_id = 0 的汇总记录保留 90 天，用于/service页面与状态页的可用性展示
+	DB.Unscoped().Delete(&model.ServiceHistory{}, "created_at < ? OR service_id NOT IN (SELECT `id` FROM services)", time.Now().AddDate(0, 0, -statusPageDays))
 	DB.Unscoped().Delete(&model.Transfer{}, "server_id NOT IN (SELECT `id` FROM servers)")
-	// 计算可清理流量记录的时长
-	var allServerKeep time.Time
-	specialServerKeep := make(map[uint64]time.Time)
-	var specialServerIDs []uint64
-	var alerts []model.AlertRule
-	DB.Find(&alerts)
-	for _, alert := range alerts {
-		for _, rule := range alert.Rules {
-			// 是不是流量记录规则
-			if !rule.IsTransferDurationRule() {
-				continue
-			}
-			dataCouldRemoveBefore := rule.GetTransferDurationStart().UTC()
-			// 判断规则影响的机器范围
-			if rule.Cover == model.RuleCoverAll {
-				// 更新全局可以清理的数据点
-				if allServerKeep.IsZero() || allServerKeep.After(dataCouldRemoveBefore) {
-					allServerKeep = dataCouldRemoveBefore
-				}
-			} else {
-				// 更新特定机器可以清理数据点
-				for id := range rule.Ignore {
-					if specialServerKeep[id].IsZero() || specialServerKeep[id].After(dataCouldRemoveBefore) {
-						specialServerKeep[id] = dataCouldRemoveBefore
-						specialServerIDs = append(specialServerIDs, id)
-					}
-				}
-			}
-		}
-	}
-	for id, couldRemove := range specialServerKeep {
-		DB.Unscoped().Delete(&model.Transfer{}, "server_id = ? AND datetime(`created_at`) < datetime(?)", id, couldRemove)
-	}
-	if allServerKeep.IsZero() {
-		DB.Unscoped().Delete(&model.Transfer{}, "server_id NOT IN (?)", specialServerIDs)
-	} else {
-		DB.Unscoped().Delete(&model.Transfer{}, "server_id NOT IN (?) AND datetime(`created_at`) < datetime(?)", specialServerIDs, allServerKeep)
-	}
+	DB.Unscoped().Delete(&model.TransferBaseline{}, "server_id NOT IN (SELECT `id` FROM servers)")
+	DB.Unscoped().Delete(&model.ServiceCert{}, "service_id NOT IN (SELECT `id` FROM services)")
+	CleanCronHistory()
+	NATShared.stats.clean()
+	cleanDDNSHistory()
+	cleanAuditLog()
+	cleanServerEvents()
+	cleanServerStateHourly()
+	cleanServerUptime()
+	cleanSessions()
+	cleanAdminBypassUses()
+	cleanTerminalRecordings()
+	cleanMeshLatency()
+	// 超出保留期限的流量记录由 RunHistoryRetention 分批清理
+	DB.Unscoped().Delete(&model.TransferDaily{}, "server_id NOT IN (SELECT `id` FROM servers)")
 }
 
 // IPDesensitize 根据设置选择是否对IP进行打码处理 返回处理后的IP(关闭打码则返回原IP)
@@ -192,9 +245,24 @@ type class[K comparable, V model.CommonInterface] struct {
 	listMu sync.RWMutex
 
 	sortedList   []V
+	userList     map[uint64][]V // 按所属用户分组的 sortedList，严格租户模式下直接取用
 	sortedListMu sync.RWMutex
 }
 
+// setSortedList 更新排序后的列表及按用户分组的索引，调用方需持有 sortedListMu
+func (c *class[K, V]) setSortedList(list []V) {
+	c.sortedList = list
+	c.userList = groupByUser(list)
+}
+
+func groupByUser[V model.CommonInterface](list []V) map[uint64][]V {
+	m := make(map[uint64][]V)
+	for _, v := range list {
+		m[v.GetUserID()] = append(m[v.GetUserID()], v)
+	}
+	return m
+}
+
 func (c *class[K, V]) Get(id K) (s V, ok bool) {
 	c.listMu.RLock()
 	defer c.listMu.RUnlock()
@@ -217,6 +285,23 @@ func (c *class[K, V]) GetSortedList() []V {
 	return slices.Clone(c.sortedList)
 }
 
+// GetSortedListByTenant 返回属于租户范围内用户的资源，保持原有顺序
+func (c *class[K, V]) GetSortedListByTenant(scope model.TenantScope) []V {
+	c.sortedListMu.RLock()
+	defer c.sortedListMu.RUnlock()
+
+	if len(scope) == 1 {
+		return slices.Clone(c.userList[scope[0]])
+	}
+	var list []V
+	for _, v := range c.sortedList {
+		if scope.Contains(v.GetUserID()) {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
 func (c *class[K, V]) Range(fn func(k K, v V) bool) {
 	c.listMu.RLock()
 	defer c.listMu.RUnlock()
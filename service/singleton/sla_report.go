@@ -0,0 +1,263 @@
+package singleton
+
+import (
+	"errors"
+	"html/template"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-json"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+var slaReportTemplate = template.Must(template.New("sla").Funcs(template.FuncMap{
+	"percent": func(v float64) string {
+		if v < 0 {
+			return "-"
+		}
+		return strconv.FormatFloat(v, 'f', 3, 64) + "%"
+	},
+	"duration": func(seconds int64) string {
+		return (time.Duration(seconds) * time.Second).String()
+	},
+	"datetime": func(t time.Time) string {
+		return t.In(Loc).Format(time.DateTime)
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Name}}</title>
+<style>
+body{font-family:sans-serif;margin:2em}table{border-collapse:collapse;margin-bottom:1.5em}
+th,td{border:1px solid #ccc;padding:4px 8px;text-align:left}.miss{color:#c00}
+</style>
+</head>
+<body>
+<h1>{{.Name}}</h1>
+<p>{{datetime .PeriodStart}} - {{datetime .PeriodEnd}}, target {{percent .Target}}</p>
+<table>
+<tr><th>Service</th><th>Uptime</th><th>Downtime</th><th>Maintenance</th><th>Mean latency (ms)</th><th>Incidents</th></tr>
+{{range .Services}}<tr{{if not .MetTarget}} class="miss"{{end}}><td>{{.Name}}</td><td>{{percent .Uptime}}</td><td>{{duration .Downtime}}</td><td>{{duration .Maintenance}}</td><td>{{printf "%.2f" .MeanLatency}}</td><td>{{len .Incidents}}</td></tr>
+{{end}}</table>
+{{if .ServerGroups}}<table>
+<tr><th>Server group</th><th>Uptime</th><th>Servers</th></tr>
+{{range .ServerGroups}}<tr{{if not .MetTarget}} class="miss"{{end}}><td>{{.Name}}</td><td>{{percent .Uptime}}</td><td>{{len .Servers}}</td></tr>
+{{end}}</table>
+{{range .ServerGroups}}{{if .Servers}}<h2>{{.Name}}</h2>
+<table>
+<tr><th>Server</th><th>Uptime</th><th>Downtime</th></tr>
+{{range .Servers}}<tr><td>{{.Name}}</td><td>{{percent .Uptime}}</td><td>{{duration .Downtime}}</td></tr>
+{{end}}</table>
+{{end}}{{end}}{{end}}{{range .Services}}{{if .Incidents}}<h2>{{.Name}}</h2>
+<table>
+<tr><th>Start</th><th>End</th><th>Duration</th></tr>
+{{range .Incidents}}<tr><td>{{datetime .Start}}</td><td>{{datetime .End}}</td><td>{{duration .Duration}}</td></tr>
+{{end}}</table>
+{{end}}{{end}}<p>Generated at {{datetime .GeneratedAt}}</p>
+</body>
+</html>
+`))
+
+// RunDueSLAReports 为启用的报告配置补齐上一个完整周期的报告，已生成的周期不会重复生成
+func RunDueSLAReports() {
+	var configs []*model.SLAReportConfig
+	if err := DB.Where("enabled = ?", true).Find(&configs).Error; err != nil {
+		log.Printf("NEZHA>> Failed to load SLA report configs: %v", err)
+		return
+	}
+	now := time.Now().In(Loc)
+	for _, cfg := range configs {
+		start, end := cfg.PeriodRange(now)
+		var count int64
+		if err := DB.Model(&model.SLAReport{}).Where("config_id = ? AND period_start = ?", cfg.ID, start).Count(&count).Error; err != nil {
+			log.Printf("NEZHA>> Failed to check SLA report %d: %v", cfg.ID, err)
+			continue
+		}
+		if count > 0 {
+			continue
+		}
+		if _, err := GenerateSLAReport(cfg, start, end); err != nil {
+			log.Printf("NEZHA>> Failed to generate SLA report %d: %v", cfg.ID, err)
+		}
+	}
+}
+
+// GenerateSLAReport 计算并保存报告，配置了 Webhook 时推送 JSON 数据
+func GenerateSLAReport(cfg *model.SLAReportConfig, start, end time.Time) (*model.SLAReport, error) {
+	data, err := computeSLAReport(cfg, start, end)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	var html strings.Builder
+	if err := slaReportTemplate.Execute(&html, data); err != nil {
+		return nil, err
+	}
+
+	report := &model.SLAReport{
+		ConfigID:    cfg.ID,
+		Name:        cfg.Name,
+		PeriodStart: start,
+		PeriodEnd:   end,
+		Data:        string(raw),
+		HTML:        html.String(),
+	}
+	report.UserID = cfg.UserID
+	if err := DB.Create(report).Error; err != nil {
+		return nil, err
+	}
+
+	if cfg.WebhookURL != "" {
+		if err := postWebhook(cfg.WebhookURL, data); err != nil {
+			log.Printf("NEZHA>> Failed to deliver SLA report %d: %v", report.ID, err)
+		}
+	}
+	return report, nil
+}
+
+func computeSLAReport(cfg *model.SLAReportConfig, start, end time.Time) (*model.SLAReportData, error) {
+	if len(cfg.Services) == 0 && len(cfg.ServerGroups) == 0 {
+		return nil, errors.New("no service or server group in report")
+	}
+
+	var rows []model.ServiceHistory
+	if err := DB.Select("service_id, created_at, up, down, avg_delay").
+		Where("server_id = 0 AND service_id IN (?) AND created_at >= ? AND created_at < ?", cfg.Services, start, end).
+		Order("created_at").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	byService := make(map[uint64][]model.ServiceHistory)
+	for _, r := range rows {
+		byService[r.ServiceID] = append(byService[r.ServiceID], r)
+	}
+
+	maintenance, err := slaMaintenanceWindows(start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &model.SLAReportData{
+		Name:        cfg.Name,
+		PeriodStart: start,
+		PeriodEnd:   end,
+		Target:      cfg.Target,
+		GeneratedAt: time.Now(),
+		Services:    make([]*model.SLAServiceReport, 0, len(cfg.Services)),
+	}
+	for _, id := range cfg.Services {
+		sr := model.ComputeServiceSLA(byService[id], maintenance[id])
+		sr.ID = id
+		if s, ok := ServiceSentinelShared.Get(id); ok {
+			sr.Name = s.Name
+		}
+		sr.MetTarget = sr.Uptime < 0 || sr.Uptime >= cfg.Target
+		data.Services = append(data.Services, sr)
+	}
+
+	if data.ServerGroups, err = computeServerGroupSLA(cfg.ServerGroups, start, end); err != nil {
+		return nil, err
+	}
+	for _, gr := range data.ServerGroups {
+		gr.MetTarget = gr.Uptime < 0 || gr.Uptime >= cfg.Target
+	}
+	return data, nil
+}
+
+// computeServerGroupSLA 根据在线采样计算各服务器分组的可用率，分组成员按当前分组计算
+func computeServerGroupSLA(groupIDs []uint64, start, end time.Time) ([]*model.SLAServerGroupReport, error) {
+	ret := make([]*model.SLAServerGroupReport, 0, len(groupIDs))
+	if len(groupIDs) == 0 {
+		return ret, nil
+	}
+
+	var groups []model.ServerGroup
+	if err := DB.Where("id IN (?)", groupIDs).Find(&groups).Error; err != nil {
+		return nil, err
+	}
+	names := make(map[uint64]string)
+	for _, g := range groups {
+		names[g.ID] = g.Name
+	}
+
+	serverIDs := ServerShared.GroupMembers(groupIDs...)
+	var rows []model.ServerUptime
+	if len(serverIDs) > 0 {
+		if err := DB.Where("server_id IN (?) AND start >= ? AND start < ?", serverIDs, start, end).
+			Order("start").Find(&rows).Error; err != nil {
+			return nil, err
+		}
+	}
+	byServer := make(map[uint64][]model.ServerUptime)
+	for _, r := range rows {
+		byServer[r.ServerID] = append(byServer[r.ServerID], r)
+	}
+	backfilled, err := backfilledRanges(serverIDs, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, gid := range groupIDs {
+		members := ServerShared.GroupMembers(gid)
+		servers := make([]*model.SLAServerReport, 0, len(members))
+		for _, id := range members {
+			sr := model.ComputeServerSLA(byServer[id], backfilled[id])
+			sr.ID = id
+			if s, ok := ServerShared.Get(id); ok {
+				sr.Name = s.Name
+			}
+			servers = append(servers, sr)
+		}
+		gr := model.ComputeServerGroupSLA(servers)
+		gr.ID = gid
+		gr.Name = names[gid]
+		ret = append(ret, gr)
+	}
+	return ret, nil
+}
+
+// slaMaintenanceWindows 返回周期内各服务的维护窗口，即严重程度为维护的状态页事件
+func slaMaintenanceWindows(start, end time.Time) (map[uint64][]model.TimeRange, error) {
+	var incidents []model.Incident
+	if err := DB.Where("severity = ? AND created_at < ? AND (resolved_at IS NULL OR resolved_at > ?)",
+		model.IncidentSeverityMaintenance, end, start).Find(&incidents).Error; err != nil {
+		return nil, err
+	}
+	ret := make(map[uint64][]model.TimeRange)
+	for _, i := range incidents {
+		window := model.TimeRange{Start: i.CreatedAt, End: end}
+		if i.ResolvedAt != nil {
+			window.End = *i.ResolvedAt
+		}
+		for _, id := range slices.Compact(slices.Sorted(slices.Values(i.Services))) {
+			ret[id] = append(ret[id], window)
+		}
+	}
+	return ret, nil
+}
+
+// backfilledRanges 返回周期内各服务器补报过状态的时间段，补报事件最晚在所覆盖的时间段之后一天内记录
+func backfilledRanges(serverIDs []uint64, start, end time.Time) (map[uint64][]model.TimeRange, error) {
+	ret := make(map[uint64][]model.TimeRange)
+	if len(serverIDs) == 0 {
+		return ret, nil
+	}
+	var events []model.ServerEvent
+	if err := DB.Where("server_id IN (?) AND type = ? AND created_at >= ? AND created_at < ?",
+		serverIDs, model.ServerEventBackfilled, start, end.Add(model.StateBackfillMaxAge)).Find(&events).Error; err != nil {
+		return nil, err
+	}
+	for _, e := range events {
+		if r, ok := e.BackfilledRange(); ok {
+			ret[e.ServerID] = append(ret[e.ServerID], r)
+		}
+	}
+	return ret, nil
+}
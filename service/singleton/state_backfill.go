@@ -0,0 +1,93 @@
+package singleton
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+// backfillTracker 记录各服务器已合并的最后一个补报采样的时间，重复投递的采样据此忽略
+type backfillTracker struct {
+	mu    sync.Mutex
+	after map[uint64]int64
+}
+
+func newBackfillTracker() *backfillTracker {
+	return &backfillTracker{after: make(map[uint64]int64)}
+}
+
+// Delete 删除服务器的补报记录
+func (t *backfillTracker) Delete(ids ...uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, id := range ids {
+		delete(t.after, id)
+	}
+}
+
+// BackfillState 合并 Agent 断线期间缓存的状态：按时间计入流量与状态历史，不修改最后上报时间与当前状态，
+// 并记录补报的时间段，期间视为在线
+func (c *ServerClass) BackfillState(server *model.Server, backfill *model.StateBackfill) error {
+	t := c.backfills
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	after, ok := t.after[server.ID]
+	if !ok {
+		// 面板重启后以最近一次补报记录为准
+		after = lastBackfilled(server.ID)
+	}
+
+	samples, rejected := backfill.Filter(time.Now(), after)
+	var history []model.StateSample
+	accepted := samples[:0]
+	for _, s := range samples {
+		if err := s.State.Sanitize(server.Host); err != nil {
+			rejected++
+			continue
+		}
+		at := time.UnixMilli(s.Time)
+		ObserveTransfer(server, s.State.NetInTransfer, s.State.NetOutTransfer, at)
+		history = append(history, model.NewStateSample(at, &s.State))
+		accepted = append(accepted, s)
+	}
+	if len(history) == 0 {
+		t.after[server.ID] = after
+		return rejectedSamples(rejected)
+	}
+
+	c.stateHistory.Merge(server.ID, history, model.BackfillCustomMetrics(accepted))
+	from, to := history[0].Time, history[len(history)-1].Time
+	t.after[server.ID] = to
+	RecordServerEvent(server.ID, model.ServerEventBackfilled, model.ServerEventActorAgent, 0,
+		model.ServerEventChange{Field: "from", New: from},
+		model.ServerEventChange{Field: "to", New: to},
+		model.ServerEventChange{Field: "samples", New: len(history)},
+	)
+	return rejectedSamples(rejected)
+}
+
+func lastBackfilled(serverID uint64) int64 {
+	var last model.ServerEvent
+	if err := DB.Where("server_id = ? AND type = ?", serverID, model.ServerEventBackfilled).
+		Order("id DESC").Limit(1).Find(&last).Error; err != nil || last.ID == 0 {
+		return 0
+	}
+	// 读取后的数值为 float64
+	if change, ok := last.Change("to"); ok {
+		if to, ok := change.New.(float64); ok {
+			return int64(to)
+		}
+	}
+	return 0
+}
+
+func rejectedSamples(n int) error {
+	if n == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d backfilled sample(s) rejected", n)
+}
@@ -0,0 +1,310 @@
+package singleton
+
+import (
+	"cmp"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"gorm.io/gorm/clause"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+// stateHistory 在内存中按服务器保存最近的状态采样，每台服务器使用固定大小的环形缓冲区
+type stateHistory struct {
+	mu         sync.RWMutex
+	rings      map[uint64]*stateRing
+	capacity   int
+	resolution time.Duration // 两个采样点的最小间隔，间隔内的上报被忽略
+}
+
+type stateRing struct {
+	samples []model.StateSample
+	next    int
+	full    bool
+
+	// 自定义指标按名称分别保存，与内置指标同时采样、保留时长相同。
+	// 指标停止上报后保留到最后一个采样点过期，期间重新出现时继续使用原有的缓冲区
+	custom map[string]*customRing
+}
+
+// customRing 自定义指标的环形缓冲区，随采样逐步扩容到与内置指标相同的容量
+type customRing struct {
+	samples []model.CustomMetricSample
+	next    int
+}
+
+// maxCustomRings 每台服务器最多同时保留的自定义指标数，指标名称频繁变化时新的指标会被忽略，直到旧的指标过期
+const maxCustomRings = 2 * model.ReportMaxCustomMetrics
+
+// stateHistoryConf 返回状态历史的保留时长与采样间隔，未加载配置时使用默认值
+func stateHistoryConf() (window, resolution time.Duration) {
+	window, resolution = 180*time.Minute, 3*time.Second
+	if Conf == nil {
+		return
+	}
+	if Conf.StateHistoryMinutes > 0 {
+		window = time.Duration(Conf.StateHistoryMinutes) * time.Minute
+	}
+	if Conf.StateHistoryResolution > 0 {
+		resolution = time.Duration(Conf.StateHistoryResolution) * time.Second
+	}
+	return
+}
+
+func newStateHistory(window, resolution time.Duration) *stateHistory {
+	return &stateHistory{
+		rings:      make(map[uint64]*stateRing),
+		capacity:   max(int(window/resolution), 1),
+		resolution: resolution,
+	}
+}
+
+// Add 记录一次状态上报
+func (h *stateHistory) Add(serverID uint64, t time.Time, state *model.HostState) {
+	if state == nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	r, ok := h.rings[serverID]
+	if !ok {
+		r = &stateRing{samples: make([]model.StateSample, h.capacity)}
+		h.rings[serverID] = r
+	}
+	if last, ok := r.last(); ok && t.UnixMilli()-last.Time < h.resolution.Milliseconds() {
+		return
+	}
+	r.samples[r.next] = model.NewStateSample(t, state)
+	r.next = (r.next + 1) % len(r.samples)
+	if r.next == 0 {
+		r.full = true
+	}
+	h.addCustom(r, t, state.CustomMetrics)
+}
+
+func (h *stateHistory) addCustom(r *stateRing, t time.Time, metrics map[string]float64) {
+	ms := t.UnixMilli()
+	expired := ms - int64(h.capacity)*h.resolution.Milliseconds()
+	for name, cr := range r.custom {
+		if cr.last().Time < expired {
+			delete(r.custom, name)
+		}
+	}
+
+	for name, v := range metrics {
+		cr, ok := r.custom[name]
+		if !ok {
+			if len(r.custom) >= maxCustomRings {
+				continue
+			}
+			if r.custom == nil {
+				r.custom = make(map[string]*customRing)
+			}
+			cr = &customRing{}
+			r.custom[name] = cr
+		}
+		cr.add(model.CustomMetricSample{Time: ms, Value: v}, h.capacity)
+	}
+}
+
+// Merge 按时间合并补报的采样及自定义指标
+func (h *stateHistory) Merge(serverID uint64, samples []model.StateSample, custom map[string][]model.CustomMetricSample) {
+	if len(samples) == 0 {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	r, ok := h.rings[serverID]
+	if !ok {
+		r = &stateRing{samples: make([]model.StateSample, h.capacity)}
+		h.rings[serverID] = r
+	}
+	merged := model.MergeStateSamples(r.ordered(), samples, h.resolution.Milliseconds(), h.capacity)
+	r.next = copy(r.samples, merged) % len(r.samples)
+	r.full = len(merged) == len(r.samples)
+
+	for name, added := range custom {
+		cr, ok := r.custom[name]
+		if !ok {
+			if len(r.custom) >= maxCustomRings {
+				continue
+			}
+			if r.custom == nil {
+				r.custom = make(map[string]*customRing)
+			}
+			cr = &customRing{}
+			r.custom[name] = cr
+		}
+		cr.samples = model.MergeCustomMetricSamples(cr.ordered(), added, h.resolution.Milliseconds(), h.capacity)
+		cr.next = 0
+	}
+}
+
+// Since 返回 since 之后的采样点，按时间排序
+func (h *stateHistory) Since(serverID uint64, since time.Time) []model.StateSample {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	r, ok := h.rings[serverID]
+	if !ok {
+		return nil
+	}
+	ordered := r.ordered()
+	ms := since.UnixMilli()
+	for i, s := range ordered {
+		if s.Time >= ms {
+			return ordered[i:]
+		}
+	}
+	return nil
+}
+
+// CustomSince 返回 since 之后各自定义指标的采样点，按时间排序，names 为空时返回全部指标
+func (h *stateHistory) CustomSince(serverID uint64, since time.Time, names ...string) map[string][]model.CustomMetricSample {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	r, ok := h.rings[serverID]
+	if !ok || len(r.custom) == 0 {
+		return nil
+	}
+	ms := since.UnixMilli()
+	ret := make(map[string][]model.CustomMetricSample)
+	for name, cr := range r.custom {
+		if len(names) > 0 && !slices.Contains(names, name) {
+			continue
+		}
+		ordered := cr.ordered()
+		i, _ := slices.BinarySearchFunc(ordered, ms, func(s model.CustomMetricSample, t int64) int {
+			return cmp.Compare(s.Time, t)
+		})
+		if i < len(ordered) {
+			ret[name] = ordered[i:]
+		}
+	}
+	return ret
+}
+
+// Purge 删除服务器在 before 之前的采样，返回删除的内置指标采样数
+func (h *stateHistory) Purge(serverID uint64, before time.Time) int64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	r, ok := h.rings[serverID]
+	if !ok {
+		return 0
+	}
+	ms := before.UnixMilli()
+	ordered := r.ordered()
+	i, _ := slices.BinarySearchFunc(ordered, ms, func(s model.StateSample, t int64) int {
+		return cmp.Compare(s.Time, t)
+	})
+	if i > 0 {
+		clear(r.samples)
+		r.next = copy(r.samples, ordered[i:]) % len(r.samples)
+		r.full = len(ordered)-i == len(r.samples)
+	}
+
+	for name, cr := range r.custom {
+		ordered := cr.ordered()
+		j, _ := slices.BinarySearchFunc(ordered, ms, func(s model.CustomMetricSample, t int64) int {
+			return cmp.Compare(s.Time, t)
+		})
+		if j == len(ordered) {
+			delete(r.custom, name)
+			continue
+		}
+		cr.samples, cr.next = ordered[j:], 0
+	}
+	return int64(i)
+}
+
+// Delete 清除服务器的采样
+func (h *stateHistory) Delete(serverIDs ...uint64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, id := range serverIDs {
+		delete(h.rings, id)
+	}
+}
+
+func (r *stateRing) last() (model.StateSample, bool) {
+	if !r.full && r.next == 0 {
+		return model.StateSample{}, false
+	}
+	return r.samples[(r.next-1+len(r.samples))%len(r.samples)], true
+}
+
+func (r *stateRing) ordered() []model.StateSample {
+	var ordered []model.StateSample
+	if r.full {
+		ordered = append(ordered, r.samples[r.next:]...)
+	}
+	return append(ordered, r.samples[:r.next]...)
+}
+
+func (r *customRing) add(s model.CustomMetricSample, capacity int) {
+	if len(r.samples) < capacity {
+		r.samples = append(r.samples, s)
+		return
+	}
+	r.samples[r.next] = s
+	r.next = (r.next + 1) % len(r.samples)
+}
+
+func (r *customRing) last() model.CustomMetricSample {
+	if len(r.samples) == 0 {
+		return model.CustomMetricSample{}
+	}
+	return r.samples[(r.next-1+len(r.samples))%len(r.samples)]
+}
+
+func (r *customRing) ordered() []model.CustomMetricSample {
+	return append(slices.Clone(r.samples[r.next:]), r.samples[:r.next]...)
+}
+
+// RecordServerStateHourly 将上一个整点小时的状态采样汇总写入数据库，重复执行时覆盖已有的汇总。
+// 状态历史的保留时长不足一小时时只汇总仍保留的采样
+func RecordServerStateHourly() {
+	defer markJobRun("server_state_hourly", time.Now())
+
+	end := time.Now().Truncate(time.Hour)
+	start := end.Add(-time.Hour)
+	var rows []model.ServerStateHourly
+	for _, s := range ServerShared.GetSortedList() {
+		var sum float64
+		var n uint64
+		for _, sample := range ServerShared.StateHistory(s.ID, time.Since(start)) {
+			if sample.Time >= start.UnixMilli() && sample.Time < end.UnixMilli() {
+				sum += sample.CPU
+				n++
+			}
+		}
+		if n > 0 {
+			rows = append(rows, model.ServerStateHourly{ServerID: s.ID, Start: start, CPU: sum / float64(n), Samples: n})
+		}
+	}
+	if len(rows) == 0 {
+		return
+	}
+	if err := DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "server_id"}, {Name: "start"}},
+		DoUpdates: clause.AssignmentColumns([]string{"cpu", "samples"}),
+	}).Create(&rows).Error; err != nil {
+		log.Printf("NEZHA>> Failed to save hourly server state: %v", err)
+	}
+}
+
+// cleanServerStateHourly 删除已删除服务器及超出汇总统计范围的小时汇总
+func cleanServerStateHourly() {
+	DB.Unscoped().Delete(&model.ServerStateHourly{}, "server_id NOT IN (SELECT `id` FROM servers) OR start < ?",
+		time.Now().AddDate(0, 0, -max(Conf.FleetSummary.Days, 1)-1))
+}
@@ -0,0 +1,104 @@
+package singleton
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+func TestStateHistory(t *testing.T) {
+	h := newStateHistory(5*time.Second, time.Second)
+	start := time.Unix(1700000000, 0)
+	for i := range 8 {
+		h.Add(1, start.Add(time.Duration(i)*time.Second), &model.HostState{CPU: float64(i)})
+		// 间隔小于采样精度的上报被忽略
+		h.Add(1, start.Add(time.Duration(i)*time.Second+100*time.Millisecond), &model.HostState{CPU: 100})
+	}
+
+	samples := h.Since(1, start)
+	if len(samples) != 5 {
+		t.Fatalf("expected ring to keep 5 samples, got %d", len(samples))
+	}
+	for i, s := range samples {
+		if s.CPU != float64(i+3) {
+			t.Fatalf("sample %d: expected cpu %d, got %v", i, i+3, s.CPU)
+		}
+	}
+
+	if samples := h.Since(1, start.Add(6*time.Second)); len(samples) != 2 {
+		t.Fatalf("expected 2 samples since 6s, got %d", len(samples))
+	}
+
+	h.Delete(1)
+	if samples := h.Since(1, start); samples != nil {
+		t.Fatalf("expected history to be cleared, got %v", samples)
+	}
+}
+
+func TestStateHistoryCustomMetrics(t *testing.T) {
+	h := newStateHistory(5*time.Second, time.Second)
+	start := time.Unix(1700000000, 0)
+	for i := range 8 {
+		metrics := map[string]float64{"queue": float64(i)}
+		// rps 只在前两次上报中出现
+		if i < 2 {
+			metrics["rps"] = float64(i * 10)
+		}
+		h.Add(1, start.Add(time.Duration(i)*time.Second), &model.HostState{CustomMetrics: metrics})
+	}
+
+	history := h.CustomSince(1, start)
+	queue := history["queue"]
+	if len(queue) != 5 {
+		t.Fatalf("expected custom ring to keep 5 samples like built-in metrics, got %d", len(queue))
+	}
+	for i, s := range queue {
+		if s.Value != float64(i+3) {
+			t.Fatalf("sample %d: expected %d, got %v", i, i+3, s.Value)
+		}
+	}
+	// 停止上报的指标在最后一个采样点过期后被清除
+	if _, ok := history["rps"]; ok {
+		t.Fatalf("expected expired metric to be dropped, got %v", history["rps"])
+	}
+
+	if history := h.CustomSince(1, start.Add(6*time.Second), "queue"); len(history["queue"]) != 2 {
+		t.Fatalf("expected 2 samples since 6s, got %v", history)
+	}
+}
+
+func TestStateHistoryPurge(t *testing.T) {
+	h := newStateHistory(5*time.Second, time.Second)
+	start := time.Unix(1700000000, 0)
+	for i := range 7 {
+		h.Add(1, start.Add(time.Duration(i)*time.Second), &model.HostState{CPU: float64(i), CustomMetrics: map[string]float64{"queue": float64(i)}})
+	}
+
+	if n := h.Purge(1, start.Add(4*time.Second)); n != 2 {
+		t.Fatalf("expected 2 samples purged, got %d", n)
+	}
+	samples := h.Since(1, start)
+	if len(samples) != 3 || samples[0].CPU != 4 {
+		t.Fatalf("expected samples from 4s, got %v", samples)
+	}
+	if queue := h.CustomSince(1, start)["queue"]; len(queue) != 3 || queue[0].Value != 4 {
+		t.Fatalf("expected custom samples from 4s, got %v", queue)
+	}
+
+	// 清理后继续写入
+	h.Add(1, start.Add(7*time.Second), &model.HostState{CPU: 7})
+	if samples := h.Since(1, start); len(samples) != 4 || samples[3].CPU != 7 {
+		t.Fatalf("expected new sample appended after purge, got %v", samples)
+	}
+
+	if n := h.Purge(1, start.Add(time.Minute)); n != 4 {
+		t.Fatalf("expected all samples purged, got %d", n)
+	}
+	if samples := h.Since(1, start); len(samples) != 0 {
+		t.Fatalf("expected no samples, got %v", samples)
+	}
+	if h.Purge(2, start) != 0 {
+		t.Fatal("expected unknown server to purge nothing")
+	}
+}
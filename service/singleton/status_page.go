@@ -0,0 +1,247 @@
+package singleton
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/goccy/go-json"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+const (
+	statusPageCacheKey = "status-page"
+	statusPageDays     = 90
+)
+
+type StatusPageCache struct {
+	Response *model.StatusPageResponse
+	ETag     string
+}
+
+// GetStatusPage 获取公开状态页数据，结果缓存一分钟
+func GetStatusPage() (*StatusPageCache, error) {
+	if v, ok := Cache.Get(statusPageCacheKey); ok {
+		return v.(*StatusPageCache), nil
+	}
+
+	resp, err := buildStatusPage()
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha1.Sum(data)
+	sp := &StatusPageCache{
+		Response: resp,
+		ETag:     `W/"` + hex.EncodeToString(sum[:]) + `"`,
+	}
+	Cache.Set(statusPageCacheKey, sp, time.Minute)
+	return sp, nil
+}
+
+// InvalidateStatusPage 状态页配置或事件变更后清除缓存
+func InvalidateStatusPage() {
+	Cache.Delete(statusPageCacheKey)
+}
+
+func buildStatusPage() (*model.StatusPageResponse, error) {
+	var sections []model.StatusPageSection
+	if err := DB.Order("display_index DESC, id").Find(&sections).Error; err != nil {
+		return nil, err
+	}
+
+	var serviceIDs, groupIDs []uint64
+	for _, s := range sections {
+		serviceIDs = append(serviceIDs, s.Services...)
+		groupIDs = append(groupIDs, s.ServerGroups...)
+	}
+
+	bars, err := loadUptimeBars(serviceIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make(map[uint64]model.ServerGroup)
+	groupServers := make(map[uint64][]uint64)
+	if len(groupIDs) > 0 {
+		var sg []model.ServerGroup
+		if err := DB.Where("id IN (?)", groupIDs).Find(&sg).Error; err != nil {
+			return nil, err
+		}
+		for _, g := range sg {
+			groups[g.ID] = g
+		}
+		var sgs []model.ServerGroupServer
+		if err := DB.Where("server_group_id IN (?)", groupIDs).Find(&sgs).Error; err != nil {
+			return nil, err
+		}
+		for _, s := range sgs {
+			groupServers[s.ServerGroupId] = append(groupServers[s.ServerGroupId], s.ServerId)
+		}
+	}
+
+	resp := &model.StatusPageResponse{
+		SiteName:  Conf.SiteName,
+		Sections:  make([]model.StatusPageSectionItem, 0, len(sections)),
+		UpdatedAt: time.Now(),
+	}
+
+	for _, s := range sections {
+		item := model.StatusPageSectionItem{
+			Name:         s.Name,
+			Services:     make([]model.StatusPageService, 0, len(s.Services)),
+			ServerGroups: make([]model.StatusPageServerGroup, 0, len(s.ServerGroups)),
+		}
+		for _, id := range s.Services {
+			service, ok := ServiceSentinelShared.Get(id)
+			if !ok {
+				continue
+			}
+			sps := model.StatusPageService{
+				ID:     id,
+				Name:   service.Name,
+				Status: ServiceSentinelShared.CurrentStatus(id),
+				Bars:   bars[id],
+			}
+			var up, down uint64
+			for _, b := range sps.Bars {
+				up += b.Up
+				down += b.Down
+			}
+			if up+down > 0 {
+				sps.Uptime = float32(up) / float32(up+down) * 100
+			}
+			item.Services = append(item.Services, sps)
+		}
+		for _, id := range s.ServerGroups {
+			g, ok := groups[id]
+			if !ok {
+				continue
+			}
+			spg := model.StatusPageServerGroup{ID: id, Name: g.Name}
+			for _, sid := range groupServers[id] {
+				server, ok := ServerShared.Get(sid)
+				if !ok {
+					continue
+				}
+				online := time.Since(server.LastActive) < server.OnlineTimeout(10*time.Second)
+				// 计划关机的服务器不计入总数
+				if server.ScheduledOff(time.Now(), online) {
+					spg.ScheduledOff++
+					continue
+				}
+				spg.Total++
+				if online {
+					spg.Online++
+				}
+			}
+			item.ServerGroups = append(item.ServerGroups, spg)
+		}
+		resp.Sections = append(resp.Sections, item)
+	}
+
+	incidents, err := ListIncidents(time.Now().AddDate(0, 0, -statusPageDays))
+	if err != nil {
+		return nil, err
+	}
+	// 游客仅能看到状态页中已展示的组件
+	for _, i := range incidents {
+		i.Services = slices.DeleteFunc(i.Services, func(id uint64) bool {
+			return !slices.Contains(serviceIDs, id)
+		})
+		i.ServerGroups = slices.DeleteFunc(i.ServerGroups, func(id uint64) bool {
+			return !slices.Contains(groupIDs, id)
+		})
+	}
+	resp.Incidents = incidents
+	return resp, nil
+}
+
+// loadUptimeBars 从监控历史中按天统计最近 90 天的可用性
+func loadUptimeBars(serviceIDs []uint64) (map[uint64][]model.StatusPageUptimeBar, error) {
+	ret := make(map[uint64][]model.StatusPageUptimeBar)
+	if len(serviceIDs) == 0 {
+		return ret, nil
+	}
+
+	now := time.Now().In(Loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, Loc)
+	from := today.AddDate(0, 0, -(statusPageDays - 1))
+
+	var mhs []model.ServiceHistory
+	if err := DB.Select("service_id, created_at, up, down").
+		Where("server_id = 0 AND service_id IN (?) AND created_at >= ?", serviceIDs, from).
+		Find(&mhs).Error; err != nil {
+		return nil, err
+	}
+
+	for _, id := range serviceIDs {
+		bars := make([]model.StatusPageUptimeBar, statusPageDays)
+		for i := range bars {
+			bars[i].Date = from.AddDate(0, 0, i)
+			bars[i].Uptime = -1
+		}
+		ret[id] = bars
+	}
+	for _, mh := range mhs {
+		bars, ok := ret[mh.ServiceID]
+		if !ok {
+			continue
+		}
+		t := mh.CreatedAt.In(Loc)
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, Loc)
+		i := int(math.Round(day.Sub(from).Hours() / 24))
+		if i < 0 || i >= statusPageDays {
+			continue
+		}
+		bars[i].Up += mh.Up
+		bars[i].Down += mh.Down
+	}
+	// 当天数据尚未全部落库，使用内存中的统计
+	for _, id := range serviceIDs {
+		if today, ok := ServiceSentinelShared.TodayStats(id); ok {
+			ret[id][statusPageDays-1].Up = today.Up
+			ret[id][statusPageDays-1].Down = today.Down
+		}
+	}
+	for _, bars := range ret {
+		for i := range bars {
+			if total := bars[i].Up + bars[i].Down; total > 0 {
+				bars[i].Uptime = float32(bars[i].Up) / float32(total) * 100
+			}
+		}
+	}
+	return ret, nil
+}
+
+// ListIncidents 获取指定时间之后创建或未解决的事件及其时间线
+func ListIncidents(since time.Time) ([]*model.Incident, error) {
+	var incidents []*model.Incident
+	if err := DB.Where("created_at >= ? OR resolved_at IS NULL", since).Order("created_at DESC").Find(&incidents).Error; err != nil {
+		return nil, err
+	}
+	if len(incidents) == 0 {
+		return incidents, nil
+	}
+
+	ids := make([]uint64, 0, len(incidents))
+	byID := make(map[uint64]*model.Incident, len(incidents))
+	for _, i := range incidents {
+		ids = append(ids, i.ID)
+		byID[i.ID] = i
+	}
+	var updates []model.IncidentUpdate
+	if err := DB.Where("incident_id IN (?)", ids).Order("created_at DESC").Find(&updates).Error; err != nil {
+		return nil, err
+	}
+	for _, u := range updates {
+		byID[u.IncidentID].Updates = append(byID[u.IncidentID].Updates, u)
+	}
+	return incidents, nil
+}
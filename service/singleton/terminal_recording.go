@@ -0,0 +1,227 @@
+package singleton
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/goccy/go-json"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+// 录像头部的终端尺寸，面板无法得知浏览器中终端的实际尺寸
+const (
+	terminalRecordingWidth  = 80
+	terminalRecordingHeight = 24
+)
+
+// TerminalSession 记录一次 Web 终端会话，结束时写入审计日志，启用录像时同时录制终端的输出
+type TerminalSession struct {
+	userID   uint64
+	ip       string
+	serverID uint64
+	start    time.Time
+
+	mu        sync.Mutex
+	closed    bool
+	rec       *model.TerminalRecording
+	f         *os.File
+	w         *bufio.Writer
+	maxSize   int64
+	pending   []byte // 被截断在两次输出之间的 UTF-8 字符
+	closeOnce sync.Once
+}
+
+// OpenTerminalSession 开始一次终端会话，录像文件创建失败时只记录审计日志
+func OpenTerminalSession(userID uint64, ip string, server *model.Server) *TerminalSession {
+	s := &TerminalSession{
+		userID:   userID,
+		ip:       ip,
+		serverID: server.ID,
+		start:    time.Now(),
+	}
+	if !Conf.TerminalRecording.Enabled {
+		return s
+	}
+
+	rec := &model.TerminalRecording{
+		CreatedAt:  s.start,
+		UserID:     userID,
+		ServerID:   server.ID,
+		ServerName: server.Name,
+	}
+	if err := s.openRecording(rec); err != nil {
+		log.Printf("NEZHA>> Failed to start terminal recording: %v", err)
+	}
+	return s
+}
+
+func (s *TerminalSession) openRecording(rec *model.TerminalRecording) error {
+	dir := terminalRecordingDir()
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return err
+	}
+	if err := DB.Create(rec).Error; err != nil {
+		return err
+	}
+	f, err := os.OpenFile(terminalRecordingFile(dir, rec.ID), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		DB.Delete(rec)
+		return err
+	}
+
+	s.rec = rec
+	s.f = f
+	s.w = bufio.NewWriter(f)
+	s.maxSize = Conf.TerminalRecording.MaxSize * 1024 * 1024
+
+	header, _ := json.Marshal(map[string]any{
+		"version":   2,
+		"width":     terminalRecordingWidth,
+		"height":    terminalRecordingHeight,
+		"timestamp": s.start.Unix(),
+		"title":     rec.ServerName,
+	})
+	s.writeLine(header)
+	return nil
+}
+
+// Recording 会话输出是否正在被录制
+func (s *TerminalSession) Recording() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rec != nil
+}
+
+// Write 录制终端的输出，录制失败不影响会话，始终返回成功
+func (s *TerminalSession) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.rec == nil || s.closed || s.rec.Truncated {
+		return len(p), nil
+	}
+
+	data := append(s.pending, p...)
+	cut := incompleteRuneStart(data)
+	s.pending = append(s.pending[:0:0], data[cut:]...)
+	if cut == 0 {
+		return len(p), nil
+	}
+
+	event, _ := json.Marshal([]any{time.Since(s.start).Seconds(), "o", string(data[:cut])})
+	if s.rec.Size+int64(len(event))+1 > s.maxSize {
+		s.rec.Truncated = true
+		return len(p), nil
+	}
+	s.writeLine(event)
+	return len(p), nil
+}
+
+func (s *TerminalSession) writeLine(b []byte) {
+	n, _ := s.w.Write(append(b, '\n'))
+	s.rec.Size += int64(n)
+}
+
+// Close 结束会话，保存录像并写入审计日志
+func (s *TerminalSession) Close() error {
+	s.closeOnce.Do(func() {
+		s.mu.Lock()
+		s.closed = true
+		duration := uint64(time.Since(s.start).Seconds())
+		var recordingID uint64
+		if s.rec != nil {
+			recordingID = s.rec.ID
+			if err := s.w.Flush(); err != nil {
+				log.Printf("NEZHA>> Failed to save terminal recording %d: %v", s.rec.ID, err)
+			}
+			s.f.Close()
+			s.rec.Duration = duration
+			DB.Model(s.rec).Select("duration", "size", "truncated").Updates(s.rec)
+		}
+		s.mu.Unlock()
+
+		summary := fmt.Sprintf("duration: %ds", duration)
+		if recordingID != 0 {
+			summary += fmt.Sprintf(", recording: %d", recordingID)
+		}
+		AuditLogShared.Record(&model.AuditLog{
+			CreatedAt:  time.Now(),
+			UserID:     s.userID,
+			IP:         s.ip,
+			Method:     "WS",
+			Route:      "/api/v1/ws/terminal/:id",
+			EntityType: "terminal",
+			EntityID:   strconv.FormatUint(s.serverID, 10),
+			Summary:    summary,
+			Status:     http.StatusOK,
+			Success:    true,
+		})
+	})
+	return nil
+}
+
+// incompleteRuneStart 返回末尾不完整的 UTF-8 字符的起始位置，没有时返回长度
+func incompleteRuneStart(b []byte) int {
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			if !utf8.FullRune(b[i:]) {
+				return i
+			}
+			break
+		}
+	}
+	return len(b)
+}
+
+func terminalRecordingDir() string {
+	if Conf.TerminalRecording.Dir != "" {
+		return Conf.TerminalRecording.Dir
+	}
+	return filepath.Join(filepath.Dir(dbPath), "terminal-recordings")
+}
+
+func terminalRecordingFile(dir string, id uint64) string {
+	return filepath.Join(dir, strconv.FormatUint(id, 10)+".cast")
+}
+
+// TerminalRecordingPath 返回录像文件的路径
+func TerminalRecordingPath(id uint64) (*model.TerminalRecording, string, error) {
+	var rec model.TerminalRecording
+	if err := DB.First(&rec, id).Error; err != nil {
+		return nil, "", Localizer.ErrorT("recording id %d does not exist", id)
+	}
+	return &rec, terminalRecordingFile(terminalRecordingDir(), id), nil
+}
+
+// DeleteTerminalRecordings 删除录像及其文件
+func DeleteTerminalRecordings(ids []uint64) error {
+	if err := DB.Unscoped().Delete(&model.TerminalRecording{}, "id in (?)", ids).Error; err != nil {
+		return err
+	}
+	dir := terminalRecordingDir()
+	for _, id := range ids {
+		if err := os.Remove(terminalRecordingFile(dir, id)); err != nil && !os.IsNotExist(err) {
+			log.Printf("NEZHA>> Failed to remove terminal recording %d: %v", id, err)
+		}
+	}
+	return nil
+}
+
+func cleanTerminalRecordings() {
+	var ids []uint64
+	if err := DB.Model(&model.TerminalRecording{}).Where("created_at < ?", time.Now().AddDate(0, 0, -Conf.TerminalRecording.RetentionDays)).
+		Pluck("id", &ids).Error; err != nil || len(ids) == 0 {
+		return
+	}
+	if err := DeleteTerminalRecordings(ids); err != nil {
+		log.Printf("NEZHA>> Failed to clean terminal recordings: %v", err)
+	}
+}
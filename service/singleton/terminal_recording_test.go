@@ -0,0 +1,34 @@
+package singleton
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+func TestTerminalSessionWrite(t *testing.T) {
+	var buf bytes.Buffer
+	s := &TerminalSession{
+		rec:     &model.TerminalRecording{},
+		w:       bufio.NewWriter(&buf),
+		maxSize: 100,
+	}
+
+	// 多字节字符被拆分在两次输出中
+	s.Write([]byte("a\xe4\xbd"))
+	s.Write([]byte("\xa0b"))
+	s.Write([]byte(strings.Repeat("x", 100)))
+	s.Write([]byte("c"))
+	s.w.Flush()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.HasSuffix(lines[0], `"o","a"]`) || !strings.HasSuffix(lines[1], `"o","你b"]`) {
+		t.Fatalf("unexpected events: %q", lines)
+	}
+	if !s.rec.Truncated || s.rec.Size != int64(buf.Len()) {
+		t.Fatalf("expected recording to be truncated at %d bytes, got %+v", buf.Len(), s.rec)
+	}
+}
@@ -0,0 +1,260 @@
+package singleton
+
+import (
+	"log"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+// interfaceTransferTracker 记录各服务器网卡计数器，计算两次上报之间的增量
+type interfaceTransferTracker struct {
+	mu      sync.Mutex
+	last    map[uint64]map[string]model.NetInterfaceTransfer
+	pending map[uint64]map[string]model.NetInterfaceTransfer
+}
+
+var interfaceTransfer = &interfaceTransferTracker{
+	last:    make(map[uint64]map[string]model.NetInterfaceTransfer),
+	pending: make(map[uint64]map[string]model.NetInterfaceTransfer),
+}
+
+// RecordInterfaceTransfer 记录 Agent 上报的网卡累计流量
+func RecordInterfaceTransfer(serverID uint64, counters []model.NetInterfaceTransfer) {
+	interfaceTransfer.mu.Lock()
+	defer interfaceTransfer.mu.Unlock()
+
+	last, ok := interfaceTransfer.last[serverID]
+	if !ok {
+		last = make(map[string]model.NetInterfaceTransfer)
+		interfaceTransfer.last[serverID] = last
+	}
+	pending, ok := interfaceTransfer.pending[serverID]
+	if !ok {
+		pending = make(map[string]model.NetInterfaceTransfer)
+		interfaceTransfer.pending[serverID] = pending
+	}
+
+	for _, cur := range counters {
+		prev, seen := last[cur.Name]
+		last[cur.Name] = cur
+		// 首次上报仅打点，避免把开机以来的累计值算作当天流量
+		if !seen {
+			continue
+		}
+		p := pending[cur.Name]
+		p.Name = cur.Name
+		p.In += transferDelta(prev.In, cur.In)
+		p.Out += transferDelta(prev.Out, cur.Out)
+		pending[cur.Name] = p
+	}
+}
+
+// ResetInterfaceTransfer 服务器重启后清除网卡计数器快照
+func ResetInterfaceTransfer(serverID uint64) {
+	interfaceTransfer.mu.Lock()
+	defer interfaceTransfer.mu.Unlock()
+	delete(interfaceTransfer.last, serverID)
+}
+
+func (t *interfaceTransferTracker) flush(serverIDs ...uint64) map[uint64]map[string]model.NetInterfaceTransfer {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(serverIDs) == 0 {
+		serverIDs = slices.Collect(maps.Keys(t.pending))
+	}
+	ret := make(map[uint64]map[string]model.NetInterfaceTransfer)
+	for _, id := range serverIDs {
+		if p, ok := t.pending[id]; ok && len(p) > 0 {
+			ret[id] = p
+			delete(t.pending, id)
+		}
+	}
+	return ret
+}
+
+// transferDelta 计数器变小说明发生了重置（重启或溢出），此时当前值即为增量
+func transferDelta(prev, cur uint64) uint64 {
+	if cur < prev {
+		return cur
+	}
+	return cur - prev
+}
+
+func transferDay(t time.Time) time.Time {
+	t = t.In(Loc)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, Loc)
+}
+
+func saveTransferDaily(db *gorm.DB, rows []model.TransferDaily) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	return db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "server_id"}, {Name: "date"}, {Name: "interface"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"in":  gorm.Expr("transfer_dailies.`in` + excluded.`in`"),
+			"out": gorm.Expr("transfer_dailies.`out` + excluded.`out`"),
+		}),
+	}).Create(&rows).Error
+}
+
+// recordTransferDaily 将本次打点的流量累加至每日汇总
+func recordTransferDaily(at time.Time, txs []model.Transfer, serverIDs ...uint64) {
+	day := transferDay(at)
+	var rows []model.TransferDaily
+	for _, tx := range txs {
+		rows = append(rows, model.TransferDaily{
+			ServerID: tx.ServerID,
+			Date:     day,
+			In:       tx.In,
+			Out:      tx.Out,
+		})
+	}
+	for id, ifaces := range interfaceTransfer.flush(serverIDs...) {
+		for name, v := range ifaces {
+			if v.In == 0 && v.Out == 0 {
+				continue
+			}
+			rows = append(rows, model.TransferDaily{
+				ServerID:  id,
+				Date:      day,
+				Interface: name,
+				In:        v.In,
+				Out:       v.Out,
+			})
+		}
+	}
+	if err := saveTransferDaily(DB, rows); err != nil {
+		log.Printf("NEZHA>> Save daily traffic failed: %v", err)
+	}
+}
+
+// backfillTransferDaily 每日汇总表为空时，从本月已有的小时流量记录中回填
+func backfillTransferDaily() error {
+	var count int64
+	if err := DB.Model(&model.TransferDaily{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	now := time.Now().In(Loc)
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, Loc)
+
+	var txs []model.Transfer
+	if err := DB.Where("created_at >= ?", monthStart).Find(&txs).Error; err != nil {
+		return err
+	}
+
+	type key struct {
+		serverID uint64
+		day      int64
+	}
+	sum := make(map[key]*model.TransferDaily)
+	for _, tx := range txs {
+		day := transferDay(tx.CreatedAt)
+		k := key{tx.ServerID, day.Unix()}
+		d, ok := sum[k]
+		if !ok {
+			d = &model.TransferDaily{ServerID: tx.ServerID, Date: day}
+			sum[k] = d
+		}
+		d.In += tx.In
+		d.Out += tx.Out
+	}
+
+	rows := make([]model.TransferDaily, 0, len(sum))
+	for _, d := range sum {
+		rows = append(rows, *d)
+	}
+	if len(rows) > 0 {
+		log.Printf("NEZHA>> Backfilled %d daily traffic record(s) from hourly usage", len(rows))
+	}
+	return saveTransferDaily(DB, rows)
+}
+
+// GetServerTraffic 获取服务器在时间范围内的流量统计
+func GetServerTraffic(serverID uint64, from, to time.Time, granularity string) (*model.ServerTrafficResponse, error) {
+	var rows []model.TransferDaily
+	if err := DB.Where("server_id = ? AND date >= ? AND date <= ?", serverID, transferDay(from), to).
+		Order("date").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	bucket := func(t time.Time) time.Time {
+		t = t.In(Loc)
+		if granularity == model.TrafficGranularityMonthly {
+			return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, Loc)
+		}
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, Loc)
+	}
+	appendPoint := func(points []model.TrafficDataPoint, r model.TransferDaily) []model.TrafficDataPoint {
+		date := bucket(r.Date)
+		if n := len(points); n > 0 && points[n-1].Date.Equal(date) {
+			points[n-1].In += r.In
+			points[n-1].Out += r.Out
+			return points
+		}
+		return append(points, model.TrafficDataPoint{Date: date, In: r.In, Out: r.Out})
+	}
+
+	resp := &model.ServerTrafficResponse{
+		ServerID:    serverID,
+		Granularity: granularity,
+		Total:       []model.TrafficDataPoint{},
+	}
+	for _, r := range rows {
+		if r.Interface == "" {
+			resp.Total = appendPoint(resp.Total, r)
+			continue
+		}
+		if resp.Interfaces == nil {
+			resp.Interfaces = make(map[string][]model.TrafficDataPoint)
+		}
+		resp.Interfaces[r.Interface] = appendPoint(resp.Interfaces[r.Interface], r)
+	}
+	return resp, nil
+}
+
+// ObserveTransfer 累加 Agent 上报的累计流量。面板重启后首次上报时以持久化的基准计算停机期间的流量，
+// 超出线路速率的增量按上限截断并记录异常事件
+func ObserveTransfer(server *model.Server, in, out uint64, at time.Time) {
+	t := server.Transfer
+	if !t.Started() {
+		var b model.TransferBaseline
+		if err := DB.Where("server_id = ?", server.ID).Limit(1).Find(&b).Error; err == nil && b.ServerID != 0 {
+			t.Restore(b, server.Host.BootTime)
+		}
+	}
+
+	var maxRate uint64
+	if Conf.TransferMaxRate > 0 {
+		maxRate = uint64(Conf.TransferMaxRate) * 1000 * 1000 / 8
+	}
+	for _, a := range t.Observe(in, out, at, maxRate) {
+		log.Printf("NEZHA>> Server %d reported an improbable %s transfer of %d bytes, clamped to %d", server.ID, a.Direction, a.Delta, a.Limit)
+		RecordServerEvent(server.ID, model.ServerEventTransferAnomaly, model.ServerEventActorAgent, 0,
+			model.ServerEventChange{Field: "transfer_" + a.Direction, Old: a.Delta, New: a.Limit})
+	}
+}
+
+func saveTransferBaselines(rows []model.TransferBaseline) {
+	if len(rows) == 0 {
+		return
+	}
+	if err := DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "server_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"boot_time", "in", "out", "updated_at"}),
+	}).Create(&rows).Error; err != nil {
+		log.Printf("NEZHA>> Save traffic baselines failed: %v", err)
+	}
+}
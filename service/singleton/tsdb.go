@@ -0,0 +1,154 @@
+package singleton
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+// TimeSeriesStore 时序数据的存储，保存各监测点上报的监控记录（server_id 不为 0）
+// server_id 为 0 的记录用于可用性统计，始终保存在主数据库中
+type TimeSeriesStore interface {
+	WriteServiceHistory(rows []*model.ServiceHistory) error
+	// ServerServiceHistory 查询服务器在时间范围内的监控记录，按服务与时间排序
+	ServerServiceHistory(serverID uint64, from, to time.Time) ([]*model.ServiceHistory, error)
+	// ServerIDs 返回有监控记录的服务器
+	ServerIDs() ([]uint64, error)
+	// DeleteServiceHistory 删除服务器或服务在 before 之前的监控记录，ID 为 0 时不按该字段过滤
+	DeleteServiceHistory(serverID, serviceID uint64, before time.Time) (int64, error)
+}
+
+// historyRollupStore 外部时序数据库中超出原始记录保留期的记录汇总为小时数据后写入主数据库
+type historyRollupStore interface {
+	HourlyServiceHistory(before time.Time) ([]model.ServiceHistoryRollup, error)
+}
+
+var TSDBShared TimeSeriesStore = gormTimeSeriesStore{}
+
+// InitTSDB 根据配置初始化时序数据存储，未配置时使用主数据库
+func InitTSDB() error {
+	switch Conf.TSDB.Type {
+	case "":
+		TSDBShared = gormTimeSeriesStore{}
+	case model.TSDBTypeClickHouse:
+		store, err := newClickHouseStore(&Conf.TSDB)
+		if err != nil {
+			return fmt.Errorf("init clickhouse: %w", err)
+		}
+		TSDBShared = store
+	default:
+		return fmt.Errorf("unsupported tsdb type: %s", Conf.TSDB.Type)
+	}
+	return nil
+}
+
+func usingExternalTSDB() bool {
+	_, ok := TSDBShared.(gormTimeSeriesStore)
+	return !ok
+}
+
+// writeServiceHistory 写入监控记录，返回写入失败的记录
+func writeServiceHistory(rows []*model.ServiceHistory) ([]*model.ServiceHistory, error) {
+	if !usingExternalTSDB() {
+		if err := TSDBShared.WriteServiceHistory(rows); err != nil {
+			return rows, err
+		}
+		return nil, nil
+	}
+
+	var local, remote []*model.ServiceHistory
+	for _, r := range rows {
+		if r.ServerID == 0 {
+			local = append(local, r)
+		} else {
+			remote = append(remote, r)
+		}
+	}
+
+	var failed []*model.ServiceHistory
+	var errs []error
+	if len(local) > 0 {
+		if err := (gormTimeSeriesStore{}).WriteServiceHistory(local); err != nil {
+			failed = append(failed, local...)
+			errs = append(errs, err)
+		}
+	}
+	if len(remote) > 0 {
+		if err := TSDBShared.WriteServiceHistory(remote); err != nil {
+			failed = append(failed, remote...)
+			errs = append(errs, err)
+		}
+	}
+	return failed, errors.Join(errs...)
+}
+
+type gormTimeSeriesStore struct{}
+
+func (gormTimeSeriesStore) WriteServiceHistory(rows []*model.ServiceHistory) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		return tx.CreateInBatches(rows, historyInsertBatch).Error
+	})
+}
+
+func (gormTimeSeriesStore) ServerServiceHistory(serverID uint64, from, to time.Time) ([]*model.ServiceHistory, error) {
+	var rows []*model.ServiceHistory
+	err := DB.Model(&model.ServiceHistory{}).Select("service_id, created_at, server_id, avg_delay, loss, jitter, up, down").
+		Where("server_id = ? AND created_at >= ? AND created_at < ?", serverID, from, to).Order("service_id, created_at").
+		Scan(&rows).Error
+	return rows, err
+}
+
+func (gormTimeSeriesStore) ServerIDs() ([]uint64, error) {
+	var ids []uint64
+	err := DB.Model(&model.ServiceHistory{}).Select("distinct(server_id)").Where("server_id != 0").Find(&ids).Error
+	return ids, err
+}
+
+func (gormTimeSeriesStore) DeleteServiceHistory(serverID, serviceID uint64, before time.Time) (int64, error) {
+	query, args := serviceHistoryFilter(serverID, serviceID, before)
+	return deleteInBatches(&model.ServiceHistory{}, query, args...)
+}
+
+// serviceHistoryFilter 返回按服务器、服务与时间过滤监控记录的条件
+func serviceHistoryFilter(serverID, serviceID uint64, before time.Time) (string, []any) {
+	query, args := "created_at < ?", []any{before}
+	if serverID != 0 {
+		query += " AND server_id = ?"
+		args = append(args, serverID)
+	}
+	if serviceID != 0 {
+		query += " AND service_id = ?"
+		args = append(args, serviceID)
+	}
+	return query, args
+}
+
+// MigrateServiceHistoryToTSDB 将主数据库中各监测点的监控记录复制到已配置的时序数据库，不删除原记录
+func MigrateServiceHistoryToTSDB() (int64, error) {
+	if !usingExternalTSDB() {
+		return 0, errors.New("no external tsdb is configured")
+	}
+
+	var total int64
+	var cursor uint64
+	for {
+		var rows []*model.ServiceHistory
+		if err := DB.Where("server_id != 0 AND id > ?", cursor).Order("id").Limit(retentionBatchSize).Find(&rows).Error; err != nil {
+			return total, err
+		}
+		if len(rows) == 0 {
+			return total, nil
+		}
+		if err := TSDBShared.WriteServiceHistory(rows); err != nil {
+			return total, err
+		}
+		cursor = rows[len(rows)-1].ID
+		total += int64(len(rows))
+		log.Printf("NEZHA>> Copied %d service history row(s) to tsdb", total)
+	}
+}
@@ -2,6 +2,7 @@ package singleton
 
 import (
 	"fmt"
+	"slices"
 	"sync"
 
 	"github.com/nezhahq/nezha/model"
@@ -12,6 +13,7 @@ import (
 var (
 	UserInfoMap         map[uint64]model.UserInfo
 	AgentSecretToUserId map[string]uint64
+	PublicSlugToUserId  map[string]uint64
 
 	UserLock sync.RWMutex
 )
@@ -19,6 +21,7 @@ var (
 func initUser() {
 	UserInfoMap = make(map[uint64]model.UserInfo)
 	AgentSecretToUserId = make(map[string]uint64)
+	PublicSlugToUserId = make(map[string]uint64)
 
 	var users []model.User
 	DB.Find(&users)
@@ -41,24 +44,61 @@ func initUser() {
 		UserInfoMap[u.ID] = model.UserInfo{
 			Role:        u.Role,
 			AgentSecret: u.AgentSecret,
+			PublicSlug:  u.PublicSlug,
 		}
 		AgentSecretToUserId[u.AgentSecret] = u.ID
+		if u.PublicSlug != "" {
+			PublicSlugToUserId[u.PublicSlug] = u.ID
+		}
 	}
 }
 
 func OnUserUpdate(u *model.User) {
-	UserLock.Lock()
-	defer UserLock.Unlock()
-
 	if u == nil {
 		return
 	}
+	defer ClusterShared.PublishChange("user")
+	APITokenShared.forgetUser(u.ID)
 
+	UserLock.Lock()
+	defer UserLock.Unlock()
+
+	if old := UserInfoMap[u.ID].PublicSlug; old != "" {
+		delete(PublicSlugToUserId, old)
+	}
 	UserInfoMap[u.ID] = model.UserInfo{
 		Role:        u.Role,
 		AgentSecret: u.AgentSecret,
+		PublicSlug:  u.PublicSlug,
 	}
 	AgentSecretToUserId[u.AgentSecret] = u.ID
+	if u.PublicSlug != "" {
+		PublicSlugToUserId[u.PublicSlug] = u.ID
+	}
+}
+
+// UserIDByPublicSlug 根据访客页面的地址标识查找用户
+func UserIDByPublicSlug(slug string) (uint64, bool) {
+	UserLock.RLock()
+	defer UserLock.RUnlock()
+
+	uid, ok := PublicSlugToUserId[slug]
+	return uid, ok
+}
+
+// AdminTenantScope 所有管理员的资源，严格租户模式下未指定地址标识的访客页面只展示这些资源
+func AdminTenantScope() model.TenantScope {
+	UserLock.RLock()
+	defer UserLock.RUnlock()
+
+	var scope model.TenantScope
+	for uid, info := range UserInfoMap {
+		if info.Role == model.RoleAdmin {
+			scope = append(scope, uid)
+		}
+	}
+	slices.Sort(scope)
+	return scope
 }
 
 func OnUserDelete(id []uint64, errorFunc func(string, ...any) error) error {
@@ -104,6 +144,12 @@ func OnUserDelete(id []uint64, errorFunc func(string, ...any) error) error {
 			if err := tx.Where("id IN (?)", id).Delete(&model.User{}).Error; err != nil {
 				return err
 			}
+			if err := tx.Unscoped().Delete(&model.APIToken{}, "user_id = ?", uid).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Delete(&model.AccessGrant{}, "target_user_id = ?", uid).Error; err != nil {
+				return err
+			}
 			return nil
 		})
 
@@ -127,12 +173,20 @@ func OnUserDelete(id []uint64, errorFunc func(string, ...any) error) error {
 				}
 			}
 			AlertsLock.Unlock()
+			RecordServerDeletion(servers, model.ServerEventActorSystem, 0)
 			ServerShared.Delete(servers)
 		}
 
+		APITokenShared.forgetUser(uid)
 		secret := UserInfoMap[uid].AgentSecret
 		delete(AgentSecretToUserId, secret)
+		delete(PublicSlugToUserId, UserInfoMap[uid].PublicSlug)
 		delete(UserInfoMap, uid)
 	}
+	AccessGrantShared.Reload()
+	// 其他节点同时移除用户的服务器与计划任务，服务器由 ServerShared.Delete 通知
+	for _, entity := range []string{"user", "user/tokens", "cron", "access-grant"} {
+		ClusterShared.PublishChange(entity)
+	}
 	return nil
 }
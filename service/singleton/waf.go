@@ -0,0 +1,218 @@
+package singleton
+
+import (
+	"cmp"
+	"fmt"
+	"log"
+	"maps"
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/geoip"
+)
+
+const (
+	// 封禁通知的聚合窗口，窗口内的后续封禁合并为一条消息
+	wafNotifyWindow = time.Minute
+	// 聚合消息与每日汇总中最多列出的条目数
+	wafNotifyMaxItems = 10
+)
+
+type wafBlockEvent struct {
+	ip     string
+	reason uint8
+}
+
+var wafNotify struct {
+	mu      sync.Mutex
+	window  bool
+	pending []wafBlockEvent
+}
+
+// applyWAFBlockPolicy 按配置设置自动封禁的时长
+func applyWAFBlockPolicy() {
+	policy := model.WAFBlockPolicy{
+		Base: make(map[uint8]uint64),
+		Max:  Conf.WAFBlockMaxDuration,
+	}
+	for name, seconds := range Conf.WAFBlockDuration {
+		reason, ok := model.WAFBlockReasons[name]
+		if !ok {
+			log.Printf("NEZHA>> Unknown WAF block reason in config: %s", name)
+			continue
+		}
+		policy.Base[reason] = seconds
+	}
+	model.BlockPolicy = policy
+}
+
+// CleanWAFBlocks 删除已过期且超出违规记录保留时间的自动封禁与手动封禁
+func CleanWAFBlocks() {
+	now := time.Now()
+	if err := DB.Unscoped().Delete(&model.WAF{}, "expires_at <> 0 AND expires_at < ?",
+		uint64(now.Unix())-min(Conf.WAFOffenseRetention, uint64(now.Unix()))).Error; err != nil {
+		log.Printf("NEZHA>> Failed to clean WAF blocks: %v", err)
+	}
+	DB.Delete(&model.WAFBlock{}, "expires_at IS NOT NULL AND expires_at < ?", now)
+}
+
+// onIPBlocked 窗口外的封禁立即通知并开始新窗口，窗口内的封禁在窗口结束时合并发送
+func onIPBlocked(ip string, reason uint8) {
+	if Conf.WAFNotificationGroupID == 0 || !wafNotifyReason(reason) {
+		return
+	}
+	e := wafBlockEvent{ip: ip, reason: reason}
+
+	wafNotify.mu.Lock()
+	defer wafNotify.mu.Unlock()
+	if wafNotify.window {
+		wafNotify.pending = append(wafNotify.pending, e)
+		return
+	}
+	wafNotify.window = true
+	time.AfterFunc(wafNotifyWindow, flushWAFNotify)
+	go sendWAFNotification([]wafBlockEvent{e})
+}
+
+func flushWAFNotify() {
+	wafNotify.mu.Lock()
+	defer wafNotify.mu.Unlock()
+	if len(wafNotify.pending) == 0 {
+		wafNotify.window = false
+		return
+	}
+	events := wafNotify.pending
+	wafNotify.pending = nil
+	time.AfterFunc(wafNotifyWindow, flushWAFNotify)
+	go sendWAFNotification(events)
+}
+
+// wafNotifyReason 手动封禁不通知，未配置原因时通知所有自动封禁
+func wafNotifyReason(reason uint8) bool {
+	if reason == model.WAFBlockReasonTypeManual {
+		return false
+	}
+	if len(Conf.WAFNotifyReasons) == 0 {
+		return true
+	}
+	return slices.Contains(Conf.WAFNotifyReasons, model.WAFBlockReasonName(reason))
+}
+
+// wafBlockSurface 被封禁的请求来源，Agent 认证失败来自 gRPC，其余来自 HTTP
+func wafBlockSurface(reason uint8) string {
+	if reason == model.WAFBlockReasonTypeAgentAuthFail {
+		return "gRPC"
+	}
+	return "HTTP"
+}
+
+func sendWAFNotification(events []wafBlockEvent) {
+	t := NotificationShared.Lang(Conf.WAFNotificationGroupID)
+	var msg string
+	if len(events) == 1 {
+		e := events[0]
+		msg = fmt.Sprintf("[%s] %s, %s: %s, %s: %s, %s: %s",
+			t.T("IP Blocked"), IPDesensitize(e.ip),
+			t.T("Reason"), model.WAFBlockReasonName(e.reason),
+			t.T("Surface"), wafBlockSurface(e.reason),
+			t.T("Country"), wafCountry(e.ip))
+	} else {
+		reasons := make(map[string]int)
+		for _, e := range events {
+			reasons[model.WAFBlockReasonName(e.reason)]++
+		}
+		var b strings.Builder
+		fmt.Fprintf(&b, "[%s] %s", t.T("IP Blocked"),
+			t.Tf("%d IPs were blocked in the last minute", len(events)))
+		b.WriteString("\n" + formatWAFCounts(reasons))
+		for _, e := range events[:min(len(events), wafNotifyMaxItems)] {
+			fmt.Fprintf(&b, "\n%s (%s, %s, %s)", IPDesensitize(e.ip),
+				model.WAFBlockReasonName(e.reason), wafBlockSurface(e.reason), wafCountry(e.ip))
+		}
+		if len(events) > wafNotifyMaxItems {
+			b.WriteString("\n...")
+		}
+		msg = b.String()
+	}
+	NotificationShared.SendNotification(Conf.WAFNotificationGroupID, msg, "")
+}
+
+func wafCountry(ip string) string {
+	addr := net.ParseIP(ip)
+	if country, ok := geoip.LookupCached(addr); ok {
+		return strings.ToUpper(country)
+	}
+	if country, err := geoip.Lookup(addr); err == nil {
+		return strings.ToUpper(country)
+	}
+	return "-"
+}
+
+// formatWAFCounts 按数量从大到小输出 名称: 数量
+func formatWAFCounts(counts map[string]int) string {
+	keys := slices.SortedFunc(maps.Keys(counts), func(a, b string) int {
+		return cmp.Or(cmp.Compare(counts[b], counts[a]), cmp.Compare(a, b))
+	})
+	items := make([]string, 0, len(keys))
+	for _, k := range keys[:min(len(keys), wafNotifyMaxItems)] {
+		items = append(items, fmt.Sprintf("%s: %d", k, counts[k]))
+	}
+	return strings.Join(items, ", ")
+}
+
+// wafNetwork 将 IP 归入所在的网段，IPv4 为 /24，IPv6 为 /48
+func wafNetwork(ip []byte) string {
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return ""
+	}
+	addr = addr.Unmap()
+	bits := 48
+	if addr.Is4() {
+		bits = 24
+	}
+	prefix, _ := addr.Prefix(bits)
+	return prefix.String()
+}
+
+// SendWAFDailySummary 汇总过去 24 小时内的自动封禁，按原因与网段统计
+func SendWAFDailySummary() {
+	if !Conf.WAFDailySummary || Conf.WAFNotificationGroupID == 0 {
+		return
+	}
+	var blocks []model.WAF
+	if err := DB.Where("block_timestamp >= ? AND block_reason <> ?",
+		time.Now().Add(-24*time.Hour).Unix(), model.WAFBlockReasonTypeManual).Find(&blocks).Error; err != nil {
+		log.Printf("NEZHA>> Failed to summarize WAF blocks: %v", err)
+		return
+	}
+
+	reasons := make(map[string]int)
+	networks := make(map[string]int)
+	var total int
+	for _, b := range blocks {
+		if !wafNotifyReason(b.BlockReason) {
+			continue
+		}
+		total++
+		reasons[model.WAFBlockReasonName(b.BlockReason)]++
+		if n := wafNetwork(b.IP); n != "" {
+			networks[n]++
+		}
+	}
+	if total == 0 {
+		return
+	}
+
+	t := NotificationShared.Lang(Conf.WAFNotificationGroupID)
+	msg := fmt.Sprintf("[%s] %s\n%s\n%s: %s", t.T("WAF Daily Summary"),
+		t.Tf("%d IPs were blocked in the last 24 hours", total),
+		formatWAFCounts(reasons),
+		t.T("Top networks"), formatWAFCounts(networks))
+	NotificationShared.SendNotification(Conf.WAFNotificationGroupID, msg, "")
+}
@@ -0,0 +1,241 @@
+package singleton
+
+import (
+	"errors"
+	"log"
+	"maps"
+	"net"
+	"net/netip"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/geoip"
+	"github.com/nezhahq/nezha/pkg/utils"
+)
+
+const wafCountryQueueSize = 256
+
+var errWAFRuleBlocked = errors.New("you were blocked by nezha WAF")
+
+// wafRuleSnapshot 启用的规则及其索引，规则变化时整体替换
+type wafRuleSnapshot struct {
+	rules     map[uint64]*model.WAFRule
+	ranges    *model.IPRangeSet
+	countries map[string][]uint64
+
+	// 按入口记录是否存在 allow 规则、国家规则与按国家放行的规则
+	hasAllow        [model.WAFSurfaceGRPC + 1]bool
+	hasCountry      [model.WAFSurfaceGRPC + 1]bool
+	hasCountryAllow [model.WAFSurfaceGRPC + 1]bool
+}
+
+// WAFRuleClass 按 CIDR 与国家规则检查请求来源，规则在内存中建立区间索引，检查时不查询数据库
+type WAFRuleClass struct {
+	snapshot atomic.Pointer[wafRuleSnapshot]
+
+	hitsMu sync.Mutex
+	hits   map[uint64]uint64 // 尚未写入数据库的命中次数
+
+	resolving sync.Map // 正在查询国家的地址
+	queue     chan netip.Addr
+}
+
+var WAFRuleShared *WAFRuleClass
+
+func NewWAFRuleClass() *WAFRuleClass {
+	c := &WAFRuleClass{
+		hits:  make(map[uint64]uint64),
+		queue: make(chan netip.Addr, wafCountryQueueSize),
+	}
+	c.Reload()
+	go c.resolver()
+	go func() {
+		for range time.Tick(time.Minute) {
+			c.flushHits()
+		}
+	}()
+	return c
+}
+
+// Reload 从数据库重新加载规则并重建索引
+func (c *WAFRuleClass) Reload() {
+	var rules []*model.WAFRule
+	if err := DB.Where("enabled = ?", true).Find(&rules).Error; err != nil {
+		log.Printf("NEZHA>> Failed to load WAF rules: %v", err)
+		return
+	}
+	c.snapshot.Store(newWAFRuleSnapshot(rules))
+}
+
+func newWAFRuleSnapshot(rules []*model.WAFRule) *wafRuleSnapshot {
+	snap := &wafRuleSnapshot{
+		rules:     make(map[uint64]*model.WAFRule, len(rules)),
+		countries: make(map[string][]uint64),
+	}
+	var b model.IPRangeSetBuilder
+	for _, r := range rules {
+		snap.rules[r.ID] = r
+		for _, cidr := range r.CIDRs {
+			prefix, err := netip.ParsePrefix(cidr)
+			if err != nil {
+				continue
+			}
+			b.Add(prefix, r.ID)
+		}
+		for _, country := range r.Countries {
+			snap.countries[country] = append(snap.countries[country], r.ID)
+		}
+		for _, surface := range []uint8{model.WAFSurfaceHTTP, model.WAFSurfaceGRPC} {
+			if r.AppliesTo(surface) {
+				snap.hasAllow[surface] = snap.hasAllow[surface] || r.Action == model.WAFRuleAllow
+				snap.hasCountry[surface] = snap.hasCountry[surface] || len(r.Countries) > 0
+				snap.hasCountryAllow[surface] = snap.hasCountryAllow[surface] || (r.Action == model.WAFRuleAllow && len(r.Countries) > 0)
+			}
+		}
+	}
+	snap.ranges = b.Build()
+	return snap
+}
+
+// Check 检查来源地址能否访问该入口
+func (c *WAFRuleClass) Check(ip string, surface uint8) error {
+	return c.check(c.snapshot.Load(), ip, surface, true)
+}
+
+// PreviewCheck 检查修改规则后来源地址能否访问该入口，用于防止管理员修改规则后无法访问面板。
+// change 修改当前启用的规则，键为规则 ID
+func (c *WAFRuleClass) PreviewCheck(ip string, surface uint8, change func(rules map[uint64]*model.WAFRule)) error {
+	rules := make(map[uint64]*model.WAFRule)
+	if snap := c.snapshot.Load(); snap != nil {
+		maps.Copy(rules, snap.rules)
+	}
+	change(rules)
+	list := slices.Collect(maps.Values(rules))
+	list = slices.DeleteFunc(list, func(r *model.WAFRule) bool { return !r.Enabled })
+	return c.check(newWAFRuleSnapshot(list), ip, surface, false)
+}
+
+func (c *WAFRuleClass) check(snap *wafRuleSnapshot, ip string, surface uint8, record bool) error {
+	if snap == nil || len(snap.rules) == 0 {
+		return nil
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return nil
+	}
+	addr = addr.Unmap()
+	hasAllow, hasCountry := snap.hasAllow[surface], snap.hasCountry[surface]
+
+	matched := snap.ranges.Lookup(addr)
+	resolved := false
+	if hasCountry {
+		var country string
+		if country, resolved = c.country(addr); resolved {
+			matched = append(matched[:len(matched):len(matched)], snap.countries[country]...)
+		}
+	}
+
+	var allowHit, denyHit uint64
+	for _, id := range matched {
+		r := snap.rules[id]
+		if r == nil || !r.AppliesTo(surface) {
+			continue
+		}
+		if r.Action == model.WAFRuleAllow {
+			allowHit = id
+			break
+		}
+		if denyHit == 0 {
+			denyHit = id
+		}
+	}
+
+	switch {
+	case allowHit != 0:
+		if record {
+			c.hit(allowHit)
+		}
+		return nil
+	case denyHit != 0:
+		if record {
+			c.hit(denyHit)
+		}
+		return errWAFRuleBlocked
+	case hasCountry && !resolved && (!hasAllow || snap.hasCountryAllow[surface]):
+		// 只有国家规则可能改变结果时才按无法确定国家处理，不会绕过按 CIDR 放行的规则
+		if unresolvedWAFAction(hasAllow) == model.WAFRuleDeny {
+			return errWAFRuleBlocked
+		}
+		return nil
+	case hasAllow:
+		return errWAFRuleBlocked
+	}
+	return nil
+}
+
+// unresolvedWAFAction 无法确定国家时的动作，未配置时存在 allow 规则则拦截，否则放行
+func unresolvedWAFAction(hasAllow bool) string {
+	if Conf.WAFUnresolvedAction != "" {
+		return Conf.WAFUnresolvedAction
+	}
+	return utils.IfOr(hasAllow, model.WAFRuleDeny, model.WAFRuleAllow)
+}
+
+// country 从缓存中查询地址所属的国家，未缓存时在后台查询，本次视为无法确定
+func (c *WAFRuleClass) country(addr netip.Addr) (string, bool) {
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return "", false
+	}
+	if country, ok := geoip.LookupCached(net.IP(addr.AsSlice())); ok {
+		return country, true
+	}
+	if _, loaded := c.resolving.LoadOrStore(addr, struct{}{}); !loaded {
+		select {
+		case c.queue <- addr:
+		default:
+			c.resolving.Delete(addr)
+		}
+	}
+	return "", false
+}
+
+func (c *WAFRuleClass) resolver() {
+	for addr := range c.queue {
+		if _, err := geoip.Lookup(net.IP(addr.AsSlice())); err != nil && Conf.Debug {
+			log.Printf("NEZHA>> Failed to resolve country of %s: %v", addr, err)
+		}
+		c.resolving.Delete(addr)
+	}
+}
+
+func (c *WAFRuleClass) hit(id uint64) {
+	c.hitsMu.Lock()
+	c.hits[id]++
+	c.hitsMu.Unlock()
+}
+
+// Hits 返回尚未写入数据库的命中次数
+func (c *WAFRuleClass) Hits(id uint64) uint64 {
+	c.hitsMu.Lock()
+	defer c.hitsMu.Unlock()
+	return c.hits[id]
+}
+
+func (c *WAFRuleClass) flushHits() {
+	c.hitsMu.Lock()
+	hits := c.hits
+	c.hits = make(map[uint64]uint64)
+	c.hitsMu.Unlock()
+
+	for id, n := range hits {
+		if err := DB.Model(&model.WAFRule{}).Where("id = ?", id).
+			UpdateColumn("hits", gorm.Expr("hits + ?", n)).Error; err != nil {
+			log.Printf("NEZHA>> Failed to save WAF rule hits: %v", err)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package singleton
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+func TestWAFRuleUnresolvedCountry(t *testing.T) {
+	old := Conf
+	Conf = &ConfigClass{Config: &model.Config{}}
+	t.Cleanup(func() { Conf = old })
+
+	c := &WAFRuleClass{hits: make(map[uint64]uint64), queue: make(chan netip.Addr, 1)}
+	check := func(rules ...*model.WAFRule) error {
+		for i, r := range rules {
+			r.ID, r.Enabled = uint64(i+1), true
+		}
+		return c.check(newWAFRuleSnapshot(rules), "10.0.0.1", model.WAFSurfaceHTTP, false)
+	}
+
+	// 内网地址无法确定国家，按国家拦截的规则不能绕过按 CIDR 放行的规则
+	if check(
+		&model.WAFRule{Action: model.WAFRuleAllow, CIDRs: []string{"192.0.2.0/24"}},
+		&model.WAFRule{Action: model.WAFRuleDeny, Countries: []string{"cn"}},
+	) == nil {
+		t.Fatal("expected address outside the allow list to be blocked")
+	}
+	if check(&model.WAFRule{Action: model.WAFRuleAllow, Countries: []string{"jp"}}) == nil {
+		t.Fatal("expected unresolved address to be blocked by a country allow list")
+	}
+	if err := check(&model.WAFRule{Action: model.WAFRuleDeny, Countries: []string{"cn"}}); err != nil {
+		t.Fatalf("expected unresolved address to pass a country deny list: %v", err)
+	}
+	Conf.WAFUnresolvedAction = model.WAFRuleDeny
+	if check(&model.WAFRule{Action: model.WAFRuleDeny, Countries: []string{"cn"}}) == nil {
+		t.Fatal("expected configured unresolved action to apply")
+	}
+}
+
+func TestWAFRulePreviewCheck(t *testing.T) {
+	c := &WAFRuleClass{hits: make(map[uint64]uint64)}
+	c.snapshot.Store(newWAFRuleSnapshot(nil))
+
+	allow := &model.WAFRule{Action: model.WAFRuleAllow, Enabled: true, CIDRs: []string{"192.0.2.0/24"}}
+	if c.PreviewCheck("198.51.100.1", model.WAFSurfaceHTTP, func(rules map[uint64]*model.WAFRule) { rules[0] = allow }) == nil {
+		t.Fatal("expected allow rule excluding the admin address to be rejected")
+	}
+	if err := c.PreviewCheck("192.0.2.1", model.WAFSurfaceHTTP, func(rules map[uint64]*model.WAFRule) { rules[0] = allow }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(c.hits) != 0 {
+		t.Fatal("preview should not count hits")
+	}
+}